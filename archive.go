@@ -0,0 +1,180 @@
+package toolkit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ArchiveEntry is a single file to be included in an archive download. The content is read from Reader
+// if it is set, and from the file at Path otherwise.
+type ArchiveEntry struct {
+	Name    string    // the name of the file inside the archive, which may include directories (e.g. "docs/a.pdf")
+	Path    string    // path to a file on disk, used when Reader is nil
+	Reader  io.Reader // optional content to stream instead of a file on disk
+	Size    int64     // the size of Reader's content; needed for tar.gz archives, which otherwise buffer the content
+	ModTime time.Time // the modification time recorded in the archive; defaults to the file's, or now
+}
+
+// DownloadZip streams a zip archive built from entries to the client as a download named displayName.
+// Nothing is staged on disk: each entry is compressed and written as it is read. Since the response has
+// already started by the time an entry fails, errors are returned for logging rather than sent to the client.
+func (t *Tools) DownloadZip(w http.ResponseWriter, r *http.Request, displayName string, files []ArchiveEntry) error {
+	if err := checkArchiveEntries(files); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
+	w.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		err := func() error {
+			content, size, modTime, err := openArchiveEntry(f)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+
+			hdr := &zip.FileHeader{
+				Name:     archiveName(f.Name),
+				Method:   zip.Deflate,
+				Modified: modTime,
+			}
+			if size > 0 {
+				hdr.UncompressedSize64 = uint64(size)
+			}
+
+			out, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, content)
+			return err
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// DownloadTarGz streams a gzip compressed tar archive built from entries to the client as a download
+// named displayName. It behaves like DownloadZip, except that entries read from a Reader should set Size,
+// since tar needs to know the size of each file up front; otherwise the content is buffered in memory.
+func (t *Tools) DownloadTarGz(w http.ResponseWriter, r *http.Request, displayName string, files []ArchiveEntry) error {
+	if err := checkArchiveEntries(files); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
+	w.WriteHeader(http.StatusOK)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		err := func() error {
+			content, size, modTime, err := openArchiveEntry(f)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+
+			var body io.Reader = content
+			if size <= 0 {
+				b, err := io.ReadAll(content)
+				if err != nil {
+					return err
+				}
+				size, body = int64(len(b)), bytes.NewReader(b)
+			}
+
+			err = tw.WriteHeader(&tar.Header{
+				Name:     archiveName(f.Name),
+				Mode:     0644,
+				Size:     size,
+				ModTime:  modTime,
+				Typeflag: tar.TypeReg,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = io.CopyN(tw, body, size)
+			return err
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// checkArchiveEntries makes sure every entry has a usable name and a source, before any of the response
+// is written.
+func checkArchiveEntries(files []ArchiveEntry) error {
+	for _, f := range files {
+		name := archiveName(f.Name)
+		if name == "" || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid archive entry name %q", f.Name)
+		}
+		if f.Reader == nil && f.Path == "" {
+			return fmt.Errorf("archive entry %q has no content", f.Name)
+		}
+	}
+	if len(files) == 0 {
+		return errors.New("archive must contain at least one file")
+	}
+	return nil
+}
+
+// archiveName cleans an entry name, so that it is relative and can't escape the extraction directory.
+func archiveName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
+
+// openArchiveEntry returns the content of an entry, along with its size (or -1 if unknown) and
+// modification time.
+func openArchiveEntry(f ArchiveEntry) (io.ReadCloser, int64, time.Time, error) {
+	modTime := f.ModTime
+
+	if f.Reader != nil {
+		if modTime.IsZero() {
+			modTime = time.Now()
+		}
+		size := f.Size
+		if size == 0 {
+			size = -1
+		}
+		return io.NopCloser(f.Reader), size, modTime, nil
+	}
+
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, 0, modTime, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, modTime, err
+	}
+	if modTime.IsZero() {
+		modTime = info.ModTime()
+	}
+	return file, info.Size(), modTime, nil
+}
@@ -0,0 +1,124 @@
+package toolkit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// archiveEntries returns entries from disk, from a reader of known size, and from a reader of unknown
+// size whose name tries to escape the archive.
+func archiveEntries() []ArchiveEntry {
+	return []ArchiveEntry{
+		{Name: "images/pic.jpg", Path: "./testdata/pic.jpg"},
+		{Name: "notes.txt", Reader: strings.NewReader("some notes"), Size: 10},
+		{Name: "../../unknown.txt", Reader: strings.NewReader("no size")},
+	}
+}
+
+var archiveNames = map[string]int64{
+	"images/pic.jpg": 98827,
+	"notes.txt":      10,
+	"unknown.txt":    7,
+}
+
+func TestTools_DownloadZip(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+
+	err := testTools.DownloadZip(rr, req, "all.zip", archiveEntries())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Disposition") != "attachment; filename=\"all.zip\"" {
+		t.Error("wrong content disposition of", rr.Header().Get("Content-Disposition"))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(archiveNames) {
+		t.Fatalf("wrong number of files; expected %d but got %d", len(archiveNames), len(zr.File))
+	}
+	for _, f := range zr.File {
+		size, ok := archiveNames[f.Name]
+		if !ok {
+			t.Errorf("unexpected file %s in archive", f.Name)
+		}
+		if int64(f.UncompressedSize64) != size {
+			t.Errorf("%s: wrong size; expected %d but got %d", f.Name, size, f.UncompressedSize64)
+		}
+	}
+}
+
+func TestTools_DownloadTarGz(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+
+	err := testTools.DownloadTarGz(rr, req, "all.tar.gz", archiveEntries())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	count := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+		size, ok := archiveNames[hdr.Name]
+		if !ok {
+			t.Errorf("unexpected file %s in archive", hdr.Name)
+		}
+		if hdr.Size != size {
+			t.Errorf("%s: wrong size; expected %d but got %d", hdr.Name, size, hdr.Size)
+		}
+	}
+	if count != len(archiveNames) {
+		t.Errorf("wrong number of files; expected %d but got %d", len(archiveNames), count)
+	}
+}
+
+var invalidArchiveTests = []struct {
+	name    string
+	entries []ArchiveEntry
+}{
+	{name: "no entries", entries: nil},
+	{name: "no content", entries: []ArchiveEntry{{Name: "a.txt"}}},
+	{name: "no name", entries: []ArchiveEntry{{Name: "/", Path: "./testdata/pic.jpg"}}},
+}
+
+func TestTools_DownloadZip_Invalid(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range invalidArchiveTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+
+		if err := testTools.DownloadZip(rr, req, "all.zip", e.entries); err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("%s: nothing should be written for an invalid archive", e.name)
+		}
+	}
+}
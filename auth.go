@@ -0,0 +1,130 @@
+package toolkit
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// authUserKey and apiKeyKey are the context keys for the user and API key authenticated by BasicAuth and
+// APIKeyAuth.
+const (
+	authUserKey contextKey = "authUser"
+	apiKeyKey   contextKey = "apiKey"
+)
+
+// BasicAuthOptions configures the BasicAuth middleware. Set Users, Validate, or both.
+type BasicAuthOptions struct {
+	Realm    string                                                // realm sent in the WWW-Authenticate header; defaults to "Restricted"
+	Users    map[string]string                                     // usernames and their passwords, compared in constant time
+	Validate func(r *http.Request, username, password string) bool // checks credentials not in Users, e.g. against password hashes
+}
+
+// BasicAuth returns middleware which requires HTTP Basic authentication. Requests without valid credentials
+// get 401 Unauthorized through ErrorJSON, with a WWW-Authenticate header. The username is available to
+// handlers from AuthUserFromContext.
+func (t *Tools) BasicAuth(opts BasicAuthOptions) Middleware {
+	if opts.Realm == "" {
+		opts.Realm = "Restricted"
+	}
+	challenge := `Basic realm="` + strings.ReplaceAll(opts.Realm, `"`, `\"`) + `", charset="UTF-8"`
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok || !basicAuthValid(r, opts, username, password) {
+				w.Header().Set("WWW-Authenticate", challenge)
+				_ = t.ErrorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authUserKey, username)))
+		})
+	}
+}
+
+// basicAuthValid checks credentials against Users and then Validate. Hashes are compared so that the time
+// taken doesn't depend on the length or content of either password.
+func basicAuthValid(r *http.Request, opts BasicAuthOptions, username, password string) bool {
+	if expected, ok := opts.Users[username]; ok {
+		got, want := sha256.Sum256([]byte(password)), sha256.Sum256([]byte(expected))
+		if subtle.ConstantTimeCompare(got[:], want[:]) == 1 {
+			return true
+		}
+	}
+	return opts.Validate != nil && opts.Validate(r, username, password)
+}
+
+// AuthUserFromContext returns the username authenticated by BasicAuth, or an empty string if there isn't one.
+func AuthUserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(authUserKey).(string)
+	return user
+}
+
+// APIKey describes a valid API key, as returned by an APIKeyLookup.
+type APIKey struct {
+	ID       string  // identifies the key, or its owner, without revealing the key itself
+	Disabled bool    // if set to true, the key is recognised but refused with 403 Forbidden
+	Rate     float64 // requests per second allowed for the key by RateLimit, overriding its Rate; 0 uses RateLimit's own
+	Burst    int     // requests allowed in a burst for the key, when Rate is set; defaults to 1
+}
+
+// APIKeyLookup returns the APIKey for key, or nil if the key isn't known. Compare stored keys in constant
+// time, or look them up by a hash of the key.
+type APIKeyLookup func(ctx context.Context, key string) (*APIKey, error)
+
+// APIKeyOptions configures the APIKeyAuth middleware.
+type APIKeyOptions struct {
+	Header     string       // request header holding the key; defaults to "X-API-Key"
+	QueryParam string       // if set, the key may also be sent in this query parameter
+	Lookup     APIKeyLookup // finds the key; required
+}
+
+// APIKeyAuth returns middleware which requires an API key in the Header header, or the QueryParam query
+// parameter if it is set. Requests without a key, or with one Lookup doesn't know, get 401 Unauthorized,
+// and requests with a disabled key get 403 Forbidden, through ErrorJSON. The key is available to handlers
+// from APIKeyFromContext, and RateLimit applies its Rate and Burst, if it has them.
+func (t *Tools) APIKeyAuth(opts APIKeyOptions) Middleware {
+	if opts.Header == "" {
+		opts.Header = "X-API-Key"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(opts.Header)
+			if key == "" && opts.QueryParam != "" {
+				key = r.URL.Query().Get(opts.QueryParam)
+			}
+			if key == "" {
+				_ = t.ErrorJSON(w, errors.New("API key required"), http.StatusUnauthorized)
+				return
+			}
+
+			apiKey, err := opts.Lookup(r.Context(), key)
+			if err != nil {
+				t.LogError(r.Context(), "API key lookup error", "error", err)
+				_ = t.ErrorJSON(w, errors.New(http.StatusText(http.StatusInternalServerError)), http.StatusInternalServerError)
+				return
+			}
+			if apiKey == nil {
+				_ = t.ErrorJSON(w, errors.New("invalid API key"), http.StatusUnauthorized)
+				return
+			}
+			if apiKey.Disabled {
+				_ = t.ErrorJSON(w, errors.New("API key disabled"), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyKey, apiKey)))
+		})
+	}
+}
+
+// APIKeyFromContext returns the API key authenticated by APIKeyAuth, or nil if there isn't one.
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyKey).(*APIKey)
+	return key
+}
@@ -0,0 +1,160 @@
+package toolkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTools_BasicAuth(t *testing.T) {
+	var testTools Tools
+	var user string
+	handler := testTools.BasicAuth(BasicAuthOptions{
+		Realm: "Admin",
+		Users: map[string]string{"ann": "s3cret"},
+		Validate: func(r *http.Request, username, password string) bool {
+			return username == "bob" && password == "hunter2"
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = AuthUserFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name           string
+		username       string
+		password       string
+		noAuth         bool
+		expectedStatus int
+	}{
+		{name: "valid user", username: "ann", password: "s3cret", expectedStatus: http.StatusOK},
+		{name: "validated user", username: "bob", password: "hunter2", expectedStatus: http.StatusOK},
+		{name: "wrong password", username: "ann", password: "s3cret!", expectedStatus: http.StatusUnauthorized},
+		{name: "unknown user", username: "eve", password: "s3cret", expectedStatus: http.StatusUnauthorized},
+		{name: "no credentials", noAuth: true, expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, e := range tests {
+		user = ""
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if !e.noAuth {
+			req.SetBasicAuth(e.username, e.password)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if e.expectedStatus == http.StatusOK {
+			if user != e.username {
+				t.Errorf("%s: expected user %q in context but got %q", e.name, e.username, user)
+			}
+			continue
+		}
+		if got := rr.Header().Get("WWW-Authenticate"); got != `Basic realm="Admin", charset="UTF-8"` {
+			t.Errorf("%s: wrong challenge %q", e.name, got)
+		}
+		var resp JSONResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || !resp.Error {
+			t.Errorf("%s: expected a JSON error, got %s", e.name, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_APIKeyAuth(t *testing.T) {
+	keys := map[string]*APIKey{
+		"good":     {ID: "client-1"},
+		"disabled": {ID: "client-2", Disabled: true},
+	}
+	var testTools Tools
+	var got *APIKey
+	handler := testTools.APIKeyAuth(APIKeyOptions{
+		QueryParam: "api_key",
+		Lookup: func(ctx context.Context, key string) (*APIKey, error) {
+			if key == "broken" {
+				return nil, errors.New("database down")
+			}
+			return keys[key], nil
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = APIKeyFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name           string
+		header         string
+		query          string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "header", header: "good", expectedStatus: http.StatusOK},
+		{name: "query", query: "good", expectedStatus: http.StatusOK},
+		{name: "missing", expectedStatus: http.StatusUnauthorized, expectedError: "API key required"},
+		{name: "unknown", header: "bad", expectedStatus: http.StatusUnauthorized, expectedError: "invalid API key"},
+		{name: "disabled", header: "disabled", expectedStatus: http.StatusForbidden, expectedError: "API key disabled"},
+		{name: "lookup error", header: "broken", expectedStatus: http.StatusInternalServerError, expectedError: "Internal Server Error"},
+	}
+
+	for _, e := range tests {
+		got = nil
+		target := "/api"
+		if e.query != "" {
+			target += "?api_key=" + e.query
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if e.header != "" {
+			req.Header.Set("X-API-Key", e.header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if e.expectedStatus == http.StatusOK {
+			if got == nil || got.ID != "client-1" {
+				t.Errorf("%s: key not in context", e.name)
+			}
+			continue
+		}
+		var resp JSONResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Message != e.expectedError {
+			t.Errorf("%s: expected error %q but got %s", e.name, e.expectedError, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_RateLimit_APIKeyRate(t *testing.T) {
+	var testTools Tools
+	keys := map[string]*APIKey{
+		"premium": {ID: "premium", Rate: 1, Burst: 3},
+		"basic":   {ID: "basic"},
+	}
+	handler := testTools.APIKeyAuth(APIKeyOptions{
+		Lookup: func(ctx context.Context, key string) (*APIKey, error) { return keys[key], nil },
+	})(testTools.RateLimit(RateLimitOptions{Rate: 1, Burst: 1})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	allowed := func(key string) int {
+		n := 0
+		for i := 0; i < 5; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/api", nil)
+			req.Header.Set("X-API-Key", key)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code == http.StatusOK {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := allowed("premium"); n != 3 {
+		t.Errorf("expected the key's burst of 3 to be allowed, got %d", n)
+	}
+	if n := allowed("basic"); n != 1 {
+		t.Errorf("expected the default burst of 1 to be allowed, got %d", n)
+	}
+}
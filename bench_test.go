@@ -0,0 +1,132 @@
+package toolkit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// benchPayload is a moderately sized response, similar to a typical list endpoint.
+var benchPayload = func() JSONResponse {
+	items := make([]map[string]any, 50)
+	for i := range items {
+		items[i] = map[string]any{"id": i, "name": "item name", "tags": []string{"a", "b", "c"}, "active": true}
+	}
+	return JSONResponse{Message: "ok", Data: items}
+}()
+
+func BenchmarkTools_WriteJSON(b *testing.B) {
+	var testTools Tools
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		if err := testTools.WriteJSON(rr, http.StatusOK, benchPayload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTools_WriteJSON_Stream(b *testing.B) {
+	testTools := Tools{JSONOptions: JSONOptions{Stream: true}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := testTools.WriteJSON(discardResponseWriter{}, http.StatusOK, benchPayload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTools_WriteJSON_Buffered(b *testing.B) {
+	var testTools Tools
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := testTools.WriteJSON(discardResponseWriter{}, http.StatusOK, benchPayload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTools_ReadJSON(b *testing.B) {
+	var testTools Tools
+	body := []byte(`{"foo": "bar"}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		if err := testTools.ReadJSON(rr, req, &decodedJSON); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTools_UploadFiles(b *testing.B) {
+	var testTools Tools
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		req.Header.Add("Content-Type", contentType)
+
+		files, err := testTools.UploadFiles(req, "./testdata/uploads/")
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = os.Remove("./testdata/uploads/" + files[0].NewFileName)
+	}
+}
+
+func BenchmarkTools_RandomString(b *testing.B) {
+	var testTools Tools
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = testTools.RandomString(25)
+	}
+}
+
+// allocationBudgets are the maximum allocations per call allowed on the hot paths. If a change pushes
+// one of these over budget, either fix the regression or raise the budget deliberately.
+var allocationBudgets = []struct {
+	name   string
+	budget float64
+	fn     func()
+}{
+	{name: "RandomString", budget: 2, fn: func() {
+		var testTools Tools
+		_ = testTools.RandomString(25)
+	}},
+	{name: "WriteJSON", budget: 4, fn: func() {
+		var testTools Tools
+		_ = testTools.WriteJSON(discardResponseWriter{}, http.StatusOK, JSONResponse{Message: "ok"})
+	}},
+	{name: "WriteJSON (Stream)", budget: 4, fn: func() {
+		testTools := Tools{JSONOptions: JSONOptions{Stream: true}}
+		_ = testTools.WriteJSON(discardResponseWriter{}, http.StatusOK, JSONResponse{Message: "ok"})
+	}},
+}
+
+func TestPerformanceBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping allocation budgets in short mode")
+	}
+	for _, e := range allocationBudgets {
+		allocs := testing.AllocsPerRun(100, e.fn)
+		if allocs > e.budget {
+			t.Errorf("%s: %v allocations per call exceeds the budget of %v", e.name, allocs, e.budget)
+		}
+	}
+}
+
+// discardResponseWriter is a http.ResponseWriter which throws everything away, so that allocation
+// budgets only measure the toolkit.
+type discardResponseWriter struct{}
+
+var discardHeader = make(http.Header)
+
+func (discardResponseWriter) Header() http.Header         { return discardHeader }
+func (discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardResponseWriter) WriteHeader(int)             {}
@@ -0,0 +1,86 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// defaultBroadcastConcurrency is the number of targets pushed to at once, if BroadcastOptions doesn't set it.
+const defaultBroadcastConcurrency = 8
+
+// BroadcastOptions configures PushJSONToRemotes.
+type BroadcastOptions struct {
+	RemoteOptions     // options used for the call to each target
+	Concurrency   int // number of targets pushed to at once; defaults to 8
+}
+
+// RemoteResult is the outcome of pushing to one of the targets of PushJSONToRemotes.
+type RemoteResult struct {
+	URI    string // the target
+	Status int    // the response status code, or 0 if there was no response
+	Body   []byte // the response body, up to MaxResponseSize
+	Err    error  // the error, if any; a *RemoteError for a status outside the 2xx range
+}
+
+// PushJSONToRemotes posts the same data, as JSON, to each of uris, with at most Concurrency calls in flight
+// at once. The data is encoded once. It returns a result for each target, in the order of uris, and an
+// error joining the errors of the targets which failed, each prefixed with its URI. A target fails if the
+// call does, or if it responds with a status outside the 2xx range. A failing target doesn't stop the others.
+func (t *Tools) PushJSONToRemotes(uris []string, data interface{}, opts ...BroadcastOptions) ([]RemoteResult, error) {
+	var o BroadcastOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultBroadcastConcurrency
+	}
+
+	jsonData, err := t.remoteJSONBody(data)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]RemoteResult, len(uris))
+	sem := make(chan struct{}, o.Concurrency)
+	var wg sync.WaitGroup
+	for i, uri := range uris {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, uri string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = t.pushToTarget(context.Background(), uri, jsonData, o.RemoteOptions)
+		}(i, uri)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.URI, r.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// pushToTarget makes the call to a single target of PushJSONToRemotes, or of an Outbox message.
+func (t *Tools) pushToTarget(ctx context.Context, uri string, jsonData []byte, opts RemoteOptions) RemoteResult {
+	result := RemoteResult{URI: uri}
+
+	response, err := t.sendToRemote(ctx, uri, bytes.NewReader(jsonData), "application/json", opts)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer response.Body.Close()
+
+	result.Status = response.StatusCode
+	result.Body, _, result.Err = readRemoteBody(response.Body, opts.MaxResponseSize)
+	if result.Err == nil && (response.StatusCode < 200 || response.StatusCode > 299) {
+		result.Err = &RemoteError{Status: response.StatusCode, Body: result.Body}
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package toolkit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTools_PushJSONToRemotes(t *testing.T) {
+	var mu sync.Mutex
+	bodies := map[string]string{}
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		b, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		bodies[req.URL.Path] = string(b)
+		mu.Unlock()
+
+		status := http.StatusOK
+		if req.URL.Path == "/down" {
+			status = http.StatusServiceUnavailable
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("from " + req.URL.Path)), Header: make(http.Header)}
+	})
+
+	var testTools Tools
+	uris := []string{"http://example.com/a", "http://example.com/down", "http://example.com/b"}
+	results, err := testTools.PushJSONToRemotes(uris, map[string]string{"event": "created"}, BroadcastOptions{
+		RemoteOptions: RemoteOptions{Client: client},
+	})
+
+	if err == nil || err.Error() != "http://example.com/down: remote server returned status 503: from /down" {
+		t.Errorf("wrong aggregate error: %v", err)
+	}
+	var remoteErr *RemoteError
+	if !errors.As(err, &remoteErr) || remoteErr.Status != http.StatusServiceUnavailable {
+		t.Errorf("expected a RemoteError in the aggregate error, got %v", err)
+	}
+
+	if len(results) != len(uris) {
+		t.Fatalf("expected %d results but got %d", len(uris), len(results))
+	}
+	for i, r := range results {
+		if r.URI != uris[i] {
+			t.Errorf("result %d is for %s, expected %s", i, r.URI, uris[i])
+		}
+		if bodies[strings.TrimPrefix(r.URI, "http://example.com")] != `{"event":"created"}` {
+			t.Errorf("%s: wrong request body", r.URI)
+		}
+		if string(r.Body) != "from "+strings.TrimPrefix(r.URI, "http://example.com") {
+			t.Errorf("%s: wrong response body %q", r.URI, r.Body)
+		}
+	}
+	if results[0].Err != nil || results[0].Status != http.StatusOK {
+		t.Errorf("first target should have succeeded: %+v", results[0])
+	}
+	if results[1].Err == nil || results[1].Status != http.StatusServiceUnavailable {
+		t.Errorf("second target should have failed: %+v", results[1])
+	}
+}
+
+func TestTools_PushJSONToRemotesConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}
+	})
+
+	uris := make([]string, 10)
+	for i := range uris {
+		uris[i] = "http://example.com/hook"
+	}
+
+	var testTools Tools
+	results, err := testTools.PushJSONToRemotes(uris, "ping", BroadcastOptions{
+		RemoteOptions: RemoteOptions{Client: client},
+		Concurrency:   3,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(results) != len(uris) {
+		t.Errorf("expected %d results but got %d", len(uris), len(results))
+	}
+	if got := atomic.LoadInt32(&maxInFlight); got > 3 || got < 2 {
+		t.Errorf("expected up to 3 calls at once but got %d", got)
+	}
+}
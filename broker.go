@@ -0,0 +1,367 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrSkipRetry can be wrapped by the error a Consume handler returns to send the message to the dead letter
+// topic straight away, for failures which retrying won't fix, such as a reference to a deleted record.
+var ErrSkipRetry = errors.New("not retrying")
+
+// defaultBrokerMaxBackoff is the longest delay between retries, if PublishOptions or ConsumeOptions doesn't
+// set one.
+const defaultBrokerMaxBackoff = time.Minute
+
+// Message is a message published to, or received from, a Broker topic (a queue, subject or routing key,
+// depending on the broker).
+type Message struct {
+	Topic   string
+	Body    []byte
+	Headers map[string]string
+}
+
+// Delivery is a Message received from a Broker, which the consumer must acknowledge once it has been
+// handled, or reject to have it redelivered, or dropped.
+type Delivery struct {
+	Message
+	Ack  func() error
+	Nack func(requeue bool) error
+}
+
+// Broker is a message broker, such as RabbitMQ or NATS, with which PublishJSON and Consume exchange messages.
+// Implementations only move messages: retries and dead letters are handled by Consume, so an adapter for a
+// broker's client library is a few lines. MemoryBroker, which delivers messages within the process, is the
+// only implementation included.
+type Broker interface {
+	// Publish sends msg to msg.Topic.
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe returns the deliveries from topic, until ctx is done, when the channel is closed. Each
+	// message is delivered to one of the topic's subscribers.
+	Subscribe(ctx context.Context, topic string) (<-chan Delivery, error)
+}
+
+// PublishOptions configures PublishJSON, the way RemoteOptions configures PushJSONToRemoteInto.
+type PublishOptions struct {
+	Headers      map[string]string // extra headers sent with the message
+	Timeout      time.Duration     // limit on the whole call, including retries
+	Retries      int               // number of times to retry after the broker fails to accept the message
+	RetryBackoff time.Duration     // delay before the first retry, doubled for each one after; defaults to 200ms
+	MaxBackoff   time.Duration     // longest delay between retries; defaults to 1 minute
+}
+
+// Headers set on messages by PublishJSON and Consume.
+const (
+	headerContentType   = "Content-Type"
+	headerAttempt       = "X-Attempt"        // the delivery attempt, from 1, of a message retried by Consume
+	headerError         = "X-Error"          // why a dead letter failed
+	headerOriginalTopic = "X-Original-Topic" // the topic a dead letter was consumed from
+)
+
+// PublishJSON publishes payload to topic as JSON, encoded the same way as PushJSONToRemote encodes it, with
+// a Content-Type of application/json. Like PushJSONToRemoteInto, failed attempts are retried, with backoff,
+// if opts.Retries is set, and the payload is logged, redacted, in DebugMode.
+func (t *Tools) PublishJSON(ctx context.Context, b Broker, topic string, payload any, opts ...PublishOptions) error {
+	var o PublishOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	body, err := t.remoteJSONBody(payload)
+	if err != nil {
+		return err
+	}
+	headers := make(map[string]string, len(o.Headers)+1)
+	for k, v := range o.Headers {
+		headers[k] = v
+	}
+	headers[headerContentType] = "application/json"
+
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	backoff := o.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	maxBackoff := o.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultBrokerMaxBackoff
+	}
+
+	msg := Message{Topic: topic, Body: body, Headers: headers}
+	for attempt := 0; ; attempt++ {
+		err := b.Publish(ctx, msg)
+		if err == nil || attempt >= o.Retries {
+			return err
+		}
+		t.LogWarn(ctx, "publish failed, retrying", "topic", topic, "error", err, "attempt", attempt+1)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryBackoff(backoff, maxBackoff, attempt+1)):
+		}
+	}
+}
+
+// retryBackoff returns the delay before the given retry, counting from 1: backoff, doubled for each retry
+// after the first, up to maxBackoff. Doubling stops at maxBackoff, so many retries can't overflow.
+func retryBackoff(backoff, maxBackoff time.Duration, retry int) time.Duration {
+	for i := 1; i < retry && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// ConsumeOptions configures Consume.
+type ConsumeOptions struct {
+	Concurrency     int           // number of messages handled at once; defaults to 1
+	MaxAttempts     int           // times a message is handled before it is dead-lettered; defaults to 5
+	RetryBackoff    time.Duration // delay before the first retry, doubled for each one after; defaults to 1 second
+	MaxBackoff      time.Duration // longest delay between retries; defaults to 1 minute
+	DeadLetterTopic string        // topic failed messages are published to; defaults to the topic with ".dead" added
+}
+
+// Consume handles the messages published to topic until ctx is done, decoding each into a T with the same
+// limits and checks as ReadJSON (MaxJSONSize, MaxDepth and AllowUnknownFields), and passing it to handler
+// with the message. A message is acknowledged once handler returns nil. If it returns an error or panics,
+// the message is published again after a backoff, with its attempt in an X-Attempt header, until it has
+// been tried MaxAttempts times; it is then published to the dead letter topic, with the error in an X-Error
+// header. Messages which can't be decoded, and errors wrapping ErrSkipRetry, are dead-lettered straight
+// away. Consume returns when the subscription ends, with ctx's error if it is done.
+func Consume[T any](ctx context.Context, t *Tools, b Broker, topic string, handler func(ctx context.Context, v T, msg Message) error, opts ConsumeOptions) error {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultBrokerMaxBackoff
+	}
+	if opts.DeadLetterTopic == "" {
+		opts.DeadLetterTopic = topic + ".dead"
+	}
+
+	deliveries, err := b.Subscribe(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	c := &consumer[T]{tools: t, broker: b, topic: topic, handler: handler, opts: opts}
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				c.process(ctx, d)
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// consumer handles the deliveries of one Consume call.
+type consumer[T any] struct {
+	tools   *Tools
+	broker  Broker
+	topic   string
+	handler func(ctx context.Context, v T, msg Message) error
+	opts    ConsumeOptions
+}
+
+// process handles one delivery, retrying or dead-lettering it if handling fails.
+func (c *consumer[T]) process(ctx context.Context, d Delivery) {
+	var v T
+	if err := c.tools.decodeMessageJSON(d.Body, &v); err != nil {
+		c.deadLetter(ctx, d, err)
+		return
+	}
+
+	err := c.handle(ctx, v, d.Message)
+	if err == nil {
+		c.settle(ctx, d.Ack())
+		return
+	}
+
+	attempt, _ := strconv.Atoi(d.Headers[headerAttempt])
+	attempt = max(attempt, 1)
+	if attempt >= c.opts.MaxAttempts || errors.Is(err, ErrSkipRetry) {
+		c.deadLetter(ctx, d, err)
+		return
+	}
+
+	c.tools.LogWarn(ctx, "message handler failed, retrying", "topic", c.topic, "attempt", attempt, "error", err)
+	select {
+	case <-ctx.Done():
+		// leave it to the broker to redeliver
+		c.settle(ctx, d.Nack(true))
+		return
+	case <-time.After(retryBackoff(c.opts.RetryBackoff, c.opts.MaxBackoff, attempt)):
+	}
+
+	retry := Message{Topic: c.topic, Body: d.Body, Headers: withHeader(d.Headers, headerAttempt, strconv.Itoa(attempt+1))}
+	if err := c.broker.Publish(ctx, retry); err != nil {
+		c.tools.LogError(ctx, "republishing message failed", "topic", c.topic, "error", err)
+		c.settle(ctx, d.Nack(true))
+		return
+	}
+	c.settle(ctx, d.Ack())
+}
+
+// handle calls the handler, turning a panic into an error so one bad message can't stop the consumer.
+func (c *consumer[T]) handle(ctx context.Context, v T, msg Message) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+	return c.handler(ctx, v, msg)
+}
+
+// deadLetter publishes d to the dead letter topic, with the reason it failed, and acknowledges it.
+func (c *consumer[T]) deadLetter(ctx context.Context, d Delivery, reason error) {
+	c.tools.LogError(ctx, "message failed, dead-lettering", "topic", c.topic, "dead_letter_topic", c.opts.DeadLetterTopic, "error", reason)
+
+	headers := withHeader(d.Headers, headerError, reason.Error())
+	headers[headerOriginalTopic] = c.topic
+	if err := c.broker.Publish(ctx, Message{Topic: c.opts.DeadLetterTopic, Body: d.Body, Headers: headers}); err != nil {
+		c.tools.LogError(ctx, "dead-lettering message failed", "topic", c.topic, "error", err)
+		c.settle(ctx, d.Nack(true))
+		return
+	}
+	c.settle(ctx, d.Ack())
+}
+
+// settle logs the error from acknowledging or rejecting a delivery, if there is one.
+func (c *consumer[T]) settle(ctx context.Context, err error) {
+	if err != nil {
+		c.tools.LogError(ctx, "settling message failed", "topic", c.topic, "error", err)
+	}
+}
+
+// withHeader returns a copy of headers with key set to value.
+func withHeader(headers map[string]string, key, value string) map[string]string {
+	h := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		h[k] = v
+	}
+	h[key] = value
+	return h
+}
+
+// decodeMessageJSON decodes a message body into data with the limits ReadJSON applies to request bodies.
+func (t *Tools) decodeMessageJSON(body []byte, data any) error {
+	maxBytes := t.bodyLimit(t.MaxJSONSize, defaultMaxUpload)
+	if len(body) > maxBytes {
+		return newMessageError(MsgTooLarge, "message", maxBytes)
+	}
+
+	dec := json.NewDecoder(t.limitJSONDepth(bytes.NewReader(body), "message"))
+	if !t.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(data); err != nil {
+		return jsonDecodeError("message", err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return newMessageError(MsgJSONOneValue, "message")
+	}
+	return nil
+}
+
+// MemoryBroker is a Broker which delivers messages within the process, for tests, and for apps which
+// don't need a broker yet. Each topic is a queue whose messages go to one of its subscribers. Messages
+// are lost when the process exits.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string]chan Message
+	size   int
+}
+
+// NewMemoryBroker returns a MemoryBroker whose topics each hold up to size messages (1000 if size is 0)
+// waiting for a subscriber; Publish blocks while a topic is full.
+func NewMemoryBroker(size int) *MemoryBroker {
+	if size <= 0 {
+		size = 1000
+	}
+	return &MemoryBroker{topics: make(map[string]chan Message), size: size}
+}
+
+// queue returns the channel holding topic's messages, creating it if needed.
+func (m *MemoryBroker) queue(topic string) chan Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.topics[topic]
+	if !ok {
+		q = make(chan Message, m.size)
+		m.topics[topic] = q
+	}
+	return q
+}
+
+// Publish queues msg on its topic.
+func (m *MemoryBroker) Publish(ctx context.Context, msg Message) error {
+	select {
+	case m.queue(msg.Topic) <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe returns the deliveries from topic. A message rejected with Nack(true) is queued again.
+func (m *MemoryBroker) Subscribe(ctx context.Context, topic string) (<-chan Delivery, error) {
+	q := m.queue(topic)
+	out := make(chan Delivery)
+
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-q:
+				d := Delivery{
+					Message: msg,
+					Ack:     func() error { return nil },
+					Nack: func(requeue bool) error {
+						if requeue {
+							return m.Publish(context.Background(), msg)
+						}
+						return nil
+					},
+				}
+				select {
+				case out <- d:
+				case <-ctx.Done():
+					// put it back for the next subscriber, without blocking if the topic has since filled up
+					go func() { q <- msg }()
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Len returns the number of messages waiting on topic.
+func (m *MemoryBroker) Len(topic string) int {
+	return len(m.queue(topic))
+}
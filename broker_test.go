@@ -0,0 +1,215 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type brokerOrder struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+}
+
+// flakyBroker fails the first failures publishes, then passes them to a MemoryBroker.
+type flakyBroker struct {
+	*MemoryBroker
+	failures  int32
+	published atomic.Int32
+}
+
+func (f *flakyBroker) Publish(ctx context.Context, msg Message) error {
+	if f.published.Add(1) <= f.failures {
+		return errors.New("connection reset")
+	}
+	return f.MemoryBroker.Publish(ctx, msg)
+}
+
+// receive returns the next message on topic, failing the test if there is none within a second.
+func receive(t *testing.T, b Broker, topic string) Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	deliveries, err := b.Subscribe(ctx, topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, ok := <-deliveries
+	if !ok {
+		t.Fatalf("expected a message on %s", topic)
+	}
+	_ = d.Ack()
+	return d.Message
+}
+
+func TestTools_PublishJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int32
+		retries   int
+		expectErr bool
+		published int32
+	}{
+		{name: "published", published: 1},
+		{name: "retried", failures: 2, retries: 2, published: 3},
+		{name: "out of retries", failures: 2, retries: 1, expectErr: true, published: 2},
+	}
+
+	for _, e := range tests {
+		var testTools Tools
+		b := &flakyBroker{MemoryBroker: NewMemoryBroker(0), failures: e.failures}
+
+		err := testTools.PublishJSON(context.Background(), b, "orders", brokerOrder{ID: 1, Email: "a@example.com"}, PublishOptions{
+			Headers:      map[string]string{"X-Tenant": "acme"},
+			Retries:      e.retries,
+			RetryBackoff: time.Millisecond,
+		})
+		if e.expectErr != (err != nil) {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+		if got := b.published.Load(); got != e.published {
+			t.Errorf("%s: expected %d attempts, got %d", e.name, e.published, got)
+		}
+		if e.expectErr {
+			continue
+		}
+
+		msg := receive(t, b, "orders")
+		if string(msg.Body) != `{"id":1,"email":"a@example.com"}` {
+			t.Errorf("%s: unexpected body %s", e.name, msg.Body)
+		}
+		if msg.Headers["Content-Type"] != "application/json" || msg.Headers["X-Tenant"] != "acme" {
+			t.Errorf("%s: unexpected headers %v", e.name, msg.Headers)
+		}
+	}
+}
+
+func TestConsume(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		failures   int // times the handler fails before succeeding
+		err        error
+		panics     bool
+		handled    int32
+		deadLetter string // expected X-Error of the dead letter; empty if there shouldn't be one
+	}{
+		{name: "handled", body: `{"id":1,"email":"a@example.com"}`, handled: 1},
+		{name: "retried", body: `{"id":1}`, failures: 2, handled: 3},
+		{name: "out of attempts", body: `{"id":1}`, failures: 5, handled: 3, deadLetter: "payment declined"},
+		{name: "skip retry", body: `{"id":1}`, failures: 5, err: ErrSkipRetry, handled: 1, deadLetter: "not retrying"},
+		{name: "panic", body: `{"id":1}`, failures: 5, panics: true, handled: 3, deadLetter: "panic: payment declined"},
+		{name: "invalid json", body: `{"id":`, deadLetter: "message contains badly-formed JSON"},
+		{name: "unknown field", body: `{"id":1,"total":5}`, deadLetter: `message contains unknown key  "total"`},
+		{name: "several values", body: `{"id":1}{"id":2}`, deadLetter: "message must contain only one JSON value"},
+	}
+
+	for _, e := range tests {
+		var testTools Tools
+		b := NewMemoryBroker(0)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var handled atomic.Int32
+		done := make(chan struct{})
+		handler := func(ctx context.Context, order brokerOrder, msg Message) error {
+			n := handled.Add(1)
+			if order.ID != 1 {
+				t.Errorf("%s: unexpected order %+v", e.name, order)
+			}
+			if int(n) <= e.failures {
+				err := errors.New("payment declined")
+				if e.err != nil {
+					err = e.err
+				}
+				if e.panics {
+					panic(err.Error())
+				}
+				return err
+			}
+			close(done)
+			return nil
+		}
+
+		result := make(chan error, 1)
+		go func() {
+			result <- Consume(ctx, &testTools, b, "orders", handler, ConsumeOptions{MaxAttempts: 3, RetryBackoff: time.Millisecond})
+		}()
+		if err := b.Publish(ctx, Message{Topic: "orders", Body: []byte(e.body)}); err != nil {
+			t.Fatal(err)
+		}
+
+		if e.deadLetter != "" {
+			msg := receive(t, b, "orders.dead")
+			if !strings.Contains(msg.Headers["X-Error"], e.deadLetter) {
+				t.Errorf("%s: expected the error %q, got %q", e.name, e.deadLetter, msg.Headers["X-Error"])
+			}
+			if msg.Headers["X-Original-Topic"] != "orders" || string(msg.Body) != e.body {
+				t.Errorf("%s: unexpected dead letter %+v", e.name, msg)
+			}
+		} else {
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: the message wasn't handled", e.name)
+			}
+		}
+
+		cancel()
+		if err := <-result; !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected Consume to stop with the context, got %v", e.name, err)
+		}
+		if got := handled.Load(); got != e.handled {
+			t.Errorf("%s: expected the handler to be called %d times, got %d", e.name, e.handled, got)
+		}
+		if e.deadLetter == "" && b.Len("orders.dead") != 0 {
+			t.Errorf("%s: expected no dead letters", e.name)
+		}
+	}
+}
+
+func TestMemoryBroker_Nack(t *testing.T) {
+	b := NewMemoryBroker(0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_ = b.Publish(ctx, Message{Topic: "orders", Body: []byte(`{}`)})
+	deliveries, _ := b.Subscribe(ctx, "orders")
+
+	d := <-deliveries
+	if err := d.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	d = <-deliveries
+	if string(d.Body) != `{}` {
+		t.Errorf("expected the requeued message, got %s", d.Body)
+	}
+	if err := d.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if n := b.Len("orders"); n != 0 {
+		t.Errorf("expected the rejected message to be dropped, got %d waiting", n)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		retry    int
+		expected time.Duration
+	}{
+		{name: "first retry", retry: 1, expected: time.Second},
+		{name: "doubled", retry: 3, expected: 4 * time.Second},
+		{name: "capped", retry: 10, expected: time.Minute},
+		{name: "past the overflow of a shift", retry: 100, expected: time.Minute},
+	}
+
+	for _, e := range tests {
+		if got := retryBackoff(time.Second, time.Minute, e.retry); got != e.expected {
+			t.Errorf("%s: wrong backoff; expected %s but got %s", e.name, e.expected, got)
+		}
+	}
+}
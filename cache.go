@@ -0,0 +1,248 @@
+package toolkit
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Cache is a key-value store for values which can be recomputed, such as rendered responses or the results
+// of slow queries. Values are bytes, so caches shared between instances, such as Redis, can implement it;
+// Remember and GetOrSet store any value by encoding it as JSON. A ttl of zero means the entry doesn't
+// expire, although a cache may still evict it to make room.
+type Cache interface {
+	// Get returns the value stored for key, and false if there is none or it has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value for key, for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the entry for key, if there is one.
+	Delete(ctx context.Context, key string) error
+	// TTL returns the time left before the entry for key expires, or 0 if it doesn't, and false if there
+	// is no entry.
+	TTL(ctx context.Context, key string) (time.Duration, bool, error)
+}
+
+// Remember returns the value cached for key, or else computes it with fn and caches it for ttl. Errors
+// from the cache, and cached values which can't be decoded into a T, are treated as misses, since the
+// cache is only an optimization; errors from fn are returned, and not cached.
+func Remember[T any](ctx context.Context, c Cache, key string, ttl time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	var value T
+	if b, ok, err := c.Get(ctx, key); err == nil && ok && json.Unmarshal(b, &value) == nil {
+		return value, nil
+	}
+
+	value, err := fn(ctx)
+	if err != nil {
+		return value, err
+	}
+	if b, err := json.Marshal(value); err == nil {
+		_ = c.Set(ctx, key, b, ttl)
+	}
+	return value, nil
+}
+
+// GetOrSet returns the value cached for key, reporting true, or else caches value for ttl and returns it,
+// reporting false. The check and the store are atomic with a MemoryCache, but may not be with other caches.
+func GetOrSet[T any](ctx context.Context, c Cache, key string, value T, ttl time.Duration) (T, bool, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return value, false, err
+	}
+
+	if m, ok := c.(*MemoryCache); ok {
+		existing, loaded := m.getOrSet(key, b, ttl)
+		if !loaded {
+			return value, false, nil
+		}
+		var actual T
+		err := json.Unmarshal(existing, &actual)
+		return actual, true, err
+	}
+
+	existing, ok, err := c.Get(ctx, key)
+	if err != nil {
+		return value, false, err
+	}
+	if ok {
+		var actual T
+		err := json.Unmarshal(existing, &actual)
+		return actual, true, err
+	}
+	return value, false, c.Set(ctx, key, b, ttl)
+}
+
+// memoryCacheShards is the number of shards a MemoryCache is split into, so concurrent requests rarely
+// wait for the same lock.
+const memoryCacheShards = 16
+
+// MemoryCache is a Cache which keeps entries in memory, split into shards by the hash of their key. Each
+// shard discards its least recently used entries once it holds its share of the maximum, and expired
+// entries are discarded from time to time, so memory use stays bounded.
+type MemoryCache struct {
+	shards [memoryCacheShards]*cacheShard
+	now    func() time.Time
+}
+
+// cacheShard is one shard of a MemoryCache: a map for lookups, and a list from the most to the least
+// recently used entry.
+type cacheShard struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	maxEntries int
+	lastSweep  time.Time
+}
+
+// cacheEntry is an entry in a cacheShard.
+type cacheEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero if the entry doesn't expire
+}
+
+// NewMemoryCache returns an empty MemoryCache holding at most maxEntries entries; 0 means no limit.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	c := &MemoryCache{now: time.Now}
+	perShard := 0
+	if maxEntries > 0 {
+		perShard = max(1, (maxEntries+memoryCacheShards-1)/memoryCacheShards)
+	}
+	for i := range c.shards {
+		c.shards[i] = &cacheShard{entries: make(map[string]*list.Element), lru: list.New(), maxEntries: perShard}
+	}
+	return c
+}
+
+// Get returns the value stored for key, marking it as recently used.
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.lookup(key, c.now())
+	if e == nil {
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+// Set stores value for key. The cache keeps value, so the caller must not change it afterwards.
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.store(key, value, ttl, c.now())
+	return nil
+}
+
+// Delete removes the entry for key.
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// TTL returns the time left before the entry for key expires.
+func (c *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := c.now()
+	e := s.lookup(key, now)
+	switch {
+	case e == nil:
+		return 0, false, nil
+	case e.expires.IsZero():
+		return 0, true, nil
+	}
+	return e.expires.Sub(now), true, nil
+}
+
+// getOrSet returns the value stored for key, or else stores value, atomically.
+func (c *MemoryCache) getOrSet(key string, value []byte, ttl time.Duration) ([]byte, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := c.now()
+	if e := s.lookup(key, now); e != nil {
+		return e.value, true
+	}
+	s.store(key, value, ttl, now)
+	return nil, false
+}
+
+// shard returns the shard holding key.
+func (c *MemoryCache) shard(key string) *cacheShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return c.shards[h.Sum32()%memoryCacheShards]
+}
+
+// lookup returns the live entry for key, marking it as recently used, or nil. An expired entry is
+// discarded. The caller must hold the lock.
+func (s *cacheShard) lookup(key string, now time.Time) *cacheEntry {
+	el, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*cacheEntry)
+	if !e.expires.IsZero() && !now.Before(e.expires) {
+		s.remove(el)
+		return nil
+	}
+	s.lru.MoveToFront(el)
+	return e
+}
+
+// store adds or replaces the entry for key, evicting the least recently used entries if the shard is
+// full. The caller must hold the lock.
+func (s *cacheShard) store(key string, value []byte, ttl time.Duration, now time.Time) {
+	s.sweep(now)
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = now.Add(ttl)
+	}
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.value, e.expires = value, expires
+		s.lru.MoveToFront(el)
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+}
+
+// remove discards the entry in el. The caller must hold the lock.
+func (s *cacheShard) remove(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.entries, el.Value.(*cacheEntry).key)
+}
+
+// sweep discards expired entries, at most once a minute. The caller must hold the lock.
+func (s *cacheShard) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for _, el := range s.entries {
+		if e := el.Value.(*cacheEntry); !e.expires.IsZero() && !now.Before(e.expires) {
+			s.remove(el)
+		}
+	}
+}
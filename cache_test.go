@@ -0,0 +1,184 @@
+package toolkit
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache(0)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, ok, _ := cache.Get(ctx, "a"); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+
+	_ = cache.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = cache.Set(ctx, "forever", []byte("2"), 0)
+	if v, ok, _ := cache.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Fatalf("expected 1, got %q", v)
+	}
+	if ttl, ok, _ := cache.TTL(ctx, "a"); !ok || ttl != time.Minute {
+		t.Errorf("expected a minute left, got %s", ttl)
+	}
+	if ttl, ok, _ := cache.TTL(ctx, "forever"); !ok || ttl != 0 {
+		t.Errorf("expected no expiry, got %s", ttl)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := cache.Get(ctx, "a"); ok {
+		t.Error("expected the entry to have expired")
+	}
+	if _, ok, _ := cache.TTL(ctx, "a"); ok {
+		t.Error("expected no TTL for an expired entry")
+	}
+
+	_ = cache.Delete(ctx, "forever")
+	if _, ok, _ := cache.Get(ctx, "forever"); ok {
+		t.Error("expected the entry to have been deleted")
+	}
+
+	// expired entries are swept
+	_ = cache.Set(ctx, "b", []byte("3"), time.Second)
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < memoryCacheShards*4; i++ {
+		_ = cache.Set(ctx, fmt.Sprint("c", i), nil, 0)
+	}
+	if el := cache.shard("b").entries["b"]; el != nil {
+		t.Error("expected the expired entry to have been swept")
+	}
+}
+
+func TestMemoryCache_Eviction(t *testing.T) {
+	cache := NewMemoryCache(memoryCacheShards)
+	ctx := context.Background()
+
+	// find three keys in the same shard, which holds one entry
+	var keys []string
+	for i := 0; len(keys) < 3; i++ {
+		key := fmt.Sprint("k", i)
+		if cache.shard(key) == cache.shard("k0") {
+			keys = append(keys, key)
+		}
+	}
+
+	_ = cache.Set(ctx, keys[0], []byte("1"), 0)
+	_ = cache.Set(ctx, keys[1], []byte("2"), 0)
+	if _, ok, _ := cache.Get(ctx, keys[0]); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if _, ok, _ := cache.Get(ctx, keys[1]); !ok {
+		t.Error("expected the newest entry to be kept")
+	}
+}
+
+func TestMemoryCache_LRU(t *testing.T) {
+	shard := &cacheShard{entries: make(map[string]*list.Element), lru: list.New(), maxEntries: 2}
+	now := time.Now()
+
+	shard.store("a", nil, 0, now)
+	shard.store("b", nil, 0, now)
+	shard.lookup("a", now) // a is now the most recently used
+	shard.store("c", nil, 0, now)
+
+	if _, ok := shard.entries["b"]; ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := shard.entries[key]; !ok {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+}
+
+func TestRemember(t *testing.T) {
+	cache := NewMemoryCache(0)
+	ctx := context.Background()
+	calls := 0
+	load := func(ctx context.Context) ([]string, error) {
+		calls++
+		return []string{"go", "rust"}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := Remember(ctx, cache, "languages", time.Minute, load)
+		if err != nil || len(v) != 2 || v[0] != "go" {
+			t.Fatalf("unexpected result %v, %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the value to be computed once, got %d", calls)
+	}
+
+	// errors aren't cached
+	failing := func(ctx context.Context) (int, error) {
+		calls++
+		return 0, errors.New("database down")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := Remember(ctx, cache, "count", time.Minute, failing); err == nil {
+			t.Error("expected the error to be returned")
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected the failing function to be called each time, got %d calls", calls)
+	}
+
+	// a value which can't be decoded is recomputed
+	_ = cache.Set(ctx, "count", []byte("not json"), 0)
+	n, err := Remember(ctx, cache, "count", time.Minute, func(ctx context.Context) (int, error) { return 7, nil })
+	if err != nil || n != 7 {
+		t.Errorf("expected 7, got %d, %v", n, err)
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	ctx := context.Background()
+
+	for _, cache := range []Cache{NewMemoryCache(0), &wrappedCache{NewMemoryCache(0)}} {
+		v, loaded, err := GetOrSet(ctx, cache, "slug:hello-world", 1, time.Minute)
+		if err != nil || loaded || v != 1 {
+			t.Errorf("%T: expected the value to be stored, got %d, %t, %v", cache, v, loaded, err)
+		}
+		v, loaded, err = GetOrSet(ctx, cache, "slug:hello-world", 2, time.Minute)
+		if err != nil || !loaded || v != 1 {
+			t.Errorf("%T: expected the stored value, got %d, %t, %v", cache, v, loaded, err)
+		}
+	}
+}
+
+func TestGetOrSet_Concurrent(t *testing.T) {
+	cache := NewMemoryCache(0)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	stored := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, loaded, _ := GetOrSet(ctx, cache, "k", i, 0); !loaded {
+				mu.Lock()
+				stored++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if stored != 1 {
+		t.Errorf("expected exactly one value to be stored, got %d", stored)
+	}
+}
+
+// wrappedCache hides a MemoryCache behind the Cache interface, to test the generic paths.
+type wrappedCache struct {
+	Cache
+}
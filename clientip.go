@@ -0,0 +1,108 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIP returns the IP address of the client which made the request. If the request arrived from one
+// of the TrustedProxies, the Forwarded, X-Forwarded-For and X-Real-IP headers (in that order) are used to
+// find the address the proxies received it from: the chain of addresses is walked from the nearest hop
+// backwards, and the first address which isn't a trusted proxy is returned. Headers from untrusted
+// connections are ignored, since anybody can set them.
+func (t *Tools) ClientIP(r *http.Request) string {
+	remote := remoteIP(r)
+	trusted := parseTrustedProxies(t.TrustedProxies)
+	if !isTrustedProxy(remote, trusted) {
+		return remote
+	}
+
+	chain := forwardedFor(r.Header.Values("Forwarded"))
+	if len(chain) == 0 {
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			for _, part := range strings.Split(v, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					chain = append(chain, part)
+				}
+			}
+		}
+	}
+	if len(chain) == 0 {
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			chain = []string{ip}
+		}
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := normalizeIP(chain[i])
+		if ip == "" {
+			// an address we can't parse can't be trusted, so stop at the last good one
+			break
+		}
+		if !isTrustedProxy(ip, trusted) || i == 0 {
+			return ip
+		}
+	}
+
+	return remote
+}
+
+// forwardedFor returns the for= addresses from Forwarded headers (RFC 7239), in order.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					chain = append(chain, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	return chain
+}
+
+// normalizeIP strips any port and IPv6 brackets from an address, returning an empty string if what is
+// left isn't an IP address.
+func normalizeIP(s string) string {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap().String()
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap().String()
+	}
+	return ""
+}
+
+// parseTrustedProxies parses IP addresses and CIDR ranges, ignoring any which are invalid.
+func parseTrustedProxies(proxies []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, p := range proxies {
+		if prefix, err := netip.ParsePrefix(p); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(p); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// isTrustedProxy reports whether ip falls within one of the trusted prefixes.
+func isTrustedProxy(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var clientIPTests = []struct {
+	name       string
+	remoteAddr string
+	headers    map[string]string
+	trusted    []string
+	expected   string
+}{
+	{name: "no proxy", remoteAddr: "203.0.113.7:1234", expected: "203.0.113.7"},
+	{name: "untrusted proxy headers ignored", remoteAddr: "203.0.113.7:1234", headers: map[string]string{"X-Forwarded-For": "1.2.3.4"}, expected: "203.0.113.7"},
+	{name: "trusted proxy", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "198.51.100.2"}, trusted: []string{"10.0.0.0/8"}, expected: "198.51.100.2"},
+	{name: "spoofed entry before real client", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.2"}, trusted: []string{"10.0.0.0/8"}, expected: "198.51.100.2"},
+	{name: "chain of trusted proxies", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "198.51.100.2, 10.0.0.5, 10.0.0.6"}, trusted: []string{"10.0.0.0/8"}, expected: "198.51.100.2"},
+	{name: "single trusted address", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "198.51.100.2"}, trusted: []string{"10.0.0.1"}, expected: "198.51.100.2"},
+	{name: "x-real-ip", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"X-Real-IP": "198.51.100.3"}, trusted: []string{"10.0.0.0/8"}, expected: "198.51.100.3"},
+	{name: "forwarded", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"Forwarded": `for=198.51.100.4;proto=https, for="[2001:db8::1]:4711"`}, trusted: []string{"10.0.0.0/8"}, expected: "2001:db8::1"},
+	{name: "forwarded preferred", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"Forwarded": "for=198.51.100.4", "X-Forwarded-For": "198.51.100.5"}, trusted: []string{"10.0.0.0/8"}, expected: "198.51.100.4"},
+	{name: "garbage in chain", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "198.51.100.2, nonsense"}, trusted: []string{"10.0.0.0/8"}, expected: "10.0.0.1"},
+	{name: "ipv6 remote", remoteAddr: "[2001:db8::2]:1234", expected: "2001:db8::2"},
+}
+
+func TestTools_ClientIP(t *testing.T) {
+	for _, e := range clientIPTests {
+		var testTools Tools
+		testTools.TrustedProxies = e.trusted
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = e.remoteAddr
+		for k, v := range e.headers {
+			req.Header.Set(k, v)
+		}
+
+		if ip := testTools.ClientIP(req); ip != e.expected {
+			t.Errorf("%s: wrong client IP; expected %s but got %s", e.name, e.expected, ip)
+		}
+	}
+}
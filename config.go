@@ -0,0 +1,202 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// ConfigOptions configures LoadConfig.
+type ConfigOptions struct {
+	Files     []string                        // JSON (.json) or YAML (.yaml, .yml) files; later files override earlier ones, and missing files are skipped
+	EnvFiles  []string                        // .env files; a variable set in the environment overrides them, and missing files are skipped
+	EnvPrefix string                          // prefix of the environment variables derived from field names, e.g. "APP_"
+	LookupEnv func(key string) (string, bool) // looks up environment variables; defaults to os.LookupEnv
+}
+
+// configField is a field LoadConfig can set, with where its value comes from.
+type configField struct {
+	key      string // the dotted key in config files, lowercased, e.g. "database.host"
+	env      string // the environment variable, e.g. "DATABASE_HOST"
+	def      string // the default value
+	hasDef   bool
+	required bool
+	value    reflect.Value
+}
+
+// LoadConfig populates cfg, a pointer to a struct, from (in rising order of precedence) `default` tags,
+// config files, .env files and environment variables. A field's key in config files is its `config` tag, or
+// its name, matched case-insensitively, with fields of nested structs under the struct's key (database.host).
+// Its environment variable is its `env` tag, or EnvPrefix followed by its key in upper case with dots
+// replaced by underscores (DATABASE_HOST). Values are converted as by ReadForm; a slice takes every value of
+// a list in a file, or the comma-separated values of a variable. Fields tagged `required:"true"` must be
+// set by one of the sources, and every missing or invalid field is reported in the error.
+//
+// YAML support covers what config files usually need: nested mappings, lists of scalars (block or [a, b]
+// style), quoted strings and comments. Anchors, multi-line strings and lists of mappings are not supported.
+func (t *Tools) LoadConfig(cfg any, opts ...ConfigOptions) error {
+	var opt ConfigOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.LookupEnv == nil {
+		opt.LookupEnv = os.LookupEnv
+	}
+
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: cfg must be a non-nil pointer to a struct, not %T", cfg)
+	}
+	var fields []configField
+	collectConfigFields(v.Elem(), "", opt.EnvPrefix, &fields)
+
+	fileValues := make(map[string][]string)
+	for _, path := range opt.Files {
+		values, err := readConfigFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for k, vals := range values {
+			fileValues[k] = vals
+		}
+	}
+
+	envFileValues := make(map[string]string)
+	for _, path := range opt.EnvFiles {
+		b, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		values, err := parseDotEnv(b)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+		for k, val := range values {
+			envFileValues[k] = val
+		}
+	}
+
+	var errs []error
+	for _, f := range fields {
+		var vals []string
+		source := ""
+		if s, ok := opt.LookupEnv(f.env); ok {
+			vals, source = configEnvValues(f.value, s), f.env
+		} else if s, ok := envFileValues[f.env]; ok {
+			vals, source = configEnvValues(f.value, s), f.env
+		} else if fv, ok := fileValues[f.key]; ok {
+			vals, source = fv, f.key
+		} else if f.hasDef {
+			vals, source = configEnvValues(f.value, f.def), f.key
+		}
+
+		if source == "" {
+			if f.required {
+				errs = append(errs, fmt.Errorf("config: %s (%s) is required", f.key, f.env))
+			}
+			continue
+		}
+		if err := setFieldValues(f.value, vals); err != nil {
+			errs = append(errs, fmt.Errorf("config: invalid value for %s: %w", source, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collectConfigFields adds the settable fields of struct v to fields, with keys under prefix. Nested
+// structs, other than those converted from a single value such as time.Time, are walked in turn, and
+// embedded structs are included as if they belonged to v.
+func collectConfigFields(v reflect.Value, prefix, envPrefix string, fields *[]configField) {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		name := sf.Tag.Get("config")
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			collectConfigFields(v.Field(i), prefix, envPrefix, fields)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		key := prefix + strings.ToLower(name)
+
+		field := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != timeType && !field.Addr().Type().Implements(textUnmarshalerType) {
+			collectConfigFields(field, key+".", envPrefix, fields)
+			continue
+		}
+
+		env := sf.Tag.Get("env")
+		if env == "" {
+			env = envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+		}
+		def, hasDef := sf.Tag.Lookup("default")
+		*fields = append(*fields, configField{
+			key:      key,
+			env:      env,
+			def:      def,
+			hasDef:   hasDef,
+			required: sf.Tag.Get("required") == "true",
+			value:    field,
+		})
+	}
+}
+
+// configEnvValues returns the values of a variable for field: its comma-separated parts for a slice, or
+// the variable itself otherwise.
+func configEnvValues(field reflect.Value, s string) []string {
+	if field.Kind() != reflect.Slice || field.Type().Elem().Kind() == reflect.Uint8 {
+		return []string{s}
+	}
+	var vals []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			vals = append(vals, part)
+		}
+	}
+	return vals
+}
+
+// readConfigFile reads a JSON or YAML config file, by its extension, into values keyed by their dotted,
+// lowercased keys.
+func readConfigFile(path string) (map[string][]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		doc, err = parseJSONConfig(b)
+	case ".yaml", ".yml":
+		doc, err = parseYAML(b)
+	default:
+		return nil, fmt.Errorf("config: %s: unsupported file type %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	values := make(map[string][]string)
+	flattenConfig("", doc, values)
+	return values, nil
+}
@@ -0,0 +1,172 @@
+package toolkit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name     string        `default:"toolkit"`
+	Port     int           `config:"port" default:"8080"`
+	Debug    bool          `env:"TOOLKIT_DEBUG"`
+	Timeout  time.Duration `default:"5s"`
+	Origins  []string
+	Secret   string `required:"true"`
+	Database struct {
+		Host string `default:"localhost"`
+		Port int
+	}
+	Ignored string `config:"-"`
+}
+
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestTools_LoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := writeConfigFile(t, dir, "config.json", `{"port": 9000, "origins": ["a.com", "b.com"], "database": {"host": "db", "port": 5432}, "secret": "from-json"}`)
+	yamlFile := writeConfigFile(t, dir, "local.yaml", `
+# local overrides
+port: 9100
+Database:
+  Host: "yaml-db" # quoted, with a comment
+origins:
+- c.com
+`)
+	envFile := writeConfigFile(t, dir, ".env", "export APP_SECRET='from-dotenv'\nAPP_DATABASE_PORT=6543\n")
+
+	var testTools Tools
+	var cfg testConfig
+	err := testTools.LoadConfig(&cfg, ConfigOptions{
+		Files:     []string{jsonFile, yamlFile, filepath.Join(dir, "missing.yaml")},
+		EnvFiles:  []string{envFile},
+		EnvPrefix: "APP_",
+		LookupEnv: testEnv(map[string]string{"APP_DATABASE_PORT": "7777", "TOOLKIT_DEBUG": "true", "APP_IGNORED": "x"}),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		got      any
+		expected any
+	}{
+		{"default", cfg.Name, "toolkit"},
+		{"later file overrides earlier", cfg.Port, 9100},
+		{"env tag", cfg.Debug, true},
+		{"default duration", cfg.Timeout, 5 * time.Second},
+		{"later file list", cfg.Origins, []string{"c.com"}},
+		{".env overrides files", cfg.Secret, "from-dotenv"},
+		{"nested key, case-insensitive", cfg.Database.Host, "yaml-db"},
+		{"environment overrides .env", cfg.Database.Port, 7777},
+		{"ignored", cfg.Ignored, ""},
+	}
+	for _, e := range tests {
+		if !reflect.DeepEqual(e.got, e.expected) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, e.got)
+		}
+	}
+}
+
+func TestTools_LoadConfig_Errors(t *testing.T) {
+	var testTools Tools
+
+	var cfg testConfig
+	err := testTools.LoadConfig(&cfg, ConfigOptions{LookupEnv: testEnv(map[string]string{"PORT": "eighty", "ORIGINS": "a.com, b.com"})})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{"config: secret (SECRET) is required", "config: invalid value for PORT"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %q", expected, err)
+		}
+	}
+	if !reflect.DeepEqual(cfg.Origins, []string{"a.com", "b.com"}) {
+		t.Errorf("expected comma-separated origins, got %v", cfg.Origins)
+	}
+
+	if err := testTools.LoadConfig(cfg); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"bad.json":   `{"port": }`,
+		"bad.yaml":   "port: 1\n  nested: 2\n",
+		"anchor.yml": "base: &base\n  a: 1\n",
+		"list.yaml":  "servers:\n  - host: a\n",
+		"config.ini": "port=1",
+	} {
+		err := testTools.LoadConfig(&testConfig{}, ConfigOptions{Files: []string{writeConfigFile(t, dir, name, content)}, LookupEnv: testEnv(map[string]string{"SECRET": "s"})})
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: expected an error naming the file, got %v", name, err)
+		}
+	}
+}
+
+var parseYAMLTests = []struct {
+	name     string
+	yaml     string
+	expected map[string]any
+	err      error
+}{
+	{name: "scalars", yaml: "a: 1\nb: two words\nc: \"x: y\"\nd: 'it''s'\ne: ~\n", expected: map[string]any{"a": "1", "b": "two words", "c": "x: y", "d": "it's", "e": nil}},
+	{name: "nested", yaml: "db:\n  host: h\n  pool:\n    size: 5\nport: 1\n", expected: map[string]any{"db": map[string]any{"host": "h", "pool": map[string]any{"size": "5"}}, "port": "1"}},
+	{name: "lists", yaml: "a:\n  - x\n  - y\nb: [1, 2]\nc:\n- z\nd: []\n", expected: map[string]any{"a": []any{"x", "y"}, "b": []any{"1", "2"}, "c": []any{"z"}, "d": []any{}}},
+	{name: "comments", yaml: "---\n# heading\na: b # trailing\nurl: http://x/#frag\n...\nignored: true\n", expected: map[string]any{"a": "b", "url": "http://x/#frag"}},
+	{name: "empty", yaml: "\n# nothing\n", expected: map[string]any{}},
+	{name: "multi-line string", yaml: "a: |\n  text\n", err: errUnsupportedYAML},
+}
+
+func TestParseYAML(t *testing.T) {
+	for _, e := range parseYAMLTests {
+		got, err := parseYAML([]byte(e.yaml))
+		if e.err != nil {
+			if !errors.Is(err, e.err) {
+				t.Errorf("%s: expected %v but got %v", e.name, e.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, e.expected) {
+			t.Errorf("%s: expected %#v but got %#v", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestParseDotEnv(t *testing.T) {
+	got, err := parseDotEnv([]byte("# comment\n\nA=1\nexport B = two # note\nC=\"line\\nbreak\"\nD='# not a comment'\nE=\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"A": "1", "B": "two", "C": "line\nbreak", "D": "# not a comment", "E": ""}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+
+	if _, err := parseDotEnv([]byte("A=1\nnot a pair\n")); err == nil || err.Error() != "line 2: expected KEY=VALUE" {
+		t.Errorf("expected a line error, got %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseJSONConfig parses a JSON config file, which must hold an object.
+func parseJSONConfig(b []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// flattenConfig adds the scalars in v to values, keyed by their dotted, lowercased path under prefix. A list
+// gives every one of its values.
+func flattenConfig(prefix string, v any, values map[string][]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		if prefix != "" {
+			prefix += "."
+		}
+		for k, child := range v {
+			flattenConfig(prefix+strings.ToLower(k), child, values)
+		}
+	case []any:
+		vals := make([]string, 0, len(v))
+		for _, item := range v {
+			if item != nil {
+				vals = append(vals, fmt.Sprint(item))
+			}
+		}
+		values[prefix] = vals
+	case nil:
+		// a null or empty value leaves the field to the other sources
+	default:
+		values[prefix] = []string{fmt.Sprint(v)}
+	}
+}
+
+// parseDotEnv parses a .env file: KEY=VALUE lines, optionally starting with "export", with blank lines and
+// comments ignored. Double-quoted values may use Go escapes such as \n, single-quoted values are taken as
+// they are, and unquoted values end at a " #" comment.
+func parseDotEnv(b []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, val, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", i+1)
+		}
+
+		val = strings.TrimSpace(val)
+		switch {
+		case len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"':
+			s, err := strconv.Unquote(val)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value", i+1)
+			}
+			val = s
+		case len(val) >= 2 && val[0] == '\'' && val[len(val)-1] == '\'':
+			val = val[1 : len(val)-1]
+		default:
+			if j := strings.Index(val, " #"); j >= 0 {
+				val = strings.TrimSpace(val[:j])
+			}
+		}
+		values[key] = val
+	}
+	return values, nil
+}
+
+// yamlLine is a line of a YAML document, without its indentation or comment.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// errUnsupportedYAML is returned for YAML features parseYAML doesn't handle.
+var errUnsupportedYAML = errors.New("unsupported YAML")
+
+// parseYAML parses the subset of YAML config files usually need: nested mappings, lists of scalars, in
+// block or flow ([a, b]) style, quoted strings and comments.
+func parseYAML(b []byte) (map[string]any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(b), "\n") {
+		raw = strings.TrimRight(raw, " \t\r")
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs must not be used for indentation", i+1)
+		}
+		text := stripYAMLComment(trimmed)
+		if text == "" || text == "---" {
+			continue
+		}
+		if text == "..." {
+			break
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(raw) - len(trimmed), text: text})
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+
+	v, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[next].num)
+	}
+	doc, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("line %d: the document must be a mapping", lines[0].num)
+	}
+	return doc, nil
+}
+
+// parseYAMLBlock parses the mapping or list starting at lines[i], whose lines are indented by indent, and
+// returns it with the index of the line after it.
+func parseYAMLBlock(lines []yamlLine, i, indent int) (any, int, error) {
+	if isYAMLListItem(lines[i].text) {
+		var list []any
+		for ; i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text); i++ {
+			item := strings.TrimSpace(strings.TrimPrefix(lines[i].text, "-"))
+			if item == "" || yamlKeyValue(item) {
+				return nil, i, fmt.Errorf("line %d: %w: only lists of scalars are supported", lines[i].num, errUnsupportedYAML)
+			}
+			v, err := parseYAMLScalar(item)
+			if err != nil {
+				return nil, i, fmt.Errorf("line %d: %w", lines[i].num, err)
+			}
+			list = append(list, v)
+		}
+		return list, i, nil
+	}
+
+	m := make(map[string]any)
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		if !yamlKeyValue(line.text) {
+			return nil, i, fmt.Errorf("line %d: expected key: value", line.num)
+		}
+		key, rest := splitYAMLKey(line.text)
+		i++
+
+		if rest != "" {
+			v, err := parseYAMLScalar(rest)
+			if err != nil {
+				return nil, i, fmt.Errorf("line %d: %w", line.num, err)
+			}
+			m[key] = v
+			continue
+		}
+
+		// A key without a value holds the block after it: one indented further, or a list at the same
+		// indentation, which YAML allows.
+		switch {
+		case i < len(lines) && lines[i].indent > indent,
+			i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text):
+			v, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, next, err
+			}
+			m[key], i = v, next
+		default:
+			m[key] = nil
+		}
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, i, fmt.Errorf("line %d: unexpected indentation", lines[i].num)
+	}
+	return m, i, nil
+}
+
+// isYAMLListItem reports whether text is a block list item.
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlKeyValue reports whether text is a "key: value" or "key:" line.
+func yamlKeyValue(text string) bool {
+	if text[0] == '"' || text[0] == '\'' {
+		end := strings.IndexByte(text[1:], text[0])
+		return end >= 0 && strings.HasPrefix(text[end+2:], ":")
+	}
+	return strings.HasSuffix(text, ":") || strings.Contains(text, ": ")
+}
+
+// splitYAMLKey splits a "key: value" line into its key, unquoted, and value.
+func splitYAMLKey(text string) (string, string) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := strings.IndexByte(text[1:], text[0]) + 1
+		return text[1:end], strings.TrimSpace(text[end+2:])
+	}
+	if key, ok := strings.CutSuffix(text, ":"); ok && !strings.Contains(key, ": ") {
+		return strings.TrimSpace(key), ""
+	}
+	key, rest, _ := strings.Cut(text, ": ")
+	return strings.TrimSpace(key), strings.TrimSpace(rest)
+}
+
+// parseYAMLScalar parses a scalar or flow list. Plain scalars are kept as strings, for LoadConfig to convert
+// to each field's type; null and ~ give nil.
+func parseYAMLScalar(s string) (any, error) {
+	switch {
+	case s == "null" || s == "~":
+		return nil, nil
+	case s[0] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double-quoted string %s", s)
+		}
+		return v, nil
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("invalid single-quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '[':
+		if s[len(s)-1] != ']' {
+			return nil, fmt.Errorf("invalid flow list %s", s)
+		}
+		list := []any{}
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		if inner == "" {
+			return list, nil
+		}
+		for _, part := range strings.Split(inner, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" || part[0] == '[' || part[0] == '{' {
+				return nil, fmt.Errorf("%w: flow list %s", errUnsupportedYAML, s)
+			}
+			v, err := parseYAMLScalar(part)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case s[0] == '{' || s[0] == '&' || s[0] == '*' || s[0] == '|' || s[0] == '>' || s[0] == '!':
+		return nil, fmt.Errorf("%w: %s", errUnsupportedYAML, s)
+	}
+	return s, nil
+}
+
+// stripYAMLComment removes a comment, which starts with a # at the beginning of text or after a space,
+// outside quotes.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || text[i-1] == ' ' || text[i-1] == '[' || text[i-1] == ',' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return strings.TrimRight(text[:i], " ")
+		}
+	}
+	return text
+}
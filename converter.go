@@ -0,0 +1,210 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Converter converts uploads into another format as UploadFiles receives them, so files are stored in
+// formats the application supports, e.g. HEIC photos as JPEGs or Word documents as PDFs.
+type Converter interface {
+	// Converts reports whether the converter handles an upload called name, whose type was detected as
+	// contentType.
+	Converts(contentType, name string) bool
+	// Convert writes the converted content of src, an upload called name, to dst and returns the extension of
+	// the new format, including the dot (e.g. ".jpg").
+	Convert(ctx context.Context, name string, src io.Reader, dst io.Writer) (string, error)
+}
+
+// CommandConverter is a Converter which runs an external program, such as heif-convert, ImageMagick or
+// LibreOffice, on a temporary copy of the upload.
+type CommandConverter struct {
+	Types      []string      // the detected types converted; wildcards such as image/* are supported
+	Extensions []string      // if set, only uploads with one of these extensions (e.g. ".docx") are converted, for formats detected as a generic type such as application/zip
+	Ext        string        // the extension of the converted files, including the dot (e.g. ".pdf")
+	Command    []string      // the program and its arguments; {in}, {out} and {outdir} are replaced with the input file, the output file and its directory
+	Timeout    time.Duration // how long to let the program run for; defaults to 2 minutes
+}
+
+// defaultConverterTimeout the default time a CommandConverter's program is allowed to run for
+const defaultConverterTimeout = 2 * time.Minute
+
+// convertExtRegexp matches the extensions kept on the temporary copy converted by CommandConverter. Others
+// are dropped, so the name of an upload never reaches the command line.
+var convertExtRegexp = regexp.MustCompile(`^\.[a-zA-Z0-9]{1,10}$`)
+
+// Converts reports whether contentType matches one of Types, and name has one of Extensions if they are set.
+func (c CommandConverter) Converts(contentType, name string) bool {
+	if len(c.Extensions) > 0 {
+		found := false
+		for _, ext := range c.Extensions {
+			if strings.EqualFold(filepath.Ext(name), ext) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, pattern := range c.Types {
+		if matchesFileType(pattern, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert copies src to a temporary directory, runs Command on it and writes the file it produced to dst.
+func (c CommandConverter) Convert(ctx context.Context, name string, src io.Reader, dst io.Writer) (string, error) {
+	if len(c.Command) == 0 {
+		return "", errors.New("converter has no command")
+	}
+	if !convertExtRegexp.MatchString(c.Ext) {
+		return "", fmt.Errorf("converter has an invalid extension %q", c.Ext)
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultConverterTimeout
+	}
+
+	dir, err := os.MkdirTemp("", "toolkit-convert-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	// The input and output are in separate directories, as tools such as LibreOffice name their output
+	// after the input.
+	inExt := filepath.Ext(name)
+	if !convertExtRegexp.MatchString(inExt) {
+		inExt = ""
+	}
+	in, outDir := filepath.Join(dir, "in", "upload"+inExt), filepath.Join(dir, "out")
+	out := filepath.Join(outDir, "upload"+c.Ext)
+	for _, d := range []string{filepath.Dir(in), outDir} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			return "", err
+		}
+	}
+	if _, err := writeFileAtomic(in, src, false); err != nil {
+		return "", err
+	}
+
+	args := make([]string, len(c.Command))
+	replacer := strings.NewReplacer("{in}", in, "{out}", out, "{outdir}", outDir)
+	for i, arg := range c.Command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", filepath.Base(args[0]), err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", filepath.Base(args[0]), err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		return "", fmt.Errorf("%s did not write the converted file: %w", filepath.Base(args[0]), err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(dst, f); err != nil {
+		return "", err
+	}
+	return c.Ext, nil
+}
+
+// convertUpload runs an upload through Converters, each taking the output of the one before if it handles
+// its type, and returns the converted content, its detected type and extension. It returns a nil file if
+// no converter handled the upload. The caller must close and remove the file.
+func (t *Tools) convertUpload(ctx context.Context, name string, content io.ReadSeeker, fileType string) (*os.File, string, string, error) {
+	var converted *os.File
+	var ext string
+	used := make([]bool, len(t.Converters))
+
+	for {
+		i := -1
+		for j, c := range t.Converters {
+			if !used[j] && c.Converts(fileType, name) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return converted, fileType, ext, nil
+		}
+		used[i] = true
+
+		out, err := os.CreateTemp("", "toolkit-converted-*")
+		if err != nil {
+			removeTempFile(converted)
+			return nil, "", "", err
+		}
+		ext, err = t.Converters[i].Convert(ctx, name, content, out)
+		removeTempFile(converted)
+		converted = out
+		if err != nil {
+			removeTempFile(converted)
+			return nil, "", "", fmt.Errorf("converting %s: %w", fileType, err)
+		}
+
+		if _, err := converted.Seek(0, io.SeekStart); err != nil {
+			removeTempFile(converted)
+			return nil, "", "", err
+		}
+		head := make([]byte, fileSniffLen)
+		n, _ := io.ReadFull(converted, head)
+		fileType = t.DetectFileType(head[:n])
+		if _, err := converted.Seek(0, io.SeekStart); err != nil {
+			removeTempFile(converted)
+			return nil, "", "", err
+		}
+		content, name = converted, strings.TrimSuffix(name, filepath.Ext(name))+ext
+	}
+}
+
+// removeTempFile closes and removes f, if it isn't nil.
+func removeTempFile(f *os.File) {
+	if f == nil {
+		return
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// keepOriginal stores the original content of a converted upload in OriginalsDir, named as the converted file
+// with the original extension, and returns its name.
+func (t *Tools) keepOriginal(ctx context.Context, file *UploadedFile, content io.ReadSeeker) (string, error) {
+	dir := tenantDir(ctx, t.OriginalsDir)
+	if err := t.CreateDirIfNotExist(dir); err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(file.NewFileName, filepath.Ext(file.NewFileName)) + filepath.Ext(file.OriginalFileName)
+	p, err := t.EnsureWithinBase(dir, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := writeFileAtomic(p, content, t.SyncUploadDir); err != nil {
+		return "", err
+	}
+	return name, nil
+}
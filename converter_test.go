@@ -0,0 +1,163 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testConverter is a Converter turning files of type from into the content of out, with the extension ext.
+type testConverter struct {
+	from string
+	out  []byte
+	ext  string
+	err  error
+}
+
+func (c testConverter) Converts(contentType, name string) bool {
+	return matchesFileType(c.from, contentType)
+}
+
+func (c testConverter) Convert(ctx context.Context, name string, src io.Reader, dst io.Writer) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	_, err := dst.Write(c.out)
+	return c.ext, err
+}
+
+func TestTools_UploadFiles_Converters(t *testing.T) {
+	png := mustReadFile(t, "./testdata/img.png")
+	pdf := testPDFs["simple"]
+
+	tests := []struct {
+		name          string
+		converters    []Converter
+		keepOriginals bool
+		expected      []byte
+		contentType   string
+		convertedFrom string
+		ext           string
+		errorExpected bool
+	}{
+		{name: "no converter", converters: []Converter{testConverter{from: "text/*", out: pdf, ext: ".pdf"}}, expected: png, contentType: "image/png", ext: ".png"},
+		{name: "converted", converters: []Converter{testConverter{from: "image/*", out: pdf, ext: ".pdf"}}, expected: pdf, contentType: "application/pdf", convertedFrom: "image/png", ext: ".pdf"},
+		{name: "pipeline", converters: []Converter{testConverter{from: "application/pdf", out: []byte("some notes"), ext: ".txt"}, testConverter{from: "image/*", out: pdf, ext: ".pdf"}},
+			expected: []byte("some notes"), contentType: "text/plain; charset=utf-8", convertedFrom: "image/png", ext: ".txt"},
+		{name: "original kept", converters: []Converter{testConverter{from: "image/*", out: pdf, ext: ".pdf"}}, keepOriginals: true, expected: pdf, contentType: "application/pdf", convertedFrom: "image/png", ext: ".pdf"},
+		{name: "conversion fails", converters: []Converter{testConverter{from: "image/*", err: errors.New("unsupported")}}, errorExpected: true},
+	}
+
+	for _, e := range tests {
+		dir, originals := t.TempDir(), t.TempDir()
+		testTools := Tools{Converters: e.converters, WriteUploadMetadata: true}
+		if e.keepOriginals {
+			testTools.OriginalsDir = originals
+		}
+		body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		file, err := testTools.UploadOneFile(request, dir)
+		if e.errorExpected {
+			if err == nil || !strings.Contains(err.Error(), "unsupported") {
+				t.Errorf("%s: expected the converter's error, got %v", e.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+
+		if filepath.Ext(file.NewFileName) != e.ext {
+			t.Errorf("%s: expected the extension %s, got %s", e.name, e.ext, file.NewFileName)
+		}
+		if file.ContentType != e.contentType || file.ConvertedFrom != e.convertedFrom {
+			t.Errorf("%s: expected %s converted from %q, got %s from %q", e.name, e.contentType, e.convertedFrom, file.ContentType, file.ConvertedFrom)
+		}
+		if stored := mustReadFile(t, filepath.Join(dir, file.NewFileName)); string(stored) != string(e.expected) || file.FileSize != int64(len(e.expected)) {
+			t.Errorf("%s: stored file differs from the converted content", e.name)
+		}
+		if meta := string(mustReadFile(t, filepath.Join(dir, file.NewFileName+uploadMetadataSuffix))); e.convertedFrom != "" && !strings.Contains(meta, `"converted_from"`) {
+			t.Errorf("%s: expected the metadata to record the conversion, got %s", e.name, meta)
+		}
+
+		if !e.keepOriginals {
+			if file.OriginalCopy != "" {
+				t.Errorf("%s: expected no original kept, got %s", e.name, file.OriginalCopy)
+			}
+			continue
+		}
+		if file.OriginalCopy != strings.TrimSuffix(file.NewFileName, e.ext)+".png" {
+			t.Errorf("%s: unexpected original name %s", e.name, file.OriginalCopy)
+		}
+		if original := mustReadFile(t, filepath.Join(originals, file.OriginalCopy)); string(original) != string(png) {
+			t.Errorf("%s: expected the original content", e.name)
+		}
+	}
+}
+
+func TestCommandConverter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh to convert files")
+	}
+
+	upper := CommandConverter{
+		Types:   []string{"text/*"},
+		Ext:     ".md",
+		Command: []string{"sh", "-c", `tr a-z A-Z < "$0" > "$1"`, "{in}", "{out}"},
+	}
+	tests := []struct {
+		name          string
+		converter     CommandConverter
+		contentType   string
+		fileName      string
+		converts      bool
+		expected      string
+		errorExpected string
+	}{
+		{name: "converted", converter: upper, contentType: "text/plain; charset=utf-8", fileName: "notes.txt", converts: true, expected: "SOME NOTES"},
+		{name: "other type", converter: upper, contentType: "image/png", fileName: "notes.txt"},
+		{name: "extension", converter: CommandConverter{Types: []string{"application/zip"}, Extensions: []string{".docx"}}, contentType: "application/zip", fileName: "report.DOCX", converts: true},
+		{name: "other extension", converter: CommandConverter{Types: []string{"application/zip"}, Extensions: []string{".docx"}}, contentType: "application/zip", fileName: "archive.zip"},
+		{name: "outdir", converter: CommandConverter{Types: []string{"text/*"}, Ext: ".txt", Command: []string{"sh", "-c", `cp "$0" "$1/upload.txt"`, "{in}", "{outdir}"}},
+			contentType: "text/plain", fileName: "notes; rm -rf.txt", converts: true, expected: "some notes"},
+		{name: "command fails", converter: CommandConverter{Types: []string{"text/*"}, Ext: ".md", Command: []string{"sh", "-c", "echo broken >&2; exit 1"}},
+			contentType: "text/plain", fileName: "notes.txt", converts: true, errorExpected: "broken"},
+		{name: "no output", converter: CommandConverter{Types: []string{"text/*"}, Ext: ".md", Command: []string{"true"}},
+			contentType: "text/plain", fileName: "notes.txt", converts: true, errorExpected: "did not write"},
+		{name: "no command", converter: CommandConverter{Types: []string{"text/*"}, Ext: ".md"}, contentType: "text/plain", fileName: "notes.txt", converts: true, errorExpected: "no command"},
+	}
+
+	for _, e := range tests {
+		if e.converter.Converts(e.contentType, e.fileName) != e.converts {
+			t.Errorf("%s: expected Converts to be %t", e.name, e.converts)
+		}
+		if !e.converts || e.converter.Ext == "" {
+			continue
+		}
+
+		var out strings.Builder
+		ext, err := e.converter.Convert(context.Background(), e.fileName, strings.NewReader("some notes"), &out)
+		if e.errorExpected != "" {
+			if err == nil || !strings.Contains(err.Error(), e.errorExpected) {
+				t.Errorf("%s: expected an error containing %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		if ext != e.converter.Ext || out.String() != e.expected {
+			t.Errorf("%s: expected %q with %s, got %q with %s", e.name, e.expected, e.converter.Ext, out.String(), ext)
+		}
+	}
+}
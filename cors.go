@@ -0,0 +1,111 @@
+package toolkit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures the CORS middleware.
+type CORSOptions struct {
+	AllowedOrigins   []string      // allowed origins; "*" allows any, and "https://*.example.com" allows subdomains
+	AllowedMethods   []string      // methods allowed in preflight requests; defaults to GET, HEAD, POST
+	AllowedHeaders   []string      // request headers allowed in preflight requests; "*" allows any
+	ExposedHeaders   []string      // response headers the browser may expose to scripts
+	AllowCredentials bool          // if set to true, allow cookies and authorization headers
+	MaxAge           time.Duration // how long browsers may cache preflight responses; 0 leaves it to the browser
+}
+
+// CORS returns middleware which implements Cross-Origin Resource Sharing. Requests from an allowed origin get
+// the matching Access-Control headers; preflight requests are answered directly with 204 No Content, and
+// never reach the wrapped handler. Requests from other origins are passed through without CORS headers,
+// which makes browsers block them.
+func (t *Tools) CORS(opts CORSOptions) Middleware {
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	allowedMethods := strings.Join(methods, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || !originAllowed(origin, opts.AllowedOrigins) {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// A wildcard can't be combined with credentials, so echo the origin back instead.
+			if containsString(opts.AllowedOrigins, "*") && !opts.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(opts.ExposedHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				if containsString(opts.AllowedHeaders, "*") {
+					w.Header().Set("Access-Control-Allow-Headers", requested)
+				} else if len(opts.AllowedHeaders) > 0 {
+					w.Header().Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+				}
+			}
+			if opts.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// originAllowed reports whether origin matches one of the allowed origin patterns.
+func originAllowed(origin string, allowed []string) bool {
+	origin = strings.ToLower(origin)
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == "*" || a == origin {
+			return true
+		}
+		// "https://*.example.com" matches any subdomain of example.com, but not example.com itself
+		if prefix, suffix, ok := strings.Cut(a, "*"); ok {
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsString reports whether s is in list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
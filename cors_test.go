@@ -0,0 +1,85 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var corsTests = []struct {
+	name            string
+	method          string
+	origin          string
+	requestMethod   string
+	requestHeaders  string
+	opts            CORSOptions
+	expectedStatus  int
+	expectedOrigin  string
+	expectedMethods string
+	expectedHeaders string
+	expectedMaxAge  string
+	reachedHandler  bool
+}{
+	{name: "no origin", method: http.MethodGet, opts: CORSOptions{AllowedOrigins: []string{"*"}}, expectedStatus: http.StatusOK, reachedHandler: true},
+	{name: "any origin", method: http.MethodGet, origin: "https://a.com", opts: CORSOptions{AllowedOrigins: []string{"*"}}, expectedStatus: http.StatusOK, expectedOrigin: "*", reachedHandler: true},
+	{name: "any origin with credentials", method: http.MethodGet, origin: "https://a.com", opts: CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true}, expectedStatus: http.StatusOK, expectedOrigin: "https://a.com", reachedHandler: true},
+	{name: "exact origin", method: http.MethodGet, origin: "https://a.com", opts: CORSOptions{AllowedOrigins: []string{"https://a.com"}}, expectedStatus: http.StatusOK, expectedOrigin: "https://a.com", reachedHandler: true},
+	{name: "wildcard subdomain", method: http.MethodGet, origin: "https://app.example.com", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, expectedStatus: http.StatusOK, expectedOrigin: "https://app.example.com", reachedHandler: true},
+	{name: "wildcard does not match apex", method: http.MethodGet, origin: "https://.example.com", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, expectedStatus: http.StatusOK, reachedHandler: true},
+	{name: "disallowed origin", method: http.MethodGet, origin: "https://evil.com", opts: CORSOptions{AllowedOrigins: []string{"https://a.com"}}, expectedStatus: http.StatusOK, reachedHandler: true},
+	{
+		name: "preflight", method: http.MethodOptions, origin: "https://a.com", requestMethod: http.MethodPut, requestHeaders: "Content-Type",
+		opts:           CORSOptions{AllowedOrigins: []string{"https://a.com"}, AllowedMethods: []string{"GET", "PUT"}, AllowedHeaders: []string{"Content-Type", "Authorization"}, MaxAge: time.Hour},
+		expectedStatus: http.StatusNoContent, expectedOrigin: "https://a.com", expectedMethods: "GET, PUT", expectedHeaders: "Content-Type, Authorization", expectedMaxAge: "3600",
+	},
+	{
+		name: "preflight any header", method: http.MethodOptions, origin: "https://a.com", requestMethod: http.MethodPost, requestHeaders: "X-Custom",
+		opts:           CORSOptions{AllowedOrigins: []string{"https://a.com"}, AllowedHeaders: []string{"*"}},
+		expectedStatus: http.StatusNoContent, expectedOrigin: "https://a.com", expectedMethods: "GET, HEAD, POST", expectedHeaders: "X-Custom",
+	},
+	{name: "preflight from disallowed origin", method: http.MethodOptions, origin: "https://evil.com", requestMethod: http.MethodPut, opts: CORSOptions{AllowedOrigins: []string{"https://a.com"}}, expectedStatus: http.StatusNoContent},
+}
+
+func TestTools_CORS(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range corsTests {
+		reached := false
+		handler := testTools.CORS(e.opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reached = true
+		}))
+
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(e.method, "/", nil)
+		if e.origin != "" {
+			req.Header.Set("Origin", e.origin)
+		}
+		if e.requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", e.requestMethod)
+		}
+		if e.requestHeaders != "" {
+			req.Header.Set("Access-Control-Request-Headers", e.requestHeaders)
+		}
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if reached != e.reachedHandler {
+			t.Errorf("%s: expected handler reached to be %v", e.name, e.reachedHandler)
+		}
+		checks := map[string]string{
+			"Access-Control-Allow-Origin":  e.expectedOrigin,
+			"Access-Control-Allow-Methods": e.expectedMethods,
+			"Access-Control-Allow-Headers": e.expectedHeaders,
+			"Access-Control-Max-Age":       e.expectedMaxAge,
+		}
+		for header, expected := range checks {
+			if got := rr.Header().Get(header); got != expected {
+				t.Errorf("%s: wrong %s; expected %q but got %q", e.name, header, expected, got)
+			}
+		}
+	}
+}
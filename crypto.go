@@ -0,0 +1,145 @@
+package toolkit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// pbkdf2Iterations the number of PBKDF2-HMAC-SHA256 iterations used to derive keys from passphrases
+const pbkdf2Iterations = 600000
+
+// EncryptionKey is a 256 bit AES key, along with the ID which is stored alongside anything encrypted with
+// it. Keeping the ID with the ciphertext is what allows keys to be rotated: new values are encrypted with
+// the first key in Tools.EncryptionKeys, while older values can still be decrypted with the keys after it.
+type EncryptionKey struct {
+	ID  string // a short identifier for the key (e.g. "2024-01"); at most 255 bytes
+	Key []byte // the 32 byte key
+}
+
+// NewEncryptionKey derives an EncryptionKey from a passphrase and salt using PBKDF2-HMAC-SHA256. This is
+// deliberately slow, so derive keys once at startup rather than per request.
+func (t *Tools) NewEncryptionKey(id, passphrase string, salt []byte) EncryptionKey {
+	return EncryptionKey{
+		ID:  id,
+		Key: pbkdf2SHA256([]byte(passphrase), salt, pbkdf2Iterations, 32),
+	}
+}
+
+// Encrypt encrypts plaintext with AES-256-GCM using the first key in EncryptionKeys, and returns it as an
+// unpadded, URL-safe base64 string, which makes it suitable for cookies, URL parameters, and file names.
+func (t *Tools) Encrypt(plaintext []byte) (string, error) {
+	if len(t.EncryptionKeys) == 0 {
+		return "", errors.New("no encryption key configured")
+	}
+	key := t.EncryptionKeys[0]
+	if len(key.ID) > 255 {
+		return "", errors.New("encryption key id must not be longer than 255 bytes")
+	}
+
+	aead, err := newAEAD(key.Key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	// The output is: key id length, key id, nonce, ciphertext. The key id header is authenticated too.
+	header := append([]byte{byte(len(key.ID))}, key.ID...)
+	out := append(header, nonce...)
+	out = aead.Seal(out, nonce, plaintext, header)
+
+	return base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// Decrypt reverses Encrypt, using whichever key in EncryptionKeys has the ID stored in the value.
+func (t *Tools) Decrypt(ciphertext string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(ciphertext)
+	if err != nil || len(data) < 1 || len(data) < 1+int(data[0]) {
+		return nil, errors.New("ciphertext is malformed")
+	}
+
+	header := data[:1+int(data[0])]
+	id := string(header[1:])
+
+	var key *EncryptionKey
+	for i := range t.EncryptionKeys {
+		if t.EncryptionKeys[i].ID == id {
+			key = &t.EncryptionKeys[i]
+			break
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown encryption key id %q", id)
+	}
+
+	aead, err := newAEAD(key.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := data[len(header):]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("ciphertext is malformed")
+	}
+
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, errors.New("ciphertext could not be decrypted")
+	}
+
+	return plaintext, nil
+}
+
+// newAEAD returns an AES-GCM AEAD for a 32 byte key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256 as the pseudorandom function.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	out := make([]byte, 0, blocks*hashLen)
+	buf := make([]byte, 4)
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u = prf.Sum(u[:0])
+
+		t := make([]byte, hashLen)
+		copy(t, u)
+		for n := 2; n <= iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+		out = append(out, t...)
+	}
+
+	return out[:keyLen]
+}
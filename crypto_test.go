@@ -0,0 +1,89 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestTools_EncryptDecrypt(t *testing.T) {
+	var testTools Tools
+	oldKey := EncryptionKey{ID: "old", Key: bytes.Repeat([]byte{1}, 32)}
+	newKey := EncryptionKey{ID: "new", Key: bytes.Repeat([]byte{2}, 32)}
+
+	testTools.EncryptionKeys = []EncryptionKey{oldKey}
+	oldValue, err := testTools.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate keys; values encrypted with the old key must still decrypt
+	testTools.EncryptionKeys = []EncryptionKey{newKey, oldKey}
+	newValue, err := testTools.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{oldValue, newValue} {
+		plaintext, err := testTools.Decrypt(v)
+		if err != nil {
+			t.Errorf("failed to decrypt %s: %s", v, err)
+		}
+		if string(plaintext) != "secret" {
+			t.Errorf("wrong plaintext; expected secret but got %s", plaintext)
+		}
+	}
+
+	// once the old key is retired, old values can no longer be decrypted
+	testTools.EncryptionKeys = []EncryptionKey{newKey}
+	if _, err := testTools.Decrypt(oldValue); err == nil {
+		t.Error("expected an error decrypting with a retired key, but none received")
+	}
+}
+
+var decryptTests = []struct {
+	name  string
+	value string
+}{
+	{name: "not base64", value: "!!!"},
+	{name: "empty", value: ""},
+	{name: "truncated", value: "A25ldw"},
+}
+
+func TestTools_Decrypt(t *testing.T) {
+	var testTools Tools
+	testTools.EncryptionKeys = []EncryptionKey{{ID: "new", Key: bytes.Repeat([]byte{2}, 32)}}
+
+	for _, e := range decryptTests {
+		if _, err := testTools.Decrypt(e.value); err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+		}
+	}
+
+	value, _ := testTools.Encrypt([]byte("secret"))
+	tampered := []byte(value)
+	if tampered[10] == 'A' {
+		tampered[10] = 'B'
+	} else {
+		tampered[10] = 'A'
+	}
+	if _, err := testTools.Decrypt(string(tampered)); err == nil {
+		t.Error("expected an error decrypting a tampered value, but none received")
+	}
+}
+
+func TestTools_Encrypt_NoKey(t *testing.T) {
+	var testTools Tools
+	if _, err := testTools.Encrypt([]byte("secret")); err == nil {
+		t.Error("expected an error encrypting without a key, but none received")
+	}
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// test vector from RFC 7914, section 11
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if hex.EncodeToString(key) != expected {
+		t.Errorf("wrong key derived: %x", key)
+	}
+}
@@ -0,0 +1,229 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// csrfKey is the context key for the CSRF secret of a request.
+const csrfKey contextKey = "csrf"
+
+// csrfSecretLen is the length of the secret kept in the CSRF cookie.
+const csrfSecretLen = 32
+
+// CSRFOptions configures the CSRF middleware. Zero values get the defaults noted.
+type CSRFOptions struct {
+	CookieName     string        // name of the cookie holding the secret; defaults to "csrf_token"
+	HeaderName     string        // request header checked for the token; defaults to "X-CSRF-Token"
+	FieldName      string        // form field checked for the token if the header isn't set; defaults to "csrf_token"
+	CookiePath     string        // path of the cookie; defaults to "/"
+	CookieDomain   string        // domain of the cookie; defaults to the host of the request
+	Secure         bool          // if set to true, the cookie is only sent over HTTPS
+	SameSite       http.SameSite // SameSite mode of the cookie; defaults to http.SameSiteLaxMode
+	MaxAge         time.Duration // lifetime of the cookie; 0 makes it last for the browser session
+	TrustedOrigins []string      // origins, other than the request's own, allowed to make unsafe requests
+}
+
+// CSRF returns middleware which protects against cross-site request forgery with the double-submit cookie
+// pattern. A random secret is kept in a cookie, and unsafe requests (anything but GET, HEAD, OPTIONS and
+// TRACE) must send a token derived from it, returned by CSRFToken, in the HeaderName header or the
+// FieldName form field; in a multipart form, such as an upload, the field must come before the files.
+// Unsafe requests with an Origin header from another site are also refused, unless it is one of
+// TrustedOrigins, where "https://*.example.com" allows any subdomain of example.com. Refused requests get a
+// 403 Forbidden through ErrorJSON, or ErrorXML if the client prefers XML.
+func (t *Tools) CSRF(opts CSRFOptions) Middleware {
+	if opts.CookieName == "" {
+		opts.CookieName = "csrf_token"
+	}
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+	if opts.FieldName == "" {
+		opts.FieldName = "csrf_token"
+	}
+	if opts.CookiePath == "" {
+		opts.CookiePath = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Cookie")
+
+			var secret []byte
+			if c, err := r.Cookie(opts.CookieName); err == nil {
+				secret, _ = base64.RawURLEncoding.DecodeString(c.Value)
+			}
+			if len(secret) != csrfSecretLen {
+				secret = make([]byte, csrfSecretLen)
+				if _, err := rand.Read(secret); err != nil {
+					t.errorResponse(w, r, errors.New(http.StatusText(http.StatusInternalServerError)), http.StatusInternalServerError)
+					return
+				}
+				cookie := &http.Cookie{
+					Name:     opts.CookieName,
+					Value:    base64.RawURLEncoding.EncodeToString(secret),
+					Path:     opts.CookiePath,
+					Domain:   opts.CookieDomain,
+					Secure:   opts.Secure,
+					HttpOnly: true,
+					SameSite: opts.SameSite,
+				}
+				if opts.MaxAge > 0 {
+					cookie.MaxAge = int(opts.MaxAge.Seconds())
+				}
+				http.SetCookie(w, cookie)
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), csrfKey, secret))
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := checkCSRFOrigin(r, opts.TrustedOrigins); err != nil {
+				t.errorResponse(w, r, err, http.StatusForbidden)
+				return
+			}
+
+			token := r.Header.Get(opts.HeaderName)
+			if token == "" {
+				token = csrfFormToken(r, opts.FieldName)
+			}
+			if token == "" {
+				t.errorResponse(w, r, errors.New("CSRF token missing"), http.StatusForbidden)
+				return
+			}
+			if !csrfTokenValid(token, secret) {
+				t.errorResponse(w, r, errors.New("invalid CSRF token"), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// csrfPeekLimit is how much of a multipart body csrfFormToken reads looking for the token field.
+const csrfPeekLimit = 64 << 10
+
+// csrfFormToken returns the token sent in the field of a form body. A multipart body isn't parsed, which
+// would spool every file without the limits UploadFiles applies: its parts are read, and put back, only
+// until the field is found, so it must come before any file, and within the first 64kb.
+func csrfFormToken(r *http.Request, field string) string {
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return r.PostFormValue(field)
+	case mediaType != "multipart/form-data" || params["boundary"] == "" || r.Body == nil:
+		return ""
+	}
+
+	body := r.Body
+	var read bytes.Buffer
+	defer func() {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(&read, body), body}
+	}()
+
+	mr := multipart.NewReader(io.TeeReader(io.LimitReader(body, csrfPeekLimit), &read), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil || part.FileName() != "" {
+			return ""
+		}
+		if part.FormName() == field {
+			b, _ := io.ReadAll(io.LimitReader(part, 1024))
+			return string(b)
+		}
+	}
+}
+
+// checkCSRFOrigin refuses an unsafe request whose Origin, or Referer if there's no Origin, is another site
+// not in trusted. Requests with neither header, such as those from non-browser clients, are allowed.
+func checkCSRFOrigin(r *http.Request, trusted []string) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		if referer, err := url.Parse(r.Referer()); err == nil && referer.Host != "" {
+			origin = referer.Scheme + "://" + referer.Host
+		}
+	}
+	if origin == "" {
+		return nil
+	}
+
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && u.Host == r.Host {
+		return nil
+	}
+	if originAllowed(origin, trusted) {
+		return nil
+	}
+	return errors.New("origin not allowed")
+}
+
+// CSRFToken returns a token for the request to send back in a form field or header, as checked by the CSRF
+// middleware. Each call returns a different token, masked with random bytes so it can't be recovered from
+// compressed responses (the BREACH attack), but they are all valid. It returns an empty string if the request
+// didn't pass through the CSRF middleware.
+func CSRFToken(r *http.Request) string {
+	secret, _ := r.Context().Value(csrfKey).([]byte)
+	if len(secret) != csrfSecretLen {
+		return ""
+	}
+
+	token := make([]byte, 2*csrfSecretLen)
+	if _, err := rand.Read(token[:csrfSecretLen]); err != nil {
+		return ""
+	}
+	for i := range secret {
+		token[csrfSecretLen+i] = token[i] ^ secret[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// CSRFTemplateField returns a hidden form input holding the token from CSRFToken, for use in html/template
+// forms, e.g. {{ .CSRFField }}. The field is named csrf_token; if CSRFOptions.FieldName is changed, write the
+// input by hand with CSRFToken instead.
+func CSRFTemplateField(r *http.Request) template.HTML {
+	return template.HTML(`<input type="hidden" name="csrf_token" value="` + template.HTMLEscapeString(CSRFToken(r)) + `">`)
+}
+
+// WriteCSRFToken writes a JSON response holding the token from CSRFToken as data.csrf_token, for clients
+// such as single-page apps which send it back in a header.
+func (t *Tools) WriteCSRFToken(w http.ResponseWriter, r *http.Request) error {
+	token := CSRFToken(r)
+	if token == "" {
+		return t.ErrorJSON(w, errors.New("CSRF middleware is not in use"), http.StatusInternalServerError)
+	}
+	return t.WriteJSON(w, http.StatusOK, JSONResponse{Data: map[string]string{"csrf_token": token}}, http.Header{"Cache-Control": {"no-store"}})
+}
+
+// csrfTokenValid reports whether token was produced by CSRFToken from secret.
+func csrfTokenValid(token string, secret []byte) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != 2*csrfSecretLen {
+		return false
+	}
+	unmasked := make([]byte, csrfSecretLen)
+	for i := range unmasked {
+		unmasked[i] = b[i] ^ b[csrfSecretLen+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
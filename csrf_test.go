@@ -0,0 +1,202 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// csrfSession makes a GET request through the CSRF middleware and returns the cookie it set and a token.
+func csrfSession(t *testing.T) (*http.Cookie, string) {
+	t.Helper()
+	var token string
+	h := (&Tools{}).CSRF(CSRFOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = CSRFToken(r)
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com/form", nil))
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected a CSRF cookie, got %d cookies", len(cookies))
+	}
+	return cookies[0], token
+}
+
+func TestTools_CSRF(t *testing.T) {
+	var testTools Tools
+	handler := testTools.CSRF(CSRFOptions{TrustedOrigins: []string{"https://*.trusted.com"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	cookie, token := csrfSession(t)
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+		t.Errorf("wrong cookie attributes %+v", cookie)
+	}
+	_, otherToken := csrfSession(t)
+
+	tests := []struct {
+		name           string
+		method         string
+		cookie         bool
+		header         string
+		form           string
+		origin         string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "safe method without token", method: http.MethodGet, expectedStatus: http.StatusNoContent},
+		{name: "header token", method: http.MethodPost, cookie: true, header: token, expectedStatus: http.StatusNoContent},
+		{name: "form token", method: http.MethodPost, cookie: true, form: token, expectedStatus: http.StatusNoContent},
+		{name: "missing token", method: http.MethodPost, cookie: true, expectedStatus: http.StatusForbidden, expectedError: "CSRF token missing"},
+		{name: "token from another session", method: http.MethodPost, cookie: true, header: otherToken, expectedStatus: http.StatusForbidden, expectedError: "invalid CSRF token"},
+		{name: "garbage token", method: http.MethodDelete, cookie: true, header: "abc", expectedStatus: http.StatusForbidden, expectedError: "invalid CSRF token"},
+		{name: "no cookie", method: http.MethodPost, header: token, expectedStatus: http.StatusForbidden, expectedError: "invalid CSRF token"},
+		{name: "same origin", method: http.MethodPut, cookie: true, header: token, origin: "http://example.com", expectedStatus: http.StatusNoContent},
+		{name: "trusted origin", method: http.MethodPut, cookie: true, header: token, origin: "https://app.trusted.com", expectedStatus: http.StatusNoContent},
+		{name: "cross origin", method: http.MethodPost, cookie: true, header: token, origin: "https://evil.com", expectedStatus: http.StatusForbidden, expectedError: "origin not allowed"},
+		{name: "null origin", method: http.MethodPost, cookie: true, header: token, origin: "null", expectedStatus: http.StatusForbidden, expectedError: "origin not allowed"},
+	}
+
+	for _, e := range tests {
+		var body *strings.Reader
+		if e.form != "" {
+			body = strings.NewReader(url.Values{"csrf_token": {e.form}}.Encode())
+		} else {
+			body = strings.NewReader("")
+		}
+		req := httptest.NewRequest(e.method, "http://example.com/submit", body)
+		if e.form != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if e.cookie {
+			req.AddCookie(cookie)
+		}
+		if e.header != "" {
+			req.Header.Set("X-CSRF-Token", e.header)
+		}
+		if e.origin != "" {
+			req.Header.Set("Origin", e.origin)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if e.expectedError != "" {
+			var resp JSONResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Message != e.expectedError {
+				t.Errorf("%s: expected error %q but got %s", e.name, e.expectedError, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if CSRFToken(req) != "" {
+		t.Error("expected no token without the middleware")
+	}
+
+	var first, second string
+	var field string
+	handler := (&Tools{}).CSRF(CSRFOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first, second = CSRFToken(r), CSRFToken(r)
+		field = string(CSRFTemplateField(r))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if first == "" || first == second {
+		t.Errorf("expected two different tokens, got %q and %q", first, second)
+	}
+	if !strings.HasPrefix(field, `<input type="hidden" name="csrf_token" value="`) {
+		t.Errorf("wrong template field %s", field)
+	}
+}
+
+func TestTools_WriteCSRFToken(t *testing.T) {
+	var testTools Tools
+	handler := testTools.CSRF(CSRFOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = testTools.WriteCSRFToken(w, r)
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/csrf", nil))
+
+	var resp struct {
+		Data struct {
+			Token string `json:"csrf_token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Data.Token == "" {
+		t.Errorf("token not written: %s", rr.Body.String())
+	}
+	if rr.Header().Get("Cache-Control") != "no-store" {
+		t.Error("token response should not be cached")
+	}
+}
+
+func TestTools_CSRF_UploadFiles(t *testing.T) {
+	cookie, token := csrfSession(t)
+
+	tests := []struct {
+		name           string
+		token          string
+		tokenLast      bool
+		size           int
+		expectedStatus int
+	}{
+		{name: "token before the file", token: token, size: 100, expectedStatus: http.StatusCreated},
+		{name: "upload over the body limit", token: token, size: 24 << 10, expectedStatus: http.StatusBadRequest},
+		{name: "token after the file", token: token, tokenLast: true, size: 100, expectedStatus: http.StatusForbidden},
+		{name: "missing token", size: 100, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, e := range tests {
+		dir := t.TempDir()
+		testTools := Tools{Limits: Limits{MaxBody: 1000}}
+		var stored []*UploadedFile
+		handler := testTools.CSRF(CSRFOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			files, err := testTools.UploadFiles(r, dir)
+			if err != nil {
+				_ = testTools.ErrorJSON(w, err)
+				return
+			}
+			stored = files
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		if e.token != "" && !e.tokenLast {
+			_ = mw.WriteField("csrf_token", e.token)
+		}
+		fw, _ := mw.CreateFormFile("file", "notes.txt")
+		_, _ = fw.Write(bytes.Repeat([]byte("a"), e.size))
+		if e.token != "" && e.tokenLast {
+			_ = mw.WriteField("csrf_token", e.token)
+		}
+		_ = mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/upload", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d: %s", e.name, e.expectedStatus, rr.Code, rr.Body.String())
+		}
+		if e.expectedStatus == http.StatusCreated && (len(stored) != 1 || stored[0].FileSize != int64(e.size)) {
+			t.Errorf("%s: expected the whole file to be stored, got %+v", e.name, stored)
+		}
+		if e.expectedStatus != http.StatusCreated && len(stored) != 0 {
+			t.Errorf("%s: expected nothing to be stored, got %+v", e.name, stored)
+		}
+	}
+}
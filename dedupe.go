@@ -0,0 +1,76 @@
+package toolkit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+)
+
+// DuplicateChecker keeps track of uploaded files by the SHA-256 hash of their content, so UploadFiles can
+// skip storing a file it already has. The in-memory checker suits a single instance; implement this
+// interface on top of a database to share it across instances and restarts.
+type DuplicateChecker interface {
+	// FindDuplicate returns the name, relative to the upload directory, of a stored file with the
+	// hex-encoded hash, and reports whether there is one.
+	FindDuplicate(ctx context.Context, hash string) (string, bool, error)
+	// RecordFile records that the stored file name, relative to the upload directory, has hash.
+	RecordFile(ctx context.Context, hash, name string) error
+}
+
+// MemoryDuplicateChecker is a DuplicateChecker which keeps hashes in memory.
+type MemoryDuplicateChecker struct {
+	mu    sync.RWMutex
+	files map[string]string
+}
+
+// NewMemoryDuplicateChecker returns an empty MemoryDuplicateChecker.
+func NewMemoryDuplicateChecker() *MemoryDuplicateChecker {
+	return &MemoryDuplicateChecker{files: make(map[string]string)}
+}
+
+// FindDuplicate returns the name recorded for hash, if any.
+func (m *MemoryDuplicateChecker) FindDuplicate(ctx context.Context, hash string) (string, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	name, ok := m.files[hash]
+	return name, ok, nil
+}
+
+// RecordFile records name as the file with hash, replacing any name recorded before.
+func (m *MemoryDuplicateChecker) RecordFile(ctx context.Context, hash, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[hash] = name
+	return nil
+}
+
+// hashContent returns the hex-encoded SHA-256 hash of everything read from r.
+func hashContent(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findDuplicateUpload asks DuplicateChecker for a stored file with hash, and returns its name if it is
+// still in uploadDir.
+func (t *Tools) findDuplicateUpload(ctx context.Context, uploadDir, hash string) (string, bool, error) {
+	name, found, err := t.DuplicateChecker.FindDuplicate(ctx, hash)
+	if err != nil || !found {
+		return "", false, err
+	}
+
+	p, err := t.EnsureWithinBase(uploadDir, name)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := os.Stat(p); err != nil {
+		// the file has gone, so store this one again
+		return "", false, nil
+	}
+	return name, true, nil
+}
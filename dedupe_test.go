@@ -0,0 +1,60 @@
+package toolkit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTools_UploadFiles_Duplicates(t *testing.T) {
+	dir := t.TempDir()
+	checker := NewMemoryDuplicateChecker()
+	testTools := Tools{DuplicateChecker: checker}
+
+	upload := func() *UploadedFile {
+		body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		file, err := testTools.UploadOneFile(request, dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return file
+	}
+
+	first := upload()
+	if first.Duplicate {
+		t.Error("first upload should not be a duplicate")
+	}
+	if len(first.SHA256) != 64 {
+		t.Errorf("expected a SHA-256 hash but got %q", first.SHA256)
+	}
+	if name, ok, _ := checker.FindDuplicate(context.Background(), first.SHA256); !ok || name != first.NewFileName {
+		t.Errorf("upload was not recorded; got %q, %t", name, ok)
+	}
+
+	second := upload()
+	if !second.Duplicate {
+		t.Error("second upload should be a duplicate")
+	}
+	if second.NewFileName != first.NewFileName || second.SHA256 != first.SHA256 || second.FileSize != first.FileSize {
+		t.Errorf("duplicate should refer to the stored file; got %+v, expected %+v", second, first)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected one stored file but got %d", len(entries))
+	}
+
+	// once the stored file is removed, the next upload is stored again
+	_ = os.Remove(filepath.Join(dir, first.NewFileName))
+	third := upload()
+	if third.Duplicate || third.NewFileName == first.NewFileName {
+		t.Errorf("upload should have been stored again, got %+v", third)
+	}
+	if _, err := os.Stat(filepath.Join(dir, third.NewFileName)); err != nil {
+		t.Errorf("file not stored: %s", err)
+	}
+}
@@ -0,0 +1,85 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrInsufficientStorage is wrapped by the error UploadFiles returns when the upload directory has less free
+// space than MinFreeDiskSpace or MinFreeDiskPercent require.
+var ErrInsufficientStorage = errors.New("insufficient storage")
+
+// DiskUsage describes the space on the file system holding Path.
+type DiskUsage struct {
+	Path        string  `json:"path"`
+	Total       uint64  `json:"total"`        // the size of the file system, in bytes
+	Free        uint64  `json:"free"`         // the bytes available to unprivileged users
+	Used        uint64  `json:"used"`         // the bytes which aren't free
+	FreePercent float64 `json:"free_percent"` // Free as a percentage of Total
+}
+
+// GetDiskUsage returns the size and free space of the file system holding path. It isn't supported on
+// platforms other than Unix.
+func GetDiskUsage(path string) (DiskUsage, error) {
+	total, free, err := diskSpace(path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	usage := DiskUsage{Path: path, Total: total, Free: free}
+	if total > 0 {
+		usage.Used = total - min(free, total)
+		usage.FreePercent = float64(free) / float64(total) * 100
+	}
+	return usage, nil
+}
+
+// checkDiskSpace returns a 507 Insufficient Storage *HTTPError if the file system holding dir would have
+// less free space than MinFreeDiskSpace or MinFreeDiskPercent require once incoming more bytes are
+// written. Platforms where free space can't be determined are not checked.
+func (t *Tools) checkDiskSpace(dir string, incoming int64) error {
+	if t.MinFreeDiskSpace == 0 && t.MinFreeDiskPercent <= 0 {
+		return nil
+	}
+	usage, err := GetDiskUsage(dir)
+	if errors.Is(err, errDiskSpaceUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := t.diskSpaceError(usage, incoming); err != nil {
+		return &HTTPError{Status: http.StatusInsufficientStorage, PublicMessage: "there is not enough storage space for the upload", Internal: err}
+	}
+	return nil
+}
+
+// diskSpaceError returns an error wrapping ErrInsufficientStorage if usage leaves too little free space once
+// incoming more bytes are written.
+func (t *Tools) diskSpaceError(usage DiskUsage, incoming int64) error {
+	free := usage.Free - min(usage.Free, uint64(max(incoming, 0)))
+	if free < t.MinFreeDiskSpace {
+		return fmt.Errorf("%w: %d bytes free on %s, need %d", ErrInsufficientStorage, free, usage.Path, t.MinFreeDiskSpace)
+	}
+	if t.MinFreeDiskPercent > 0 && usage.Total > 0 {
+		if pct := float64(free) / float64(usage.Total) * 100; pct < t.MinFreeDiskPercent {
+			return fmt.Errorf("%w: %.1f%% free on %s, need %.1f%%", ErrInsufficientStorage, pct, usage.Path, t.MinFreeDiskPercent)
+		}
+	}
+	return nil
+}
+
+// UploadDiskSpaceCheck returns a health check which fails when the file system holding uploadDir has less
+// free space than MinFreeDiskSpace or MinFreeDiskPercent require, so UploadFiles would refuse uploads. The
+// check reports the DiskUsage in its details.
+func (t *Tools) UploadDiskSpaceCheck(uploadDir string) HealthCheck {
+	return func(ctx context.Context) error {
+		usage, err := GetDiskUsage(uploadDir)
+		if err != nil {
+			return err
+		}
+		SetHealthDetails(ctx, usage)
+		return t.diskSpaceError(usage, 0)
+	}
+}
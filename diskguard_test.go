@@ -0,0 +1,98 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTools_diskSpaceError(t *testing.T) {
+	usage := DiskUsage{Path: "/data", Total: 1000, Free: 300, Used: 700, FreePercent: 30}
+
+	tests := []struct {
+		name          string
+		minFree       uint64
+		minPercent    float64
+		incoming      int64
+		errorExpected bool
+	}{
+		{name: "no limits", incoming: 1000},
+		{name: "enough bytes", minFree: 200, incoming: 100},
+		{name: "too few bytes", minFree: 200, incoming: 101, errorExpected: true},
+		{name: "enough percent", minPercent: 25, incoming: 50},
+		{name: "too small a percent", minPercent: 25, incoming: 51, errorExpected: true},
+		{name: "unknown size", minFree: 300, incoming: -1},
+		{name: "upload larger than free space", minFree: 1, incoming: 5000, errorExpected: true},
+	}
+
+	for _, e := range tests {
+		testTools := Tools{MinFreeDiskSpace: e.minFree, MinFreeDiskPercent: e.minPercent}
+		err := testTools.diskSpaceError(usage, e.incoming)
+		if e.errorExpected != (err != nil) {
+			t.Errorf("%s: expected error to be %t, got %v", e.name, e.errorExpected, err)
+		}
+		if err != nil && !errors.Is(err, ErrInsufficientStorage) {
+			t.Errorf("%s: expected ErrInsufficientStorage, got %v", e.name, err)
+		}
+	}
+}
+
+func TestTools_UploadFiles_DiskSpace(t *testing.T) {
+	if _, err := GetDiskUsage(t.TempDir()); errors.Is(err, errDiskSpaceUnsupported) {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name          string
+		tools         Tools
+		errorExpected bool
+	}{
+		{name: "enough space", tools: Tools{MinFreeDiskSpace: 1, MinFreeDiskPercent: 0.0001}},
+		{name: "too few bytes", tools: Tools{MinFreeDiskSpace: ^uint64(0)}, errorExpected: true},
+		{name: "too small a percent", tools: Tools{MinFreeDiskPercent: 100}, errorExpected: true},
+	}
+
+	for _, e := range tests {
+		body, contentType := e.tools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		_, err := e.tools.UploadFiles(request, t.TempDir())
+		if !e.errorExpected {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", e.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInsufficientStorage) {
+			t.Errorf("%s: expected ErrInsufficientStorage, got %v", e.name, err)
+			continue
+		}
+
+		rr := httptest.NewRecorder()
+		_ = e.tools.ErrorJSON(rr, err)
+		if rr.Code != http.StatusInsufficientStorage {
+			t.Errorf("%s: expected status %d, got %d", e.name, http.StatusInsufficientStorage, rr.Code)
+		}
+	}
+}
+
+func TestTools_UploadDiskSpaceCheck(t *testing.T) {
+	if _, err := GetDiskUsage(t.TempDir()); errors.Is(err, errDiskSpaceUnsupported) {
+		t.Skip(err)
+	}
+
+	testTools := Tools{MinFreeDiskSpace: 1}
+	if err := testTools.UploadDiskSpaceCheck(t.TempDir())(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	testTools.MinFreeDiskPercent = 100
+	if err := testTools.UploadDiskSpaceCheck(t.TempDir())(context.Background()); !errors.Is(err, ErrInsufficientStorage) {
+		t.Errorf("expected ErrInsufficientStorage, got %v", err)
+	}
+	if err := testTools.UploadDiskSpaceCheck("./does/not/exist")(context.Background()); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
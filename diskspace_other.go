@@ -0,0 +1,8 @@
+//go:build !unix
+
+package toolkit
+
+// diskSpace isn't implemented on this platform.
+func diskSpace(path string) (total, free uint64, err error) {
+	return 0, 0, errDiskSpaceUnsupported
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package toolkit
+
+import "syscall"
+
+// diskSpace returns the size of the file system holding path, and the bytes available on it to
+// unprivileged users.
+func diskSpace(path string) (total, free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return uint64(stat.Blocks) * uint64(stat.Bsize), uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
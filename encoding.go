@@ -0,0 +1,50 @@
+package toolkit
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// requestBody limits the request body to maxBytes, and returns a reader for it. If the body was sent
+// with Content-Encoding gzip or deflate, the returned reader decompresses it, and stops with an
+// *http.MaxBytesError once more than MaxDecompressedSize bytes (or maxBytes, if that isn't set) have been
+// decompressed, so a small compressed body can't expand into an enormous one.
+func (t *Tools) requestBody(w http.ResponseWriter, r *http.Request, maxBytes int) (io.Reader, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	maxDecompressed := maxBytes
+	if t.MaxDecompressedSize != 0 {
+		maxDecompressed = t.MaxDecompressedSize
+	}
+
+	var decompressed io.ReadCloser
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return r.Body, nil
+
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, errors.New("body is not valid gzip")
+		}
+		decompressed = gz
+
+	case "deflate":
+		// Despite the name, the deflate content coding is the zlib format (RFC 1950).
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			return nil, errors.New("body is not valid deflate")
+		}
+		decompressed = zr
+
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+
+	return http.MaxBytesReader(w, decompressed, int64(maxDecompressed)), nil
+}
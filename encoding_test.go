@@ -0,0 +1,87 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(s string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, _ = gz.Write([]byte(s))
+	_ = gz.Close()
+	return buf.Bytes()
+}
+
+func deflateBytes(s string) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write([]byte(s))
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+var compressedJSONTests = []struct {
+	name            string
+	body            []byte
+	encoding        string
+	maxDecompressed int
+	errorExpected   bool
+}{
+	{name: "gzip", body: gzipBytes(`{"foo": "bar"}`), encoding: "gzip", errorExpected: false},
+	{name: "deflate", body: deflateBytes(`{"foo": "bar"}`), encoding: "deflate", errorExpected: false},
+	{name: "identity", body: []byte(`{"foo": "bar"}`), encoding: "identity", errorExpected: false},
+	{name: "invalid gzip", body: []byte(`{"foo": "bar"}`), encoding: "gzip", errorExpected: true},
+	{name: "unsupported encoding", body: []byte(`{"foo": "bar"}`), encoding: "br", errorExpected: true},
+	{name: "zip bomb", body: gzipBytes(`{"foo": "` + strings.Repeat("a", 100000) + `"}`), encoding: "gzip", maxDecompressed: 1024, errorExpected: true},
+}
+
+func TestTools_ReadJSON_ContentEncoding(t *testing.T) {
+	for _, e := range compressedJSONTests {
+		var testTools Tools
+		testTools.MaxDecompressedSize = e.maxDecompressed
+
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(e.body))
+		req.Header.Set("Content-Encoding", e.encoding)
+		rr := httptest.NewRecorder()
+
+		err := testTools.ReadJSON(rr, req, &decodedJSON)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected but one received: %s", e.name, err)
+		}
+		if !e.errorExpected && decodedJSON.Foo != "bar" {
+			t.Errorf("%s: wrong value decoded; expected bar but got %s", e.name, decodedJSON.Foo)
+		}
+	}
+}
+
+func TestTools_ReadXML_ContentEncoding(t *testing.T) {
+	var testTools Tools
+
+	var note struct {
+		To string `xml:"to"`
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(gzipBytes(`<note><to>John Smith</to></note>`)))
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	if err := testTools.ReadXML(rr, req, &note); err != nil {
+		t.Fatal(err)
+	}
+	if note.To != "John Smith" {
+		t.Errorf("wrong value decoded; expected John Smith but got %s", note.To)
+	}
+}
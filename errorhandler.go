@@ -0,0 +1,63 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorResponse is an error response about to be sent by ErrorJSON, ErrorXML, ErrorJSONLocalized or the
+// middleware, passed to the ErrorHandler to report or change.
+type ErrorResponse struct {
+	Request *http.Request // the request, or nil when sent by ErrorJSON or ErrorXML, which don't receive it
+	Err     error         // the error; its message, or an HTTPError's public message, is sent unless Payload is set
+	Status  int           // the status code
+	XML     bool          // whether the response is sent as XML rather than JSON
+	Payload any           // if set, sent in place of the default JSONResponse or XMLResponse envelope
+	Panic   any           // the value recovered, if Recoverer is sending the response
+	Stack   []byte        // the stack trace of the panic, if Recoverer is sending the response
+}
+
+// ErrorHandler is called with every error response the toolkit sends, before it is written, so an app can
+// report errors to a service such as Sentry, hide the details of internal errors in production, or use its
+// own envelope, all in one place. It may change the Err, Status and Payload of resp.
+type ErrorHandler interface {
+	HandleError(resp *ErrorResponse)
+}
+
+// ErrorHandlerFunc is an adapter allowing an ordinary function to be used as an ErrorHandler.
+type ErrorHandlerFunc func(resp *ErrorResponse)
+
+// HandleError calls f(resp).
+func (f ErrorHandlerFunc) HandleError(resp *ErrorResponse) {
+	f(resp)
+}
+
+// sendError passes resp to the ErrorHandler, if there is one, and then writes it. Without a status, the
+// one of an *HTTPError is used, or else 400 Bad Request.
+func (t *Tools) sendError(w http.ResponseWriter, resp *ErrorResponse) error {
+	var httpErr *HTTPError
+	if resp.Status == 0 && errors.As(resp.Err, &httpErr) {
+		resp.Status = httpErr.Status
+	}
+	if resp.Status == 0 {
+		resp.Status = http.StatusBadRequest
+	}
+
+	if t.ErrorHandler != nil {
+		t.ErrorHandler.HandleError(resp)
+	}
+
+	payload := resp.Payload
+	if resp.XML {
+		if payload == nil {
+			message, data := errorEnvelope(resp.Err)
+			payload = XMLResponse{Error: true, Message: message, Data: data}
+		}
+		return t.WriteXML(w, resp.Status, payload)
+	}
+	if payload == nil {
+		message, data := errorEnvelope(resp.Err)
+		payload = JSONResponse{Error: true, Message: message, Data: data}
+	}
+	return t.WriteJSON(w, resp.Status, payload)
+}
@@ -0,0 +1,113 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTools_ErrorHandler(t *testing.T) {
+	var got []ErrorResponse
+	testTools := Tools{ErrorHandler: ErrorHandlerFunc(func(resp *ErrorResponse) {
+		got = append(got, *resp)
+		if resp.Status >= http.StatusInternalServerError {
+			resp.Err = errors.New("something went wrong")
+		}
+	})}
+
+	tests := []struct {
+		name           string
+		send           func(w http.ResponseWriter, r *http.Request)
+		accept         string
+		expectedStatus int
+		expectedBody   string
+		expectRequest  bool
+		expectXML      bool
+	}{
+		{name: "ErrorJSON", send: func(w http.ResponseWriter, r *http.Request) {
+			_ = testTools.ErrorJSON(w, errors.New("bad input"))
+		}, expectedStatus: http.StatusBadRequest, expectedBody: `{"error":true,"message":"bad input"}`},
+		{name: "ErrorJSON redacted", send: func(w http.ResponseWriter, r *http.Request) {
+			_ = testTools.ErrorJSON(w, errors.New("pq: connection refused"), http.StatusInternalServerError)
+		}, expectedStatus: http.StatusInternalServerError, expectedBody: `{"error":true,"message":"something went wrong"}`},
+		{name: "ErrorXML", send: func(w http.ResponseWriter, r *http.Request) {
+			_ = testTools.ErrorXML(w, errors.New("disk full"), http.StatusInsufficientStorage)
+		}, expectedStatus: http.StatusInsufficientStorage, expectedBody: "<message>something went wrong</message>", expectXML: true},
+		{name: "ErrorJSONLocalized", send: func(w http.ResponseWriter, r *http.Request) {
+			_ = testTools.ErrorJSONLocalized(w, r, errors.New("bad input"), http.StatusUnprocessableEntity)
+		}, expectedStatus: http.StatusUnprocessableEntity, expectedBody: `{"error":true,"message":"bad input"}`, expectRequest: true},
+		{name: "middleware prefers XML", send: func(w http.ResponseWriter, r *http.Request) {
+			testTools.errorResponse(w, r, errors.New("forbidden"), http.StatusForbidden)
+		}, accept: "application/xml", expectedStatus: http.StatusForbidden, expectedBody: "<message>forbidden</message>", expectRequest: true, expectXML: true},
+	}
+
+	for _, e := range tests {
+		got = nil
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if e.accept != "" {
+			req.Header.Set("Accept", e.accept)
+		}
+		rr := httptest.NewRecorder()
+		e.send(rr, req)
+
+		if len(got) != 1 {
+			t.Errorf("%s: expected the handler to be called once, got %d", e.name, len(got))
+			continue
+		}
+		if got[0].XML != e.expectXML || (got[0].Request != nil) != e.expectRequest {
+			t.Errorf("%s: wrong response passed to the handler: %+v", e.name, got[0])
+		}
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), e.expectedBody) {
+			t.Errorf("%s: expected body to contain %s, got %s", e.name, e.expectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_ErrorHandlerPayload(t *testing.T) {
+	type problem struct {
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+	}
+	testTools := Tools{ErrorHandler: ErrorHandlerFunc(func(resp *ErrorResponse) {
+		resp.Status = http.StatusConflict
+		resp.Payload = problem{Title: resp.Err.Error(), Status: resp.Status}
+	})}
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, errors.New("already exists"))
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr.Body.String() != `{"title":"already exists","status":409}` {
+		t.Errorf("expected the handler's payload, got %s", rr.Body.String())
+	}
+}
+
+func TestTools_ErrorHandlerRecoverer(t *testing.T) {
+	var got *ErrorResponse
+	testTools := Tools{ErrorHandler: ErrorHandlerFunc(func(resp *ErrorResponse) {
+		got = resp
+	})}
+
+	handler := testTools.Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got == nil {
+		t.Fatal("expected the handler to be called")
+	}
+	if got.Panic != "boom" || len(got.Stack) == 0 || got.Request == nil {
+		t.Errorf("expected the panic, stack and request, got %v, %d bytes, %v", got.Panic, len(got.Stack), got.Request)
+	}
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "Internal Server Error") {
+		t.Errorf("expected a 500 response, got %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,152 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+)
+
+// ErrEventBusClosed is returned by Publish once the bus has been shut down.
+var ErrEventBusClosed = errors.New("event bus is shut down")
+
+// SubscribeOptions configures a subscription made with Subscribe.
+type SubscribeOptions struct {
+	// Async runs the handler in its own goroutine, so Publish doesn't wait for it, and its error is logged
+	// rather than returned. Use it for slow side effects, such as sending an email.
+	Async bool
+}
+
+// EventBus delivers events published in the process to the handlers subscribed to their type, so the code
+// raising an event, such as an upload completing or a user signing up, doesn't need to know what reacts
+// to it. Events are matched by their exact type, so a handler for a struct doesn't receive pointers to it.
+// It is safe for concurrent use.
+type EventBus struct {
+	tools  *Tools
+	mu     sync.RWMutex
+	subs   map[reflect.Type][]*subscription
+	nextID uint64
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// subscription is a handler subscribed to an event type.
+type subscription struct {
+	id    uint64
+	name  string // the event type, for logs
+	async bool
+	fn    func(ctx context.Context, event any) error
+}
+
+// NewEventBus returns an event bus with no subscribers, which logs the failures of asynchronous handlers
+// using t.
+func (t *Tools) NewEventBus() *EventBus {
+	return &EventBus{tools: t, subs: make(map[reflect.Type][]*subscription)}
+}
+
+// Subscribe registers fn to be called with every event of type T published to b, and returns a function
+// which unsubscribes it. Handlers are called in the order they subscribed.
+func Subscribe[T any](b *EventBus, fn func(ctx context.Context, event T) error, opts ...SubscribeOptions) (unsubscribe func()) {
+	var o SubscribeOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	typ := reflect.TypeFor[T]()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	sub := &subscription{
+		id:    b.nextID,
+		name:  typ.String(),
+		async: o.Async,
+		fn:    func(ctx context.Context, event any) error { return fn(ctx, event.(T)) },
+	}
+	b.subs[typ] = append(b.subs[typ], sub)
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		subs := b.subs[typ]
+		for i, s := range subs {
+			if s.id == sub.id {
+				// copy, so a Publish ranging over the old slice isn't affected
+				b.subs[typ] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Publish delivers event to the handlers subscribed to type T. Synchronous handlers are called in turn,
+// and their errors are returned joined; asynchronous handlers are started in the background, with a
+// context which isn't cancelled with ctx, and their errors are logged. A handler which panics is treated
+// as failed, without stopping the others or taking the process down.
+func Publish[T any](ctx context.Context, b *EventBus, event T) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrEventBusClosed
+	}
+	subs := b.subs[reflect.TypeFor[T]()]
+	for _, s := range subs {
+		if s.async {
+			b.wg.Add(1)
+		}
+	}
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, s := range subs {
+		if s.async {
+			go func() {
+				defer b.wg.Done()
+				ctx := context.WithoutCancel(ctx)
+				if err := b.call(ctx, s, event); err != nil {
+					b.tools.LogError(ctx, "event handler failed", "event", s.name, "error", err)
+				}
+			}()
+			continue
+		}
+		if err := b.call(ctx, s, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// call calls the handler, turning a panic into an error.
+func (b *EventBus) call(ctx context.Context, s *subscription, event any) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			b.tools.LogError(ctx, "event handler panicked", "event", s.name, "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+			err = fmt.Errorf("event handler for %s panicked: %v", s.name, rec)
+		}
+	}()
+	return s.fn(ctx, event)
+}
+
+// Shutdown stops the bus accepting events, and waits for the asynchronous handlers still running to
+// finish, or for ctx to be done, when ctx's error is returned.
+func (b *EventBus) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,109 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type uploadCompleted struct {
+	Path string
+}
+
+type userCreated struct {
+	Email string
+}
+
+func TestEventBus(t *testing.T) {
+	var testTools Tools
+	bus := testTools.NewEventBus()
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, s)
+	}
+
+	Subscribe(bus, func(ctx context.Context, e uploadCompleted) error {
+		record("thumbnail " + e.Path)
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, e uploadCompleted) error {
+		panic("scanner crashed")
+	})
+	Subscribe(bus, func(ctx context.Context, e uploadCompleted) error {
+		record("index " + e.Path)
+		return errors.New("index unavailable")
+	})
+	unsubscribe := Subscribe(bus, func(ctx context.Context, e uploadCompleted) error {
+		record("unsubscribed")
+		return nil
+	})
+	unsubscribe()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	Subscribe(bus, func(ctx context.Context, e userCreated) error {
+		close(started)
+		<-release
+		record("welcome " + e.Email)
+		return errors.New("smtp down")
+	}, SubscribeOptions{Async: true})
+	Subscribe(bus, func(ctx context.Context, e *userCreated) error {
+		record("pointer")
+		return nil
+	})
+
+	err := Publish(context.Background(), bus, uploadCompleted{Path: "a.png"})
+	if err == nil || !strings.Contains(err.Error(), "panicked: scanner crashed") || !strings.Contains(err.Error(), "index unavailable") {
+		t.Errorf("expected the handler errors joined, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := Publish(ctx, bus, userCreated{Email: "a@example.com"}); err != nil {
+		t.Errorf("expected async handler errors to be logged, got %v", err)
+	}
+	cancel()
+	<-started
+	if slices.Contains(calls, "welcome a@example.com") {
+		t.Error("expected Publish not to wait for the async handler")
+	}
+	close(release)
+
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"thumbnail a.png", "index a.png", "welcome a@example.com"}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+
+	if err := Publish(context.Background(), bus, uploadCompleted{}); !errors.Is(err, ErrEventBusClosed) {
+		t.Errorf("expected ErrEventBusClosed, got %v", err)
+	}
+}
+
+func TestEventBus_ShutdownTimeout(t *testing.T) {
+	var testTools Tools
+	bus := testTools.NewEventBus()
+
+	release := make(chan struct{})
+	defer close(release)
+	Subscribe(bus, func(ctx context.Context, e userCreated) error {
+		<-release
+		return nil
+	}, SubscribeOptions{Async: true})
+	_ = Publish(context.Background(), bus, userCreated{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bus.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// fieldTree is a parsed set of field paths. A nil subtree keeps the whole value.
+type fieldTree map[string]fieldTree
+
+// WriteJSONFiltered writes data like WriteJSON, but keeps only the fields listed in the request's fields
+// query parameter (e.g. ?fields=id,name,author.name), so clients can ask for a sparse response. Dots select
+// fields of nested objects, and fields apply to every element of an array, whether the array is the whole
+// response or nested in it. Fields which don't exist are ignored. Without a fields parameter the response
+// is written in full.
+func (t *Tools) WriteJSONFiltered(w http.ResponseWriter, r *http.Request, status int, data interface{}, headers ...http.Header) error {
+	fields := QueryStringSlice(r, "fields", nil)
+	if len(fields) == 0 {
+		return t.WriteJSON(w, status, data, headers...)
+	}
+
+	buf, err := t.encodeJSON(data)
+	if err != nil {
+		t.writeJSONFailure(w, err)
+		return err
+	}
+	defer putBuffer(buf)
+
+	out := getBuffer()
+	defer putBuffer(out)
+
+	if err := pruneJSON(out, buf.Bytes(), parseFieldPaths(fields), !t.JSONOptions.DisableHTMLEscaping); err != nil {
+		return err
+	}
+
+	return t.writeJSONBody(w, status, out.Bytes(), headers...)
+}
+
+// parseFieldPaths builds a fieldTree from dotted paths.
+func parseFieldPaths(paths []string) fieldTree {
+	tree := fieldTree{}
+	for _, p := range paths {
+		node := tree
+		parts := strings.Split(p, ".")
+		for i, part := range parts {
+			sub, seen := node[part]
+			if seen && sub == nil {
+				// a shorter path already keeps the whole value
+				break
+			}
+			if i == len(parts)-1 {
+				node[part] = nil
+				break
+			}
+			if sub == nil {
+				sub = fieldTree{}
+				node[part] = sub
+			}
+			node = sub
+		}
+	}
+	return tree
+}
+
+// pruneJSON writes the encoded value raw to out, keeping only the fields in tree. Object keys keep their
+// original order, and keys are written with HTML characters escaped if escapeHTML is set.
+func pruneJSON(out *bytes.Buffer, raw []byte, tree fieldTree, escapeHTML bool) error {
+	raw = bytes.TrimSpace(raw)
+	if tree == nil || len(raw) == 0 || (raw[0] != '{' && raw[0] != '[') {
+		out.Write(raw)
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	if raw[0] == '[' {
+		out.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			var elem json.RawMessage
+			if err := dec.Decode(&elem); err != nil {
+				return err
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := pruneJSON(out, elem, tree, escapeHTML); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+		return nil
+	}
+
+	out.WriteByte('{')
+	first := true
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+
+		sub, keep := tree[key]
+		if !keep {
+			continue
+		}
+		if !first {
+			out.WriteByte(',')
+		}
+		first = false
+
+		if err := writeJSONKey(out, key, escapeHTML); err != nil {
+			return err
+		}
+		if err := pruneJSON(out, value, sub, escapeHTML); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
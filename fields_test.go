@@ -0,0 +1,64 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type filteredAuthor struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Bio  string `json:"bio"`
+}
+
+type filteredBook struct {
+	ID      int              `json:"id"`
+	Title   string           `json:"title"`
+	Author  filteredAuthor   `json:"author"`
+	Editors []filteredAuthor `json:"editors"`
+	Tags    []string         `json:"tags"`
+}
+
+var filteredBooks = []filteredBook{
+	{ID: 1, Title: "Go", Author: filteredAuthor{ID: 7, Name: "Ann", Bio: "long"}, Editors: []filteredAuthor{{ID: 8, Name: "Bob"}}, Tags: []string{"x"}},
+	{ID: 2, Title: "C", Author: filteredAuthor{ID: 9, Name: "Cy"}},
+}
+
+var writeJSONFilteredTests = []struct {
+	name     string
+	fields   string
+	data     any
+	expected string
+}{
+	{name: "no fields", fields: "", data: filteredBooks[1], expected: `{"id":2,"title":"C","author":{"id":9,"name":"Cy","bio":""},"editors":null,"tags":null}`},
+	{name: "top level", fields: "title,id", data: filteredBooks[1], expected: `{"id":2,"title":"C"}`},
+	{name: "nested", fields: "id,author.name", data: filteredBooks[0], expected: `{"id":1,"author":{"name":"Ann"}}`},
+	{name: "nested array", fields: "editors.name", data: filteredBooks[0], expected: `{"editors":[{"name":"Bob"}]}`},
+	{name: "whole value wins", fields: "author.name,author", data: filteredBooks[0], expected: `{"author":{"id":7,"name":"Ann","bio":"long"}}`},
+	{name: "scalar array kept", fields: "tags", data: filteredBooks[0], expected: `{"tags":["x"]}`},
+	{name: "top level array", fields: "id", data: filteredBooks, expected: `[{"id":1},{"id":2}]`},
+	{name: "envelope", fields: "message,data.title", data: JSONResponse{Message: "ok", Data: filteredBooks}, expected: `{"message":"ok","data":[{"title":"Go"},{"title":"C"}]}`},
+	{name: "unknown field", fields: "missing", data: filteredBooks[1], expected: `{}`},
+}
+
+func TestTools_WriteJSONFiltered(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range writeJSONFilteredTests {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/?fields="+url.QueryEscape(e.fields), nil)
+
+		if err := testTools.WriteJSONFiltered(rr, req, http.StatusOK, e.data); err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: wrong body;\nexpected %s\n but got %s", e.name, e.expected, rr.Body.String())
+		}
+		if rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: wrong content type %q", e.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+)
+
+// ErrMissingUpload is wrapped by the error UploadFilesFromField returns when a Required field has no files.
+var ErrMissingUpload = errors.New("required file missing")
+
+// UploadOptions are the rules UploadFilesFromField applies to the files of one form field. Unset options
+// fall back to the settings of Tools.
+type UploadOptions struct {
+	KeepFileNames    bool       // store the files under their original names, instead of random ones
+	AllowedFileTypes []string   // allowed file types for the field; if this and AllowedTypes are nil, those of Tools apply
+	AllowedTypes     []TypeRule // allowed file types for the field, with optional per-type size limits
+	MaxFileSize      int64      // maximum size of each file in the field in bytes; 0 means no limit of its own
+	MaxFiles         int        // maximum number of files in the field; 0 means no limit of its own
+	Required         bool       // fail if the field has no files
+}
+
+// UploadFilesFromField stores the files sent in the form field called field in uploadDir, as UploadFiles
+// does, applying the rules of opts to them. Files in other fields are ignored, so a handler with several
+// file inputs (an avatar and documents, say) can call it once for each, with different rules. The form is
+// parsed once, on the first call.
+func (t *Tools) UploadFilesFromField(r *http.Request, field, uploadDir string, opts ...UploadOptions) ([]*UploadedFile, error) {
+	var o UploadOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	ft := *t
+	if o.AllowedFileTypes != nil || o.AllowedTypes != nil {
+		ft.AllowedFileTypes, ft.AllowedTypes = o.AllowedFileTypes, o.AllowedTypes
+	}
+	only := &fieldUpload{name: field, maxFiles: o.MaxFiles, maxFileSize: o.MaxFileSize, required: o.Required}
+	return ft.uploadFiles(r, uploadDir, !o.KeepFileNames, only)
+}
+
+// fieldUpload is the field UploadFilesFromField stores the files of, and the limits they are checked against
+// before any is stored.
+type fieldUpload struct {
+	name        string
+	maxFiles    int
+	maxFileSize int64
+	required    bool
+}
+
+// check returns an error if the files of the field in form break its limits.
+func (f *fieldUpload) check(form *multipart.Form) error {
+	hdrs := form.File[f.name]
+	if f.required && len(hdrs) == 0 {
+		return &HTTPError{Status: http.StatusBadRequest, PublicMessage: fmt.Sprintf("field %s must contain a file", f.name), Internal: ErrMissingUpload}
+	}
+	if f.maxFiles > 0 && len(hdrs) > f.maxFiles {
+		return &HTTPError{Status: http.StatusRequestEntityTooLarge, PublicMessage: fmt.Sprintf("field %s must not contain more than %d files", f.name, f.maxFiles), Internal: ErrTooManyFiles}
+	}
+	for _, hdr := range hdrs {
+		if f.maxFileSize > 0 && hdr.Size > f.maxFileSize {
+			return &HTTPError{Status: http.StatusRequestEntityTooLarge, PublicMessage: fmt.Sprintf("files in field %s must not be larger than %s", f.name, HumanBytes(f.maxFileSize))}
+		}
+	}
+	return nil
+}
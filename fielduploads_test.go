@@ -0,0 +1,90 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestTools_UploadFilesFromField(t *testing.T) {
+	files := []MultipartFile{
+		{FieldName: "avatar", Path: "./testdata/img.png"},
+		{FieldName: "documents", Path: "./testdata/pic.jpg"},
+		{FieldName: "documents", Path: "./testdata/img.png"},
+	}
+
+	tests := []struct {
+		name     string
+		tools    Tools
+		field    string
+		opts     UploadOptions
+		stored   int
+		status   int
+		expected error
+	}{
+		{name: "one field", field: "documents", stored: 2},
+		{name: "original names", field: "avatar", opts: UploadOptions{KeepFileNames: true}, stored: 1},
+		{name: "field types", tools: Tools{AllowedFileTypes: []string{"image/jpeg"}}, field: "avatar", opts: UploadOptions{AllowedFileTypes: []string{"image/png"}}, stored: 1},
+		{name: "field type rejected", field: "avatar", opts: UploadOptions{AllowedTypes: []TypeRule{{Pattern: "image/jpeg"}}}},
+		{name: "tools types apply", tools: Tools{AllowedFileTypes: []string{"image/jpeg"}}, field: "avatar"},
+		{name: "too many files", field: "documents", opts: UploadOptions{MaxFiles: 1}, status: http.StatusRequestEntityTooLarge, expected: ErrTooManyFiles},
+		{name: "too large", field: "avatar", opts: UploadOptions{MaxFileSize: 100}, status: http.StatusRequestEntityTooLarge},
+		{name: "missing, optional", field: "cover"},
+		{name: "missing, required", field: "cover", opts: UploadOptions{Required: true}, status: http.StatusBadRequest, expected: ErrMissingUpload},
+	}
+
+	for _, e := range tests {
+		dir := t.TempDir()
+		body, contentType := e.tools.BuildMultipartBody(files, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		uploaded, err := e.tools.UploadFilesFromField(request, e.field, dir, e.opts)
+		if e.status != 0 {
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.Status != e.status {
+				t.Errorf("%s: expected a %d error, got %v", e.name, e.status, err)
+			}
+			if e.expected != nil && !errors.Is(err, e.expected) {
+				t.Errorf("%s: expected %v, got %v", e.name, e.expected, err)
+			}
+		}
+		if e.stored == 0 {
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("%s: expected nothing stored, got %d files", e.name, len(entries))
+			}
+			continue
+		}
+
+		if err != nil || len(uploaded) != e.stored {
+			t.Errorf("%s: expected %d files, got %d and %v", e.name, e.stored, len(uploaded), err)
+			continue
+		}
+		if e.opts.KeepFileNames && uploaded[0].NewFileName != "img.png" {
+			t.Errorf("%s: expected the original name, got %s", e.name, uploaded[0].NewFileName)
+		}
+	}
+}
+
+func TestTools_UploadFilesFromField_SeveralFields(t *testing.T) {
+	var testTools Tools
+	files := []MultipartFile{
+		{FieldName: "avatar", Path: "./testdata/img.png"},
+		{FieldName: "documents", Path: "./testdata/pic.jpg"},
+	}
+	body, contentType := testTools.BuildMultipartBody(files, nil)
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Add("Content-Type", contentType)
+
+	// the form is parsed once, and each call stores the files of its own field
+	avatars, err := testTools.UploadFilesFromField(request, "avatar", t.TempDir(), UploadOptions{AllowedFileTypes: []string{"image/png"}, MaxFiles: 1})
+	if err != nil || len(avatars) != 1 || avatars[0].OriginalFileName != "img.png" {
+		t.Fatalf("expected the avatar to be stored, got %v and %v", avatars, err)
+	}
+	documents, err := testTools.UploadFilesFromField(request, "documents", t.TempDir(), UploadOptions{AllowedFileTypes: []string{"image/jpeg"}})
+	if err != nil || len(documents) != 1 || documents[0].OriginalFileName != "pic.jpg" {
+		t.Fatalf("expected the document to be stored, got %v and %v", documents, err)
+	}
+}
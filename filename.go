@@ -0,0 +1,67 @@
+package toolkit
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// FileNamePolicy sets how the names of uploads are cleaned up and checked, whether or not they are renamed.
+// The zero value keeps names as they are.
+type FileNamePolicy struct {
+	LowercaseExtensions   bool     // if set to true, extensions are stored in lower case, e.g. photo.JPG as photo.jpg
+	StripDoubleExtensions bool     // if set to true, executable extensions are removed from names with several, e.g. shell.php.jpg and shell.jpg.php are stored as shell.jpg
+	AllowedExtensions     []string // if set, uploads must have one of these extensions (e.g. ".jpg"), compared ignoring case; checked alongside their detected type
+}
+
+// dangerousExtensions are the extensions web servers may execute, or browsers run, wherever they appear in a
+// name, so a name like shell.php.jpg can be served as a script.
+var dangerousExtensions = map[string]bool{
+	".php": true, ".php3": true, ".php4": true, ".php5": true, ".php7": true, ".phtml": true, ".phar": true,
+	".asp": true, ".aspx": true, ".jsp": true, ".jspx": true, ".cgi": true, ".pl": true, ".py": true,
+	".sh": true, ".exe": true, ".bat": true, ".cmd": true, ".com": true, ".dll": true, ".js": true,
+	".htm": true, ".html": true, ".shtml": true, ".svg": true,
+}
+
+// cleanFileName returns name with its extensions rewritten as the policy says. The name is only used for
+// its extension when an upload is renamed, so the same rules apply in both modes.
+func (p FileNamePolicy) cleanFileName(name string) string {
+	if p.StripDoubleExtensions {
+		parts := strings.Split(name, ".")
+		if len(parts) > 2 {
+			kept := []string{parts[0]}
+			for _, part := range parts[1:] {
+				if !dangerousExtensions["."+strings.ToLower(part)] {
+					kept = append(kept, part)
+				}
+			}
+			if len(kept) == 1 {
+				// every extension is dangerous: keep the last, for the allowed types and extensions to reject
+				kept = append(kept, parts[len(parts)-1])
+			}
+			name = strings.Join(kept, ".")
+		}
+	}
+	if p.LowercaseExtensions {
+		ext := filepath.Ext(name)
+		name = strings.TrimSuffix(name, ext) + strings.ToLower(ext)
+	}
+	return name
+}
+
+// checkExtension returns an error if AllowedExtensions is set and the extension of name isn't among them.
+func (p FileNamePolicy) checkExtension(name string) error {
+	if len(p.AllowedExtensions) == 0 {
+		return nil
+	}
+	ext := filepath.Ext(name)
+	for _, allowed := range p.AllowedExtensions {
+		if ext != "" && strings.EqualFold(strings.TrimPrefix(allowed, "."), strings.TrimPrefix(ext, ".")) {
+			return nil
+		}
+	}
+	if ext == "" {
+		return errors.New("file extension required")
+	}
+	return errors.New("file extension not allowed: " + ext)
+}
@@ -0,0 +1,103 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileNamePolicy_cleanFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   FileNamePolicy
+		fileName string
+		expected string
+	}{
+		{name: "zero value", fileName: "shell.php.JPG", expected: "shell.php.JPG"},
+		{name: "lowercase", policy: FileNamePolicy{LowercaseExtensions: true}, fileName: "Photo.JPG", expected: "Photo.jpg"},
+		{name: "inner dangerous", policy: FileNamePolicy{StripDoubleExtensions: true}, fileName: "shell.php.jpg", expected: "shell.jpg"},
+		{name: "outer dangerous", policy: FileNamePolicy{StripDoubleExtensions: true}, fileName: "shell.jpg.PHP", expected: "shell.jpg"},
+		{name: "all dangerous", policy: FileNamePolicy{StripDoubleExtensions: true}, fileName: "shell.php.php5", expected: "shell.php5"},
+		{name: "single extension", policy: FileNamePolicy{StripDoubleExtensions: true}, fileName: "script.php", expected: "script.php"},
+		{name: "safe double extension", policy: FileNamePolicy{StripDoubleExtensions: true}, fileName: "backup.tar.gz", expected: "backup.tar.gz"},
+		{name: "both", policy: FileNamePolicy{LowercaseExtensions: true, StripDoubleExtensions: true}, fileName: "Shell.Phtml.PNG", expected: "Shell.png"},
+	}
+
+	for _, e := range tests {
+		if got := e.policy.cleanFileName(e.fileName); got != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestFileNamePolicy_checkExtension(t *testing.T) {
+	policy := FileNamePolicy{AllowedExtensions: []string{".jpg", "png"}}
+	tests := []struct {
+		fileName      string
+		errorExpected bool
+	}{
+		{fileName: "photo.jpg"},
+		{fileName: "photo.JPG"},
+		{fileName: "photo.png"},
+		{fileName: "photo.gif", errorExpected: true},
+		{fileName: "photo", errorExpected: true},
+		{fileName: "photo.png.php", errorExpected: true},
+	}
+
+	for _, e := range tests {
+		if err := policy.checkExtension(e.fileName); (err != nil) != e.errorExpected {
+			t.Errorf("%s: expected an error to be %t, got %v", e.fileName, e.errorExpected, err)
+		}
+	}
+	if err := (FileNamePolicy{}).checkExtension("anything.exe"); err != nil {
+		t.Errorf("expected any extension to be allowed by the zero value, got %v", err)
+	}
+}
+
+func TestTools_UploadFiles_FileNames(t *testing.T) {
+	tests := []struct {
+		name          string
+		fileName      string
+		rename        bool
+		expected      string
+		errorExpected bool
+	}{
+		{name: "kept name", fileName: "img.php.PNG", expected: "img.png"},
+		{name: "renamed", fileName: "img.PNG.phtml", rename: true, expected: ".png"},
+		{name: "extension not allowed", fileName: "img.gif", errorExpected: true},
+		{name: "extension not allowed, renamed", fileName: "img.gif", rename: true, errorExpected: true},
+	}
+
+	for _, e := range tests {
+		dir := t.TempDir()
+		testTools := Tools{FileNames: FileNamePolicy{LowercaseExtensions: true, StripDoubleExtensions: true, AllowedExtensions: []string{".png"}}}
+		body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", FileName: e.fileName, Path: "./testdata/img.png"}}, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		files, err := testTools.UploadFiles(request, dir, e.rename)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected an error, got none", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		stored := files[0].NewFileName
+		if e.rename && filepath.Ext(stored) != e.expected || !e.rename && stored != e.expected {
+			t.Errorf("%s: expected %s to be stored as %s, got %s", e.name, e.fileName, e.expected, stored)
+		}
+		if files[0].OriginalFileName != e.fileName {
+			t.Errorf("%s: expected the original name %s, got %s", e.name, e.fileName, files[0].OriginalFileName)
+		}
+		if _, err := os.Stat(filepath.Join(dir, stored)); err != nil {
+			t.Errorf("%s: expected the file to be stored: %v", e.name, err)
+		}
+	}
+}
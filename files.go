@@ -0,0 +1,205 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnsureWithinBase joins name onto base and returns the result, or an error if the result would lie
+// outside base (for example, because name contains "../" or is an absolute path elsewhere). It works on
+// the paths alone, so the file doesn't have to exist, and symbolic links are not resolved.
+func (t *Tools) EnsureWithinBase(base, name string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+
+	target := name
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(absBase, target)
+	}
+	target = filepath.Clean(target)
+
+	rel, err := filepath.Rel(absBase, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside of %q", name, base)
+	}
+
+	return target, nil
+}
+
+// CopyDir recursively copies the directory src to dst, creating dst if necessary and preserving file
+// modes. It refuses to copy a directory into itself.
+func (t *Tools) CopyDir(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(absSrc, absDst); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.New("cannot copy a directory into itself")
+	}
+
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info.Mode().Perm())
+		default:
+			// symbolic links, devices and the like are skipped rather than followed
+			return nil
+		}
+	})
+}
+
+// MoveFile moves the file src to dst. It tries a rename first, and if that fails (for example, because src
+// and dst are on different devices), copies the file and removes the original.
+func (t *Tools) MoveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", src)
+	}
+
+	if err := copyFile(src, dst, info.Mode().Perm()); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// RemoveContents removes everything inside dir, leaving dir itself in place. As a safety check, it
+// refuses to work on the root of a file system.
+func (t *Tools) RemoveContents(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if abs == filepath.Dir(abs) {
+		return fmt.Errorf("refusing to remove the contents of %s", abs)
+	}
+
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(abs, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the regular file src to dst, and syncs dst to disk.
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// writeFileAtomic writes r to path by way of a temporary file in the same directory, which is synced to disk
+// and then renamed, so path never holds a partly written file. If syncDir is true the directory is synced
+// too, so the rename itself survives a crash. It returns the number of bytes written.
+func writeFileAtomic(path string, r io.Reader, syncDir bool) (int64, error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	tmpName := tmp.Name()
+
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return 0, err
+	}
+
+	if syncDir {
+		if err := syncDirectory(dir); err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// syncDirectory syncs the directory dir to disk, making renames and new files in it durable.
+func syncDirectory(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
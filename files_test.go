@@ -0,0 +1,197 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var ensureWithinBaseTests = []struct {
+	name          string
+	base          string
+	path          string
+	errorExpected bool
+}{
+	{name: "simple", base: "./testdata", path: "pic.jpg", errorExpected: false},
+	{name: "subdirectory", base: "./testdata", path: "uploads/pic.jpg", errorExpected: false},
+	{name: "clean within base", base: "./testdata", path: "uploads/../pic.jpg", errorExpected: false},
+	{name: "parent", base: "./testdata", path: "../tools.go", errorExpected: true},
+	{name: "sneaky parent", base: "./testdata", path: "uploads/../../tools.go", errorExpected: true},
+	{name: "absolute outside", base: "./testdata", path: "/etc/passwd", errorExpected: true},
+	{name: "prefix sibling", base: "./testdata", path: "../testdata2/pic.jpg", errorExpected: true},
+}
+
+func TestTools_EnsureWithinBase(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range ensureWithinBaseTests {
+		_, err := testTools.EnsureWithinBase(e.base, e.path)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected but one received: %s", e.name, err)
+		}
+	}
+}
+
+func TestTools_CopyDir(t *testing.T) {
+	var testTools Tools
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+
+	_ = os.MkdirAll(filepath.Join(src, "nested"), 0755)
+	_ = os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644)
+	_ = os.WriteFile(filepath.Join(src, "nested", "b.txt"), []byte("bb"), 0600)
+
+	dst := filepath.Join(dir, "dst")
+	if err := testTools.CopyDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dst, "nested", "b.txt"))
+	if err != nil || string(b) != "bb" {
+		t.Errorf("nested file not copied correctly: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dst, "nested", "b.txt"))
+	if err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("file mode not preserved; expected 0600 but got %o", info.Mode().Perm())
+	}
+
+	if err := testTools.CopyDir(src, filepath.Join(src, "nested", "copy")); err == nil {
+		t.Error("expected an error copying a directory into itself, but none received")
+	}
+	if err := testTools.CopyDir(filepath.Join(src, "a.txt"), dst); err == nil {
+		t.Error("expected an error copying a file with CopyDir, but none received")
+	}
+}
+
+func TestTools_CopyDir_Relative(t *testing.T) {
+	var testTools Tools
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "src", "a.txt"), []byte("a"), 0644)
+
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	if err := testTools.CopyDir("src", "dst"); err != nil {
+		t.Fatalf("expected a relative destination beside src to be allowed, got %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "dst", "a.txt")); err != nil || string(b) != "a" {
+		t.Errorf("file not copied correctly: %v", err)
+	}
+	if err := testTools.CopyDir("src", "./src/copy"); err == nil {
+		t.Error("expected an error copying a directory into itself, but none received")
+	}
+	if err := testTools.CopyDir("src", "src"); err == nil {
+		t.Error("expected an error copying a directory onto itself, but none received")
+	}
+}
+
+func TestTools_MoveFile(t *testing.T) {
+	var testTools Tools
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "a.txt")
+	dst := filepath.Join(dir, "b.txt")
+	_ = os.WriteFile(src, []byte("a"), 0644)
+
+	if err := testTools.MoveFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("source file still exists after move")
+	}
+	if b, err := os.ReadFile(dst); err != nil || string(b) != "a" {
+		t.Errorf("file not moved correctly: %v", err)
+	}
+
+	if err := testTools.MoveFile(filepath.Join(dir, "missing.txt"), dst); err == nil {
+		t.Error("expected an error moving a missing file, but none received")
+	}
+}
+
+func TestTools_RemoveContents(t *testing.T) {
+	var testTools Tools
+	dir := t.TempDir()
+
+	_ = os.MkdirAll(filepath.Join(dir, "nested"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "nested", "b.txt"), []byte("b"), 0644)
+
+	if err := testTools.RemoveContents(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("directory itself was removed: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected an empty directory, but found %d entries", len(entries))
+	}
+}
+
+func TestTools_DownloadStaticFile_Traversal(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+
+	testTools.DownloadStaticFile(rr, req, "./testdata", "../tools.go", "tools.go")
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("wrong status; expected %d but got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	for _, syncDir := range []bool{false, true} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "file.txt")
+
+		n, err := writeFileAtomic(path, strings.NewReader("hello"), syncDir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n != 5 {
+			t.Errorf("expected 5 bytes written but got %d", n)
+		}
+		if b, _ := os.ReadFile(path); string(b) != "hello" {
+			t.Errorf("wrong contents %q", b)
+		}
+
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Errorf("expected only the file to be left in the directory, got %d entries", len(entries))
+		}
+	}
+}
+
+func TestWriteFileAtomic_Failure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := writeFileAtomic(path, iotest.ErrReader(errors.New("connection reset")), false)
+	if err == nil || err.Error() != "connection reset" {
+		t.Errorf("expected the read error, got %v", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "original" {
+		t.Errorf("existing file was changed to %q", b)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary file was not removed, got %d entries", len(entries))
+	}
+}
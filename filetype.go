@@ -0,0 +1,126 @@
+package toolkit
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// fileSniffLen is the number of leading bytes of a file used to detect its type.
+const fileSniffLen = 512
+
+// FileSignature recognises a file type by the magic number it starts with.
+type FileSignature struct {
+	MIMEType string // type of files matching the signature, e.g. image/heic
+	Offset   int    // position of Magic from the start of the file
+	Magic    []byte // bytes files of this type have at Offset; if empty, the signature only sets MaxSize
+	MaxSize  int64  // maximum size of files of this type in bytes; 0 means only the usual limits apply
+}
+
+// matches reports whether head, the start of a file, has the signature.
+func (s FileSignature) matches(head []byte) bool {
+	return len(s.Magic) > 0 && len(head) >= s.Offset+len(s.Magic) && bytes.Equal(head[s.Offset:s.Offset+len(s.Magic)], s.Magic)
+}
+
+// defaultFileSignatures holds signatures for formats http.DetectContentType doesn't know, or mistakes for
+// others (HEIC, for example, is reported as video/mp4 or application/octet-stream).
+var defaultFileSignatures = []FileSignature{
+	{MIMEType: "image/heic", Offset: 4, Magic: []byte("ftypheic")},
+	{MIMEType: "image/heic", Offset: 4, Magic: []byte("ftypheix")},
+	{MIMEType: "image/heic-sequence", Offset: 4, Magic: []byte("ftyphevc")},
+	{MIMEType: "image/heic-sequence", Offset: 4, Magic: []byte("ftyphevx")},
+	{MIMEType: "image/heif", Offset: 4, Magic: []byte("ftypmif1")},
+	{MIMEType: "image/heif-sequence", Offset: 4, Magic: []byte("ftypmsf1")},
+	{MIMEType: "image/avif", Offset: 4, Magic: []byte("ftypavif")},
+	{MIMEType: "image/tiff", Magic: []byte("II*\x00")},
+	{MIMEType: "image/tiff", Magic: []byte("MM\x00*")},
+	{MIMEType: "application/vnd.apache.parquet", Magic: []byte("PAR1")},
+	{MIMEType: "application/x-7z-compressed", Magic: []byte("7z\xbc\xaf\x27\x1c")},
+	{MIMEType: "application/vnd.sqlite3", Magic: []byte("SQLite format 3\x00")},
+}
+
+// DetectFileType returns the MIME type of a file from its first bytes (up to 512 are used). FileSignatures are
+// checked first, in order, then the built-in signatures for formats such as HEIC, AVIF, TIFF and Parquet,
+// and finally http.DetectContentType. This is the check used against AllowedFileTypes.
+func (t *Tools) DetectFileType(head []byte) string {
+	if len(head) > fileSniffLen {
+		head = head[:fileSniffLen]
+	}
+	for _, s := range t.FileSignatures {
+		if s.matches(head) {
+			return s.MIMEType
+		}
+	}
+	for _, s := range defaultFileSignatures {
+		if s.matches(head) {
+			return s.MIMEType
+		}
+	}
+	return http.DetectContentType(head)
+}
+
+// TypeRule allows uploaded or downloaded files of the types matching Pattern, optionally limiting their size.
+type TypeRule struct {
+	Pattern string // a MIME type (image/png), a wildcard for a whole group (image/*), or */* for any type
+	MaxSize int64  // maximum size of files matching the rule in bytes; 0 means only the usual limits apply
+}
+
+// matchesFileType reports whether a pattern from TypeRule or AllowedFileTypes matches a detected fileType.
+// Wildcards ignore any parameters of the type, such as charset.
+func matchesFileType(pattern, fileType string) bool {
+	if strings.EqualFold(pattern, fileType) {
+		return true
+	}
+	base, _, _ := strings.Cut(fileType, ";")
+	base = strings.TrimSpace(base)
+	switch {
+	case pattern == "*" || pattern == "*/*":
+		return true
+	case strings.HasSuffix(pattern, "/*"):
+		major, _, _ := strings.Cut(base, "/")
+		return strings.EqualFold(strings.TrimSuffix(pattern, "/*"), major)
+	}
+	return false
+}
+
+// checkFileType returns an error if fileType isn't allowed by AllowedFileTypes or AllowedTypes, and otherwise
+// the size limit for the type, or 0 if there is none besides the usual limits. Any type is allowed if neither
+// is set. The limit is the smaller of those set by the first matching TypeRule and by FileSignatures.
+func (t *Tools) checkFileType(fileType string) (int64, error) {
+	allowed := len(t.AllowedFileTypes) == 0 && len(t.AllowedTypes) == 0
+	for _, x := range t.AllowedFileTypes {
+		if matchesFileType(x, fileType) {
+			allowed = true
+		}
+	}
+
+	var maxSize int64
+	for _, rule := range t.AllowedTypes {
+		if matchesFileType(rule.Pattern, fileType) {
+			allowed = true
+			maxSize = rule.MaxSize
+			break
+		}
+	}
+	if !allowed {
+		return 0, errors.New("file type not allowed: " + fileType)
+	}
+
+	if sigMax := t.fileTypeMaxSize(fileType); sigMax > 0 && (maxSize <= 0 || sigMax < maxSize) {
+		maxSize = sigMax
+	}
+	return maxSize, nil
+}
+
+// fileTypeMaxSize returns the size limit FileSignatures sets for fileType, or 0 if there is none. The limit
+// applies however the type was detected, so a signature for a type http.DetectContentType knows can be
+// added just to limit its size.
+func (t *Tools) fileTypeMaxSize(fileType string) int64 {
+	for _, s := range t.FileSignatures {
+		if s.MaxSize > 0 && s.MIMEType == fileType {
+			return s.MaxSize
+		}
+	}
+	return 0
+}
@@ -0,0 +1,152 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+var detectFileTypeTests = []struct {
+	name       string
+	head       []byte
+	signatures []FileSignature
+	expected   string
+}{
+	{name: "heic", head: []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), expected: "image/heic"},
+	{name: "heif", head: []byte("\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00mif1heic"), expected: "image/heif"},
+	{name: "avif", head: []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1"), expected: "image/avif"},
+	{name: "parquet", head: []byte("PAR1\x15\x04\x15\x10"), expected: "application/vnd.apache.parquet"},
+	{name: "tiff", head: []byte("II*\x00\x08\x00\x00\x00"), expected: "image/tiff"},
+	{name: "sqlite", head: []byte("SQLite format 3\x00\x10\x00"), expected: "application/vnd.sqlite3"},
+	{name: "png falls back to DetectContentType", head: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), expected: "image/png"},
+	{name: "text", head: []byte("hello, world"), expected: "text/plain; charset=utf-8"},
+	{name: "custom signature", head: []byte("GLTF\x02\x00"), signatures: []FileSignature{{MIMEType: "model/gltf-binary", Magic: []byte("glTF")}, {MIMEType: "model/x-test", Magic: []byte("GLTF")}}, expected: "model/x-test"},
+	{name: "custom signature at offset", head: []byte("xxxxMAGIC"), signatures: []FileSignature{{MIMEType: "application/x-test", Offset: 4, Magic: []byte("MAGIC")}}, expected: "application/x-test"},
+	{name: "custom signature wins", head: []byte("PAR1\x15\x04"), signatures: []FileSignature{{MIMEType: "application/x-parquet", Magic: []byte("PAR1")}}, expected: "application/x-parquet"},
+	{name: "size-only signature is not matched", head: []byte("PAR1\x15\x04"), signatures: []FileSignature{{MIMEType: "application/x-test", MaxSize: 10}}, expected: "application/vnd.apache.parquet"},
+	{name: "head shorter than signature", head: []byte("\x00\x00\x00\x18ftyp"), expected: "application/octet-stream"},
+}
+
+func TestTools_DetectFileType(t *testing.T) {
+	for _, e := range detectFileTypeTests {
+		testTools := Tools{FileSignatures: e.signatures}
+		if got := testTools.DetectFileType(e.head); got != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, got)
+		}
+	}
+}
+
+var uploadFileTypeLimitTests = []struct {
+	name          string
+	signatures    []FileSignature
+	errorExpected string
+}{
+	{name: "no limit"},
+	{name: "under the limit", signatures: []FileSignature{{MIMEType: "image/png", MaxSize: 1024 * 1024}}},
+	{name: "over the limit", signatures: []FileSignature{{MIMEType: "image/png", MaxSize: 1024}}, errorExpected: "image/png files must not be larger than 1 KB"},
+	{name: "limit for another type", signatures: []FileSignature{{MIMEType: "image/jpeg", MaxSize: 1024}}},
+}
+
+func TestTools_UploadFiles_FileTypeLimit(t *testing.T) {
+	for _, e := range uploadFileTypeLimitTests {
+		testTools := Tools{AllowedFileTypes: []string{"image/png"}, FileSignatures: e.signatures}
+
+		body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		files, err := testTools.UploadFiles(request, "./testdata/uploads/")
+		for _, f := range files {
+			_ = os.Remove("./testdata/uploads/" + f.NewFileName)
+		}
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
+
+func TestTools_DownloadRemoteFile_FileTypeLimit(t *testing.T) {
+	png, err := os.ReadFile("./testdata/img.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(png)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	testTools := Tools{FileSignatures: []FileSignature{{MIMEType: "image/png", MaxSize: 1024}}}
+	_, err = testTools.DownloadRemoteFile(server.URL+"/img.png", dir, RemoteDownloadOptions{FileName: "img.png"})
+	if err == nil || err.Error() != "remote file must not be larger than 1 KB" {
+		t.Errorf("expected the type's size limit to apply, got %v", err)
+	}
+	if _, err := os.Stat(dir + "/img.png.part"); !os.IsNotExist(err) {
+		t.Error("partial file was not removed")
+	}
+}
+
+var checkFileTypeTests = []struct {
+	name          string
+	allowedFiles  []string
+	allowedTypes  []TypeRule
+	signatures    []FileSignature
+	fileType      string
+	expectedMax   int64
+	errorExpected bool
+}{
+	{name: "nothing set", fileType: "video/mp4"},
+	{name: "exact", allowedFiles: []string{"image/png"}, fileType: "image/png"},
+	{name: "exact is case insensitive", allowedFiles: []string{"IMAGE/PNG"}, fileType: "image/png"},
+	{name: "not allowed", allowedFiles: []string{"image/png"}, fileType: "image/gif", errorExpected: true},
+	{name: "wildcard in AllowedFileTypes", allowedFiles: []string{"image/*"}, fileType: "image/heic"},
+	{name: "wildcard ignores parameters", allowedFiles: []string{"text/*"}, fileType: "text/plain; charset=utf-8"},
+	{name: "wildcard for another group", allowedFiles: []string{"image/*"}, fileType: "video/mp4", errorExpected: true},
+	{name: "any type", allowedTypes: []TypeRule{{Pattern: "*/*", MaxSize: 10}}, fileType: "application/pdf", expectedMax: 10},
+	{name: "rule limit", allowedTypes: []TypeRule{{Pattern: "image/*", MaxSize: 5}, {Pattern: "video/*", MaxSize: 500}}, fileType: "video/mp4", expectedMax: 500},
+	{name: "first rule wins", allowedTypes: []TypeRule{{Pattern: "image/png", MaxSize: 1}, {Pattern: "image/*", MaxSize: 5}}, fileType: "image/png", expectedMax: 1},
+	{name: "rule not matched", allowedTypes: []TypeRule{{Pattern: "image/*", MaxSize: 5}}, fileType: "application/pdf", errorExpected: true},
+	{name: "either list allows", allowedFiles: []string{"application/pdf"}, allowedTypes: []TypeRule{{Pattern: "image/*", MaxSize: 5}}, fileType: "application/pdf"},
+	{name: "smaller signature limit", allowedTypes: []TypeRule{{Pattern: "image/*", MaxSize: 5}}, signatures: []FileSignature{{MIMEType: "image/png", MaxSize: 2}}, fileType: "image/png", expectedMax: 2},
+	{name: "smaller rule limit", allowedTypes: []TypeRule{{Pattern: "image/*", MaxSize: 5}}, signatures: []FileSignature{{MIMEType: "image/png", MaxSize: 20}}, fileType: "image/png", expectedMax: 5},
+}
+
+func TestTools_checkFileType(t *testing.T) {
+	for _, e := range checkFileTypeTests {
+		testTools := Tools{AllowedFileTypes: e.allowedFiles, AllowedTypes: e.allowedTypes, FileSignatures: e.signatures}
+		maxSize, err := testTools.checkFileType(e.fileType)
+		if e.errorExpected {
+			if err == nil || err.Error() != "file type not allowed: "+e.fileType {
+				t.Errorf("%s: expected the type to be rejected, got %v", e.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		}
+		if maxSize != e.expectedMax {
+			t.Errorf("%s: expected limit %d but got %d", e.name, e.expectedMax, maxSize)
+		}
+	}
+}
+
+func TestTools_UploadFiles_TypeRules(t *testing.T) {
+	testTools := Tools{AllowedTypes: []TypeRule{{Pattern: "image/*", MaxSize: 1024}, {Pattern: "video/*"}}}
+
+	body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Add("Content-Type", contentType)
+
+	files, err := testTools.UploadFiles(request, "./testdata/uploads/")
+	for _, f := range files {
+		_ = os.Remove("./testdata/uploads/" + f.NewFileName)
+	}
+	if err == nil || err.Error() != "image/png files must not be larger than 1 KB" {
+		t.Errorf("expected the rule's size limit to apply, got %v", err)
+	}
+}
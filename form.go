@@ -0,0 +1,256 @@
+package toolkit
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formTimeLayouts the layouts tried, in order, when decoding a time.Time value; the last two are what
+// date and datetime-local inputs send.
+var formTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// ReadForm reads an application/x-www-form-urlencoded or multipart/form-data request body into data,
+// which must be a pointer to a struct. Fields are matched by their `form:"name"` tag, or their name if
+// they don't have one, and a tag of "-" skips the field. Values are converted to the field's type:
+// strings, booleans (including "on" from checkboxes), integers, floats, durations, time.Time (RFC 3339, or
+// the formats sent by date and datetime-local inputs), anything implementing encoding.TextUnmarshaler, and
+// pointers and slices of those. A slice receives every value sent for its name. The body is limited to
+// Limits.MaxBody or MaxFormSize, and decompressed like ReadJSON's if it is sent with gzip or deflate. Unknown
+// fields are rejected unless AllowUnknownFields is set, with errors worded like those from ReadJSON.
+func (t *Tools) ReadForm(w http.ResponseWriter, r *http.Request, data any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data" {
+		return newMessageError(MsgContentTypeForm, "")
+	}
+
+	if err := t.checkHeaders(r); err != nil {
+		return err
+	}
+
+	// Limit the size of the body, decompressing it if necessary, before the form is parsed from it.
+	maxBytes := t.bodyLimit(t.MaxFormSize, defaultMaxUpload)
+	body, err := t.requestBody(w, r, maxBytes)
+	if err != nil {
+		return err
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, r.Body}
+
+	var values url.Values
+	if mediaType == "multipart/form-data" {
+		memoryLimit := maxBytes
+		if t.MultipartMemoryLimit > 0 {
+			memoryLimit = t.MultipartMemoryLimit
+		}
+		// Stop parsing as soon as the form has too many parts.
+		stopLimit := t.limitMultipartBody(r, t.multipartPartsLimit(), 0, 0)
+		err := r.ParseMultipartForm(int64(memoryLimit))
+		stopLimit()
+		if err != nil {
+			return formParseError(err)
+		}
+		if err := t.checkMultipartParts(r.MultipartForm); err != nil {
+			return err
+		}
+		values = r.MultipartForm.Value
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return formParseError(err)
+		}
+		values = r.PostForm
+	}
+
+	return decodeValues("body", values, data, "form", t.AllowUnknownFields)
+}
+
+// formParseError describes an error from parsing a form body.
+func formParseError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return newMessageError(MsgTooLarge, "body", maxBytesError.Limit)
+	}
+	var partsErr *partsLimitError
+	if errors.As(err, &partsErr) {
+		return partsErr
+	}
+	return newMessageError(MsgBadForm, "body", err.Error())
+}
+
+// decodeValues sets the fields of the struct data points to from values, matching names using tag.
+// Errors describe the source of the values as subject (e.g. "body").
+func decodeValues(subject string, values url.Values, data any, tag string, allowUnknown bool) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("error decoding %s: data must be a non-nil pointer to a struct, not %T", subject, data)
+	}
+
+	fields := make(map[string]reflect.Value)
+	collectFields(v.Elem(), tag, fields)
+
+	for name, vals := range values {
+		field, ok := fields[name]
+		if !ok {
+			if allowUnknown {
+				continue
+			}
+			return newMessageError(MsgUnknownKey, subject, strconv.Quote(name))
+		}
+		if err := setFieldValues(field, vals); err != nil {
+			return newMessageError(MsgInvalidField, subject, name)
+		}
+	}
+
+	return nil
+}
+
+// collectFields adds the settable fields of struct v to fields, keyed by their tag name. Fields of
+// embedded structs are included as if they belonged to v.
+func collectFields(v reflect.Value, tag string, fields map[string]reflect.Value) {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		name := sf.Tag.Get(tag)
+		if name == "-" {
+			continue
+		}
+		name, _, _ = strings.Cut(name, ",")
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			collectFields(v.Field(i), tag, fields)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields[name] = v.Field(i)
+	}
+}
+
+// setFieldValues sets field from the values sent for it. Slices get every value; other types get the
+// first.
+func setFieldValues(field reflect.Value, vals []string) error {
+	if len(vals) == 0 {
+		return nil
+	}
+
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if err := setFieldValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setFieldValue(field, vals[0])
+}
+
+// setFieldValue converts s to the type of field, and sets it.
+func setFieldValue(field reflect.Value, s string) error {
+	if field.Kind() == reflect.Pointer {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setFieldValue(ptr.Elem(), s); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) && field.Type() != timeType {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch {
+	case field.Type() == timeType:
+		tm, err := parseFormTime(s)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(tm))
+		return nil
+
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+
+	case reflect.Bool:
+		if s == "on" {
+			field.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
+
+// parseFormTime parses s using the first of formTimeLayouts which fits.
+func parseFormTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range formTimeLayouts {
+		var tm time.Time
+		if tm, err = time.Parse(layout, s); err == nil {
+			return tm, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,163 @@
+package toolkit
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type formAddress struct {
+	City string `form:"city"`
+}
+
+type signupForm struct {
+	formAddress
+	Name      string        `form:"name"`
+	Age       int           `form:"age"`
+	Score     float64       `form:"score"`
+	Subscribe bool          `form:"subscribe"`
+	Born      time.Time     `form:"born"`
+	Remind    time.Duration `form:"remind"`
+	Tags      []string      `form:"tags"`
+	Lucky     []uint8Like   `form:"lucky"`
+	Nickname  *string       `form:"nickname"`
+	IP        netip.Addr    `form:"ip"`
+	Ignored   string        `form:"-"`
+	Untagged  string
+}
+
+type uint8Like uint16
+
+var readFormTests = []struct {
+	name          string
+	body          string
+	contentType   string
+	allowUnknown  bool
+	maxSize       int
+	errorExpected string
+}{
+	{name: "valid", body: "name=Ann&age=42&score=9.5&subscribe=on&born=1990-05-17&remind=1h&tags=a&tags=b&lucky=7&lucky=13&nickname=annie&ip=10.0.0.1&city=Kyiv&Untagged=x", contentType: "application/x-www-form-urlencoded"},
+	{name: "charset parameter", body: "name=Ann", contentType: "application/x-www-form-urlencoded; charset=utf-8"},
+	{name: "wrong content type", body: "name=Ann", contentType: "application/json", errorExpected: "Content-Type must be application/x-www-form-urlencoded or multipart/form-data"},
+	{name: "bad int", body: "age=old", contentType: "application/x-www-form-urlencoded", errorExpected: `body contains an invalid value for field "age"`},
+	{name: "bad time", body: "born=yesterday", contentType: "application/x-www-form-urlencoded", errorExpected: `body contains an invalid value for field "born"`},
+	{name: "bad slice element", body: "lucky=1&lucky=x", contentType: "application/x-www-form-urlencoded", errorExpected: `body contains an invalid value for field "lucky"`},
+	{name: "unknown field", body: "email=a@b.c", contentType: "application/x-www-form-urlencoded", errorExpected: `body contains unknown key "email"`},
+	{name: "skipped field", body: "Ignored=x", contentType: "application/x-www-form-urlencoded", errorExpected: `body contains unknown key "Ignored"`},
+	{name: "allow unknown", body: "email=a@b.c", contentType: "application/x-www-form-urlencoded", allowUnknown: true},
+	{name: "too large", body: "name=" + strings.Repeat("a", 100), contentType: "application/x-www-form-urlencoded", maxSize: 50, errorExpected: "body must not be larger than 50 bytes"},
+}
+
+func TestTools_ReadForm(t *testing.T) {
+	for _, e := range readFormTests {
+		testTools := Tools{AllowUnknownFields: e.allowUnknown, MaxFormSize: e.maxSize}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", e.contentType)
+
+		var dst signupForm
+		err := testTools.ReadForm(httptest.NewRecorder(), req, &dst)
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
+
+func TestTools_ReadForm_ContentEncoding(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          []byte
+		encoding      string
+		maxSize       int
+		errorExpected string
+	}{
+		{name: "gzip", body: gzipBytes("name=Ann&age=42"), encoding: "gzip"},
+		{name: "invalid gzip", body: []byte("name=Ann"), encoding: "gzip", errorExpected: "body is not valid gzip"},
+		{name: "unsupported", body: []byte("name=Ann"), encoding: "br", errorExpected: `unsupported Content-Encoding "br"`},
+		{name: "zip bomb", body: gzipBytes("name=" + strings.Repeat("a", 1000)), encoding: "gzip", maxSize: 100, errorExpected: "body must not be larger than 100 bytes"},
+	}
+
+	for _, e := range tests {
+		testTools := Tools{MaxFormSize: e.maxSize}
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Content-Encoding", e.encoding)
+
+		var dst signupForm
+		err := testTools.ReadForm(httptest.NewRecorder(), req, &dst)
+
+		if e.errorExpected == "" && (err != nil || dst.Name != "Ann" || dst.Age != 42) {
+			t.Errorf("%s: expected the decompressed form, got %+v, %v", e.name, dst, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
+
+func TestTools_ReadFormValues(t *testing.T) {
+	var testTools Tools
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(readFormTests[0].body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var got signupForm
+	if err := testTools.ReadForm(httptest.NewRecorder(), req, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	nickname := "annie"
+	expected := signupForm{
+		formAddress: formAddress{City: "Kyiv"},
+		Name:        "Ann",
+		Age:         42,
+		Score:       9.5,
+		Subscribe:   true,
+		Born:        time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		Remind:      time.Hour,
+		Tags:        []string{"a", "b"},
+		Lucky:       []uint8Like{7, 13},
+		Nickname:    &nickname,
+		IP:          netip.MustParseAddr("10.0.0.1"),
+		Untagged:    "x",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong result;\nexpected %+v\n but got %+v", expected, got)
+	}
+}
+
+func TestTools_ReadFormMultipart(t *testing.T) {
+	var testTools Tools
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	_ = mw.WriteField("name", "Ann")
+	_ = mw.WriteField("born", "2024-02-29T13:45")
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var got signupForm
+	if err := testTools.ReadForm(httptest.NewRecorder(), req, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Ann" || !got.Born.Equal(time.Date(2024, 2, 29, 13, 45, 0, 0, time.UTC)) {
+		t.Errorf("wrong result %+v", got)
+	}
+
+	if err := testTools.ReadForm(httptest.NewRecorder(), req, got); err == nil {
+		t.Error("expected error decoding into a non-pointer")
+	}
+}
@@ -0,0 +1,100 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+// FuzzTools_ReadJSON makes sure that ReadJSON never panics, whatever the body contains.
+func FuzzTools_ReadJSON(f *testing.F) {
+	for _, test := range jsonTests {
+		f.Add([]byte(test.json))
+	}
+
+	testTools := New()
+	testTools.MaxJSONSize = 4096
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		_ = testTools.ReadJSON(rr, req, &decodedJSON)
+	})
+}
+
+// FuzzTools_ReadXML makes sure that ReadXML never panics, whatever the body contains.
+func FuzzTools_ReadXML(f *testing.F) {
+	for _, test := range xmlTests {
+		f.Add([]byte(test.xml))
+	}
+
+	testTools := New()
+	testTools.MaxXMLSize = 4096
+	testTools.MaxXMLAttributes = 8
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var note struct {
+			To   string `xml:"to"`
+			From string `xml:"from"`
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		_ = testTools.ReadXML(rr, req, &note)
+	})
+}
+
+// FuzzTools_Slugify makes sure that Slugify always returns a well-formed slug or an error.
+func FuzzTools_Slugify(f *testing.F) {
+	for _, test := range slugTests {
+		f.Add(test.s)
+	}
+
+	testTools := New()
+	valid := regexp.MustCompile(`^[a-z\d]+(-[a-z\d]+)*$`)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		slug, err := testTools.Slugify(s)
+		if err != nil {
+			return
+		}
+		if !valid.MatchString(slug) {
+			t.Errorf("malformed slug %q returned for %q", slug, s)
+		}
+	})
+}
+
+// FuzzReadEXIF makes sure that parsing EXIF data never panics, whatever the uploaded image contains.
+func FuzzReadEXIF(f *testing.F) {
+	f.Add(testEXIF(binary.BigEndian))
+	f.Add(testEXIF(binary.LittleEndian))
+
+	f.Fuzz(func(t *testing.T, tiff []byte) {
+		var meta ImageMetadata
+		readEXIF(tiff, &meta, ImageMetadataOptions{GPS: true})
+		_ = jpegEXIF(tiff)
+	})
+}
+
+// FuzzParsePDF makes sure that reading a PDF never panics or hangs, whatever the uploaded file contains.
+func FuzzParsePDF(f *testing.F) {
+	for _, doc := range testPDFs {
+		f.Add(doc)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := ParsePDF(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		_ = doc.PageCount()
+		_, _ = doc.Text()
+	})
+}
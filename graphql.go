@@ -0,0 +1,158 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// GraphQLRequest is a GraphQL request, as read by ReadGraphQLRequest.
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation is a position in a GraphQL document, counted from 1.
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is an error in the format of the GraphQL specification. WriteGraphQLError sends it as it is,
+// wherever it is in the chain of the error it is given.
+type GraphQLError struct {
+	Message    string            `json:"message"`
+	Locations  []GraphQLLocation `json:"locations,omitempty"`
+	Path       []any             `json:"path,omitempty"` // the field names and list indexes of the response field the error is about
+	Extensions map[string]any    `json:"extensions,omitempty"`
+}
+
+// Error returns the message of the error.
+func (e *GraphQLError) Error() string {
+	return e.Message
+}
+
+// GraphQLResponse is the body of a GraphQL response.
+type GraphQLResponse struct {
+	Data       any             `json:"data,omitempty"`
+	Errors     []*GraphQLError `json:"errors,omitempty"`
+	Extensions map[string]any  `json:"extensions,omitempty"`
+}
+
+// ReadGraphQLRequest reads a GraphQL request from the query, operationName, variables and extensions
+// parameters of a GET request, or from the body of a POST, sent as application/json or as a bare
+// application/graphql query. The body, or the query string of a GET, is limited like that of ReadJSON, as
+// is the nesting depth of variables; unknown fields in the body are ignored. Leaving only queries, and not
+// mutations, to GET requests is up to the executor, which parses the query.
+func (t *Tools) ReadGraphQLRequest(w http.ResponseWriter, r *http.Request) (*GraphQLRequest, error) {
+	if err := t.checkHeaders(r); err != nil {
+		return nil, err
+	}
+	maxBytes := t.bodyLimit(t.MaxJSONSize, defaultMaxUpload)
+
+	var req GraphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		if len(r.URL.RawQuery) > maxBytes {
+			return nil, newMessageError(MsgTooLarge, "query string", maxBytes)
+		}
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if err := t.decodeGraphQLParam(q.Get("variables"), "variables", &req.Variables); err != nil {
+			return nil, err
+		}
+		if err := t.decodeGraphQLParam(q.Get("extensions"), "extensions", &req.Extensions); err != nil {
+			return nil, err
+		}
+
+	case http.MethodPost:
+		mediaType := "application/json"
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			mediaType, _, _ = mime.ParseMediaType(ct)
+		}
+		body, err := t.requestBody(w, r, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		switch mediaType {
+		case "application/json":
+			dec := json.NewDecoder(t.limitJSONDepth(body, "body"))
+			if err := dec.Decode(&req); err != nil {
+				return nil, jsonDecodeError("body", err)
+			}
+			if err := dec.Decode(&struct{}{}); err != io.EOF {
+				return nil, newMessageError(MsgJSONOneValue, "body")
+			}
+		case "application/graphql":
+			query, err := io.ReadAll(body)
+			if err != nil {
+				return nil, jsonDecodeError("body", err)
+			}
+			req.Query = string(query)
+		default:
+			return nil, &HTTPError{Status: http.StatusUnsupportedMediaType, PublicMessage: "Content-Type must be application/json or application/graphql"}
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		return nil, &HTTPError{Status: http.StatusMethodNotAllowed, PublicMessage: "GraphQL requests must use GET or POST"}
+	}
+
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, newMessageError(MsgEmpty, "query")
+	}
+	return &req, nil
+}
+
+// decodeGraphQLParam decodes the JSON object in the query parameter name, if it is set, into v.
+func (t *Tools) decodeGraphQLParam(value, name string, v *map[string]any) error {
+	if value == "" {
+		return nil
+	}
+	if err := json.NewDecoder(t.limitJSONDepth(strings.NewReader(value), name)).Decode(v); err != nil {
+		return jsonDecodeError(name, err)
+	}
+	return nil
+}
+
+// WriteGraphQLError sends err in the error format of the GraphQL specification, as the errors of a
+// response without data. A *GraphQLError in its chain is sent as it is, errors joined with errors.Join are
+// sent as an entry each, and anything else is sent with its message, or an *HTTPError's public message.
+// The status is the one given, or else that of an *HTTPError, or 400 Bad Request. Like ErrorJSON, the
+// response is passed to the ErrorHandler first.
+func (t *Tools) WriteGraphQLError(w http.ResponseWriter, err error, status ...int) error {
+	statusCode := 0
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+
+	return t.sendError(w, &ErrorResponse{Err: err, Status: statusCode, Payload: GraphQLResponse{Errors: graphQLErrors(err)}})
+}
+
+// graphQLErrors returns err as a list of GraphQL errors.
+func graphQLErrors(err error) []*GraphQLError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []*GraphQLError
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, graphQLErrors(e)...)
+		}
+		return errs
+	}
+	var gqlErr *GraphQLError
+	if errors.As(err, &gqlErr) {
+		return []*GraphQLError{gqlErr}
+	}
+
+	message, fields := errorEnvelope(err)
+	gqlErr = &GraphQLError{Message: message}
+	if fields != nil {
+		gqlErr.Extensions = map[string]any{"fields": fields}
+	}
+	return []*GraphQLError{gqlErr}
+}
@@ -0,0 +1,117 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTools_ReadGraphQLRequest(t *testing.T) {
+	getURL := "/graphql?" + url.Values{
+		"query":         {"query Book($id: ID!) { book(id: $id) { title } }"},
+		"operationName": {"Book"},
+		"variables":     {`{"id":"42"}`},
+	}.Encode()
+
+	tests := []struct {
+		name          string
+		tools         Tools
+		method        string
+		target        string
+		contentType   string
+		body          string
+		expected      GraphQLRequest
+		errorExpected bool
+		status        int
+	}{
+		{name: "get", method: http.MethodGet, target: getURL, expected: GraphQLRequest{Query: "query Book($id: ID!) { book(id: $id) { title } }", OperationName: "Book", Variables: map[string]any{"id": "42"}}},
+		{name: "post json", method: http.MethodPost, contentType: "application/json; charset=utf-8", body: `{"query":"{ books { title } }","variables":{"first":10},"id":"ignored"}`, expected: GraphQLRequest{Query: "{ books { title } }", Variables: map[string]any{"first": float64(10)}}},
+		{name: "post without content type", method: http.MethodPost, body: `{"query":"{ books { title } }"}`, expected: GraphQLRequest{Query: "{ books { title } }"}},
+		{name: "post graphql", method: http.MethodPost, contentType: "application/graphql", body: "{ books { title } }", expected: GraphQLRequest{Query: "{ books { title } }"}},
+		{name: "no query", method: http.MethodPost, contentType: "application/json", body: `{"variables":{}}`, errorExpected: true},
+		{name: "bad json", method: http.MethodPost, contentType: "application/json", body: `{"query":`, errorExpected: true},
+		{name: "bad variables", method: http.MethodGet, target: "/graphql?query=%7Ba%7D&variables=%5B1%5D", errorExpected: true},
+		{name: "too large", tools: Tools{MaxJSONSize: 16}, method: http.MethodPost, contentType: "application/json", body: `{"query":"{ books { title } }"}`, errorExpected: true},
+		{name: "query string too large", tools: Tools{MaxJSONSize: 16}, method: http.MethodGet, target: getURL, errorExpected: true},
+		{name: "too deep", tools: Tools{Limits: Limits{MaxDepth: 2}}, method: http.MethodPost, contentType: "application/json", body: `{"query":"{a}","variables":{"a":{"b":1}}}`, errorExpected: true},
+		{name: "wrong content type", method: http.MethodPost, contentType: "text/plain", body: "{a}", errorExpected: true, status: http.StatusUnsupportedMediaType},
+		{name: "wrong method", method: http.MethodPut, body: `{"query":"{a}"}`, errorExpected: true, status: http.StatusMethodNotAllowed},
+	}
+
+	for _, e := range tests {
+		target := e.target
+		if target == "" {
+			target = "/graphql"
+		}
+		request := httptest.NewRequest(e.method, target, strings.NewReader(e.body))
+		if e.contentType != "" {
+			request.Header.Set("Content-Type", e.contentType)
+		}
+		rr := httptest.NewRecorder()
+
+		req, err := e.tools.ReadGraphQLRequest(rr, request)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected an error, got none", e.name)
+			}
+			var httpErr *HTTPError
+			if e.status != 0 && (!errors.As(err, &httpErr) || httpErr.Status != e.status) {
+				t.Errorf("%s: expected a %d error, got %v", e.name, e.status, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		got, _ := json.Marshal(req)
+		expected, _ := json.Marshal(e.expected)
+		if string(got) != string(expected) {
+			t.Errorf("%s: expected %s, got %s", e.name, expected, got)
+		}
+	}
+}
+
+func TestTools_WriteGraphQLError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		status   []int
+		expected string
+		code     int
+	}{
+		{name: "plain error", err: errors.New("query must not be empty"), expected: `{"errors":[{"message":"query must not be empty"}]}`, code: http.StatusBadRequest},
+		{name: "http error", err: &HTTPError{Status: http.StatusUnauthorized, PublicMessage: "log in first", Internal: errors.New("no session")}, expected: `{"errors":[{"message":"log in first"}]}`, code: http.StatusUnauthorized},
+		{name: "fields", err: &HTTPError{Status: http.StatusBadRequest, PublicMessage: "invalid input", Fields: map[string]string{"email": "is required"}}, expected: `{"errors":[{"message":"invalid input","extensions":{"fields":{"email":"is required"}}}]}`, code: http.StatusBadRequest},
+		{name: "graphql error", err: &GraphQLError{Message: "Cannot query field \"titel\"", Locations: []GraphQLLocation{{Line: 1, Column: 10}}}, status: []int{http.StatusOK}, expected: `{"errors":[{"message":"Cannot query field \"titel\"","locations":[{"line":1,"column":10}]}]}`, code: http.StatusOK},
+		{name: "joined", err: errors.Join(&GraphQLError{Message: "a", Path: []any{"books", 0}}, errors.New("b")), expected: `{"errors":[{"message":"a","path":["books",0]},{"message":"b"}]}`, code: http.StatusBadRequest},
+	}
+
+	var testTools Tools
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		if err := testTools.WriteGraphQLError(rr, e.err, e.status...); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if rr.Code != e.code {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.code, rr.Code)
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+
+	// the ErrorHandler sees the response, as with ErrorJSON
+	var seen *ErrorResponse
+	testTools.ErrorHandler = ErrorHandlerFunc(func(resp *ErrorResponse) { seen = resp })
+	_ = testTools.WriteGraphQLError(httptest.NewRecorder(), errors.New("boom"))
+	if seen == nil || seen.Err.Error() != "boom" {
+		t.Errorf("expected the ErrorHandler to be called, got %v", seen)
+	}
+}
@@ -0,0 +1,141 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+)
+
+// GRPCCode is a gRPC status code, with the same values as codes.Code in google.golang.org/grpc/codes.
+type GRPCCode uint32
+
+// The gRPC status codes.
+const (
+	GRPCOK                 GRPCCode = 0
+	GRPCCanceled           GRPCCode = 1
+	GRPCUnknown            GRPCCode = 2
+	GRPCInvalidArgument    GRPCCode = 3
+	GRPCDeadlineExceeded   GRPCCode = 4
+	GRPCNotFound           GRPCCode = 5
+	GRPCAlreadyExists      GRPCCode = 6
+	GRPCPermissionDenied   GRPCCode = 7
+	GRPCResourceExhausted  GRPCCode = 8
+	GRPCFailedPrecondition GRPCCode = 9
+	GRPCAborted            GRPCCode = 10
+	GRPCOutOfRange         GRPCCode = 11
+	GRPCUnimplemented      GRPCCode = 12
+	GRPCInternal           GRPCCode = 13
+	GRPCUnavailable        GRPCCode = 14
+	GRPCDataLoss           GRPCCode = 15
+	GRPCUnauthenticated    GRPCCode = 16
+)
+
+// statusClientClosedRequest is the non-standard status used for requests the client canceled.
+const statusClientClosedRequest = 499
+
+// grpcHTTPStatus maps gRPC status codes to HTTP statuses, as grpc-gateway does.
+var grpcHTTPStatus = map[GRPCCode]int{
+	GRPCOK:                 http.StatusOK,
+	GRPCCanceled:           statusClientClosedRequest,
+	GRPCUnknown:            http.StatusInternalServerError,
+	GRPCInvalidArgument:    http.StatusBadRequest,
+	GRPCDeadlineExceeded:   http.StatusGatewayTimeout,
+	GRPCNotFound:           http.StatusNotFound,
+	GRPCAlreadyExists:      http.StatusConflict,
+	GRPCPermissionDenied:   http.StatusForbidden,
+	GRPCResourceExhausted:  http.StatusTooManyRequests,
+	GRPCFailedPrecondition: http.StatusBadRequest,
+	GRPCAborted:            http.StatusConflict,
+	GRPCOutOfRange:         http.StatusBadRequest,
+	GRPCUnimplemented:      http.StatusNotImplemented,
+	GRPCInternal:           http.StatusInternalServerError,
+	GRPCUnavailable:        http.StatusServiceUnavailable,
+	GRPCDataLoss:           http.StatusInternalServerError,
+	GRPCUnauthenticated:    http.StatusUnauthorized,
+}
+
+// GRPCStatusToHTTP returns the HTTP status for a gRPC status code, as grpc-gateway maps them: NotFound is
+// 404, Unavailable is 503, and so on. Codes it doesn't know are 500 Internal Server Error.
+func GRPCStatusToHTTP(code GRPCCode) int {
+	if status, ok := grpcHTTPStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// MapGRPCError converts an error from a gRPC backend into an *HTTPError with the matching HTTP status, for
+// ErrorJSON to send, so services fronting gRPC present the same errors as the rest of the API. Errors with
+// a gRPC status (those from google.golang.org/grpc/status, found anywhere in the chain of err) and
+// context.DeadlineExceeded and context.Canceled are converted; anything else is returned as it is. For
+// client errors, the message of the status is sent; server errors send only their status text, so the
+// backend's internals aren't shown to clients. The original error is kept as the internal error.
+func MapGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	code, message, ok := grpcStatus(err)
+	if !ok {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			code = GRPCDeadlineExceeded
+		case errors.Is(err, context.Canceled):
+			code, message = GRPCCanceled, context.Canceled.Error()
+		default:
+			return err
+		}
+	}
+
+	status := GRPCStatusToHTTP(code)
+	if status >= http.StatusInternalServerError {
+		message = ""
+	}
+	return &HTTPError{Status: status, PublicMessage: message, Internal: err}
+}
+
+// grpcStatus returns the code and message of the first error in the chain of err with a GRPCStatus method,
+// as the errors of google.golang.org/grpc/status have. The method and the status it returns are called
+// through reflection, so the toolkit doesn't depend on gRPC.
+func grpcStatus(err error) (GRPCCode, string, bool) {
+	for _, e := range errorChain(err) {
+		method := reflect.ValueOf(e).MethodByName("GRPCStatus")
+		if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+			continue
+		}
+		status := method.Call(nil)[0]
+		if status.Kind() == reflect.Pointer && status.IsNil() {
+			continue
+		}
+		codeMethod, messageMethod := status.MethodByName("Code"), status.MethodByName("Message")
+		if !codeMethod.IsValid() || !messageMethod.IsValid() || codeMethod.Type().NumIn() != 0 || messageMethod.Type().NumIn() != 0 {
+			continue
+		}
+		code, message := codeMethod.Call(nil), messageMethod.Call(nil)
+		if len(code) != 1 || !code[0].CanUint() || len(message) != 1 || message[0].Kind() != reflect.String {
+			continue
+		}
+		return GRPCCode(code[0].Uint()), message[0].String(), true
+	}
+	return 0, "", false
+}
+
+// errorChain returns err and every error it wraps, depth first, as errors.As walks them.
+func errorChain(err error) []error {
+	var chain []error
+	for err != nil {
+		chain = append(chain, err)
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, e := range x.Unwrap() {
+				chain = append(chain, errorChain(e)...)
+			}
+			return chain
+		default:
+			return chain
+		}
+	}
+	return chain
+}
@@ -0,0 +1,104 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeCode, fakeStatus and fakeStatusError mirror codes.Code, status.Status and the error type of
+// google.golang.org/grpc/status.
+type fakeCode uint32
+
+type fakeStatus struct {
+	code    fakeCode
+	message string
+}
+
+func (s *fakeStatus) Code() fakeCode  { return s.code }
+func (s *fakeStatus) Message() string { return s.message }
+
+type fakeStatusError struct{ s *fakeStatus }
+
+func (e *fakeStatusError) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", e.s.code, e.s.message)
+}
+func (e *fakeStatusError) GRPCStatus() *fakeStatus { return e.s }
+
+func grpcErr(code GRPCCode, message string) error {
+	return &fakeStatusError{s: &fakeStatus{code: fakeCode(code), message: message}}
+}
+
+func TestMapGRPCError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		message  string
+		mapped   bool
+		internal bool
+	}{
+		{name: "not found", err: grpcErr(GRPCNotFound, "book 42 not found"), status: http.StatusNotFound, message: "book 42 not found", mapped: true},
+		{name: "invalid argument", err: grpcErr(GRPCInvalidArgument, "isbn is required"), status: http.StatusBadRequest, message: "isbn is required", mapped: true},
+		{name: "unauthenticated", err: grpcErr(GRPCUnauthenticated, "token expired"), status: http.StatusUnauthorized, message: "token expired", mapped: true},
+		{name: "internal hides message", err: grpcErr(GRPCInternal, "pq: relation books does not exist"), status: http.StatusInternalServerError, message: "Internal Server Error", mapped: true},
+		{name: "unavailable", err: grpcErr(GRPCUnavailable, "connection refused"), status: http.StatusServiceUnavailable, message: "Service Unavailable", mapped: true},
+		{name: "unknown code", err: grpcErr(99, "odd"), status: http.StatusInternalServerError, message: "Internal Server Error", mapped: true},
+		{name: "wrapped", err: fmt.Errorf("calling catalog: %w", grpcErr(GRPCAlreadyExists, "book exists")), status: http.StatusConflict, message: "book exists", mapped: true},
+		{name: "joined", err: errors.Join(errors.New("retrying"), grpcErr(GRPCResourceExhausted, "quota exceeded")), status: http.StatusTooManyRequests, message: "quota exceeded", mapped: true},
+		{name: "deadline", err: context.DeadlineExceeded, status: http.StatusGatewayTimeout, message: "Gateway Timeout", mapped: true},
+		{name: "canceled", err: fmt.Errorf("rpc: %w", context.Canceled), status: 499, message: "context canceled", mapped: true},
+		{name: "nil status", err: &fakeStatusError{}},
+		{name: "plain error", err: errors.New("boom")},
+	}
+
+	for _, e := range tests {
+		mapped := MapGRPCError(e.err)
+		var httpErr *HTTPError
+		if !e.mapped {
+			if mapped != e.err {
+				t.Errorf("%s: expected the error to be returned as it is, got %v", e.name, mapped)
+			}
+			continue
+		}
+		if !errors.As(mapped, &httpErr) {
+			t.Errorf("%s: expected an *HTTPError, got %v", e.name, mapped)
+			continue
+		}
+		if httpErr.Status != e.status || httpErr.publicMessage() != e.message {
+			t.Errorf("%s: expected %d %q, got %d %q", e.name, e.status, e.message, httpErr.Status, httpErr.publicMessage())
+		}
+		if !errors.Is(mapped, e.err) {
+			t.Errorf("%s: expected the original error to be kept", e.name)
+		}
+	}
+
+	if MapGRPCError(nil) != nil {
+		t.Error("expected nil for a nil error")
+	}
+}
+
+func TestTools_ErrorJSON_GRPC(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+
+	_ = testTools.ErrorJSON(rr, MapGRPCError(grpcErr(GRPCPermissionDenied, "not your book")))
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rr.Code)
+	}
+	if expected := `{"error":true,"message":"not your book"}`; rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
+
+func TestGRPCStatusToHTTP(t *testing.T) {
+	for code := GRPCOK; code <= GRPCUnauthenticated; code++ {
+		if status := GRPCStatusToHTTP(code); status < 200 || status > 599 {
+			t.Errorf("code %d: unexpected status %d", code, status)
+		}
+	}
+}
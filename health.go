@@ -0,0 +1,256 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheck reports whether a dependency is healthy, returning an error if it isn't.
+type HealthCheck func(ctx context.Context) error
+
+// HealthCheckResult is the outcome of a single check, as reported by the health handlers.
+type HealthCheckResult struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"` // set by the check with SetHealthDetails, e.g. the disk usage
+}
+
+// HealthReport is the body written by the health handlers.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// healthStatusOK and healthStatusFail are the statuses used in health reports.
+const (
+	healthStatusOK   = "ok"
+	healthStatusFail = "fail"
+)
+
+// healthDetailsKey is the context key for the details a check reports with SetHealthDetails.
+const healthDetailsKey contextKey = "healthDetails"
+
+// healthDetails holds the details reported by a running check.
+type healthDetails struct {
+	mu    sync.Mutex
+	value any
+}
+
+// SetHealthDetails reports details, such as current usage, from a running HealthCheck. They are included
+// in the check's result, whether it passes or fails. It does nothing outside a check.
+func SetHealthDetails(ctx context.Context, details any) {
+	if d, ok := ctx.Value(healthDetailsKey).(*healthDetails); ok {
+		d.mu.Lock()
+		d.value = details
+		d.mu.Unlock()
+	}
+}
+
+// namedHealthCheck is a registered check.
+type namedHealthCheck struct {
+	name      string
+	check     HealthCheck
+	readiness bool
+}
+
+// Health holds the health checks for a service, and serves liveness and readiness endpoints. Liveness
+// checks should only fail when the process needs restarting; readiness checks (databases, caches, remote
+// dependencies) fail when the service shouldn't be sent traffic for now.
+type Health struct {
+	Timeout time.Duration // time allowed for each check; defaults to 5 seconds
+	tools   *Tools
+	mu      sync.RWMutex
+	checks  []namedHealthCheck
+}
+
+// NewHealth returns an empty Health, which writes its reports using t.
+func (t *Tools) NewHealth() *Health {
+	return &Health{Timeout: 5 * time.Second, tools: t}
+}
+
+// AddLivenessCheck registers a check used by the liveness endpoint (and the combined one).
+func (h *Health) AddLivenessCheck(name string, check HealthCheck) {
+	h.add(namedHealthCheck{name: name, check: check})
+}
+
+// AddReadinessCheck registers a check used by the readiness endpoint (and the combined one).
+func (h *Health) AddReadinessCheck(name string, check HealthCheck) {
+	h.add(namedHealthCheck{name: name, check: check, readiness: true})
+}
+
+// add registers a check, replacing any existing check with the same name.
+func (h *Health) add(c namedHealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := range h.checks {
+		if h.checks[i].name == c.name {
+			h.checks[i] = c
+			return
+		}
+	}
+	h.checks = append(h.checks, c)
+}
+
+// Check runs the selected checks concurrently and returns the aggregate report. The overall status is
+// "ok" only if every check passed.
+func (h *Health) Check(ctx context.Context, liveness, readiness bool) HealthReport {
+	h.mu.RLock()
+	var checks []namedHealthCheck
+	for _, c := range h.checks {
+		if (c.readiness && readiness) || (!c.readiness && liveness) {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	report := HealthReport{Status: healthStatusOK, Checks: make(map[string]HealthCheckResult, len(checks))}
+	results := make([]HealthCheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedHealthCheck) {
+			defer wg.Done()
+			results[i] = h.run(ctx, c.check)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != healthStatusOK {
+			report.Status = healthStatusFail
+		}
+	}
+
+	return report
+}
+
+// run runs a single check with the configured timeout, recovering from panics so a broken check can't
+// take the endpoint down.
+func (h *Health) run(ctx context.Context, check HealthCheck) (result HealthCheckResult) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	details := &healthDetails{}
+	ctx = context.WithValue(ctx, healthDetailsKey, details)
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- fmt.Errorf("check panicked: %v", rec)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	details.mu.Lock()
+	result = HealthCheckResult{Status: healthStatusOK, Latency: time.Since(start).String(), Details: details.value}
+	details.mu.Unlock()
+	if err != nil {
+		result.Status = healthStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler returns a handler which runs the liveness checks. It responds 200 OK if they all pass,
+// and 503 Service Unavailable otherwise.
+func (h *Health) LivenessHandler() http.Handler {
+	return h.handler(true, false)
+}
+
+// ReadinessHandler returns a handler which runs the readiness checks, responding like LivenessHandler.
+func (h *Health) ReadinessHandler() http.Handler {
+	return h.handler(false, true)
+}
+
+// Handler returns a handler which runs every check, responding like LivenessHandler.
+func (h *Health) Handler() http.Handler {
+	return h.handler(true, true)
+}
+
+// handler returns a handler which runs the selected checks and writes the report as JSON.
+func (h *Health) handler(liveness, readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Check(r.Context(), liveness, readiness)
+
+		status := http.StatusOK
+		if report.Status != healthStatusOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		headers := http.Header{"Cache-Control": []string{"no-store"}}
+		_ = h.tools.WriteJSON(w, status, report, headers)
+	})
+}
+
+// Pinger is implemented by *sql.DB and many cache clients.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingCheck returns a check which calls p.PingContext.
+func PingCheck(p Pinger) HealthCheck {
+	return p.PingContext
+}
+
+// RemoteCheck returns a check which fails unless a GET request to url responds with a status below 400.
+func RemoteCheck(client *http.Client, url string) HealthCheck {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// DiskSpaceCheck returns a check which fails if the file system holding path has fewer than minFree
+// bytes available. The check reports the DiskUsage in its details.
+func DiskSpaceCheck(path string, minFree uint64) HealthCheck {
+	return func(ctx context.Context) error {
+		usage, err := GetDiskUsage(path)
+		if err != nil {
+			return err
+		}
+		SetHealthDetails(ctx, usage)
+		if usage.Free < minFree {
+			return fmt.Errorf("only %d bytes free on %s, need %d", usage.Free, path, minFree)
+		}
+		return nil
+	}
+}
+
+// errDiskSpaceUnsupported is returned where free disk space can't be determined.
+var errDiskSpaceUnsupported = errors.New("free disk space is not supported on this platform")
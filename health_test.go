@@ -0,0 +1,160 @@
+package toolkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var healthTests = []struct {
+	name           string
+	handler        func(h *Health) http.Handler
+	expectedStatus int
+	expectedReport string
+	expectedChecks []string
+}{
+	{name: "liveness", handler: (*Health).LivenessHandler, expectedStatus: http.StatusOK, expectedReport: healthStatusOK, expectedChecks: []string{"process"}},
+	{name: "readiness", handler: (*Health).ReadinessHandler, expectedStatus: http.StatusServiceUnavailable, expectedReport: healthStatusFail, expectedChecks: []string{"db", "cache", "slow", "panics"}},
+	{name: "combined", handler: (*Health).Handler, expectedStatus: http.StatusServiceUnavailable, expectedReport: healthStatusFail, expectedChecks: []string{"process", "db", "cache", "slow", "panics"}},
+}
+
+func TestHealth_Handlers(t *testing.T) {
+	var testTools Tools
+	h := testTools.NewHealth()
+	h.Timeout = 50 * time.Millisecond
+
+	h.AddLivenessCheck("process", func(ctx context.Context) error { return nil })
+	h.AddReadinessCheck("db", func(ctx context.Context) error { return errors.New("connection refused") })
+	h.AddReadinessCheck("db", func(ctx context.Context) error { return nil })
+	h.AddReadinessCheck("cache", func(ctx context.Context) error { return errors.New("cache down") })
+	h.AddReadinessCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h.AddReadinessCheck("panics", func(ctx context.Context) error { panic("boom") })
+
+	for _, e := range healthTests {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+
+		e.handler(h).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if rr.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("%s: expected Cache-Control no-store but got %q", e.name, rr.Header().Get("Cache-Control"))
+		}
+
+		var report HealthReport
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("%s: error decoding report: %s", e.name, err)
+		}
+		if report.Status != e.expectedReport {
+			t.Errorf("%s: wrong report status; expected %q but got %q", e.name, e.expectedReport, report.Status)
+		}
+		if len(report.Checks) != len(e.expectedChecks) {
+			t.Errorf("%s: expected %d checks but got %d", e.name, len(e.expectedChecks), len(report.Checks))
+		}
+		for _, name := range e.expectedChecks {
+			result, ok := report.Checks[name]
+			if !ok {
+				t.Errorf("%s: missing check %q", e.name, name)
+				continue
+			}
+			if result.Latency == "" {
+				t.Errorf("%s: check %q has no latency", e.name, name)
+			}
+		}
+	}
+
+	report := h.Check(context.Background(), false, true)
+	if report.Checks["db"].Status != healthStatusOK {
+		t.Errorf("expected re-registered db check to replace the original, got %+v", report.Checks["db"])
+	}
+	if report.Checks["cache"].Error != "cache down" {
+		t.Errorf("wrong cache error; got %q", report.Checks["cache"].Error)
+	}
+	if report.Checks["slow"].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("expected slow check to time out, got %q", report.Checks["slow"].Error)
+	}
+	if report.Checks["panics"].Error != "check panicked: boom" {
+		t.Errorf("wrong panic error; got %q", report.Checks["panics"].Error)
+	}
+}
+
+func TestSetHealthDetails(t *testing.T) {
+	var testTools Tools
+	h := testTools.NewHealth()
+	h.AddLivenessCheck("queue", func(ctx context.Context) error {
+		SetHealthDetails(ctx, map[string]int{"pending": 3})
+		return nil
+	})
+	h.AddLivenessCheck("plain", func(ctx context.Context) error { return nil })
+
+	rr := httptest.NewRecorder()
+	h.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	var report struct {
+		Checks map[string]struct {
+			Details map[string]int `json:"details"`
+		} `json:"checks"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("error decoding report: %s", err)
+	}
+	if report.Checks["queue"].Details["pending"] != 3 {
+		t.Errorf("expected the queue check's details, got %+v", report.Checks["queue"])
+	}
+	if report.Checks["plain"].Details != nil {
+		t.Errorf("expected no details for the plain check, got %+v", report.Checks["plain"])
+	}
+
+	// outside a check, details are ignored
+	SetHealthDetails(context.Background(), "ignored")
+}
+
+func TestRemoteCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	if err := RemoteCheck(srv.Client(), srv.URL+"/up")(context.Background()); err != nil {
+		t.Errorf("expected healthy remote, got %s", err)
+	}
+	if err := RemoteCheck(srv.Client(), srv.URL+"/down")(context.Background()); err == nil {
+		t.Error("expected error for remote responding 502")
+	}
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	if _, _, err := diskSpace(t.TempDir()); errors.Is(err, errDiskSpaceUnsupported) {
+		t.Skip(err)
+	}
+
+	if err := DiskSpaceCheck(t.TempDir(), 1)(context.Background()); err != nil {
+		t.Errorf("expected at least one free byte, got %s", err)
+	}
+
+	// the usage is reported in the check's details, even when it fails
+	var testTools Tools
+	h := testTools.NewHealth()
+	h.AddReadinessCheck("disk", DiskSpaceCheck(t.TempDir(), ^uint64(0)))
+	result := h.Check(context.Background(), true, true).Checks["disk"]
+	if usage, ok := result.Details.(DiskUsage); result.Status != healthStatusFail || !ok || usage.Total == 0 {
+		t.Errorf("expected a failed check with the disk usage, got %+v", result)
+	}
+	if err := DiskSpaceCheck(t.TempDir(), ^uint64(0))(context.Background()); err == nil {
+		t.Error("expected error when requiring more space than exists")
+	}
+	if err := DiskSpaceCheck("./does/not/exist", 1)(context.Background()); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
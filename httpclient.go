@@ -0,0 +1,94 @@
+package toolkit
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTPClientOptions configures a client built by NewHTTPClient. Zero values get the defaults noted.
+type HTTPClientOptions struct {
+	Timeout             time.Duration                         // limit on a whole request, including reading the response body; defaults to 30s
+	DialTimeout         time.Duration                         // limit on opening a connection; defaults to 10s
+	TLSHandshakeTimeout time.Duration                         // limit on the TLS handshake; defaults to 10s
+	IdleConnTimeout     time.Duration                         // how long idle connections are kept for reuse; defaults to 90s
+	MaxIdleConnsPerHost int                                   // idle connections kept per host; defaults to 10
+	Proxy               func(*http.Request) (*url.URL, error) // proxy to use; defaults to http.ProxyFromEnvironment
+	TLSConfig           *tls.Config                           // TLS configuration, e.g. for client certificates or private CAs
+}
+
+// defaultHTTPClient is shared by the remote helpers when Tools has no HTTPClient, so connections are reused.
+var defaultHTTPClient = NewHTTPClient(HTTPClientOptions{})
+
+// NewHTTPClient returns an http.Client with production-safe timeouts and connection pooling. Build one
+// client and share it, e.g. by setting Tools.HTTPClient, so connections to the same hosts are reused.
+func NewHTTPClient(opts HTTPClientOptions) *http.Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	if opts.TLSHandshakeTimeout <= 0 {
+		opts.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if opts.IdleConnTimeout <= 0 {
+		opts.IdleConnTimeout = 90 * time.Second
+	}
+	if opts.MaxIdleConnsPerHost <= 0 {
+		opts.MaxIdleConnsPerHost = 10
+	}
+	if opts.Proxy == nil {
+		opts.Proxy = http.ProxyFromEnvironment
+	}
+
+	dialer := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 opts.Proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		TLSClientConfig:       opts.TLSConfig,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{Timeout: opts.Timeout, Transport: transport}
+}
+
+// httpClient returns the client the remote helpers use when they aren't given one: HTTPClient if it is
+// set, or a shared client with the defaults of NewHTTPClient.
+func (t *Tools) httpClient() *http.Client {
+	if t.HTTPClient != nil {
+		return t.HTTPClient
+	}
+	return defaultHTTPClient
+}
+
+// withTimeout returns req with a deadline of timeout, if it is positive, and a func which releases it.
+func withTimeout(req *http.Request, timeout time.Duration) (*http.Request, context.CancelFunc) {
+	if timeout <= 0 {
+		return req, func() {}
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	return req.WithContext(ctx), cancel
+}
+
+// cancelOnClose releases a request's deadline once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer.
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,87 @@
+package toolkit
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	client := NewHTTPClient(HTTPClientOptions{})
+	transport := client.Transport.(*http.Transport)
+
+	if client.Timeout != 30*time.Second {
+		t.Errorf("expected default timeout of 30s but got %s", client.Timeout)
+	}
+	if transport.MaxIdleConnsPerHost != 10 {
+		t.Errorf("expected 10 idle connections per host but got %d", transport.MaxIdleConnsPerHost)
+	}
+	if transport.TLSHandshakeTimeout != 10*time.Second || transport.IdleConnTimeout != 90*time.Second {
+		t.Errorf("unexpected transport timeouts %s, %s", transport.TLSHandshakeTimeout, transport.IdleConnTimeout)
+	}
+	if transport.Proxy == nil {
+		t.Error("expected proxy settings to come from the environment")
+	}
+
+	proxyURL, _ := url.Parse("http://proxy.internal:3128")
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS13}
+	client = NewHTTPClient(HTTPClientOptions{
+		Timeout:             time.Second,
+		MaxIdleConnsPerHost: 50,
+		Proxy:               http.ProxyURL(proxyURL),
+		TLSConfig:           tlsConfig,
+	})
+	transport = client.Transport.(*http.Transport)
+
+	if client.Timeout != time.Second || transport.MaxIdleConnsPerHost != 50 || transport.TLSClientConfig != tlsConfig {
+		t.Error("options were not applied")
+	}
+	if p, _ := transport.Proxy(httptest.NewRequest(http.MethodGet, "http://example.com", nil)); p.String() != proxyURL.String() {
+		t.Errorf("expected proxy %s but got %s", proxyURL, p)
+	}
+}
+
+func TestTools_HTTPClient(t *testing.T) {
+	var testTools Tools
+	if testTools.httpClient() != defaultHTTPClient {
+		t.Error("expected the shared default client")
+	}
+
+	used := false
+	testTools.HTTPClient = NewTestClient(func(req *http.Request) *http.Response {
+		used = true
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: make(http.Header)}
+	})
+	if _, _, err := testTools.PushJSONToRemote("http://example.com", map[string]int{"a": 1}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !used {
+		t.Error("expected HTTPClient to be used when no client is given")
+	}
+}
+
+func TestTools_PushToRemoteTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	var testTools Tools
+	start := time.Now()
+	_, _, err := testTools.PushToRemote(srv.URL, strings.NewReader("x"), "text/plain", RemoteOptions{Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error but got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("timeout was not applied; call took %s", time.Since(start))
+	}
+}
@@ -0,0 +1,93 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+)
+
+// HTTPError is an error carrying the response it should produce: a status code, a message which is safe
+// to show the client, and optionally the errors of individual fields. ErrorJSON, ErrorXML and the other
+// error helpers send the public message, with the fields as the envelope's data, and use the status
+// unless one is passed explicitly, so handlers can return errors and have them rendered consistently.
+// The internal error is never sent; it is there for logs and the ErrorHandler.
+type HTTPError struct {
+	Status        int               // the status code of the response
+	PublicMessage string            // the message sent to the client; defaults to the status text, e.g. "Not Found"
+	Internal      error             // the underlying error, which is not sent to the client
+	Fields        map[string]string // problems with individual fields, e.g. {"email": "is required"}
+}
+
+// NewHTTPError returns an *HTTPError with the given status, public message and internal error.
+func NewHTTPError(status int, message string, err error) *HTTPError {
+	return &HTTPError{Status: status, PublicMessage: message, Internal: err}
+}
+
+// Error returns the public message, followed by the internal error if there is one, for logs.
+func (e *HTTPError) Error() string {
+	if e.Internal == nil {
+		return e.publicMessage()
+	}
+	return e.publicMessage() + ": " + e.Internal.Error()
+}
+
+// Unwrap returns the internal error, so errors.Is and errors.As see through an HTTPError.
+func (e *HTTPError) Unwrap() error {
+	return e.Internal
+}
+
+// publicMessage returns PublicMessage, or the status text if it is empty.
+func (e *HTTPError) publicMessage() string {
+	if e.PublicMessage != "" {
+		return e.PublicMessage
+	}
+	return http.StatusText(e.Status)
+}
+
+// BadRequest returns a 400 Bad Request *HTTPError wrapping err.
+func BadRequest(err error) *HTTPError {
+	return &HTTPError{Status: http.StatusBadRequest, Internal: err}
+}
+
+// Unauthorized returns a 401 Unauthorized *HTTPError wrapping err.
+func Unauthorized(err error) *HTTPError {
+	return &HTTPError{Status: http.StatusUnauthorized, Internal: err}
+}
+
+// Forbidden returns a 403 Forbidden *HTTPError wrapping err.
+func Forbidden(err error) *HTTPError {
+	return &HTTPError{Status: http.StatusForbidden, Internal: err}
+}
+
+// NotFound returns a 404 Not Found *HTTPError wrapping err.
+func NotFound(err error) *HTTPError {
+	return &HTTPError{Status: http.StatusNotFound, Internal: err}
+}
+
+// Conflict returns a 409 Conflict *HTTPError wrapping err.
+func Conflict(err error) *HTTPError {
+	return &HTTPError{Status: http.StatusConflict, Internal: err}
+}
+
+// Unprocessable returns a 422 Unprocessable Entity *HTTPError listing the problems with each field.
+func Unprocessable(fields map[string]string) *HTTPError {
+	return &HTTPError{Status: http.StatusUnprocessableEntity, Fields: fields}
+}
+
+// InternalServerError returns a 500 Internal Server Error *HTTPError wrapping err, whose details are
+// kept from the client.
+func InternalServerError(err error) *HTTPError {
+	return &HTTPError{Status: http.StatusInternalServerError, Internal: err}
+}
+
+// errorEnvelope returns the message and data to send for err: those of an *HTTPError in its chain, or
+// else err's own message.
+func errorEnvelope(err error) (string, any) {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return err.Error(), nil
+	}
+	if len(httpErr.Fields) == 0 {
+		return httpErr.publicMessage(), nil
+	}
+	return httpErr.publicMessage(), httpErr.Fields
+}
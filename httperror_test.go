@@ -0,0 +1,96 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPError_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *HTTPError
+		expected string
+	}{
+		{name: "status text", err: NotFound(nil), expected: "Not Found"},
+		{name: "internal", err: NotFound(fs.ErrNotExist), expected: "Not Found: file does not exist"},
+		{name: "public message", err: NewHTTPError(http.StatusConflict, "email already registered", nil), expected: "email already registered"},
+	}
+
+	for _, e := range tests {
+		if e.err.Error() != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, e.err.Error())
+		}
+	}
+
+	if !errors.Is(fmt.Errorf("loading: %w", NotFound(fs.ErrNotExist)), fs.ErrNotExist) {
+		t.Error("expected errors.Is to see the internal error")
+	}
+}
+
+func TestTools_ErrorJSON_HTTPError(t *testing.T) {
+	var testTools Tools
+
+	tests := []struct {
+		name           string
+		err            error
+		status         []int
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "not found", err: NotFound(errors.New("sql: no rows")), expectedStatus: http.StatusNotFound, expectedBody: `{"error":true,"message":"Not Found"}`},
+		{name: "wrapped", err: fmt.Errorf("get user: %w", Forbidden(nil)), expectedStatus: http.StatusForbidden, expectedBody: `{"error":true,"message":"Forbidden"}`},
+		{name: "explicit status wins", err: Conflict(nil), status: []int{http.StatusGone}, expectedStatus: http.StatusGone, expectedBody: `{"error":true,"message":"Conflict"}`},
+		{name: "internal hidden", err: InternalServerError(errors.New("pq: password authentication failed")), expectedStatus: http.StatusInternalServerError, expectedBody: `{"error":true,"message":"Internal Server Error"}`},
+		{name: "public message", err: NewHTTPError(http.StatusTooManyRequests, "slow down", nil), expectedStatus: http.StatusTooManyRequests, expectedBody: `{"error":true,"message":"slow down"}`},
+		{name: "fields", err: Unprocessable(map[string]string{"email": "is required"}), expectedStatus: http.StatusUnprocessableEntity, expectedBody: `{"error":true,"message":"Unprocessable Entity","data":{"email":"is required"}}`},
+		{name: "plain error", err: errors.New("bad input"), expectedStatus: http.StatusBadRequest, expectedBody: `{"error":true,"message":"bad input"}`},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		_ = testTools.ErrorJSON(rr, e.err, e.status...)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if rr.Body.String() != e.expectedBody {
+			t.Errorf("%s: expected body %s, got %s", e.name, e.expectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_ErrorXML_HTTPError(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorXML(rr, Unprocessable(map[string]string{"email": "is required"}))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "<message>Unprocessable Entity</message><data><email>is required</email></data>") {
+		t.Errorf("unexpected body %s", rr.Body.String())
+	}
+}
+
+func TestHandleJSON_HTTPError(t *testing.T) {
+	var testTools Tools
+	handler := HandleJSON(&testTools, func(r *http.Request, in struct{}) (struct{}, int, error) {
+		return struct{}{}, 0, NotFound(errors.New("no such widget"))
+	})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/widgets/1", nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr.Body.String() != `{"error":true,"message":"Not Found"}` {
+		t.Errorf("unexpected body %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,131 @@
+package toolkit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteUnits are the units HumanBytes uses, each 1024 times the one before.
+var byteUnits = []string{"KB", "MB", "GB", "TB", "PB", "EB"}
+
+// HumanBytes writes a size in bytes for people, using units of 1024, e.g. 104857600 is "100 MB" and 1536
+// is "1.5 KB". Sizes under a kilobyte are written in bytes.
+func HumanBytes(n int64) string {
+	if n < 0 {
+		return "-" + HumanBytes(-n)
+	}
+	if n < 1024 {
+		return plural(n, "byte")
+	}
+
+	v := float64(n)
+	unit := -1
+	for unit < len(byteUnits)-1 && math.Round(v*10)/10 >= 1024 {
+		v /= 1024
+		unit++
+	}
+	return trimDecimal(v) + " " + byteUnits[unit]
+}
+
+// HumanDuration writes d for people, e.g. "1 hour 30 minutes", "2.5 seconds" or "250 milliseconds". From a
+// minute up, the two largest units are given, and the rest is dropped.
+func HumanDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + HumanDuration(-d)
+	}
+
+	switch {
+	case d < time.Microsecond:
+		return plural(int64(d), "nanosecond")
+	case d < time.Millisecond:
+		return pluralDecimal(float64(d)/float64(time.Microsecond), "microsecond")
+	case d < time.Second:
+		return pluralDecimal(float64(d)/float64(time.Millisecond), "millisecond")
+	case d < time.Minute:
+		return pluralDecimal(d.Seconds(), "second")
+	}
+
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{24 * time.Hour, "day"},
+		{time.Hour, "hour"},
+		{time.Minute, "minute"},
+		{time.Second, "second"},
+	}
+	for i, u := range units {
+		if d < u.size {
+			continue
+		}
+		s := plural(int64(d/u.size), u.name)
+		if i+1 < len(units) {
+			if n := int64(d % u.size / units[i+1].size); n > 0 {
+				s += " " + plural(n, units[i+1].name)
+			}
+		}
+		return s
+	}
+	return ""
+}
+
+// RelativeTime writes how long ago tm was, or how long until it is, e.g. "3 hours ago", "in 2 days" or
+// "just now" for anything within a minute.
+func RelativeTime(tm time.Time) string {
+	return relativeTime(tm, time.Now())
+}
+
+// relativeTime writes tm relative to now.
+func relativeTime(tm, now time.Time) string {
+	d := now.Sub(tm)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	if d < time.Minute {
+		return "just now"
+	}
+
+	var s string
+	switch {
+	case d < time.Hour:
+		s = plural(int64(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		s = plural(int64(d/time.Hour), "hour")
+	case d < 30*24*time.Hour:
+		s = plural(int64(d/(24*time.Hour)), "day")
+	case d < 365*24*time.Hour:
+		s = plural(int64(d/(30*24*time.Hour)), "month")
+	default:
+		s = plural(int64(d/(365*24*time.Hour)), "year")
+	}
+	if future {
+		return "in " + s
+	}
+	return s + " ago"
+}
+
+// plural writes n with unit, adding an s unless n is 1.
+func plural(n int64, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// pluralDecimal writes v, to one decimal place, with unit, adding an s unless it is 1.
+func pluralDecimal(v float64, unit string) string {
+	s := trimDecimal(v)
+	if s == "1" {
+		return "1 " + unit
+	}
+	return s + " " + unit + "s"
+}
+
+// trimDecimal writes v with at most one decimal place, dropping it if it is zero.
+func trimDecimal(v float64) string {
+	return strings.TrimSuffix(strconv.FormatFloat(v, 'f', 1, 64), ".0")
+}
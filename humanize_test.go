@@ -0,0 +1,81 @@
+package toolkit
+
+import (
+	"testing"
+	"time"
+)
+
+var humanBytesTests = []struct {
+	n        int64
+	expected string
+}{
+	{0, "0 bytes"},
+	{1, "1 byte"},
+	{1023, "1023 bytes"},
+	{1024, "1 KB"},
+	{1536, "1.5 KB"},
+	{104857600, "100 MB"},
+	{1024*1024 - 1, "1 MB"},
+	{5 * 1024 * 1024 * 1024 * 1024, "5 TB"},
+	{-2048, "-2 KB"},
+}
+
+func TestHumanBytes(t *testing.T) {
+	for _, e := range humanBytesTests {
+		if got := HumanBytes(e.n); got != e.expected {
+			t.Errorf("%d: expected %q but got %q", e.n, e.expected, got)
+		}
+	}
+}
+
+var humanDurationTests = []struct {
+	d        time.Duration
+	expected string
+}{
+	{0, "0 nanoseconds"},
+	{850 * time.Microsecond, "850 microseconds"},
+	{1500 * time.Microsecond, "1.5 milliseconds"},
+	{250 * time.Millisecond, "250 milliseconds"},
+	{time.Second, "1 second"},
+	{2500 * time.Millisecond, "2.5 seconds"},
+	{90 * time.Second, "1 minute 30 seconds"},
+	{time.Hour, "1 hour"},
+	{time.Hour + 30*time.Minute + 10*time.Second, "1 hour 30 minutes"},
+	{49*time.Hour + 5*time.Minute, "2 days 1 hour"},
+	{24*time.Hour + 5*time.Minute, "1 day"},
+	{-90 * time.Second, "-1 minute 30 seconds"},
+}
+
+func TestHumanDuration(t *testing.T) {
+	for _, e := range humanDurationTests {
+		if got := HumanDuration(e.d); got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.d, e.expected, got)
+		}
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2025, time.March, 14, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		tm       time.Time
+		expected string
+	}{
+		{now.Add(-30 * time.Second), "just now"},
+		{now.Add(20 * time.Second), "just now"},
+		{now.Add(-time.Minute), "1 minute ago"},
+		{now.Add(-3*time.Hour - 20*time.Minute), "3 hours ago"},
+		{now.Add(2*24*time.Hour + time.Hour), "in 2 days"},
+		{now.Add(-45 * 24 * time.Hour), "1 month ago"},
+		{now.Add(-800 * 24 * time.Hour), "2 years ago"},
+	}
+	for _, e := range tests {
+		if got := relativeTime(e.tm, now); got != e.expected {
+			t.Errorf("%s: expected %q but got %q", now.Sub(e.tm), e.expected, got)
+		}
+	}
+
+	if got := RelativeTime(time.Now().Add(-2 * time.Hour)); got != "2 hours ago" {
+		t.Errorf("expected %q but got %q", "2 hours ago", got)
+	}
+}
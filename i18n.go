@@ -0,0 +1,201 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keys of the messages the toolkit's request errors are built from. Translations are fmt format strings
+// taking the same arguments as the English default, shown next to each key; use explicit argument indexes,
+// such as %[2]q, where a language needs them in a different order.
+const (
+	MsgContentTypeJSON = "content_type.json"  // Content-Type must be application/json
+	MsgContentTypeForm = "content_type.form"  // Content-Type must be application/x-www-form-urlencoded or multipart/form-data
+	MsgEmpty           = "decode.empty"       // %s must not be empty
+	MsgTooLarge        = "decode.too_large"   // %s must not be larger than %d bytes
+	MsgUnknownKey      = "decode.unknown_key" // %s contains unknown key %s
+	MsgInvalidField    = "decode.invalid"     // %s contains an invalid value for field %q
+	MsgBadForm         = "form.syntax"        // %s contains a badly-formed form: %s
+	MsgJSONOneValue    = "json.one_value"     // %s must contain only one JSON value
+	MsgJSONSyntax      = "json.syntax"        // %s contains badly-formed JSON
+	MsgJSONSyntaxAt    = "json.syntax_at"     // %s contains badly-formed JSON (at character %d)
+	MsgJSONFieldType   = "json.field_type"    // %s contains icnorrect JSON type for field %q
+	MsgJSONTypeAt      = "json.type_at"       // %s contains an invalid JSON (at character %d)
+	MsgJSONDepth       = "json.depth"         // %s must not nest JSON more than %d deep
+	MsgTooManyHeaders  = "request.headers"    // request must not have more than %d headers
+	MsgQueryInt        = "query.int"          // query parameter %q must be a whole number
+	MsgQueryPositive   = "query.positive"     // query parameter %q must be a positive whole number
+	MsgQueryMax        = "query.max"          // query parameter %q must not be larger than %d
+	MsgQueryBool       = "query.bool"         // query parameter %q must be true or false
+	MsgQueryTime       = "query.time"         // query parameter %q must be a date or an RFC 3339 time
+)
+
+// defaultMessages are the English messages, used when there is no translation.
+var defaultMessages = map[string]string{
+	MsgContentTypeJSON: "Content-Type must be application/json",
+	MsgContentTypeForm: "Content-Type must be application/x-www-form-urlencoded or multipart/form-data",
+	MsgEmpty:           "%s must not be empty",
+	MsgTooLarge:        "%s must not be larger than %d bytes",
+	MsgUnknownKey:      "%s contains unknown key %s",
+	MsgInvalidField:    "%s contains an invalid value for field %q",
+	MsgBadForm:         "%s contains a badly-formed form: %s",
+	MsgJSONOneValue:    "%s must contain only one JSON value",
+	MsgJSONSyntax:      "%s contains badly-formed JSON",
+	MsgJSONSyntaxAt:    "%s contains badly-formed JSON (at character %d)",
+	MsgJSONFieldType:   "%s contains icnorrect JSON type for field %q",
+	MsgJSONTypeAt:      "%s contains an invalid JSON (at character %d)",
+	MsgJSONDepth:       "%s must not nest JSON more than %d deep",
+	MsgTooManyHeaders:  "request must not have more than %d headers",
+	MsgQueryInt:        "query parameter %q must be a whole number",
+	MsgQueryPositive:   "query parameter %q must be a positive whole number",
+	MsgQueryMax:        "query parameter %q must not be larger than %d",
+	MsgQueryBool:       "query parameter %q must be true or false",
+	MsgQueryTime:       "query parameter %q must be a date or an RFC 3339 time",
+}
+
+// messageSubject is the part of a request a message is about, such as "body". It is translated with the
+// key "subject.<name>", e.g. "subject.body" or "subject.query string".
+type messageSubject string
+
+// MessageError is an error with a message from the catalog, which ErrorJSONLocalized can send in the
+// client's language. Its Error method returns the English message.
+type MessageError struct {
+	Key  string // one of the Msg keys
+	Args []any  // the arguments of the message
+}
+
+// newMessageError returns a *MessageError for key, about subject if it isn't empty.
+func newMessageError(key string, subject string, args ...any) *MessageError {
+	if subject != "" {
+		args = append([]any{messageSubject(subject)}, args...)
+	}
+	return &MessageError{Key: key, Args: args}
+}
+
+// Error implements the error interface.
+func (e *MessageError) Error() string {
+	format, ok := defaultMessages[e.Key]
+	if !ok {
+		return e.Key
+	}
+	return fmt.Sprintf(format, e.Args...)
+}
+
+// Translator is a source of translated messages, such as a Catalog, or one backed by files or a database.
+type Translator interface {
+	// Translate returns the message for key in locale, e.g. "pt-BR", and reports whether there is one.
+	Translate(locale, key string) (string, bool)
+}
+
+// Catalog is a Translator holding messages in memory, by locale and then by key.
+type Catalog map[string]map[string]string
+
+// Translate implements Translator.
+func (c Catalog) Translate(locale, key string) (string, bool) {
+	msg, ok := c[locale][key]
+	return msg, ok
+}
+
+// NegotiateLocale returns the locale in Locales which best matches the request's Accept-Language header,
+// preferring an exact match, then one for the same language (so "fr-CA" matches "fr", and "en" matches
+// "en-GB"). If nothing matches, it returns the first of Locales, or "en" if Locales is empty.
+func (t *Tools) NegotiateLocale(r *http.Request) string {
+	if len(t.Locales) == 0 {
+		return "en"
+	}
+
+	type languageRange struct {
+		tag string
+		q   float64
+	}
+	var ranges []languageRange
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, languageRange{tag, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, lr := range ranges {
+		for _, locale := range t.Locales {
+			if strings.EqualFold(locale, lr.tag) {
+				return locale
+			}
+		}
+		lang, _, _ := strings.Cut(lr.tag, "-")
+		for _, locale := range t.Locales {
+			if l, _, _ := strings.Cut(locale, "-"); strings.EqualFold(l, lang) {
+				return locale
+			}
+		}
+	}
+	return t.Locales[0]
+}
+
+// Localize returns the message of err in the language negotiated for r. Only a *MessageError can be
+// translated, and only if Translator has its key for that locale; any other error, or one without a
+// translation, gives err.Error().
+func (t *Tools) Localize(r *http.Request, err error) string {
+	msg, _ := t.localize(r, err)
+	return msg
+}
+
+// localize returns the message of err for r, and the locale it was translated to, if it was.
+func (t *Tools) localize(r *http.Request, err error) (string, string) {
+	msgErr, ok := err.(*MessageError)
+	if !ok || t.Translator == nil {
+		return err.Error(), ""
+	}
+
+	locale := t.NegotiateLocale(r)
+	format, ok := t.Translator.Translate(locale, msgErr.Key)
+	if !ok {
+		return err.Error(), ""
+	}
+
+	args := make([]any, len(msgErr.Args))
+	for i, arg := range msgErr.Args {
+		args[i] = arg
+		if subject, ok := arg.(messageSubject); ok {
+			if s, ok := t.Translator.Translate(locale, "subject."+string(subject)); ok {
+				args[i] = s
+			}
+		}
+	}
+	return fmt.Sprintf(format, args...), locale
+}
+
+// localizedError returns err translated for r, setting the Content-Language header of w if it was.
+func (t *Tools) localizedError(w http.ResponseWriter, r *http.Request, err error) error {
+	msg, locale := t.localize(r, err)
+	if locale == "" {
+		return err
+	}
+	w.Header().Set("Content-Language", locale)
+	return errors.New(msg)
+}
+
+// ErrorJSONLocalized is like ErrorJSON, but sends the message of err in the client's language, as
+// negotiated from the Accept-Language header of r. See Localize.
+func (t *Tools) ErrorJSONLocalized(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	statusCode := 0
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+	return t.sendError(w, &ErrorResponse{Request: r, Err: t.localizedError(w, r, err), Status: statusCode})
+}
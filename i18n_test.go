@@ -0,0 +1,101 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var negotiateLocaleTests = []struct {
+	name           string
+	locales        []string
+	acceptLanguage string
+	expected       string
+}{
+	{name: "no locales", acceptLanguage: "fr", expected: "en"},
+	{name: "no header", locales: []string{"en", "fr"}, expected: "en"},
+	{name: "exact", locales: []string{"en", "pt-BR"}, acceptLanguage: "pt-br", expected: "pt-BR"},
+	{name: "region to language", locales: []string{"en", "fr"}, acceptLanguage: "fr-CA", expected: "fr"},
+	{name: "language to region", locales: []string{"de", "en-GB"}, acceptLanguage: "en", expected: "en-GB"},
+	{name: "quality", locales: []string{"en", "fr", "es"}, acceptLanguage: "fr;q=0.5, es;q=0.8", expected: "es"},
+	{name: "first acceptable", locales: []string{"en", "fr"}, acceptLanguage: "ja, fr;q=0.9", expected: "fr"},
+	{name: "refused", locales: []string{"en", "fr"}, acceptLanguage: "fr;q=0", expected: "en"},
+	{name: "no match", locales: []string{"en", "fr"}, acceptLanguage: "ja, *", expected: "en"},
+}
+
+func TestTools_NegotiateLocale(t *testing.T) {
+	for _, e := range negotiateLocaleTests {
+		testTools := Tools{Locales: e.locales}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if e.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", e.acceptLanguage)
+		}
+		if got := testTools.NegotiateLocale(req); got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+var testCatalog = Catalog{
+	"es": {
+		MsgEmpty:        "%s no debe estar vacío",
+		MsgInvalidField: "el campo %[2]q de %[1]s no es válido",
+		"subject.body":  "el cuerpo",
+	},
+}
+
+func TestTools_Localize(t *testing.T) {
+	testTools := Tools{Translator: testCatalog, Locales: []string{"en", "es"}}
+
+	tests := []struct {
+		name           string
+		err            error
+		acceptLanguage string
+		expected       string
+	}{
+		{name: "translated", err: newMessageError(MsgEmpty, "body"), acceptLanguage: "es-MX", expected: "el cuerpo no debe estar vacío"},
+		{name: "reordered arguments", err: newMessageError(MsgInvalidField, "body", "age"), acceptLanguage: "es", expected: `el campo "age" de el cuerpo no es válido`},
+		{name: "untranslated subject", err: newMessageError(MsgEmpty, "message"), acceptLanguage: "es", expected: "message no debe estar vacío"},
+		{name: "untranslated key", err: newMessageError(MsgJSONSyntax, "body"), acceptLanguage: "es", expected: "body contains badly-formed JSON"},
+		{name: "default locale", err: newMessageError(MsgEmpty, "body"), acceptLanguage: "fr", expected: "body must not be empty"},
+		{name: "plain error", err: errors.New("something broke"), acceptLanguage: "es", expected: "something broke"},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", e.acceptLanguage)
+		if got := testTools.Localize(req, e.err); got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestTools_ErrorJSONLocalized(t *testing.T) {
+	testTools := Tools{Translator: testCatalog, Locales: []string{"en", "es"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "es")
+	rr := httptest.NewRecorder()
+
+	var data struct{}
+	err := testTools.ReadJSON(rr, req, &data)
+	if err == nil || err.Error() != "body must not be empty" {
+		t.Fatalf("expected the English error from ReadJSON, got %v", err)
+	}
+	if err := testTools.ErrorJSONLocalized(rr, req, err); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 but got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Content-Language"); got != "es" {
+		t.Errorf("expected Content-Language es but got %q", got)
+	}
+	if got := rr.Body.String(); !strings.Contains(got, `"message":"el cuerpo no debe estar vacío"`) {
+		t.Errorf("expected a translated message, got %s", got)
+	}
+}
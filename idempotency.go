@@ -0,0 +1,263 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IdempotentResponse is a response stored by the Idempotency middleware, to be replayed for retries.
+type IdempotentResponse struct {
+	Status      int
+	Header      http.Header
+	Body        []byte
+	Fingerprint string // a hash of the request's method, URL and body, to detect a key reused for another request
+}
+
+// IdempotencyStore keeps the responses stored by the Idempotency middleware. The in-memory store suits a
+// single instance; implement this interface on top of a shared store such as Redis to replay responses
+// across instances.
+type IdempotencyStore interface {
+	// Start returns the response stored for key, if there is one. Otherwise it claims key for a request for
+	// at most ttl, and reports whether it could; it can't while another request holds the claim.
+	Start(ctx context.Context, key string, ttl time.Duration) (resp *IdempotentResponse, claimed bool, err error)
+	// Finish stores resp for key for ttl, in place of the claim.
+	Finish(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error
+	// Cancel releases the claim on key, so the request can be tried again.
+	Cancel(ctx context.Context, key string) error
+}
+
+// IdempotencyOptions configures the Idempotency middleware.
+type IdempotencyOptions struct {
+	Store           IdempotencyStore // where responses are kept; defaults to a new MemoryIdempotencyStore
+	TTL             time.Duration    // how long responses are replayed for; defaults to 24 hours
+	LockTimeout     time.Duration    // how long a key stays claimed by a request in progress; defaults to a minute
+	Methods         []string         // methods keys are honored for; defaults to POST and PATCH
+	Required        bool             // if set to true, requests with those methods and no key get 400 Bad Request
+	MaxRequestSize  int64            // largest request body accepted, as it is read to fingerprint the request; defaults to 10 MB
+	MaxResponseSize int64            // largest response body stored; larger responses aren't replayed; defaults to 1 MB
+}
+
+// maxIdempotencyKeyLength is the longest Idempotency-Key accepted.
+const maxIdempotencyKeyLength = 255
+
+// Idempotency returns middleware honoring the Idempotency-Key header, so clients can safely retry
+// requests such as payments. The first response for a key (its status, headers and body) is stored, and
+// replayed with an Idempotent-Replayed header for retries with the same key, without calling the handler
+// again. A retry while the first request is still in progress gets 409 Conflict, and a key reused for a
+// different method, URL or body gets 422 Unprocessable Entity. Server errors (5xx) and responses larger
+// than MaxResponseSize aren't stored, so the request can be retried. Keys are scoped to the API key
+// authenticated by APIKeyAuth and the tenant resolved by TenantResolver, if any. If the store fails, the
+// error is logged and the request is handled as if it had no key.
+func (t *Tools) Idempotency(opts IdempotencyOptions) Middleware {
+	if opts.Store == nil {
+		opts.Store = NewMemoryIdempotencyStore()
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = time.Minute
+	}
+	if len(opts.Methods) == 0 {
+		opts.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if opts.MaxRequestSize <= 0 {
+		opts.MaxRequestSize = defaultMaxUpload
+	}
+	if opts.MaxResponseSize <= 0 {
+		opts.MaxResponseSize = 1 << 20
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(opts.Methods, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := r.Header.Get("Idempotency-Key")
+			switch {
+			case key == "" && opts.Required:
+				_ = t.ErrorJSON(w, errors.New("Idempotency-Key header is required"), http.StatusBadRequest)
+				return
+			case key == "":
+				next.ServeHTTP(w, r)
+				return
+			case len(key) > maxIdempotencyKeyLength:
+				_ = t.ErrorJSON(w, errors.New("Idempotency-Key header must not be longer than "+strconv.Itoa(maxIdempotencyKeyLength)+" characters"), http.StatusBadRequest)
+				return
+			}
+			if apiKey := APIKeyFromContext(r.Context()); apiKey != nil {
+				key = "apikey:" + apiKey.ID + ":" + key
+			}
+			if tenant := TenantFromContext(r.Context()); tenant != nil {
+				key = "tenant:" + tenant.ID + ":" + key
+			}
+
+			fingerprint, err := requestFingerprint(r, opts.MaxRequestSize)
+			if err != nil {
+				_ = t.ErrorJSON(w, err)
+				return
+			}
+
+			stored, claimed, err := opts.Store.Start(r.Context(), key, opts.LockTimeout)
+			if err != nil {
+				t.LogError(r.Context(), "idempotency store error", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			switch {
+			case stored != nil && stored.Fingerprint != fingerprint:
+				_ = t.ErrorJSON(w, errors.New("Idempotency-Key was already used for a different request"), http.StatusUnprocessableEntity)
+				return
+			case stored != nil:
+				replayResponse(w, stored)
+				return
+			case !claimed:
+				w.Header().Set("Retry-After", "1")
+				_ = t.ErrorJSON(w, errors.New("a request with this Idempotency-Key is still in progress"), http.StatusConflict)
+				return
+			}
+
+			t.serveIdempotent(w, r, next, opts, key, fingerprint)
+		})
+	}
+}
+
+// serveIdempotent calls next for a request which has claimed key, and stores its response, or releases
+// the claim if the response can't be replayed, or next panics.
+func (t *Tools) serveIdempotent(w http.ResponseWriter, r *http.Request, next http.Handler, opts IdempotencyOptions, key, fingerprint string) {
+	// The response is stored, or the claim released, even if the client has gone away.
+	ctx := context.WithoutCancel(r.Context())
+	finished := false
+	defer func() {
+		if !finished {
+			if err := opts.Store.Cancel(ctx, key); err != nil {
+				t.LogError(ctx, "idempotency store error", "error", err)
+			}
+		}
+	}()
+
+	rw := WrapResponseWriter(w)
+	rw.CaptureBody(opts.MaxResponseSize)
+	next.ServeHTTP(rw, r)
+
+	body, ok := rw.Body()
+	if !ok || rw.Status() >= http.StatusInternalServerError {
+		return
+	}
+	resp := &IdempotentResponse{Status: rw.Status(), Header: w.Header().Clone(), Body: bytes.Clone(body), Fingerprint: fingerprint}
+	if err := opts.Store.Finish(ctx, key, resp, opts.TTL); err != nil {
+		t.LogError(ctx, "idempotency store error", "error", err)
+		return
+	}
+	finished = true
+}
+
+// requestFingerprint returns a hash of the method, URL and body of r, leaving the body to be read again.
+func requestFingerprint(r *http.Request, maxSize int64) (string, error) {
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	if r.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+		if err != nil {
+			return "", err
+		}
+		if int64(len(body)) > maxSize {
+			return "", &HTTPError{Status: http.StatusRequestEntityTooLarge, PublicMessage: "body must not be larger than " + HumanBytes(maxSize)}
+		}
+		h.Write(body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// replayResponse writes a stored response, marked with an Idempotent-Replayed header.
+func replayResponse(w http.ResponseWriter, resp *IdempotentResponse) {
+	for k, v := range resp.Header {
+		w.Header()[k] = slices.Clone(v)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(resp.Body)
+}
+
+// idempotencyEntry is a key in a MemoryIdempotencyStore: a claim, while resp is nil, or a stored response.
+type idempotencyEntry struct {
+	resp    *IdempotentResponse
+	expires time.Time
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore which keeps responses in memory. Expired entries are
+// discarded from time to time, so memory use follows the number of keys used within the TTL.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]*idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// Start returns the response stored for key, or claims it if it is free.
+func (m *MemoryIdempotencyStore) Start(ctx context.Context, key string, ttl time.Duration) (*IdempotentResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	if e, ok := m.entries[key]; ok && now.Before(e.expires) {
+		return e.resp, false, nil
+	}
+	m.entries[key] = &idempotencyEntry{expires: now.Add(ttl)}
+	return nil, true, nil
+}
+
+// Finish stores resp for key.
+func (m *MemoryIdempotencyStore) Finish(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = &idempotencyEntry{resp: resp, expires: m.now().Add(ttl)}
+	return nil
+}
+
+// Cancel releases the claim on key. A stored response is left alone.
+func (m *MemoryIdempotencyStore) Cancel(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok && e.resp == nil {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// sweep discards expired entries, at most once a minute.
+func (m *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+
+	for key, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
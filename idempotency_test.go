@@ -0,0 +1,225 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// the first request claims the key, and others can't while it holds it
+	if resp, claimed, _ := store.Start(ctx, "a", time.Minute); resp != nil || !claimed {
+		t.Fatal("expected the key to be claimed")
+	}
+	if _, claimed, _ := store.Start(ctx, "a", time.Minute); claimed {
+		t.Fatal("expected the key not to be claimed twice")
+	}
+
+	// a cancelled claim frees the key
+	_ = store.Cancel(ctx, "a")
+	if _, claimed, _ := store.Start(ctx, "a", time.Minute); !claimed {
+		t.Fatal("expected the key to be claimed again after cancelling")
+	}
+
+	// a finished request's response is returned until it expires, and can't be cancelled
+	_ = store.Finish(ctx, "a", &IdempotentResponse{Status: http.StatusCreated}, time.Hour)
+	_ = store.Cancel(ctx, "a")
+	if resp, claimed, _ := store.Start(ctx, "a", time.Minute); resp == nil || resp.Status != http.StatusCreated || claimed {
+		t.Fatalf("expected the stored response, got %v", resp)
+	}
+
+	// an abandoned claim expires
+	_, _, _ = store.Start(ctx, "b", time.Minute)
+	now = now.Add(2 * time.Minute)
+	if _, claimed, _ := store.Start(ctx, "b", time.Minute); !claimed {
+		t.Error("expected an expired claim to be replaced")
+	}
+
+	// expired entries are swept
+	now = now.Add(2 * time.Hour)
+	_, _, _ = store.Start(ctx, "c", time.Minute)
+	if len(store.entries) != 1 {
+		t.Errorf("expected expired entries to be swept, but %d remain", len(store.entries))
+	}
+}
+
+func TestTools_Idempotency(t *testing.T) {
+	var testTools Tools
+	calls := 0
+	handler := testTools.Idempotency(IdempotencyOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if strings.Contains(r.URL.Path, "fail") {
+			http.Error(w, "database down", http.StatusServiceUnavailable)
+			return
+		}
+		var payment struct{ Amount int }
+		if err := testTools.ReadJSON(w, r, &payment); err != nil {
+			_ = testTools.ErrorJSON(w, err)
+			return
+		}
+		_ = testTools.Created(w, "/payments/1", map[string]int{"amount": payment.Amount, "call": calls})
+	}))
+
+	send := func(method, target, key, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			request.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+		return rr
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		key      string
+		body     string
+		status   int
+		replayed bool
+		calls    int
+		expected string
+	}{
+		{name: "first", method: http.MethodPost, target: "/payments", key: "k1", body: `{"Amount":10}`, status: http.StatusCreated, calls: 1, expected: `{"amount":10,"call":1}`},
+		{name: "retry", method: http.MethodPost, target: "/payments", key: "k1", body: `{"Amount":10}`, status: http.StatusCreated, replayed: true, calls: 1, expected: `{"amount":10,"call":1}`},
+		{name: "other body", method: http.MethodPost, target: "/payments", key: "k1", body: `{"Amount":20}`, status: http.StatusUnprocessableEntity, calls: 1},
+		{name: "other URL", method: http.MethodPost, target: "/refunds", key: "k1", body: `{"Amount":10}`, status: http.StatusUnprocessableEntity, calls: 1},
+		{name: "other key", method: http.MethodPost, target: "/payments", key: "k2", body: `{"Amount":10}`, status: http.StatusCreated, calls: 2, expected: `{"amount":10,"call":2}`},
+		{name: "no key", method: http.MethodPost, target: "/payments", body: `{"Amount":10}`, status: http.StatusCreated, calls: 3},
+		{name: "client error stored", method: http.MethodPost, target: "/payments", key: "k3", body: `{"Amount":"x"}`, status: http.StatusBadRequest, calls: 4},
+		{name: "client error replayed", method: http.MethodPost, target: "/payments", key: "k3", body: `{"Amount":"x"}`, status: http.StatusBadRequest, replayed: true, calls: 4},
+		{name: "server error", method: http.MethodPost, target: "/fail", key: "k4", status: http.StatusServiceUnavailable, calls: 5},
+		{name: "server error retried", method: http.MethodPost, target: "/fail", key: "k4", status: http.StatusServiceUnavailable, calls: 6},
+		{name: "other method", method: http.MethodPut, target: "/payments", key: "k1", body: `{"Amount":10}`, status: http.StatusCreated, calls: 7},
+		{name: "key too long", method: http.MethodPost, target: "/payments", key: strings.Repeat("k", 256), status: http.StatusBadRequest, calls: 7},
+	}
+
+	for _, e := range tests {
+		rr := send(e.method, e.target, e.key, e.body)
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, rr.Code)
+		}
+		if replayed := rr.Header().Get("Idempotent-Replayed") == "true"; replayed != e.replayed {
+			t.Errorf("%s: expected replayed to be %t", e.name, e.replayed)
+		}
+		if calls != e.calls {
+			t.Errorf("%s: expected %d calls to the handler, got %d", e.name, e.calls, calls)
+		}
+		if e.expected != "" && rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+		if e.replayed && e.status == http.StatusCreated && rr.Header().Get("Location") != "/payments/1" {
+			t.Errorf("%s: expected the headers to be replayed", e.name)
+		}
+	}
+}
+
+func TestTools_Idempotency_Options(t *testing.T) {
+	var testTools Tools
+	handler := testTools.Idempotency(IdempotencyOptions{Required: true, MaxRequestSize: 8, MaxResponseSize: 4})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("a long response"))
+	}))
+
+	tests := []struct {
+		name   string
+		key    string
+		body   string
+		status int
+	}{
+		{name: "key required", body: "{}", status: http.StatusBadRequest},
+		{name: "body too large", key: "k", body: "0123456789", status: http.StatusRequestEntityTooLarge},
+		{name: "response not stored", key: "k", body: "{}", status: http.StatusOK},
+		{name: "response too large to replay", key: "k", body: "{}", status: http.StatusOK},
+	}
+	for _, e := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		if e.key != "" {
+			request.Header.Set("Idempotency-Key", e.key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+		if rr.Code != e.status || rr.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("%s: expected status %d without a replay, got %d", e.name, e.status, rr.Code)
+		}
+	}
+}
+
+func TestTools_Idempotency_Concurrent(t *testing.T) {
+	var testTools Tools
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := testTools.Idempotency(IdempotencyOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		request := httptest.NewRequest(http.MethodPost, "/", nil)
+		request.Header.Set("Idempotency-Key", "k")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+	}()
+	<-started
+
+	// a retry while the first request is in progress is told to wait
+	request := httptest.NewRequest(http.MethodPost, "/", nil)
+	request.Header.Set("Idempotency-Key", "k")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request)
+	if rr.Code != http.StatusConflict || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 409 with Retry-After, got %d", rr.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, request)
+	if rr.Code != http.StatusCreated || rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected the replayed 201, got %d", rr.Code)
+	}
+}
+
+// failingIdempotencyStore is an IdempotencyStore which always fails.
+type failingIdempotencyStore struct{}
+
+func (failingIdempotencyStore) Start(ctx context.Context, key string, ttl time.Duration) (*IdempotentResponse, bool, error) {
+	return nil, false, errors.New("redis unavailable")
+}
+func (failingIdempotencyStore) Finish(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	return nil
+}
+func (failingIdempotencyStore) Cancel(ctx context.Context, key string) error { return nil }
+
+func TestTools_Idempotency_StoreError(t *testing.T) {
+	var logBuf bytes.Buffer
+	testTools := Tools{ErrorLog: log.New(&logBuf, "", 0)}
+	handler := testTools.Idempotency(IdempotencyOptions{Store: failingIdempotencyStore{}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	request := httptest.NewRequest(http.MethodPost, "/", nil)
+	request.Header.Set("Idempotency-Key", "k")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected the request to be handled despite the store failing, got %d", rr.Code)
+	}
+	if !strings.Contains(logBuf.String(), "redis unavailable") {
+		t.Errorf("expected the store error to be logged, got %q", logBuf.String())
+	}
+}
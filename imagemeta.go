@@ -0,0 +1,301 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	_ "image/gif"  // register the GIF decoder for ExtractImageMetadata
+	_ "image/jpeg" // register the JPEG decoder for ExtractImageMetadata
+	_ "image/png"  // register the PNG decoder for ExtractImageMetadata
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// exifDateFormat is the layout of the dates in EXIF data.
+const exifDateFormat = "2006:01:02 15:04:05"
+
+// EXIF tags read by ExtractImageMetadata.
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagLensModel        = 0xA434
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+	tagGPSAltitudeRef   = 0x0005
+	tagGPSAltitude      = 0x0006
+)
+
+// ImageMetadata describes an image: its dimensions, and what its EXIF data says about how and when it was
+// taken. Fields the image has no data for are left empty.
+type ImageMetadata struct {
+	Format      string       `json:"format"`               // the image format: jpeg, png or gif
+	Width       int          `json:"width"`                // the width in pixels, as stored; swap it with Height for orientations 5 to 8
+	Height      int          `json:"height"`               // the height in pixels, as stored
+	Orientation int          `json:"orientation"`          // the EXIF orientation, 1 to 8; 1 means the image is upright as stored
+	Make        string       `json:"make,omitempty"`       // the camera maker, e.g. Canon
+	Model       string       `json:"model,omitempty"`      // the camera model
+	LensModel   string       `json:"lens_model,omitempty"` // the lens model
+	Software    string       `json:"software,omitempty"`   // the software which produced the image
+	TakenAt     time.Time    `json:"taken_at"`             // when the photo was taken, in the camera's local time, which EXIF doesn't record
+	GPS         *GPSPosition `json:"gps,omitempty"`        // where the photo was taken; only set if asked for with ImageMetadataOptions.GPS
+}
+
+// GPSPosition is a position recorded in EXIF data.
+type GPSPosition struct {
+	Latitude  float64 `json:"latitude"`  // degrees north; negative for south
+	Longitude float64 `json:"longitude"` // degrees east; negative for west
+	Altitude  float64 `json:"altitude"`  // metres above sea level; negative for below
+}
+
+// ImageMetadataOptions configures ExtractImageMetadata.
+type ImageMetadataOptions struct {
+	GPS bool // if set to true, include the GPS position, which is personal data, so left out by default
+}
+
+// ExtractImageMetadata reads the dimensions of the JPEG, PNG or GIF image in r, and for JPEGs, the
+// orientation, camera, time and optionally GPS position from its EXIF data. Only the start of the image is
+// read. An error is returned if r isn't an image in a supported format; EXIF data which can't be parsed
+// is ignored.
+func ExtractImageMetadata(r io.Reader, opts ...ImageMetadataOptions) (*ImageMetadata, error) {
+	var options ImageMetadataOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	// DecodeConfig stops reading at the image data, after the EXIF segment, so keep what it reads to find it.
+	var head bytes.Buffer
+	cfg, format, err := image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &ImageMetadata{Format: format, Width: cfg.Width, Height: cfg.Height, Orientation: 1}
+	if format == "jpeg" {
+		if exif := jpegEXIF(head.Bytes()); exif != nil {
+			readEXIF(exif, meta, options)
+		}
+	}
+	return meta, nil
+}
+
+// ExtractImageMetadataFile is ExtractImageMetadata for the image file at path.
+func ExtractImageMetadataFile(path string, opts ...ImageMetadataOptions) (*ImageMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ExtractImageMetadata(f, opts...)
+}
+
+// jpegEXIF returns the TIFF structure held in the EXIF segment of the JPEG b, or nil if there isn't one.
+func jpegEXIF(b []byte) []byte {
+	if len(b) < 2 || b[0] != 0xFF || b[1] != 0xD8 {
+		return nil
+	}
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xFF {
+			return nil
+		}
+		marker := b[i+1]
+		if marker == 0xD9 || marker == 0xDA {
+			// the end of the image, or the start of the image data
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(b[i+2:]))
+		if size < 2 || i+2+size > len(b) {
+			return nil
+		}
+		segment := b[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		i += 2 + size
+	}
+	return nil
+}
+
+// tiffEntry is an entry of an IFD (image file directory) in a TIFF structure.
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// tiffTypeSizes are the sizes in bytes of each TIFF field type, by type number.
+var tiffTypeSizes = map[uint16]uint64{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// tiffReader reads the IFDs of a TIFF structure, checking every offset against its bounds.
+type tiffReader struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+// newTIFFReader returns a reader for the TIFF structure b, and the offset of its first IFD.
+func newTIFFReader(b []byte) (*tiffReader, uint32, bool) {
+	if len(b) < 8 {
+		return nil, 0, false
+	}
+	tr := &tiffReader{b: b}
+	switch string(b[:2]) {
+	case "II":
+		tr.order = binary.LittleEndian
+	case "MM":
+		tr.order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+	if tr.order.Uint16(b[2:]) != 42 {
+		return nil, 0, false
+	}
+	return tr, tr.order.Uint32(b[4:]), true
+}
+
+// ifd returns the entries of the IFD at offset, by tag. Entries whose values lie out of bounds are
+// skipped.
+func (tr *tiffReader) ifd(offset uint32) map[uint16]tiffEntry {
+	off := uint64(offset)
+	if off+2 > uint64(len(tr.b)) {
+		return nil
+	}
+	n := uint64(tr.order.Uint16(tr.b[off:]))
+	off += 2
+	if off+n*12 > uint64(len(tr.b)) {
+		return nil
+	}
+
+	entries := make(map[uint16]tiffEntry, n)
+	for i := uint64(0); i < n; i++ {
+		e := tr.b[off+i*12 : off+i*12+12]
+		typ := tr.order.Uint16(e[2:])
+		count := tr.order.Uint32(e[4:])
+		size, ok := tiffTypeSizes[typ]
+		if !ok {
+			continue
+		}
+		size *= uint64(count)
+
+		value := e[8:12]
+		if size > 4 {
+			start := uint64(tr.order.Uint32(e[8:]))
+			if start+size > uint64(len(tr.b)) {
+				continue
+			}
+			value = tr.b[start : start+size]
+		}
+		entries[tr.order.Uint16(e)] = tiffEntry{typ: typ, count: count, value: value[:size]}
+	}
+	return entries
+}
+
+// text returns the value of an ASCII entry.
+func (tr *tiffReader) text(e tiffEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	s, _, _ := strings.Cut(string(e.value), "\x00")
+	return strings.TrimSpace(s)
+}
+
+// number returns the first value of a BYTE, SHORT or LONG entry.
+func (tr *tiffReader) number(e tiffEntry) (uint32, bool) {
+	if e.count == 0 {
+		return 0, false
+	}
+	switch e.typ {
+	case 1:
+		return uint32(e.value[0]), true
+	case 3:
+		return uint32(tr.order.Uint16(e.value)), true
+	case 4:
+		return tr.order.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+// rationals returns the values of a RATIONAL entry.
+func (tr *tiffReader) rationals(e tiffEntry) []float64 {
+	if e.typ != 5 {
+		return nil
+	}
+	out := make([]float64, e.count)
+	for i := range out {
+		num, den := tr.order.Uint32(e.value[i*8:]), tr.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			return nil
+		}
+		out[i] = float64(num) / float64(den)
+	}
+	return out
+}
+
+// readEXIF fills in meta from the EXIF TIFF structure b.
+func readEXIF(b []byte, meta *ImageMetadata, opts ImageMetadataOptions) {
+	tr, offset, ok := newTIFFReader(b)
+	if !ok {
+		return
+	}
+	ifd0 := tr.ifd(offset)
+
+	if o, ok := tr.number(ifd0[tagOrientation]); ok && o >= 1 && o <= 8 {
+		meta.Orientation = int(o)
+	}
+	meta.Make = tr.text(ifd0[tagMake])
+	meta.Model = tr.text(ifd0[tagModel])
+	meta.Software = tr.text(ifd0[tagSoftware])
+	taken := tr.text(ifd0[tagDateTime])
+
+	if off, ok := tr.number(ifd0[tagExifIFD]); ok {
+		exif := tr.ifd(off)
+		if s := tr.text(exif[tagDateTimeOriginal]); s != "" {
+			taken = s
+		}
+		meta.LensModel = tr.text(exif[tagLensModel])
+	}
+	if t, err := time.Parse(exifDateFormat, taken); err == nil {
+		meta.TakenAt = t
+	}
+
+	if !opts.GPS {
+		return
+	}
+	if off, ok := tr.number(ifd0[tagGPSIFD]); ok {
+		meta.GPS = readGPS(tr, tr.ifd(off))
+	}
+}
+
+// readGPS returns the position in a GPS IFD, or nil if it has no latitude and longitude.
+func readGPS(tr *tiffReader, gps map[uint16]tiffEntry) *GPSPosition {
+	lat, lon := tr.rationals(gps[tagGPSLatitude]), tr.rationals(gps[tagGPSLongitude])
+	if len(lat) != 3 || len(lon) != 3 {
+		return nil
+	}
+
+	pos := &GPSPosition{
+		Latitude:  lat[0] + lat[1]/60 + lat[2]/3600,
+		Longitude: lon[0] + lon[1]/60 + lon[2]/3600,
+	}
+	if tr.text(gps[tagGPSLatitudeRef]) == "S" {
+		pos.Latitude = -pos.Latitude
+	}
+	if tr.text(gps[tagGPSLongitudeRef]) == "W" {
+		pos.Longitude = -pos.Longitude
+	}
+	if alt := tr.rationals(gps[tagGPSAltitude]); len(alt) == 1 {
+		pos.Altitude = alt[0]
+		if ref, ok := tr.number(gps[tagGPSAltitudeRef]); ok && ref == 1 {
+			pos.Altitude = -pos.Altitude
+		}
+	}
+	return pos
+}
@@ -0,0 +1,239 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// exifField is a field of a test EXIF IFD; if ifd is set, the field points to that IFD.
+type exifField struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+	ifd   int
+}
+
+// exifASCII returns an ASCII field holding s.
+func exifASCII(tag uint16, s string) exifField {
+	return exifField{tag: tag, typ: 2, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+// exifRationals returns a RATIONAL field holding the fractions in nums, each numerator followed by its
+// denominator.
+func exifRationals(order binary.ByteOrder, tag uint16, nums ...uint32) exifField {
+	data := make([]byte, 4*len(nums))
+	for i, n := range nums {
+		order.PutUint32(data[i*4:], n)
+	}
+	return exifField{tag: tag, typ: 5, count: uint32(len(nums) / 2), data: data}
+}
+
+// buildTIFF lays out the IFDs one after another, the first being IFD0, each followed by its data.
+func buildTIFF(order binary.ByteOrder, ifds ...[]exifField) []byte {
+	offsets := make([]uint32, len(ifds))
+	offset := uint32(8)
+	for i, fields := range ifds {
+		offsets[i] = offset
+		offset += uint32(2 + 12*len(fields) + 4)
+		for _, f := range fields {
+			if len(f.data) > 4 {
+				offset += uint32(len(f.data))
+			}
+		}
+	}
+
+	app := order.(binary.AppendByteOrder)
+	var out []byte
+	if order == binary.LittleEndian {
+		out = []byte("II")
+	} else {
+		out = []byte("MM")
+	}
+	out = app.AppendUint16(out, 42)
+	out = app.AppendUint32(out, offsets[0])
+
+	for i, fields := range ifds {
+		dataOffset := offsets[i] + uint32(2+12*len(fields)+4)
+		var data []byte
+		out = app.AppendUint16(out, uint16(len(fields)))
+		for _, f := range fields {
+			out = app.AppendUint16(out, f.tag)
+			out = app.AppendUint16(out, f.typ)
+			out = app.AppendUint32(out, f.count)
+			switch {
+			case f.ifd > 0:
+				out = app.AppendUint32(out, offsets[f.ifd])
+			case len(f.data) > 4:
+				out = app.AppendUint32(out, dataOffset+uint32(len(data)))
+				data = append(data, f.data...)
+			default:
+				out = append(out, append(f.data, make([]byte, 4-len(f.data))...)...)
+			}
+		}
+		out = app.AppendUint32(out, 0)
+		out = append(out, data...)
+	}
+	return out
+}
+
+// testJPEG returns a width x height JPEG with an EXIF segment holding tiff, if it isn't nil.
+func testJPEG(t *testing.T, width, height int, tiff []byte) []byte {
+	var buf bytes.Buffer
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if tiff == nil {
+		return buf.Bytes()
+	}
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, buf.Bytes()[2:]...)
+}
+
+// testEXIF returns the EXIF data of a photo taken with a Canon in Sydney.
+func testEXIF(order binary.ByteOrder) []byte {
+	short := make([]byte, 2)
+	order.PutUint16(short, 6)
+	return buildTIFF(order,
+		[]exifField{
+			exifASCII(tagMake, "Canon"),
+			exifASCII(tagModel, "Canon EOS R5"),
+			{tag: tagOrientation, typ: 3, count: 1, data: short},
+			{tag: tagExifIFD, typ: 4, count: 1, ifd: 1},
+			{tag: tagGPSIFD, typ: 4, count: 1, ifd: 2},
+		},
+		[]exifField{
+			exifASCII(tagDateTimeOriginal, "2024:03:15 14:30:00"),
+			exifASCII(tagLensModel, "RF24-105mm F4 L IS USM"),
+		},
+		[]exifField{
+			exifASCII(tagGPSLatitudeRef, "S"),
+			exifRationals(order, tagGPSLatitude, 33, 1, 51, 1, 3144, 100),
+			exifASCII(tagGPSLongitudeRef, "E"),
+			exifRationals(order, tagGPSLongitude, 151, 1, 12, 1, 3360, 100),
+			{tag: tagGPSAltitudeRef, typ: 1, count: 1, data: []byte{0}},
+			exifRationals(order, tagGPSAltitude, 58, 1),
+		},
+	)
+}
+
+func TestExtractImageMetadata(t *testing.T) {
+	var pngBuf bytes.Buffer
+	_ = png.Encode(&pngBuf, image.NewRGBA(image.Rect(0, 0, 3, 2)))
+
+	taken := time.Date(2024, 3, 15, 14, 30, 0, 0, time.UTC)
+	sydney := &GPSPosition{Latitude: -(33 + 51.0/60 + 31.44/3600), Longitude: 151 + 12.0/60 + 33.6/3600, Altitude: 58}
+	camera := ImageMetadata{Format: "jpeg", Width: 40, Height: 30, Orientation: 6, Make: "Canon", Model: "Canon EOS R5",
+		LensModel: "RF24-105mm F4 L IS USM", TakenAt: taken}
+
+	withGPS := camera
+	withGPS.GPS = sydney
+
+	tests := []struct {
+		name     string
+		image    []byte
+		opts     ImageMetadataOptions
+		expected *ImageMetadata
+	}{
+		{name: "big endian", image: testJPEG(t, 40, 30, testEXIF(binary.BigEndian)), expected: &camera},
+		{name: "little endian", image: testJPEG(t, 40, 30, testEXIF(binary.LittleEndian)), expected: &camera},
+		{name: "gps", image: testJPEG(t, 40, 30, testEXIF(binary.BigEndian)), opts: ImageMetadataOptions{GPS: true}, expected: &withGPS},
+		{name: "no exif", image: testJPEG(t, 40, 30, nil), expected: &ImageMetadata{Format: "jpeg", Width: 40, Height: 30, Orientation: 1}},
+		{name: "corrupt exif", image: testJPEG(t, 40, 30, []byte("MM\x00*\xff\xff\xff\xff")), expected: &ImageMetadata{Format: "jpeg", Width: 40, Height: 30, Orientation: 1}},
+		{name: "png", image: pngBuf.Bytes(), expected: &ImageMetadata{Format: "png", Width: 3, Height: 2, Orientation: 1}},
+		{name: "not an image", image: []byte("hello, world")},
+	}
+
+	for _, e := range tests {
+		meta, err := ExtractImageMetadata(bytes.NewReader(e.image), e.opts)
+		if e.expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+
+		gps, expectedGPS := meta.GPS, e.expected.GPS
+		meta.GPS, e.expected.GPS = nil, nil
+		if *meta != *e.expected {
+			t.Errorf("%s: expected %+v, got %+v", e.name, e.expected, meta)
+		}
+		e.expected.GPS = expectedGPS
+		if (gps == nil) != (expectedGPS == nil) {
+			t.Errorf("%s: expected GPS %+v, got %+v", e.name, expectedGPS, gps)
+		} else if gps != nil && (math.Abs(gps.Latitude-expectedGPS.Latitude) > 1e-9 || math.Abs(gps.Longitude-expectedGPS.Longitude) > 1e-9 || gps.Altitude != expectedGPS.Altitude) {
+			t.Errorf("%s: expected GPS %+v, got %+v", e.name, expectedGPS, gps)
+		}
+	}
+}
+
+func TestExtractImageMetadataFile(t *testing.T) {
+	meta, err := ExtractImageMetadataFile("./testdata/img.png")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cfg, _, _ := image.DecodeConfig(bytes.NewReader(mustReadFile(t, "./testdata/img.png")))
+	if meta.Format != "png" || meta.Width != cfg.Width || meta.Height != cfg.Height {
+		t.Errorf("unexpected metadata %+v", meta)
+	}
+
+	if _, err := ExtractImageMetadataFile("./testdata/missing.png"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestTools_UploadFiles_ImageMetadata(t *testing.T) {
+	dir := t.TempDir()
+	photo := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(photo, testJPEG(t, 40, 30, testEXIF(binary.BigEndian)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testTools := Tools{ExtractImageMetadata: true}
+	body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: photo}}, nil)
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Add("Content-Type", contentType)
+
+	file, err := testTools.UploadOneFile(request, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if file.Image == nil || file.Image.Width != 40 || file.Image.Orientation != 6 || file.Image.Model != "Canon EOS R5" || file.Image.GPS != nil {
+		t.Errorf("unexpected image metadata %+v", file.Image)
+	}
+
+	// the file is stored whole after its metadata is read
+	stored := mustReadFile(t, filepath.Join(dir, file.NewFileName))
+	if !bytes.Equal(stored, mustReadFile(t, photo)) {
+		t.Error("stored file differs from the upload")
+	}
+}
+
+// mustReadFile returns the content of the file at path, failing the test if it can't be read.
+func mustReadFile(t *testing.T, path string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,91 @@
+package toolkit
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RemoveStaleFiles removes regular files anywhere under dir which were last modified more than maxAge
+// ago, and returns their paths. Directories are left in place. If dryRun is true, nothing is removed, and
+// the returned paths are the files which would have been.
+func (t *Tools) RemoveStaleFiles(dir string, maxAge time.Duration, dryRun bool) ([]string, error) {
+	cutoff := time.Now().Add(-maxAge)
+	var removed []string
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// a file which disappeared while we were walking is not a problem
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.ModTime().Before(cutoff) {
+			return nil
+		}
+
+		if !dryRun {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		removed = append(removed, p)
+		return nil
+	})
+
+	return removed, err
+}
+
+// StartJanitor starts a background goroutine which calls RemoveStaleFiles on dir every interval, so
+// abandoned uploads and temporary files don't accumulate forever. It runs until ctx is cancelled, and the
+// returned channel is closed once it has stopped. If the optional dryRun argument is true, files are only
+// logged, not removed. Removals are logged at info level and failures at error level. To run the janitor
+// alongside other periodic tasks, add JanitorTask to a Scheduler instead.
+func (t *Tools) StartJanitor(ctx context.Context, dir string, maxAge, interval time.Duration, dryRun ...bool) <-chan struct{} {
+	s := t.NewScheduler()
+	s.Add("janitor", Every(interval), t.JanitorTask(dir, maxAge, dryRun...), TaskOptions{RunAtStart: true})
+	return s.Start(ctx)
+}
+
+// JanitorTask returns a Job which runs a single janitor pass over dir, for use with a Scheduler.
+func (t *Tools) JanitorTask(dir string, maxAge time.Duration, dryRun ...bool) Job {
+	dry := false
+	if len(dryRun) > 0 {
+		dry = dryRun[0]
+	}
+
+	return func(ctx context.Context) error {
+		t.sweep(dir, maxAge, dry)
+		return nil
+	}
+}
+
+// sweep runs a single janitor pass, and logs the result.
+func (t *Tools) sweep(dir string, maxAge time.Duration, dryRun bool) {
+	ctx := context.Background()
+	removed, err := t.RemoveStaleFiles(dir, maxAge, dryRun)
+	if err != nil {
+		t.LogError(ctx, "janitor: error cleaning directory", "dir", dir, "error", err)
+	}
+	for _, p := range removed {
+		if dryRun {
+			t.LogInfo(ctx, "janitor: would remove", "path", p)
+		} else {
+			t.LogInfo(ctx, "janitor: removed", "path", p)
+		}
+	}
+}
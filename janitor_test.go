@@ -0,0 +1,116 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// makeStaleFiles creates an old file, an old file in a subdirectory, and a fresh file in dir.
+func makeStaleFiles(t *testing.T, dir string) {
+	t.Helper()
+	old := time.Now().Add(-2 * time.Hour)
+
+	_ = os.MkdirAll(filepath.Join(dir, "nested"), 0755)
+	for _, name := range []string{"old.tmp", filepath.Join("nested", "old.tmp")} {
+		p := filepath.Join(dir, name)
+		_ = os.WriteFile(p, []byte("old"), 0644)
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = os.WriteFile(filepath.Join(dir, "new.tmp"), []byte("new"), 0644)
+}
+
+func TestTools_RemoveStaleFiles(t *testing.T) {
+	var testTools Tools
+	dir := t.TempDir()
+	makeStaleFiles(t, dir)
+
+	// a dry run reports the stale files, but leaves them alone
+	removed, err := testTools.RemoveStaleFiles(dir, time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("dry run: expected 2 stale files, but got %d", len(removed))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.tmp")); err != nil {
+		t.Error("dry run removed a file")
+	}
+
+	removed, err = testTools.RemoveStaleFiles(dir, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("expected 2 stale files removed, but got %d", len(removed))
+	}
+	for _, name := range []string{"old.tmp", filepath.Join("nested", "old.tmp")} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.tmp")); err != nil {
+		t.Error("fresh file was removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nested")); err != nil {
+		t.Error("directory was removed")
+	}
+}
+
+// safeBuffer is a bytes.Buffer which can be written by a logger in one goroutine, and read by a test in another.
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestTools_StartJanitor(t *testing.T) {
+	var buf safeBuffer
+	var testTools Tools
+	testTools.InfoLog = log.New(&buf, "", 0)
+
+	dir := t.TempDir()
+	makeStaleFiles(t, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := testTools.StartJanitor(ctx, dir, time.Hour, time.Hour, true)
+
+	// the first sweep happens straight away
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(buf.String(), "would remove") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("janitor did not stop after the context was cancelled")
+	}
+
+	if !strings.Contains(buf.String(), "janitor: would remove") {
+		t.Errorf("expected dry run to be logged, but got %q", buf.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.tmp")); err != nil {
+		t.Error("dry run janitor removed a file")
+	}
+}
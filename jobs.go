@@ -0,0 +1,221 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// defaultJobWorkers, defaultJobQueueSize, defaultJobBackoff and defaultJobMaxBackoff are used when
+// JobQueueOptions leaves them unset.
+const (
+	defaultJobWorkers    = 4
+	defaultJobQueueSize  = 100
+	defaultJobBackoff    = time.Second
+	defaultJobMaxBackoff = time.Minute
+)
+
+var (
+	// ErrJobQueueFull is returned by Enqueue when the queue has no room for another job.
+	ErrJobQueueFull = errors.New("job queue is full")
+	// ErrJobQueueClosed is returned by Enqueue once Shutdown has been called.
+	ErrJobQueueClosed = errors.New("job queue is shut down")
+)
+
+// Job is a unit of background work. The context is cancelled if the queue is shut down before the job
+// finishes.
+type Job func(ctx context.Context) error
+
+// JobEvent describes an attempt to run a job, for the metrics hooks.
+type JobEvent struct {
+	Name     string        // the name the job was enqueued with
+	Attempt  int           // 1 for the first attempt
+	Duration time.Duration // time spent on this attempt
+	Err      error         // the error the attempt returned, if any
+	Retrying bool          // whether the job will be retried
+}
+
+// JobHooks are called as jobs move through a queue, so they can be counted and timed. Any of them may be
+// nil. They are called from the worker goroutines, so they must be safe for concurrent use.
+type JobHooks struct {
+	Enqueued func(name string)
+	Started  func(name string, attempt int)
+	Finished func(e JobEvent)
+}
+
+// JobQueueOptions configures NewJobQueue.
+type JobQueueOptions struct {
+	Workers    int           // number of jobs run at once; defaults to 4
+	QueueSize  int           // number of jobs which may wait for a worker; defaults to 100
+	MaxRetries int           // times a failed job is retried; 0 means it is only tried once
+	Backoff    time.Duration // delay before the first retry, doubled for each one after; defaults to 1 second
+	MaxBackoff time.Duration // longest delay between retries; defaults to 1 minute
+	Hooks      JobHooks      // optional metrics hooks
+}
+
+// JobQueue runs jobs in the background on a fixed pool of workers, so handlers can hand off slow work,
+// such as processing an upload, and respond straight away.
+type JobQueue struct {
+	tools  *Tools
+	opts   JobQueueOptions
+	jobs   chan queuedJob
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+}
+
+// queuedJob is a job waiting for a worker.
+type queuedJob struct {
+	name string
+	job  Job
+}
+
+// NewJobQueue starts a job queue with opts.Workers workers. Failed jobs are retried with exponential
+// backoff, and a job which panics is treated as failed rather than taking the process down. Failures
+// are logged at error level. Call Shutdown to stop the queue.
+func (t *Tools) NewJobQueue(opts JobQueueOptions) *JobQueue {
+	if opts.Workers <= 0 {
+		opts.Workers = defaultJobWorkers
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultJobQueueSize
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultJobBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultJobMaxBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &JobQueue{
+		tools:  t,
+		opts:   opts,
+		jobs:   make(chan queuedJob, opts.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for i := 0; i < opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	return q
+}
+
+// Enqueue adds a job to the queue. The name is used in logs and hooks. If the queue is full, the job is
+// not added and ErrJobQueueFull is returned, so callers can shed load instead of blocking a request.
+func (q *JobQueue) Enqueue(name string, job Job) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrJobQueueClosed
+	}
+
+	select {
+	case q.jobs <- queuedJob{name: name, job: job}:
+	default:
+		return ErrJobQueueFull
+	}
+
+	if q.opts.Hooks.Enqueued != nil {
+		q.opts.Hooks.Enqueued(name)
+	}
+	return nil
+}
+
+// Len returns the number of jobs waiting for a worker.
+func (q *JobQueue) Len() int {
+	return len(q.jobs)
+}
+
+// Shutdown stops the queue accepting jobs, and waits for the queued and running jobs to finish. If ctx is
+// done first, the contexts of the running jobs are cancelled, jobs still waiting are dropped, and ctx's
+// error is returned.
+func (q *JobQueue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+// work runs jobs until the queue is closed and empty, or cancelled.
+func (q *JobQueue) work() {
+	defer q.wg.Done()
+
+	for j := range q.jobs {
+		if q.ctx.Err() != nil {
+			continue
+		}
+		q.runWithRetries(j)
+	}
+}
+
+// runWithRetries runs a job, retrying it with backoff until it succeeds or runs out of retries.
+func (q *JobQueue) runWithRetries(j queuedJob) {
+	backoff := q.opts.Backoff
+
+	for attempt := 1; ; attempt++ {
+		if q.opts.Hooks.Started != nil {
+			q.opts.Hooks.Started(j.name, attempt)
+		}
+
+		start := time.Now()
+		err := q.run(j)
+		retrying := err != nil && attempt <= q.opts.MaxRetries && q.ctx.Err() == nil
+
+		if q.opts.Hooks.Finished != nil {
+			q.opts.Hooks.Finished(JobEvent{Name: j.name, Attempt: attempt, Duration: time.Since(start), Err: err, Retrying: retrying})
+		}
+		if err == nil {
+			return
+		}
+
+		q.tools.LogError(q.ctx, "job failed", "job", j.name, "attempt", attempt, "retrying", retrying, "error", err)
+		if !retrying {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-q.ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, q.opts.MaxBackoff)
+	}
+}
+
+// run runs a single attempt of a job, turning a panic into an error.
+func (q *JobQueue) run(j queuedJob) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			q.tools.LogError(q.ctx, "job panicked", "job", j.name, "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+			err = fmt.Errorf("job panicked: %v", rec)
+		}
+	}()
+	return j.job(q.ctx)
+}
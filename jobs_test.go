@@ -0,0 +1,132 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobQueue_RetriesAndPanics(t *testing.T) {
+	var testTools Tools
+
+	var mu sync.Mutex
+	var events []JobEvent
+	enqueued := 0
+
+	q := testTools.NewJobQueue(JobQueueOptions{
+		Workers:    2,
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+		Hooks: JobHooks{
+			Enqueued: func(name string) { mu.Lock(); enqueued++; mu.Unlock() },
+			Finished: func(e JobEvent) { mu.Lock(); events = append(events, e); mu.Unlock() },
+		},
+	})
+
+	var flaky, broken, panics atomic.Int32
+	_ = q.Enqueue("flaky", func(ctx context.Context) error {
+		if flaky.Add(1) < 3 {
+			return errors.New("try again")
+		}
+		return nil
+	})
+	_ = q.Enqueue("broken", func(ctx context.Context) error {
+		broken.Add(1)
+		return errors.New("always fails")
+	})
+	_ = q.Enqueue("panics", func(ctx context.Context) error {
+		if panics.Add(1) == 1 {
+			panic("boom")
+		}
+		return nil
+	})
+
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if flaky.Load() != 3 {
+		t.Errorf("expected flaky job to run 3 times, ran %d", flaky.Load())
+	}
+	if broken.Load() != 3 {
+		t.Errorf("expected broken job to run 1 + 2 retries times, ran %d", broken.Load())
+	}
+	if panics.Load() != 2 {
+		t.Errorf("expected panicking job to be retried once, ran %d", panics.Load())
+	}
+
+	if enqueued != 3 || len(events) != 8 {
+		t.Errorf("wrong hook calls; got %d enqueued and %d finished", enqueued, len(events))
+	}
+	for _, e := range events {
+		if e.Name == "broken" && e.Attempt == 3 && e.Retrying {
+			t.Error("last attempt of broken job should not be retrying")
+		}
+		if e.Name == "panics" && e.Attempt == 1 && (e.Err == nil || e.Err.Error() != "job panicked: boom") {
+			t.Errorf("wrong panic error %v", e.Err)
+		}
+	}
+
+	if err := q.Enqueue("late", func(ctx context.Context) error { return nil }); !errors.Is(err, ErrJobQueueClosed) {
+		t.Errorf("expected ErrJobQueueClosed, got %v", err)
+	}
+}
+
+func TestJobQueue_Full(t *testing.T) {
+	var testTools Tools
+
+	release := make(chan struct{})
+	q := testTools.NewJobQueue(JobQueueOptions{Workers: 1, QueueSize: 1})
+
+	started := make(chan struct{})
+	_ = q.Enqueue("blocker", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	if err := q.Enqueue("queued", func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+	if q.Len() != 1 {
+		t.Errorf("expected 1 waiting job, got %d", q.Len())
+	}
+	if err := q.Enqueue("overflow", func(ctx context.Context) error { return nil }); !errors.Is(err, ErrJobQueueFull) {
+		t.Errorf("expected ErrJobQueueFull, got %v", err)
+	}
+
+	close(release)
+	_ = q.Shutdown(context.Background())
+}
+
+func TestJobQueue_ShutdownTimeout(t *testing.T) {
+	var testTools Tools
+
+	q := testTools.NewJobQueue(JobQueueOptions{Workers: 1})
+
+	cancelled := make(chan struct{})
+	started := make(chan struct{})
+	_ = q.Enqueue("slow", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("running job was not cancelled")
+	}
+}
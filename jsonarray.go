@@ -0,0 +1,109 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errJSONElementTooLarge is returned by an elementLimitReader once the current element is over its limit.
+var errJSONElementTooLarge = errors.New("json array element too large")
+
+// ReadJSONArray decodes a body containing a JSON array one element at a time, calling fn with each
+// element in turn, so bulk imports can be processed without holding the whole payload in memory. Each
+// element is limited to Limits.MaxBody or MaxJSONSize bytes and the whole body to MaxJSONArraySize bytes. Decoding stops
+// at the first error, and an error returned by fn is returned as is; elements before it have already
+// been handled.
+func (t *Tools) ReadJSONArray(w http.ResponseWriter, r *http.Request, fn func(json.RawMessage) error) error {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && strings.ToLower(contentType) != "application/json" {
+		return newMessageError(MsgContentTypeJSON, "")
+	}
+	if err := t.checkHeaders(r); err != nil {
+		return err
+	}
+
+	maxBytes := defaultMaxJSONArraySize
+	if t.MaxJSONArraySize != 0 {
+		maxBytes = t.MaxJSONArraySize
+	}
+	maxElement := t.bodyLimit(t.MaxJSONSize, defaultMaxUpload)
+
+	body, err := t.requestBody(w, r, maxBytes)
+	if err != nil {
+		return err
+	}
+
+	lr := &elementLimitReader{r: body, limit: int64(maxElement)}
+	dec := json.NewDecoder(t.limitJSONDepth(lr, "body"))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return jsonDecodeError("body", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return errors.New("body must contain a JSON array")
+	}
+
+	tooLarge := fmt.Errorf("body contains an array element larger than %d bytes", maxElement)
+	for {
+		lr.start = lr.read
+		if !dec.More() {
+			break
+		}
+
+		var elem json.RawMessage
+		if err := dec.Decode(&elem); err != nil {
+			if errors.Is(err, errJSONElementTooLarge) {
+				return tooLarge
+			}
+			return jsonDecodeError("body", err)
+		}
+		if len(elem) > maxElement {
+			return tooLarge
+		}
+
+		if err := fn(elem); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		if errors.Is(err, errJSONElementTooLarge) {
+			return tooLarge
+		}
+		return jsonDecodeError("body", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return newMessageError(MsgJSONOneValue, "body")
+	}
+
+	return nil
+}
+
+// elementLimitReader stops a json.Decoder from buffering an array element larger than limit. The decoder
+// only reads more input while the value it is decoding is incomplete, so once more than limit bytes have
+// been read since start the element must be too large.
+type elementLimitReader struct {
+	r     io.Reader
+	read  int64 // bytes read so far
+	start int64 // value of read when the current element began
+	limit int64
+}
+
+// Read implements io.Reader.
+func (l *elementLimitReader) Read(p []byte) (int, error) {
+	remaining := l.limit - (l.read - l.start)
+	if remaining < 0 {
+		return 0, errJSONElementTooLarge
+	}
+	// Read at most one byte past the limit, so it is noticed without buffering much more.
+	if int64(len(p)) > remaining+1 {
+		p = p[:remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
+}
@@ -0,0 +1,102 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var readJSONArrayTests = []struct {
+	name          string
+	body          string
+	maxElement    int
+	maxBody       int
+	failAt        int
+	expected      []string
+	errorExpected string
+}{
+	{name: "objects", body: `[{"id":1}, {"id":2},{"id":3}]`, expected: []string{`{"id":1}`, `{"id":2}`, `{"id":3}`}},
+	{name: "mixed values", body: " [1, \"two\", null, [3]] \n", expected: []string{`1`, `"two"`, `null`, `[3]`}},
+	{name: "empty array", body: `[]`},
+	{name: "empty body", body: ``, errorExpected: "body must not be empty"},
+	{name: "not an array", body: `{"id":1}`, errorExpected: "body must contain a JSON array"},
+	{name: "element too large", body: `[{"id":1},{"name":"a long name"},{"id":3}]`, maxElement: 12, expected: []string{`{"id":1}`}, errorExpected: "body contains an array element larger than 12 bytes"},
+	{name: "large element within limit", body: `[{"name":"a long name"}]`, maxElement: 22, expected: []string{`{"name":"a long name"}`}},
+	{name: "badly formed element", body: `[{"id":1},{"id":}]`, expected: []string{`{"id":1}`}, errorExpected: "body contains badly-formed JSON (at character 17)"},
+	{name: "unterminated", body: `[{"id":1}`, expected: []string{`{"id":1}`}, errorExpected: "body contains badly-formed JSON (at character 9)"},
+	{name: "trailing value", body: `[1] [2]`, expected: []string{`1`}, errorExpected: "body must contain only one JSON value"},
+	{name: "body too large", body: `[1,2,3,4,5,6,7,8,9]`, maxBody: 8, expected: []string{`1`, `2`, `3`}, errorExpected: "body must not be larger than 8 bytes"},
+	{name: "handler error", body: `[1,2,3]`, failAt: 2, expected: []string{`1`, `2`}, errorExpected: "stop"},
+}
+
+func TestTools_ReadJSONArray(t *testing.T) {
+	for _, e := range readJSONArrayTests {
+		testTools := Tools{MaxJSONSize: e.maxElement, MaxJSONArraySize: e.maxBody}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+
+		var got []string
+		err := testTools.ReadJSONArray(rr, req, func(elem json.RawMessage) error {
+			got = append(got, string(elem))
+			if len(got) == e.failAt {
+				return errors.New("stop")
+			}
+			return nil
+		})
+
+		switch {
+		case e.errorExpected == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		case e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected):
+			t.Errorf("%s: expected error %q but got %v", e.name, e.errorExpected, err)
+		}
+
+		if strings.Join(got, "|") != strings.Join(e.expected, "|") {
+			t.Errorf("%s: expected elements %v but got %v", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestTools_ReadJSONArrayStreams(t *testing.T) {
+	// a body far larger than the per-element limit is fine, as long as each element fits
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := 0; i < 10000; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(`{"id":1234567890}`)
+	}
+	sb.WriteString("]")
+
+	testTools := Tools{MaxJSONSize: 32}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(sb.String()))
+
+	count := 0
+	err := testTools.ReadJSONArray(httptest.NewRecorder(), req, func(elem json.RawMessage) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if count != 10000 {
+		t.Errorf("expected 10000 elements but got %d", count)
+	}
+}
+
+func TestTools_ReadJSONArrayContentType(t *testing.T) {
+	var testTools Tools
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[]`))
+	req.Header.Set("Content-Type", "text/plain")
+
+	err := testTools.ReadJSONArray(httptest.NewRecorder(), req, func(json.RawMessage) error { return nil })
+	if err == nil {
+		t.Error("expected error for wrong content type")
+	}
+}
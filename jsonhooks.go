@@ -0,0 +1,321 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// JSONMarshalFunc converts a value of a registered type into the value which WriteJSON writes in its
+// place. For example, a func returning v.(time.Duration).String() renders durations as "5s".
+type JSONMarshalFunc func(v any) (any, error)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// RegisterJSONMarshaler registers fn as the way to render values with the same type as sample when they
+// are written by WriteJSON, wherever they appear in the data. This makes it possible to change how types
+// you don't own are rendered, without wrapping them or implementing MarshalJSON. Register marshalers when
+// setting up Tools, before it is used to write responses.
+func (t *Tools) RegisterJSONMarshaler(sample any, fn JSONMarshalFunc) {
+	if t.jsonMarshalers == nil {
+		t.jsonMarshalers = make(map[reflect.Type]JSONMarshalFunc)
+	}
+	t.jsonMarshalers[reflect.TypeOf(sample)] = fn
+}
+
+// applyJSONMarshalers returns data with every value of a registered type replaced by the result of its
+// JSONMarshalFunc. Structs are converted to objects which keep their field order and follow the usual
+// json tag rules. If no marshalers are registered and JSONOptions leaves times alone, data is returned
+// untouched.
+func (t *Tools) applyJSONMarshalers(data any) (any, error) {
+	if len(t.jsonMarshalers) == 0 && !t.JSONOptions.formatsTimes() {
+		return data, nil
+	}
+	return t.convertJSONValue(reflect.ValueOf(data))
+}
+
+func (t *Tools) convertJSONValue(v reflect.Value) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	if fn, ok := t.jsonMarshaler(v.Type()); ok {
+		return fn(v.Interface())
+	}
+
+	// Pointers to types with a marshaler are followed, even if the pointer type implements json.Marshaler.
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		if _, ok := t.jsonMarshaler(v.Type().Elem()); ok {
+			return t.convertJSONValue(v.Elem())
+		}
+	}
+
+	// Types which already know how to render themselves are left alone, including, as encoding/json does,
+	// addressable values, such as slice elements, whose pointer has the method.
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface(), nil
+	}
+	if v.CanAddr() && (v.Addr().Type().Implements(jsonMarshalerType) || v.Addr().Type().Implements(textMarshalerType)) {
+		return v.Addr().Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return t.convertJSONValue(v.Elem())
+
+	case reflect.Struct:
+		return t.convertJSONStruct(v)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := jsonMapKey(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			val, err := t.convertJSONValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			out[key] = val
+		}
+		return out, nil
+
+	case reflect.Slice, reflect.Array:
+		// byte slices are encoded as base64 strings, so they are left alone
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface(), nil
+		}
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			val, err := t.convertJSONValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = val
+		}
+		return out, nil
+
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// convertJSONStruct converts a struct into a jsonObject, following the json tag rules for names, "-",
+// omitempty and string, and flattening embedded structs.
+func (t *Tools) convertJSONStruct(v reflect.Value) (any, error) {
+	var obj jsonObject
+	for _, f := range jsonStructFields(v.Type()) {
+		fv, ok := jsonFieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+		if strings.Contains(","+f.opts+",", ",omitempty,") && isEmptyJSONValue(fv) {
+			continue
+		}
+
+		val, err := t.convertJSONValue(fv)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(","+f.opts+",", ",string,") {
+			switch fv.Kind() {
+			case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+				reflect.Float32, reflect.Float64, reflect.String:
+				b, err := json.Marshal(val)
+				if err != nil {
+					return nil, err
+				}
+				val = string(b)
+			}
+		}
+
+		obj = append(obj, jsonField{name: f.name, value: val})
+	}
+	return obj, nil
+}
+
+// jsonStructField is a field of a struct, or of a struct embedded in it, which is encoded.
+type jsonStructField struct {
+	name   string
+	index  []int // as for reflect.Value.FieldByIndex
+	tagged bool  // the name comes from a json tag
+	opts   string
+}
+
+// jsonStructFields returns the fields of typ which are encoded, in order. Fields of embedded structs are
+// promoted, and where several share a name, the one encoding/json would pick wins: the shallowest, or at the
+// same depth the only one with a json tag; if there's still more than one, none is encoded.
+func jsonStructFields(typ reflect.Type) []jsonStructField {
+	var fields []jsonStructField
+	// an embedded struct which is already being walked, through a pointer to its own type, isn't walked again
+	walking := map[reflect.Type]bool{typ: true}
+
+	var walk func(typ reflect.Type, index []int)
+	walk = func(typ reflect.Type, index []int) {
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			fieldIndex := append(append([]int(nil), index...), i)
+
+			// Embedded structs without a name of their own have their fields promoted.
+			if field.Anonymous && name == "" {
+				ft := field.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					if !walking[ft] {
+						walking[ft] = true
+						walk(ft, fieldIndex)
+						delete(walking, ft)
+					}
+					continue
+				}
+			}
+
+			if !field.IsExported() {
+				continue
+			}
+			f := jsonStructField{name: name, index: fieldIndex, tagged: name != "", opts: opts}
+			if f.name == "" {
+				f.name = field.Name
+			}
+			fields = append(fields, f)
+		}
+	}
+	walk(typ, nil)
+
+	type contender struct {
+		depth, count, tagged, field int
+	}
+	names := make(map[string]*contender, len(fields))
+	for i, f := range fields {
+		c, ok := names[f.name]
+		if !ok || len(f.index) < c.depth {
+			c = &contender{depth: len(f.index), field: -1}
+			names[f.name] = c
+		}
+		if len(f.index) > c.depth {
+			continue
+		}
+		c.count++
+		if f.tagged {
+			c.tagged++
+		}
+		if c.count == 1 || (f.tagged && c.tagged == 1) {
+			c.field = i
+		}
+	}
+
+	var out []jsonStructField
+	for i, f := range fields {
+		// two fields at the same depth, neither or both tagged, leave the name ambiguous, and out
+		if c := names[f.name]; c.field == i && (c.count == 1 || c.tagged == 1) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// jsonFieldByIndex returns the field of v at index, reporting false if it is in an embedded struct reached
+// through a nil pointer.
+func jsonFieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// jsonMapKey returns the string form of a map key, as encoding/json would.
+func jsonMapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fmt.Sprint(k.Interface()), nil
+	}
+	return "", fmt.Errorf("json: unsupported map key type %s", k.Type())
+}
+
+// isEmptyJSONValue reports whether v is empty in the sense of the omitempty option.
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// jsonField is a single name and value in a jsonObject.
+type jsonField struct {
+	name  string
+	value any
+}
+
+// jsonObject is a JSON object which, unlike a map, keeps its fields in order.
+type jsonObject []jsonField
+
+// MarshalJSON writes the fields of the object in order. HTML characters are left unescaped, so the
+// encoder writing the object decides whether to escape them.
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := enc.Encode(f.name); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+		buf.WriteByte(':')
+		if err := enc.Encode(f.value); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,163 @@
+package toolkit
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testUUID [4]byte
+
+type testBase struct {
+	ID testUUID `json:"id"`
+}
+
+type testJob struct {
+	testBase
+	Name     string            `json:"name"`
+	Timeout  time.Duration     `json:"timeout"`
+	Retry    *time.Duration    `json:"retry,omitempty"`
+	Parent   testUUID          `json:"parent"`
+	Count    int               `json:"count,string"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Steps    []time.Duration   `json:"steps"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+var jsonMarshalerTests = []struct {
+	name     string
+	data     any
+	expected string
+}{
+	{
+		name:     "struct",
+		data:     testJob{testBase: testBase{ID: testUUID{1, 2, 3, 4}}, Name: "resize", Timeout: 5 * time.Second, Count: 3, Steps: []time.Duration{time.Minute}, Ignored: "x", internal: "y"},
+		expected: `{"id":"01020304","name":"resize","timeout":"5s","parent":null,"count":"3","steps":["1m0s"]}`,
+	},
+	{
+		name:     "pointer and map",
+		data:     map[string]any{"job": &testJob{Retry: durationPtr(time.Hour)}},
+		expected: `{"job":{"id":null,"name":"","timeout":"0s","retry":"1h0m0s","parent":null,"count":"0","steps":null}}`,
+	},
+	{
+		name:     "outer field shadows an embedded one",
+		data:     testOuter{testBase: testBase{ID: testUUID{0, 0, 0, 1}}, ID: testUUID{0, 0, 0, 2}, Name: "outer"},
+		expected: `{"id":"00000002","Name":"outer"}`,
+	},
+	{
+		name: "tagged field wins at the same depth",
+		data: struct {
+			testLabel
+			testNamed
+		}{testLabel{Name: "untagged"}, testNamed{Title: "tagged"}},
+		expected: `{"Name":"tagged"}`,
+	},
+	{
+		name: "ambiguous fields are left out",
+		data: struct {
+			testLabel
+			testUntagged
+		}{testLabel{Name: "a"}, testUntagged{Name: "b"}},
+		expected: `{}`,
+	},
+	{
+		name:     "embedded pointer cycle",
+		data:     testLoop{testLoop: &testLoop{Next: "inner"}, Next: "outer"},
+		expected: `{"next":"outer"}`,
+	},
+	{
+		name:     "pointer receiver marshaler in a slice",
+		data:     []testPtrMarshaler{{A: 1}},
+		expected: `["custom"]`,
+	},
+	{
+		name: "pointer receiver marshaler in a field",
+		data: &struct {
+			F testPtrMarshaler
+		}{F: testPtrMarshaler{A: 1}},
+		expected: `{"F":"custom"}`,
+	},
+	{
+		name:     "envelope",
+		data:     JSONResponse{Message: "ok", Data: time.Second},
+		expected: `{"error":false,"message":"ok","data":"1s"}`,
+	},
+}
+
+type testOuter struct {
+	testBase
+	ID   testUUID `json:"id"`
+	Name string
+}
+
+type testLabel struct {
+	Name string
+}
+
+type testUntagged struct {
+	Name string
+}
+
+type testNamed struct {
+	Title string `json:"Name"`
+}
+
+// testPtrMarshaler implements json.Marshaler on its pointer only.
+type testPtrMarshaler struct {
+	A int
+}
+
+func (*testPtrMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"custom"`), nil
+}
+
+type testLoop struct {
+	*testLoop
+	Next string `json:"next"`
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
+func TestTools_RegisterJSONMarshaler(t *testing.T) {
+	var testTools Tools
+	testTools.RegisterJSONMarshaler(time.Duration(0), func(v any) (any, error) {
+		return v.(time.Duration).String(), nil
+	})
+	testTools.RegisterJSONMarshaler(testUUID{}, func(v any) (any, error) {
+		u := v.(testUUID)
+		if u == (testUUID{}) {
+			return nil, nil
+		}
+		return hex.EncodeToString(u[:]), nil
+	})
+
+	for _, e := range jsonMarshalerTests {
+		rr := httptest.NewRecorder()
+		err := testTools.WriteJSON(rr, http.StatusOK, e.data)
+		if err != nil {
+			t.Errorf("%s: failed to write JSON: %s", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: wrong JSON written;\nexpected %s\n but got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_RegisterJSONMarshaler_Error(t *testing.T) {
+	var testTools Tools
+	testTools.RegisterJSONMarshaler(time.Duration(0), func(v any) (any, error) {
+		return nil, errors.New("cannot render duration")
+	})
+
+	rr := httptest.NewRecorder()
+	if err := testTools.WriteJSON(rr, http.StatusOK, []time.Duration{time.Second}); err == nil {
+		t.Error("expected an error from the marshaler, but none received")
+	}
+}
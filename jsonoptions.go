@@ -0,0 +1,137 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// JSONOptions controls how the toolkit encodes JSON. The zero value matches encoding/json.
+type JSONOptions struct {
+	Indent              string // indent used to pretty-print responses and pushed payloads (e.g. "  "); empty means compact
+	DisableHTMLEscaping bool   // write <, > and & as they are, rather than as \u003c, \u003e and \u0026
+	TimeFormat          string // layout used for time.Time values (e.g. time.RFC3339); empty means RFC 3339 with nanoseconds
+	TimeUTC             bool   // convert time.Time values to UTC before formatting them
+	OmitNull            bool   // leave out object fields whose value is null
+	Stream              bool   // have WriteJSON encode straight to the ResponseWriter, skipping the intermediate buffer; ignored with OmitNull
+}
+
+// formatsTimes reports whether time.Time values need converting.
+func (o JSONOptions) formatsTimes() bool {
+	return o.TimeFormat != "" || o.TimeUTC
+}
+
+// formatTime is the JSONMarshalFunc used for time.Time values when TimeFormat or TimeUTC are set.
+func (o JSONOptions) formatTime(v any) (any, error) {
+	tm := v.(time.Time)
+	if o.TimeUTC {
+		tm = tm.UTC()
+	}
+	layout := o.TimeFormat
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return tm.Format(layout), nil
+}
+
+// jsonMarshaler returns the JSONMarshalFunc for values of type typ: a registered marshaler if there is
+// one, or the time formatter for time.Time values if the JSONOptions call for it.
+func (t *Tools) jsonMarshaler(typ reflect.Type) (JSONMarshalFunc, bool) {
+	if fn, ok := t.jsonMarshalers[typ]; ok {
+		return fn, true
+	}
+	if typ == timeType && t.JSONOptions.formatsTimes() {
+		return t.JSONOptions.formatTime, true
+	}
+	return nil, false
+}
+
+// indentJSON returns the encoded JSON in src indented according to JSONOptions, or src itself if no
+// indent is set.
+func (t *Tools) indentJSON(src []byte) ([]byte, error) {
+	if t.JSONOptions.Indent == "" {
+		return src, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, src, "", t.JSONOptions.Indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// omitJSONNulls writes the encoded value raw to out, leaving out any object fields which are null.
+func omitJSONNulls(out *bytes.Buffer, raw []byte, escapeHTML bool) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || (raw[0] != '{' && raw[0] != '[') {
+		out.Write(raw)
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	if raw[0] == '[' {
+		out.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			var elem json.RawMessage
+			if err := dec.Decode(&elem); err != nil {
+				return err
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := omitJSONNulls(out, elem, escapeHTML); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+		return nil
+	}
+
+	out.WriteByte('{')
+	first := true
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		if string(value) == "null" {
+			continue
+		}
+
+		if !first {
+			out.WriteByte(',')
+		}
+		first = false
+		if err := writeJSONKey(out, key, escapeHTML); err != nil {
+			return err
+		}
+		if err := omitJSONNulls(out, value, escapeHTML); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+// writeJSONKey writes key as a JSON string followed by a colon.
+func writeJSONKey(out *bytes.Buffer, key string, escapeHTML bool) error {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(escapeHTML)
+	if err := enc.Encode(key); err != nil {
+		return err
+	}
+	// Encode adds a trailing newline.
+	out.Truncate(out.Len() - 1)
+	out.WriteByte(':')
+	return nil
+}
@@ -0,0 +1,148 @@
+package toolkit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type jsonOptionsPayload struct {
+	Name    string     `json:"name"`
+	Note    *string    `json:"note"`
+	Created time.Time  `json:"created"`
+	Tags    []string   `json:"tags"`
+	Parent  *time.Time `json:"parent"`
+}
+
+var jsonOptionsCreated = time.Date(2024, 3, 1, 12, 30, 0, 500, time.FixedZone("EST", -5*3600))
+
+var jsonOptionsTests = []struct {
+	name     string
+	options  JSONOptions
+	data     any
+	expected string
+}{
+	{name: "defaults", data: jsonOptionsPayload{Name: "<b>", Created: jsonOptionsCreated},
+		expected: `{"name":"\u003cb\u003e","note":null,"created":"2024-03-01T12:30:00.0000005-05:00","tags":null,"parent":null}`},
+	{name: "html escaping off", options: JSONOptions{DisableHTMLEscaping: true}, data: map[string]string{"a&b": "<b>"},
+		expected: `{"a&b":"<b>"}`},
+	{name: "time format", options: JSONOptions{TimeFormat: time.RFC3339}, data: jsonOptionsPayload{Created: jsonOptionsCreated, Parent: &jsonOptionsCreated},
+		expected: `{"name":"","note":null,"created":"2024-03-01T12:30:00-05:00","tags":null,"parent":"2024-03-01T12:30:00-05:00"}`},
+	{name: "utc", options: JSONOptions{TimeUTC: true}, data: []time.Time{jsonOptionsCreated},
+		expected: `["2024-03-01T17:30:00.0000005Z"]`},
+	{name: "omit null", options: JSONOptions{OmitNull: true}, data: []any{jsonOptionsPayload{Name: "x", Tags: []string{}}, nil},
+		expected: `[{"name":"x","created":"0001-01-01T00:00:00Z","tags":[]},null]`},
+	{name: "omit null keeps escaping", options: JSONOptions{OmitNull: true}, data: map[string]any{"<k>": nil, "<v>": map[string]any{"x": nil}},
+		expected: `{"\u003cv\u003e":{}}`},
+	{name: "indent", options: JSONOptions{Indent: "  "}, data: map[string]int{"a": 1},
+		expected: "{\n  \"a\": 1\n}"},
+	{name: "combined", options: JSONOptions{Indent: "\t", DisableHTMLEscaping: true, TimeFormat: time.DateOnly, OmitNull: true}, data: jsonOptionsPayload{Name: "<b>", Created: jsonOptionsCreated},
+		expected: "{\n\t\"name\": \"<b>\",\n\t\"created\": \"2024-03-01\"\n}"},
+}
+
+func TestTools_JSONOptions(t *testing.T) {
+	for _, e := range jsonOptionsTests {
+		testTools := Tools{JSONOptions: e.options}
+
+		rr := httptest.NewRecorder()
+		if err := testTools.WriteJSON(rr, http.StatusOK, e.data); err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: wrong body;\nexpected %s\n but got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_JSONOptionsStream(t *testing.T) {
+	for _, e := range jsonOptionsTests {
+		e.options.Stream = true
+		testTools := Tools{JSONOptions: e.options}
+
+		rr := httptest.NewRecorder()
+		if err := testTools.WriteJSON(rr, http.StatusCreated, e.data, http.Header{"X-Test": {"1"}}); err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		expected := e.expected
+		if !e.options.OmitNull {
+			// the encoder ends the body with a newline
+			expected += "\n"
+		}
+		if rr.Body.String() != expected {
+			t.Errorf("%s: wrong body;\nexpected %q\n but got %q", e.name, expected, rr.Body.String())
+		}
+		if rr.Code != http.StatusCreated || rr.Header().Get("Content-Type") != "application/json" || rr.Header().Get("X-Test") != "1" {
+			t.Errorf("%s: wrong status or headers: %d %v", e.name, rr.Code, rr.Header())
+		}
+	}
+}
+
+func TestTools_JSONOptionsStreamError(t *testing.T) {
+	testTools := Tools{JSONOptions: JSONOptions{Stream: true}}
+
+	rr := httptest.NewRecorder()
+	if err := testTools.WriteJSON(rr, http.StatusOK, map[string]any{"f": func() {}}, http.Header{"X-Test": {"1"}}); err == nil {
+		t.Fatal("expected an error for a value which can't be encoded")
+	}
+	if rr.Code != http.StatusInternalServerError || rr.Body.String() != `{"error":true,"message":"Internal Server Error"}` {
+		t.Errorf("expected the fallback error response, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Test") != "" {
+		t.Error("expected the response's own headers not to be sent with the fallback")
+	}
+}
+
+func TestTools_JSONOptionsWithMarshaler(t *testing.T) {
+	testTools := Tools{JSONOptions: JSONOptions{TimeFormat: time.RFC3339}}
+	testTools.RegisterJSONMarshaler(time.Time{}, func(v any) (any, error) {
+		return v.(time.Time).Unix(), nil
+	})
+
+	rr := httptest.NewRecorder()
+	_ = testTools.WriteJSON(rr, http.StatusOK, []time.Time{time.Unix(100, 0)})
+	if rr.Body.String() != `[100]` {
+		t.Errorf("expected registered marshaler to win over TimeFormat, got %s", rr.Body.String())
+	}
+}
+
+func TestTools_JSONOptionsErrorJSON(t *testing.T) {
+	testTools := Tools{JSONOptions: JSONOptions{Indent: " ", DisableHTMLEscaping: true, OmitNull: true}}
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, errors.New("a < b"), http.StatusBadRequest)
+
+	expected := "{\n \"error\": true,\n \"message\": \"a < b\"\n}"
+	if rr.Body.String() != expected {
+		t.Errorf("wrong body;\nexpected %s\n but got %s", expected, rr.Body.String())
+	}
+}
+
+func TestTools_JSONOptionsPushJSONToRemote(t *testing.T) {
+	var body []byte
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		body, _ = io.ReadAll(req.Body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString("ok")),
+			Header:     make(http.Header),
+		}
+	})
+
+	testTools := Tools{JSONOptions: JSONOptions{Indent: "  ", TimeUTC: true, OmitNull: true}}
+	data := jsonOptionsPayload{Name: "x", Created: jsonOptionsCreated}
+
+	if _, _, err := testTools.PushJSONToRemote("http://example.com/some/path", data, client); err != nil {
+		t.Fatalf("failed to call remote url: %s", err)
+	}
+
+	expected := "{\n  \"name\": \"x\",\n  \"created\": \"2024-03-01T17:30:00.0000005Z\"\n}"
+	if string(body) != expected {
+		t.Errorf("wrong body;\nexpected %s\n but got %s", expected, body)
+	}
+}
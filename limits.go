@@ -0,0 +1,135 @@
+package toolkit
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// Limits bounds the work done parsing a request, whatever its format, so the same limits apply to ReadJSON,
+// ReadJSONArray, ReadXML, ReadForm, UploadFiles and WebSocket messages. Each field left at zero falls back
+// to the older field for that format, such as MaxJSONSize or MaxXMLDepth, and then to its default, so
+// existing configurations keep working.
+type Limits struct {
+	MaxBody           int // maximum size of a request body (of an element, for ReadJSONArray) or WebSocket message, in bytes
+	MaxMultipartParts int // maximum number of parts (files and fields) in a multipart form
+	MaxHeaders        int // maximum number of request header values; 0 means no limit
+	MaxDepth          int // maximum nesting depth of a JSON or XML document; for JSON, 0 means no limit beyond encoding/json's own
+}
+
+// bodyLimit returns Limits.MaxBody if it is set, or else legacy, the limit for the format, or def.
+func (t *Tools) bodyLimit(legacy, def int) int {
+	switch {
+	case t.Limits.MaxBody > 0:
+		return t.Limits.MaxBody
+	case legacy != 0:
+		return legacy
+	}
+	return def
+}
+
+// multipartPartsLimit returns the maximum number of parts in a multipart form, or 0 for no limit.
+func (t *Tools) multipartPartsLimit() int {
+	if t.Limits.MaxMultipartParts > 0 {
+		return t.Limits.MaxMultipartParts
+	}
+	return t.MaxMultipartParts
+}
+
+// xmlDepthLimit returns the maximum nesting depth of XML elements, or 0 for no limit.
+func (t *Tools) xmlDepthLimit() int {
+	if t.Limits.MaxDepth > 0 {
+		return t.Limits.MaxDepth
+	}
+	return t.MaxXMLDepth
+}
+
+// partsLimitError is the error for a multipart form with more parts than the limit.
+type partsLimitError struct {
+	limit int
+}
+
+// Error implements error.
+func (e *partsLimitError) Error() string {
+	return fmt.Sprintf("multipart form must not contain more than %d parts", e.limit)
+}
+
+// checkMultipartParts returns an error if form has more parts than the limit, removing any files it holds.
+// It catches what limitMultipartBody lets through while the parser is ahead of it.
+func (t *Tools) checkMultipartParts(form *multipart.Form) error {
+	limit := t.multipartPartsLimit()
+	if limit <= 0 || countMultipartParts(form) <= limit {
+		return nil
+	}
+	_ = form.RemoveAll()
+	return &partsLimitError{limit: limit}
+}
+
+// checkHeaders returns an error if r has more header values than Limits.MaxHeaders.
+func (t *Tools) checkHeaders(r *http.Request) error {
+	if t.Limits.MaxHeaders <= 0 {
+		return nil
+	}
+	n := 0
+	for _, vals := range r.Header {
+		n += len(vals)
+	}
+	if n > t.Limits.MaxHeaders {
+		return newMessageError(MsgTooManyHeaders, "", t.Limits.MaxHeaders)
+	}
+	return nil
+}
+
+// limitJSONDepth returns r, wrapped to fail once the JSON it holds nests objects and arrays more than
+// Limits.MaxDepth deep. The error describes the document as subject (e.g. "body").
+func (t *Tools) limitJSONDepth(r io.Reader, subject string) io.Reader {
+	if t.Limits.MaxDepth <= 0 {
+		return r
+	}
+	return &jsonDepthReader{r: r, max: t.Limits.MaxDepth, subject: subject}
+}
+
+// jsonDepthReader tracks the nesting depth of the JSON passing through it, so a deeply nested document is
+// rejected before the decoder recurses into it.
+type jsonDepthReader struct {
+	r        io.Reader
+	max      int
+	subject  string
+	depth    int
+	inString bool
+	escaped  bool
+	err      error // set once the document is too deep, and returned by every later Read
+}
+
+// Read implements io.Reader.
+func (d *jsonDepthReader) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	n, err := d.r.Read(p)
+	for i, c := range p[:n] {
+		switch {
+		case d.escaped:
+			d.escaped = false
+		case d.inString:
+			switch c {
+			case '\\':
+				d.escaped = true
+			case '"':
+				d.inString = false
+			}
+		case c == '"':
+			d.inString = true
+		case c == '{' || c == '[':
+			d.depth++
+			if d.depth > d.max {
+				d.err = newMessageError(MsgJSONDepth, d.subject, d.max)
+				return i, d.err
+			}
+		case c == '}' || c == ']':
+			d.depth--
+		}
+	}
+	return n, err
+}
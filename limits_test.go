@@ -0,0 +1,158 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTools_bodyLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		tools    Tools
+		legacy   int
+		expected int
+	}{
+		{name: "default", expected: 100},
+		{name: "legacy", legacy: 50, expected: 50},
+		{name: "limits over legacy", tools: Tools{Limits: Limits{MaxBody: 10}}, legacy: 50, expected: 10},
+		{name: "limits over default", tools: Tools{Limits: Limits{MaxBody: 10}}, expected: 10},
+	}
+
+	for _, e := range tests {
+		if got := e.tools.bodyLimit(e.legacy, 100); got != e.expected {
+			t.Errorf("%s: expected %d, got %d", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestTools_ReadJSON_Limits(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        Limits
+		maxJSONSize   int
+		json          string
+		headers       int
+		errorExpected string
+	}{
+		{name: "no limits", json: `{"a": [[[1]]]}`},
+		{name: "body within limit", limits: Limits{MaxBody: 20}, json: `{"a": 1}`},
+		{name: "body too large", limits: Limits{MaxBody: 5}, json: `{"a": 1}`, errorExpected: "body must not be larger than 5 bytes"},
+		{name: "limits override MaxJSONSize", limits: Limits{MaxBody: 5}, maxJSONSize: 100, json: `{"a": 1}`, errorExpected: "body must not be larger than 5 bytes"},
+		{name: "depth within limit", limits: Limits{MaxDepth: 3}, json: `{"a": [[1]]}`},
+		{name: "too deep", limits: Limits{MaxDepth: 3}, json: `{"a": [[[1]]]}`, errorExpected: "body must not nest JSON more than 3 deep"},
+		{name: "brackets in strings", limits: Limits{MaxDepth: 1}, json: `{"a": "[[[{\"[\"}]]]"}`},
+		{name: "headers within limit", limits: Limits{MaxHeaders: 3}, json: `{"a": 1}`, headers: 2},
+		{name: "too many headers", limits: Limits{MaxHeaders: 3}, json: `{"a": 1}`, headers: 3, errorExpected: "request must not have more than 3 headers"},
+	}
+
+	for _, e := range tests {
+		testTools := Tools{Limits: e.limits, MaxJSONSize: e.maxJSONSize}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i < e.headers; i++ {
+			req.Header.Add("X-Extra", "value")
+		}
+
+		var data struct {
+			A any `json:"a"`
+		}
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &data)
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
+
+func TestTools_ReadJSONArray_LimitsDepth(t *testing.T) {
+	testTools := Tools{Limits: Limits{MaxDepth: 2}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"a": 1}, {"a": {"b": 2}}]`))
+
+	count := 0
+	err := testTools.ReadJSONArray(httptest.NewRecorder(), req, func(json.RawMessage) error {
+		count++
+		return nil
+	})
+	if err == nil || err.Error() != "body must not nest JSON more than 2 deep" {
+		t.Errorf("expected depth error, got %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 element before the error, got %d", count)
+	}
+}
+
+func TestTools_ReadXML_LimitsDepth(t *testing.T) {
+	testTools := Tools{MaxXMLDepth: 10, Limits: Limits{MaxDepth: 2}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<note><to><b>Ann</b></to></note>`))
+
+	var data struct {
+		To string `xml:"to"`
+	}
+	err := testTools.ReadXML(httptest.NewRecorder(), req, &data)
+	if err == nil || err.Error() != "body must not nest XML elements more than 2 deep" {
+		t.Errorf("expected depth error, got %v", err)
+	}
+}
+
+func TestTools_ReadForm_LimitsMultipartParts(t *testing.T) {
+	testTools := Tools{Limits: Limits{MaxMultipartParts: 1}}
+	body, contentType := testTools.BuildMultipartBody(nil, map[string]string{"name": "Ann", "city": "Kyiv"})
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", contentType)
+
+	var data struct {
+		Name string `form:"name"`
+		City string `form:"city"`
+	}
+	err := testTools.ReadForm(httptest.NewRecorder(), req, &data)
+	if err == nil || err.Error() != "multipart form must not contain more than 1 parts" {
+		t.Errorf("expected parts error, got %v", err)
+	}
+}
+
+func TestTools_MultipartParts_StopsParsing(t *testing.T) {
+	for _, upload := range []bool{true, false} {
+		testTools := Tools{Limits: Limits{MaxMultipartParts: 2}}
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		for i := 0; i < 500; i++ {
+			_ = mw.WriteField(fmt.Sprintf("field%d", i), strings.Repeat("a", 4096))
+		}
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		var err error
+		if upload {
+			_, err = testTools.UploadFiles(req, t.TempDir())
+		} else {
+			err = testTools.ReadForm(httptest.NewRecorder(), req, &struct{}{})
+		}
+		if err == nil || err.Error() != "multipart form must not contain more than 2 parts" {
+			t.Errorf("upload %t: expected parts error, got %v", upload, err)
+		}
+		// parsing stopped at the limit, rather than reading the whole body
+		if body.Len() == 0 {
+			t.Errorf("upload %t: expected the body not to be read to the end", upload)
+		}
+	}
+}
+
+func TestTools_UploadFiles_LimitsMaxBody(t *testing.T) {
+	testTools := Tools{Limits: Limits{MaxBody: 100}}
+	body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+
+	if _, err := testTools.UploadFiles(req, "./testdata/uploads/"); err == nil {
+		t.Error("expected an error for a body over Limits.MaxBody, but none received")
+	}
+}
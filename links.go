@@ -0,0 +1,200 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Link is a HAL link object, as written in _links.
+type Link struct {
+	Href      string `json:"href"`
+	Title     string `json:"title,omitempty"`
+	Templated bool   `json:"templated,omitempty"` // true if Href is a URI template, e.g. /books{?q}
+}
+
+// Links are the hypermedia links WriteJSONWithLinks adds to a response, as a HAL _links object. Empty links
+// are left out.
+type Links struct {
+	Self   string          // the URL of the resource itself
+	Next   string          // the URL of the next page, if any
+	Prev   string          // the URL of the previous page, if any
+	Custom map[string]Link // any other relations, by name, e.g. "author"
+}
+
+// MarshalJSON writes the links as a HAL _links object: self, next and prev first, then the others sorted
+// by name. A custom link named self, next or prev takes the place of the field.
+func (l Links) MarshalJSON() ([]byte, error) {
+	rels := []string{"self", "next", "prev"}
+	links := map[string]Link{"self": {Href: l.Self}, "next": {Href: l.Next}, "prev": {Href: l.Prev}}
+	custom := make([]string, 0, len(l.Custom))
+	for rel, link := range l.Custom {
+		if _, std := links[rel]; !std {
+			custom = append(custom, rel)
+		}
+		links[rel] = link
+	}
+	sort.Strings(custom)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, rel := range append(rels, custom...) {
+		if links[rel].Href == "" {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(rel)
+		value, err := json.Marshal(links[rel])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// WriteJSONWithLinks writes data like WriteJSON, with links added to it as a HAL _links object. If data
+// is written as a JSON object, _links is added as its first field; anything else, such as a slice, is
+// wrapped in an object as its data field.
+func (t *Tools) WriteJSONWithLinks(w http.ResponseWriter, status int, data interface{}, links Links, headers ...http.Header) error {
+	buf, err := t.encodeJSON(data)
+	if err != nil {
+		t.writeJSONFailure(w, err)
+		return err
+	}
+	defer putBuffer(buf)
+
+	linksBuf, err := t.encodeJSON(links)
+	if err != nil {
+		t.writeJSONFailure(w, err)
+		return err
+	}
+	defer putBuffer(linksBuf)
+
+	out := getBuffer()
+	defer putBuffer(out)
+
+	out.WriteString(`{"_links":`)
+	out.Write(linksBuf.Bytes())
+	body := bytes.TrimSpace(buf.Bytes())
+	switch {
+	case bytes.HasPrefix(body, []byte("{")):
+		if rest := bytes.TrimSpace(body[1:]); !bytes.HasPrefix(rest, []byte("}")) {
+			out.WriteByte(',')
+			out.Write(rest)
+		} else {
+			out.WriteByte('}')
+		}
+	default:
+		out.WriteString(`,"data":`)
+		out.Write(body)
+		out.WriteByte('}')
+	}
+
+	return t.writeJSONBody(w, status, out.Bytes(), headers...)
+}
+
+// LinkBuilder builds absolute URLs for the links of a response from the request being answered. The
+// scheme and host are those the client used: behind one of the TrustedProxies, they are taken from the
+// Forwarded or X-Forwarded-Proto and X-Forwarded-Host headers.
+type LinkBuilder struct {
+	request *url.URL
+}
+
+// NewLinkBuilder returns a LinkBuilder for URLs relative to r.
+func (t *Tools) NewLinkBuilder(r *http.Request) *LinkBuilder {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if isTrustedProxy(remoteIP(r), parseTrustedProxies(t.TrustedProxies)) {
+		proto, fwdHost := forwardedProtoHost(r)
+		if proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwdHost != "" {
+			host = fwdHost
+		}
+	}
+
+	u := *r.URL
+	u.Scheme, u.Host, u.User, u.Fragment = scheme, host, nil, ""
+	return &LinkBuilder{request: &u}
+}
+
+// forwardedProtoHost returns the scheme and host the nearest proxy received a request on, from the first
+// Forwarded header (RFC 7239), or else X-Forwarded-Proto and X-Forwarded-Host.
+func forwardedProtoHost(r *http.Request) (proto, host string) {
+	if fwd := r.Header.Get("Forwarded"); fwd != "" {
+		element, _, _ := strings.Cut(fwd, ",")
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(key) {
+			case "proto":
+				proto = strings.ToLower(strings.Trim(value, `"`))
+			case "host":
+				host = strings.Trim(value, `"`)
+			}
+		}
+		return proto, host
+	}
+
+	proto, _, _ = strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	host, _, _ = strings.Cut(r.Header.Get("X-Forwarded-Host"), ",")
+	return strings.ToLower(strings.TrimSpace(proto)), strings.TrimSpace(host)
+}
+
+// Self returns the absolute URL of the request.
+func (b *LinkBuilder) Self() string {
+	return b.request.String()
+}
+
+// URL returns ref, such as /books/42 or 42/reviews, resolved against the request URL. A ref which isn't a
+// valid URL reference is returned as it is.
+func (b *LinkBuilder) URL(ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.request.ResolveReference(u).String()
+}
+
+// WithQuery returns the absolute URL of the request with the given query parameters replaced; empty values
+// are removed.
+func (b *LinkBuilder) WithQuery(params map[string]string) string {
+	u := *b.request
+	q := u.Query()
+	for k, v := range params {
+		if v == "" {
+			q.Del(k)
+		} else {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Pagination returns the self, next and prev links for a page of count items from p, as absolute URLs.
+func (b *LinkBuilder) Pagination(p *Paginator, count int) Links {
+	meta := p.Meta(count)
+	links := Links{Self: b.Self()}
+	if meta.Next != "" {
+		links.Next = b.URL(meta.Next)
+	}
+	if meta.Prev != "" {
+		links.Prev = b.URL(meta.Prev)
+	}
+	return links
+}
@@ -0,0 +1,117 @@
+package toolkit
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTools_WriteJSONWithLinks(t *testing.T) {
+	links := Links{
+		Self:   "https://api.example.com/books/1",
+		Custom: map[string]Link{"search": {Href: "/books{?q}", Templated: true}, "author": {Href: "/authors/7", Title: "Jane"}},
+	}
+
+	tests := []struct {
+		name     string
+		data     any
+		links    Links
+		expected string
+	}{
+		{name: "object", data: map[string]int{"id": 1}, links: links, expected: `{"_links":{"self":{"href":"https://api.example.com/books/1"},"author":{"href":"/authors/7","title":"Jane"},"search":{"href":"/books{?q}","templated":true}},"id":1}`},
+		{name: "empty object", data: struct{}{}, links: Links{Self: "/a"}, expected: `{"_links":{"self":{"href":"/a"}}}`},
+		{name: "slice", data: []int{1, 2}, links: Links{Self: "/a", Next: "/a?page=2"}, expected: `{"_links":{"self":{"href":"/a"},"next":{"href":"/a?page=2"}},"data":[1,2]}`},
+		{name: "no links", data: map[string]int{"id": 1}, expected: `{"_links":{},"id":1}`},
+		{name: "custom self", data: map[string]int{"id": 1}, links: Links{Self: "/a", Custom: map[string]Link{"self": {Href: "/b"}}}, expected: `{"_links":{"self":{"href":"/b"}},"id":1}`},
+	}
+
+	var testTools Tools
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		if err := testTools.WriteJSONWithLinks(rr, http.StatusOK, e.data, e.links); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+
+	// unencodable data is reported, as with WriteJSON
+	rr := httptest.NewRecorder()
+	if err := testTools.WriteJSONWithLinks(rr, http.StatusOK, make(chan int), links); err == nil || rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected an error and a 500, got %v and %d", err, rr.Code)
+	}
+}
+
+func TestTools_NewLinkBuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		trusted  []string
+		remote   string
+		headers  map[string]string
+		tls      bool
+		expected string
+	}{
+		{name: "plain", remote: "203.0.113.5:1234", expected: "http://example.com/books?page=2"},
+		{name: "tls", remote: "203.0.113.5:1234", tls: true, expected: "https://example.com/books?page=2"},
+		{name: "untrusted proxy", remote: "203.0.113.5:1234", headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "api.example.com"}, expected: "http://example.com/books?page=2"},
+		{name: "trusted proxy", trusted: []string{"10.0.0.0/8"}, remote: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "api.example.com, proxy.internal"}, expected: "https://api.example.com/books?page=2"},
+		{name: "forwarded", trusted: []string{"10.0.0.1"}, remote: "10.0.0.1:1234", headers: map[string]string{"Forwarded": `for=192.0.2.60;proto=https;host="api.example.com"`, "X-Forwarded-Proto": "http"}, expected: "https://api.example.com/books?page=2"},
+		{name: "bad proto", trusted: []string{"10.0.0.1"}, remote: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-Proto": "javascript"}, expected: "http://example.com/books?page=2"},
+	}
+
+	for _, e := range tests {
+		testTools := Tools{TrustedProxies: e.trusted}
+		request := httptest.NewRequest(http.MethodGet, "http://example.com/books?page=2", nil)
+		request.RemoteAddr = e.remote
+		if e.tls {
+			request.TLS = &tls.ConnectionState{}
+		}
+		for k, v := range e.headers {
+			request.Header.Set(k, v)
+		}
+
+		if got := testTools.NewLinkBuilder(request).Self(); got != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestLinkBuilder(t *testing.T) {
+	var testTools Tools
+	request := httptest.NewRequest(http.MethodGet, "https://api.example.com/books/?page=2&per_page=10&q=go", nil)
+	b := testTools.NewLinkBuilder(request)
+
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{name: "absolute path", got: b.URL("/authors/7"), expected: "https://api.example.com/authors/7"},
+		{name: "relative path", got: b.URL("42/reviews"), expected: "https://api.example.com/books/42/reviews"},
+		{name: "absolute URL", got: b.URL("https://cdn.example.com/a.png"), expected: "https://cdn.example.com/a.png"},
+		{name: "with query", got: b.WithQuery(map[string]string{"page": "3", "q": ""}), expected: "https://api.example.com/books/?page=3&per_page=10"},
+	}
+	for _, e := range tests {
+		if e.got != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, e.got)
+		}
+	}
+
+	p, err := NewPaginator(request, PaginationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetTotal(35)
+	links := b.Pagination(p, 10)
+	expected := Links{
+		Self: "https://api.example.com/books/?page=2&per_page=10&q=go",
+		Next: "https://api.example.com/books/?page=3&per_page=10&q=go",
+		Prev: "https://api.example.com/books/?page=1&per_page=10&q=go",
+	}
+	if links.Self != expected.Self || links.Next != expected.Next || links.Prev != expected.Prev {
+		t.Errorf("expected %+v, got %+v", expected, links)
+	}
+}
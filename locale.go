@@ -0,0 +1,181 @@
+package toolkit
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocaleFormat describes how numbers, amounts of money and dates are written in a locale.
+type LocaleFormat struct {
+	DecimalSeparator string // separates the fraction from the whole number, e.g. "." or ","
+	GroupSeparator   string // separates groups of three digits, e.g. "," or "."; the built-in formats use a non-breaking space rather than a space
+	CurrencyFormat   string // where the symbol (¤) goes relative to the amount (#), e.g. "¤#" or "#\u00a0¤"
+	DateFormat       string // a time.Format layout for dates, e.g. "01/02/2006"
+}
+
+// defaultLocaleFormats are the formats of the locales supported out of the box. LocaleFormats can add
+// more, or replace these.
+var defaultLocaleFormats = map[string]LocaleFormat{
+	"en":    {DecimalSeparator: ".", GroupSeparator: ",", CurrencyFormat: "¤#", DateFormat: "01/02/2006"},
+	"en-GB": {DecimalSeparator: ".", GroupSeparator: ",", CurrencyFormat: "¤#", DateFormat: "02/01/2006"},
+	"de":    {DecimalSeparator: ",", GroupSeparator: ".", CurrencyFormat: "#\u00a0¤", DateFormat: "02.01.2006"},
+	"es":    {DecimalSeparator: ",", GroupSeparator: ".", CurrencyFormat: "#\u00a0¤", DateFormat: "02/01/2006"},
+	"fr":    {DecimalSeparator: ",", GroupSeparator: "\u00a0", CurrencyFormat: "#\u00a0¤", DateFormat: "02/01/2006"},
+	"it":    {DecimalSeparator: ",", GroupSeparator: ".", CurrencyFormat: "#\u00a0¤", DateFormat: "02/01/2006"},
+	"nl":    {DecimalSeparator: ",", GroupSeparator: ".", CurrencyFormat: "¤\u00a0#", DateFormat: "02-01-2006"},
+	"pl":    {DecimalSeparator: ",", GroupSeparator: "\u00a0", CurrencyFormat: "#\u00a0¤", DateFormat: "02.01.2006"},
+	"pt":    {DecimalSeparator: ",", GroupSeparator: "\u00a0", CurrencyFormat: "#\u00a0¤", DateFormat: "02/01/2006"},
+	"pt-BR": {DecimalSeparator: ",", GroupSeparator: ".", CurrencyFormat: "¤\u00a0#", DateFormat: "02/01/2006"},
+	"ru":    {DecimalSeparator: ",", GroupSeparator: "\u00a0", CurrencyFormat: "#\u00a0¤", DateFormat: "02.01.2006"},
+	"uk":    {DecimalSeparator: ",", GroupSeparator: "\u00a0", CurrencyFormat: "#\u00a0¤", DateFormat: "02.01.2006"},
+	"ja":    {DecimalSeparator: ".", GroupSeparator: ",", CurrencyFormat: "¤#", DateFormat: "2006/01/02"},
+	"zh":    {DecimalSeparator: ".", GroupSeparator: ",", CurrencyFormat: "¤#", DateFormat: "2006/01/02"},
+}
+
+// currencySymbols are the symbols written for common ISO 4217 currency codes; other currencies are
+// written with their code.
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "INR": "₹", "KRW": "₩",
+	"BRL": "R$", "RUB": "₽", "UAH": "₴", "PLN": "zł", "TRY": "₺", "ILS": "₪",
+}
+
+// currencyDecimals are the minor units of currencies which don't have two.
+var currencyDecimals = map[string]int{
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0, "HUF": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+// LocaleFormatFor returns the format of locale, from LocaleFormats or the built-in formats. If there is
+// none for the locale, the one for its language is used (so "de-AT" is written like "de"), and failing
+// that, the one for "en".
+func (t *Tools) LocaleFormatFor(locale string) LocaleFormat {
+	lang, _, _ := strings.Cut(locale, "-")
+	for _, l := range []string{locale, lang} {
+		if f, ok := t.LocaleFormats[l]; ok {
+			return f
+		}
+		for name, f := range defaultLocaleFormats {
+			if strings.EqualFold(name, l) {
+				return f
+			}
+		}
+	}
+	return defaultLocaleFormats["en"]
+}
+
+// FormatNumber writes n in locale with the given number of decimal places, grouping the digits of the
+// whole number, e.g. 1234567.891 with 2 decimals is "1,234,567.89" in "en" and "1.234.567,89" in "de".
+func (t *Tools) FormatNumber(locale string, n float64, decimals int) string {
+	return formatNumber(t.LocaleFormatFor(locale), n, decimals)
+}
+
+// formatNumber writes n using f.
+func formatNumber(f LocaleFormat, n float64, decimals int) string {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+
+	s := strconv.FormatFloat(math.Abs(n), 'f', max(decimals, 0), 64)
+	whole, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if n < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.GroupSeparator)
+		}
+		b.WriteRune(c)
+	}
+	if frac != "" {
+		b.WriteString(f.DecimalSeparator)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// FormatCurrency writes amount of currency, an ISO 4217 code such as "EUR", in locale, with the currency's
+// usual number of decimal places, e.g. "$1,234.50" in "en" and "1.234,50 €" in "de" (with a non-breaking
+// space).
+func (t *Tools) FormatCurrency(locale string, amount float64, currency string) string {
+	currency = strings.ToUpper(currency)
+	f := t.LocaleFormatFor(locale)
+
+	decimals, ok := currencyDecimals[currency]
+	if !ok {
+		decimals = 2
+	}
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+
+	number := formatNumber(f, amount, decimals)
+	sign := ""
+	if rest, ok := strings.CutPrefix(number, "-"); ok {
+		sign, number = "-", rest
+	}
+
+	format := f.CurrencyFormat
+	if format == "" {
+		format = "¤#"
+	}
+	return sign + strings.NewReplacer("¤", symbol, "#", number).Replace(format)
+}
+
+// FormatDate writes the date of tm in locale, e.g. "03/14/2025" in "en" and "14.03.2025" in "de".
+func (t *Tools) FormatDate(locale string, tm time.Time) string {
+	layout := t.LocaleFormatFor(locale).DateFormat
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	return tm.Format(layout)
+}
+
+// Translate returns the message for key in locale from Translator, formatted with args. Keys without a
+// translation fall back to the toolkit's English messages, and then to the key itself, so it can be used
+// for an app's own messages as well as the Msg keys.
+func (t *Tools) Translate(locale, key string, args ...any) string {
+	format, ok := "", false
+	if t.Translator != nil {
+		format, ok = t.Translator.Translate(locale, key)
+	}
+	if !ok {
+		if format, ok = defaultMessages[key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// LocaleFuncs returns template functions which translate and format for locale: "t" (Translate),
+// "formatNumber", "formatCurrency" and "formatDate", along with "humanBytes", "humanDuration" and
+// "relativeTime", which are always in English. Add them to a template with Funcs, e.g. using the
+// locale from NegotiateLocale.
+func (t *Tools) LocaleFuncs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"t": func(key string, args ...any) string {
+			return t.Translate(locale, key, args...)
+		},
+		"formatNumber": func(n float64, decimals int) string {
+			return t.FormatNumber(locale, n, decimals)
+		},
+		"formatCurrency": func(amount float64, currency string) string {
+			return t.FormatCurrency(locale, amount, currency)
+		},
+		"formatDate": func(tm time.Time) string {
+			return t.FormatDate(locale, tm)
+		},
+		"humanBytes":    HumanBytes,
+		"humanDuration": HumanDuration,
+		"relativeTime":  RelativeTime,
+	}
+}
@@ -0,0 +1,111 @@
+package toolkit
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"time"
+)
+
+var formatNumberTests = []struct {
+	name     string
+	locale   string
+	n        float64
+	decimals int
+	expected string
+}{
+	{name: "en", locale: "en", n: 1234567.891, decimals: 2, expected: "1,234,567.89"},
+	{name: "de", locale: "de", n: 1234567.891, decimals: 2, expected: "1.234.567,89"},
+	{name: "fr", locale: "fr", n: 1234.5, decimals: 1, expected: "1\u00a0234,5"},
+	{name: "region falls back to language", locale: "de-AT", n: 1000, decimals: 0, expected: "1.000"},
+	{name: "unknown locale", locale: "xx", n: 1000, decimals: 0, expected: "1,000"},
+	{name: "small", locale: "en", n: 12, decimals: 0, expected: "12"},
+	{name: "rounding", locale: "en", n: 999.996, decimals: 2, expected: "1,000.00"},
+	{name: "negative", locale: "en", n: -1234.5, decimals: 2, expected: "-1,234.50"},
+	{name: "negative zero", locale: "en", n: -0.001, decimals: 2, expected: "0.00"},
+	{name: "custom", locale: "x-test", n: 1234.5, decimals: 1, expected: "1'234·5"},
+}
+
+func TestTools_FormatNumber(t *testing.T) {
+	testTools := Tools{LocaleFormats: map[string]LocaleFormat{"x-test": {DecimalSeparator: "·", GroupSeparator: "'"}}}
+	for _, e := range formatNumberTests {
+		if got := testTools.FormatNumber(e.locale, e.n, e.decimals); got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+var formatCurrencyTests = []struct {
+	name     string
+	locale   string
+	amount   float64
+	currency string
+	expected string
+}{
+	{name: "en USD", locale: "en", amount: 1234.5, currency: "USD", expected: "$1,234.50"},
+	{name: "de EUR", locale: "de", amount: 1234.5, currency: "EUR", expected: "1.234,50\u00a0€"},
+	{name: "pt-BR BRL", locale: "pt-BR", amount: 10, currency: "brl", expected: "R$\u00a010,00"},
+	{name: "no minor units", locale: "ja", amount: 1500.4, currency: "JPY", expected: "¥1,500"},
+	{name: "three minor units", locale: "en", amount: 1.5, currency: "KWD", expected: "KWD1.500"},
+	{name: "unknown symbol", locale: "en", amount: 5, currency: "CHF", expected: "CHF5.00"},
+	{name: "negative", locale: "en", amount: -5, currency: "GBP", expected: "-£5.00"},
+}
+
+func TestTools_FormatCurrency(t *testing.T) {
+	var testTools Tools
+	for _, e := range formatCurrencyTests {
+		if got := testTools.FormatCurrency(e.locale, e.amount, e.currency); got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestTools_FormatDate(t *testing.T) {
+	var testTools Tools
+	tm := time.Date(2025, time.March, 14, 15, 4, 5, 0, time.UTC)
+	for locale, expected := range map[string]string{"en": "03/14/2025", "en-GB": "14/03/2025", "de": "14.03.2025", "ja": "2025/03/14"} {
+		if got := testTools.FormatDate(locale, tm); got != expected {
+			t.Errorf("%s: expected %q but got %q", locale, expected, got)
+		}
+	}
+}
+
+func TestTools_Translate(t *testing.T) {
+	testTools := Tools{Translator: Catalog{"es": {"greeting": "Hola, %s", MsgEmpty: "%s no debe estar vacío"}}}
+
+	tests := []struct {
+		locale   string
+		key      string
+		args     []any
+		expected string
+	}{
+		{locale: "es", key: "greeting", args: []any{"Ana"}, expected: "Hola, Ana"},
+		{locale: "es", key: MsgEmpty, args: []any{"name"}, expected: "name no debe estar vacío"},
+		{locale: "en", key: MsgEmpty, args: []any{"name"}, expected: "name must not be empty"},
+		{locale: "en", key: "greeting", expected: "greeting"},
+	}
+	for _, e := range tests {
+		if got := testTools.Translate(e.locale, e.key, e.args...); got != e.expected {
+			t.Errorf("%s %s: expected %q but got %q", e.locale, e.key, e.expected, got)
+		}
+	}
+}
+
+func TestTools_LocaleFuncs(t *testing.T) {
+	testTools := Tools{Translator: Catalog{"de": {"total": "Summe"}}}
+	tmpl := template.Must(template.New("t").Funcs(testTools.LocaleFuncs("de")).Parse(
+		`{{t "total"}}: {{formatCurrency .Amount "EUR"}} ({{formatNumber .Count 0}} Stück, {{formatDate .Date}})`))
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, map[string]any{
+		"Amount": 1234.5,
+		"Count":  1500.0,
+		"Date":   time.Date(2025, time.March, 14, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Summe: 1.234,50\u00a0€ (1.500 Stück, 14.03.2025)"; buf.String() != expected {
+		t.Errorf("expected %q but got %q", expected, buf.String())
+	}
+}
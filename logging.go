@@ -0,0 +1,249 @@
+package toolkit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedLoggers the most loggers kept by logger; the cache is emptied when it is full
+const maxCachedLoggers = 64
+
+// LogDebug logs a debug message through LogHandler, with optional key-value pairs (e.g. "user", 42). The
+// request ID from ctx, if there is one, is added automatically. Debug messages are dropped when logging
+// falls back to InfoLog and ErrorLog.
+func (t *Tools) LogDebug(ctx context.Context, msg string, args ...any) {
+	t.log(ctx, slog.LevelDebug, msg, args...)
+}
+
+// LogInfo logs an informational message, the same way as LogDebug. Without a LogHandler it goes to InfoLog.
+func (t *Tools) LogInfo(ctx context.Context, msg string, args ...any) {
+	t.log(ctx, slog.LevelInfo, msg, args...)
+}
+
+// LogWarn logs a warning, the same way as LogDebug. Without a LogHandler it goes to ErrorLog.
+func (t *Tools) LogWarn(ctx context.Context, msg string, args ...any) {
+	t.log(ctx, slog.LevelWarn, msg, args...)
+}
+
+// LogError logs an error, the same way as LogDebug. Without a LogHandler it goes to ErrorLog.
+func (t *Tools) LogError(ctx context.Context, msg string, args ...any) {
+	t.log(ctx, slog.LevelError, msg, args...)
+}
+
+// log writes a record at level, with the source of the call to LogDebug, LogInfo, LogWarn or LogError
+// rather than of this file, for handlers which add it and loggers with the Lshortfile or Llongfile flag.
+func (t *Tools) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	l := t.logger()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip runtime.Callers, log and the Log method
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	_ = l.Handler().Handle(ctx, r)
+}
+
+// logEnabled reports whether a message at level would be written anywhere, so callers can skip the work
+// of building one.
+func (t *Tools) logEnabled(ctx context.Context, level slog.Level) bool {
+	return t.logger().Enabled(ctx, level)
+}
+
+// Slog returns the logger LogDebug, LogInfo, LogWarn and LogError use, for packages which take a
+// *slog.Logger, such as toolkitdb, to log the same way.
+func (t *Tools) Slog() *slog.Logger {
+	return t.logger()
+}
+
+// loggerKey identifies the configuration a cached logger was built from. The redactor's fields, which
+// can't be compared as a key, are kept with the logger.
+type loggerKey struct {
+	handler       slog.Handler
+	info, error   *log.Logger
+	mask          string
+	defaultFields bool
+}
+
+// cachedLogger is a logger built by logger, with the redactor fields it was built with.
+type cachedLogger struct {
+	logger *slog.Logger
+	fields []string
+}
+
+// loggerCache holds the loggers built by logger, so they aren't built again for every message.
+var loggerCache = struct {
+	mu      sync.RWMutex
+	loggers map[loggerKey]cachedLogger
+}{loggers: make(map[loggerKey]cachedLogger)}
+
+// logger returns a structured logger writing to the configured handler. It is built once for each
+// configuration, and again if LogHandler, InfoLog, ErrorLog or Redactor change.
+func (t *Tools) logger() *slog.Logger {
+	// a handler which can't be compared can't be a key
+	if t.LogHandler != nil && !reflect.TypeOf(t.LogHandler).Comparable() {
+		return slog.New(t.logHandler())
+	}
+	key := loggerKey{handler: t.LogHandler, info: t.InfoLog, error: t.ErrorLog, mask: t.Redactor.Mask, defaultFields: t.Redactor.Fields == nil}
+
+	loggerCache.mu.RLock()
+	c, ok := loggerCache.loggers[key]
+	loggerCache.mu.RUnlock()
+	if ok && slices.Equal(c.fields, t.Redactor.Fields) {
+		return c.logger
+	}
+
+	c = cachedLogger{logger: slog.New(t.logHandler()), fields: slices.Clone(t.Redactor.Fields)}
+	loggerCache.mu.Lock()
+	if len(loggerCache.loggers) >= maxCachedLoggers {
+		clear(loggerCache.loggers)
+	}
+	loggerCache.loggers[key] = c
+	loggerCache.mu.Unlock()
+	return c.logger
+}
+
+// logHandler returns LogHandler, or a shim writing to InfoLog and ErrorLog if it isn't set, wrapped so
+// that request IDs are included and sensitive values are masked by Redactor.
+func (t *Tools) logHandler() slog.Handler {
+	h := t.LogHandler
+	if h == nil {
+		h = &legacyLogHandler{info: legacyTextHandler(t.InfoLog), error: legacyTextHandler(t.ErrorLog)}
+	}
+	return &redactLogHandler{Handler: &requestIDLogHandler{Handler: h}, redactor: t.Redactor}
+}
+
+// legacyTextHandler returns a text handler writing through l, or nil if l is nil. The time is left to
+// the logger's own flags, and durations are written with HumanDuration. If l has the Lshortfile or
+// Llongfile flag, the file and line it would report are those of this package, so the record's source is
+// written instead. The logger's output, prefix and flags are read for every line, so changes to them apply.
+func legacyTextHandler(l *log.Logger) slog.Handler {
+	if l == nil {
+		return nil
+	}
+	return slog.NewTextHandler(logWriter{l}, &slog.HandlerOptions{
+		AddSource: true,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			if src, ok := a.Value.Any().(*slog.Source); ok && len(groups) == 0 && a.Key == slog.SourceKey {
+				flags := l.Flags()
+				if flags&(log.Lshortfile|log.Llongfile) == 0 {
+					return slog.Attr{}
+				}
+				file := src.File
+				if flags&log.Lshortfile != 0 {
+					file = filepath.Base(file)
+				}
+				return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", file, src.Line))
+			}
+			if a.Value.Kind() == slog.KindDuration {
+				return slog.String(a.Key, HumanDuration(a.Value.Duration()))
+			}
+			return a
+		},
+	})
+}
+
+// logWriter writes each line it receives to a *log.Logger.
+type logWriter struct {
+	l *log.Logger
+}
+
+// Write implements io.Writer. A logger with the Lshortfile or Llongfile flag would report this file, so the
+// line is written without it, the source being in the line already.
+func (w logWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	if flags := w.l.Flags(); flags&(log.Lshortfile|log.Llongfile) != 0 {
+		log.New(w.l.Writer(), w.l.Prefix(), flags&^(log.Lshortfile|log.Llongfile)).Print(line)
+		return len(p), nil
+	}
+	w.l.Print(line)
+	return len(p), nil
+}
+
+// legacyLogHandler sends debug and info records to one handler, and warnings and errors to another.
+// Either may be nil, in which case those records are dropped.
+type legacyLogHandler struct {
+	info  slog.Handler
+	error slog.Handler
+}
+
+// target returns the handler for records at level.
+func (h *legacyLogHandler) target(level slog.Level) slog.Handler {
+	if level >= slog.LevelWarn {
+		return h.error
+	}
+	return h.info
+}
+
+// Enabled implements slog.Handler.
+func (h *legacyLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	target := h.target(level)
+	return target != nil && target.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *legacyLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if target := h.target(r.Level); target != nil {
+		return target.Handle(ctx, r)
+	}
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *legacyLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &legacyLogHandler{
+		info:  withHandler(h.info, func(s slog.Handler) slog.Handler { return s.WithAttrs(attrs) }),
+		error: withHandler(h.error, func(s slog.Handler) slog.Handler { return s.WithAttrs(attrs) }),
+	}
+}
+
+// WithGroup implements slog.Handler.
+func (h *legacyLogHandler) WithGroup(name string) slog.Handler {
+	return &legacyLogHandler{
+		info:  withHandler(h.info, func(s slog.Handler) slog.Handler { return s.WithGroup(name) }),
+		error: withHandler(h.error, func(s slog.Handler) slog.Handler { return s.WithGroup(name) }),
+	}
+}
+
+// withHandler applies fn to h, unless h is nil.
+func withHandler(h slog.Handler, fn func(slog.Handler) slog.Handler) slog.Handler {
+	if h == nil {
+		return nil
+	}
+	return fn(h)
+}
+
+// requestIDLogHandler adds the request ID found in the context to every record.
+type requestIDLogHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler.
+func (h *requestIDLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *requestIDLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDLogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *requestIDLogHandler) WithGroup(name string) slog.Handler {
+	return &requestIDLogHandler{Handler: h.Handler.WithGroup(name)}
+}
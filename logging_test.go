@@ -0,0 +1,131 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+var loggingTests = []struct {
+	name     string
+	log      func(t *Tools, ctx context.Context)
+	infoLog  string
+	errorLog string
+}{
+	{name: "debug dropped", log: func(t *Tools, ctx context.Context) { t.LogDebug(ctx, "debugging", "n", 1) }},
+	{name: "info", log: func(t *Tools, ctx context.Context) { t.LogInfo(ctx, "started", "port", 8080) }, infoLog: "level=INFO msg=started port=8080"},
+	{name: "warn", log: func(t *Tools, ctx context.Context) { t.LogWarn(ctx, "slow", "ms", 900) }, errorLog: "level=WARN msg=slow ms=900"},
+	{name: "error", log: func(t *Tools, ctx context.Context) { t.LogError(ctx, "failed", "error", "boom") }, errorLog: "level=ERROR msg=failed error=boom"},
+	{name: "request id", log: func(t *Tools, ctx context.Context) {
+		t.LogInfo(context.WithValue(ctx, requestIDKey, "abc123"), "handled")
+	}, infoLog: "level=INFO msg=handled request_id=abc123"},
+	{name: "slog", log: func(t *Tools, ctx context.Context) { t.Slog().InfoContext(ctx, "migrated", "version", 3) }, infoLog: "level=INFO msg=migrated version=3"},
+	{name: "duration", log: func(t *Tools, ctx context.Context) { t.LogInfo(ctx, "handled", "duration", 1500*time.Millisecond) }, infoLog: `level=INFO msg=handled duration="1.5 seconds"`},
+}
+
+func TestTools_LogLegacy(t *testing.T) {
+	for _, e := range loggingTests {
+		var infoBuf, errorBuf bytes.Buffer
+		var testTools Tools
+		testTools.InfoLog = log.New(&infoBuf, "", 0)
+		testTools.ErrorLog = log.New(&errorBuf, "", 0)
+
+		e.log(&testTools, context.Background())
+
+		if strings.TrimSpace(infoBuf.String()) != e.infoLog {
+			t.Errorf("%s: wrong info log; expected %q but got %q", e.name, e.infoLog, infoBuf.String())
+		}
+		if strings.TrimSpace(errorBuf.String()) != e.errorLog {
+			t.Errorf("%s: wrong error log; expected %q but got %q", e.name, e.errorLog, errorBuf.String())
+		}
+	}
+}
+
+func TestTools_LogHandler(t *testing.T) {
+	var buf, legacy bytes.Buffer
+	var testTools Tools
+	testTools.InfoLog = log.New(&legacy, "", 0)
+	testTools.LogHandler = slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+
+	ctx := context.WithValue(context.Background(), requestIDKey, "abc123")
+	testTools.LogDebug(ctx, "debugging", "user", 42)
+
+	if !strings.Contains(buf.String(), `"msg":"debugging"`) || !strings.Contains(buf.String(), `"user":42`) {
+		t.Errorf("expected structured debug record, got %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"request_id":"abc123"`) {
+		t.Errorf("expected request ID in record, got %s", buf.String())
+	}
+	if legacy.Len() != 0 {
+		t.Errorf("expected nothing written to InfoLog when LogHandler is set, got %s", legacy.String())
+	}
+}
+
+func TestTools_LogNoLoggers(t *testing.T) {
+	var testTools Tools
+
+	// with no loggers configured, logging is a no-op
+	testTools.LogError(context.Background(), "nobody is listening")
+	if testTools.logEnabled(context.Background(), slog.LevelError) {
+		t.Error("expected logging to be disabled")
+	}
+}
+
+func TestTools_LogSource(t *testing.T) {
+	var errorBuf, jsonBuf bytes.Buffer
+	var testTools Tools
+	testTools.ErrorLog = log.New(&errorBuf, "", log.Lshortfile)
+
+	testTools.LogError(context.Background(), "failed")
+	if !strings.HasPrefix(errorBuf.String(), "level=ERROR source=logging_test.go:") {
+		t.Errorf("expected the caller's file and line, got %q", errorBuf.String())
+	}
+
+	testTools.LogHandler = slog.NewJSONHandler(&jsonBuf, &slog.HandlerOptions{AddSource: true})
+	testTools.LogWarn(context.Background(), "slow")
+	if !strings.Contains(jsonBuf.String(), `logging_test.go","line":`) {
+		t.Errorf("expected the caller's source in the record, got %s", jsonBuf.String())
+	}
+}
+
+func TestTools_LoggerCache(t *testing.T) {
+	var testTools Tools
+	testTools.ErrorLog = log.New(&bytes.Buffer{}, "", 0)
+
+	if testTools.logger() != testTools.logger() {
+		t.Error("expected the logger to be built once")
+	}
+
+	before := testTools.logger()
+	testTools.Redactor.Fields = []string{"pin"}
+	if testTools.logger() == before {
+		t.Error("expected the logger to be rebuilt when the redactor changes")
+	}
+	testTools.ErrorLog = log.New(&bytes.Buffer{}, "", 0)
+	if testTools.logger() == before {
+		t.Error("expected the logger to be rebuilt when the log changes")
+	}
+}
+
+func TestTools_LogLegacyChanges(t *testing.T) {
+	var first, second bytes.Buffer
+	var testTools Tools
+	testTools.ErrorLog = log.New(&first, "", log.Lshortfile)
+
+	testTools.LogError(context.Background(), "first")
+	testTools.ErrorLog.SetOutput(&second)
+	testTools.ErrorLog.SetPrefix("app: ")
+	testTools.ErrorLog.SetFlags(0)
+	testTools.LogError(context.Background(), "second")
+
+	if !strings.Contains(first.String(), "msg=first") || strings.Contains(first.String(), "second") {
+		t.Errorf("expected only the first message in the first output, got %q", first.String())
+	}
+	if got := strings.TrimSpace(second.String()); got != "app: level=ERROR msg=second" {
+		t.Errorf("expected the logger's new output, prefix and flags to apply, got %q", got)
+	}
+}
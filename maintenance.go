@@ -0,0 +1,139 @@
+package toolkit
+
+import (
+	"errors"
+	"html"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMaintenance is wrapped by the error sent to clients while maintenance mode is on.
+var ErrMaintenance = errors.New("service is down for maintenance")
+
+// MaintenanceOptions configures a Maintenance switch. Besides Enable and Disable, maintenance mode can be
+// turned on from outside the process, by creating File, or by setting EnvVar.
+type MaintenanceOptions struct {
+	File       string        // if set, maintenance mode is on while this file exists, such as during a deployment
+	EnvVar     string        // if set, maintenance mode is on while this environment variable is 1, true or on
+	Message    string        // message sent to clients; defaults to "service is down for maintenance"
+	RetryAfter time.Duration // sent in the Retry-After header; defaults to 5 minutes
+	AllowPaths []string      // path prefixes still served, such as /healthz or /admin
+	AllowIPs   []string      // client IPs or CIDR ranges still served, as returned by ClientIP, such as the team's office
+}
+
+// Maintenance is a switch putting a service into maintenance mode, in which its Middleware answers every
+// request, other than those allowed, with 503 Service Unavailable. It is safe for concurrent use.
+type Maintenance struct {
+	tools    *Tools
+	opts     MaintenanceOptions
+	allowIPs []netip.Prefix
+	enabled  atomic.Bool
+}
+
+// NewMaintenance returns a Maintenance switch, which is off until enabled, and sends its responses using t.
+func (t *Tools) NewMaintenance(opts MaintenanceOptions) *Maintenance {
+	if opts.Message == "" {
+		opts.Message = ErrMaintenance.Error()
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = 5 * time.Minute
+	}
+	return &Maintenance{tools: t, opts: opts, allowIPs: parseTrustedProxies(opts.AllowIPs)}
+}
+
+// Enable turns maintenance mode on.
+func (m *Maintenance) Enable() {
+	m.enabled.Store(true)
+}
+
+// Disable turns maintenance mode off, unless File or EnvVar keep it on.
+func (m *Maintenance) Disable() {
+	m.enabled.Store(false)
+}
+
+// Enabled reports whether maintenance mode is on: if it was enabled, File exists, or EnvVar is set to a
+// true value. File and EnvVar are checked on every call, so they take effect straight away.
+func (m *Maintenance) Enabled() bool {
+	if m.enabled.Load() {
+		return true
+	}
+	if m.opts.EnvVar != "" {
+		switch strings.ToLower(strings.TrimSpace(os.Getenv(m.opts.EnvVar))) {
+		case "1", "true", "on":
+			return true
+		}
+	}
+	if m.opts.File != "" {
+		if _, err := os.Stat(m.opts.File); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware answers requests with 503 Service Unavailable and a Retry-After header while maintenance
+// mode is on, except for those to AllowPaths or from AllowIPs, which are passed on. The message is sent
+// as a small HTML page to clients preferring HTML, such as browsers, and as an error through ErrorJSON,
+// or ErrorXML if the client prefers it, otherwise.
+func (m *Maintenance) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Enabled() || m.allowed(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(m.opts.RetryAfter.Round(time.Second).Seconds())))
+		if prefersHTML(r) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			message := html.EscapeString(m.opts.Message)
+			_, _ = w.Write([]byte("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>" + message +
+				"</title></head><body><h1>" + message + "</h1></body></html>\n"))
+			return
+		}
+		err := &HTTPError{Status: http.StatusServiceUnavailable, PublicMessage: m.opts.Message, Internal: ErrMaintenance}
+		m.tools.errorResponse(w, r, err, http.StatusServiceUnavailable)
+	})
+}
+
+// allowed reports whether r is to one of AllowPaths, or from one of AllowIPs.
+func (m *Maintenance) allowed(r *http.Request) bool {
+	for _, p := range m.opts.AllowPaths {
+		if r.URL.Path == p || strings.HasPrefix(r.URL.Path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return len(m.allowIPs) > 0 && isTrustedProxy(m.tools.ClientIP(r), m.allowIPs)
+}
+
+// prefersHTML reports whether the request's Accept header ranks text/html above JSON, as browsers' do.
+func prefersHTML(r *http.Request) bool {
+	jsonQ, htmlQ := -1.0, -1.0
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		switch mediaType = strings.ToLower(strings.TrimSpace(mediaType)); {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			jsonQ = max(jsonQ, q)
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+
+	return htmlQ > 0 && htmlQ > jsonQ
+}
@@ -0,0 +1,107 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenance_Enabled(t *testing.T) {
+	var testTools Tools
+	sentinel := filepath.Join(t.TempDir(), "maintenance")
+	m := testTools.NewMaintenance(MaintenanceOptions{File: sentinel, EnvVar: "TOOLKIT_TEST_MAINTENANCE"})
+
+	if m.Enabled() {
+		t.Fatal("expected maintenance mode to start off")
+	}
+
+	m.Enable()
+	if !m.Enabled() {
+		t.Error("expected Enable to turn maintenance mode on")
+	}
+	m.Disable()
+
+	if err := os.WriteFile(sentinel, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Enabled() {
+		t.Error("expected the sentinel file to turn maintenance mode on")
+	}
+	_ = os.Remove(sentinel)
+
+	for _, v := range []string{"1", "true", "ON"} {
+		t.Setenv("TOOLKIT_TEST_MAINTENANCE", v)
+		if !m.Enabled() {
+			t.Errorf("expected %s in the environment to turn maintenance mode on", v)
+		}
+	}
+	t.Setenv("TOOLKIT_TEST_MAINTENANCE", "0")
+	if m.Enabled() {
+		t.Error("expected maintenance mode to be off")
+	}
+}
+
+func TestMaintenance_Middleware(t *testing.T) {
+	var testTools Tools
+	m := testTools.NewMaintenance(MaintenanceOptions{
+		RetryAfter: 2 * time.Minute,
+		AllowPaths: []string{"/healthz", "/admin/"},
+		AllowIPs:   []string{"10.0.0.0/8"},
+	})
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		path        string
+		remoteAddr  string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{name: "off", path: "/books", status: http.StatusOK, body: "ok"},
+		{name: "json", enabled: true, path: "/books", status: http.StatusServiceUnavailable, contentType: "application/json", body: `{"error":true,"message":"service is down for maintenance"}`},
+		{name: "html", enabled: true, path: "/books", accept: "text/html,application/xhtml+xml,*/*;q=0.8", status: http.StatusServiceUnavailable, contentType: "text/html; charset=utf-8", body: "<h1>service is down for maintenance</h1>"},
+		{name: "allowed path", enabled: true, path: "/healthz", status: http.StatusOK, body: "ok"},
+		{name: "allowed prefix", enabled: true, path: "/admin/users", status: http.StatusOK, body: "ok"},
+		{name: "prefix needs a boundary", enabled: true, path: "/healthzz", status: http.StatusServiceUnavailable},
+		{name: "allowed ip", enabled: true, path: "/books", remoteAddr: "10.1.2.3:5000", status: http.StatusOK, body: "ok"},
+	}
+
+	for _, e := range tests {
+		if e.enabled {
+			m.Enable()
+		} else {
+			m.Disable()
+		}
+		request := httptest.NewRequest(http.MethodGet, e.path, nil)
+		if e.remoteAddr != "" {
+			request.RemoteAddr = e.remoteAddr
+		}
+		if e.accept != "" {
+			request.Header.Set("Accept", e.accept)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, rr.Code)
+		}
+		if e.status == http.StatusServiceUnavailable && rr.Header().Get("Retry-After") != "120" {
+			t.Errorf("%s: expected Retry-After 120, got %q", e.name, rr.Header().Get("Retry-After"))
+		}
+		if e.contentType != "" && rr.Header().Get("Content-Type") != e.contentType {
+			t.Errorf("%s: expected Content-Type %s, got %s", e.name, e.contentType, rr.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rr.Body.String(), e.body) {
+			t.Errorf("%s: expected the body to contain %s, got %s", e.name, e.body, rr.Body.String())
+		}
+	}
+}
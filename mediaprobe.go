@@ -0,0 +1,141 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaInfo describes an audio or video file, as reported by a MediaProber. Fields the file has no data for
+// are left empty.
+type MediaInfo struct {
+	Format     string        `json:"format"`                // the container format, e.g. "mov,mp4,m4a,3gp,3g2,mj2" or "mp3"
+	Duration   time.Duration `json:"duration"`              // how long the media plays for
+	BitRate    int64         `json:"bit_rate,omitempty"`    // the overall bit rate, in bits per second
+	VideoCodec string        `json:"video_codec,omitempty"` // the codec of the first video stream, e.g. "h264"
+	Width      int           `json:"width,omitempty"`       // the width of the first video stream, in pixels
+	Height     int           `json:"height,omitempty"`      // the height of the first video stream, in pixels
+	AudioCodec string        `json:"audio_codec,omitempty"` // the codec of the first audio stream, e.g. "aac"
+	SampleRate int           `json:"sample_rate,omitempty"` // the sample rate of the first audio stream, in Hz
+	Channels   int           `json:"channels,omitempty"`    // the number of channels of the first audio stream
+}
+
+// MediaProber reads the duration, codecs, resolution and bit rate of audio and video files. UploadFiles
+// uses it, if set, to fill in UploadedFile.Media.
+type MediaProber interface {
+	// Probe returns the details of the media file at path.
+	Probe(ctx context.Context, path string) (*MediaInfo, error)
+}
+
+// FFProbe is a MediaProber which runs ffprobe, from FFmpeg, which must be installed.
+type FFProbe struct {
+	Path    string        // the path of the ffprobe binary; defaults to "ffprobe", found in PATH
+	Timeout time.Duration // how long to let ffprobe run for; defaults to 30 seconds
+}
+
+// defaultFFProbeTimeout the default time ffprobe is allowed to run for
+const defaultFFProbeTimeout = 30 * time.Second
+
+// Probe runs ffprobe on the file at path and returns what it reports.
+func (p FFProbe) Probe(ctx context.Context, path string) (*MediaInfo, error) {
+	bin := p.Path
+	if bin == "" {
+		bin = "ffprobe"
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultFFProbeTimeout
+	}
+
+	// Pass an absolute path with the file: protocol, so a name can't be taken for an option or a URL.
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", "file:"+abs)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffprobe: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ffprobe: %w", err)
+	}
+	return parseFFProbe(out)
+}
+
+// ffprobeOutput is the part of ffprobe's JSON output read by parseFFProbe. ffprobe reports most numbers as
+// strings.
+type ffprobeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType  string `json:"codec_type"`
+		CodecName  string `json:"codec_name"`
+		Width      int    `json:"width"`
+		Height     int    `json:"height"`
+		SampleRate string `json:"sample_rate"`
+		Channels   int    `json:"channels"`
+	} `json:"streams"`
+}
+
+// parseFFProbe returns the MediaInfo in the JSON output of ffprobe.
+func parseFFProbe(out []byte) (*MediaInfo, error) {
+	var probe ffprobeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("ffprobe: unexpected output: %w", err)
+	}
+	if probe.Format.FormatName == "" && len(probe.Streams) == 0 {
+		return nil, fmt.Errorf("ffprobe: no media found")
+	}
+
+	info := &MediaInfo{Format: probe.Format.FormatName}
+	if secs, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(secs * float64(time.Second))
+	}
+	info.BitRate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	for _, s := range probe.Streams {
+		switch {
+		case s.CodecType == "video" && info.VideoCodec == "":
+			info.VideoCodec, info.Width, info.Height = s.CodecName, s.Width, s.Height
+		case s.CodecType == "audio" && info.AudioCodec == "":
+			info.AudioCodec, info.Channels = s.CodecName, s.Channels
+			info.SampleRate, _ = strconv.Atoi(s.SampleRate)
+		}
+	}
+	return info, nil
+}
+
+// isMediaType reports whether fileType is an audio or video type, which MediaProber is used for.
+func isMediaType(fileType string) bool {
+	return strings.HasPrefix(fileType, "audio/") || strings.HasPrefix(fileType, "video/")
+}
+
+// probeUpload fills in the Media of an audio or video upload stored at path, if MediaProber is set. Media
+// which can't be probed is logged and left without it, rather than failing the upload.
+func (t *Tools) probeUpload(ctx context.Context, path string, file *UploadedFile) {
+	if t.MediaProber == nil || !isMediaType(file.ContentType) {
+		return
+	}
+	info, err := t.MediaProber.Probe(ctx, path)
+	if err != nil {
+		t.LogWarn(ctx, "could not probe upload", "file", file.OriginalFileName, "error", err)
+		return
+	}
+	file.Media = info
+}
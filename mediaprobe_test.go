@@ -0,0 +1,141 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ffprobeVideo is the output of ffprobe for a short MP4 video with a sound track.
+const ffprobeVideo = `{
+    "streams": [
+        {"index": 0, "codec_name": "h264", "codec_type": "video", "width": 1920, "height": 1080},
+        {"index": 1, "codec_name": "aac", "codec_type": "audio", "sample_rate": "48000", "channels": 2},
+        {"index": 2, "codec_name": "mov_text", "codec_type": "subtitle"}
+    ],
+    "format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "12.500000", "bit_rate": "5000000"}
+}`
+
+func TestParseFFProbe(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected *MediaInfo
+	}{
+		{name: "video", output: ffprobeVideo, expected: &MediaInfo{Format: "mov,mp4,m4a,3gp,3g2,mj2", Duration: 12500 * time.Millisecond,
+			BitRate: 5000000, VideoCodec: "h264", Width: 1920, Height: 1080, AudioCodec: "aac", SampleRate: 48000, Channels: 2}},
+		{name: "audio", output: `{"streams":[{"codec_name":"mp3","codec_type":"audio","sample_rate":"44100","channels":1}],"format":{"format_name":"mp3","duration":"180.25"}}`,
+			expected: &MediaInfo{Format: "mp3", Duration: 180250 * time.Millisecond, AudioCodec: "mp3", SampleRate: 44100, Channels: 1}},
+		{name: "no media", output: `{}`},
+		{name: "invalid", output: `not json`},
+	}
+
+	for _, e := range tests {
+		info, err := parseFFProbe([]byte(e.output))
+		if e.expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		if *info != *e.expected {
+			t.Errorf("%s: expected %+v, got %+v", e.name, e.expected, info)
+		}
+	}
+}
+
+func TestFFProbe_Probe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of ffprobe")
+	}
+
+	// a stand-in for ffprobe which checks it was given the file, and prints a canned report
+	dir := t.TempDir()
+	media := filepath.Join(dir, "clip.mp4")
+	_ = os.WriteFile(media, []byte("clip"), 0644)
+	script := filepath.Join(dir, "ffprobe")
+	_ = os.WriteFile(script, []byte("#!/bin/sh\n"+
+		"for last; do :; done\n"+
+		"[ \"$last\" = \"file:"+media+"\" ] || { echo \"unexpected file $last\" >&2; exit 1; }\n"+
+		"cat <<'EOF'\n"+ffprobeVideo+"\nEOF\n"), 0755)
+
+	info, err := FFProbe{Path: script}.Probe(context.Background(), media)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if info.VideoCodec != "h264" || info.Duration != 12500*time.Millisecond {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	_, err = FFProbe{Path: script}.Probe(context.Background(), filepath.Join(dir, "other.mp4"))
+	if err == nil || !strings.Contains(err.Error(), "unexpected file") {
+		t.Errorf("expected ffprobe's error output in the error, got %v", err)
+	}
+
+	if _, err := (FFProbe{Path: filepath.Join(dir, "missing")}).Probe(context.Background(), media); err == nil {
+		t.Error("expected an error without ffprobe")
+	}
+}
+
+// testMediaProber is a MediaProber returning info, or err, for every file, recording the paths probed.
+type testMediaProber struct {
+	info  *MediaInfo
+	err   error
+	paths []string
+}
+
+func (p *testMediaProber) Probe(ctx context.Context, path string) (*MediaInfo, error) {
+	p.paths = append(p.paths, path)
+	return p.info, p.err
+}
+
+func TestTools_UploadFiles_MediaProber(t *testing.T) {
+	dir := t.TempDir()
+	audio := filepath.Join(t.TempDir(), "episode.mp3")
+	_ = os.WriteFile(audio, append([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), make([]byte, 512)...), 0644)
+
+	tests := []struct {
+		name     string
+		path     string
+		prober   *testMediaProber
+		expected *MediaInfo
+		probed   bool
+	}{
+		{name: "audio", path: audio, prober: &testMediaProber{info: &MediaInfo{Format: "mp3", AudioCodec: "mp3"}}, expected: &MediaInfo{Format: "mp3", AudioCodec: "mp3"}, probed: true},
+		{name: "probe fails", path: audio, prober: &testMediaProber{err: errors.New("invalid data")}, probed: true},
+		{name: "not media", path: "./testdata/img.png", prober: &testMediaProber{info: &MediaInfo{Format: "mp3"}}},
+	}
+
+	for _, e := range tests {
+		testTools := Tools{MediaProber: e.prober}
+		body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: e.path}}, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		file, err := testTools.UploadOneFile(request, dir)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		if (file.Media == nil) != (e.expected == nil) || (file.Media != nil && *file.Media != *e.expected) {
+			t.Errorf("%s: expected %+v, got %+v", e.name, e.expected, file.Media)
+		}
+		if e.probed && (len(e.prober.paths) != 1 || e.prober.paths[0] != filepath.Join(dir, file.NewFileName)) {
+			t.Errorf("%s: expected the stored file to be probed, got %v", e.name, e.prober.paths)
+		}
+		if !e.probed && len(e.prober.paths) != 0 {
+			t.Errorf("%s: expected nothing probed, got %v", e.name, e.prober.paths)
+		}
+	}
+}
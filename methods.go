@@ -0,0 +1,132 @@
+package toolkit
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// methodOverrideHeaders are the headers MethodOverride reads the method from, in order.
+var methodOverrideHeaders = []string{"X-HTTP-Method-Override", "X-HTTP-Method", "X-Method-Override"}
+
+// MethodOverride is middleware which lets clients behind proxies that only pass GET and POST send PUT, PATCH
+// and DELETE requests, as a POST with the method in an X-HTTP-Method-Override header (or X-HTTP-Method, or
+// X-Method-Override). Other methods can't be sent this way, and get 400 Bad Request, so a POST can't be
+// turned into a GET, which safeguards such as CSRF skip. Since http.ServeMux matches routes by method, wrap
+// the Router with it, rather than adding it with Use, for the new method to be routed.
+func (t *Tools) MethodOverride(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for _, header := range methodOverrideHeaders {
+			method := strings.ToUpper(strings.TrimSpace(r.Header.Get(header)))
+			if method == "" {
+				continue
+			}
+			switch method {
+			case http.MethodPut, http.MethodPatch, http.MethodDelete:
+				r = r.Clone(r.Context())
+				r.Method = method
+			default:
+				t.errorResponse(w, r, errors.New(header+" must be PUT, PATCH or DELETE"), http.StatusBadRequest)
+				return
+			}
+			break
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AutoHead is middleware which answers HEAD requests with the handler for GET, such as one writing JSON
+// with WriteJSON, sending the headers it would send, with a Content-Length matching the body, but no body.
+// Handlers which flush, such as those streaming a response, have their headers sent as they are.
+func (t *Tools) AutoHead(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		r.Method = http.MethodGet
+		hw := &headResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(hw, r)
+		hw.finish()
+	})
+}
+
+// headResponseWriter discards the body of a response to a HEAD request, counting its bytes, and holds back
+// the headers until the handler has finished, so Content-Length can be set.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// WriteHeader records the status, to be sent when the handler finishes. Informational (1xx) responses
+// are passed on.
+func (hw *headResponseWriter) WriteHeader(status int) {
+	if status < http.StatusOK && status != http.StatusSwitchingProtocols {
+		hw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if hw.status == 0 {
+		hw.status = status
+	}
+}
+
+// Write counts the bytes of the body, and discards them.
+func (hw *headResponseWriter) Write(b []byte) (int, error) {
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+	hw.bytes += int64(len(b))
+	return len(b), nil
+}
+
+// Flush sends the headers as they are, without waiting for the handler to finish.
+func (hw *headResponseWriter) Flush() {
+	hw.sendHeader(false)
+	_ = http.NewResponseController(hw.ResponseWriter).Flush()
+}
+
+// Hijack passes hijacks through to the underlying writer.
+func (hw *headResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(hw.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (hw *headResponseWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+// finish sends the headers, with the Content-Length of the discarded body, if they haven't been sent.
+func (hw *headResponseWriter) finish() {
+	hw.sendHeader(true)
+}
+
+// sendHeader sends the headers once, setting Content-Length to the bytes counted if withLength is set and
+// the handler didn't set it.
+func (hw *headResponseWriter) sendHeader(withLength bool) {
+	if hw.wroteHeader {
+		return
+	}
+	hw.wroteHeader = true
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+
+	h := hw.Header()
+	bodyAllowed := hw.status != http.StatusNoContent && hw.status != http.StatusNotModified
+	if withLength && bodyAllowed && h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" {
+		h.Set("Content-Length", strconv.FormatInt(hw.bytes, 10))
+	}
+	hw.ResponseWriter.WriteHeader(hw.status)
+}
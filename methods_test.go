@@ -0,0 +1,111 @@
+package toolkit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTools_MethodOverride(t *testing.T) {
+	var testTools Tools
+	router := NewRouter()
+	for _, method := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+		router.HandleFunc(method+" /books/1", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Method))
+		})
+	}
+	handler := testTools.MethodOverride(router)
+
+	tests := []struct {
+		name     string
+		method   string
+		header   string
+		value    string
+		status   int
+		expected string
+	}{
+		{name: "no override", method: http.MethodPost, status: http.StatusOK, expected: "POST"},
+		{name: "put", method: http.MethodPost, header: "X-HTTP-Method-Override", value: "PUT", status: http.StatusOK, expected: "PUT"},
+		{name: "lower case", method: http.MethodPost, header: "X-HTTP-Method-Override", value: "delete", status: http.StatusOK, expected: "DELETE"},
+		{name: "other header", method: http.MethodPost, header: "X-HTTP-Method", value: "PATCH", status: http.StatusOK, expected: "PATCH"},
+		{name: "get refused", method: http.MethodPost, header: "X-HTTP-Method-Override", value: "GET", status: http.StatusBadRequest},
+		{name: "only from post", method: http.MethodGet, header: "X-HTTP-Method-Override", value: "DELETE", status: http.StatusMethodNotAllowed},
+	}
+
+	for _, e := range tests {
+		request := httptest.NewRequest(e.method, "/books/1", nil)
+		if e.header != "" {
+			request.Header.Set(e.header, e.value)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, rr.Code)
+		}
+		if e.expected != "" && rr.Body.String() != e.expected {
+			t.Errorf("%s: expected the handler for %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_AutoHead(t *testing.T) {
+	var testTools Tools
+	mux := http.NewServeMux()
+	mux.HandleFunc("/books", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		_ = testTools.WriteJSON(w, http.StatusOK, map[string]string{"title": strings.Repeat("a", 5000)})
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data: 1\n\n"))
+		http.NewResponseController(w).Flush()
+		_, _ = w.Write([]byte("data: 2\n\n"))
+	})
+	server := httptest.NewServer(testTools.AutoHead(mux))
+	defer server.Close()
+
+	get, err := http.Get(server.URL + "/books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(get.Body)
+	_ = get.Body.Close()
+
+	tests := []struct {
+		name          string
+		path          string
+		status        int
+		contentLength int64
+		contentType   string
+	}{
+		{name: "json", path: "/books", status: http.StatusOK, contentLength: int64(len(body)), contentType: "application/json"},
+		{name: "no content", path: "/empty", status: http.StatusNoContent, contentLength: -1},
+		{name: "flushed", path: "/stream", status: http.StatusOK, contentLength: -1},
+	}
+	for _, e := range tests {
+		resp, err := http.Head(server.URL + e.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, resp.StatusCode)
+		}
+		if resp.ContentLength != e.contentLength || n != 0 {
+			t.Errorf("%s: expected Content-Length %d and no body, got %d and %d bytes", e.name, e.contentLength, resp.ContentLength, n)
+		}
+		if resp.Header.Get("Content-Type") != e.contentType && e.contentType != "" {
+			t.Errorf("%s: expected Content-Type %s, got %s", e.name, e.contentType, resp.Header.Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Recoverer is middleware which recovers from panics in the handlers it wraps. The panic and its stack
+// trace are logged at error level, and the client receives a 500 Internal Server Error through ErrorXML if
+// its Accept header prefers XML, or ErrorJSON otherwise.
+func (t *Tools) Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// http.ErrAbortHandler is used deliberately to abort a response, so let the server handle it.
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			stack := debug.Stack()
+			t.LogError(r.Context(), "panic serving request", "method", r.Method, "path", r.URL.Path, "panic", fmt.Sprint(rec), "stack", string(stack))
+
+			t.respondError(w, &ErrorResponse{
+				Request: r,
+				Err:     errors.New(http.StatusText(http.StatusInternalServerError)),
+				Status:  http.StatusInternalServerError,
+				Panic:   rec,
+				Stack:   stack,
+			})
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errorResponse sends err to the client, in its language, as XML if the request prefers it, or JSON
+// otherwise.
+func (t *Tools) errorResponse(w http.ResponseWriter, r *http.Request, err error, status int) {
+	t.respondError(w, &ErrorResponse{Request: r, Err: err, Status: status})
+}
+
+// respondError sends resp like errorResponse, passing it to the ErrorHandler first.
+func (t *Tools) respondError(w http.ResponseWriter, resp *ErrorResponse) {
+	resp.Err = t.localizedError(w, resp.Request, resp.Err)
+	resp.XML = prefersXML(resp.Request)
+	_ = t.sendError(w, resp)
+}
+
+// prefersXML reports whether the request's Accept header ranks an XML media type above JSON.
+func prefersXML(r *http.Request) bool {
+	jsonQ, xmlQ := -1.0, -1.0
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		switch mediaType = strings.ToLower(strings.TrimSpace(mediaType)); {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			jsonQ = max(jsonQ, q)
+		case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+			xmlQ = max(xmlQ, q)
+		}
+	}
+
+	return xmlQ > 0 && xmlQ > jsonQ
+}
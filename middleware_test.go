@@ -0,0 +1,83 @@
+package toolkit
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var recovererTests = []struct {
+	name                string
+	accept              string
+	expectedContentType string
+}{
+	{name: "no accept header", accept: "", expectedContentType: "application/json"},
+	{name: "json", accept: "application/json", expectedContentType: "application/json"},
+	{name: "xml", accept: "application/xml", expectedContentType: "application/xml"},
+	{name: "xml preferred", accept: "application/json;q=0.5, text/xml", expectedContentType: "application/xml"},
+	{name: "json preferred", accept: "application/xml;q=0.5, application/json", expectedContentType: "application/json"},
+}
+
+func TestTools_Recoverer(t *testing.T) {
+	var buf bytes.Buffer
+	var testTools Tools
+	testTools.ErrorLog = log.New(&buf, "", 0)
+
+	handler := testTools.Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	}))
+
+	for _, e := range recovererTests {
+		buf.Reset()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+		if e.accept != "" {
+			req.Header.Set("Accept", e.accept)
+		}
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, http.StatusInternalServerError, rr.Code)
+		}
+		if rr.Header().Get("Content-Type") != e.expectedContentType {
+			t.Errorf("%s: wrong content type; expected %s but got %s", e.name, e.expectedContentType, rr.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(buf.String(), "something went wrong") || !strings.Contains(buf.String(), "goroutine") {
+			t.Errorf("%s: panic and stack trace not logged: %s", e.name, buf.String())
+		}
+	}
+}
+
+func TestTools_Recoverer_NoPanic(t *testing.T) {
+	var testTools Tools
+
+	handler := testTools.Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("wrong status; expected %d but got %d", http.StatusTeapot, rr.Code)
+	}
+}
+
+func TestTools_Recoverer_ErrAbortHandler(t *testing.T) {
+	var testTools Tools
+
+	handler := testTools.Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be re-panicked, but got %v", rec)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
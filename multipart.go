@@ -0,0 +1,92 @@
+package toolkit
+
+import (
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// MultipartFile describes a single file part to be written by BuildMultipartBody. If Content is nil,
+// the file found at Path is opened and streamed instead.
+type MultipartFile struct {
+	FieldName string    // the form field name (e.g. "file")
+	FileName  string    // the file name sent to the server; defaults to the base name of Path
+	Path      string    // path to a file on disk, used when Content is nil
+	Content   io.Reader // optional in-memory or streamed content
+}
+
+// BuildMultipartBody builds a multipart/form-data request body from the supplied files and plain form
+// fields, and returns a reader for the body along with the matching Content-Type header value. The body
+// is written through a pipe, so large files are never buffered in memory; any error encountered while
+// building the body is returned by the reader.
+func (t *Tools) BuildMultipartBody(files []MultipartFile, fields map[string]string) (io.Reader, string) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	go func() {
+		err := writeMultipartBody(writer, files, fields)
+		if err == nil {
+			err = writer.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	return pr, writer.FormDataContentType()
+}
+
+// writeMultipartBody writes the form fields, sorted by name so the output is deterministic, followed by
+// the file parts.
+func writeMultipartBody(writer *multipart.Writer, files []MultipartFile, fields map[string]string) error {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := writer.WriteField(k, fields[k]); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		if err := writeMultipartFile(writer, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeMultipartFile writes a single file part, opening the file from disk if no content was supplied.
+func writeMultipartFile(writer *multipart.Writer, f MultipartFile) error {
+	content := f.Content
+	if content == nil {
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		content = file
+	}
+
+	fileName := f.FileName
+	if fileName == "" {
+		fileName = filepath.Base(f.Path)
+	}
+
+	fieldName := f.FieldName
+	if fieldName == "" {
+		fieldName = "file"
+	}
+
+	part, err := writer.CreateFormFile(fieldName, fileName)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, content)
+	return err
+}
@@ -0,0 +1,85 @@
+package toolkit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+var multipartTests = []struct {
+	name          string
+	files         []MultipartFile
+	fields        map[string]string
+	errorExpected bool
+}{
+	{name: "file from disk", files: []MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, fields: map[string]string{"title": "a picture"}, errorExpected: false},
+	{name: "file from reader", files: []MultipartFile{{FieldName: "file", FileName: "notes.txt", Content: strings.NewReader("some notes")}}, errorExpected: false},
+	{name: "missing file", files: []MultipartFile{{FieldName: "file", Path: "./testdata/does-not-exist.png"}}, errorExpected: true},
+}
+
+func TestTools_BuildMultipartBody(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range multipartTests {
+		body, contentType := testTools.BuildMultipartBody(e.files, e.fields)
+
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		err := request.ParseMultipartForm(1024 * 1024)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected but one received: %s", e.name, err)
+			continue
+		}
+		if e.errorExpected {
+			continue
+		}
+
+		for k, v := range e.fields {
+			if request.FormValue(k) != v {
+				t.Errorf("%s: wrong value for field %s; expected %s but got %s", e.name, k, v, request.FormValue(k))
+			}
+		}
+
+		if len(request.MultipartForm.File["file"]) != len(e.files) {
+			t.Errorf("%s: wrong number of files; expected %d but got %d", e.name, len(e.files), len(request.MultipartForm.File["file"]))
+		}
+	}
+}
+
+func TestTools_BuildMultipartBody_Upload(t *testing.T) {
+	var testTools Tools
+	testTools.AllowedFileTypes = []string{"image/png"}
+
+	body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Add("Content-Type", contentType)
+
+	uploadedFile, err := testTools.UploadOneFile(request, "./testdata/uploads/", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(fmt.Sprintf("./testdata/uploads/%s", uploadedFile.NewFileName))
+	if err != nil {
+		t.Fatalf("file not uploaded: %s", err)
+	}
+
+	original, _ := os.Open("./testdata/img.png")
+	defer original.Close()
+	originalBytes, _ := io.ReadAll(original)
+	if stat.Size() != int64(len(originalBytes)) {
+		t.Errorf("wrong file size; expected %d but got %d", len(originalBytes), stat.Size())
+	}
+
+	// Clean up
+	_ = os.Remove(fmt.Sprintf("./testdata/uploads/%s", uploadedFile.NewFileName))
+}
@@ -0,0 +1,263 @@
+package toolkit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuthConfig describes an OAuth2 or OpenID Connect provider and the client registered with it. For OpenID
+// Connect providers, DiscoverOIDC can fill in the endpoints.
+type OAuthConfig struct {
+	ClientID     string       // the client ID issued by the provider
+	ClientSecret string       // the client secret issued by the provider; may be empty for public clients using PKCE
+	AuthURL      string       // the provider's authorization endpoint
+	TokenURL     string       // the provider's token endpoint
+	RedirectURL  string       // the URL the provider sends users back to, as registered with it
+	Scopes       []string     // scopes to request, e.g. "openid", "email"
+	Issuer       string       // the issuer ID tokens must come from; only needed for OpenID Connect
+	JWKSURL      string       // where the provider publishes the keys ID tokens are signed with; only needed for OpenID Connect
+	Client       *http.Client // client used to call the provider; defaults to HTTPClient, or a shared client with safe timeouts
+}
+
+// OAuthRequest is an authorization request built by BuildAuthURL. Redirect the user to URL, and keep the
+// rest, e.g. in a short-lived cookie or the session, to check the callback with.
+type OAuthRequest struct {
+	URL          string // the URL to redirect the user to
+	State        string // must match the state parameter of the callback
+	CodeVerifier string // the PKCE verifier to pass to ExchangeCode
+	Nonce        string // the nonce to pass to VerifyIDToken; only set if "openid" is one of the scopes
+}
+
+// OAuthToken is a token response from the provider.
+type OAuthToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"` // when the access token expires, worked out from ExpiresIn; zero if it wasn't given
+}
+
+// Expired reports whether the access token has expired, or will within the next ten seconds.
+func (tok *OAuthToken) Expired() bool {
+	return !tok.Expiry.IsZero() && time.Now().Add(10*time.Second).After(tok.Expiry)
+}
+
+// OAuthError is an error response from the provider's token endpoint.
+type OAuthError struct {
+	Status      int    // the status code of the response
+	Code        string `json:"error"`             // the OAuth2 error code, e.g. invalid_grant
+	Description string `json:"error_description"` // the provider's description of the error, if any
+}
+
+// Error implements the error interface.
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2: %s: %s", e.Code, e.Description)
+	}
+	return "oauth2: " + e.Code
+}
+
+// BuildAuthURL starts the authorization code flow. It returns the URL to redirect the user to, with a
+// random state, a PKCE (S256) code challenge and, for OpenID Connect, a nonce. Extra parameters, such as
+// "prompt" or "access_type", can be added with params.
+func (t *Tools) BuildAuthURL(cfg OAuthConfig, params ...url.Values) (*OAuthRequest, error) {
+	u, err := url.Parse(cfg.AuthURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &OAuthRequest{}
+	if req.State, err = randomToken(); err != nil {
+		return nil, err
+	}
+	if req.CodeVerifier, err = randomToken(); err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	for _, p := range params {
+		for k, v := range p {
+			q[k] = v
+		}
+	}
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	if cfg.RedirectURL != "" {
+		q.Set("redirect_uri", cfg.RedirectURL)
+	}
+	if len(cfg.Scopes) > 0 {
+		q.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	q.Set("state", req.State)
+	q.Set("code_challenge", pkceChallenge(req.CodeVerifier))
+	q.Set("code_challenge_method", "S256")
+	if containsString(cfg.Scopes, "openid") {
+		if req.Nonce, err = randomToken(); err != nil {
+			return nil, err
+		}
+		q.Set("nonce", req.Nonce)
+	}
+
+	u.RawQuery = q.Encode()
+	req.URL = u.String()
+	return req, nil
+}
+
+// ExchangeCode exchanges the code from the callback for a token, sending codeVerifier from the
+// OAuthRequest. Check the callback's state against OAuthRequest.State before calling it. Errors from the
+// provider are returned as an *OAuthError.
+func (t *Tools) ExchangeCode(ctx context.Context, cfg OAuthConfig, code, codeVerifier string) (*OAuthToken, error) {
+	form := url.Values{
+		"grant_type": {"authorization_code"},
+		"code":       {code},
+	}
+	if cfg.RedirectURL != "" {
+		form.Set("redirect_uri", cfg.RedirectURL)
+	}
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+	return t.requestToken(ctx, cfg, form)
+}
+
+// RefreshToken uses refreshToken to get a new access token. Some providers don't return a new refresh
+// token, in which case the old one is kept in the result.
+func (t *Tools) RefreshToken(ctx context.Context, cfg OAuthConfig, refreshToken string) (*OAuthToken, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}
+	tok, err := t.requestToken(ctx, cfg, form)
+	if err != nil {
+		return nil, err
+	}
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = refreshToken
+	}
+	return tok, nil
+}
+
+// requestToken posts form to the token endpoint, with the client credentials, and decodes the response.
+func (t *Tools) requestToken(ctx context.Context, cfg OAuthConfig, form url.Values) (*OAuthToken, error) {
+	form.Set("client_id", cfg.ClientID)
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub, among others, answers with a form-encoded body unless JSON is asked for.
+	req.Header.Set("Accept", "application/json")
+
+	client := cfg.Client
+	if client == nil {
+		client = t.httpClient()
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, truncated, err := readRemoteBody(res.Body, 1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return nil, errors.New("oauth2: token response too large")
+	}
+
+	// Some providers send errors with a 200 status, so look for an error code whatever the status.
+	oauthErr := &OAuthError{Status: res.StatusCode}
+	if json.Unmarshal(body, oauthErr) == nil && oauthErr.Code != "" {
+		return nil, oauthErr
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &RemoteError{Status: res.StatusCode, Body: body}
+	}
+
+	tok := &OAuthToken{}
+	if err := json.Unmarshal(body, tok); err != nil {
+		return nil, fmt.Errorf("oauth2: error decoding token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return nil, errors.New("oauth2: token response has no access_token")
+	}
+	if tok.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// DiscoverOIDC fetches the OpenID Connect discovery document of issuer, such as "https://accounts.google.com",
+// and returns cfg with its AuthURL, TokenURL, JWKSURL and Issuer filled in.
+func (t *Tools) DiscoverOIDC(ctx context.Context, issuer string, cfg OAuthConfig) (OAuthConfig, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return cfg, err
+	}
+	client := cfg.Client
+	if client == nil {
+		client = t.httpClient()
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return cfg, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return cfg, &RemoteError{Status: res.StatusCode, Body: body}
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1024*1024)).Decode(&doc); err != nil {
+		return cfg, fmt.Errorf("oidc: error decoding discovery document: %w", err)
+	}
+	// The issuer must be the one asked for, or tokens from another issuer could be accepted.
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return cfg, fmt.Errorf("oidc: discovery document is for issuer %q, not %q", doc.Issuer, issuer)
+	}
+
+	cfg.Issuer = doc.Issuer
+	cfg.AuthURL = doc.AuthorizationEndpoint
+	cfg.TokenURL = doc.TokenEndpoint
+	cfg.JWKSURL = doc.JWKSURI
+	return cfg, nil
+}
+
+// randomToken returns 32 random bytes, base64url encoded, for use as a state, nonce or PKCE verifier.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// pkceChallenge returns the S256 code challenge for a PKCE code verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,378 @@
+package toolkit
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTools_BuildAuthURL(t *testing.T) {
+	var testTools Tools
+	cfg := OAuthConfig{
+		ClientID:    "client",
+		AuthURL:     "https://accounts.example.com/authorize?hd=example.com",
+		RedirectURL: "https://app.example.com/callback",
+		Scopes:      []string{"openid", "email"},
+	}
+
+	req, err := testTools.BuildAuthURL(cfg, url.Values{"prompt": {"consent"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+
+	expected := map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"redirect_uri":          "https://app.example.com/callback",
+		"scope":                 "openid email",
+		"state":                 req.State,
+		"nonce":                 req.Nonce,
+		"code_challenge":        pkceChallenge(req.CodeVerifier),
+		"code_challenge_method": "S256",
+		"prompt":                "consent",
+		"hd":                    "example.com",
+	}
+	for k, v := range expected {
+		if q.Get(k) != v {
+			t.Errorf("expected %s=%q but got %q", k, v, q.Get(k))
+		}
+	}
+	if req.State == "" || req.Nonce == "" || len(req.CodeVerifier) < 43 {
+		t.Errorf("state, nonce or verifier missing: %+v", req)
+	}
+
+	cfg.Scopes = []string{"read:user"}
+	req, _ = testTools.BuildAuthURL(cfg)
+	if req.Nonce != "" || strings.Contains(req.URL, "nonce=") {
+		t.Error("nonce should only be sent for OpenID Connect")
+	}
+}
+
+func TestTools_ExchangeCode(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostForm.Get("code") {
+		case "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"code expired"}`))
+		case "github-style":
+			_, _ = w.Write([]byte(`{"error":"bad_verification_code"}`))
+		default:
+			_, _ = w.Write([]byte(`{"access_token":"at","token_type":"Bearer","refresh_token":"rt","expires_in":3600,"id_token":"x.y.z"}`))
+		}
+	}))
+	defer server.Close()
+
+	var testTools Tools
+	cfg := OAuthConfig{ClientID: "client", ClientSecret: "secret", TokenURL: server.URL, RedirectURL: "https://app.example.com/callback"}
+
+	tok, err := testTools.ExchangeCode(context.Background(), cfg, "good", "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tok.AccessToken != "at" || tok.RefreshToken != "rt" || tok.IDToken != "x.y.z" {
+		t.Errorf("wrong token %+v", tok)
+	}
+	if tok.Expired() || time.Until(tok.Expiry) < 59*time.Minute {
+		t.Errorf("wrong expiry %s", tok.Expiry)
+	}
+	for k, v := range map[string]string{"grant_type": "authorization_code", "code": "good", "code_verifier": "verifier", "client_id": "client", "client_secret": "secret", "redirect_uri": "https://app.example.com/callback"} {
+		if form.Get(k) != v {
+			t.Errorf("expected %s=%q but got %q", k, v, form.Get(k))
+		}
+	}
+
+	for code, expected := range map[string]string{"bad": "oauth2: invalid_grant: code expired", "github-style": "oauth2: bad_verification_code"} {
+		_, err = testTools.ExchangeCode(context.Background(), cfg, code, "verifier")
+		var oauthErr *OAuthError
+		if !errors.As(err, &oauthErr) || err.Error() != expected {
+			t.Errorf("%s: expected OAuthError %q but got %v", code, expected, err)
+		}
+	}
+}
+
+func TestTools_RefreshToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "rt" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_request"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"new","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	var testTools Tools
+	tok, err := testTools.RefreshToken(context.Background(), OAuthConfig{ClientID: "client", TokenURL: server.URL}, "rt")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tok.AccessToken != "new" || tok.RefreshToken != "rt" {
+		t.Errorf("wrong token %+v", tok)
+	}
+	if tok.Expired() {
+		t.Error("a token without an expiry should not be expired")
+	}
+}
+
+// testSigner signs ID tokens for the tests, and publishes its key in a JWKS.
+type testSigner struct {
+	alg  string
+	kid  string
+	key  crypto.Signer
+	hash crypto.Hash
+}
+
+func (s testSigner) jwk() map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch k := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": s.kid, "use": "sig", "n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": s.kid, "crv": "P-256", "x": b64(k.X.FillBytes(make([]byte, 32))), "y": b64(k.Y.FillBytes(make([]byte, 32)))}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": s.kid, "crv": "Ed25519", "x": b64(k)}
+	}
+	return nil
+}
+
+func (s testSigner) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var sig []byte
+	var err error
+	switch k := s.key.(type) {
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signed))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signed))
+		var r, ss *big.Int
+		r, ss, err = ecdsa.Sign(rand.Reader, k, sum[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+		}
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestTools_VerifyIDToken(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	signers := []testSigner{
+		{alg: "RS256", kid: "rsa", key: rsaKey},
+		{alg: "ES256", kid: "ec", key: ecKey},
+		{alg: "EdDSA", kid: "ed", key: edKey},
+	}
+
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		var keys []map[string]string
+		for _, s := range signers {
+			keys = append(keys, s.jwk())
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	defer server.Close()
+
+	var testTools Tools
+	cfg := OAuthConfig{ClientID: "client", Issuer: "https://accounts.example.com", JWKSURL: server.URL}
+	now := time.Now().Unix()
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss": "https://accounts.example.com", "sub": "123", "aud": "client", "nonce": "n1",
+			"exp": now + 300, "iat": now, "email": "ann@example.com", "email_verified": true,
+		}
+	}
+	with := func(k string, v any) map[string]any {
+		c := valid()
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+		return c
+	}
+
+	for _, s := range signers {
+		claims, err := testTools.VerifyIDToken(context.Background(), cfg, s.sign(t, valid()), "n1")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", s.alg, err)
+			continue
+		}
+		if claims.Subject != "123" || claims.Email != "ann@example.com" || !claims.EmailVerified || claims.ExpiresAt.Unix() != now+300 {
+			t.Errorf("%s: wrong claims %+v", s.alg, claims)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected the key set to be fetched once, got %d", fetches)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		nonce    string
+		noIssuer bool
+		expected string
+	}{
+		{name: "wrong issuer", token: signers[0].sign(t, with("iss", "https://evil.com")), expected: `oidc: ID token issued by "https://evil.com", not "https://accounts.example.com"`},
+		{name: "wrong audience", token: signers[0].sign(t, with("aud", []string{"other"})), expected: "oidc: ID token was not issued to this client"},
+		{name: "expired", token: signers[0].sign(t, with("exp", now-3600)), expected: "oidc: ID token has expired"},
+		{name: "no expiry", token: signers[0].sign(t, with("exp", nil)), expected: "oidc: ID token has no expiry"},
+		{name: "not yet valid", token: signers[0].sign(t, with("nbf", now+3600)), expected: "oidc: ID token is not valid yet"},
+		{name: "wrong nonce", token: signers[0].sign(t, valid()), nonce: "n2", expected: "oidc: ID token nonce does not match"},
+		{name: "tampered", token: strings.Replace(signers[0].sign(t, valid()), ".", ".e30", 1), expected: "oidc: invalid ID token signature"},
+		{name: "alg none", token: testSigner{alg: "none", kid: "rsa", key: rsaKey}.sign(t, valid()), expected: "oidc: unsupported ID token signing algorithm"},
+		{name: "alg mismatch", token: testSigner{alg: "ES256", kid: "rsa", key: ecKey}.sign(t, valid()), expected: "oidc: invalid ID token signature"},
+		{name: "unknown key", token: testSigner{alg: "RS256", kid: "gone", key: rsaKey}.sign(t, valid()), expected: `oidc: no key with ID "gone"`},
+		{name: "malformed", token: "abc", expected: "oidc: malformed ID token"},
+		{name: "issuer with trailing slash", token: signers[0].sign(t, with("iss", "https://accounts.example.com/")), expected: `oidc: ID token issued by "https://accounts.example.com/", not "https://accounts.example.com"`},
+		{name: "no issuer configured", noIssuer: true, token: signers[0].sign(t, valid()), expected: "oidc: no Issuer configured"},
+	}
+	for _, e := range tests {
+		cfg := cfg
+		if e.noIssuer {
+			cfg.Issuer = ""
+		}
+		_, err := testTools.VerifyIDToken(context.Background(), cfg, e.token, e.nonce)
+		if err == nil || err.Error() != e.expected {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.expected, err)
+		}
+	}
+}
+
+func TestVerifyJWTSignature_Curve(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	signed := []byte("header.payload")
+	sum := sha256.Sum256(signed)
+	r, ss, err := ecdsa.Sign(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 48)), ss.FillBytes(make([]byte, 48))...)
+
+	if err := verifyJWTSignature("ES256", &key.PublicKey, signed, sig); err == nil {
+		t.Error("expected ES256 to be rejected for a P-384 key")
+	}
+}
+
+func TestTools_VerifyIDToken_ConcurrentFetch(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer := testSigner{alg: "ES256", kid: "ec", key: ecKey}
+
+	var mu sync.Mutex
+	fetches := 0
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{signer.jwk()}})
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{signer.jwk()}})
+	}))
+	defer fast.Close()
+
+	var testTools Tools
+	now := time.Now().Unix()
+	token := signer.sign(t, map[string]any{"iss": "https://accounts.example.com", "sub": "123", "aud": "client", "exp": now + 300, "iat": now})
+	cfg := OAuthConfig{ClientID: "client", Issuer: "https://accounts.example.com", JWKSURL: slow.URL}
+	other := OAuthConfig{ClientID: "client", Issuer: "https://accounts.example.com", JWKSURL: fast.URL}
+
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			_, err := testTools.VerifyIDToken(context.Background(), cfg, token, "")
+			errs <- err
+		}()
+	}
+
+	// another provider's keys are fetched while the slow fetch is in progress
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := testTools.VerifyIDToken(ctx, other, token, ""); err != nil {
+		t.Errorf("expected a token from another provider to be verified during the fetch, got %v", err)
+	}
+
+	close(release)
+	for i := 0; i < 5; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fetches != 1 {
+		t.Errorf("expected the callers to share one fetch, got %d", fetches)
+	}
+}
+
+func TestTools_DiscoverOIDC(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/auth",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/keys",
+		})
+	}))
+	defer server.Close()
+
+	var testTools Tools
+	cfg, err := testTools.DiscoverOIDC(context.Background(), server.URL, OAuthConfig{ClientID: "client"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.ClientID != "client" || cfg.Issuer != server.URL || cfg.AuthURL != server.URL+"/auth" || cfg.TokenURL != server.URL+"/token" || cfg.JWKSURL != server.URL+"/keys" {
+		t.Errorf("wrong config %+v", cfg)
+	}
+
+	if _, err := testTools.DiscoverOIDC(context.Background(), server.URL+"/other", OAuthConfig{}); err == nil {
+		t.Error("expected an error for a missing discovery document")
+	}
+}
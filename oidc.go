@@ -0,0 +1,449 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idTokenLeeway is the clock skew allowed when checking the times in an ID token.
+const idTokenLeeway = time.Minute
+
+// jwksMaxAge is how long a provider's keys are cached, and jwksMinRefresh how often they may be fetched
+// again when a token is signed with a key that isn't in the cache. jwksFetchTimeout limits a fetch, which
+// callers share and so can't cancel.
+const (
+	jwksMaxAge       = time.Hour
+	jwksMinRefresh   = time.Minute
+	jwksFetchTimeout = 30 * time.Second
+)
+
+// IDTokenClaims are the claims of an OpenID Connect ID token verified by VerifyIDToken.
+type IDTokenClaims struct {
+	Issuer          string         // iss: the provider which issued the token
+	Subject         string         // sub: the provider's ID for the user
+	Audience        []string       // aud: the clients the token was issued to
+	AuthorizedParty string         // azp: the client the token was issued to, if there are several audiences
+	Nonce           string         // nonce: the nonce from the authorization request
+	ExpiresAt       time.Time      // exp
+	IssuedAt        time.Time      // iat
+	Email           string         // email, if the email scope was granted
+	EmailVerified   bool           // email_verified
+	Name            string         // name, if the profile scope was granted
+	Picture         string         // picture, if the profile scope was granted
+	Raw             map[string]any // all the claims, including any not listed here
+}
+
+// VerifyIDToken verifies an OpenID Connect ID token, as returned in OAuthToken.IDToken, and returns its
+// claims. The signature is checked against the provider's keys at cfg.JWKSURL, which are cached; RS256,
+// RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512 and EdDSA are supported. The token must be issued
+// by exactly cfg.Issuer, which is required, to cfg.ClientID, and must not have expired. If nonce isn't empty,
+// it must match the nonce in the token, as it should when the token comes from a flow started by BuildAuthURL.
+func (t *Tools) VerifyIDToken(ctx context.Context, cfg OAuthConfig, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("oidc: no Issuer configured")
+	}
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("oidc: malformed ID token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("oidc: malformed ID token signature")
+	}
+
+	keys, err := t.jwksKeys(ctx, cfg, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if err := verifyJWTSignature(header.Alg, key, signed, sig); err == nil {
+			verified = true
+			break
+		} else if errors.Is(err, errUnsupportedJWTAlg) {
+			return nil, err
+		}
+	}
+	if !verified {
+		return nil, errors.New("oidc: invalid ID token signature")
+	}
+
+	claims, err := parseIDTokenClaims(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIDTokenClaims(claims, cfg, nonce, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// decodeJWTPart decodes a base64url encoded JSON part of a JWT into v.
+func decodeJWTPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// parseIDTokenClaims decodes the payload of an ID token.
+func parseIDTokenClaims(payload string) (*IDTokenClaims, error) {
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errors.New("oidc: malformed ID token payload")
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("oidc: malformed ID token payload: %w", err)
+	}
+
+	str := func(name string) string {
+		s, _ := raw[name].(string)
+		return s
+	}
+	claims := &IDTokenClaims{
+		Issuer:          str("iss"),
+		Subject:         str("sub"),
+		AuthorizedParty: str("azp"),
+		Nonce:           str("nonce"),
+		Email:           str("email"),
+		Name:            str("name"),
+		Picture:         str("picture"),
+		ExpiresAt:       numericDate(raw["exp"]),
+		IssuedAt:        numericDate(raw["iat"]),
+		Raw:             raw,
+	}
+
+	switch aud := raw["aud"].(type) {
+	case string:
+		claims.Audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				claims.Audience = append(claims.Audience, s)
+			}
+		}
+	}
+
+	// Some providers send email_verified as a string.
+	switch v := raw["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = v
+	case string:
+		claims.EmailVerified = v == "true"
+	}
+	return claims, nil
+}
+
+// numericDate converts a JWT NumericDate, in seconds since the epoch, to a time; anything else is the zero time.
+func numericDate(v any) time.Time {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(f*float64(time.Second)))
+}
+
+// checkIDTokenClaims checks the issuer, audience, times and nonce of an ID token, as of now.
+func checkIDTokenClaims(claims *IDTokenClaims, cfg OAuthConfig, nonce string, now time.Time) error {
+	// OpenID Connect requires the issuer to match exactly, trailing slash and all
+	if claims.Issuer != cfg.Issuer {
+		return fmt.Errorf("oidc: ID token issued by %q, not %q", claims.Issuer, cfg.Issuer)
+	}
+	if !containsString(claims.Audience, cfg.ClientID) {
+		return errors.New("oidc: ID token was not issued to this client")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != "" && claims.AuthorizedParty != cfg.ClientID {
+		return errors.New("oidc: ID token was not issued to this client")
+	}
+	if claims.ExpiresAt.IsZero() {
+		return errors.New("oidc: ID token has no expiry")
+	}
+	if now.After(claims.ExpiresAt.Add(idTokenLeeway)) {
+		return errors.New("oidc: ID token has expired")
+	}
+	if nbf := numericDate(claims.Raw["nbf"]); !nbf.IsZero() && now.Add(idTokenLeeway).Before(nbf) {
+		return errors.New("oidc: ID token is not valid yet")
+	}
+	if !claims.IssuedAt.IsZero() && now.Add(idTokenLeeway).Before(claims.IssuedAt) {
+		return errors.New("oidc: ID token was issued in the future")
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return errors.New("oidc: ID token nonce does not match")
+	}
+	return nil
+}
+
+// ecdsaJWTAlgs is the algorithm for keys on each curve, since each ES algorithm is defined for one curve.
+var ecdsaJWTAlgs = map[string]string{"P-256": "ES256", "P-384": "ES384", "P-521": "ES512"}
+
+// errUnsupportedJWTAlg is returned for tokens signed with an algorithm VerifyIDToken doesn't accept,
+// including "none" and the HMAC algorithms.
+var errUnsupportedJWTAlg = errors.New("oidc: unsupported ID token signing algorithm")
+
+// verifyJWTSignature checks sig, made with alg, over signed against key.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+	default:
+		return errUnsupportedJWTAlg
+	}
+
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	invalid := errors.New("oidc: invalid signature")
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		case "PS":
+			return rsa.VerifyPSS(k, hash, digest, sig, nil)
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg != ecdsaJWTAlgs[k.Curve.Params().Name] || len(sig) != 2*size {
+			return invalid
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if ecdsa.Verify(k, digest, r, s) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if alg == "EdDSA" && ed25519.Verify(k, signed, sig) {
+			return nil
+		}
+	}
+	return invalid
+}
+
+// jwksEntry is a provider's key set, as last fetched.
+type jwksEntry struct {
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// jwksFetch is a fetch of a key set in progress, which callers needing the keys wait for.
+type jwksFetch struct {
+	done  chan struct{} // closed when the fetch is over
+	entry *jwksEntry
+	err   error
+}
+
+// jwksCache holds the key sets fetched by VerifyIDToken, and the fetches in progress, by URL.
+var jwksCache = struct {
+	mu       sync.Mutex
+	entries  map[string]*jwksEntry
+	fetching map[string]*jwksFetch
+}{entries: make(map[string]*jwksEntry), fetching: make(map[string]*jwksFetch)}
+
+// jwksKeys returns the keys at cfg.JWKSURL with the ID kid, or all of them if kid is empty. The key set is
+// fetched again once it is an hour old, or if kid isn't in it, as happens when the provider rotates its
+// keys, but no more than once a minute. Callers needing a key set which is being fetched wait for that
+// fetch rather than starting another, and the cache isn't locked meanwhile, so other providers' keys can be
+// used.
+func (t *Tools) jwksKeys(ctx context.Context, cfg OAuthConfig, kid string) ([]crypto.PublicKey, error) {
+	if cfg.JWKSURL == "" {
+		return nil, errors.New("oidc: no JWKSURL configured")
+	}
+
+	jwksCache.mu.Lock()
+	entry := jwksCache.entries[cfg.JWKSURL]
+	_, known := entry.lookup(kid)
+	if entry == nil || time.Since(entry.fetched) > jwksMaxAge || (!known && time.Since(entry.fetched) > jwksMinRefresh) {
+		f := jwksCache.fetching[cfg.JWKSURL]
+		if f == nil {
+			f = &jwksFetch{done: make(chan struct{})}
+			jwksCache.fetching[cfg.JWKSURL] = f
+			go t.refreshJWKS(ctx, cfg, f)
+		}
+		jwksCache.mu.Unlock()
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if f.err != nil {
+			// keep using the keys we have, if they include the one needed
+			if known {
+				t.LogWarn(ctx, "refreshing JWKS", "url", cfg.JWKSURL, "error", f.err)
+				k, _ := entry.lookup(kid)
+				return k, nil
+			}
+			return nil, f.err
+		}
+		entry = f.entry
+	} else {
+		jwksCache.mu.Unlock()
+	}
+
+	keys, ok := entry.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("oidc: no key with ID %q", kid)
+	}
+	return keys, nil
+}
+
+// refreshJWKS runs the fetch f of the key set at cfg.JWKSURL, caching the keys if it succeeds. The fetch is
+// shared, so it isn't cancelled with ctx when the caller which started it gives up.
+func (t *Tools) refreshJWKS(ctx context.Context, cfg OAuthConfig, f *jwksFetch) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+	defer cancel()
+	keys, err := t.fetchJWKS(ctx, cfg)
+
+	jwksCache.mu.Lock()
+	if err != nil {
+		f.err = err
+	} else {
+		f.entry = &jwksEntry{keys: keys, fetched: time.Now()}
+		jwksCache.entries[cfg.JWKSURL] = f.entry
+	}
+	delete(jwksCache.fetching, cfg.JWKSURL)
+	jwksCache.mu.Unlock()
+	close(f.done)
+}
+
+// lookup returns the key with the ID kid, or every key if kid is empty, and whether there were any.
+func (e *jwksEntry) lookup(kid string) ([]crypto.PublicKey, bool) {
+	if e == nil {
+		return nil, false
+	}
+	if kid != "" {
+		k, ok := e.keys[kid]
+		return []crypto.PublicKey{k}, ok
+	}
+	keys := make([]crypto.PublicKey, 0, len(e.keys))
+	for _, k := range e.keys {
+		keys = append(keys, k)
+	}
+	return keys, len(keys) > 0
+}
+
+// fetchJWKS fetches and parses the key set at cfg.JWKSURL. Keys which aren't for signatures, or are of a
+// type VerifyIDToken doesn't support, are left out.
+func (t *Tools) fetchJWKS(ctx context.Context, cfg OAuthConfig) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := cfg.Client
+	if client == nil {
+		client = t.httpClient()
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return nil, &RemoteError{Status: res.StatusCode, Body: body}
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1024*1024)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("oidc: error decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		kid := k.Kid
+		if kid == "" {
+			kid = fmt.Sprintf("#%d", i)
+		}
+
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			keys[kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		case "OKP":
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			keys[kid] = ed25519.PublicKey(x)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,522 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OpenAPI is a registry of the operations an API serves, described by the same structs their handlers pass
+// to ReadJSON and WriteJSON, from which it generates an OpenAPI 3.1 document. Serve the document with
+// ServeOpenAPI, and check request bodies against it with ValidateOpenAPI. It is safe for concurrent use.
+type OpenAPI struct {
+	Title       string   // title of the API, required by the specification
+	Version     string   // version of the API (not of the specification), required by the specification
+	Description string   // optional description of the API
+	Servers     []string // optional base URLs the API is served from
+
+	mu         sync.RWMutex
+	mux        *http.ServeMux
+	operations map[string]*Operation   // by pattern
+	schemas    map[string]*Schema      // components, by name
+	names      map[reflect.Type]string // component names, by type
+	requests   map[*Operation]*Schema  // request body schemas
+	responses  map[*Operation]*Schema  // response body schemas
+}
+
+// Operation describes one route of an API. Request and Response are values of the types the handler reads
+// and writes, such as CreateBook{} and Book{}, whose schemas are generated from their fields and json tags;
+// a field's doc tag becomes its description. Leave Request nil for operations without a body, and Response
+// nil for those which send none.
+type Operation struct {
+	ID          string      // optional operationId, unique across the API
+	Summary     string      // short summary of what the operation does
+	Description string      // optional longer description
+	Tags        []string    // optional tags, grouping operations in documentation
+	Request     interface{} // value of the type of the request body, or nil
+	Response    interface{} // value of the type of the response body, or nil
+	Status      int         // status of a successful response; defaults to 200
+}
+
+// Schema is a JSON Schema, as used by OpenAPI 3.1, for a Go type. Named struct types are described once,
+// under components/schemas, and referred to with Ref.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"-"`
+	Nullable             bool               `json:"-"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// MarshalJSON encodes the schema, with its type as a list including "null" if it is nullable.
+func (s Schema) MarshalJSON() ([]byte, error) {
+	type schema Schema
+	out := struct {
+		Type interface{} `json:"type,omitempty"`
+		schema
+	}{schema: schema(s)}
+	switch {
+	case s.Type != "" && s.Nullable:
+		out.Type = []string{s.Type, "null"}
+	case s.Type != "":
+		out.Type = s.Type
+	}
+	return json.Marshal(out)
+}
+
+// componentPrefix starts the Ref of a schema under components/schemas.
+const componentPrefix = "#/components/schemas/"
+
+// NewOpenAPI returns an empty registry for the API with the given title and version.
+func NewOpenAPI(title, version string) *OpenAPI {
+	return &OpenAPI{
+		Title:      title,
+		Version:    version,
+		mux:        http.NewServeMux(),
+		operations: make(map[string]*Operation),
+		schemas:    make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+		requests:   make(map[*Operation]*Schema),
+		responses:  make(map[*Operation]*Schema),
+	}
+}
+
+// Add registers the operation served at pattern, in http.ServeMux syntax with the full path, such as
+// "POST /books" or "GET /books/{id}". Like http.ServeMux, it panics if the pattern is invalid or conflicts
+// with one already added. Patterns without a method are described as GET.
+func (api *OpenAPI) Add(pattern string, op Operation) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	o := &op
+	api.mux.Handle(pattern, http.NotFoundHandler())
+	api.operations[pattern] = o
+	if op.Request != nil {
+		api.requests[o] = api.schemaOf(reflect.TypeOf(op.Request))
+	}
+	if op.Response != nil {
+		api.responses[o] = api.schemaOf(reflect.TypeOf(op.Response))
+	}
+}
+
+// Document returns the OpenAPI 3.1 document describing the operations added so far, ready to be encoded
+// as JSON.
+func (api *OpenAPI) Document() map[string]interface{} {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+
+	info := map[string]interface{}{"title": api.Title, "version": api.Version}
+	if api.Description != "" {
+		info["description"] = api.Description
+	}
+	doc := map[string]interface{}{"openapi": "3.1.0", "info": info}
+	if len(api.Servers) > 0 {
+		servers := make([]map[string]string, 0, len(api.Servers))
+		for _, s := range api.Servers {
+			servers = append(servers, map[string]string{"url": s})
+		}
+		doc["servers"] = servers
+	}
+
+	errSchema := &Schema{Type: "object", Required: []string{"error", "message"}, Properties: map[string]*Schema{
+		"error":   {Type: "boolean"},
+		"message": {Type: "string"},
+		"data":    {Description: "details of the error, such as the invalid fields"},
+	}}
+	paths := make(map[string]map[string]interface{})
+	for pattern, op := range api.operations {
+		method, path := splitPattern(pattern)
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		paths[path][strings.ToLower(method)] = api.operationObject(op, path, errSchema)
+	}
+	doc["paths"] = paths
+
+	if len(api.schemas) > 0 {
+		doc["components"] = map[string]interface{}{"schemas": maps.Clone(api.schemas)}
+	}
+	return doc
+}
+
+// operationObject returns the OpenAPI description of op, served at path.
+func (api *OpenAPI) operationObject(op *Operation, path string, errSchema *Schema) map[string]interface{} {
+	obj := make(map[string]interface{})
+	if op.ID != "" {
+		obj["operationId"] = op.ID
+	}
+	if op.Summary != "" {
+		obj["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		obj["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		obj["tags"] = op.Tags
+	}
+
+	var params []map[string]interface{}
+	for _, name := range pathParams(path) {
+		params = append(params, map[string]interface{}{"name": name, "in": "path", "required": true, "schema": &Schema{Type: "string"}})
+	}
+	if len(params) > 0 {
+		obj["parameters"] = params
+	}
+
+	if s, ok := api.requests[op]; ok {
+		obj["requestBody"] = map[string]interface{}{"required": true, "content": jsonContent(s)}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if s, ok := api.responses[op]; ok && status != http.StatusNoContent {
+		success["content"] = jsonContent(s)
+	}
+	obj["responses"] = map[string]interface{}{
+		strconv.Itoa(status): success,
+		"default":            map[string]interface{}{"description": "Error", "content": jsonContent(errSchema)},
+	}
+	return obj
+}
+
+// jsonContent returns an OpenAPI content map with s as the schema of an application/json body.
+func jsonContent(s *Schema) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": s}}
+}
+
+// splitPattern returns the method, GET if there is none, and the path of a ServeMux pattern, in OpenAPI
+// syntax: without a host, {$} or the ... of wildcards matching the rest of the path.
+func splitPattern(pattern string) (method, path string) {
+	method, path, found := strings.Cut(strings.TrimSpace(pattern), " ")
+	if !found {
+		method, path = http.MethodGet, method
+	}
+	path = strings.TrimSpace(path)
+	if i := strings.Index(path, "/"); i > 0 {
+		path = path[i:]
+	}
+	path = strings.ReplaceAll(strings.TrimSuffix(path, "{$}"), "...}", "}")
+	return strings.ToUpper(method), path
+}
+
+// pathParamRegexp matches the wildcards in an OpenAPI path.
+var pathParamRegexp = regexp.MustCompile(`\{([^}]+)\}`)
+
+// pathParams returns the names of the wildcards in an OpenAPI path.
+func pathParams(path string) []string {
+	var names []string
+	for _, m := range pathParamRegexp.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// schemaOf returns the schema for values of type t, as encoding/json encodes them, adding the named
+// structs it uses to the components. The caller must hold the lock.
+func (api *OpenAPI) schemaOf(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time", Nullable: nullable}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string", Nullable: nullable}
+	}
+
+	s := &Schema{Nullable: nullable}
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uintptr:
+		s.Type = "integer"
+	case reflect.Int32, reflect.Uint32:
+		s.Type, s.Format = "integer", "int32"
+	case reflect.Int64, reflect.Uint64:
+		s.Type, s.Format = "integer", "int64"
+	case reflect.Float32:
+		s.Type, s.Format = "number", "float"
+	case reflect.Float64:
+		s.Type, s.Format = "number", "double"
+	case reflect.String:
+		s.Type = "string"
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			s.Type, s.Format = "string", "byte"
+			break
+		}
+		s.Type, s.Items = "array", api.schemaOf(t.Elem())
+	case reflect.Map:
+		s.Type, s.AdditionalProperties = "object", api.schemaOf(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return api.structSchema(t)
+		}
+		return &Schema{Ref: componentPrefix + api.componentName(t)}
+	}
+	return s
+}
+
+// componentUnsafe matches the characters not allowed in a component name.
+var componentUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// componentName returns the name of the component describing the named struct t, adding it if needed. If
+// another type has the same name, its package name is added in front.
+func (api *OpenAPI) componentName(t reflect.Type) string {
+	if name, ok := api.names[t]; ok {
+		return name
+	}
+
+	name := componentUnsafe.ReplaceAllString(t.Name(), "_")
+	if _, taken := api.schemas[name]; taken {
+		pkg := t.PkgPath()
+		base := componentUnsafe.ReplaceAllString(pkg[strings.LastIndex(pkg, "/")+1:], "_") + "." + name
+		name = base
+		for i := 2; ; i++ {
+			if _, taken := api.schemas[name]; !taken {
+				break
+			}
+			name = base + strconv.Itoa(i)
+		}
+	}
+
+	// register the name before describing the struct, so types referring to themselves end
+	api.names[t] = name
+	api.schemas[name] = &Schema{}
+	*api.schemas[name] = *api.structSchema(t)
+	return name
+}
+
+// structSchema returns the object schema for struct type t, with a property for each field encoding/json
+// encodes, including those of embedded structs. Fields are required unless they are pointers or tagged
+// omitempty or omitzero.
+func (api *OpenAPI) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	api.addFields(s, t)
+	return s
+}
+
+// addFields adds the properties for the fields of struct type t to s. Fields of embedded structs are
+// added after t's own, so those take precedence, as with encoding/json.
+func (api *OpenAPI) addFields(s *Schema, t reflect.Type) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := s.Properties[name]; ok {
+			continue
+		}
+
+		options := strings.Split(opts, ",")
+		var prop *Schema
+		if slices.Contains(options, "string") {
+			prop = &Schema{Type: "string"}
+		} else {
+			prop = api.schemaOf(f.Type)
+		}
+		if doc := f.Tag.Get("doc"); doc != "" {
+			if prop.Ref != "" {
+				// siblings of $ref are allowed in 3.1, but copy the ref to keep the component unchanged
+				prop = &Schema{Ref: prop.Ref}
+			}
+			prop.Description = doc
+		}
+		s.Properties[name] = prop
+
+		if f.Type.Kind() != reflect.Pointer && !slices.Contains(options, "omitempty") && !slices.Contains(options, "omitzero") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	for _, et := range embedded {
+		api.addFields(s, et)
+	}
+}
+
+// ServeOpenAPI returns a handler which serves api's OpenAPI document as JSON, such as at
+// GET /openapi.json.
+func (t *Tools) ServeOpenAPI(api *OpenAPI) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = t.WriteJSON(w, http.StatusOK, api.Document())
+	})
+}
+
+// ValidateOpenAPI returns middleware which checks the JSON bodies of requests to operations added to api
+// against their Request schema before the handler reads them, answering 422 Unprocessable Entity, with
+// the invalid fields, if a required field is missing or a value has the wrong type. Requests to routes not
+// in api, and bodies which aren't valid JSON or are too large, are passed on for ReadJSON to refuse.
+func (t *Tools) ValidateOpenAPI(api *OpenAPI) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			schema := api.requestSchema(r)
+			if schema == nil || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			maxBytes := int64(t.bodyLimit(t.MaxJSONSize, defaultMaxUpload))
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+			if err != nil || int64(len(body)) > maxBytes {
+				r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			var value interface{}
+			if dec.Decode(&value) != nil || dec.Decode(&struct{}{}) != io.EOF {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			fields := make(map[string]string)
+			api.mu.RLock()
+			api.validate(schema, value, "", fields)
+			api.mu.RUnlock()
+			if len(fields) > 0 {
+				t.errorResponse(w, r, Unprocessable(fields), http.StatusUnprocessableEntity)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// readCloser reads from one reader, and closes another.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// requestSchema returns the schema of the body of the operation r is for, or nil if there is none.
+func (api *OpenAPI) requestSchema(r *http.Request) *Schema {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+
+	_, pattern := api.mux.Handler(r)
+	if op, ok := api.operations[pattern]; ok {
+		return api.requests[op]
+	}
+	return nil
+}
+
+// validate checks value, decoded with UseNumber, against s, adding a message for each problem to fields,
+// keyed by its path, such as author.name or items[0].quantity. The caller must hold the read lock.
+func (api *OpenAPI) validate(s *Schema, value interface{}, path string, fields map[string]string) {
+	if s.Ref != "" {
+		if ref, ok := api.schemas[strings.TrimPrefix(s.Ref, componentPrefix)]; ok {
+			s = ref
+		}
+	}
+	key := path
+	if key == "" {
+		key = "body"
+	}
+
+	if value == nil {
+		if s.Type != "" && !s.Nullable {
+			fields[key] = "must not be null"
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fields[key] = "must be an object"
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				fields[joinPath(path, name)] = "is required"
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				api.validate(prop, obj[name], joinPath(path, name), fields)
+			} else if s.AdditionalProperties != nil {
+				api.validate(s.AdditionalProperties, obj[name], joinPath(path, name), fields)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fields[key] = "must be an array"
+			return
+		}
+		for i, item := range items {
+			api.validate(s.Items, item, path+"["+strconv.Itoa(i)+"]", fields)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			fields[key] = "must be a string"
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fields[key] = "must be a boolean"
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			fields[key] = "must be a number"
+		}
+	case "integer":
+		if n, ok := value.(json.Number); !ok || strings.ContainsAny(n.String(), ".eE") {
+			fields[key] = "must be an integer"
+		}
+	}
+}
+
+// joinPath returns the path of the property name of the object at path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,198 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type openAPIAuthor struct {
+	Name   string         `json:"name" doc:"full name of the author"`
+	Mentor *openAPIAuthor `json:"mentor,omitempty"`
+}
+
+type openAPIBook struct {
+	ID        int64          `json:"id"`
+	Title     string         `json:"title"`
+	Author    openAPIAuthor  `json:"author"`
+	Tags      []string       `json:"tags,omitempty"`
+	Price     *float64       `json:"price"`
+	Published time.Time      `json:"published"`
+	Meta      map[string]int `json:"meta,omitempty"`
+	Secret    string         `json:"-"`
+	internal  string
+}
+
+type openAPICreateBook struct {
+	Title  string        `json:"title"`
+	Pages  int           `json:"pages"`
+	Author openAPIAuthor `json:"author"`
+	Tags   []string      `json:"tags,omitempty"`
+}
+
+func newTestOpenAPI() *OpenAPI {
+	api := NewOpenAPI("Books", "1.0.0")
+	api.Add("POST /books", Operation{ID: "createBook", Summary: "Add a book", Request: openAPICreateBook{}, Response: openAPIBook{}, Status: http.StatusCreated})
+	api.Add("GET /books/{id}", Operation{ID: "getBook", Response: &openAPIBook{}})
+	api.Add("DELETE /books/{id}", Operation{Status: http.StatusNoContent})
+	api.Add("GET /files/{path...}", Operation{Response: []openAPIBook{}})
+	return api
+}
+
+func TestOpenAPI_Document(t *testing.T) {
+	var testTools Tools
+	api := newTestOpenAPI()
+
+	rr := httptest.NewRecorder()
+	testTools.ServeOpenAPI(api).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title, Version string
+		}
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]struct {
+				Type       interface{}                `json:"type"`
+				Required   []string                   `json:"required"`
+				Properties map[string]json.RawMessage `json:"properties"`
+			}
+		}
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title != "Books" || doc.Info.Version != "1.0.0" {
+		t.Errorf("unexpected header: %s %+v", doc.OpenAPI, doc.Info)
+	}
+	for _, p := range []string{"/books", "/books/{id}", "/files/{path}"} {
+		if doc.Paths[p] == nil {
+			t.Errorf("expected path %s, got %v", p, doc.Paths)
+		}
+	}
+	if len(doc.Paths["/books/{id}"]) != 2 {
+		t.Errorf("expected get and delete on /books/{id}, got %v", doc.Paths["/books/{id}"])
+	}
+
+	create := string(doc.Paths["/books"]["post"])
+	for _, s := range []string{`"operationId":"createBook"`, `"201"`, `"$ref":"#/components/schemas/openAPICreateBook"`, `"$ref":"#/components/schemas/openAPIBook"`} {
+		if !strings.Contains(create, s) {
+			t.Errorf("expected the create operation to contain %s, got %s", s, create)
+		}
+	}
+	if get := string(doc.Paths["/books/{id}"]["get"]); !strings.Contains(get, `"in":"path"`) || !strings.Contains(get, `"name":"id"`) {
+		t.Errorf("expected the id path parameter, got %s", get)
+	}
+	if del := string(doc.Paths["/books/{id}"]["delete"]); !strings.Contains(del, `"204"`) || strings.Contains(del, "requestBody") {
+		t.Errorf("expected a 204 without a body, got %s", del)
+	}
+
+	book, ok := doc.Components.Schemas["openAPIBook"]
+	if !ok {
+		t.Fatalf("expected a book component, got %v", doc.Components.Schemas)
+	}
+	if !slices.Equal(book.Required, []string{"id", "title", "author", "published"}) {
+		t.Errorf("unexpected required fields: %v", book.Required)
+	}
+	tests := map[string]string{
+		"id":        `{"type":"integer","format":"int64"}`,
+		"tags":      `{"type":"array","items":{"type":"string"}}`,
+		"price":     `{"type":["number","null"],"format":"double"}`,
+		"published": `{"type":"string","format":"date-time"}`,
+		"meta":      `{"type":"object","additionalProperties":{"type":"integer"}}`,
+		"author":    `{"$ref":"#/components/schemas/openAPIAuthor"}`,
+	}
+	for name, expected := range tests {
+		if got := string(book.Properties[name]); got != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, got)
+		}
+	}
+	for _, name := range []string{"Secret", "internal"} {
+		if _, ok := book.Properties[name]; ok {
+			t.Errorf("expected %s not to be described", name)
+		}
+	}
+
+	author := doc.Components.Schemas["openAPIAuthor"]
+	if got := string(author.Properties["name"]); got != `{"type":"string","description":"full name of the author"}` {
+		t.Errorf("expected the doc tag as description, got %s", got)
+	}
+	if got := string(author.Properties["mentor"]); got != `{"$ref":"#/components/schemas/openAPIAuthor"}` {
+		t.Errorf("expected a recursive reference, got %s", got)
+	}
+}
+
+func TestOpenAPI_Add_Conflict(t *testing.T) {
+	api := newTestOpenAPI()
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a conflicting pattern to panic")
+		}
+	}()
+	api.Add("POST /books", Operation{})
+}
+
+func TestTools_ValidateOpenAPI(t *testing.T) {
+	var testTools Tools
+	api := newTestOpenAPI()
+	handler := testTools.ValidateOpenAPI(api)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		fields map[string]string
+	}{
+		{name: "valid", method: http.MethodPost, target: "/books", body: `{"title":"Go","pages":300,"author":{"name":"Rob"}}`, status: http.StatusOK},
+		{name: "missing fields", method: http.MethodPost, target: "/books", body: `{"title":"Go"}`, status: http.StatusUnprocessableEntity, fields: map[string]string{"pages": "is required", "author": "is required"}},
+		{name: "wrong types", method: http.MethodPost, target: "/books", body: `{"title":1,"pages":1.5,"author":{"name":null},"tags":["a",2]}`, status: http.StatusUnprocessableEntity, fields: map[string]string{"title": "must be a string", "pages": "must be an integer", "author.name": "must not be null", "tags[1]": "must be a string"}},
+		{name: "not an object", method: http.MethodPost, target: "/books", body: `[]`, status: http.StatusUnprocessableEntity, fields: map[string]string{"body": "must be an object"}},
+		{name: "invalid json passed on", method: http.MethodPost, target: "/books", body: `{"title":`, status: http.StatusOK},
+		{name: "route without a request body", method: http.MethodGet, target: "/books/1", body: `1`, status: http.StatusOK},
+		{name: "unknown route", method: http.MethodPost, target: "/authors", body: `1`, status: http.StatusOK},
+	}
+
+	for _, e := range tests {
+		request := httptest.NewRequest(e.method, e.target, strings.NewReader(e.body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d: %s", e.name, e.status, rr.Code, rr.Body.String())
+		}
+		if e.status == http.StatusOK {
+			if rr.Body.String() != e.body {
+				t.Errorf("%s: expected the handler to read the body, got %s", e.name, rr.Body.String())
+			}
+			continue
+		}
+
+		var resp struct {
+			Data map[string]string `json:"data"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		if len(resp.Data) != len(e.fields) {
+			t.Errorf("%s: expected fields %v, got %v", e.name, e.fields, resp.Data)
+		}
+		for k, v := range e.fields {
+			if resp.Data[k] != v {
+				t.Errorf("%s: expected %s to be %q, got %q", e.name, k, v, resp.Data[k])
+			}
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultOutboxInterval, defaultOutboxBatchSize, defaultOutboxMaxAttempts, defaultOutboxMaxBackoff and
+// defaultOutboxLease are used when OutboxOptions leaves them unset.
+const (
+	defaultOutboxInterval    = time.Second
+	defaultOutboxBatchSize   = 100
+	defaultOutboxMaxAttempts = 10
+	defaultOutboxMaxBackoff  = time.Hour
+	defaultOutboxLease       = time.Minute
+)
+
+// OutboxMessage is a JSON payload kept in an Outbox until it has been posted to its URI.
+type OutboxMessage struct {
+	ID          string
+	URI         string
+	Body        []byte      // the JSON payload
+	Headers     http.Header // extra headers sent with it, such as a webhook signature
+	Attempts    int         // number of failed attempts so far
+	NextAttempt time.Time   // when the message is next due
+	LastError   string      // why the last attempt failed
+	Failed      bool        // whether the dispatcher has given up on the message
+	CreatedAt   time.Time
+}
+
+// OutboxStore keeps the messages of an Outbox. To be sure a message is sent if, and only if, the change it
+// announces is saved, store it in the same database transaction as the change, as toolkitdb's OutboxStore
+// can. MemoryOutboxStore keeps messages in memory, for tests. Implementations must be safe for concurrent use.
+type OutboxStore interface {
+	// Add saves a new message.
+	Add(ctx context.Context, msg OutboxMessage) error
+	// Claim returns up to limit messages which haven't failed and are due at now, oldest first, and
+	// postpones them by lease, so other dispatchers don't send them at the same time.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error)
+	// Delete removes a message once it has been delivered.
+	Delete(ctx context.Context, id string) error
+	// Update saves the Attempts, NextAttempt, LastError and Failed of a message after a failed attempt.
+	Update(ctx context.Context, msg OutboxMessage) error
+}
+
+// OutboxOptions configures NewOutbox.
+type OutboxOptions struct {
+	Store       OutboxStore   // where messages are kept; defaults to a MemoryOutboxStore, which is lost on restart
+	Remote      RemoteOptions // client, headers, authentication and timeout of each attempt; Retries is ignored
+	Interval    time.Duration // how often the dispatcher looks for due messages; defaults to 1 second
+	BatchSize   int           // most messages attempted by one Dispatch; defaults to 100
+	MaxAttempts int           // attempts before a message is marked failed; defaults to 10
+	Backoff     time.Duration // delay before the first retry, doubled for each one after; defaults to 1 second
+	MaxBackoff  time.Duration // longest delay between retries; defaults to 1 hour
+	Lease       time.Duration // time a claimed message is held for its attempt, which is cut off then; defaults to 1 minute
+}
+
+// Outbox delivers JSON payloads to remote URIs reliably: messages are saved to a store first, and a
+// background dispatcher posts them, retrying with backoff until they are delivered, so a crash or an
+// unavailable receiver doesn't lose them. Delivery is at least once, so receivers should be idempotent,
+// for example by using the message ID, which is sent in an Idempotency-Key header.
+type Outbox struct {
+	tools *Tools
+	opts  OutboxOptions
+	now   func() time.Time
+	wake  chan struct{}
+}
+
+// NewOutbox returns an Outbox. Call Start to run its dispatcher.
+func (t *Tools) NewOutbox(opts OutboxOptions) *Outbox {
+	if opts.Store == nil {
+		opts.Store = NewMemoryOutboxStore()
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultOutboxInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultOutboxBatchSize
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultOutboxMaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultJobBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultOutboxMaxBackoff
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaultOutboxLease
+	}
+	opts.Remote.Retries = 0
+
+	return &Outbox{tools: t, opts: opts, now: time.Now, wake: make(chan struct{}, 1)}
+}
+
+// Message returns a new message posting data, encoded as JSON like PushJSONToRemote does, to uri, without
+// storing it. Use it with a store which saves messages in a transaction, such as toolkitdb's OutboxStore.
+func (o *Outbox) Message(uri string, data interface{}, headers ...http.Header) (OutboxMessage, error) {
+	body, err := o.tools.remoteJSONBody(data)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+
+	now := o.now()
+	msg := OutboxMessage{ID: o.tools.RandomString(25), URI: uri, Body: body, NextAttempt: now, CreatedAt: now}
+	if len(headers) > 0 {
+		msg.Headers = headers[0].Clone()
+	}
+	return msg, nil
+}
+
+// PushJSON stores a message posting data as JSON to uri, for the dispatcher to deliver, and wakes the
+// dispatcher so it is sent straight away.
+func (o *Outbox) PushJSON(ctx context.Context, uri string, data interface{}, headers ...http.Header) error {
+	msg, err := o.Message(uri, data, headers...)
+	if err != nil {
+		return err
+	}
+	if err := o.opts.Store.Add(ctx, msg); err != nil {
+		return err
+	}
+	o.Notify()
+	return nil
+}
+
+// Notify wakes the dispatcher, so messages added to the store directly are sent without waiting for
+// the next interval.
+func (o *Outbox) Notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the dispatcher until ctx is cancelled, and returns a channel which is closed once it has
+// stopped. Failures are logged.
+func (o *Outbox) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(o.opts.Interval)
+		defer ticker.Stop()
+		for {
+			// keep going while there are full batches waiting
+			for {
+				n, err := o.Dispatch(ctx)
+				if err != nil && ctx.Err() == nil {
+					o.tools.LogError(ctx, "outbox dispatch failed", "error", err)
+				}
+				if err != nil || n < o.opts.BatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-o.wake:
+			}
+		}
+	}()
+	return done
+}
+
+// Dispatch makes one attempt to deliver each message due, up to BatchSize, and returns how many it
+// attempted. Messages are claimed one at a time, just before their attempt, so a lease never runs out
+// while the message waits for others to be delivered. Start calls it; it is exported for running the
+// outbox from a scheduler or a test.
+func (o *Outbox) Dispatch(ctx context.Context) (int, error) {
+	var errs []error
+	n := 0
+	for n < o.opts.BatchSize {
+		msgs, err := o.opts.Store.Claim(ctx, o.now(), o.opts.Lease, 1)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if len(msgs) == 0 {
+			break
+		}
+		n++
+		if err := o.deliver(ctx, msgs[0]); err != nil {
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// deliver posts msg, then deletes it if it was delivered, or records the failure, returning an error if
+// the store couldn't be updated. The attempt is cut off when the message's lease runs out, since another
+// dispatcher may claim it then; if ctx is done first, the attempt isn't counted, and the message is sent
+// again once its lease has run out.
+func (o *Outbox) deliver(ctx context.Context, msg OutboxMessage) error {
+	opts := o.opts.Remote
+	opts.Headers = opts.Headers.Clone()
+	if opts.Headers == nil {
+		opts.Headers = http.Header{}
+	}
+	for k, v := range msg.Headers {
+		opts.Headers[k] = v
+	}
+	opts.Headers.Set("Idempotency-Key", msg.ID)
+
+	attemptCtx, cancel := context.WithTimeout(ctx, o.opts.Lease)
+	result := o.tools.pushToTarget(attemptCtx, msg.URI, msg.Body, opts)
+	cancel()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if result.Err == nil {
+		return o.opts.Store.Delete(ctx, msg.ID)
+	}
+
+	msg.Attempts++
+	msg.LastError = result.Err.Error()
+	// like doWithRetries, only network errors, 429s and 5xx responses are worth retrying
+	retryable := result.Status == 0 || result.Status == http.StatusTooManyRequests || result.Status >= 500
+	if !retryable || msg.Attempts >= o.opts.MaxAttempts {
+		msg.Failed = true
+		o.tools.LogError(ctx, "outbox message failed", "id", msg.ID, "uri", msg.URI, "attempts", msg.Attempts, "error", result.Err)
+	} else {
+		backoff := o.opts.Backoff
+		for i := 1; i < msg.Attempts && backoff < o.opts.MaxBackoff; i++ {
+			backoff *= 2
+		}
+		msg.NextAttempt = o.now().Add(min(backoff, o.opts.MaxBackoff))
+		o.tools.LogWarn(ctx, "outbox message failed, retrying", "id", msg.ID, "uri", msg.URI, "attempt", msg.Attempts, "error", result.Err)
+	}
+	return o.opts.Store.Update(ctx, msg)
+}
+
+// MemoryOutboxStore is an OutboxStore which keeps messages in memory. They are lost when the process
+// exits, so it is meant for tests and development.
+type MemoryOutboxStore struct {
+	mu       sync.Mutex
+	messages map[string]OutboxMessage
+}
+
+// NewMemoryOutboxStore returns an empty MemoryOutboxStore.
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{messages: make(map[string]OutboxMessage)}
+}
+
+// Add saves a new message.
+func (s *MemoryOutboxStore) Add(ctx context.Context, msg OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages[msg.ID] = msg
+	return nil
+}
+
+// Claim returns the messages due at now, and postpones them by lease.
+func (s *MemoryOutboxStore) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []OutboxMessage
+	for _, msg := range s.messages {
+		if !msg.Failed && !msg.NextAttempt.After(now) {
+			due = append(due, msg)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, msg := range due {
+		claimed := msg
+		claimed.NextAttempt = now.Add(lease)
+		s.messages[msg.ID] = claimed
+	}
+	return due, nil
+}
+
+// Delete removes a message.
+func (s *MemoryOutboxStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.messages, id)
+	return nil
+}
+
+// Update saves a message after a failed attempt.
+func (s *MemoryOutboxStore) Update(ctx context.Context, msg OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.messages[msg.ID]; ok {
+		s.messages[msg.ID] = msg
+	}
+	return nil
+}
+
+// Messages returns the messages in the store, pending and failed, oldest first.
+func (s *MemoryOutboxStore) Messages() []OutboxMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]OutboxMessage, 0, len(s.messages))
+	for _, msg := range s.messages {
+		msgs = append(msgs, msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].CreatedAt.Before(msgs[j].CreatedAt) })
+	return msgs
+}
@@ -0,0 +1,235 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutbox_Dispatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // responses to successive attempts
+		attempts int
+		failed   bool
+		pending  bool
+	}{
+		{name: "delivered", statuses: []int{http.StatusOK}, attempts: 1},
+		{name: "retried", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusAccepted}, attempts: 3},
+		{name: "out of attempts", statuses: []int{500, 500, 500, 500}, attempts: 3, failed: true},
+		{name: "rejected", statuses: []int{http.StatusBadRequest}, attempts: 1, failed: true},
+	}
+
+	for _, e := range tests {
+		var mu sync.Mutex
+		var bodies, keys, signatures []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			b, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(b))
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			signatures = append(signatures, r.Header.Get("X-Signature"))
+			w.WriteHeader(e.statuses[len(bodies)-1])
+		}))
+
+		var testTools Tools
+		store := NewMemoryOutboxStore()
+		outbox := testTools.NewOutbox(OutboxOptions{Store: store, MaxAttempts: 3, Backoff: time.Second})
+		now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		outbox.now = func() time.Time { return now }
+
+		if err := outbox.PushJSON(context.Background(), server.URL, map[string]int{"id": 1}, http.Header{"X-Signature": {"sig"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 5; i++ {
+			if _, err := outbox.Dispatch(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			// the retries are due 1s, then 2s, later
+			now = now.Add(2 * time.Second)
+		}
+		server.Close()
+
+		if len(bodies) != e.attempts {
+			t.Errorf("%s: expected %d attempts, got %d", e.name, e.attempts, len(bodies))
+		}
+		for i := range bodies {
+			if bodies[i] != `{"id":1}` || keys[i] != keys[0] || keys[0] == "" || signatures[i] != "sig" {
+				t.Errorf("%s: unexpected request %d: %s %q %q", e.name, i, bodies[i], keys[i], signatures[i])
+			}
+		}
+
+		msgs := store.Messages()
+		if !e.failed {
+			if len(msgs) != 0 {
+				t.Errorf("%s: expected the delivered message to be deleted, got %+v", e.name, msgs)
+			}
+			continue
+		}
+		if len(msgs) != 1 || !msgs[0].Failed || msgs[0].Attempts != e.attempts || !strings.Contains(msgs[0].LastError, "remote server returned status") {
+			t.Errorf("%s: expected a failed message, got %+v", e.name, msgs)
+		}
+	}
+}
+
+func TestOutbox_Backoff(t *testing.T) {
+	var testTools Tools
+	store := NewMemoryOutboxStore()
+	outbox := testTools.NewOutbox(OutboxOptions{Store: store, Backoff: time.Second, MaxBackoff: 3 * time.Second})
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	outbox.now = func() time.Time { return now }
+
+	// nothing listens on this port, so every attempt fails
+	_ = outbox.PushJSON(context.Background(), "http://127.0.0.1:1/hook", 1)
+
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if n, _ := outbox.Dispatch(context.Background()); n != 1 {
+			t.Fatalf("expected the message to be due, got %d", n)
+		}
+		msg := store.Messages()[0]
+		if got := msg.NextAttempt.Sub(now); got != expected {
+			t.Errorf("attempt %d: expected a backoff of %s, got %s", msg.Attempts, expected, got)
+		}
+		if n, _ := outbox.Dispatch(context.Background()); n != 0 {
+			t.Errorf("expected the message not to be due yet, got %d", n)
+		}
+		now = msg.NextAttempt
+	}
+}
+
+func TestOutbox_Start(t *testing.T) {
+	delivered := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		delivered <- string(b)
+	}))
+	defer server.Close()
+
+	var testTools Tools
+	outbox := testTools.NewOutbox(OutboxOptions{Interval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := outbox.Start(ctx)
+
+	if err := outbox.PushJSON(ctx, server.URL, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-delivered:
+		if body != `["a"]` {
+			t.Errorf("unexpected body %s", body)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected PushJSON to wake the dispatcher")
+	}
+
+	cancel()
+	<-done
+}
+
+func TestOutbox_Dispatch_Context(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var testTools Tools
+	store := NewMemoryOutboxStore()
+	outbox := testTools.NewOutbox(OutboxOptions{Store: store})
+	_ = outbox.PushJSON(context.Background(), server.URL, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := outbox.Dispatch(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the delivery to be cancelled, took %s", elapsed)
+	}
+	if msgs := store.Messages(); len(msgs) != 1 || msgs[0].Attempts != 0 {
+		t.Errorf("expected a cancelled attempt not to be counted, got %+v", msgs)
+	}
+}
+
+// claimRecorder is an OutboxStore recording the times messages are claimed at.
+type claimRecorder struct {
+	*MemoryOutboxStore
+	mu     sync.Mutex
+	claims []time.Time
+}
+
+func (c *claimRecorder) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error) {
+	msgs, err := c.MemoryOutboxStore.Claim(ctx, now, lease, limit)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for range msgs {
+		c.claims = append(c.claims, now)
+	}
+	return msgs, err
+}
+
+func TestOutbox_Lease(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// each delivery takes a minute
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(time.Minute)
+	}))
+	defer server.Close()
+
+	var testTools Tools
+	store := &claimRecorder{MemoryOutboxStore: NewMemoryOutboxStore()}
+	outbox := testTools.NewOutbox(OutboxOptions{Store: store})
+	outbox.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	start := outbox.now()
+	for i := 0; i < 3; i++ {
+		_ = outbox.PushJSON(context.Background(), server.URL, i)
+	}
+
+	if n, err := outbox.Dispatch(context.Background()); n != 3 || err != nil {
+		t.Fatalf("expected 3 messages delivered, got %d and %v", n, err)
+	}
+	for i, claimed := range store.claims {
+		if expected := start.Add(time.Duration(i) * time.Minute); !claimed.Equal(expected) {
+			t.Errorf("expected message %d to be claimed just before its attempt, at %s, got %s", i, expected, claimed)
+		}
+	}
+}
+
+func TestOutbox_LeaseCutsOffAttempt(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var testTools Tools
+	store := NewMemoryOutboxStore()
+	outbox := testTools.NewOutbox(OutboxOptions{Store: store, Lease: 50 * time.Millisecond})
+	_ = outbox.PushJSON(context.Background(), server.URL, 1)
+
+	if _, err := outbox.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	msgs := store.Messages()
+	if len(msgs) != 1 || msgs[0].Attempts != 1 || msgs[0].Failed || !strings.Contains(msgs[0].LastError, "deadline exceeded") {
+		t.Errorf("expected the attempt to be cut off at the lease and retried, got %+v", msgs)
+	}
+}
@@ -0,0 +1,187 @@
+package toolkit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// defaultPerPage and defaultMaxPerPage are the page sizes used when PaginationOptions leaves them unset.
+const (
+	defaultPerPage    = 20
+	defaultMaxPerPage = 100
+)
+
+// PaginationOptions configures NewPaginator.
+type PaginationOptions struct {
+	DefaultPerPage int // page size when the client doesn't ask for one; defaults to 20
+	MaxPerPage     int // largest page size a client may ask for; larger requests are capped; defaults to 100
+}
+
+// Paginator holds the page a client asked for, through the page and per_page query parameters, or the
+// cursor parameter for cursor-based pagination. Once the handler has fetched the page, it sets the total
+// (or next cursor) if it knows it, and passes the Paginator to WritePaginatedJSON.
+type Paginator struct {
+	Page       int    // 1-based page number
+	PerPage    int    // number of items per page
+	Cursor     string // cursor sent by the client, if any
+	total      int
+	hasTotal   bool
+	nextCursor string
+	url        *url.URL
+}
+
+// PaginationMeta is the meta object written by WritePaginatedJSON.
+type PaginationMeta struct {
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	Total      *int   `json:"total,omitempty"`
+	TotalPages *int   `json:"total_pages,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// PaginatedResponse is the body written by WritePaginatedJSON.
+type PaginatedResponse struct {
+	Data any            `json:"data"`
+	Meta PaginationMeta `json:"meta"`
+}
+
+// NewPaginator parses the pagination query parameters of r. A per_page larger than MaxPerPage is capped,
+// but a page or per_page which isn't a positive whole number is an error, as is a page whose offset
+// wouldn't fit in an int.
+func NewPaginator(r *http.Request, opts PaginationOptions) (*Paginator, error) {
+	if opts.DefaultPerPage <= 0 {
+		opts.DefaultPerPage = defaultPerPage
+	}
+	if opts.MaxPerPage <= 0 {
+		opts.MaxPerPage = defaultMaxPerPage
+	}
+
+	page, err := QueryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		return nil, newMessageError(MsgQueryPositive, "", "page")
+	}
+	perPage, err := QueryInt(r, "per_page", opts.DefaultPerPage)
+	if err != nil || perPage < 1 {
+		return nil, newMessageError(MsgQueryPositive, "", "per_page")
+	}
+	perPage = min(perPage, opts.MaxPerPage)
+	// a page so far on that its offset doesn't fit in an int can't hold anything
+	if maxPage := math.MaxInt / perPage; page > maxPage {
+		return nil, newMessageError(MsgQueryMax, "", "page", maxPage)
+	}
+
+	return &Paginator{
+		Page:    page,
+		PerPage: perPage,
+		Cursor:  r.URL.Query().Get("cursor"),
+		url:     r.URL,
+	}, nil
+}
+
+// Offset returns the number of items before the current page, for use in a query's OFFSET clause.
+func (p *Paginator) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// Limit returns the page size, for use in a query's LIMIT clause.
+func (p *Paginator) Limit() int {
+	return p.PerPage
+}
+
+// SetTotal records the total number of items, so the response can include the page count and a link to
+// the last page.
+func (p *Paginator) SetTotal(total int) {
+	p.total, p.hasTotal = total, true
+}
+
+// SetNextCursor records the cursor for the next page, when paginating by cursor. Leave it empty on the
+// last page.
+func (p *Paginator) SetNextCursor(cursor string) {
+	p.nextCursor = cursor
+}
+
+// Meta returns the pagination metadata for a page containing count items, with links relative to the
+// request URL.
+func (p *Paginator) Meta(count int) PaginationMeta {
+	meta := PaginationMeta{PerPage: p.PerPage}
+
+	if p.Cursor != "" || p.nextCursor != "" {
+		meta.NextCursor = p.nextCursor
+		if p.nextCursor != "" {
+			meta.Next = p.link(map[string]string{"cursor": p.nextCursor, "page": ""})
+		}
+		return meta
+	}
+
+	meta.Page = p.Page
+	hasNext := count >= p.PerPage
+	if p.hasTotal {
+		totalPages := (p.total + p.PerPage - 1) / p.PerPage
+		meta.Total, meta.TotalPages = &p.total, &totalPages
+		hasNext = p.Page < totalPages
+	}
+	if hasNext {
+		meta.Next = p.link(map[string]string{"page": strconv.Itoa(p.Page + 1)})
+	}
+	if p.Page > 1 {
+		meta.Prev = p.link(map[string]string{"page": strconv.Itoa(p.Page - 1)})
+	}
+	return meta
+}
+
+// link returns the request URL with the given query parameters replaced; empty values are removed.
+func (p *Paginator) link(params map[string]string) string {
+	u := url.URL{Path: "/"}
+	if p.url != nil {
+		u = *p.url
+	}
+	q := u.Query()
+	for k, v := range params {
+		if v == "" {
+			q.Del(k)
+		} else {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// WritePaginatedJSON writes data, which should be a slice holding the current page, with WriteJSON,
+// wrapped in a PaginatedResponse. The next and previous pages are also linked in a Link header, and the
+// total, if known, is sent in X-Total-Count.
+func (t *Tools) WritePaginatedJSON(w http.ResponseWriter, status int, data any, p *Paginator, headers ...http.Header) error {
+	count := 0
+	if v := reflect.ValueOf(data); v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		count = v.Len()
+	}
+	meta := p.Meta(count)
+
+	h := http.Header{}
+	if len(headers) > 0 {
+		h = headers[0].Clone()
+	}
+
+	var links []string
+	if meta.Next != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", meta.Next))
+	}
+	if meta.Prev != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"prev\"", meta.Prev))
+	}
+	if len(links) > 0 {
+		h.Set("Link", strings.Join(links, ", "))
+	}
+	if meta.Total != nil {
+		h.Set("X-Total-Count", strconv.Itoa(*meta.Total))
+	}
+
+	return t.WriteJSON(w, status, PaginatedResponse{Data: data, Meta: meta}, h)
+}
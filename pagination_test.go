@@ -0,0 +1,94 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var paginatorTests = []struct {
+	name            string
+	query           string
+	total           int
+	count           int
+	nextCursor      string
+	errorExpected   bool
+	expectedOffset  int
+	expectedPerPage int
+	expectedBody    string
+	expectedLink    string
+	expectedTotal   string
+}{
+	{name: "defaults", query: "", total: -1, count: 20, expectedPerPage: 20,
+		expectedBody: `{"data":[],"meta":{"page":1,"per_page":20,"next":"/items?page=2"}}`,
+		expectedLink: `</items?page=2>; rel="next"`},
+	{name: "middle page with total", query: "page=3&per_page=10&sort=name", total: 45, count: 10, expectedOffset: 20, expectedPerPage: 10,
+		expectedBody:  `{"data":[],"meta":{"page":3,"per_page":10,"total":45,"total_pages":5,"next":"/items?page=4\u0026per_page=10\u0026sort=name","prev":"/items?page=2\u0026per_page=10\u0026sort=name"}}`,
+		expectedLink:  `</items?page=4&per_page=10&sort=name>; rel="next", </items?page=2&per_page=10&sort=name>; rel="prev"`,
+		expectedTotal: "45"},
+	{name: "last page", query: "page=5&per_page=10", total: 45, count: 5, expectedOffset: 40, expectedPerPage: 10,
+		expectedBody:  `{"data":[],"meta":{"page":5,"per_page":10,"total":45,"total_pages":5,"prev":"/items?page=4\u0026per_page=10"}}`,
+		expectedLink:  `</items?page=4&per_page=10>; rel="prev"`,
+		expectedTotal: "45"},
+	{name: "short page without total", query: "per_page=10", total: -1, count: 3, expectedPerPage: 10,
+		expectedBody: `{"data":[],"meta":{"page":1,"per_page":10}}`},
+	{name: "capped", query: "per_page=1000", total: -1, count: 0, expectedPerPage: 100,
+		expectedBody: `{"data":[],"meta":{"page":1,"per_page":100}}`},
+	{name: "cursor", query: "cursor=abc&page=2", total: -1, count: 20, nextCursor: "def", expectedOffset: 20, expectedPerPage: 20,
+		expectedBody: `{"data":[],"meta":{"per_page":20,"next_cursor":"def","next":"/items?cursor=def"}}`,
+		expectedLink: `</items?cursor=def>; rel="next"`},
+	{name: "zero page", query: "page=0", errorExpected: true},
+	{name: "bad per page", query: "per_page=many", errorExpected: true},
+	{name: "page overflow", query: "page=9223372036854775807&per_page=100", errorExpected: true},
+	{name: "last page before overflow", query: "page=92233720368547758&per_page=100", total: -1, count: 0, expectedOffset: 9223372036854775700, expectedPerPage: 100,
+		expectedBody: `{"data":[],"meta":{"page":92233720368547758,"per_page":100,"prev":"/items?page=92233720368547757\u0026per_page=100"}}`,
+		expectedLink: `</items?page=92233720368547757&per_page=100>; rel="prev"`},
+}
+
+func TestTools_WritePaginatedJSON(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range paginatorTests {
+		req := httptest.NewRequest(http.MethodGet, "/items?"+e.query, nil)
+
+		p, err := NewPaginator(req, PaginationOptions{})
+		if e.errorExpected {
+			var msgErr *MessageError
+			if !errors.As(err, &msgErr) {
+				t.Errorf("%s: expected a message error but got %v", e.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+
+		if p.Offset() != e.expectedOffset || p.Limit() != e.expectedPerPage {
+			t.Errorf("%s: wrong offset and limit; expected %d, %d but got %d, %d", e.name, e.expectedOffset, e.expectedPerPage, p.Offset(), p.Limit())
+		}
+
+		if e.total >= 0 {
+			p.SetTotal(e.total)
+		}
+		p.SetNextCursor(e.nextCursor)
+
+		// Only the count matters for the links, so send an empty page.
+		meta := p.Meta(e.count)
+		rr := httptest.NewRecorder()
+		_ = testTools.WriteJSON(rr, http.StatusOK, PaginatedResponse{Data: []int{}, Meta: meta})
+		if rr.Body.String() != e.expectedBody {
+			t.Errorf("%s: wrong body;\nexpected %s\n but got %s", e.name, e.expectedBody, rr.Body.String())
+		}
+
+		rr = httptest.NewRecorder()
+		_ = testTools.WritePaginatedJSON(rr, http.StatusOK, make([]int, e.count), p)
+		if rr.Header().Get("Link") != e.expectedLink {
+			t.Errorf("%s: wrong Link header;\nexpected %s\n but got %s", e.name, e.expectedLink, rr.Header().Get("Link"))
+		}
+		if rr.Header().Get("X-Total-Count") != e.expectedTotal {
+			t.Errorf("%s: wrong X-Total-Count; expected %q but got %q", e.name, e.expectedTotal, rr.Header().Get("X-Total-Count"))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidParam is wrapped by the errors ParamInt and ParamUUID return for a path parameter which is
+// missing, or can't be parsed as the requested type.
+var ErrInvalidParam = errors.New("invalid path parameter")
+
+// ParamInt returns the path parameter name, such as the id in "GET /books/{id}", as an int. If it is
+// missing or isn't a base 10 integer, a 400 *HTTPError wrapping ErrInvalidParam is returned, with the
+// parameter in its fields, for ErrorJSON to send.
+func ParamInt(r *http.Request, name string) (int, error) {
+	n, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		return 0, paramError(name, "must be an integer")
+	}
+	return n, nil
+}
+
+// ParamUUID returns the path parameter name as a UUID in its canonical form, 36 lower case hex digits and
+// hyphens, accepting upper case digits. If it is missing or isn't a UUID, a 400 *HTTPError wrapping
+// ErrInvalidParam is returned, with the parameter in its fields.
+func ParamUUID(r *http.Request, name string) (string, error) {
+	v := strings.ToLower(r.PathValue(name))
+	if len(v) != 36 {
+		return "", paramError(name, "must be a UUID")
+	}
+	for i, c := range v {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return "", paramError(name, "must be a UUID")
+			}
+		default:
+			if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+				return "", paramError(name, "must be a UUID")
+			}
+		}
+	}
+	return v, nil
+}
+
+// paramError returns the 400 error for the invalid path parameter name.
+func paramError(name, problem string) error {
+	return &HTTPError{Status: http.StatusBadRequest, Internal: ErrInvalidParam, Fields: map[string]string{name: problem}}
+}
@@ -0,0 +1,73 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParamInt(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected int
+		fails    bool
+	}{
+		{name: "valid", value: "42", expected: 42},
+		{name: "negative", value: "-7", expected: -7},
+		{name: "not a number", value: "abc", fails: true},
+		{name: "missing", fails: true},
+	}
+
+	for _, e := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.SetPathValue("id", e.value)
+		n, err := ParamInt(request, "id")
+		checkParamError(t, e.name, err, e.fails)
+		if n != e.expected {
+			t.Errorf("%s: expected %d, got %d", e.name, e.expected, n)
+		}
+	}
+}
+
+func TestParamUUID(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		fails    bool
+	}{
+		{name: "valid", value: "123e4567-e89b-12d3-a456-426614174000", expected: "123e4567-e89b-12d3-a456-426614174000"},
+		{name: "upper case", value: "123E4567-E89B-12D3-A456-426614174000", expected: "123e4567-e89b-12d3-a456-426614174000"},
+		{name: "no hyphens", value: "123e4567e89b12d3a456426614174000", fails: true},
+		{name: "misplaced hyphen", value: "123e4567e-89b-12d3-a456-42661417400", fails: true},
+		{name: "not hex", value: "123e4567-e89b-12d3-a456-42661417400g", fails: true},
+		{name: "missing", fails: true},
+	}
+
+	for _, e := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.SetPathValue("id", e.value)
+		id, err := ParamUUID(request, "id")
+		checkParamError(t, e.name, err, e.fails)
+		if id != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, id)
+		}
+	}
+}
+
+// checkParamError fails the test unless err is nil, or a 400 error naming the id parameter if fails is set.
+func checkParamError(t *testing.T, name string, err error, fails bool) {
+	t.Helper()
+	if !fails {
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+		return
+	}
+	var httpErr *HTTPError
+	if !errors.Is(err, ErrInvalidParam) || !errors.As(err, &httpErr) || httpErr.Status != http.StatusBadRequest || httpErr.Fields["id"] == "" {
+		t.Errorf("%s: expected a 400 error for the id parameter, got %v", name, err)
+	}
+}
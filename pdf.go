@@ -0,0 +1,845 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFStreamSize the most a single PDF stream is decompressed to, so a small file can't exhaust memory
+const maxPDFStreamSize = 64 << 20
+
+// maxToUnicodeCodes the most character codes a ToUnicode map's ranges may cover in total
+const maxToUnicodeCodes = 1 << 17
+
+// maxPDFDepth how deeply nested objects, references and page trees are followed
+const maxPDFDepth = 64
+
+// ErrPDFEncrypted is returned by PDF.Text for password-protected or otherwise encrypted documents.
+var ErrPDFEncrypted = errors.New("pdf is encrypted")
+
+// PDF is a parsed PDF document, read by ParsePDF or OpenPDF, giving its page count, whether it is
+// encrypted, and its text for search indexing. Only what is needed for that is understood: the page tree,
+// Flate-compressed streams and object streams, and fonts' ToUnicode maps.
+type PDF struct {
+	version   string
+	objects   map[int]any
+	trailer   pdfDict
+	encrypted bool
+}
+
+// pdfDict is a PDF dictionary, keyed by name without the leading slash.
+type pdfDict map[string]any
+
+// pdfName is a PDF name, without the leading slash.
+type pdfName string
+
+// pdfRef is an indirect reference to object num.
+type pdfRef struct {
+	num, gen int
+}
+
+// pdfStream is a stream object: its dictionary and its raw, still encoded, data.
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+// pdfKeyword is a bare word, such as an operator in a content stream.
+type pdfKeyword string
+
+// pdfObjectHeader matches the start of an indirect object, e.g. "12 0 obj".
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// pdfTrailer matches the start of a trailer dictionary.
+var pdfTrailer = regexp.MustCompile(`trailer\s*<<`)
+
+// ParsePDF reads the PDF document in r. An error is returned if it isn't a PDF.
+func ParsePDF(r io.Reader) (*PDF, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	start := bytes.Index(data, []byte("%PDF-"))
+	if start < 0 || start > 1024 {
+		return nil, errors.New("not a PDF document")
+	}
+	p := &PDF{objects: make(map[int]any), trailer: make(pdfDict)}
+	if end := bytes.IndexAny(data[start+5:], "\r\n \t%"); end > 0 && end < 8 {
+		p.version = string(data[start+5 : start+5+end])
+	}
+
+	p.readObjects(data)
+	if _, ok := p.trailer["Root"]; !ok {
+		return nil, errors.New("pdf has no document catalog")
+	}
+	_, p.encrypted = p.trailer["Encrypt"]
+	return p, nil
+}
+
+// OpenPDF is ParsePDF for the file at path.
+func OpenPDF(path string) (*PDF, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePDF(f)
+}
+
+// Version returns the PDF version declared in the header, e.g. "1.7".
+func (p *PDF) Version() string {
+	return p.version
+}
+
+// Encrypted reports whether the document is password-protected or otherwise encrypted.
+func (p *PDF) Encrypted() bool {
+	return p.encrypted
+}
+
+// PageCount returns the number of pages in the document.
+func (p *PDF) PageCount() int {
+	if n := len(p.pages()); n > 0 {
+		return n
+	}
+	// The page tree couldn't be walked, e.g. because it is compressed in an encrypted object stream, so
+	// fall back to what its root says.
+	if pages, ok := p.resolve(p.catalog()["Pages"]).(pdfDict); ok {
+		return pdfInt(p.resolve(pages["Count"]))
+	}
+	return 0
+}
+
+// Text returns the text of every page, in order, with pages separated by form feeds. Text is decoded with
+// fonts' ToUnicode maps where they have one; it is meant for search indexing, so the layout isn't kept.
+func (p *PDF) Text() (string, error) {
+	if p.encrypted {
+		return "", ErrPDFEncrypted
+	}
+
+	var sb strings.Builder
+	for i, page := range p.pages() {
+		if i > 0 {
+			sb.WriteByte('\f')
+		}
+		content, err := p.pageContent(page.dict)
+		if err != nil {
+			return "", err
+		}
+		p.extractText(&sb, content, page.fonts())
+	}
+	return sb.String(), nil
+}
+
+// readObjects reads every indirect object in data, and the trailer, into p. Objects are found by scanning
+// rather than through the cross-reference table, so damaged files can still be read; later definitions of
+// an object replace earlier ones, as incremental updates do.
+func (p *PDF) readObjects(data []byte) {
+	type trailerAt struct {
+		pos  int
+		dict pdfDict
+	}
+	var trailers []trailerAt
+	var objStreams []pdfStream
+
+	for pos := 0; pos < len(data); {
+		loc := pdfObjectHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		ps := &pdfScanner{b: data, pos: pos + loc[1]}
+		v := ps.value(0)
+		end := ps.pos
+
+		if dict, ok := v.(pdfDict); ok {
+			if stream, next, ok := ps.stream(dict); ok {
+				v, end = stream, next
+				switch dict["Type"] {
+				case pdfName("ObjStm"):
+					objStreams = append(objStreams, stream)
+				case pdfName("XRef"):
+					trailers = append(trailers, trailerAt{pos: pos + loc[0], dict: dict})
+				}
+			}
+		}
+		p.objects[num] = v
+		pos = end
+	}
+
+	for _, loc := range pdfTrailer.FindAllIndex(data, -1) {
+		ps := &pdfScanner{b: data, pos: loc[1] - 2}
+		if dict, ok := ps.value(0).(pdfDict); ok {
+			trailers = append(trailers, trailerAt{pos: loc[0], dict: dict})
+		}
+	}
+	sort.Slice(trailers, func(i, j int) bool { return trailers[i].pos < trailers[j].pos })
+	for _, t := range trailers {
+		for k, v := range t.dict {
+			p.trailer[k] = v
+		}
+	}
+
+	for _, stream := range objStreams {
+		p.readObjectStream(stream)
+	}
+}
+
+// readObjectStream adds the objects compressed in an object stream, unless they are defined directly.
+func (p *PDF) readObjectStream(stream pdfStream) {
+	data, err := p.decodeStream(stream)
+	if err != nil {
+		return
+	}
+	n, first := pdfInt(stream.dict["N"]), pdfInt(stream.dict["First"])
+	if first <= 0 || first > len(data) {
+		return
+	}
+
+	header := &pdfScanner{b: data[:first]}
+	for i := 0; i < n; i++ {
+		num, ok1 := header.value(0).(float64)
+		offset, ok2 := header.value(0).(float64)
+		if !ok1 || !ok2 || first+int(offset) >= len(data) || offset < 0 {
+			return
+		}
+		if _, defined := p.objects[int(num)]; defined {
+			continue
+		}
+		ps := &pdfScanner{b: data, pos: first + int(offset)}
+		p.objects[int(num)] = ps.value(0)
+	}
+}
+
+// resolve follows v if it is a reference, returning the object referred to.
+func (p *PDF) resolve(v any) any {
+	for i := 0; i < maxPDFDepth; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = p.objects[ref.num]
+	}
+	return nil
+}
+
+// dict resolves v and returns it if it is a dictionary, or a stream's dictionary.
+func (p *PDF) dict(v any) pdfDict {
+	switch v := p.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// catalog returns the document catalog.
+func (p *PDF) catalog() pdfDict {
+	return p.dict(p.trailer["Root"])
+}
+
+// pdfPage is a page and the resources it has, directly or inherited from the page tree.
+type pdfPage struct {
+	p         *PDF
+	dict      pdfDict
+	resources pdfDict
+}
+
+// fonts returns the fonts in the page's resources, by resource name.
+func (pg pdfPage) fonts() map[string]*pdfFont {
+	fonts := make(map[string]*pdfFont)
+	for name, v := range pg.p.dict(pg.resources["Font"]) {
+		fonts[name] = pg.p.font(pg.p.dict(v))
+	}
+	return fonts
+}
+
+// pages returns the pages of the document, in order.
+func (p *PDF) pages() []pdfPage {
+	var pages []pdfPage
+	visited := make(map[int]bool)
+
+	var walk func(node any, resources pdfDict, depth int)
+	walk = func(node any, resources pdfDict, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+		}
+		dict := p.dict(node)
+		if dict == nil || depth > maxPDFDepth {
+			return
+		}
+		if r := p.dict(dict["Resources"]); r != nil {
+			resources = r
+		}
+
+		kids, ok := p.resolve(dict["Kids"]).([]any)
+		if !ok || dict["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{p: p, dict: dict, resources: resources})
+			return
+		}
+		for _, kid := range kids {
+			walk(kid, resources, depth+1)
+		}
+	}
+	walk(p.catalog()["Pages"], nil, 0)
+	return pages
+}
+
+// pageContent returns the decoded content streams of a page, joined.
+func (p *PDF) pageContent(page pdfDict) ([]byte, error) {
+	contents := p.resolve(page["Contents"])
+	streams, ok := contents.([]any)
+	if !ok {
+		streams = []any{contents}
+	}
+
+	var out []byte
+	for _, s := range streams {
+		stream, ok := p.resolve(s).(pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := p.decodeStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		out = append(append(out, data...), '\n')
+	}
+	return out, nil
+}
+
+// decodeStream returns the data of stream with its filters undone. Only FlateDecode is supported.
+func (p *PDF) decodeStream(stream pdfStream) ([]byte, error) {
+	filters, ok := p.resolve(stream.dict["Filter"]).([]any)
+	if !ok {
+		if f := p.resolve(stream.dict["Filter"]); f != nil {
+			filters = []any{f}
+		}
+	}
+
+	data := stream.data
+	for _, f := range filters {
+		switch p.resolve(f) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("pdf stream: %w", err)
+			}
+			// Keep what can be decompressed of truncated streams, which are common.
+			decoded, err := io.ReadAll(io.LimitReader(zr, maxPDFStreamSize+1))
+			if len(decoded) > maxPDFStreamSize {
+				return nil, errors.New("pdf stream is too large")
+			}
+			if err != nil && len(decoded) == 0 {
+				return nil, fmt.Errorf("pdf stream: %w", err)
+			}
+			data = decoded
+		default:
+			return nil, fmt.Errorf("pdf stream filter %v is not supported", f)
+		}
+	}
+	return data, nil
+}
+
+// pdfFont is what is needed of a font to decode the strings shown with it.
+type pdfFont struct {
+	codeLen int               // the length in bytes of a character code
+	toUni   map[string]string // text by character code, from the ToUnicode map
+}
+
+// font returns the font described by dict.
+func (p *PDF) font(dict pdfDict) *pdfFont {
+	f := &pdfFont{codeLen: 1}
+	if dict["Subtype"] == pdfName("Type0") {
+		f.codeLen = 2
+	}
+	stream, ok := p.resolve(dict["ToUnicode"]).(pdfStream)
+	if !ok {
+		return f
+	}
+	data, err := p.decodeStream(stream)
+	if err != nil {
+		return f
+	}
+	f.toUni = parseToUnicode(data, &f.codeLen)
+	return f
+}
+
+// parseToUnicode returns the mappings of a ToUnicode CMap, setting codeLen from its code space.
+func parseToUnicode(data []byte, codeLen *int) map[string]string {
+	m := make(map[string]string)
+	budget := maxToUnicodeCodes
+	ps := &pdfScanner{b: data}
+	var operands []any
+	for i := 0; i < 1<<20; i++ {
+		v := ps.value(0)
+		if v == nil {
+			break
+		}
+		kw, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+
+		switch kw {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].([]byte); ok && len(lo) > 0 {
+					*codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for j := 0; j+1 < len(operands); j += 2 {
+				src, ok1 := operands[j].([]byte)
+				dst, ok2 := operands[j+1].([]byte)
+				if ok1 && ok2 {
+					m[string(src)] = utf16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for j := 0; j+2 < len(operands); j += 3 {
+				lo, ok1 := operands[j].([]byte)
+				hi, ok2 := operands[j+1].([]byte)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+					continue
+				}
+				start, end := codeValue(lo), codeValue(hi)
+				if end < start || end-start >= uint32(budget) {
+					continue
+				}
+				budget -= int(end-start) + 1
+				for c := start; c <= end; c++ {
+					code := codeBytes(c, len(lo))
+					switch dst := operands[j+2].(type) {
+					case []byte:
+						if len(dst) == 0 {
+							continue
+						}
+						next := append([]byte(nil), dst...)
+						next[len(next)-1] += byte(c - start)
+						m[string(code)] = utf16BE(next)
+					case []any:
+						if int(c-start) < len(dst) {
+							if b, ok := dst[c-start].([]byte); ok {
+								m[string(code)] = utf16BE(b)
+							}
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return m
+}
+
+// codeValue returns the big-endian value of a character code.
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+// codeBytes returns the character code of value v, n bytes long.
+func codeBytes(v uint32, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+// utf16BE decodes UTF-16BE text, as ToUnicode maps hold.
+func utf16BE(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// decode returns the text of string s shown with font f, or with a simple Latin font if f is nil.
+func (f *pdfFont) decode(s []byte) string {
+	if f == nil || (f.toUni == nil && f.codeLen == 1) {
+		var sb strings.Builder
+		for _, c := range s {
+			if c >= 0x80 && c < 0xA0 {
+				sb.WriteRune(windows1252[c-0x80])
+			} else {
+				sb.WriteRune(rune(c))
+			}
+		}
+		return sb.String()
+	}
+	if f.toUni == nil {
+		// a composite font without a ToUnicode map, whose text can't be known
+		return ""
+	}
+
+	var sb strings.Builder
+	for i := 0; i+f.codeLen <= len(s); i += f.codeLen {
+		sb.WriteString(f.toUni[string(s[i:i+f.codeLen])])
+	}
+	return sb.String()
+}
+
+// extractText writes the text shown by a page's content stream to sb.
+func (p *PDF) extractText(sb *strings.Builder, content []byte, fonts map[string]*pdfFont) {
+	var font *pdfFont
+	var operands []any
+	ps := &pdfScanner{b: content}
+
+	space := func(sep byte) {
+		if s := sb.String(); len(s) > 0 && !strings.ContainsAny(s[len(s)-1:], " \n\f") {
+			sb.WriteByte(sep)
+		}
+	}
+
+	for {
+		v := ps.value(0)
+		if v == nil {
+			return
+		}
+		kw, ok := v.(pdfKeyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+
+		switch kw {
+		case "Tf":
+			if len(operands) > 0 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+			}
+		case "Tj", "'", "\"":
+			if kw != "Tj" {
+				space('\n')
+			}
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].([]byte); ok {
+					sb.WriteString(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				parts, _ := operands[len(operands)-1].([]any)
+				for _, part := range parts {
+					switch part := part.(type) {
+					case []byte:
+						sb.WriteString(font.decode(part))
+					case float64:
+						// a large negative adjustment moves right by about a space
+						if part < -200 {
+							space(' ')
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) == 2 && operands[1] != float64(0) {
+				space('\n')
+			} else {
+				space(' ')
+			}
+		case "T*", "ET", "Tm":
+			space('\n')
+		case "ID":
+			ps.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// pdfScanner reads PDF objects from b, starting at pos.
+type pdfScanner struct {
+	b   []byte
+	pos int
+}
+
+// isPDFWhitespace reports whether c is a PDF whitespace character.
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+// isPDFDelimiter reports whether c ends a name, number or keyword.
+func isPDFDelimiter(c byte) bool {
+	return isPDFWhitespace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace skips whitespace and comments.
+func (ps *pdfScanner) skipSpace() {
+	for ps.pos < len(ps.b) {
+		c := ps.b[ps.pos]
+		switch {
+		case isPDFWhitespace(c):
+			ps.pos++
+		case c == '%':
+			for ps.pos < len(ps.b) && ps.b[ps.pos] != '\n' && ps.b[ps.pos] != '\r' {
+				ps.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// value reads the next object: a pdfDict, []any, pdfName, pdfRef, []byte for strings, float64 for
+// numbers, bool, or pdfKeyword for anything else, including null. It returns nil at the end of the input.
+func (ps *pdfScanner) value(depth int) any {
+	ps.skipSpace()
+	if ps.pos >= len(ps.b) || depth > maxPDFDepth {
+		ps.pos = len(ps.b)
+		return nil
+	}
+
+	c := ps.b[ps.pos]
+	switch {
+	case c == '<' && ps.pos+1 < len(ps.b) && ps.b[ps.pos+1] == '<':
+		ps.pos += 2
+		dict := make(pdfDict)
+		for {
+			ps.skipSpace()
+			if ps.pos >= len(ps.b) {
+				return dict
+			}
+			if bytes.HasPrefix(ps.b[ps.pos:], []byte(">>")) {
+				ps.pos += 2
+				return dict
+			}
+			key, ok := ps.value(depth + 1).(pdfName)
+			if !ok {
+				continue
+			}
+			dict[string(key)] = ps.value(depth + 1)
+		}
+
+	case c == '[':
+		ps.pos++
+		var arr []any
+		for {
+			ps.skipSpace()
+			if ps.pos >= len(ps.b) {
+				return arr
+			}
+			if ps.b[ps.pos] == ']' {
+				ps.pos++
+				return arr
+			}
+			arr = append(arr, ps.value(depth+1))
+		}
+
+	case c == '(':
+		return ps.literalString()
+
+	case c == '<':
+		return ps.hexString()
+
+	case c == '/':
+		ps.pos++
+		return pdfName(ps.word())
+
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		n, err := strconv.ParseFloat(ps.word(), 64)
+		if err != nil {
+			return pdfKeyword("")
+		}
+		// "12 0 R" is a reference
+		save := ps.pos
+		if n == float64(int(n)) {
+			ps.skipSpace()
+			gen := ps.word()
+			ps.skipSpace()
+			if g, err := strconv.Atoi(gen); err == nil && ps.pos < len(ps.b) && ps.b[ps.pos] == 'R' &&
+				(ps.pos+1 == len(ps.b) || isPDFDelimiter(ps.b[ps.pos+1])) {
+				ps.pos++
+				return pdfRef{num: int(n), gen: g}
+			}
+		}
+		ps.pos = save
+		return n
+
+	case c == ')' || c == '>' || c == ']' || c == '{' || c == '}':
+		ps.pos++
+		return pdfKeyword(string(c))
+	}
+
+	w := ps.word()
+	if w == "" {
+		// a lone delimiter
+		ps.pos++
+		return pdfKeyword(string(c))
+	}
+	switch w {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return pdfKeyword("null")
+	}
+	return pdfKeyword(w)
+}
+
+// word reads up to the next delimiter.
+func (ps *pdfScanner) word() string {
+	start := ps.pos
+	for ps.pos < len(ps.b) && !isPDFDelimiter(ps.b[ps.pos]) {
+		ps.pos++
+	}
+	return string(ps.b[start:ps.pos])
+}
+
+// literalString reads a string in parentheses, undoing its escapes.
+func (ps *pdfScanner) literalString() []byte {
+	ps.pos++
+	var out []byte
+	nesting := 0
+	for ps.pos < len(ps.b) {
+		c := ps.b[ps.pos]
+		ps.pos++
+		switch c {
+		case '(':
+			nesting++
+		case ')':
+			if nesting == 0 {
+				return out
+			}
+			nesting--
+		case '\\':
+			if ps.pos >= len(ps.b) {
+				return out
+			}
+			c = ps.b[ps.pos]
+			ps.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if ps.pos < len(ps.b) && ps.b[ps.pos] == '\n' {
+					ps.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && ps.pos < len(ps.b) && ps.b[ps.pos] >= '0' && ps.b[ps.pos] <= '7'; i++ {
+						v = v*8 + int(ps.b[ps.pos]-'0')
+						ps.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// hexString reads a string of hex digits in angle brackets.
+func (ps *pdfScanner) hexString() []byte {
+	ps.pos++
+	var digits []byte
+	for ps.pos < len(ps.b) && ps.b[ps.pos] != '>' {
+		if c := ps.b[ps.pos]; strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+		ps.pos++
+	}
+	if ps.pos < len(ps.b) {
+		ps.pos++
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+// stream reads the stream following dict, if there is one, returning it and the position after it.
+func (ps *pdfScanner) stream(dict pdfDict) (pdfStream, int, bool) {
+	ps.skipSpace()
+	if !bytes.HasPrefix(ps.b[ps.pos:], []byte("stream")) {
+		return pdfStream{}, 0, false
+	}
+	start := ps.pos + len("stream")
+	if bytes.HasPrefix(ps.b[start:], []byte("\r\n")) {
+		start += 2
+	} else if start < len(ps.b) && ps.b[start] == '\n' {
+		start++
+	}
+
+	// Trust a direct Length if "endstream" follows it, and otherwise look for "endstream".
+	if n, ok := dict["Length"].(float64); ok && n >= 0 && start+int(n) <= len(ps.b) {
+		end := start + int(n)
+		rest := &pdfScanner{b: ps.b, pos: end}
+		rest.skipSpace()
+		if bytes.HasPrefix(ps.b[rest.pos:], []byte("endstream")) {
+			return pdfStream{dict: dict, data: ps.b[start:end]}, rest.pos + len("endstream"), true
+		}
+	}
+	i := bytes.Index(ps.b[start:], []byte("endstream"))
+	if i < 0 {
+		return pdfStream{dict: dict, data: ps.b[start:]}, len(ps.b), true
+	}
+	data := bytes.TrimSuffix(bytes.TrimSuffix(ps.b[start:start+i], []byte("\n")), []byte("\r"))
+	return pdfStream{dict: dict, data: data}, start + i + len("endstream"), true
+}
+
+// skipInlineImage skips the data of an inline image, up to its EI operator.
+func (ps *pdfScanner) skipInlineImage() {
+	ps.pos++
+	for ps.pos+2 < len(ps.b) {
+		if isPDFWhitespace(ps.b[ps.pos]) && ps.b[ps.pos+1] == 'E' && ps.b[ps.pos+2] == 'I' &&
+			(ps.pos+3 == len(ps.b) || isPDFDelimiter(ps.b[ps.pos+3])) {
+			ps.pos += 3
+			return
+		}
+		ps.pos++
+	}
+	ps.pos = len(ps.b)
+}
+
+// pdfInt returns v as an int, if it is a number.
+func pdfInt(v any) int {
+	n, _ := v.(float64)
+	return int(n)
+}
+
+// isEncryptedPDF reports whether content is an encrypted PDF. Content which can't be parsed isn't.
+func isEncryptedPDF(content io.Reader) bool {
+	doc, err := ParsePDF(content)
+	return err == nil && doc.Encrypted()
+}
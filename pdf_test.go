@@ -0,0 +1,199 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildPDF returns a PDF document holding objects, numbered from 1, with a cross-reference table and a
+// trailer with the extra entries in trailer.
+func buildPDF(trailer string, objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return buf.Bytes()
+}
+
+// pdfStreamObject returns a stream object holding data, Flate-compressed if compress is set.
+func pdfStreamObject(data string, compress bool, dict string) string {
+	if !compress {
+		return fmt.Sprintf("<< /Length %d %s >>\nstream\n%s\nendstream", len(data), dict, data)
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write([]byte(data))
+	_ = zw.Close()
+	return fmt.Sprintf("<< /Length %d /Filter /FlateDecode %s >>\nstream\n%s\nendstream", buf.Len(), dict, buf.String())
+}
+
+// toUnicodeCMap maps the two-byte codes 0x0001 to 0x0005 to "Héllo", and 0x0006 to a space.
+const toUnicodeCMap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+2 beginbfchar
+<0002> <00E9>
+<0006> <0020>
+endbfchar
+2 beginbfrange
+<0001> <0001> <0048>
+<0003> <0005> [<006C> <006C> <006F>]
+endbfrange
+endcmap
+end end`
+
+// testPDFs are PDF documents built for the tests.
+var testPDFs = map[string][]byte{
+	"simple": buildPDF("",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [7 0 R 8 0 R] >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		pdfStreamObject("BT /F1 12 Tf 72 720 Td (Hello, \\(World\\)) Tj 0 -14 Td (Caf\\351 na\\357ve) Tj ET", false, ""),
+		pdfStreamObject("BT /F1 12 Tf 72 720 Td [(Page) -250 (two) 120 (!)] TJ ET", true, ""),
+		pdfStreamObject("q 10 0 0 10 0 0 cm BI /W 2 /H 1 /BPC 8 /CS /G ID \x00\xff EI Q BT /F1 12 Tf (end) ' ET", false, ""),
+	),
+	"type0": buildPDF("",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Noto /Encoding /Identity-H /ToUnicode 6 0 R >>",
+		pdfStreamObject("BT /F1 12 Tf <000100020003000400050006> Tj <0001> Tj ET", true, ""),
+		pdfStreamObject(toUnicodeCMap, true, ""),
+	),
+	"object streams": func() []byte {
+		pages, page := "<< /Type /Pages /Kids [3 0 R] /Count 1 >> ", "<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>"
+		header := fmt.Sprintf("2 0 3 %d ", len(pages))
+		first := len(header)
+		objStm := header + pages + page
+		doc := buildPDF("",
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"",
+			"",
+			pdfStreamObject("BT (Compressed) Tj ET", true, ""),
+			pdfStreamObject(objStm, true, fmt.Sprintf("/Type /ObjStm /N 2 /First %d", first)),
+		)
+		// drop the placeholders for objects 2 and 3, which are only in the object stream
+		doc = bytes.Replace(doc, []byte("2 0 obj\n\nendobj\n"), nil, 1)
+		return bytes.Replace(doc, []byte("3 0 obj\n\nendobj\n"), nil, 1)
+	}(),
+	"encrypted": buildPDF("/Encrypt 4 0 R /ID [<01> <01>]",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Filter /Standard /V 2 /R 3 /O <00> /U <00> /P -3904 >>",
+	),
+}
+
+func TestParsePDF(t *testing.T) {
+	tests := []struct {
+		name      string
+		pdf       []byte
+		pages     int
+		encrypted bool
+		text      string
+		err       error
+	}{
+		{name: "simple", pdf: testPDFs["simple"], pages: 2, text: "Hello, (World)\nCafé naïve\n\fPage two!\nend\n"},
+		{name: "type0", pdf: testPDFs["type0"], pages: 1, text: "Héllo H\n"},
+		{name: "object streams", pdf: testPDFs["object streams"], pages: 1, text: "Compressed\n"},
+		{name: "encrypted", pdf: testPDFs["encrypted"], pages: 1, encrypted: true, err: ErrPDFEncrypted},
+	}
+
+	for _, e := range tests {
+		doc, err := ParsePDF(bytes.NewReader(e.pdf))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		if doc.Version() != "1.7" {
+			t.Errorf("%s: expected version 1.7, got %q", e.name, doc.Version())
+		}
+		if doc.PageCount() != e.pages {
+			t.Errorf("%s: expected %d pages, got %d", e.name, e.pages, doc.PageCount())
+		}
+		if doc.Encrypted() != e.encrypted {
+			t.Errorf("%s: expected encrypted to be %t", e.name, e.encrypted)
+		}
+
+		text, err := doc.Text()
+		if !errors.Is(err, e.err) {
+			t.Errorf("%s: expected error %v, got %v", e.name, e.err, err)
+		}
+		if text != e.text {
+			t.Errorf("%s: expected text %q, got %q", e.name, e.text, text)
+		}
+	}
+
+	for _, bad := range []string{"hello, world", "%PDF-1.4\n1 0 obj << >> endobj\n"} {
+		if _, err := ParsePDF(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestOpenPDF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	_ = os.WriteFile(path, testPDFs["simple"], 0644)
+
+	doc, err := OpenPDF(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if doc.PageCount() != 2 {
+		t.Errorf("expected 2 pages, got %d", doc.PageCount())
+	}
+
+	if _, err := OpenPDF(filepath.Join(t.TempDir(), "missing.pdf")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestTools_UploadFiles_RejectEncryptedPDFs(t *testing.T) {
+	dir := t.TempDir()
+	for name, expectErr := range map[string]bool{"simple": false, "encrypted": true} {
+		path := filepath.Join(t.TempDir(), name+".pdf")
+		_ = os.WriteFile(path, testPDFs[name], 0644)
+
+		testTools := Tools{RejectEncryptedPDFs: true}
+		body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: path}}, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		file, err := testTools.UploadOneFile(request, dir)
+		if expectErr {
+			if err == nil || !strings.Contains(err.Error(), "encrypted") {
+				t.Errorf("%s: expected the upload to be rejected, got %v", name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+			continue
+		}
+		if stored := mustReadFile(t, filepath.Join(dir, file.NewFileName)); !bytes.Equal(stored, testPDFs[name]) {
+			t.Errorf("%s: stored file differs from the upload", name)
+		}
+	}
+}
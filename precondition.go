@@ -0,0 +1,86 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrPreconditionFailed is wrapped by the error CheckPrecondition returns when the resource has changed
+// since the client last read it.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ErrPreconditionRequired is wrapped by the error RequirePrecondition returns for a request without an
+// If-Match or If-Unmodified-Since header.
+var ErrPreconditionRequired = errors.New("precondition required")
+
+// ParseIfMatch returns the entity tags listed in the request's If-Match headers, with their quotes and any
+// W/ prefix, and reports whether the header is * instead, which matches any current version of the
+// resource. Malformed entries are skipped.
+func ParseIfMatch(r *http.Request) (etags []string, wildcard bool) {
+	for _, v := range r.Header.Values("If-Match") {
+		for _, tag := range strings.Split(v, ",") {
+			tag = strings.TrimSpace(tag)
+			switch {
+			case tag == "*":
+				wildcard = true
+			case len(tag) >= 2 && strings.HasPrefix(strings.TrimPrefix(tag, "W/"), `"`) && strings.HasSuffix(tag, `"`):
+				etags = append(etags, tag)
+			}
+		}
+	}
+	return etags, wildcard
+}
+
+// CheckPrecondition evaluates the If-Match and If-Unmodified-Since headers of a request to change a
+// resource, as RFC 9110 specifies, against the resource's current etag and modification time, so updates
+// based on a stale copy are rejected. etag may be given with or without its quotes; leave it empty, and
+// modified zero, if the resource doesn't exist. If-Match requires a strong match, so weak tags never
+// match, and * matches any existing resource. If-Unmodified-Since is only checked without If-Match, and
+// dates which can't be parsed are ignored. A failed precondition is returned as a 412 *HTTPError wrapping
+// ErrPreconditionFailed, for ErrorJSON to send; requests without either header pass.
+func CheckPrecondition(r *http.Request, etag string, modified time.Time) error {
+	if etag != "" && !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+		etag = `"` + etag + `"`
+	}
+
+	if r.Header.Get("If-Match") != "" {
+		tags, wildcard := ParseIfMatch(r)
+		exists := etag != "" || !modified.IsZero()
+		if wildcard && exists {
+			return nil
+		}
+		if etag != "" && !strings.HasPrefix(etag, "W/") {
+			for _, tag := range tags {
+				if tag == etag {
+					return nil
+				}
+			}
+		}
+		return preconditionFailedError()
+	}
+
+	if since := r.Header.Get("If-Unmodified-Since"); since != "" && !modified.IsZero() {
+		t, err := http.ParseTime(since)
+		if err == nil && modified.Truncate(time.Second).After(t) {
+			return preconditionFailedError()
+		}
+	}
+	return nil
+}
+
+// RequirePrecondition returns a 428 *HTTPError wrapping ErrPreconditionRequired if the request has neither
+// an If-Match nor an If-Unmodified-Since header, so clients can't overwrite a resource without saying
+// which version they are changing.
+func RequirePrecondition(r *http.Request) error {
+	if r.Header.Get("If-Match") == "" && r.Header.Get("If-Unmodified-Since") == "" {
+		return &HTTPError{Status: http.StatusPreconditionRequired, PublicMessage: "request must have an If-Match or If-Unmodified-Since header", Internal: ErrPreconditionRequired}
+	}
+	return nil
+}
+
+// preconditionFailedError returns the 412 error for a request whose precondition failed.
+func preconditionFailedError() error {
+	return &HTTPError{Status: http.StatusPreconditionFailed, PublicMessage: "resource has been changed since it was read", Internal: ErrPreconditionFailed}
+}
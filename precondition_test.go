@@ -0,0 +1,99 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  []string
+		expected []string
+		wildcard bool
+	}{
+		{name: "none"},
+		{name: "one", headers: []string{`"abc"`}, expected: []string{`"abc"`}},
+		{name: "list", headers: []string{`"abc", W/"def"`, `"ghi"`}, expected: []string{`"abc"`, `W/"def"`, `"ghi"`}},
+		{name: "wildcard", headers: []string{"*"}, wildcard: true},
+		{name: "malformed", headers: []string{`abc, "def`}},
+	}
+
+	for _, e := range tests {
+		request := httptest.NewRequest(http.MethodPut, "/", nil)
+		for _, h := range e.headers {
+			request.Header.Add("If-Match", h)
+		}
+		etags, wildcard := ParseIfMatch(request)
+		if !slices.Equal(etags, e.expected) || wildcard != e.wildcard {
+			t.Errorf("%s: expected %v and %t, got %v and %t", e.name, e.expected, e.wildcard, etags, wildcard)
+		}
+	}
+}
+
+func TestCheckPrecondition(t *testing.T) {
+	modified := time.Date(2026, 5, 1, 12, 0, 0, 500, time.UTC)
+
+	tests := []struct {
+		name              string
+		ifMatch           string
+		ifUnmodifiedSince string
+		etag              string
+		modified          time.Time
+		fails             bool
+	}{
+		{name: "no headers", etag: `"v2"`},
+		{name: "matching etag", ifMatch: `"v1", "v2"`, etag: `"v2"`},
+		{name: "unquoted etag", ifMatch: `"v2"`, etag: "v2"},
+		{name: "stale etag", ifMatch: `"v1"`, etag: `"v2"`, fails: true},
+		{name: "weak tags never match", ifMatch: `W/"v2"`, etag: `W/"v2"`, fails: true},
+		{name: "wildcard, exists", ifMatch: "*", etag: `"v2"`},
+		{name: "wildcard, missing", ifMatch: "*", fails: true},
+		{name: "if-match wins", ifMatch: `"v2"`, ifUnmodifiedSince: "Fri, 01 May 2026 11:00:00 GMT", etag: `"v2"`, modified: modified},
+		{name: "unmodified", ifUnmodifiedSince: "Fri, 01 May 2026 12:00:00 GMT", modified: modified},
+		{name: "modified", ifUnmodifiedSince: "Fri, 01 May 2026 11:59:59 GMT", modified: modified, fails: true},
+		{name: "bad date ignored", ifUnmodifiedSince: "yesterday", modified: modified},
+	}
+
+	for _, e := range tests {
+		request := httptest.NewRequest(http.MethodPut, "/books/1", nil)
+		if e.ifMatch != "" {
+			request.Header.Set("If-Match", e.ifMatch)
+		}
+		if e.ifUnmodifiedSince != "" {
+			request.Header.Set("If-Unmodified-Since", e.ifUnmodifiedSince)
+		}
+
+		err := CheckPrecondition(request, e.etag, e.modified)
+		if !e.fails {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", e.name, err)
+			}
+			continue
+		}
+		var httpErr *HTTPError
+		if !errors.Is(err, ErrPreconditionFailed) || !errors.As(err, &httpErr) || httpErr.Status != http.StatusPreconditionFailed {
+			t.Errorf("%s: expected a 412 error, got %v", e.name, err)
+		}
+	}
+}
+
+func TestRequirePrecondition(t *testing.T) {
+	var testTools Tools
+	request := httptest.NewRequest(http.MethodPut, "/books/1", nil)
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, RequirePrecondition(request))
+	if rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("expected 428, got %d", rr.Code)
+	}
+
+	request.Header.Set("If-Match", `"v1"`)
+	if err := RequirePrecondition(request); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
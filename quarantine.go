@@ -0,0 +1,252 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileScanner checks the content of uploads, e.g. with an antivirus engine, before UploadFiles stores them.
+type FileScanner interface {
+	// ScanFile reads the content of the file uploaded as name, and returns an error if it must be rejected.
+	ScanFile(ctx context.Context, name string, content io.Reader) error
+}
+
+// FileScannerFunc is an adapter allowing an ordinary function to be used as a FileScanner.
+type FileScannerFunc func(ctx context.Context, name string, content io.Reader) error
+
+// ScanFile calls f(ctx, name, content).
+func (f FileScannerFunc) ScanFile(ctx context.Context, name string, content io.Reader) error {
+	return f(ctx, name, content)
+}
+
+// QuarantineRecord describes an upload which was rejected, and kept in QuarantineDir for review instead of
+// being discarded. It is written next to the file, as <id>.meta.json.
+type QuarantineRecord struct {
+	ID               string    `json:"id"`                 // the name of the file in QuarantineDir
+	OriginalFileName string    `json:"original_file_name"` // the name the file was uploaded with
+	FileName         string    `json:"file_name"`          // the name the file is stored under if it is released
+	UploadDir        string    `json:"upload_dir"`         // the directory the file was uploaded to
+	ContentType      string    `json:"content_type"`
+	Size             int64     `json:"size"`
+	SHA256           string    `json:"sha256"`
+	Reason           string    `json:"reason"` // why the file was rejected
+	UploaderID       string    `json:"uploader_id,omitempty"`
+	QuarantinedAt    time.Time `json:"quarantined_at"`
+}
+
+// validateUpload checks the extension of an upload against FileNames, its type and size against
+// AllowedFileTypes, AllowedTypes and FileSignatures, rejects encrypted PDFs if RejectEncryptedPDFs is set,
+// and passes its content to FileScanner, leaving it rewound.
+func (t *Tools) validateUpload(ctx context.Context, hdr *multipart.FileHeader, content io.ReadSeeker, fileType string) error {
+	if err := t.FileNames.checkExtension(t.FileNames.cleanFileName(hdr.Filename)); err != nil {
+		return err
+	}
+	maxSize, err := t.checkFileType(fileType)
+	if err != nil {
+		return err
+	}
+	if maxSize > 0 && hdr.Size > maxSize {
+		return fmt.Errorf("%s files must not be larger than %s", fileType, HumanBytes(maxSize))
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if t.RejectEncryptedPDFs && fileType == "application/pdf" {
+		encrypted := isEncryptedPDF(content)
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if encrypted {
+			return errors.New("encrypted or password-protected PDF files are not allowed")
+		}
+	}
+
+	if t.FileScanner == nil {
+		return nil
+	}
+	if err := t.FileScanner.ScanFile(ctx, hdr.Filename, content); err != nil {
+		return fmt.Errorf("file rejected by scanner: %w", err)
+	}
+	_, err = content.Seek(0, io.SeekStart)
+	return err
+}
+
+// quarantineUpload moves a rejected upload into QuarantineDir, with a record of why it was rejected. Failing
+// to quarantine the file is logged rather than returned, as the upload has failed either way.
+func (t *Tools) quarantineUpload(ctx context.Context, uploadDir, fileName string, hdr *multipart.FileHeader, content io.ReadSeeker, fileType string, reason error) {
+	rec := QuarantineRecord{
+		ID:               t.RandomString(25),
+		OriginalFileName: hdr.Filename,
+		FileName:         fileName,
+		UploadDir:        uploadDir,
+		ContentType:      fileType,
+		Reason:           reason.Error(),
+		UploaderID:       UploaderIDFromContext(ctx),
+		QuarantinedAt:    time.Now().UTC(),
+	}
+	if err := t.writeQuarantine(&rec, content); err != nil {
+		t.LogError(ctx, "could not quarantine upload", "file", hdr.Filename, "reason", rec.Reason, "error", err)
+		return
+	}
+	t.LogWarn(ctx, "upload quarantined", "id", rec.ID, "file", hdr.Filename, "reason", rec.Reason)
+}
+
+// writeQuarantine writes content and its record to QuarantineDir, filling in the size and hash of rec.
+func (t *Tools) writeQuarantine(rec *QuarantineRecord, content io.ReadSeeker) error {
+	if err := t.CreateDirIfNotExist(t.QuarantineDir); err != nil {
+		return err
+	}
+	p, err := t.EnsureWithinBase(t.QuarantineDir, rec.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	rec.Size, err = writeFileAtomic(p, io.TeeReader(content, hash), t.SyncUploadDir)
+	if err != nil {
+		return err
+	}
+	rec.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	out, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := writeFileAtomic(p+uploadMetadataSuffix, bytes.NewReader(out), t.SyncUploadDir); err != nil {
+		_ = os.Remove(p)
+		return err
+	}
+	return nil
+}
+
+// ListQuarantine returns the records of the files in QuarantineDir, oldest first, for an admin to review.
+func (t *Tools) ListQuarantine() ([]QuarantineRecord, error) {
+	if t.QuarantineDir == "" {
+		return nil, errors.New("QuarantineDir is not set")
+	}
+	entries, err := os.ReadDir(t.QuarantineDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []QuarantineRecord
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), uploadMetadataSuffix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		rec, err := t.quarantineRecord(id)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].QuarantinedAt.Before(records[j].QuarantinedAt)
+	})
+	return records, nil
+}
+
+// quarantineRecord reads the record of the quarantined file id.
+func (t *Tools) quarantineRecord(id string) (QuarantineRecord, error) {
+	var rec QuarantineRecord
+	if t.QuarantineDir == "" {
+		return rec, errors.New("QuarantineDir is not set")
+	}
+	p, err := t.EnsureWithinBase(t.QuarantineDir, id+uploadMetadataSuffix)
+	if err != nil {
+		return rec, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return rec, fmt.Errorf("quarantine record %s: %w", id, err)
+	}
+	rec.ID = id
+	return rec, nil
+}
+
+// ReleaseQuarantine moves the quarantined file id, found to be a false positive, to the directory it was
+// uploaded to, and records it as UploadFiles would have: with DuplicateChecker and its metadata. It returns
+// the stored file.
+func (t *Tools) ReleaseQuarantine(ctx context.Context, id string) (*UploadedFile, error) {
+	rec, err := t.quarantineRecord(id)
+	if err != nil {
+		return nil, err
+	}
+	src, err := t.EnsureWithinBase(t.QuarantineDir, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.CreateDirIfNotExist(rec.UploadDir); err != nil {
+		return nil, err
+	}
+	dst, err := t.EnsureWithinBase(rec.UploadDir, rec.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	size, err := writeFileAtomic(dst, in, t.SyncUploadDir)
+	in.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	file := &UploadedFile{
+		NewFileName:      rec.FileName,
+		OriginalFileName: rec.OriginalFileName,
+		FileSize:         size,
+		ContentType:      rec.ContentType,
+		SHA256:           rec.SHA256,
+	}
+	ctx = WithUploaderID(ctx, rec.UploaderID)
+	if t.DuplicateChecker != nil {
+		if err := t.DuplicateChecker.RecordFile(ctx, file.SHA256, file.NewFileName); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.saveUploadMetadata(ctx, rec.UploadDir, file); err != nil {
+		return nil, err
+	}
+
+	return file, t.DeleteQuarantine(id)
+}
+
+// DeleteQuarantine removes the quarantined file id and its record, once it has been reviewed.
+func (t *Tools) DeleteQuarantine(id string) error {
+	if _, err := t.quarantineRecord(id); err != nil {
+		return err
+	}
+	p, err := t.EnsureWithinBase(t.QuarantineDir, id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Remove(p + uploadMetadataSuffix)
+}
@@ -0,0 +1,153 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTools_UploadFiles_Quarantine(t *testing.T) {
+	infected := FileScannerFunc(func(ctx context.Context, name string, content io.Reader) error {
+		if _, err := io.ReadAll(content); err != nil {
+			return err
+		}
+		return errors.New("Eicar-Test-Signature")
+	})
+
+	tests := []struct {
+		name          string
+		tools         Tools
+		expectedError string
+		quarantined   bool
+	}{
+		{name: "scanner", tools: Tools{FileScanner: infected}, expectedError: "file rejected by scanner: Eicar-Test-Signature", quarantined: true},
+		{name: "file type", tools: Tools{AllowedFileTypes: []string{"image/jpeg"}}, expectedError: "file type not allowed: image/png", quarantined: true},
+		{name: "type size", tools: Tools{AllowedTypes: []TypeRule{{Pattern: "image/png", MaxSize: 10}}}, expectedError: "image/png files must not be larger than 10 bytes", quarantined: true},
+		{name: "accepted", tools: Tools{FileScanner: FileScannerFunc(func(context.Context, string, io.Reader) error { return nil })}},
+	}
+
+	for _, e := range tests {
+		uploadDir := t.TempDir()
+		e.tools.QuarantineDir = t.TempDir()
+
+		body, contentType := e.tools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+		request = request.WithContext(WithUploaderID(request.Context(), "user-1"))
+
+		_, err := e.tools.UploadOneFile(request, uploadDir)
+		if e.expectedError == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		}
+		if e.expectedError != "" && (err == nil || err.Error() != e.expectedError) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.expectedError, err)
+		}
+
+		records, err := e.tools.ListQuarantine()
+		if err != nil {
+			t.Fatalf("%s: unexpected error listing the quarantine: %s", e.name, err)
+		}
+		if !e.quarantined {
+			if len(records) != 0 {
+				t.Errorf("%s: expected nothing quarantined, got %+v", e.name, records)
+			}
+			continue
+		}
+		if len(records) != 1 {
+			t.Fatalf("%s: expected one quarantined file, got %d", e.name, len(records))
+		}
+
+		rec := records[0]
+		info, _ := os.Stat("./testdata/img.png")
+		if rec.OriginalFileName != "img.png" || rec.ContentType != "image/png" || rec.Size != info.Size() || len(rec.SHA256) != 64 ||
+			rec.Reason != e.expectedError || rec.UploaderID != "user-1" || rec.UploadDir != uploadDir || filepath.Ext(rec.FileName) != ".png" {
+			t.Errorf("%s: unexpected record %+v", e.name, rec)
+		}
+		if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+			t.Errorf("%s: expected nothing stored in the upload directory, got %d files", e.name, len(entries))
+		}
+	}
+}
+
+func TestTools_ReleaseQuarantine(t *testing.T) {
+	uploadDir := t.TempDir()
+	checker := NewMemoryDuplicateChecker()
+	testTools := Tools{
+		QuarantineDir:       t.TempDir(),
+		AllowedFileTypes:    []string{"image/jpeg"},
+		DuplicateChecker:    checker,
+		WriteUploadMetadata: true,
+	}
+
+	body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Add("Content-Type", contentType)
+	if _, err := testTools.UploadFiles(request, uploadDir); err == nil {
+		t.Fatal("expected the upload to be rejected")
+	}
+
+	records, _ := testTools.ListQuarantine()
+	if len(records) != 1 {
+		t.Fatalf("expected one quarantined file, got %d", len(records))
+	}
+
+	file, err := testTools.ReleaseQuarantine(context.Background(), records[0].ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if file.NewFileName != records[0].FileName || file.OriginalFileName != "img.png" || file.SHA256 != records[0].SHA256 {
+		t.Errorf("unexpected file %+v", file)
+	}
+
+	want, _ := os.ReadFile("./testdata/img.png")
+	got, err := os.ReadFile(filepath.Join(uploadDir, file.NewFileName))
+	if err != nil || string(got) != string(want) {
+		t.Errorf("released file not stored intact: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(uploadDir, file.NewFileName+uploadMetadataSuffix)); err != nil {
+		t.Errorf("expected the metadata sidecar to be written: %s", err)
+	}
+	if name, ok, _ := checker.FindDuplicate(context.Background(), file.SHA256); !ok || name != file.NewFileName {
+		t.Errorf("released file not recorded with the duplicate checker; got %q, %t", name, ok)
+	}
+
+	if records, _ := testTools.ListQuarantine(); len(records) != 0 {
+		t.Errorf("expected the quarantine to be empty, got %+v", records)
+	}
+	if _, err := testTools.ReleaseQuarantine(context.Background(), records[0].ID); err == nil {
+		t.Error("expected an error releasing a file twice")
+	}
+}
+
+func TestTools_DeleteQuarantine(t *testing.T) {
+	testTools := Tools{QuarantineDir: t.TempDir()}
+
+	rec := QuarantineRecord{ID: "abc", Reason: "virus"}
+	if err := testTools.writeQuarantine(&rec, strings.NewReader("X5O!P%@AP")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"../abc", "missing"} {
+		if err := testTools.DeleteQuarantine(id); err == nil {
+			t.Errorf("%s: expected an error", id)
+		}
+	}
+
+	if err := testTools.DeleteQuarantine("abc"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if entries, _ := os.ReadDir(testTools.QuarantineDir); len(entries) != 0 {
+		t.Errorf("expected the quarantine directory to be empty, got %d entries", len(entries))
+	}
+
+	var unset Tools
+	if _, err := unset.ListQuarantine(); err == nil {
+		t.Error("expected an error without QuarantineDir")
+	}
+}
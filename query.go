@@ -0,0 +1,81 @@
+package toolkit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadQuery reads the request's query parameters into data, which must be a pointer to a struct. Fields
+// are matched by their `query:"name"` tag, and converted the same way as by ReadForm. Parameters without
+// a matching field are ignored, since links often carry tracking parameters the handler doesn't care about.
+func (t *Tools) ReadQuery(r *http.Request, data any) error {
+	return decodeValues("query string", r.URL.Query(), data, "query", true)
+}
+
+// QueryInt returns the query parameter name as an int, or def if it is missing or empty. An error is
+// returned if it isn't a whole number.
+func QueryInt(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def, newMessageError(MsgQueryInt, "", name)
+	}
+	return n, nil
+}
+
+// QueryBool returns the query parameter name as a bool, or def if it is missing or empty. Besides the
+// values strconv.ParseBool accepts, "on", "yes" and "no" are understood. An error is returned for anything
+// else.
+func QueryBool(r *http.Request, name string, def bool) (bool, error) {
+	s := strings.ToLower(r.URL.Query().Get(name))
+	switch s {
+	case "":
+		return def, nil
+	case "on", "yes":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return def, newMessageError(MsgQueryBool, "", name)
+	}
+	return b, nil
+}
+
+// QueryTime returns the query parameter name as a time, or def if it is missing or empty. RFC 3339 and
+// plain dates (2006-01-02) are accepted, and an error is returned for anything else.
+func QueryTime(r *http.Request, name string, def time.Time) (time.Time, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	tm, err := parseFormTime(s)
+	if err != nil {
+		return def, newMessageError(MsgQueryTime, "", name)
+	}
+	return tm, nil
+}
+
+// QueryStringSlice returns every value of the query parameter name, splitting comma-separated values, so
+// that both ?tag=a&tag=b and ?tag=a,b give [a b]. Empty values are dropped, and def is returned if none
+// are left.
+func QueryStringSlice(r *http.Request, name string, def []string) []string {
+	var out []string
+	for _, v := range r.URL.Query()[name] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return def
+	}
+	return out
+}
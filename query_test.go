@@ -0,0 +1,77 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type listQuery struct {
+	Page   int       `query:"page"`
+	Status []string  `query:"status"`
+	Since  time.Time `query:"since"`
+	Active *bool     `query:"active"`
+}
+
+func TestTools_ReadQuery(t *testing.T) {
+	var testTools Tools
+
+	req := httptest.NewRequest(http.MethodGet, "/items?page=2&status=open&status=closed&since=2024-01-02&active=false&utm_source=mail", nil)
+
+	var got listQuery
+	if err := testTools.ReadQuery(req, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	active := false
+	expected := listQuery{Page: 2, Status: []string{"open", "closed"}, Since: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Active: &active}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong result;\nexpected %+v\n but got %+v", expected, got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/items?page=two", nil)
+	err := testTools.ReadQuery(req, &got)
+	if err == nil || err.Error() != `query string contains an invalid value for field "page"` {
+		t.Errorf("wrong error %v", err)
+	}
+}
+
+var queryGetterTests = []struct {
+	name          string
+	query         string
+	get           func(r *http.Request) (any, error)
+	expected      any
+	errorExpected string
+}{
+	{name: "int", query: "n=42", get: func(r *http.Request) (any, error) { return QueryInt(r, "n", 1) }, expected: 42},
+	{name: "int default", query: "", get: func(r *http.Request) (any, error) { return QueryInt(r, "n", 1) }, expected: 1},
+	{name: "int invalid", query: "n=4.2", get: func(r *http.Request) (any, error) { return QueryInt(r, "n", 1) }, expected: 1, errorExpected: `query parameter "n" must be a whole number`},
+	{name: "bool", query: "b=true", get: func(r *http.Request) (any, error) { return QueryBool(r, "b", false) }, expected: true},
+	{name: "bool yes", query: "b=YES", get: func(r *http.Request) (any, error) { return QueryBool(r, "b", false) }, expected: true},
+	{name: "bool default", query: "b=", get: func(r *http.Request) (any, error) { return QueryBool(r, "b", true) }, expected: true},
+	{name: "bool invalid", query: "b=maybe", get: func(r *http.Request) (any, error) { return QueryBool(r, "b", false) }, expected: false, errorExpected: `query parameter "b" must be true or false`},
+	{name: "time", query: "t=2024-03-04T05:06:07Z", get: func(r *http.Request) (any, error) { return QueryTime(r, "t", time.Time{}) }, expected: time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)},
+	{name: "time invalid", query: "t=tomorrow", get: func(r *http.Request) (any, error) { return QueryTime(r, "t", time.Time{}) }, expected: time.Time{}, errorExpected: `query parameter "t" must be a date or an RFC 3339 time`},
+	{name: "slice", query: "s=a,b&s=c&s=", get: func(r *http.Request) (any, error) { return QueryStringSlice(r, "s", nil), nil }, expected: []string{"a", "b", "c"}},
+	{name: "slice default", query: "s=,", get: func(r *http.Request) (any, error) { return QueryStringSlice(r, "s", []string{"x"}), nil }, expected: []string{"x"}},
+}
+
+func TestQueryGetters(t *testing.T) {
+	for _, e := range queryGetterTests {
+		req := httptest.NewRequest(http.MethodGet, "/?"+e.query, nil)
+
+		got, err := e.get(req)
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.errorExpected, err)
+		}
+		if !reflect.DeepEqual(got, e.expected) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitStore keeps the token buckets used by the RateLimit middleware. The in-memory store suits a
+// single instance; implement this interface on top of a shared store such as Redis to limit across
+// instances.
+type RateLimitStore interface {
+	// Allow takes a token from the bucket for key, which refills at rate tokens per second up to burst
+	// tokens. It reports whether a token was available and, if not, how long until one will be.
+	Allow(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+}
+
+// RateLimitOptions configures the RateLimit middleware.
+type RateLimitOptions struct {
+	Rate    float64                      // requests allowed per second, on average; zero or less turns limiting off
+	Burst   int                          // requests allowed in a burst; defaults to 1
+	KeyFunc func(r *http.Request) string // returns the key to limit by; defaults to ClientIP
+	Store   RateLimitStore               // where buckets are kept; defaults to a new MemoryRateLimitStore
+}
+
+// RateLimit returns middleware which limits requests using a token bucket per key (the client IP, as
+// returned by ClientIP, unless KeyFunc is set). Requests authenticated by APIKeyAuth with a key which sets
+// its own Rate are limited per key at that rate instead, and requests for a tenant resolved by
+// TenantResolver are counted separately for each tenant. Requests over the limit get 429 Too Many Requests
+// through ErrorJSON, with a Retry-After header. If the store fails, the error is logged and the request is
+// allowed, so a store outage doesn't take the service down with it. If Rate isn't positive, requests aren't
+// limited, except for those made with API keys which set their own Rate.
+func (t *Tools) RateLimit(opts RateLimitOptions) Middleware {
+	if opts.Burst < 1 {
+		opts.Burst = 1
+	}
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = t.ClientIP
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryRateLimitStore()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, rate, burst := opts.KeyFunc(r), opts.Rate, opts.Burst
+			// API keys with a rate of their own are limited separately, at that rate.
+			if apiKey := APIKeyFromContext(r.Context()); apiKey != nil && apiKey.Rate > 0 {
+				key, rate, burst = "apikey:"+apiKey.ID, apiKey.Rate, max(apiKey.Burst, 1)
+			}
+			if tenant := TenantFromContext(r.Context()); tenant != nil {
+				key = "tenant:" + tenant.ID + ":" + key
+			}
+			if rate <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, retryAfter, err := opts.Store.Allow(r.Context(), key, rate, burst)
+			if err != nil {
+				t.LogError(r.Context(), "rate limit store error", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				_ = t.ErrorJSON(w, errors.New("too many requests"), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// remoteIP returns the IP address of the connection the request arrived on.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tokenBucket is the state of a single key in a MemoryRateLimitStore.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // when the bucket will have refilled completely at its own rate; zero if it never will
+}
+
+// MemoryRateLimitStore is a RateLimitStore which keeps buckets in memory. Buckets which have refilled
+// completely are discarded from time to time, so memory use follows the number of active keys.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket for key, creating a full bucket if there isn't one.
+func (m *MemoryRateLimitStore) Allow(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		m.buckets[key] = b
+	}
+
+	// refill for the time since the bucket was last used
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = time.Time{}
+	if rate > 0 {
+		b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	}
+	if allowed {
+		return true, 0, nil
+	}
+
+	if rate <= 0 {
+		return false, time.Hour, nil
+	}
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second)), nil
+}
+
+// sweep discards buckets which would have refilled completely, at most once a minute. Each bucket is judged
+// by the rate and burst it was last used with, since keys such as API keys' are limited at rates of their own.
+func (m *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		if !b.full.IsZero() && now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	// a burst of 2 is allowed, and the third request must wait half a second at 2 requests per second
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := store.Allow(context.Background(), "a", 2, 2); !ok {
+			t.Fatalf("request %d should have been allowed", i+1)
+		}
+	}
+	ok, retryAfter, _ := store.Allow(context.Background(), "a", 2, 2)
+	if ok {
+		t.Fatal("third request should have been limited")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("wrong retry after; expected 500ms but got %s", retryAfter)
+	}
+
+	// other keys have their own bucket
+	if ok, _, _ := store.Allow(context.Background(), "b", 2, 2); !ok {
+		t.Error("a different key should have been allowed")
+	}
+
+	// the bucket refills over time
+	now = now.Add(500 * time.Millisecond)
+	if ok, _, _ := store.Allow(context.Background(), "a", 2, 2); !ok {
+		t.Error("request should have been allowed after the bucket refilled")
+	}
+
+	// idle buckets are swept
+	now = now.Add(2 * time.Minute)
+	_, _, _ = store.Allow(context.Background(), "c", 2, 2)
+	if len(store.buckets) != 1 {
+		t.Errorf("expected idle buckets to be swept, but %d remain", len(store.buckets))
+	}
+}
+
+func TestMemoryRateLimitStore_SweepRates(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	// a slow key, such as an API key with a rate of its own, takes 10 minutes to refill
+	for i := 0; i < 2; i++ {
+		_, _, _ = store.Allow(context.Background(), "slow", 1.0/300, 2)
+	}
+	_, _, _ = store.Allow(context.Background(), "fast", 10, 10)
+
+	// a fast key sweeps after 2 minutes, which must not discard the slow bucket and refill it
+	now = now.Add(2 * time.Minute)
+	_, _, _ = store.Allow(context.Background(), "other", 10, 10)
+	if _, ok := store.buckets["fast"]; ok {
+		t.Error("expected the refilled fast bucket to be swept")
+	}
+	if ok, _, _ := store.Allow(context.Background(), "slow", 1.0/300, 2); ok {
+		t.Error("expected the slow bucket to be kept, still empty")
+	}
+}
+
+func TestTools_RateLimit(t *testing.T) {
+	var testTools Tools
+
+	handler := testTools.RateLimit(RateLimitOptions{Rate: 1, Burst: 2})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	statuses := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for i, expected := range statuses {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != expected {
+			t.Errorf("request %d: wrong status; expected %d but got %d", i+1, expected, rr.Code)
+		}
+		if expected == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "1" {
+			t.Errorf("request %d: wrong Retry-After; expected 1 but got %q", i+1, rr.Header().Get("Retry-After"))
+		}
+	}
+
+	// a different client is not affected
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("different client: wrong status; expected %d but got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestTools_RateLimit_NoRate(t *testing.T) {
+	var testTools Tools
+
+	store := NewMemoryRateLimitStore()
+	handler := testTools.RateLimit(RateLimitOptions{Store: store})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := range 5 {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("request %d: wrong status; expected %d but got %d", i+1, http.StatusOK, rr.Code)
+		}
+	}
+	if len(store.buckets) != 0 {
+		t.Errorf("expected no buckets without a rate, but got %d", len(store.buckets))
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Allow(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	return false, 0, errors.New("store unavailable")
+}
+
+func TestTools_RateLimit_StoreError(t *testing.T) {
+	var testTools Tools
+
+	handler := testTools.RateLimit(RateLimitOptions{
+		Rate:    1,
+		Store:   failingRateLimitStore{},
+		KeyFunc: func(r *http.Request) string { return r.Header.Get("X-API-Key") },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected requests to be allowed when the store fails, but got %d", rr.Code)
+	}
+}
@@ -0,0 +1,240 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// defaultRedactedFields are the names of the fields a Redactor masks if Fields isn't set.
+var defaultRedactedFields = []string{
+	"password", "passwd", "secret", "client_secret", "token", "access_token", "refresh_token", "id_token",
+	"api_key", "apikey", "authorization", "cookie", "ssn", "credit_card", "card_number", "cvv",
+}
+
+// defaultRedactionMask replaces redacted values if Mask isn't set.
+const defaultRedactionMask = "[REDACTED]"
+
+// maxRedactDepth is how deeply Redact descends into nested values; anything deeper is masked.
+const maxRedactDepth = 32
+
+// Redactor masks sensitive values before they are logged or shown: struct fields tagged `redact:"true"`,
+// and fields, map keys, JSON keys and log attributes with a sensitive name, wherever they are nested. The
+// zero value masks the built-in names (password, token, ssn and the like) with "[REDACTED]".
+type Redactor struct {
+	Fields []string // names masked wherever they appear, matched case-insensitively, ignoring "-" and "_"; replaces the built-in names
+	Mask   string   // replaces redacted values; defaults to "[REDACTED]"
+}
+
+// IsSensitive reports whether a field named name is masked.
+func (rd Redactor) IsSensitive(name string) bool {
+	fields := rd.Fields
+	if fields == nil {
+		fields = defaultRedactedFields
+	}
+	name = normalizeFieldName(name)
+	for _, f := range fields {
+		if normalizeFieldName(f) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeFieldName lowercases name and drops "-" and "_", so "API-Key", "api_key" and "apiKey" match.
+func normalizeFieldName(name string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(name))
+}
+
+// mask returns the string redacted values are replaced with.
+func (rd Redactor) mask() string {
+	if rd.Mask == "" {
+		return defaultRedactionMask
+	}
+	return rd.Mask
+}
+
+// Redact returns a copy of v with its sensitive values masked, for logging or writing as JSON. Structs
+// become maps keyed by their JSON names, and slices of anything but bytes become []any; other values,
+// including anything implementing json.Marshaler or encoding.TextMarshaler, are returned as they are.
+func (rd Redactor) Redact(v any) any {
+	if v == nil {
+		return nil
+	}
+	return rd.redactValue(reflect.ValueOf(v), 0)
+}
+
+// redactValue returns v with its sensitive values masked.
+func (rd Redactor) redactValue(v reflect.Value, depth int) any {
+	if depth > maxRedactDepth {
+		return rd.mask()
+	}
+
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+			return v.Interface()
+		}
+		v = v.Elem()
+	}
+	if !v.CanInterface() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	if v.CanAddr() && (v.Addr().Type().Implements(jsonMarshalerType) || v.Addr().Type().Implements(textMarshalerType)) {
+		return v.Addr().Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		out := make(map[string]any)
+		rd.redactStruct(v, out, depth)
+		return out
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			if rd.IsSensitive(key) {
+				out[key] = rd.mask()
+			} else {
+				out[key] = rd.redactValue(iter.Value(), depth+1)
+			}
+		}
+		return out
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = rd.redactValue(v.Index(i), depth+1)
+		}
+		return out
+	}
+	return v.Interface()
+}
+
+// redactStruct adds the exported fields of struct v to out, keyed by their JSON names, with embedded
+// structs' fields added as encoding/json would.
+func (rd Redactor) redactStruct(v reflect.Value, out map[string]any, depth int) {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		field := v.Field(i)
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			rd.redactStruct(field, out, depth)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(opts, "omitempty") && field.IsZero() {
+			continue
+		}
+
+		if sf.Tag.Get("redact") == "true" || rd.IsSensitive(name) {
+			out[name] = rd.mask()
+		} else {
+			out[name] = rd.redactValue(field, depth+1)
+		}
+	}
+}
+
+// RedactJSON returns the JSON document b with the values of sensitive keys masked. If b isn't valid JSON,
+// it can't be redacted, and the mask is returned in its place.
+func (rd Redactor) RedactJSON(b []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return []byte(rd.mask())
+	}
+	out, err := json.Marshal(rd.Redact(doc))
+	if err != nil {
+		return []byte(rd.mask())
+	}
+	return out
+}
+
+// redactLogHandler masks sensitive attributes, and sensitive values inside structs, maps and slices
+// logged as attributes, before passing records on.
+type redactLogHandler struct {
+	slog.Handler
+	redactor Redactor
+}
+
+// Handle implements slog.Handler.
+func (h *redactLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+// redactAttr returns a with its value masked if its key is sensitive, or redacted if it holds a
+// struct, map or slice.
+func (h *redactLogHandler) redactAttr(a slog.Attr) slog.Attr {
+	if h.redactor.IsSensitive(a.Key) {
+		return slog.String(a.Key, h.redactor.mask())
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			redacted[i] = h.redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch reflect.Indirect(reflect.ValueOf(a.Value.Any())).Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+			if _, ok := a.Value.Any().(error); ok {
+				return a
+			}
+			return slog.Any(a.Key, h.redactor.Redact(a.Value.Any()))
+		}
+	}
+	return a
+}
+
+// WithAttrs implements slog.Handler.
+func (h *redactLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactAttr(a)
+	}
+	return &redactLogHandler{Handler: h.Handler.WithAttrs(redacted), redactor: h.redactor}
+}
+
+// WithGroup implements slog.Handler.
+func (h *redactLogHandler) WithGroup(name string) slog.Handler {
+	return &redactLogHandler{Handler: h.Handler.WithGroup(name), redactor: h.redactor}
+}
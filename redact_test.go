@@ -0,0 +1,193 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type redactAddress struct {
+	Street string `json:"street"`
+	SSN    string `json:"ssn"`
+}
+
+type redactEmbedded struct {
+	APIKey string
+}
+
+type redactUser struct {
+	redactEmbedded
+	Name     string            `json:"name"`
+	Password string            `json:"password"`
+	PIN      string            `json:"pin" redact:"true"`
+	Address  *redactAddress    `json:"address,omitempty"`
+	Meta     map[string]string `json:"meta"`
+	Created  time.Time         `json:"created"`
+	Skipped  string            `json:"-"`
+	internal string
+}
+
+func TestRedactor_Redact(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := redactUser{
+		redactEmbedded: redactEmbedded{APIKey: "k-123"},
+		Name:           "Ann",
+		Password:       "hunter2",
+		PIN:            "1234",
+		Address:        &redactAddress{Street: "Main St", SSN: "123-45-6789"},
+		Meta:           map[string]string{"Refresh-Token": "r-1", "plan": "pro"},
+		Created:        created,
+		Skipped:        "x",
+		internal:       "y",
+	}
+
+	tests := []struct {
+		name     string
+		redactor Redactor
+		data     any
+		expected string
+	}{
+		{name: "struct", data: user,
+			expected: `{"APIKey":"[REDACTED]","address":{"ssn":"[REDACTED]","street":"Main St"},"created":"2025-01-02T03:04:05Z","meta":{"Refresh-Token":"[REDACTED]","plan":"pro"},"name":"Ann","password":"[REDACTED]","pin":"[REDACTED]"}`},
+		{name: "pointer and slice", data: []*redactAddress{{Street: "a", SSN: "b"}, nil},
+			expected: `[{"ssn":"[REDACTED]","street":"a"},null]`},
+		{name: "nested maps", data: map[string]any{"auth": map[string]any{"token": "t", "user": "ann"}, "items": []any{map[string]any{"cvv": 123}}},
+			expected: `{"auth":{"token":"[REDACTED]","user":"ann"},"items":[{"cvv":"[REDACTED]"}]}`},
+		{name: "custom fields and mask", redactor: Redactor{Fields: []string{"email"}, Mask: "***"}, data: map[string]string{"email": "a@b.c", "password": "p"},
+			expected: `{"email":"***","password":"p"}`},
+		{name: "tag with custom fields", redactor: Redactor{Fields: []string{"email"}}, data: redactUser{PIN: "1"},
+			expected: `{"APIKey":"","created":"0001-01-01T00:00:00Z","meta":null,"name":"","password":"","pin":"[REDACTED]"}`},
+		{name: "pointer receiver marshaler", data: &struct{ F testPtrMarshaler }{F: testPtrMarshaler{A: 1}},
+			expected: `{"F":"custom"}`},
+		{name: "scalar", data: "password", expected: `"password"`},
+		{name: "nil", data: nil, expected: `null`},
+	}
+
+	for _, e := range tests {
+		out, err := json.Marshal(e.redactor.Redact(e.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		if string(out) != e.expected {
+			t.Errorf("%s: wrong result;\nexpected %s\n but got %s", e.name, e.expected, out)
+		}
+	}
+}
+
+func TestRedactor_RedactJSON(t *testing.T) {
+	var rd Redactor
+	tests := []struct {
+		name     string
+		json     string
+		expected string
+	}{
+		{name: "object", json: `{"user":"ann","password":"hunter2","amount":10.50}`, expected: `{"amount":10.50,"password":"[REDACTED]","user":"ann"}`},
+		{name: "array", json: `[{"access_token":"a"},{"id":1}]`, expected: `[{"access_token":"[REDACTED]"},{"id":1}]`},
+		{name: "invalid", json: `{"password":"hunt`, expected: `[REDACTED]`},
+	}
+
+	for _, e := range tests {
+		if got := string(rd.RedactJSON([]byte(e.json))); got != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestRedactor_IsSensitive(t *testing.T) {
+	var rd Redactor
+	for name, expected := range map[string]bool{"password": true, "Password": true, "API-Key": true, "apiKey": true, "api_key": true, "name": false, "tokens": false} {
+		if rd.IsSensitive(name) != expected {
+			t.Errorf("%s: expected %v", name, expected)
+		}
+	}
+}
+
+func TestTools_LogRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	testTools := Tools{LogHandler: slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})}
+
+	testTools.LogInfo(context.Background(), "login", "user", "ann", "password", "hunter2",
+		slog.Group("auth", "token", "t-1", "method", "basic"),
+		"payload", redactAddress{Street: "Main St", SSN: "123"})
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "t-1", `"123"`} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %s to be redacted, got %s", secret, out)
+		}
+	}
+	for _, kept := range []string{`"user":"ann"`, `"method":"basic"`, `"street":"Main St"`} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("expected %s in the log, got %s", kept, out)
+		}
+	}
+}
+
+func TestTools_Logger_LogBody(t *testing.T) {
+	var buf bytes.Buffer
+	testTools := Tools{LogHandler: slog.NewTextHandler(&buf, nil)}
+
+	var received string
+	handler := testTools.Logger(LoggerOptions{LogBody: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+	}))
+
+	body := `{"email":"ann@example.com","password":"hunter2"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != body {
+		t.Errorf("expected the handler to receive the whole body, got %s", received)
+	}
+	if strings.Contains(buf.String(), "hunter2") || !strings.Contains(buf.String(), `ann@example.com`) {
+		t.Errorf("expected the body to be logged with the password redacted, got %s", buf.String())
+	}
+
+	// a body too large to log is masked whole
+	buf.Reset()
+	large := `{"data":"` + strings.Repeat("a", maxLoggedBody) + `"}`
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(large))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != large {
+		t.Errorf("expected the handler to receive the whole large body, got %d bytes", len(received))
+	}
+	if !strings.Contains(buf.String(), "body=[REDACTED]") {
+		t.Errorf("expected the large body to be masked, got %s", buf.String())
+	}
+}
+
+func TestTools_PushJSONToRemote_DebugRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	testTools := Tools{DebugMode: true, LogHandler: slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})}
+
+	var sent string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		b, _ := io.ReadAll(req.Body)
+		sent = string(b)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("OK")), Header: make(http.Header)}
+	})
+
+	payload := map[string]string{"client_id": "app", "client_secret": "s3cret"}
+	if _, _, err := testTools.PushJSONToRemote("http://example.com/token", payload, client); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sent, "s3cret") {
+		t.Errorf("expected the remote to receive the real payload, got %s", sent)
+	}
+	if strings.Contains(buf.String(), "s3cret") || !strings.Contains(buf.String(), "client_id:app") {
+		t.Errorf("expected the payload to be logged with the secret redacted, got %s", buf.String())
+	}
+}
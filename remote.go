@@ -0,0 +1,233 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultRetryBackoff is the delay before the first retry of a remote call, if RemoteOptions doesn't set one.
+const defaultRetryBackoff = 200 * time.Millisecond
+
+// RemoteOptions configures a call made by PushToRemote.
+type RemoteOptions struct {
+	Client          *http.Client  // client used to make the call; defaults to HTTPClient, or a shared client with safe timeouts
+	Timeout         time.Duration // limit on the whole call, including retries; the client's own timeout also applies
+	Headers         http.Header   // extra headers sent with the request
+	BearerToken     string        // if set, sent as an "Authorization: Bearer" header
+	Username        string        // if set, sent with Password using HTTP basic authentication
+	Password        string        // password for basic authentication
+	Retries         int           // number of times to retry after a network error, a 429 or a 5xx response
+	RetryBackoff    time.Duration // delay before the first retry, doubled for each one after; defaults to 200ms
+	MaxResponseSize int64         // maximum size of a response body read into memory; defaults to 10mb
+}
+
+// RemoteError is returned by PushJSONToRemoteInto when the remote server responds with a status outside
+// the 2xx range.
+type RemoteError struct {
+	Status int    // the status code of the response
+	Body   []byte // the response body, up to MaxResponseSize
+}
+
+// Error implements the error interface.
+func (e *RemoteError) Error() string {
+	body := string(e.Body)
+	if len(body) > 200 {
+		body = body[:200] + "..."
+	}
+	if body == "" {
+		return fmt.Sprintf("remote server returned status %d", e.Status)
+	}
+	return fmt.Sprintf("remote server returned status %d: %s", e.Status, body)
+}
+
+// clientOptions returns RemoteOptions using the optional client passed to the older remote helpers.
+func clientOptions(client []*http.Client) RemoteOptions {
+	var opts RemoteOptions
+	if len(client) > 0 {
+		opts.Client = client[0]
+	}
+	return opts
+}
+
+// PushToRemote posts body, of the given content type, to uri, and returns the response, the response
+// status code, and error, if any. It is the plumbing behind PushJSONToRemote and PushXMLToRemote, for
+// payloads such as form-encoded or multipart bodies (see BuildMultipartBody). When retries are enabled,
+// bodies which can't be replayed are read into memory first. The response body is read, up to
+// MaxResponseSize, before the connection is released, and can be read again from the returned response.
+func (t *Tools) PushToRemote(uri string, body io.Reader, contentType string, opts ...RemoteOptions) (*http.Response, int, error) {
+	var o RemoteOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	response, err := t.sendToRemote(context.Background(), uri, body, contentType, o)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	b, _, err := readRemoteBody(response.Body, o.MaxResponseSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(b))
+
+	return response, response.StatusCode, nil
+}
+
+// PushJSONToRemoteInto posts data as JSON to uri, like PushJSONToRemote, and decodes a JSON response into
+// target, unless target is nil or the response is empty. It returns the response status code. Responses
+// with a status outside the 2xx range are returned as a *RemoteError holding the body, and responses
+// larger than MaxResponseSize are an error.
+func (t *Tools) PushJSONToRemoteInto(uri string, data, target interface{}, opts ...RemoteOptions) (int, error) {
+	var o RemoteOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	jsonData, err := t.remoteJSONBody(data)
+	if err != nil {
+		return 0, err
+	}
+
+	if o.Headers.Get("Accept") == "" {
+		o.Headers = o.Headers.Clone()
+		if o.Headers == nil {
+			o.Headers = http.Header{}
+		}
+		o.Headers.Set("Accept", "application/json")
+	}
+
+	response, err := t.sendToRemote(context.Background(), uri, bytes.NewReader(jsonData), "application/json", o)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	b, truncated, err := readRemoteBody(response.Body, o.MaxResponseSize)
+	if err != nil {
+		return response.StatusCode, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, &RemoteError{Status: response.StatusCode, Body: b}
+	}
+	if truncated {
+		return response.StatusCode, fmt.Errorf("remote response must not be larger than %d bytes", len(b))
+	}
+
+	if target != nil && len(bytes.TrimSpace(b)) > 0 {
+		if err := json.Unmarshal(b, target); err != nil {
+			return response.StatusCode, fmt.Errorf("error decoding remote response: %w", err)
+		}
+	}
+	return response.StatusCode, nil
+}
+
+// readRemoteBody reads up to max bytes of a response body, or 10mb if max isn't positive, reporting
+// whether there was more.
+func readRemoteBody(r io.Reader, max int64) ([]byte, bool, error) {
+	if max <= 0 {
+		max = defaultMaxUpload
+	}
+	b, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(b)) > max {
+		return b[:max], true, nil
+	}
+	return b, false, nil
+}
+
+// sendToRemote posts body to uri, retrying as configured by opts, until ctx is done. The caller must close the
+// response body.
+func (t *Tools) sendToRemote(ctx context.Context, uri string, body io.Reader, contentType string, opts RemoteOptions) (*http.Response, error) {
+	httpClient := opts.Client
+	if httpClient == nil {
+		httpClient = t.httpClient()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, val := range opts.Headers {
+		request.Header[key] = val
+	}
+	request.Header.Set("Content-Type", contentType)
+	if opts.BearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+opts.BearerToken)
+	}
+	if opts.Username != "" {
+		request.SetBasicAuth(opts.Username, opts.Password)
+	}
+
+	request, cancel := withTimeout(request, opts.Timeout)
+	response, err := t.doWithRetries(httpClient, request, body, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	response.Body = cancelOnClose{response.Body, cancel}
+	return response, nil
+}
+
+// doWithRetries sends a request, retrying as configured by opts. Body is the request's original body.
+func (t *Tools) doWithRetries(httpClient *http.Client, request *http.Request, body io.Reader, opts RemoteOptions) (*http.Response, error) {
+	uri := request.URL.String()
+
+	if opts.Retries <= 0 {
+		return httpClient.Do(request)
+	}
+
+	// Retries need to send the body again.
+	if body != nil && request.GetBody == nil {
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		request.ContentLength = int64(len(b))
+		request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		}
+		request.Body, _ = request.GetBody()
+	}
+
+	backoff := opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, err := httpClient.Do(request)
+		retry := err != nil || response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+		if !retry || attempt == opts.Retries {
+			return response, err
+		}
+
+		if response != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+			response.Body.Close()
+			t.LogWarn(request.Context(), "remote call failed, retrying", "uri", uri, "status", response.StatusCode, "attempt", attempt+1)
+		} else {
+			t.LogWarn(request.Context(), "remote call failed, retrying", "uri", uri, "error", err, "attempt", attempt+1)
+		}
+
+		select {
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		case <-time.After(backoff << attempt):
+		}
+
+		if request.GetBody != nil {
+			if request.Body, err = request.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
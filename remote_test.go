@@ -0,0 +1,203 @@
+package toolkit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestTools_PushToRemote(t *testing.T) {
+	var request *http.Request
+	var body []byte
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		request = req
+		body, _ = io.ReadAll(req.Body)
+		return &http.Response{
+			StatusCode: http.StatusCreated,
+			Body:       io.NopCloser(bytes.NewBufferString("ok")),
+			Header:     make(http.Header),
+		}
+	})
+
+	var testTools Tools
+	form := url.Values{"name": {"Ann"}, "age": {"30"}}
+	_, status, err := testTools.PushToRemote("http://example.com/form", strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", RemoteOptions{
+		Client:      client,
+		Headers:     http.Header{"X-Trace": {"abc"}},
+		BearerToken: "secret",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if status != http.StatusCreated {
+		t.Errorf("expected status 201 but got %d", status)
+	}
+	if string(body) != "age=30&name=Ann" {
+		t.Errorf("wrong body %q", body)
+	}
+	if got := request.Header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+		t.Errorf("wrong content type %q", got)
+	}
+	if got := request.Header.Get("X-Trace"); got != "abc" {
+		t.Errorf("custom header not sent, got %q", got)
+	}
+	if got := request.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("wrong authorization header %q", got)
+	}
+}
+
+func TestTools_PushToRemoteBasicAuth(t *testing.T) {
+	var user, pass string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		user, pass, _ = req.BasicAuth()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}
+	})
+
+	var testTools Tools
+	_, _, err := testTools.PushToRemote("http://example.com", nil, "text/plain", RemoteOptions{Client: client, Username: "ann", Password: "pw"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if user != "ann" || pass != "pw" {
+		t.Errorf("expected basic auth ann/pw but got %s/%s", user, pass)
+	}
+}
+
+var pushToRemoteRetryTests = []struct {
+	name             string
+	statuses         []int
+	retries          int
+	expectedStatus   int
+	expectedAttempts int
+}{
+	{name: "no retries", statuses: []int{503, 200}, expectedStatus: 503, expectedAttempts: 1},
+	{name: "recovers", statuses: []int{503, 429, 200}, retries: 3, expectedStatus: 200, expectedAttempts: 3},
+	{name: "gives up", statuses: []int{500, 502, 503, 504}, retries: 2, expectedStatus: 503, expectedAttempts: 3},
+	{name: "client errors not retried", statuses: []int{400, 200}, retries: 2, expectedStatus: 400, expectedAttempts: 1},
+}
+
+func TestTools_PushToRemoteRetries(t *testing.T) {
+	for _, e := range pushToRemoteRetryTests {
+		var bodies []string
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			b, _ := io.ReadAll(req.Body)
+			bodies = append(bodies, string(b))
+			return &http.Response{StatusCode: e.statuses[len(bodies)-1], Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}
+		})
+
+		var testTools Tools
+		// a reader which can't be replayed, so it has to be buffered for the retries
+		body := iotest.OneByteReader(strings.NewReader("payload"))
+		_, status, err := testTools.PushToRemote("http://example.com", body, "text/plain", RemoteOptions{
+			Client:       client,
+			Retries:      e.retries,
+			RetryBackoff: time.Millisecond,
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+
+		if status != e.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.expectedStatus, status)
+		}
+		if len(bodies) != e.expectedAttempts {
+			t.Errorf("%s: expected %d attempts but got %d", e.name, e.expectedAttempts, len(bodies))
+		}
+		for i, b := range bodies {
+			if b != "payload" {
+				t.Errorf("%s: attempt %d sent body %q", e.name, i+1, b)
+			}
+		}
+	}
+}
+
+func TestTools_PushToRemoteResponseBody(t *testing.T) {
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"id":7}`)), Header: make(http.Header)}
+	})
+
+	var testTools Tools
+	response, _, err := testTools.PushJSONToRemote("http://example.com/items", map[string]int{"a": 1}, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("response body can't be read: %s", err)
+	}
+	if string(body) != `{"id":7}` {
+		t.Errorf("wrong response body %q", body)
+	}
+}
+
+var pushJSONToRemoteIntoTests = []struct {
+	name          string
+	status        int
+	body          string
+	maxSize       int64
+	expectedID    int
+	errorExpected string
+	remoteError   bool
+}{
+	{name: "decoded", status: http.StatusCreated, body: `{"id":7}`, expectedID: 7},
+	{name: "empty body", status: http.StatusNoContent, body: ""},
+	{name: "not found", status: http.StatusNotFound, body: `{"error":"no such item"}`, errorExpected: `remote server returned status 404: {"error":"no such item"}`, remoteError: true},
+	{name: "server error without body", status: http.StatusBadGateway, errorExpected: "remote server returned status 502", remoteError: true},
+	{name: "too large", status: http.StatusOK, body: `{"id":7}`, maxSize: 4, errorExpected: "remote response must not be larger than 4 bytes"},
+	{name: "bad json", status: http.StatusOK, body: `{"id":`, errorExpected: "error decoding remote response: unexpected end of JSON input"},
+}
+
+func TestTools_PushJSONToRemoteInto(t *testing.T) {
+	for _, e := range pushJSONToRemoteIntoTests {
+		var accept string
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			accept = req.Header.Get("Accept")
+			return &http.Response{StatusCode: e.status, Body: io.NopCloser(strings.NewReader(e.body)), Header: make(http.Header)}
+		})
+
+		var testTools Tools
+		var target struct {
+			ID int `json:"id"`
+		}
+		status, err := testTools.PushJSONToRemoteInto("http://example.com/items", map[string]string{"name": "widget"}, &target, RemoteOptions{
+			Client:          client,
+			MaxResponseSize: e.maxSize,
+		})
+
+		if status != e.status {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.status, status)
+		}
+		if accept != "application/json" {
+			t.Errorf("%s: wrong accept header %q", e.name, accept)
+		}
+
+		if e.errorExpected == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", e.name, err)
+			}
+			if target.ID != e.expectedID {
+				t.Errorf("%s: expected id %d but got %d", e.name, e.expectedID, target.ID)
+			}
+			continue
+		}
+
+		if err == nil || err.Error() != e.errorExpected {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.errorExpected, err)
+		}
+		var remoteErr *RemoteError
+		if errors.As(err, &remoteErr) != e.remoteError {
+			t.Errorf("%s: expected RemoteError %t but got %T", e.name, e.remoteError, err)
+		}
+		if remoteErr != nil && (remoteErr.Status != e.status || string(remoteErr.Body) != e.body) {
+			t.Errorf("%s: wrong RemoteError %+v", e.name, remoteErr)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package toolkit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RemoteDownloadOptions configures DownloadRemoteFile.
+type RemoteDownloadOptions struct {
+	Client   *http.Client  // client used to make the request; defaults to HTTPClient, or a shared client with safe timeouts
+	Timeout  time.Duration // limit on the whole download; note that the shared client limits requests to 30s
+	Headers  http.Header   // extra headers sent with the request, e.g. Authorization
+	FileName string        // name to save the file as; defaults to a random name with the remote file's extension
+	MaxSize  int64         // maximum size of the file in bytes; defaults to MaxFileSize
+	SHA256   string        // expected hex-encoded SHA-256 checksum of the file; it is removed if it doesn't match
+	Resume   bool          // continue a previous, interrupted download of FileName with a Range request
+}
+
+// DownloadRemoteFile streams the file at uri into destDir, and returns a record of it like those returned
+// by UploadFiles. The file is limited to MaxSize bytes, its type must be allowed by AllowedFileTypes or
+// AllowedTypes (if set), and it must match the SHA256 checksum (if set); a file failing any check is
+// removed. The download is written to a ".part" file which is renamed once it is complete, so with Resume
+// set an interrupted download of the same FileName carries on where it stopped, if the server supports
+// Range requests.
+func (t *Tools) DownloadRemoteFile(uri, destDir string, opts ...RemoteDownloadOptions) (*UploadedFile, error) {
+	var o RemoteDownloadOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Resume && o.FileName == "" {
+		return nil, errors.New("resuming a download requires a FileName")
+	}
+
+	maxSize := o.MaxSize
+	if maxSize <= 0 {
+		maxSize = int64(t.MaxFileSize)
+	}
+	if maxSize <= 0 {
+		maxSize = 1024 * 1024 * 1024 // 1Gb, as for UploadFiles
+	}
+
+	if err := t.CreateDirIfNotExist(destDir); err != nil {
+		return nil, err
+	}
+
+	original := remoteFileName(uri)
+	newName := o.FileName
+	if newName == "" {
+		newName = t.RandomString(25) + filepath.Ext(original)
+	}
+	outPath, err := t.EnsureWithinBase(destDir, newName)
+	if err != nil {
+		return nil, err
+	}
+	partPath := outPath + ".part"
+
+	var offset int64
+	if o.Resume {
+		if info, err := os.Stat(partPath); err == nil {
+			offset = info.Size()
+		}
+	} else {
+		_ = os.Remove(partPath)
+	}
+
+	request, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, val := range o.Headers {
+		request.Header[key] = val
+	}
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	request, cancel := withTimeout(request, o.Timeout)
+	defer cancel()
+
+	httpClient := o.Client
+	if httpClient == nil {
+		httpClient = t.httpClient()
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if name := contentDispositionName(response.Header.Get("Content-Disposition")); name != "" {
+		original = name
+	}
+
+	var body io.Reader = response.Body
+	switch {
+	case response.StatusCode == http.StatusPartialContent && offset > 0:
+		// carry on from the end of the partial file
+	case response.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the partial file is already complete
+		body = http.NoBody
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		// the server sent the whole file, so start again
+		offset = 0
+	default:
+		return nil, fmt.Errorf("remote server returned %s", response.Status)
+	}
+
+	if response.StatusCode == http.StatusOK && response.ContentLength > maxSize {
+		return nil, fmt.Errorf("remote file must not be larger than %s", HumanBytes(maxSize))
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	part, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := t.writeRemoteFile(part, partPath, offset, body, maxSize)
+	if closeErr := part.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// keep what we have if the download can be resumed, unless the file itself was rejected
+		var rejected remoteFileRejected
+		if !o.Resume || errors.As(err, &rejected) {
+			_ = os.Remove(partPath)
+		}
+		return nil, err
+	}
+
+	if err := t.checkRemoteFile(partPath, o.SHA256); err != nil {
+		_ = os.Remove(partPath)
+		return nil, err
+	}
+
+	if err := os.Rename(partPath, outPath); err != nil {
+		return nil, err
+	}
+
+	return &UploadedFile{NewFileName: newName, OriginalFileName: original, FileSize: size}, nil
+}
+
+// remoteFileRejected is an error for a download which fails a check, rather than one which was interrupted.
+type remoteFileRejected struct{ error }
+
+// writeRemoteFile appends body to part, which already holds offset bytes, checking the type of the file
+// as soon as enough of it is known, and its size as it goes, against any limit set for the type by
+// AllowedTypes or FileSignatures. It returns the size of the whole file.
+func (t *Tools) writeRemoteFile(part *os.File, partPath string, offset int64, body io.Reader, maxSize int64) (int64, error) {
+	br := bufio.NewReaderSize(body, 512)
+
+	if len(t.AllowedFileTypes) > 0 || len(t.AllowedTypes) > 0 || len(t.FileSignatures) > 0 {
+		head := make([]byte, 0, fileSniffLen)
+		if offset > 0 {
+			existing, err := os.Open(partPath)
+			if err != nil {
+				return 0, err
+			}
+			n, _ := io.ReadFull(existing, head[:min(int64(cap(head)), offset)])
+			existing.Close()
+			head = head[:n]
+		}
+		peeked, _ := br.Peek(cap(head) - len(head))
+		head = append(head, peeked...)
+
+		if len(head) > 0 {
+			fileType := t.DetectFileType(head)
+			typeMax, err := t.checkFileType(fileType)
+			if err != nil {
+				return 0, remoteFileRejected{err}
+			}
+			if typeMax > 0 && typeMax < maxSize {
+				maxSize = typeMax
+			}
+		}
+	}
+
+	n, err := io.Copy(part, io.LimitReader(br, maxSize-offset+1))
+	if err != nil {
+		return 0, err
+	}
+	if offset+n > maxSize {
+		return 0, remoteFileRejected{fmt.Errorf("remote file must not be larger than %s", HumanBytes(maxSize))}
+	}
+	return offset + n, nil
+}
+
+// checkRemoteFile verifies the SHA-256 checksum of a downloaded file, if one is expected.
+func (t *Tools) checkRemoteFile(p, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, checksum) {
+		return fmt.Errorf("checksum mismatch: expected %s but got %s", strings.ToLower(checksum), sum)
+	}
+	return nil
+}
+
+// remoteFileName returns the last element of the path of uri, which is used as the original file name
+// unless the server sends one.
+func remoteFileName(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return name
+}
+
+// contentDispositionName returns the file name in a Content-Disposition header, if there is one.
+func contentDispositionName(header string) string {
+	if header == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil || params["filename"] == "" {
+		return ""
+	}
+	return filepath.Base(params["filename"])
+}
@@ -0,0 +1,131 @@
+package toolkit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTools_DownloadRemoteFile(t *testing.T) {
+	img, err := os.ReadFile("./testdata/img.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(img)
+	checksum := hex.EncodeToString(sum[:])
+
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.URL.Path == "/named" {
+			w.Header().Set("Content-Disposition", `attachment; filename="logo.png"`)
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "img.png", time.Time{}, bytes.NewReader(img))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name             string
+		path             string
+		opts             RemoteDownloadOptions
+		allowed          []string
+		expectedOriginal string
+		errorExpected    string
+	}{
+		{name: "plain", path: "/files/img.png", expectedOriginal: "img.png"},
+		{name: "named by server", path: "/named", opts: RemoteDownloadOptions{FileName: "saved.png"}, expectedOriginal: "logo.png"},
+		{name: "checksum", path: "/img.png", opts: RemoteDownloadOptions{SHA256: strings.ToUpper(checksum)}, expectedOriginal: "img.png"},
+		{name: "checksum mismatch", path: "/img.png", opts: RemoteDownloadOptions{FileName: "bad.png", SHA256: strings.Repeat("0", 64)}, errorExpected: "checksum mismatch"},
+		{name: "allowed type", path: "/img.png", allowed: []string{"image/png"}, expectedOriginal: "img.png"},
+		{name: "type not allowed", path: "/img.png", opts: RemoteDownloadOptions{FileName: "bad.png"}, allowed: []string{"image/jpeg"}, errorExpected: "file type not allowed: image/png"},
+		{name: "too large", path: "/img.png", opts: RemoteDownloadOptions{FileName: "bad.png", MaxSize: 100}, errorExpected: "remote file must not be larger than 100 bytes"},
+		{name: "not found", path: "/missing", errorExpected: "remote server returned 404 Not Found"},
+		{name: "resume without name", path: "/img.png", opts: RemoteDownloadOptions{Resume: true}, errorExpected: "resuming a download requires a FileName"},
+		{name: "outside destination", path: "/img.png", opts: RemoteDownloadOptions{FileName: "../escape.png"}, errorExpected: "outside"},
+	}
+
+	for _, e := range tests {
+		dir := t.TempDir()
+		testTools := Tools{AllowedFileTypes: e.allowed}
+
+		file, err := testTools.DownloadRemoteFile(srv.URL+e.path, dir, e.opts)
+		if e.errorExpected != "" {
+			if err == nil || !strings.Contains(err.Error(), e.errorExpected) {
+				t.Errorf("%s: expected error containing %q but got %v", e.name, e.errorExpected, err)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("%s: expected nothing to be left behind, found %d files", e.name, len(entries))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+
+		if file.OriginalFileName != e.expectedOriginal {
+			t.Errorf("%s: expected original name %q but got %q", e.name, e.expectedOriginal, file.OriginalFileName)
+		}
+		if e.opts.FileName != "" && file.NewFileName != e.opts.FileName {
+			t.Errorf("%s: expected file to be saved as %q but got %q", e.name, e.opts.FileName, file.NewFileName)
+		}
+		if file.FileSize != int64(len(img)) {
+			t.Errorf("%s: expected size %d but got %d", e.name, len(img), file.FileSize)
+		}
+		saved, err := os.ReadFile(filepath.Join(dir, file.NewFileName))
+		if err != nil || !bytes.Equal(saved, img) {
+			t.Errorf("%s: saved file does not match the remote file", e.name)
+		}
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "resumed.png.part"), img[:1000], 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ranges = nil
+		testTools := Tools{AllowedFileTypes: []string{"image/png"}}
+		file, err := testTools.DownloadRemoteFile(srv.URL+"/img.png", dir, RemoteDownloadOptions{FileName: "resumed.png", Resume: true, SHA256: checksum})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+			t.Errorf("expected a range request from byte 1000, got %v", ranges)
+		}
+		if file.FileSize != int64(len(img)) {
+			t.Errorf("expected size %d but got %d", len(img), file.FileSize)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "resumed.png.part")); !os.IsNotExist(err) {
+			t.Error("expected the partial file to be renamed")
+		}
+	})
+
+	t.Run("resume complete", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "done.png.part"), img, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var testTools Tools
+		file, err := testTools.DownloadRemoteFile(srv.URL+"/img.png", dir, RemoteDownloadOptions{FileName: "done.png", Resume: true, SHA256: checksum})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if file.FileSize != int64(len(img)) {
+			t.Errorf("expected size %d but got %d", len(img), file.FileSize)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestIDHeader the header used to receive and return request IDs
+const requestIDHeader = "X-Request-ID"
+
+// contextKey is the type of the keys the toolkit stores in request contexts.
+type contextKey string
+
+// requestIDKey the context key for the request ID
+const requestIDKey contextKey = "requestID"
+
+// RequestID is middleware which gives every request an ID, for correlating log lines. An ID sent by the
+// client (or a proxy) in the X-Request-ID header is kept, as long as it is reasonably short; otherwise a
+// random one is generated. The ID is stored in the request context, and returned in the X-Request-ID
+// response header.
+func (t *Tools) RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = t.RandomString(20)
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// RequestIDFromContext returns the request ID set by the RequestID middleware, or an empty string if
+// there isn't one.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// LoggerOptions configures the Logger middleware.
+type LoggerOptions struct {
+	SampleRate   float64  // fraction of successful requests to log, between 0 and 1; 0 logs every request
+	ExcludePaths []string // paths which are never logged (e.g. "/healthz"); a trailing "*" matches a prefix
+	LogBody      bool     // log JSON request bodies, with sensitive fields masked by Redactor; bodies over 4 KB are masked whole
+}
+
+// maxLoggedBody is the largest request body the Logger middleware logs.
+const maxLoggedBody = 4096
+
+// Logger returns middleware which logs every request at info level once it has been handled, with its
+// method, path, status, bytes written, duration, and request ID (if the RequestID middleware ran first). Set
+// SampleRate to log only a fraction of requests on busy services; server errors are always logged. With
+// LogBody, JSON request bodies are logged too, after Redactor has masked their sensitive fields.
+func (t *Tools) Logger(opts LoggerOptions) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !t.logEnabled(r.Context(), slog.LevelInfo) || excludedPath(r.URL.Path, opts.ExcludePaths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if opts.LogBody && r.Body != nil && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+				body, r.Body = peekBody(r.Body, maxLoggedBody)
+			}
+
+			start := time.Now()
+			rec := WrapResponseWriter(w)
+			next.ServeHTTP(rec, r)
+			duration := time.Since(start)
+
+			status := rec.Status()
+			if status < http.StatusInternalServerError && opts.SampleRate > 0 && opts.SampleRate < 1 && rand.Float64() >= opts.SampleRate {
+				return
+			}
+
+			args := []any{"method", r.Method, "path", r.URL.Path, "status", status, "bytes", rec.BytesWritten(), "duration", duration}
+			if len(body) > 0 {
+				args = append(args, "body", string(t.Redactor.RedactJSON(body)))
+			}
+			t.LogInfo(r.Context(), "request", args...)
+		})
+	}
+}
+
+// peekBody reads up to n+1 bytes of body, returning them along with a body which reads them again
+// followed by the rest, so the handler sees the whole body. More than n bytes means the body is too long to
+// be logged, which RedactJSON turns into the mask.
+func peekBody(body io.ReadCloser, n int64) ([]byte, io.ReadCloser) {
+	b, _ := io.ReadAll(io.LimitReader(body, n+1))
+	return b, struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), body), body}
+}
+
+// excludedPath reports whether path matches one of the exclusion patterns.
+func excludedPath(path string, patterns []string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package toolkit
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTools_RequestID(t *testing.T) {
+	var testTools Tools
+	var seen string
+
+	handler := testTools.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	// a new ID is generated
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if seen == "" || rr.Header().Get("X-Request-ID") != seen {
+		t.Errorf("request ID not generated; context has %q and header has %q", seen, rr.Header().Get("X-Request-ID"))
+	}
+
+	// an incoming ID is kept
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "abc123")
+	handler.ServeHTTP(rr, req)
+	if seen != "abc123" || rr.Header().Get("X-Request-ID") != "abc123" {
+		t.Errorf("incoming request ID not kept; got %q", seen)
+	}
+}
+
+var loggerTests = []struct {
+	name      string
+	path      string
+	status    int
+	opts      LoggerOptions
+	logged    bool
+	contained []string
+}{
+	{name: "logged", path: "/users", status: http.StatusCreated, opts: LoggerOptions{}, logged: true, contained: []string{"method=POST path=/users status=201 bytes=5", "request_id=abc123"}},
+	{name: "excluded", path: "/healthz", status: http.StatusOK, opts: LoggerOptions{ExcludePaths: []string{"/healthz"}}, logged: false},
+	{name: "excluded prefix", path: "/static/app.js", status: http.StatusOK, opts: LoggerOptions{ExcludePaths: []string{"/static/*"}}, logged: false},
+	{name: "sampled out", path: "/users", status: http.StatusOK, opts: LoggerOptions{SampleRate: 0.0000001}, logged: false},
+	{name: "errors ignore sampling", path: "/users", status: http.StatusInternalServerError, opts: LoggerOptions{SampleRate: 0.0000001}, logged: true, contained: []string{"status=500"}},
+}
+
+func TestTools_Logger(t *testing.T) {
+	for _, e := range loggerTests {
+		var buf bytes.Buffer
+		var testTools Tools
+		testTools.InfoLog = log.New(&buf, "", 0)
+
+		handler := testTools.RequestID(testTools.Logger(e.opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(e.status)
+			_, _ = w.Write([]byte("hello"))
+		})))
+
+		req := httptest.NewRequest(http.MethodPost, e.path, nil)
+		req.Header.Set("X-Request-ID", "abc123")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if e.logged && buf.Len() == 0 {
+			t.Errorf("%s: expected request to be logged, but it wasn't", e.name)
+		}
+		if !e.logged && buf.Len() != 0 {
+			t.Errorf("%s: expected request not to be logged, but got %s", e.name, buf.String())
+		}
+		for _, s := range e.contained {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("%s: expected log to contain %q, but got %s", e.name, s, buf.String())
+			}
+		}
+	}
+}
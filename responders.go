@@ -0,0 +1,63 @@
+package toolkit
+
+import (
+	"net/http"
+)
+
+// NoContent sends a 204 No Content response, with no body and none of the headers describing one.
+func (t *Tools) NoContent(w http.ResponseWriter, headers ...http.Header) {
+	if len(headers) > 0 {
+		for key, val := range headers[0] {
+			w.Header()[key] = val
+		}
+	}
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Created sends data as JSON with a 201 Created status, and a Location header pointing at the new resource
+// if location is not empty.
+func (t *Tools) Created(w http.ResponseWriter, location string, data interface{}, headers ...http.Header) error {
+	return t.WriteJSON(w, http.StatusCreated, data, withLocation(location, headers))
+}
+
+// AcceptedResponse is the body Accepted sends.
+type AcceptedResponse struct {
+	StatusURL string `json:"status_url"` // where the client can check on the progress of the request
+}
+
+// Accepted sends a 202 Accepted response for a request which will be processed later, with a Location
+// header and body pointing at statusURL, where the client can check on its progress.
+func (t *Tools) Accepted(w http.ResponseWriter, statusURL string, headers ...http.Header) error {
+	return t.WriteJSON(w, http.StatusAccepted, AcceptedResponse{StatusURL: statusURL}, withLocation(statusURL, headers))
+}
+
+// RedirectResponse is the body RedirectJSON sends.
+type RedirectResponse struct {
+	Redirect string `json:"redirect"` // the URL the client should go to
+}
+
+// RedirectJSON sends a redirect to url to a JSON client, with a Location header and the URL in the body, so
+// clients which don't follow redirects themselves, such as single-page apps, can navigate to it. The status
+// is 303 See Other, unless another is given.
+func (t *Tools) RedirectJSON(w http.ResponseWriter, url string, status ...int) error {
+	statusCode := http.StatusSeeOther
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+	return t.WriteJSON(w, statusCode, RedirectResponse{Redirect: url}, withLocation(url, nil))
+}
+
+// withLocation returns the first of headers, if any, with a Location header added if location is not empty.
+// The headers passed in are not changed.
+func withLocation(location string, headers []http.Header) http.Header {
+	h := http.Header{}
+	if len(headers) > 0 {
+		h = headers[0].Clone()
+	}
+	if location != "" {
+		h.Set("Location", location)
+	}
+	return h
+}
@@ -0,0 +1,76 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTools_NoContent(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Content-Type", "application/json")
+
+	testTools.NoContent(rr, http.Header{"X-Request-Id": {"abc"}})
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rr.Code)
+	}
+	if rr.Body.Len() != 0 || rr.Header().Get("Content-Type") != "" {
+		t.Errorf("expected no body or content type, got %q and %q", rr.Body.String(), rr.Header().Get("Content-Type"))
+	}
+	if rr.Header().Get("X-Request-Id") != "abc" {
+		t.Error("expected the custom header to be sent")
+	}
+}
+
+func TestTools_Responders(t *testing.T) {
+	var testTools Tools
+	headers := http.Header{"X-Foo": {"bar"}}
+
+	tests := []struct {
+		name     string
+		respond  func(w http.ResponseWriter) error
+		status   int
+		location string
+		body     string
+	}{
+		{name: "created", respond: func(w http.ResponseWriter) error {
+			return testTools.Created(w, "/users/1", map[string]int{"id": 1}, headers)
+		}, status: http.StatusCreated, location: "/users/1", body: `{"id":1}`},
+		{name: "created without location", respond: func(w http.ResponseWriter) error {
+			return testTools.Created(w, "", map[string]int{"id": 1})
+		}, status: http.StatusCreated, body: `{"id":1}`},
+		{name: "accepted", respond: func(w http.ResponseWriter) error {
+			return testTools.Accepted(w, "/jobs/7", headers)
+		}, status: http.StatusAccepted, location: "/jobs/7", body: `{"status_url":"/jobs/7"}`},
+		{name: "redirect", respond: func(w http.ResponseWriter) error {
+			return testTools.RedirectJSON(w, "/login")
+		}, status: http.StatusSeeOther, location: "/login", body: `{"redirect":"/login"}`},
+		{name: "redirect with status", respond: func(w http.ResponseWriter) error {
+			return testTools.RedirectJSON(w, "https://example.com/", http.StatusOK)
+		}, status: http.StatusOK, location: "https://example.com/", body: `{"redirect":"https://example.com/"}`},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		if err := e.respond(rr); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, rr.Code)
+		}
+		if got := rr.Header().Get("Location"); got != e.location {
+			t.Errorf("%s: expected Location %q, got %q", e.name, e.location, got)
+		}
+		if rr.Header().Get("Content-Type") != "application/json" || rr.Body.String() != e.body {
+			t.Errorf("%s: expected the JSON body %s, got %s", e.name, e.body, rr.Body.String())
+		}
+	}
+
+	// the headers passed in are sent, but not changed
+	if headers.Get("Location") != "" {
+		t.Error("expected the headers passed in not to be changed")
+	}
+}
@@ -0,0 +1,109 @@
+package toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+// WrappedResponseWriter wraps a http.ResponseWriter, recording the status code and number of bytes
+// written, and optionally a copy of the body, for middleware such as logging, metrics and caching which
+// need to know what a handler sent. Flushes and hijacks are passed through to the underlying writer, and
+// http.ResponseController reaches it through Unwrap.
+type WrappedResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	body        *bytes.Buffer
+	bodyLimit   int64
+	bodyTooLong bool
+}
+
+// WrapResponseWriter returns w wrapped so what is written to it is recorded.
+func WrapResponseWriter(w http.ResponseWriter) *WrappedResponseWriter {
+	return &WrappedResponseWriter{ResponseWriter: w}
+}
+
+// CaptureBody has a copy kept of the body written from now on, of up to limit bytes, to be read with Body.
+func (rw *WrappedResponseWriter) CaptureBody(limit int64) {
+	rw.body = new(bytes.Buffer)
+	rw.bodyLimit = limit
+	rw.bodyTooLong = false
+}
+
+// WriteHeader records the status code before passing it on. Informational (1xx) responses, such as 103
+// Early Hints, are passed on without being recorded, as the final status is still to come.
+func (rw *WrappedResponseWriter) WriteHeader(status int) {
+	if rw.status == 0 && (status >= http.StatusOK || status == http.StatusSwitchingProtocols) {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written, and copies them if the body is being captured, recording an implicit 200
+// status if WriteHeader wasn't called.
+func (rw *WrappedResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+
+	if rw.body != nil && !rw.bodyTooLong {
+		if int64(rw.body.Len()+n) > rw.bodyLimit {
+			rw.bodyTooLong = true
+			rw.body.Reset()
+		} else {
+			rw.body.Write(b[:n])
+		}
+	}
+	return n, err
+}
+
+// Status returns the status code written, or 200 if the handler didn't write anything.
+func (rw *WrappedResponseWriter) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// WroteHeader reports whether the response headers have been sent, after which the status can't change.
+func (rw *WrappedResponseWriter) WroteHeader() bool {
+	return rw.status != 0
+}
+
+// BytesWritten returns the number of body bytes written.
+func (rw *WrappedResponseWriter) BytesWritten() int64 {
+	return rw.bytes
+}
+
+// Body returns the body captured since CaptureBody was called. It returns false if the body wasn't
+// captured, or grew past the limit, so that a partial body is never mistaken for the whole one.
+func (rw *WrappedResponseWriter) Body() ([]byte, bool) {
+	if rw.body == nil || rw.bodyTooLong {
+		return nil, false
+	}
+	return rw.body.Bytes(), true
+}
+
+// Flush passes flushes through to the underlying writer, if it supports them. Flushing sends the
+// headers, so an implicit 200 status is recorded if WriteHeader wasn't called.
+func (rw *WrappedResponseWriter) Flush() {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Hijack passes hijacks through to the underlying writer, returning http.ErrNotSupported if it doesn't
+// support them.
+func (rw *WrappedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rw.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (rw *WrappedResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
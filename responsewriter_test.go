@@ -0,0 +1,117 @@
+package toolkit
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrapResponseWriter(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       func(w http.ResponseWriter)
+		expectedCode  int
+		expectedBytes int64
+		wroteHeader   bool
+	}{
+		{name: "nothing written", handler: func(w http.ResponseWriter) {}, expectedCode: http.StatusOK},
+		{name: "implicit status", handler: func(w http.ResponseWriter) { _, _ = w.Write([]byte("hello")) }, expectedCode: http.StatusOK, expectedBytes: 5, wroteHeader: true},
+		{name: "explicit status", handler: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("hi"))
+			_, _ = w.Write([]byte("!"))
+		}, expectedCode: http.StatusCreated, expectedBytes: 3, wroteHeader: true},
+		{name: "first status wins", handler: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusOK)
+		}, expectedCode: http.StatusNotFound, wroteHeader: true},
+		{name: "informational status", handler: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusAccepted)
+		}, expectedCode: http.StatusAccepted, wroteHeader: true},
+		{name: "flush", handler: func(w http.ResponseWriter) { w.(http.Flusher).Flush() }, expectedCode: http.StatusOK, wroteHeader: true},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		rw := WrapResponseWriter(rr)
+		e.handler(rw)
+
+		if rw.Status() != e.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.expectedCode, rw.Status())
+		}
+		if rw.BytesWritten() != e.expectedBytes {
+			t.Errorf("%s: expected %d bytes, got %d", e.name, e.expectedBytes, rw.BytesWritten())
+		}
+		if rw.WroteHeader() != e.wroteHeader {
+			t.Errorf("%s: expected WroteHeader %v, got %v", e.name, e.wroteHeader, rw.WroteHeader())
+		}
+	}
+}
+
+func TestWrappedResponseWriter_Body(t *testing.T) {
+	rw := WrapResponseWriter(httptest.NewRecorder())
+	if _, ok := rw.Body(); ok {
+		t.Error("expected no body before CaptureBody is called")
+	}
+
+	rw.CaptureBody(10)
+	_, _ = rw.Write([]byte("hello "))
+	_, _ = rw.Write([]byte("you"))
+	if body, ok := rw.Body(); !ok || string(body) != "hello you" {
+		t.Errorf("expected captured body \"hello you\", got %q (%v)", body, ok)
+	}
+
+	_, _ = rw.Write([]byte(" there"))
+	if body, ok := rw.Body(); ok {
+		t.Errorf("expected no body once over the limit, got %q", body)
+	}
+	if rw.BytesWritten() != 15 {
+		t.Errorf("expected 15 bytes written, got %d", rw.BytesWritten())
+	}
+}
+
+func TestWrappedResponseWriter_Flush(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rw := WrapResponseWriter(rr)
+	rw.Flush()
+	if !rr.Flushed {
+		t.Error("expected the flush to reach the underlying writer")
+	}
+
+	// http.ResponseController finds the underlying writer through Unwrap
+	rr = httptest.NewRecorder()
+	if err := http.NewResponseController(WrapResponseWriter(rr)).Flush(); err != nil || !rr.Flushed {
+		t.Errorf("expected flush through ResponseController, got %v", err)
+	}
+}
+
+func TestWrappedResponseWriter_Hijack(t *testing.T) {
+	if _, _, err := WrapResponseWriter(httptest.NewRecorder()).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected http.ErrNotSupported, got %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := WrapResponseWriter(w).Hijack()
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = brw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		_ = brw.Flush()
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	line, _ := bufio.NewReader(res.Body).ReadString('\n')
+	if line != "hijacked" {
+		t.Errorf("expected the hijacked response, got %q", line)
+	}
+}
@@ -0,0 +1,132 @@
+package toolkit
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps an http.Handler with extra behavior, such as logging or recovering from panics.
+type Middleware func(http.Handler) http.Handler
+
+// Router is a thin wrapper around http.ServeMux which applies a stack of middleware to every handler
+// registered through it. Groups share the underlying mux, but add a path prefix and middleware of
+// their own, so a stack can be declared once for a set of routes.
+type Router struct {
+	mux        *http.ServeMux
+	prefix     string
+	middleware []Middleware
+}
+
+// NewRouter returns a Router with an empty http.ServeMux and no middleware.
+func NewRouter() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// Use appends middleware to the router's stack. Middleware runs in the order it was added, and only
+// applies to handlers registered after the call.
+func (rt *Router) Use(mw ...Middleware) {
+	rt.middleware = append(rt.middleware, mw...)
+}
+
+// Group returns a router whose routes are registered under prefix, and which runs mw after the parent
+// router's middleware.
+func (rt *Router) Group(prefix string, mw ...Middleware) *Router {
+	stack := make([]Middleware, 0, len(rt.middleware)+len(mw))
+	stack = append(stack, rt.middleware...)
+	stack = append(stack, mw...)
+
+	return &Router{
+		mux:        rt.mux,
+		prefix:     rt.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: stack,
+	}
+}
+
+// With returns a router for registering routes which run mw after the router's middleware, without
+// adding a path prefix, for middleware which only some routes need.
+func (rt *Router) With(mw ...Middleware) *Router {
+	return rt.Group("", mw...)
+}
+
+// Handle registers handler for pattern, wrapped in the router's middleware. Patterns use the
+// http.ServeMux syntax, optionally starting with a method (e.g. "GET /users/{id}"); the group prefix is
+// added in front of the path.
+func (rt *Router) Handle(pattern string, handler http.Handler) {
+	for i := len(rt.middleware) - 1; i >= 0; i-- {
+		handler = rt.middleware[i](handler)
+	}
+	rt.mux.Handle(rt.pattern(pattern), handler)
+}
+
+// HandleFunc registers a handler function for pattern, wrapped in the router's middleware.
+func (rt *Router) HandleFunc(pattern string, fn func(http.ResponseWriter, *http.Request)) {
+	rt.Handle(pattern, http.HandlerFunc(fn))
+}
+
+// ServeHTTP dispatches the request to the handler whose pattern most closely matches it.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// pattern adds the group prefix to the path in a ServeMux pattern, keeping any method in front of it.
+func (rt *Router) pattern(pattern string) string {
+	if rt.prefix == "" {
+		return pattern
+	}
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		return rt.prefix + pattern
+	}
+	return method + " " + rt.prefix + strings.TrimLeft(path, " ")
+}
+
+// HandleJSON adapts a typed function into an http.Handler. The request body, if there is one, is read into
+// a value of type Req with ReadJSON; the function's result is then written with WriteJSON using the status
+// it returns (or 200 if it returns 0). If reading the body fails, or the function returns an error, the
+// error is sent with ErrorJSONLocalized using the returned status, or if none was given, that of an
+// *HTTPError, or 400 Bad Request.
+func HandleJSON[Req, Resp any](t *Tools, fn func(r *http.Request, in Req) (Resp, int, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in Req
+		if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+			if err := t.ReadJSON(w, r, &in); err != nil {
+				_ = t.ErrorJSONLocalized(w, r, err)
+				return
+			}
+		}
+
+		out, status, err := fn(r, in)
+		if err != nil {
+			_ = t.ErrorJSONLocalized(w, r, err, status)
+			return
+		}
+
+		if status == 0 {
+			status = http.StatusOK
+		}
+		_ = t.WriteJSON(w, status, out)
+	})
+}
+
+// HandlerFunc is a handler which returns its error instead of sending it. Adapt it into an http.Handler
+// with Tools.Handler.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handler adapts fn into an http.Handler which sends the error fn returns with ErrorJSONLocalized, so
+// handlers can return errors rather than writing them, with the status of an *HTTPError, or 400 Bad
+// Request. If fn has already started the response when it returns an error, the error is logged instead,
+// as its status can no longer change.
+func (t *Tools) Handler(fn HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := WrapResponseWriter(w)
+		err := fn(rw, r)
+		if err == nil {
+			return
+		}
+		if rw.WroteHeader() {
+			t.LogError(r.Context(), "handler failed after writing its response", "method", r.Method, "path", r.URL.Path, "status", rw.Status(), "error", err)
+			return
+		}
+		_ = t.ErrorJSONLocalized(w, r, err)
+	})
+}
@@ -0,0 +1,152 @@
+package toolkit
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// traceMiddleware appends name to the X-Trace response header, so tests can see which middleware ran, and in what order.
+func traceMiddleware(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var routerTests = []struct {
+	name           string
+	method         string
+	path           string
+	expectedStatus int
+	expectedTrace  string
+}{
+	{name: "root route", method: http.MethodGet, path: "/health", expectedStatus: http.StatusOK, expectedTrace: "root"},
+	{name: "group route", method: http.MethodGet, path: "/api/users/42", expectedStatus: http.StatusOK, expectedTrace: "root,api"},
+	{name: "nested group route", method: http.MethodPost, path: "/api/admin/reindex", expectedStatus: http.StatusOK, expectedTrace: "root,api,admin"},
+	{name: "route with its own middleware", method: http.MethodDelete, path: "/api/users/42", expectedStatus: http.StatusOK, expectedTrace: "root,api,auth"},
+	{name: "wrong method", method: http.MethodGet, path: "/api/admin/reindex", expectedStatus: http.StatusMethodNotAllowed, expectedTrace: ""},
+	{name: "not found", method: http.MethodGet, path: "/nope", expectedStatus: http.StatusNotFound, expectedTrace: ""},
+}
+
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	router.Use(traceMiddleware("root"))
+	router.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {})
+
+	api := router.Group("/api/", traceMiddleware("api"))
+	api.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "42" {
+			t.Errorf("wrong path value; expected 42 but got %s", r.PathValue("id"))
+		}
+	})
+
+	api.With(traceMiddleware("auth")).HandleFunc("DELETE /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	admin := api.Group("/admin", traceMiddleware("admin"))
+	admin.HandleFunc("POST /reindex", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, e := range routerTests {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(e.method, e.path, nil)
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if trace := strings.Join(rr.Header().Values("X-Trace"), ","); trace != e.expectedTrace {
+			t.Errorf("%s: wrong middleware; expected %q but got %q", e.name, e.expectedTrace, trace)
+		}
+	}
+}
+
+type greetRequest struct {
+	Name string `json:"name"`
+}
+
+type greetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+var handleJSONTests = []struct {
+	name           string
+	body           string
+	expectedStatus int
+	expectedBody   string
+}{
+	{name: "valid", body: `{"name": "Jack"}`, expectedStatus: http.StatusCreated, expectedBody: `{"greeting":"Hello, Jack"}`},
+	{name: "handler error", body: `{"name": ""}`, expectedStatus: http.StatusUnprocessableEntity, expectedBody: `{"error":true,"message":"name is required"}`},
+	{name: "bad json", body: `{"name": }`, expectedStatus: http.StatusBadRequest, expectedBody: `{"error":true,"message":"body contains badly-formed JSON (at character 10)"}`},
+}
+
+func TestHandleJSON(t *testing.T) {
+	var testTools Tools
+
+	handler := HandleJSON(&testTools, func(r *http.Request, in greetRequest) (greetResponse, int, error) {
+		if in.Name == "" {
+			return greetResponse{}, http.StatusUnprocessableEntity, errors.New("name is required")
+		}
+		return greetResponse{Greeting: "Hello, " + in.Name}, http.StatusCreated, nil
+	})
+
+	for _, e := range handleJSONTests {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if rr.Body.String() != e.expectedBody {
+			t.Errorf("%s: wrong body; expected %s but got %s", e.name, e.expectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_Handler(t *testing.T) {
+	var logBuf strings.Builder
+	testTools := Tools{LogHandler: slog.NewTextHandler(&logBuf, nil)}
+
+	tests := []struct {
+		name           string
+		fn             HandlerFunc
+		expectedStatus int
+		expectedBody   string
+		logged         bool
+	}{
+		{name: "success", fn: func(w http.ResponseWriter, r *http.Request) error {
+			return testTools.WriteJSON(w, http.StatusOK, greetResponse{Greeting: "Hello"})
+		}, expectedStatus: http.StatusOK, expectedBody: `{"greeting":"Hello"}`},
+		{name: "plain error", fn: func(w http.ResponseWriter, r *http.Request) error {
+			return errors.New("name is required")
+		}, expectedStatus: http.StatusBadRequest, expectedBody: `{"error":true,"message":"name is required"}`},
+		{name: "http error", fn: func(w http.ResponseWriter, r *http.Request) error {
+			return NotFound(errors.New("no row"))
+		}, expectedStatus: http.StatusNotFound, expectedBody: `{"error":true,"message":"Not Found"}`},
+		{name: "error after writing", fn: func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusAccepted)
+			return errors.New("stream broke")
+		}, expectedStatus: http.StatusAccepted, logged: true},
+	}
+
+	for _, e := range tests {
+		logBuf.Reset()
+		rr := httptest.NewRecorder()
+		testTools.Handler(e.fn).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if strings.TrimSpace(rr.Body.String()) != e.expectedBody {
+			t.Errorf("%s: wrong body; expected %s but got %s", e.name, e.expectedBody, rr.Body.String())
+		}
+		if logged := strings.Contains(logBuf.String(), "stream broke"); logged != e.logged {
+			t.Errorf("%s: expected the error to be logged: %t", e.name, e.logged)
+		}
+	}
+}
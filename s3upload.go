@@ -0,0 +1,298 @@
+package toolkit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config holds the credentials and location of the S3 (or S3-compatible) storage used by
+// GeneratePresignedUpload and VerifyPresignedUpload.
+type S3Config struct {
+	Region          string // the bucket's region, e.g. "eu-west-1"
+	AccessKeyID     string // the access key the uploads are signed with
+	SecretAccessKey string // the secret of AccessKeyID
+	SessionToken    string // the session token of temporary credentials, e.g. from an IAM role
+	Endpoint        string // if set, the URL of an S3-compatible store such as MinIO, addressed path-style; defaults to AWS's virtual-hosted URL of the bucket
+}
+
+// PresignedUpload is a form a browser posts a file to, uploading it straight to an S3 bucket. The
+// browser sends a multipart/form-data POST to URL with each of Fields, followed by the file in a field
+// named "file". Once it has, it passes Token back to the application, which checks the upload with
+// VerifyPresignedUpload.
+type PresignedUpload struct {
+	URL     string            `json:"url"`
+	Fields  map[string]string `json:"fields"`
+	Token   string            `json:"token"`
+	Expires time.Time         `json:"expires"`
+}
+
+// S3Object describes an object uploaded to S3, as checked by VerifyPresignedUpload.
+type S3Object struct {
+	Bucket      string `json:"bucket"`
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	ETag        string `json:"etag"`
+}
+
+// presignedUploadClaims are the details of an upload carried, signed, in its token.
+type presignedUploadClaims struct {
+	Bucket      string `json:"b"`
+	Key         string `json:"k"`
+	ContentType string `json:"t"`
+	MaxSize     int64  `json:"s"`
+}
+
+// s3DateFormat is the format of the dates used in AWS Signature Version 4.
+const s3DateFormat = "20060102T150405Z"
+
+// GeneratePresignedUpload returns a presigned POST form which lets a browser upload a file of
+// contentType, of at most maxSize bytes, to key in bucket directly, for the next ttl. S3 itself enforces
+// the key, type and size, so the file never passes through the application. The form is signed with
+// the credentials in S3, using AWS Signature Version 4. Its token is signed with URLSigningKey, which
+// must be set, so VerifyPresignedUpload can tell the callback is about an upload it allowed.
+func (t *Tools) GeneratePresignedUpload(bucket, key, contentType string, maxSize int64, ttl time.Duration) (*PresignedUpload, error) {
+	if t.S3.Region == "" || t.S3.AccessKeyID == "" || t.S3.SecretAccessKey == "" {
+		return nil, errors.New("S3 region and credentials are not configured")
+	}
+	if bucket == "" || key == "" {
+		return nil, errors.New("bucket and key are required")
+	}
+	if maxSize <= 0 {
+		return nil, errors.New("maxSize must be positive")
+	}
+
+	token, err := t.presignedUploadToken(presignedUploadClaims{Bucket: bucket, Key: key, ContentType: contentType, MaxSize: maxSize})
+	if err != nil {
+		return nil, err
+	}
+	u, err := t.S3.bucketURL(bucket, "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	expires := now.Add(ttl)
+	date := now.Format(s3DateFormat)
+	credential := t.S3.AccessKeyID + "/" + t.S3.scope(now)
+
+	fields := map[string]string{
+		"key":              key,
+		"Content-Type":     contentType,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": credential,
+		"x-amz-date":       date,
+	}
+	conditions := []any{
+		map[string]string{"bucket": bucket},
+		[]any{"eq", "$key", key},
+		[]any{"eq", "$Content-Type", contentType},
+		[]any{"content-length-range", 0, maxSize},
+		map[string]string{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]string{"x-amz-credential": credential},
+		map[string]string{"x-amz-date": date},
+	}
+	if t.S3.SessionToken != "" {
+		fields["x-amz-security-token"] = t.S3.SessionToken
+		conditions = append(conditions, map[string]string{"x-amz-security-token": t.S3.SessionToken})
+	}
+
+	policy, err := json.Marshal(map[string]any{
+		"expiration": expires.Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fields["policy"] = base64.StdEncoding.EncodeToString(policy)
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(t.S3.signingKey(now), fields["policy"]))
+
+	return &PresignedUpload{URL: u.String(), Fields: fields, Token: token, Expires: expires}, nil
+}
+
+// VerifyPresignedUpload checks the token of an upload made with a form from GeneratePresignedUpload,
+// which the browser sends once it has uploaded the file. The token must have been signed with
+// URLSigningKey, and the object must exist in S3, with the type and at most the size the upload allowed.
+// It returns the object's details, so the application can record it.
+func (t *Tools) VerifyPresignedUpload(ctx context.Context, token string) (*S3Object, error) {
+	claims, err := t.parsePresignedUploadToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := t.S3.bucketURL(claims.Bucket, claims.Key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	t.S3.signRequest(req, time.Now().UTC())
+
+	res, err := t.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("object %s was not uploaded", claims.Key)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("checking object %s: %s", claims.Key, res.Status)
+	}
+
+	obj := &S3Object{
+		Bucket:      claims.Bucket,
+		Key:         claims.Key,
+		Size:        res.ContentLength,
+		ContentType: res.Header.Get("Content-Type"),
+		ETag:        res.Header.Get("ETag"),
+	}
+	if obj.Size > claims.MaxSize {
+		return nil, fmt.Errorf("object %s is %d bytes, larger than the %d allowed", claims.Key, obj.Size, claims.MaxSize)
+	}
+	if obj.ContentType != claims.ContentType {
+		return nil, fmt.Errorf("object %s has the type %q, not %q", claims.Key, obj.ContentType, claims.ContentType)
+	}
+	return obj, nil
+}
+
+// presignedUploadToken returns claims encoded and signed with URLSigningKey.
+func (t *Tools) presignedUploadToken(claims presignedUploadClaims) (string, error) {
+	if len(t.URLSigningKey) == 0 {
+		return "", errors.New("no URL signing key configured")
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + hex.EncodeToString(hmacSHA256(t.URLSigningKey, "presigned-upload:"+encoded)), nil
+}
+
+// parsePresignedUploadToken returns the claims in a token from presignedUploadToken, checking its signature.
+func (t *Tools) parsePresignedUploadToken(token string) (presignedUploadClaims, error) {
+	var claims presignedUploadClaims
+	if len(t.URLSigningKey) == 0 {
+		return claims, errors.New("no URL signing key configured")
+	}
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(hmacSHA256(t.URLSigningKey, "presigned-upload:"+encoded)))) {
+		return claims, errors.New("invalid upload token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, errors.New("invalid upload token")
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, errors.New("invalid upload token")
+	}
+	return claims, nil
+}
+
+// bucketURL returns the URL of key in bucket, or of the bucket itself if key is empty.
+func (c S3Config) bucketURL(bucket, key string) (*url.URL, error) {
+	var u *url.URL
+	var err error
+	if c.Endpoint != "" {
+		u, err = url.Parse(strings.TrimSuffix(c.Endpoint, "/") + "/" + bucket + "/")
+	} else {
+		u, err = url.Parse("https://" + bucket + ".s3." + c.Region + ".amazonaws.com/")
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.Path += key
+	u.RawPath = u.Path[:len(u.Path)-len(key)] + s3EscapePath(key)
+	return u, nil
+}
+
+// scope returns the credential scope of requests signed at now.
+func (c S3Config) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + c.Region + "/s3/aws4_request"
+}
+
+// signingKey derives the key requests signed at now are signed with.
+func (c S3Config) signingKey(now time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+// signRequest adds an AWS Signature Version 4 Authorization header to req, which has no body or query
+// string. The host and every header already set on req are signed.
+func (c S3Config) signRequest(req *http.Request, now time.Time) {
+	emptyHash := sha256.Sum256(nil)
+	payloadHash := hex.EncodeToString(emptyHash[:])
+	req.Header.Set("X-Amz-Date", now.Format(s3DateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	headers := []string{"host"}
+	for h := range req.Header {
+		headers = append(headers, strings.ToLower(h))
+	}
+	sort.Strings(headers)
+
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		v := strings.Join(req.Header.Values(h), ",")
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format(s3DateFormat) + "\n" + c.scope(now) + "\n" + hex.EncodeToString(hash[:])
+	signature := hex.EncodeToString(hmacSHA256(c.signingKey(now), stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKeyID+"/"+c.scope(now)+
+		",SignedHeaders="+signedHeaders+",Signature="+signature)
+}
+
+// s3EscapePath escapes an object key as S3 expects in a path: every byte except unreserved characters
+// and slashes is percent-encoded.
+func s3EscapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
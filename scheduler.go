@@ -0,0 +1,298 @@
+package toolkit
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Schedule decides when a scheduled task runs next.
+type Schedule interface {
+	// Next returns the first time the task should run after after, or the zero time if it never should.
+	Next(after time.Time) time.Time
+}
+
+// intervalSchedule runs a task at a fixed interval.
+type intervalSchedule time.Duration
+
+// Next implements Schedule.
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(s))
+}
+
+// Every returns a Schedule which runs a task every d, measured from when the scheduler starts.
+func Every(d time.Duration) Schedule {
+	return intervalSchedule(d)
+}
+
+// cronSchedule is a parsed cron expression. Each field is a bit set of the values it allows.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// cronDescriptors are the shorthands Cron accepts in place of five fields.
+var cronDescriptors = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// Cron parses a standard five-field cron expression (minute, hour, day of month, month, day of week),
+// returning a Schedule which follows it in the time zone of the times it is given. Fields accept "*",
+// single values, ranges ("1-5"), steps ("*/15", "0-30/10") and lists ("1,15"); day of week runs from 0
+// (Sunday) to 6, and 7 also means Sunday. As in cron, when both day fields are restricted a day matching
+// either is used. The shorthands @hourly, @daily, @weekly, @monthly and @yearly are also accepted.
+func Cron(expr string) (Schedule, error) {
+	if d, ok := cronDescriptors[strings.TrimSpace(expr)]; ok {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	var s cronSchedule
+	var err error
+	bounds := []struct {
+		dst      *uint64
+		min, max int
+		name     string
+	}{
+		{&s.minute, 0, 59, "minute"},
+		{&s.hour, 0, 23, "hour"},
+		{&s.dom, 1, 31, "day of month"},
+		{&s.month, 1, 12, "month"},
+		{&s.dow, 0, 7, "day of week"},
+	}
+	for i, b := range bounds {
+		if *b.dst, err = parseCronField(fields[i], b.min, b.max); err != nil {
+			return nil, fmt.Errorf("cron expression %q has an invalid %s: %w", expr, b.name, err)
+		}
+	}
+
+	// 7 is another name for Sunday.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return &s, nil
+}
+
+// parseCronField parses one field of a cron expression into a bit set of the values it allows.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value %q", loStr)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid value %q", hiStr)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+
+	return bits, nil
+}
+
+// Next implements Schedule.
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Every valid expression matches at least once in a leap cycle; give up on ones like "0 0 31 2 *".
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			// step in t's zone, since Truncate works in UTC and zones such as India's are offset by half an hour
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			if !next.After(t) {
+				next = t.Add(time.Hour)
+			}
+			t = next
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of t is allowed by the day of month and day of week fields.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// TaskOptions configures a scheduled task.
+type TaskOptions struct {
+	Jitter       time.Duration // random delay of up to Jitter added to each run, so instances don't all fire at once
+	AllowOverlap bool          // start a run even if the previous one hasn't finished; runs are skipped otherwise
+	RunAtStart   bool          // run once as soon as the scheduler starts, as well as on the schedule
+}
+
+// scheduledTask is a task registered with a Scheduler.
+type scheduledTask struct {
+	name     string
+	schedule Schedule
+	job      Job
+	opts     TaskOptions
+	running  atomic.Bool
+}
+
+// Scheduler runs tasks periodically, on an interval or a cron schedule.
+type Scheduler struct {
+	tools *Tools
+	mu    sync.Mutex
+	tasks []*scheduledTask
+}
+
+// NewScheduler returns an empty Scheduler.
+func (t *Tools) NewScheduler() *Scheduler {
+	return &Scheduler{tools: t}
+}
+
+// Add registers job to run on schedule. Tasks must be added before Start is called.
+func (s *Scheduler) Add(name string, schedule Schedule, job Job, opts ...TaskOptions) {
+	task := &scheduledTask{name: name, schedule: schedule, job: job}
+	if len(opts) > 0 {
+		task.opts = opts[0]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, task)
+}
+
+// Start runs the registered tasks until ctx is cancelled, and returns a channel which is closed once
+// every task has stopped and any runs in progress have returned. Runs are given ctx, failures are logged
+// at error level, and a task which panics is logged and keeps its schedule.
+func (s *Scheduler) Start(ctx context.Context) <-chan struct{} {
+	s.mu.Lock()
+	tasks := append([]*scheduledTask(nil), s.tasks...)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, task, &wg)
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// loop waits for each run of a task in turn, until ctx is cancelled. Runs are tracked in wg.
+func (s *Scheduler) loop(ctx context.Context, task *scheduledTask, wg *sync.WaitGroup) {
+	if task.opts.RunAtStart {
+		s.trigger(ctx, task, wg)
+	}
+
+	last := time.Now()
+	for {
+		next := task.schedule.Next(last)
+		// If runs were missed (e.g. the machine was suspended), skip them rather than catching up.
+		if now := time.Now(); !next.IsZero() && next.Before(now) {
+			next = task.schedule.Next(now)
+		}
+		if next.IsZero() {
+			return
+		}
+		last = next
+
+		delay := time.Until(next)
+		if task.opts.Jitter > 0 {
+			delay += rand.N(task.opts.Jitter)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// The timer and cancellation may both be ready; don't start a run after the scheduler has stopped.
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.trigger(ctx, task, wg)
+	}
+}
+
+// trigger starts a run of task, unless the previous one is still going and overlaps aren't allowed.
+func (s *Scheduler) trigger(ctx context.Context, task *scheduledTask, wg *sync.WaitGroup) {
+	if !task.running.CompareAndSwap(false, true) && !task.opts.AllowOverlap {
+		s.tools.LogWarn(ctx, "scheduler: skipping run, previous run still in progress", "task", task.name)
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer task.running.Store(false)
+		defer func() {
+			if rec := recover(); rec != nil {
+				s.tools.LogError(ctx, "scheduler: task panicked", "task", task.name, "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+			}
+		}()
+
+		if err := task.job(ctx); err != nil {
+			s.tools.LogError(ctx, "scheduler: task failed", "task", task.name, "error", err)
+		}
+	}()
+}
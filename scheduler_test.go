@@ -0,0 +1,114 @@
+package toolkit
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var cronTests = []struct {
+	name          string
+	expr          string
+	after         string
+	expected      string
+	errorExpected bool
+}{
+	{name: "every minute", expr: "* * * * *", after: "2024-01-01T10:00:30Z", expected: "2024-01-01T10:01:00Z"},
+	{name: "every 15 minutes", expr: "*/15 * * * *", after: "2024-01-01T10:16:00Z", expected: "2024-01-01T10:30:00Z"},
+	{name: "daily at 02:30", expr: "30 2 * * *", after: "2024-01-01T03:00:00Z", expected: "2024-01-02T02:30:00Z"},
+	{name: "weekdays at 9", expr: "0 9 * * 1-5", after: "2024-01-05T09:00:00Z", expected: "2024-01-08T09:00:00Z"},
+	{name: "sunday as 7", expr: "0 0 * * 7", after: "2024-01-01T00:00:00Z", expected: "2024-01-07T00:00:00Z"},
+	{name: "list", expr: "0 0 1,15 * *", after: "2024-01-02T00:00:00Z", expected: "2024-01-15T00:00:00Z"},
+	{name: "day of month or week", expr: "0 0 13 * 5", after: "2024-01-06T00:00:00Z", expected: "2024-01-12T00:00:00Z"},
+	{name: "leap day", expr: "0 0 29 2 *", after: "2024-03-01T00:00:00Z", expected: "2028-02-29T00:00:00Z"},
+	{name: "range with step", expr: "0-30/10 * * * *", after: "2024-01-01T10:21:00Z", expected: "2024-01-01T10:30:00Z"},
+	{name: "monthly", expr: "@monthly", after: "2024-12-15T00:00:00Z", expected: "2025-01-01T00:00:00Z"},
+	{name: "half hour offset", expr: "0 11 * * *", after: "2024-01-01T10:45:00+05:30", expected: "2024-01-01T11:00:00+05:30"},
+	{name: "quarter hour offset", expr: "0 * * * *", after: "2024-01-01T10:45:00+05:45", expected: "2024-01-01T11:00:00+05:45"},
+	{name: "never", expr: "0 0 31 2 *", after: "2024-01-01T00:00:00Z", expected: "0001-01-01T00:00:00Z"},
+	{name: "too few fields", expr: "* * * *", errorExpected: true},
+	{name: "out of range", expr: "60 * * * *", errorExpected: true},
+	{name: "bad step", expr: "*/0 * * * *", errorExpected: true},
+	{name: "reversed range", expr: "* 5-1 * * *", errorExpected: true},
+}
+
+func TestCron(t *testing.T) {
+	for _, e := range cronTests {
+		s, err := Cron(e.expr)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected error but got none", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+
+		after, _ := time.Parse(time.RFC3339, e.after)
+		if got := s.Next(after).Format(time.RFC3339); got != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	var testTools Tools
+	s := testTools.NewScheduler()
+
+	var fast, slow, startup atomic.Int32
+	s.Add("fast", Every(5*time.Millisecond), func(ctx context.Context) error {
+		fast.Add(1)
+		return nil
+	})
+	s.Add("slow", Every(5*time.Millisecond), func(ctx context.Context) error {
+		slow.Add(1)
+		select {
+		case <-ctx.Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+		return nil
+	})
+	s.Add("startup", Every(time.Hour), func(ctx context.Context) error {
+		startup.Add(1)
+		panic("survived")
+	}, TaskOptions{RunAtStart: true, Jitter: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := s.Start(ctx)
+
+	time.Sleep(60 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+
+	if fast.Load() < 3 {
+		t.Errorf("expected fast task to run several times, ran %d", fast.Load())
+	}
+	if slow.Load() != 1 {
+		t.Errorf("expected overlapping runs of slow task to be skipped, ran %d", slow.Load())
+	}
+	if startup.Load() != 1 {
+		t.Errorf("expected startup task to run once, ran %d", startup.Load())
+	}
+}
+
+func TestCron_Location(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	s, _ := Cron("0 11 * * *")
+
+	after := time.Date(2024, 1, 1, 10, 45, 0, 0, kolkata)
+	expected := time.Date(2024, 1, 1, 11, 0, 0, 0, kolkata)
+	if got := s.Next(after); !got.Equal(expected) {
+		t.Errorf("expected %s but got %s", expected, got)
+	}
+}
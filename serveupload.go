@@ -0,0 +1,143 @@
+package toolkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MetadataLoader is implemented by a MetadataStore which can also return the metadata it has saved. If
+// MetadataStore implements it, ServeUpload uses it to find the original name and type of a file.
+type MetadataLoader interface {
+	// LoadMetadata returns the metadata saved for the stored file name, and reports whether there is any.
+	LoadMetadata(ctx context.Context, name string) (UploadMetadata, bool, error)
+}
+
+// ServeUploadOptions configures ServeUpload.
+type ServeUploadOptions struct {
+	Dir       string                                          // the upload directory
+	Name      string                                          // the stored name of the file, as returned in UploadedFile.NewFileName
+	Inline    bool                                            // display the file in the browser rather than download it
+	Authorize func(r *http.Request, meta UploadMetadata) bool // called before the file is served; if it returns false, the response is 403 Forbidden
+}
+
+// ServeUpload serves a file stored by UploadFiles. Its original name, used in the Content-Disposition
+// header, and its type are taken from MetadataStore, if it implements MetadataLoader, or else from the
+// file's metadata sidecar (see WriteUploadMetadata); without either, the stored name is used and the type
+// is detected from the content. Range and conditional requests are handled as by DownloadStaticFile.
+// Responses are sent with X-Content-Type-Options: nosniff, so take care serving untrusted HTML or SVG inline.
+// As with UploadFiles, files for a tenant resolved by TenantResolver are served from its subdirectory of Dir.
+func (t *Tools) ServeUpload(w http.ResponseWriter, r *http.Request, opts ServeUploadOptions) {
+	name := opts.Name
+	if name == "" || strings.HasPrefix(filepath.Base(name), ".") || strings.HasSuffix(name, uploadMetadataSuffix) {
+		// temporary files and metadata sidecars are never served
+		http.NotFound(w, r)
+		return
+	}
+	fp, err := t.EnsureWithinBase(tenantDir(r.Context(), opts.Dir), name)
+	if err != nil {
+		http.Error(w, "invalid file path", http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(fp)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	meta, err := t.loadUploadMetadata(r.Context(), fp, name)
+	if err != nil {
+		t.LogError(r.Context(), "loading upload metadata", "name", name, "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if meta.Size == 0 {
+		meta.Size = info.Size()
+	}
+
+	if opts.Authorize != nil && !opts.Authorize(r, meta) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if meta.ContentType == "" {
+		head := make([]byte, fileSniffLen)
+		n, _ := io.ReadFull(f, head)
+		meta.ContentType = t.DetectFileType(head[:n])
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	disposition := "attachment"
+	if opts.Inline {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": meta.OriginalFileName}))
+	w.Header().Set("Content-Type", meta.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", fileETag(info))
+
+	http.ServeContent(w, r, meta.OriginalFileName, info.ModTime(), f)
+}
+
+// loadUploadMetadata returns the metadata of the stored file name, at fp, from MetadataStore or its
+// sidecar. If there is none, it returns metadata with just the names.
+func (t *Tools) loadUploadMetadata(ctx context.Context, fp, name string) (UploadMetadata, error) {
+	meta := UploadMetadata{NewFileName: name, OriginalFileName: filepath.Base(name)}
+
+	if loader, ok := t.MetadataStore.(MetadataLoader); ok {
+		stored, found, err := loader.LoadMetadata(ctx, name)
+		if err != nil {
+			return meta, err
+		}
+		if found {
+			return withUploadNames(stored, meta), nil
+		}
+	}
+
+	b, err := os.ReadFile(fp + uploadMetadataSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+	var stored UploadMetadata
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return meta, err
+	}
+	return withUploadNames(stored, meta), nil
+}
+
+// withUploadNames fills in the names of stored metadata from defaults, where they are missing.
+func withUploadNames(stored, defaults UploadMetadata) UploadMetadata {
+	if stored.NewFileName == "" {
+		stored.NewFileName = defaults.NewFileName
+	}
+	if stored.OriginalFileName == "" {
+		stored.OriginalFileName = defaults.OriginalFileName
+	}
+	return stored
+}
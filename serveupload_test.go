@@ -0,0 +1,105 @@
+package toolkit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testMetadataLoader struct {
+	testMetadataStore
+	loaded map[string]UploadMetadata
+}
+
+func (s *testMetadataLoader) LoadMetadata(ctx context.Context, name string) (UploadMetadata, bool, error) {
+	meta, ok := s.loaded[name]
+	return meta, ok, nil
+}
+
+func TestTools_ServeUpload(t *testing.T) {
+	dir := t.TempDir()
+	testTools := Tools{WriteUploadMetadata: true}
+	file, err := uploadTestImage(t, WithUploaderID(context.Background(), "ann"), &testTools, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("plain text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ownerOnly := func(r *http.Request, meta UploadMetadata) bool {
+		return meta.UploaderID == r.Header.Get("X-User")
+	}
+
+	tests := []struct {
+		name                string
+		opts                ServeUploadOptions
+		user                string
+		expectedStatus      int
+		expectedType        string
+		expectedDisposition string
+	}{
+		{name: "attachment", opts: ServeUploadOptions{Name: file.NewFileName}, expectedStatus: http.StatusOK, expectedType: "image/png", expectedDisposition: `attachment; filename=img.png`},
+		{name: "inline", opts: ServeUploadOptions{Name: file.NewFileName, Inline: true}, expectedStatus: http.StatusOK, expectedType: "image/png", expectedDisposition: `inline; filename=img.png`},
+		{name: "authorized", opts: ServeUploadOptions{Name: file.NewFileName, Authorize: ownerOnly}, user: "ann", expectedStatus: http.StatusOK, expectedType: "image/png", expectedDisposition: `attachment; filename=img.png`},
+		{name: "forbidden", opts: ServeUploadOptions{Name: file.NewFileName, Authorize: ownerOnly}, user: "bob", expectedStatus: http.StatusForbidden},
+		{name: "without metadata", opts: ServeUploadOptions{Name: "notes.txt"}, expectedStatus: http.StatusOK, expectedType: "text/plain; charset=utf-8", expectedDisposition: `attachment; filename=notes.txt`},
+		{name: "missing", opts: ServeUploadOptions{Name: "missing.png"}, expectedStatus: http.StatusNotFound},
+		{name: "sidecar", opts: ServeUploadOptions{Name: file.NewFileName + ".meta.json"}, expectedStatus: http.StatusNotFound},
+		{name: "traversal", opts: ServeUploadOptions{Name: "../img.png"}, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, e := range tests {
+		e.opts.Dir = dir
+		req := httptest.NewRequest(http.MethodGet, "/files", nil)
+		req.Header.Set("X-User", e.user)
+		rr := httptest.NewRecorder()
+
+		testTools.ServeUpload(rr, req, e.opts)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if e.expectedStatus != http.StatusOK {
+			continue
+		}
+		if got := rr.Header().Get("Content-Type"); got != e.expectedType {
+			t.Errorf("%s: expected content type %q but got %q", e.name, e.expectedType, got)
+		}
+		if got := rr.Header().Get("Content-Disposition"); got != e.expectedDisposition {
+			t.Errorf("%s: expected disposition %q but got %q", e.name, e.expectedDisposition, got)
+		}
+		if rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("%s: nosniff header not set", e.name)
+		}
+	}
+}
+
+func TestTools_ServeUpload_MetadataLoader(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "abc123.bin"), []byte("report"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testTools := Tools{MetadataStore: &testMetadataLoader{loaded: map[string]UploadMetadata{
+		"abc123.bin": {OriginalFileName: "Quarterly Résumé.pdf", ContentType: "application/pdf"},
+	}}}
+
+	req := httptest.NewRequest(http.MethodGet, "/files", nil)
+	req.Header.Set("Range", "bytes=0-2")
+	rr := httptest.NewRecorder()
+	testTools.ServeUpload(rr, req, ServeUploadOptions{Dir: dir, Name: "abc123.bin"})
+
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "rep" {
+		t.Errorf("expected a partial response, got %d %q", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("wrong content type %q", got)
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != "attachment; filename*=utf-8''Quarterly%20R%C3%A9sum%C3%A9.pdf" {
+		t.Errorf("wrong disposition %q", got)
+	}
+}
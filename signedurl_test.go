@@ -0,0 +1,63 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var signedURLTests = []struct {
+	name          string
+	path          string
+	expiry        time.Duration
+	tamper        func(string) string
+	errorExpected bool
+}{
+	{name: "valid", path: "/downloads/pic.jpg", expiry: time.Minute, errorExpected: false},
+	{name: "valid with query", path: "/downloads/pic.jpg?size=large", expiry: time.Minute, errorExpected: false},
+	{name: "expired", path: "/downloads/pic.jpg", expiry: -time.Minute, errorExpected: true},
+	{name: "changed path", path: "/downloads/pic.jpg", expiry: time.Minute, tamper: func(s string) string { return strings.Replace(s, "pic.jpg", "img.png", 1) }, errorExpected: true},
+	{name: "changed query", path: "/downloads/pic.jpg?size=large", expiry: time.Minute, tamper: func(s string) string { return strings.Replace(s, "size=large", "size=small", 1) }, errorExpected: true},
+	{name: "unsigned", path: "/downloads/pic.jpg", expiry: time.Minute, tamper: func(s string) string { return "/downloads/pic.jpg" }, errorExpected: true},
+}
+
+func TestTools_SignURL(t *testing.T) {
+	var testTools Tools
+	testTools.URLSigningKey = []byte("secret")
+
+	for _, e := range signedURLTests {
+		signed, err := testTools.SignURL(e.path, e.expiry)
+		if err != nil {
+			t.Errorf("%s: failed to sign URL: %s", e.name, err)
+			continue
+		}
+
+		if e.tamper != nil {
+			signed = e.tamper(signed)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, signed, nil)
+		err = testTools.VerifySignedURL(req)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected but one received: %s", e.name, err)
+		}
+	}
+}
+
+func TestTools_SignURL_NoKey(t *testing.T) {
+	var testTools Tools
+
+	if _, err := testTools.SignURL("/downloads/pic.jpg", time.Minute); err == nil {
+		t.Error("expected an error signing without a key, but none received")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/downloads/pic.jpg", nil)
+	if err := testTools.VerifySignedURL(req); err == nil {
+		t.Error("expected an error verifying without a key, but none received")
+	}
+}
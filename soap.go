@@ -0,0 +1,168 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// soapEnvelopeNS is the namespace of SOAP 1.1 envelopes.
+const soapEnvelopeNS = "http://schemas.xmlsoap.org/soap/envelope/"
+
+// SOAPFault is a fault returned in the body of a SOAP 1.1 response. It is returned as an error by
+// ParseSOAPResponse and PushSOAPToRemote.
+type SOAPFault struct {
+	Code   string          `xml:"faultcode"`
+	String string          `xml:"faultstring"`
+	Actor  string          `xml:"faultactor"`
+	Detail SOAPFaultDetail `xml:"detail"`
+}
+
+// SOAPFaultDetail holds the application-specific detail of a SOAPFault, as raw XML.
+type SOAPFaultDetail struct {
+	Content string `xml:",innerxml"`
+}
+
+// Error implements the error interface.
+func (f *SOAPFault) Error() string {
+	return fmt.Sprintf("soap fault %s: %s", f.Code, f.String)
+}
+
+// BuildSOAPEnvelope returns a SOAP 1.1 envelope containing body, and header if one is given, encoded the
+// same way as by WriteXML. Body is usually a struct whose XMLName gives the operation and its namespace:
+//
+//	type GetPrice struct {
+//		XMLName xml.Name `xml:"http://example.com/prices GetPrice"`
+//		Item    string   `xml:"Item"`
+//	}
+func (t *Tools) BuildSOAPEnvelope(body any, header ...any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+
+	// The prefix is written by hand, as encoding/xml would otherwise put the payload in the envelope's
+	// default namespace.
+	envelope := xml.StartElement{
+		Name: xml.Name{Local: "soap:Envelope"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns:soap"}, Value: soapEnvelopeNS}},
+	}
+	if err := enc.EncodeToken(envelope); err != nil {
+		return nil, err
+	}
+
+	if len(header) > 0 && header[0] != nil {
+		if err := encodeSOAPSection(enc, "soap:Header", header[0]); err != nil {
+			return nil, err
+		}
+	}
+	if err := encodeSOAPSection(enc, "soap:Body", body); err != nil {
+		return nil, err
+	}
+
+	if err := enc.EncodeToken(envelope.End()); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeSOAPSection writes the header or body element of an envelope, containing data.
+func encodeSOAPSection(enc *xml.Encoder, name string, data any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if data != nil {
+		if err := encodeXMLValue(enc, xml.StartElement{}, reflect.ValueOf(data), "request"); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// ParseSOAPResponse reads a SOAP envelope from r and decodes the first element of its body into data. If
+// the body holds a fault, it is returned as a *SOAPFault. The envelope is read with the same limits as
+// ReadXML, including Limits.MaxBody or MaxXMLSize.
+func (t *Tools) ParseSOAPResponse(r io.Reader, data any) error {
+	maxBytes := t.bodyLimit(t.MaxXMLSize, defaultMaxUpload)
+
+	b, err := io.ReadAll(io.LimitReader(r, int64(maxBytes)+1))
+	if err != nil {
+		return err
+	}
+	if len(b) > maxBytes {
+		return fmt.Errorf("response must not be larger than %d bytes", maxBytes)
+	}
+
+	dec, err := t.newXMLDecoder(b)
+	if err != nil {
+		return err
+	}
+
+	inBody := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return errors.New("response does not contain a SOAP body")
+		}
+		if err != nil {
+			return err
+		}
+
+		switch se := tok.(type) {
+		case xml.StartElement:
+			if !inBody {
+				inBody = se.Name.Local == "Body"
+				continue
+			}
+			if se.Name.Local == "Fault" {
+				fault := new(SOAPFault)
+				if err := dec.DecodeElement(fault, &se); err != nil {
+					return err
+				}
+				return fault
+			}
+			if data == nil {
+				return nil
+			}
+			return dec.DecodeElement(data, &se)
+
+		case xml.EndElement:
+			if inBody {
+				// an empty body
+				return nil
+			}
+		}
+	}
+}
+
+// PushSOAPToRemote posts body to uri in a SOAP 1.1 envelope, with the SOAPAction header set to action,
+// and decodes the body of the response envelope into response with ParseSOAPResponse. It returns the
+// response status code, and a *SOAPFault if the service returned a fault. The final parameter, client, is
+// optional, as for PushJSONToRemote.
+func (t *Tools) PushSOAPToRemote(uri, action string, body, response any, client ...*http.Client) (int, error) {
+	envelope, err := t.BuildSOAPEnvelope(body)
+	if err != nil {
+		return 0, err
+	}
+
+	opts := clientOptions(client)
+	opts.Headers = http.Header{}
+	// SOAP 1.1 requires the action to be quoted, even when it is empty.
+	opts.Headers.Set("SOAPAction", `"`+action+`"`)
+
+	res, err := t.sendToRemote(context.Background(), uri, bytes.NewReader(envelope), "text/xml; charset=utf-8", opts)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode, t.ParseSOAPResponse(res.Body, response)
+}
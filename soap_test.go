@@ -0,0 +1,162 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type soapGetPrice struct {
+	XMLName xml.Name `xml:"http://example.com/prices GetPrice"`
+	Item    string   `xml:"Item"`
+}
+
+type soapGetPriceResponse struct {
+	Price float64 `xml:"Price"`
+}
+
+type soapAuth struct {
+	XMLName xml.Name `xml:"http://example.com/auth Auth"`
+	Token   string   `xml:"Token"`
+}
+
+func TestTools_BuildSOAPEnvelope(t *testing.T) {
+	var testTools Tools
+
+	out, err := testTools.BuildSOAPEnvelope(soapGetPrice{Item: "Apples"}, soapAuth{Token: "t<1>"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := xml.Header +
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">` +
+		`<soap:Header><Auth xmlns="http://example.com/auth"><Token>t&lt;1&gt;</Token></Auth></soap:Header>` +
+		`<soap:Body><GetPrice xmlns="http://example.com/prices"><Item>Apples</Item></GetPrice></soap:Body>` +
+		`</soap:Envelope>`
+	if string(out) != expected {
+		t.Errorf("wrong envelope;\nexpected %s\n but got %s", expected, out)
+	}
+}
+
+var parseSOAPResponseTests = []struct {
+	name          string
+	body          string
+	expected      float64
+	fault         *SOAPFault
+	errorExpected bool
+}{
+	{name: "response", body: `<?xml version="1.0"?><soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Header><x>1</x></soap:Header>` +
+		`<soap:Body><m:GetPriceResponse xmlns:m="http://example.com/prices"><m:Price>1.9</m:Price></m:GetPriceResponse></soap:Body></soap:Envelope>`, expected: 1.9},
+	{name: "fault", body: `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultcode>s:Client</faultcode>` +
+		`<faultstring>Unknown item</faultstring><detail><code>42</code></detail></s:Fault></s:Body></s:Envelope>`,
+		fault: &SOAPFault{Code: "s:Client", String: "Unknown item", Detail: SOAPFaultDetail{Content: "<code>42</code>"}}},
+	{name: "empty body", body: `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body></s:Body></s:Envelope>`},
+	{name: "not soap", body: `<html><p>Bad gateway</p></html>`, errorExpected: true},
+	{name: "badly formed", body: `<s:Envelope><s:Body><a></b></s:Body></s:Envelope>`, errorExpected: true},
+}
+
+func TestTools_ParseSOAPResponse(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range parseSOAPResponseTests {
+		var res soapGetPriceResponse
+		err := testTools.ParseSOAPResponse(strings.NewReader(e.body), &res)
+
+		var fault *SOAPFault
+		switch {
+		case e.fault != nil:
+			if !errors.As(err, &fault) {
+				t.Errorf("%s: expected a fault but got %v", e.name, err)
+			} else if *fault != *e.fault {
+				t.Errorf("%s: expected fault %+v but got %+v", e.name, *e.fault, *fault)
+			}
+		case e.errorExpected:
+			if err == nil {
+				t.Errorf("%s: expected error but got none", e.name)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		case res.Price != e.expected:
+			t.Errorf("%s: expected price %v but got %v", e.name, e.expected, res.Price)
+		}
+	}
+}
+
+func TestTools_ParseSOAPResponseTooLarge(t *testing.T) {
+	testTools := Tools{MaxXMLSize: 10}
+	err := testTools.ParseSOAPResponse(strings.NewReader(parseSOAPResponseTests[0].body), nil)
+	if err == nil || err.Error() != "response must not be larger than 10 bytes" {
+		t.Errorf("expected size error but got %v", err)
+	}
+}
+
+func TestTools_PushSOAPToRemote(t *testing.T) {
+	var request *http.Request
+	var requestBody []byte
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		request = req
+		requestBody, _ = io.ReadAll(req.Body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(parseSOAPResponseTests[0].body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	var testTools Tools
+	var res soapGetPriceResponse
+	status, err := testTools.PushSOAPToRemote("http://example.com/soap", "http://example.com/prices/GetPrice", soapGetPrice{Item: "Apples"}, &res, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if status != http.StatusOK {
+		t.Errorf("expected status 200 but got %d", status)
+	}
+	if res.Price != 1.9 {
+		t.Errorf("expected price 1.9 but got %v", res.Price)
+	}
+	if got := request.Header.Get("SOAPAction"); got != `"http://example.com/prices/GetPrice"` {
+		t.Errorf("wrong SOAPAction header %s", got)
+	}
+	if got := request.Header.Get("Content-Type"); got != "text/xml; charset=utf-8" {
+		t.Errorf("wrong content type %s", got)
+	}
+	if !bytes.Contains(requestBody, []byte(`<soap:Body><GetPrice xmlns="http://example.com/prices"><Item>Apples</Item></GetPrice></soap:Body>`)) {
+		t.Errorf("request body does not contain the payload: %s", requestBody)
+	}
+}
+
+func TestTools_PushXMLToRemote(t *testing.T) {
+	var requestBody []byte
+	var contentType string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		contentType = req.Header.Get("Content-Type")
+		requestBody, _ = io.ReadAll(req.Body)
+		return &http.Response{
+			StatusCode: http.StatusAccepted,
+			Body:       io.NopCloser(bytes.NewBufferString("ok")),
+			Header:     make(http.Header),
+		}
+	})
+
+	var testTools Tools
+	_, status, err := testTools.PushXMLToRemote("http://example.com/some/path", map[string]string{"name": "Ann"}, client)
+	if err != nil {
+		t.Fatalf("failed to call remote url: %s", err)
+	}
+
+	if status != http.StatusAccepted {
+		t.Errorf("expected status 202 but got %d", status)
+	}
+	if contentType != "application/xml" {
+		t.Errorf("wrong content type %s", contentType)
+	}
+	if expected := xml.Header + `<response><name>Ann</name></response>`; string(requestBody) != expected {
+		t.Errorf("wrong body;\nexpected %s\n but got %s", expected, requestBody)
+	}
+}
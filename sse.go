@@ -0,0 +1,132 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSEStream writes Server-Sent Events to a client, for pushing progress and status updates without
+// polling. Events may be sent from several goroutines.
+type SSEStream struct {
+	tools  *Tools
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+}
+
+// NewSSEStream starts an event stream on w, sending the text/event-stream headers straight away. Any write
+// deadline set by the server is cleared, since streams are expected to stay open. The stream ends when the
+// client disconnects (the request context is done) or Close is called; after that, SendEvent returns an
+// error. An error is returned if w can't be flushed.
+func (t *Tools) NewSSEStream(w http.ResponseWriter, r *http.Request) (*SSEStream, error) {
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	return &SSEStream{tools: t, w: w, rc: rc, ctx: ctx, cancel: cancel}, nil
+}
+
+// SendEvent sends data, encoded as JSON, to the client and flushes it. The id and event fields are only
+// sent if they aren't empty; clients use the id to resume with the Last-Event-ID header after
+// reconnecting, and the event name to pick a listener.
+func (s *SSEStream) SendEvent(id, event string, data any) error {
+	buf, err := s.tools.encodeJSON(data)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(buf)
+	b := buf.Bytes()
+
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: " + sseField(id) + "\n")
+	}
+	if event != "" {
+		sb.WriteString("event: " + sseField(event) + "\n")
+	}
+	sb.WriteString("data: ")
+	sb.Write(b)
+	sb.WriteString("\n\n")
+
+	return s.write(sb.String())
+}
+
+// SendRetry tells the client how long to wait before reconnecting if the stream is lost.
+func (s *SSEStream) SendRetry(d time.Duration) error {
+	return s.write("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n")
+}
+
+// Heartbeat sends a comment every interval until the stream ends, so proxies don't close an idle
+// connection and a disconnected client is noticed even when there are no events to send.
+func (s *SSEStream) Heartbeat(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.write(": ping\n\n"); err != nil {
+					s.cancel()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Done returns a channel which is closed when the client disconnects or the stream is closed.
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Close ends the stream, waiting for any event being written to finish. Handlers should defer a call to
+// Close, so nothing is written after they return.
+func (s *SSEStream) Close() {
+	s.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+}
+
+// errSSEClosed is returned when writing to a stream which has ended.
+var errSSEClosed = errors.New("event stream is closed")
+
+// write sends raw event text and flushes it.
+func (s *SSEStream) write(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return errSSEClosed
+	}
+	if _, err := s.w.Write([]byte(text)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// sseField removes line breaks, which would end a field early.
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,104 @@
+package toolkit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTools_NewSSEStream(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+
+	stream, err := testTools.NewSSEStream(rr, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("wrong content type; got %q", rr.Header().Get("Content-Type"))
+	}
+	if !rr.Flushed {
+		t.Error("expected headers to be flushed")
+	}
+
+	if err := stream.SendRetry(3 * time.Second); err != nil {
+		t.Error(err)
+	}
+	if err := stream.SendEvent("1", "progress", map[string]int{"percent": 50}); err != nil {
+		t.Error(err)
+	}
+	if err := stream.SendEvent("", "", "done\nnow"); err != nil {
+		t.Error(err)
+	}
+	if err := stream.SendEvent("2\n", "status\r\n", nil); err != nil {
+		t.Error(err)
+	}
+
+	expected := "retry: 3000\n\n" +
+		"id: 1\nevent: progress\ndata: {\"percent\":50}\n\n" +
+		"data: \"done\\nnow\"\n\n" +
+		"id: 2\nevent: status\ndata: null\n\n"
+	if rr.Body.String() != expected {
+		t.Errorf("wrong body; expected %q but got %q", expected, rr.Body.String())
+	}
+
+	stream.Close()
+	select {
+	case <-stream.Done():
+	default:
+		t.Error("expected Done to be closed after Close")
+	}
+	if err := stream.SendEvent("3", "", 1); err == nil {
+		t.Error("expected error sending to a closed stream")
+	}
+}
+
+func TestSSEStream_HeartbeatAndDisconnect(t *testing.T) {
+	var testTools Tools
+
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, err := testTools.NewSSEStream(w, r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer stream.Close()
+
+		stream.Heartbeat(10 * time.Millisecond)
+		<-stream.Done()
+		received <- "disconnected"
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+	n, err := resp.Body.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(buf[:n]), ": ping") {
+		t.Errorf("expected heartbeat comment, got %q", buf[:n])
+	}
+
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Error("handler did not notice the client disconnecting")
+	}
+}
@@ -0,0 +1,251 @@
+package toolkit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultStaticMaxAge how long browsers may cache fingerprinted assets
+const defaultStaticMaxAge = 365 * 24 * time.Hour
+
+// fingerprintLength the number of hex characters of the content hash put in fingerprinted names
+const fingerprintLength = 12
+
+// StaticOptions configures ServeStatic.
+type StaticOptions struct {
+	MaxAge          time.Duration // Cache-Control max-age for fingerprinted assets; defaults to a year
+	ListDirectories bool          // list the contents of directories without an index.html; off by default
+}
+
+// StaticHandler serves static assets from an fs.FS. Use URL to link to assets with a fingerprint in the
+// name, so they can be cached forever and still change when they're redeployed.
+type StaticHandler struct {
+	prefix string
+	root   fs.FS
+	opts   StaticOptions
+	mu     sync.RWMutex
+	hashes map[string]string
+}
+
+// ServeStatic returns a handler which serves the files in root, such as an embed.FS or os.DirFS, under
+// the URL path prefix (e.g. "/static/"). Range and conditional requests are supported.
+//
+// Requests for a fingerprinted name, as returned by URL, are served with a far-future, immutable
+// Cache-Control header; other requests must be revalidated, using the ETag. If the client accepts brotli
+// or gzip, and root contains a precompressed variant of the file (app.css.br or app.css.gz), the variant is
+// served instead. Directory listings are disabled unless ListDirectories is set.
+func (t *Tools) ServeStatic(prefix string, root fs.FS, opts ...StaticOptions) *StaticHandler {
+	h := &StaticHandler{
+		prefix: strings.TrimSuffix(prefix, "/") + "/",
+		root:   root,
+		hashes: make(map[string]string),
+	}
+	if len(opts) > 0 {
+		h.opts = opts[0]
+	}
+	if h.opts.MaxAge <= 0 {
+		h.opts.MaxAge = defaultStaticMaxAge
+	}
+	return h
+}
+
+// URL returns the URL path of the asset name, with a hash of its content inserted before the extension
+// (e.g. "/static/css/app.3f2a9c1b04de.css"). Hashes are computed once, and remembered.
+func (h *StaticHandler) URL(name string) (string, error) {
+	name = strings.TrimPrefix(name, "/")
+
+	hash, err := h.fingerprint(name)
+	if err != nil {
+		return "", err
+	}
+
+	ext := path.Ext(name)
+	return h.prefix + strings.TrimSuffix(name, ext) + "." + hash + ext, nil
+}
+
+// fingerprint returns the fingerprint of the file name, computing it the first time it is needed.
+func (h *StaticHandler) fingerprint(name string) (string, error) {
+	h.mu.RLock()
+	hash, ok := h.hashes[name]
+	h.mu.RUnlock()
+	if ok {
+		return hash, nil
+	}
+
+	hash, err := h.hash(name)
+	if err != nil {
+		return "", err
+	}
+	h.mu.Lock()
+	h.hashes[name] = hash
+	h.mu.Unlock()
+	return hash, nil
+}
+
+// hash computes the fingerprint of the file name.
+func (h *StaticHandler) hash(name string) (string, error) {
+	f, err := h.root.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil))[:fingerprintLength], nil
+}
+
+// ServeHTTP serves the asset named by the request path.
+func (h *StaticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// the prefix ends in a slash, so "/static/" doesn't match "/statics/"; "/static" is its root
+	name, ok := strings.CutPrefix(r.URL.Path, h.prefix)
+	if !ok && r.URL.Path+"/" != h.prefix {
+		http.NotFound(w, r)
+		return
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(h.root, name)
+	immutable := false
+	if err != nil {
+		// The name may be fingerprinted; serve the current file if the fingerprint still matches it.
+		original, hash, found := splitFingerprint(name)
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		if current, err := h.fingerprint(original); err != nil || current != hash {
+			http.NotFound(w, r)
+			return
+		}
+		name, immutable = original, true
+		if info, err = fs.Stat(h.root, name); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	if info.IsDir() {
+		index := path.Join(name, "index.html")
+		if _, err := fs.Stat(h.root, index); err == nil {
+			name = index
+		} else if h.opts.ListDirectories {
+			http.StripPrefix(strings.TrimSuffix(h.prefix, "/"), http.FileServerFS(h.root)).ServeHTTP(w, r)
+			return
+		} else {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	if immutable {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(h.opts.MaxAge.Seconds()))+", immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	h.serveFile(w, r, name)
+}
+
+// serveFile sends the file name, or a precompressed variant of it if the client accepts one.
+func (h *StaticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	served := name
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	for _, v := range []struct{ encoding, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+		if !acceptsEncoding(r, v.encoding) {
+			continue
+		}
+		if info, err := fs.Stat(h.root, name+v.ext); err == nil && !info.IsDir() {
+			served = name + v.ext
+			w.Header().Set("Content-Encoding", v.encoding)
+			break
+		}
+	}
+
+	f, err := h.root.Open(served)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if etag, err := fsFileETag(h.root, served, info); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// splitFingerprint removes the fingerprint from a name returned by URL, returning the original name and
+// the fingerprint.
+func splitFingerprint(name string) (string, string, bool) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	dot := strings.LastIndexByte(base, '.')
+	if dot < 0 || len(base)-dot-1 != fingerprintLength {
+		return "", "", false
+	}
+	hash := base[dot+1:]
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", "", false
+	}
+	return base[:dot] + ext, hash, true
+}
+
+// acceptsEncoding reports whether the request's Accept-Encoding header allows encoding.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(q, 64); err == nil && f == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package toolkit
+
+import (
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+//go:embed testdata/pic.jpg testdata/img.png
+var testAssets embed.FS
+
+func TestTools_ServeStatic(t *testing.T) {
+	var testTools Tools
+	handler := testTools.ServeStatic("/static/", testAssets)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/static/testdata/pic.jpg", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("wrong status; expected %d but got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Header().Get("Content-Type") != "image/jpeg" || rr.Body.Len() != 98827 {
+		t.Errorf("wrong file served; got %s of %d bytes", rr.Header().Get("Content-Type"), rr.Body.Len())
+	}
+	if rr.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("expected unfingerprinted asset to be revalidated, got %q", rr.Header().Get("Cache-Control"))
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/static/testdata/missing.jpg", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("wrong status for missing file; expected %d but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+var staticFS = fstest.MapFS{
+	"css/app.css":        {Data: []byte("body { color: red }")},
+	"css/app.css.gz":     {Data: []byte("gzipped")},
+	"css/app.css.br":     {Data: []byte("brotli")},
+	"js/app.js":          {Data: []byte("console.log(1)")},
+	"docs/index.html":    {Data: []byte("<h1>docs</h1>")},
+	"img/logo.png":       {Data: []byte("png")},
+	"v/lib.0123456789ab": {Data: []byte("odd name")},
+}
+
+var serveStaticTests = []struct {
+	name             string
+	path             string
+	method           string
+	acceptEncoding   string
+	listDirectories  bool
+	expectedStatus   int
+	expectedBody     string
+	expectedEncoding string
+	expectedCache    string
+}{
+	{name: "plain", path: "/assets/js/app.js", expectedStatus: http.StatusOK, expectedBody: "console.log(1)", expectedCache: "no-cache"},
+	{name: "fingerprinted", path: "/assets/js/app.0a286891c11c.js", expectedStatus: http.StatusOK, expectedBody: "console.log(1)", expectedCache: "public, max-age=3600, immutable"},
+	{name: "stale fingerprint", path: "/assets/js/app.000000000000.js", expectedStatus: http.StatusNotFound},
+	{name: "brotli preferred", path: "/assets/css/app.css", acceptEncoding: "gzip, br", expectedStatus: http.StatusOK, expectedBody: "brotli", expectedEncoding: "br", expectedCache: "no-cache"},
+	{name: "gzip", path: "/assets/css/app.css", acceptEncoding: "gzip, br;q=0", expectedStatus: http.StatusOK, expectedBody: "gzipped", expectedEncoding: "gzip", expectedCache: "no-cache"},
+	{name: "identity", path: "/assets/css/app.css", expectedStatus: http.StatusOK, expectedBody: "body { color: red }", expectedCache: "no-cache"},
+	{name: "no variant", path: "/assets/js/app.js", acceptEncoding: "br", expectedStatus: http.StatusOK, expectedBody: "console.log(1)", expectedCache: "no-cache"},
+	{name: "directory index", path: "/assets/docs/", expectedStatus: http.StatusOK, expectedBody: "<h1>docs</h1>", expectedCache: "no-cache"},
+	{name: "listing disabled", path: "/assets/img/", expectedStatus: http.StatusNotFound},
+	{name: "listing enabled", path: "/assets/img/", listDirectories: true, expectedStatus: http.StatusOK},
+	{name: "literal hex name", path: "/assets/v/lib.0123456789ab", expectedStatus: http.StatusOK, expectedBody: "odd name", expectedCache: "no-cache"},
+	{name: "traversal", path: "/assets/../static.go", expectedStatus: http.StatusNotFound},
+	{name: "prefix boundary", path: "/assetsfoo/js/app.js", expectedStatus: http.StatusNotFound},
+	{name: "wrong method", path: "/assets/js/app.js", method: http.MethodPost, expectedStatus: http.StatusMethodNotAllowed},
+}
+
+func TestStaticHandler(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range serveStaticTests {
+		handler := testTools.ServeStatic("/assets", staticFS, StaticOptions{MaxAge: time.Hour, ListDirectories: e.listDirectories})
+
+		method := e.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req := httptest.NewRequest(method, "/", nil)
+		req.URL.Path = e.path
+		if e.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", e.acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+			continue
+		}
+		if e.expectedBody != "" && rr.Body.String() != e.expectedBody {
+			t.Errorf("%s: wrong body; expected %q but got %q", e.name, e.expectedBody, rr.Body.String())
+		}
+		if rr.Header().Get("Content-Encoding") != e.expectedEncoding {
+			t.Errorf("%s: wrong Content-Encoding; expected %q but got %q", e.name, e.expectedEncoding, rr.Header().Get("Content-Encoding"))
+		}
+		if rr.Header().Get("Cache-Control") != e.expectedCache {
+			t.Errorf("%s: wrong Cache-Control; expected %q but got %q", e.name, e.expectedCache, rr.Header().Get("Cache-Control"))
+		}
+		if e.expectedEncoding != "" && rr.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+			t.Errorf("%s: wrong Content-Type for compressed variant %q", e.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestStaticHandler_URL(t *testing.T) {
+	var testTools Tools
+	handler := testTools.ServeStatic("/assets/", staticFS)
+
+	url, err := handler.URL("js/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "/assets/js/app.0a286891c11c.js" {
+		t.Errorf("wrong URL %q", url)
+	}
+
+	if _, err := handler.URL("js/missing.js"); err == nil {
+		t.Error("expected error for missing asset")
+	}
+}
+
+func TestStaticHandler_FingerprintCache(t *testing.T) {
+	var testTools Tools
+	root := fstest.MapFS{"js/app.js": {Data: []byte("console.log(1)")}}
+	handler := testTools.ServeStatic("/assets/", root)
+
+	url, err := handler.URL("js/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fingerprints are remembered rather than computed for each request, so a file changed without a
+	// redeploy is still served under the fingerprint URL returned
+	root["js/app.js"] = &fstest.MapFile{Data: []byte("console.log(2)")}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "console.log(2)" {
+		t.Errorf("expected the remembered fingerprint to be used, got %d %q", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,137 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// tenantKey is the context key for the tenant resolved by TenantResolver.
+const tenantKey contextKey = "tenant"
+
+// validTenantID matches the tenant IDs TenantResolver accepts; they are used as directory names, so they
+// are kept to a safe set of characters.
+var validTenantID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,62}$`)
+
+// Tenant is the tenant a request is for, as resolved by TenantResolver.
+type Tenant struct {
+	ID   string // identifies the tenant; used to scope upload directories and rate limits
+	Data any    // anything else the lookup loaded, e.g. the tenant's settings
+}
+
+// TenantLookup loads the tenant with the given ID, or returns nil if there is no such tenant.
+type TenantLookup func(ctx context.Context, id string) (*Tenant, error)
+
+// TenantOptions configures the TenantResolver middleware. The sources are tried in the order listed, and
+// the first to give an ID is used.
+type TenantOptions struct {
+	Header     string       // if set, the tenant ID may be sent in this request header
+	BaseDomain string       // if set, the tenant ID may be the subdomain of this domain, e.g. "acme" in acme.example.com
+	PathPrefix bool         // if set to true, the tenant ID may be the first element of the path, which is then removed from it
+	Lookup     TenantLookup // loads the tenant; if nil, any valid ID is accepted
+}
+
+// TenantResolver returns middleware which works out the tenant a request is for, loads it with Lookup, and
+// puts it in the request context, where TenantFromContext returns it. Within the request, UploadFiles and
+// ServeUpload use a subdirectory of the upload directory named after the tenant, and RateLimit keeps
+// separate limits for each tenant. Requests without a tenant ID, or with an invalid one, get 400 Bad
+// Request, and those for an unknown tenant 404 Not Found, through ErrorJSON.
+func (t *Tools) TenantResolver(opts TenantOptions) Middleware {
+	baseDomain := "." + strings.ToLower(strings.Trim(opts.BaseDomain, "."))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var id string
+			stripPath := false
+
+			if opts.Header != "" {
+				id = r.Header.Get(opts.Header)
+			}
+			if id == "" && opts.BaseDomain != "" {
+				host := r.Host
+				if h, _, err := net.SplitHostPort(host); err == nil {
+					host = h
+				}
+				if sub, ok := strings.CutSuffix(strings.ToLower(host), baseDomain); ok && !strings.Contains(sub, ".") {
+					id = sub
+				}
+			}
+			if id == "" && opts.PathPrefix {
+				id, _, _ = strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+				stripPath = true
+			}
+
+			if id == "" {
+				_ = t.ErrorJSON(w, errors.New("tenant required"), http.StatusBadRequest)
+				return
+			}
+			if !validTenantID.MatchString(id) {
+				_ = t.ErrorJSON(w, errors.New("invalid tenant"), http.StatusBadRequest)
+				return
+			}
+
+			tenant := &Tenant{ID: id}
+			if opts.Lookup != nil {
+				var err error
+				tenant, err = opts.Lookup(r.Context(), id)
+				if err != nil {
+					t.LogError(r.Context(), "tenant lookup error", "tenant", id, "error", err)
+					_ = t.ErrorJSON(w, errors.New(http.StatusText(http.StatusInternalServerError)), http.StatusInternalServerError)
+					return
+				}
+				if tenant == nil {
+					_ = t.ErrorJSON(w, errors.New("unknown tenant"), http.StatusNotFound)
+					return
+				}
+				if tenant.ID == "" {
+					tenant.ID = id
+				}
+			}
+
+			if stripPath {
+				r = stripTenantPrefix(r, id)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey, tenant)))
+		})
+	}
+}
+
+// stripTenantPrefix returns a copy of r with the tenant ID removed from the start of its path.
+func stripTenantPrefix(r *http.Request, id string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+
+	prefix := "/" + id
+	r2.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+	if r2.URL.Path == "" {
+		r2.URL.Path = "/"
+	}
+	if r.URL.RawPath != "" {
+		r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+		if r2.URL.RawPath == "" {
+			r2.URL.RawPath = "/"
+		}
+	}
+	return r2
+}
+
+// TenantFromContext returns the tenant resolved by TenantResolver, or nil if there isn't one.
+func TenantFromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantKey).(*Tenant)
+	return tenant
+}
+
+// tenantDir returns dir, or the tenant's subdirectory of it if ctx has a tenant.
+func tenantDir(ctx context.Context, dir string) string {
+	if tenant := TenantFromContext(ctx); tenant != nil && validTenantID.MatchString(tenant.ID) {
+		return filepath.Join(dir, tenant.ID)
+	}
+	return dir
+}
@@ -0,0 +1,152 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTools_TenantResolver(t *testing.T) {
+	var testTools Tools
+	var gotTenant *Tenant
+	var gotPath string
+	handler := testTools.TenantResolver(TenantOptions{
+		Header:     "X-Tenant",
+		BaseDomain: "example.com",
+		PathPrefix: true,
+		Lookup: func(ctx context.Context, id string) (*Tenant, error) {
+			switch id {
+			case "broken":
+				return nil, errors.New("database down")
+			case "acme", "globex":
+				return &Tenant{ID: id, Data: "plan:" + id}, nil
+			}
+			return nil, nil
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = TenantFromContext(r.Context())
+		gotPath = r.URL.Path
+	}))
+
+	tests := []struct {
+		name           string
+		host           string
+		path           string
+		header         string
+		expectedStatus int
+		expectedID     string
+		expectedPath   string
+	}{
+		{name: "header", host: "example.com", path: "/files", header: "acme", expectedStatus: http.StatusOK, expectedID: "acme", expectedPath: "/files"},
+		{name: "subdomain", host: "globex.example.com:8080", path: "/files", expectedStatus: http.StatusOK, expectedID: "globex", expectedPath: "/files"},
+		{name: "subdomain case", host: "ACME.Example.com", path: "/", expectedStatus: http.StatusOK, expectedID: "acme", expectedPath: "/"},
+		{name: "path prefix", host: "example.com", path: "/acme/files/1", expectedStatus: http.StatusOK, expectedID: "acme", expectedPath: "/files/1"},
+		{name: "path prefix only", host: "example.com", path: "/acme", expectedStatus: http.StatusOK, expectedID: "acme", expectedPath: "/"},
+		{name: "header first", host: "globex.example.com", path: "/", header: "acme", expectedStatus: http.StatusOK, expectedID: "acme", expectedPath: "/"},
+		{name: "nested subdomain", host: "a.b.example.com", path: "/", expectedStatus: http.StatusBadRequest},
+		{name: "missing", host: "example.com", path: "/", expectedStatus: http.StatusBadRequest},
+		{name: "invalid", host: "example.com", path: "/", header: "../etc", expectedStatus: http.StatusBadRequest},
+		{name: "unknown", host: "example.com", path: "/initech/files", expectedStatus: http.StatusNotFound},
+		{name: "lookup error", host: "example.com", path: "/", header: "broken", expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, e := range tests {
+		gotTenant, gotPath = nil, ""
+		req := httptest.NewRequest(http.MethodGet, e.path, nil)
+		req.Host = e.host
+		if e.header != "" {
+			req.Header.Set("X-Tenant", e.header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.expectedStatus, rr.Code)
+			continue
+		}
+		if e.expectedStatus != http.StatusOK {
+			if gotTenant != nil {
+				t.Errorf("%s: handler should not have been called", e.name)
+			}
+			continue
+		}
+		if gotTenant == nil || gotTenant.ID != e.expectedID || gotTenant.Data != "plan:"+e.expectedID {
+			t.Errorf("%s: wrong tenant %+v", e.name, gotTenant)
+		}
+		if gotPath != e.expectedPath {
+			t.Errorf("%s: expected path %q but got %q", e.name, e.expectedPath, gotPath)
+		}
+	}
+}
+
+func TestTools_TenantResolver_NoLookup(t *testing.T) {
+	var testTools Tools
+	var gotTenant *Tenant
+	handler := testTools.TenantResolver(TenantOptions{Header: "X-Tenant"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = TenantFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant", "anyone")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || gotTenant == nil || gotTenant.ID != "anyone" {
+		t.Errorf("expected tenant anyone, got status %d and %+v", rr.Code, gotTenant)
+	}
+}
+
+func TestTools_UploadFiles_Tenant(t *testing.T) {
+	dir := t.TempDir()
+	var testTools Tools
+	ctx := context.WithValue(context.Background(), tenantKey, &Tenant{ID: "acme"})
+
+	file, err := uploadTestImage(t, ctx, &testTools, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "acme", file.NewFileName)); err != nil {
+		t.Fatalf("expected upload in the tenant's directory: %s", err)
+	}
+
+	// the file is served to the same tenant, but not to another
+	for tenant, expected := range map[string]int{"acme": http.StatusOK, "globex": http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), tenantKey, &Tenant{ID: tenant}))
+		rr := httptest.NewRecorder()
+		testTools.ServeUpload(rr, req, ServeUploadOptions{Dir: dir, Name: file.NewFileName})
+		if rr.Code != expected {
+			t.Errorf("tenant %s: expected status %d but got %d", tenant, expected, rr.Code)
+		}
+	}
+}
+
+func TestTools_RateLimit_Tenant(t *testing.T) {
+	var testTools Tools
+	handler := testTools.TenantResolver(TenantOptions{Header: "X-Tenant"})(
+		testTools.RateLimit(RateLimitOptions{Rate: 1, Burst: 1})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})),
+	)
+
+	tests := []struct {
+		tenant         string
+		expectedStatus int
+	}{
+		{"acme", http.StatusOK},
+		{"acme", http.StatusTooManyRequests},
+		{"globex", http.StatusOK},
+	}
+	for i, e := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Tenant", e.tenant)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != e.expectedStatus {
+			t.Errorf("request %d for %s: expected status %d but got %d", i+1, e.tenant, e.expectedStatus, rr.Code)
+		}
+	}
+}
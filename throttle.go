@@ -0,0 +1,225 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrThrottled is wrapped by the error returned for a call which couldn't be made within a throttled
+// transport's limits, because it waited longer than QueueTimeout or the queue was full.
+var ErrThrottled = errors.New("call throttled")
+
+// HostLimit limits the calls made to a host by a throttled transport. Zero values mean no limit.
+type HostLimit struct {
+	Rate        float64 // calls started per second, on average
+	Burst       int     // calls which may be started at once, within Rate; defaults to 1
+	Concurrency int     // calls in flight at once, until their response bodies are closed
+	MaxQueue    int     // calls which may wait for the limits; further calls fail straight away
+}
+
+// ThrottleOptions configures NewThrottledTransport.
+type ThrottleOptions struct {
+	Default      HostLimit            // limits for hosts not in Hosts
+	Hosts        map[string]HostLimit // limits by host, such as "api.partner.com", or with the port, "localhost:8080"
+	QueueTimeout time.Duration        // longest a call waits for the limits; 0 means until its context is done
+	Store        RateLimitStore       // where the rate limits' buckets are kept; defaults to a new MemoryRateLimitStore
+}
+
+// throttledTransport is the http.RoundTripper returned by NewThrottledTransport.
+type throttledTransport struct {
+	tools *Tools
+	base  http.RoundTripper
+	opts  ThrottleOptions
+	mu    sync.Mutex
+	hosts map[string]*hostThrottle
+}
+
+// hostThrottle is the state of the limits for one host.
+type hostThrottle struct {
+	limit   HostLimit
+	slots   chan struct{} // holds a value for each call in flight; nil if Concurrency isn't limited
+	mu      sync.Mutex
+	waiting int
+}
+
+// NewThrottledTransport returns a transport which makes calls with base (http.DefaultTransport if nil)
+// within limits for each host, so a burst of calls, such as webhook deliveries, doesn't trip a partner's
+// rate limits. Calls over the limits wait in a queue, until the limits allow them, QueueTimeout passes or
+// their context is done. Use the transport in the client set as HTTPClient to throttle the remote helpers.
+// Rate limits are token buckets kept in Store, which may be shared between instances, such as
+// toolkitredis's; if the store fails, the error is logged and the call is made.
+func (t *Tools) NewThrottledTransport(base http.RoundTripper, opts ThrottleOptions) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryRateLimitStore()
+	}
+	return &throttledTransport{tools: t, base: base, opts: opts, hosts: make(map[string]*hostThrottle)}
+}
+
+// host returns the state of the limits for the host of a call, creating it if needed.
+func (tt *throttledTransport) host(host string) *hostThrottle {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	h, ok := tt.hosts[host]
+	if !ok {
+		limit := tt.opts.Default
+		if l, ok := tt.opts.Hosts[host]; ok {
+			limit = l
+		}
+		if limit.Burst <= 0 {
+			limit.Burst = 1
+		}
+		h = &hostThrottle{limit: limit}
+		if limit.Concurrency > 0 {
+			h.slots = make(chan struct{}, limit.Concurrency)
+		}
+		tt.hosts[host] = h
+	}
+	return h
+}
+
+// RoundTrip waits for the host's limits to allow the call, then makes it.
+func (tt *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if _, ok := tt.opts.Hosts[host]; !ok {
+		host = req.URL.Hostname()
+	}
+	h := tt.host(host)
+
+	release, err := tt.wait(req.Context(), host, h)
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	response, err := tt.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	response.Body = &releaseOnClose{ReadCloser: response.Body, release: release}
+	return response, nil
+}
+
+// wait queues a call until the limits allow it, and returns a func which frees its concurrency slot.
+func (tt *throttledTransport) wait(ctx context.Context, host string, h *hostThrottle) (func(), error) {
+	if h.slots == nil && h.limit.Rate <= 0 {
+		return func() {}, nil
+	}
+
+	waitCtx := ctx
+	if tt.opts.QueueTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, tt.opts.QueueTimeout)
+		defer cancel()
+	}
+	// only calls which have to wait count towards MaxQueue
+	queued := false
+	defer func() {
+		if queued {
+			h.dequeue()
+		}
+	}()
+	queue := func() error {
+		if queued {
+			return nil
+		}
+		if !h.enqueue() {
+			return fmt.Errorf("%w: too many calls to %s waiting", ErrThrottled, host)
+		}
+		queued = true
+		return nil
+	}
+	timedOut := func() error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: waited %s for %s", ErrThrottled, tt.opts.QueueTimeout, host)
+	}
+
+	release := func() {}
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+		default:
+			if err := queue(); err != nil {
+				return nil, err
+			}
+			select {
+			case h.slots <- struct{}{}:
+			case <-waitCtx.Done():
+				return nil, timedOut()
+			}
+		}
+		var once sync.Once
+		release = func() { once.Do(func() { <-h.slots }) }
+	}
+
+	for h.limit.Rate > 0 {
+		ok, retryAfter, err := tt.opts.Store.Allow(ctx, "throttle:"+host, h.limit.Rate, h.limit.Burst)
+		if err != nil {
+			tt.tools.LogWarn(ctx, "throttle store failed, allowing call", "host", host, "error", err)
+			break
+		}
+		if ok {
+			break
+		}
+		if err := queue(); err != nil {
+			release()
+			return nil, err
+		}
+
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-timer.C:
+		case <-waitCtx.Done():
+			timer.Stop()
+			release()
+			return nil, timedOut()
+		}
+	}
+	return release, nil
+}
+
+// enqueue counts a call waiting for the host's limits, reporting false if MaxQueue calls already are.
+func (h *hostThrottle) enqueue() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.limit.MaxQueue > 0 && h.waiting >= h.limit.MaxQueue {
+		return false
+	}
+	h.waiting++
+	return true
+}
+
+// dequeue stops counting a call which was waiting.
+func (h *hostThrottle) dequeue() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.waiting--
+}
+
+// releaseOnClose frees a call's concurrency slot once its response body is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close implements io.Closer.
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
@@ -0,0 +1,136 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowTransport answers every call after a delay, recording the most calls in flight at once.
+type slowTransport struct {
+	delay    time.Duration
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	calls    atomic.Int32
+}
+
+func (s *slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.calls.Add(1)
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(s.delay)
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: make(http.Header)}, nil
+}
+
+// callConcurrently makes n GET calls to uri at once with client, closing the response bodies, and returns
+// their errors.
+func callConcurrently(client *http.Client, uri string, n int) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Get(uri)
+			if err == nil {
+				_ = response.Body.Close()
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestTools_NewThrottledTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		uri       string
+		opts      ThrottleOptions
+		calls     int
+		peak      int32 // most calls expected in flight at once
+		throttled int
+		minTime   time.Duration
+	}{
+		{name: "no limits", uri: "http://a.example.com", calls: 5, peak: 5},
+		{name: "concurrency", uri: "http://a.example.com", opts: ThrottleOptions{Default: HostLimit{Concurrency: 2}}, calls: 6, peak: 2},
+		{name: "host limit", uri: "http://b.example.com/hook", opts: ThrottleOptions{Default: HostLimit{Concurrency: 4}, Hosts: map[string]HostLimit{"b.example.com": {Concurrency: 1}}}, calls: 3, peak: 1},
+		{name: "host and port", uri: "http://b.example.com:8080/hook", opts: ThrottleOptions{Hosts: map[string]HostLimit{"b.example.com:8080": {Concurrency: 1}}}, calls: 3, peak: 1},
+		{name: "rate", uri: "http://a.example.com", opts: ThrottleOptions{Default: HostLimit{Rate: 50, Burst: 2}}, calls: 6, peak: 6, minTime: 120 * time.Millisecond},
+		{name: "queue timeout", uri: "http://a.example.com", opts: ThrottleOptions{Default: HostLimit{Concurrency: 1}, QueueTimeout: 30 * time.Millisecond}, calls: 3, peak: 1, throttled: 2},
+		{name: "queue full", uri: "http://a.example.com", opts: ThrottleOptions{Default: HostLimit{Concurrency: 1, MaxQueue: 1}}, calls: 4, peak: 1, throttled: 2},
+	}
+
+	for _, e := range tests {
+		var testTools Tools
+		base := &slowTransport{delay: 50 * time.Millisecond}
+		client := &http.Client{Transport: testTools.NewThrottledTransport(base, e.opts)}
+
+		start := time.Now()
+		errs := callConcurrently(client, e.uri, e.calls)
+		elapsed := time.Since(start)
+
+		throttled := 0
+		for _, err := range errs {
+			switch {
+			case errors.Is(err, ErrThrottled):
+				throttled++
+			case err != nil:
+				t.Errorf("%s: unexpected error: %v", e.name, err)
+			}
+		}
+		if throttled != e.throttled {
+			t.Errorf("%s: expected %d calls throttled, got %d: %v", e.name, e.throttled, throttled, errs)
+		}
+		if got := base.peak.Load(); got > e.peak {
+			t.Errorf("%s: expected at most %d calls in flight, got %d", e.name, e.peak, got)
+		}
+		if got := int(base.calls.Load()); got != e.calls-e.throttled {
+			t.Errorf("%s: expected %d calls made, got %d", e.name, e.calls-e.throttled, got)
+		}
+		if elapsed < e.minTime {
+			t.Errorf("%s: expected the calls to take at least %s, took %s", e.name, e.minTime, elapsed)
+		}
+	}
+}
+
+func TestTools_NewThrottledTransport_Context(t *testing.T) {
+	var testTools Tools
+	base := &slowTransport{delay: 100 * time.Millisecond}
+	client := &http.Client{Transport: testTools.NewThrottledTransport(base, ThrottleOptions{Default: HostLimit{Concurrency: 1}})}
+
+	go callConcurrently(client, "http://a.example.com", 1)
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://a.example.com", nil)
+	_, err := client.Do(request)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrThrottled) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+}
+
+func TestTools_NewThrottledTransport_RemoteHelpers(t *testing.T) {
+	var testTools Tools
+	base := &slowTransport{delay: 10 * time.Millisecond}
+	testTools.HTTPClient = &http.Client{Transport: testTools.NewThrottledTransport(base, ThrottleOptions{Default: HostLimit{Concurrency: 1}})}
+
+	uris := []string{"http://a.example.com/1", "http://a.example.com/2", "http://a.example.com/3"}
+	if _, err := testTools.PushJSONToRemotes(uris, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := base.peak.Load(); got != 1 {
+		t.Errorf("expected the pushes to be made one at a time, got %d at once", got)
+	}
+}
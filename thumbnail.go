@@ -0,0 +1,476 @@
+package toolkit
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultThumbnailCacheSize the default most bytes of thumbnails kept on disk (100 mb)
+const defaultThumbnailCacheSize = 100 << 20
+
+// defaultThumbnailMaxSize the default largest width and height of a thumbnail
+const defaultThumbnailMaxSize = 2000
+
+// defaultThumbnailMaxPixels the default largest image, in pixels, thumbnails are made of
+const defaultThumbnailMaxPixels = 50_000_000
+
+// defaultThumbnailMaxAge how long browsers may cache thumbnails by default
+const defaultThumbnailMaxAge = 7 * 24 * time.Hour
+
+// defaultThumbnailQuality the default quality of JPEG thumbnails
+const defaultThumbnailQuality = 85
+
+// ThumbnailOptions configures ServeThumbnails.
+type ThumbnailOptions struct {
+	Dir       string        // the upload directory holding the originals
+	CacheDir  string        // where thumbnails are kept once made; defaults to toolkit-thumbnails in the temporary directory
+	CacheSize int64         // the most bytes of thumbnails kept in CacheDir, the least recently used being removed first; defaults to 100 mb
+	MaxSize   int           // the largest width or height which may be asked for; defaults to 2000
+	MaxPixels int           // the largest original, in pixels, thumbnails are made of; defaults to 50 megapixels
+	MaxAge    time.Duration // Cache-Control max-age of thumbnails; defaults to a week
+	Quality   int           // the quality of JPEG thumbnails, 1 to 100; defaults to 85
+	Unsigned  bool          // if set to true, serve requests without a signature; anyone can then make the server resize images to any size
+}
+
+// ThumbnailHandler serves thumbnails of uploaded images, resized on demand and cached on disk. Use URL to
+// link to a thumbnail.
+type ThumbnailHandler struct {
+	t      *Tools
+	prefix string
+	opts   ThumbnailOptions
+	cache  *thumbnailCache
+}
+
+// ServeThumbnails returns a handler which serves thumbnails of the JPEG, PNG and GIF images in opts.Dir
+// under the URL path prefix (e.g. "/thumb/"), as in /thumb/photo.jpg?w=200&h=200&fit=cover. The
+// parameters are:
+//
+//   - w and h: the size of the thumbnail, in pixels; either may be left out to keep the aspect ratio
+//   - fit: contain (the default) to fit the image within the size, or cover to fill it, cropping the
+//     middle of the image
+//
+// Images are turned upright according to their EXIF orientation, and never enlarged. JPEGs are served as
+// JPEGs, and other images as PNGs. Requests must be signed with URLSigningKey, as URL does, unless Unsigned is
+// set, so clients can't make the server resize images to arbitrary sizes. Thumbnails are cached in
+// CacheDir, and served with an ETag and a Cache-Control max-age. As with ServeUpload, files for a tenant
+// resolved by TenantResolver are served from its subdirectory of Dir.
+func (t *Tools) ServeThumbnails(prefix string, opts ThumbnailOptions) *ThumbnailHandler {
+	if opts.CacheDir == "" {
+		opts.CacheDir = filepath.Join(os.TempDir(), "toolkit-thumbnails")
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = defaultThumbnailCacheSize
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultThumbnailMaxSize
+	}
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = defaultThumbnailMaxPixels
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = defaultThumbnailMaxAge
+	}
+	if opts.Quality <= 0 || opts.Quality > 100 {
+		opts.Quality = defaultThumbnailQuality
+	}
+
+	return &ThumbnailHandler{
+		t:      t,
+		prefix: strings.TrimSuffix(prefix, "/") + "/",
+		opts:   opts,
+		cache:  newThumbnailCache(opts.CacheDir, opts.CacheSize),
+	}
+}
+
+// URL returns a signed URL of a thumbnail of the stored file name, width by height pixels (either may be
+// 0), fitted as fit ("contain" or "cover"), which is valid for expiry.
+func (h *ThumbnailHandler) URL(name string, width, height int, fit string, expiry time.Duration) (string, error) {
+	q := url.Values{}
+	if width > 0 {
+		q.Set("w", strconv.Itoa(width))
+	}
+	if height > 0 {
+		q.Set("h", strconv.Itoa(height))
+	}
+	if fit != "" {
+		q.Set("fit", fit)
+	}
+	u := (&url.URL{Path: h.prefix + strings.TrimPrefix(name, "/"), RawQuery: q.Encode()}).String()
+	if h.opts.Unsigned {
+		return u, nil
+	}
+	return h.t.SignURL(u, expiry)
+}
+
+// thumbnailSpec is the thumbnail asked for by a request.
+type thumbnailSpec struct {
+	width, height int
+	cover         bool
+}
+
+// parseThumbnailSpec reads the thumbnail parameters of a request.
+func (h *ThumbnailHandler) parseThumbnailSpec(q url.Values) (thumbnailSpec, error) {
+	var spec thumbnailSpec
+	for _, p := range []struct {
+		name string
+		v    *int
+	}{{"w", &spec.width}, {"h", &spec.height}} {
+		s := q.Get(p.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > h.opts.MaxSize {
+			return spec, fmt.Errorf("%s must be a number from 1 to %d", p.name, h.opts.MaxSize)
+		}
+		*p.v = n
+	}
+	if spec.width == 0 && spec.height == 0 {
+		return spec, errors.New("w or h is required")
+	}
+
+	switch q.Get("fit") {
+	case "", "contain":
+	case "cover":
+		spec.cover = true
+	default:
+		return spec, errors.New("fit must be contain or cover")
+	}
+	return spec, nil
+}
+
+// ServeHTTP serves the thumbnail asked for by the request.
+func (h *ThumbnailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.opts.Unsigned {
+		if err := h.t.VerifySignedURL(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	name, ok := strings.CutPrefix(r.URL.Path, h.prefix)
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if !ok || name == "" || strings.HasPrefix(path.Base(name), ".") || strings.HasSuffix(name, uploadMetadataSuffix) {
+		// temporary files and metadata sidecars are never served
+		http.NotFound(w, r)
+		return
+	}
+
+	spec, err := h.parseThumbnailSpec(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fp, err := h.t.EnsureWithinBase(tenantDir(r.Context(), h.opts.Dir), name)
+	if err != nil {
+		http.Error(w, "invalid file path", http.StatusBadRequest)
+		return
+	}
+	info, err := os.Stat(fp)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The key changes whenever the original does, so a replaced file never gets a stale thumbnail.
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%d|%d|%t|%d", fp, info.ModTime().UnixNano(), info.Size(), spec.width, spec.height, spec.cover, h.opts.Quality)))
+	key := hex.EncodeToString(sum[:16])
+
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(h.opts.MaxAge.Seconds())))
+	w.Header().Set("ETag", `"`+key+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	for _, ext := range []string{".jpg", ".png"} {
+		if f, ok := h.cache.open(key + ext); ok {
+			defer f.Close()
+			w.Header().Set("Content-Type", thumbnailContentType(ext))
+			http.ServeContent(w, r, "", info.ModTime(), f)
+			return
+		}
+	}
+
+	thumb, ext, err := h.makeThumbnail(fp, spec)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			http.Error(w, "file is not a supported image", http.StatusUnsupportedMediaType)
+			return
+		}
+		h.t.LogError(r.Context(), "making thumbnail", "name", name, "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if err := h.cache.add(key+ext, thumb); err != nil {
+		h.t.LogWarn(r.Context(), "caching thumbnail", "name", name, "error", err)
+	}
+
+	w.Header().Set("Content-Type", thumbnailContentType(ext))
+	http.ServeContent(w, r, "", info.ModTime(), bytes.NewReader(thumb))
+}
+
+// thumbnailContentType returns the type of thumbnails with the extension ext.
+func thumbnailContentType(ext string) string {
+	if ext == ".jpg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// makeThumbnail returns the encoded thumbnail of the image at fp, and the extension of its format.
+func (h *ThumbnailHandler) makeThumbnail(fp string, spec thumbnailSpec) ([]byte, string, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	meta, err := ExtractImageMetadata(f)
+	if err != nil {
+		return nil, "", err
+	}
+	if meta.Width*meta.Height > h.opts.MaxPixels {
+		return nil, "", fmt.Errorf("image is %dx%d, larger than %d pixels", meta.Width, meta.Height, h.opts.MaxPixels)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+	thumb := resizeImage(orientImage(rgba, meta.Orientation), spec)
+
+	var buf bytes.Buffer
+	if meta.Format == "jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: h.opts.Quality})
+		return buf.Bytes(), ".jpg", err
+	}
+	err = png.Encode(&buf, thumb)
+	return buf.Bytes(), ".png", err
+}
+
+// orientImage returns src turned upright according to its EXIF orientation.
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° counter-clockwise, so turn it clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° clockwise, so turn it counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+	return dst
+}
+
+// resizeImage returns src scaled down to fit spec, never enlarging it. Each pixel is the average of those
+// it covers in src, which keeps thumbnails smooth.
+func resizeImage(src *image.RGBA, spec thumbnailSpec) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw == 0 || sh == 0 {
+		return src
+	}
+	w, h := spec.width, spec.height
+	if w == 0 {
+		w = max(1, sw*h/sh)
+	}
+	if h == 0 {
+		h = max(1, sh*w/sw)
+	}
+
+	if spec.cover {
+		// crop the middle of the image to the thumbnail's aspect ratio
+		cw, ch := sw, max(1, sw*h/w)
+		if ch > sh {
+			cw, ch = max(1, sh*w/h), sh
+		}
+		x0, y0 := (sw-cw)/2, (sh-ch)/2
+		src = src.SubImage(image.Rect(x0, y0, x0+cw, y0+ch)).(*image.RGBA)
+		sw, sh = cw, ch
+		w, h = min(w, sw), min(h, sh)
+	} else {
+		// scale by whichever side is the tighter fit
+		if sw*h > sh*w {
+			h = max(1, sh*w/sw)
+		} else {
+			w = max(1, sw*h/sh)
+		}
+		if w >= sw || h >= sh {
+			w, h = sw, sh
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	base := src.PixOffset(src.Bounds().Min.X, src.Bounds().Min.Y)
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				off := base + sy*src.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += uint64(src.Pix[off+c])
+					}
+					off += 4
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			d := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[d+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// thumbnailCache keeps thumbnails in a directory, removing the least recently used once they take up more
+// than limit bytes. Files' modification times record when they were last used, so the order survives
+// restarts.
+type thumbnailCache struct {
+	dir   string
+	limit int64
+	mu    sync.Mutex
+	size  int64
+	order *list.List // of *thumbnailEntry, most recently used first
+	items map[string]*list.Element
+}
+
+// thumbnailEntry is a cached thumbnail.
+type thumbnailEntry struct {
+	name string
+	size int64
+}
+
+// newThumbnailCache returns a cache in dir holding the thumbnails already there.
+func newThumbnailCache(dir string, limit int64) *thumbnailCache {
+	c := &thumbnailCache{dir: dir, limit: limit, order: list.New(), items: make(map[string]*list.Element)}
+
+	entries, _ := os.ReadDir(dir)
+	var infos []fs.FileInfo
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.Mode().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().After(infos[j].ModTime()) })
+	for _, info := range infos {
+		c.items[info.Name()] = c.order.PushBack(&thumbnailEntry{name: info.Name(), size: info.Size()})
+		c.size += info.Size()
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c
+}
+
+// open returns the cached thumbnail name, marking it as used, and reports whether there is one.
+func (c *thumbnailCache) open(name string) (*os.File, bool) {
+	c.mu.Lock()
+	el, ok := c.items[name]
+	if ok {
+		c.order.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	p := filepath.Join(c.dir, name)
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return f, true
+}
+
+// add stores the thumbnail name, removing the least recently used ones if the cache is over its limit.
+func (c *thumbnailCache) add(name string, data []byte) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	if _, err := writeFileAtomic(filepath.Join(c.dir, name), bytes.NewReader(data), false); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[name]; ok {
+		c.size -= el.Value.(*thumbnailEntry).size
+		c.order.Remove(el)
+	}
+	c.items[name] = c.order.PushFront(&thumbnailEntry{name: name, size: int64(len(data))})
+	c.size += int64(len(data))
+	c.evict()
+	return nil
+}
+
+// evict removes the least recently used thumbnails until the cache is within its limit. The caller must
+// hold c.mu.
+func (c *thumbnailCache) evict() {
+	for c.size > c.limit && c.order.Len() > 0 {
+		el := c.order.Back()
+		entry := el.Value.(*thumbnailEntry)
+		c.order.Remove(el)
+		delete(c.items, entry.name)
+		c.size -= entry.size
+		_ = os.Remove(filepath.Join(c.dir, entry.name))
+	}
+}
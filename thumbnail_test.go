@@ -0,0 +1,253 @@
+package toolkit
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestImage writes a width by height JPEG to path.
+func writeTestImage(t *testing.T, path string, width, height int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	_ = jpeg.Encode(&buf, img, nil)
+	_ = os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func TestThumbnailHandler_ServeHTTP(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImage(t, filepath.Join(dir, "photo.jpg"), 400, 200)
+	_ = os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "photo.jpg"+uploadMetadataSuffix), []byte("{}"), 0644)
+	img, _ := os.ReadFile("./testdata/img.png")
+	_ = os.WriteFile(filepath.Join(dir, "img.png"), img, 0644)
+
+	testTools := Tools{URLSigningKey: []byte("secret")}
+	h := testTools.ServeThumbnails("/thumb/", ThumbnailOptions{Dir: dir, CacheDir: t.TempDir()})
+
+	signed := func(name string, w, hgt int, fit string) string {
+		u, err := h.URL(name, w, hgt, fit, time.Hour)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return u
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		expectedCode  int
+		contentType   string
+		width, height int
+	}{
+		{name: "contain", url: signed("photo.jpg", 100, 100, ""), expectedCode: http.StatusOK, contentType: "image/jpeg", width: 100, height: 50},
+		{name: "cover", url: signed("photo.jpg", 100, 100, "cover"), expectedCode: http.StatusOK, contentType: "image/jpeg", width: 100, height: 100},
+		{name: "height only", url: signed("photo.jpg", 0, 50, ""), expectedCode: http.StatusOK, contentType: "image/jpeg", width: 100, height: 50},
+		{name: "not enlarged", url: signed("photo.jpg", 1000, 0, ""), expectedCode: http.StatusOK, contentType: "image/jpeg", width: 400, height: 200},
+		{name: "cover not enlarged", url: signed("photo.jpg", 1000, 1000, "cover"), expectedCode: http.StatusOK, contentType: "image/jpeg", width: 200, height: 200},
+		{name: "png", url: signed("img.png", 10, 10, "cover"), expectedCode: http.StatusOK, contentType: "image/png", width: 10, height: 10},
+		{name: "unsigned", url: "/thumb/photo.jpg?w=100", expectedCode: http.StatusForbidden},
+		{name: "tampered", url: strings.Replace(signed("photo.jpg", 100, 0, ""), "w=100", "w=1000", 1), expectedCode: http.StatusForbidden},
+		{name: "no size", url: signed("photo.jpg", 0, 0, ""), expectedCode: http.StatusBadRequest},
+		{name: "too large", url: signed("photo.jpg", 5000, 0, ""), expectedCode: http.StatusBadRequest},
+		{name: "bad fit", url: signed("photo.jpg", 100, 0, "stretch"), expectedCode: http.StatusBadRequest},
+		{name: "missing", url: signed("missing.jpg", 100, 0, ""), expectedCode: http.StatusNotFound},
+		{name: "metadata", url: signed("photo.jpg"+uploadMetadataSuffix, 100, 0, ""), expectedCode: http.StatusNotFound},
+		{name: "not an image", url: signed("notes.txt", 100, 0, ""), expectedCode: http.StatusUnsupportedMediaType},
+		{name: "post", method: http.MethodPost, url: signed("photo.jpg", 100, 0, ""), expectedCode: http.StatusMethodNotAllowed},
+	}
+
+	for _, e := range tests {
+		method := e.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, e.url, nil))
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: expected status %d, got %d: %s", e.name, e.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if e.expectedCode != http.StatusOK {
+			continue
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != e.contentType {
+			t.Errorf("%s: expected content type %s, got %s", e.name, e.contentType, ct)
+		}
+		if cc := rr.Header().Get("Cache-Control"); cc != "public, max-age=604800" {
+			t.Errorf("%s: unexpected Cache-Control %q", e.name, cc)
+		}
+		if rr.Header().Get("ETag") == "" {
+			t.Errorf("%s: expected an ETag", e.name)
+		}
+		cfg, _, err := image.DecodeConfig(rr.Body)
+		if err != nil {
+			t.Errorf("%s: could not decode thumbnail: %s", e.name, err)
+			continue
+		}
+		if cfg.Width != e.width || cfg.Height != e.height {
+			t.Errorf("%s: expected %dx%d, got %dx%d", e.name, e.width, e.height, cfg.Width, cfg.Height)
+		}
+	}
+}
+
+func TestThumbnailHandler_Cache(t *testing.T) {
+	dir, cacheDir := t.TempDir(), t.TempDir()
+	writeTestImage(t, filepath.Join(dir, "photo.jpg"), 400, 200)
+
+	testTools := Tools{URLSigningKey: []byte("secret")}
+	h := testTools.ServeThumbnails("/thumb", ThumbnailOptions{Dir: dir, CacheDir: cacheDir})
+	u, _ := h.URL("photo.jpg", 100, 0, "", time.Hour)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, u, nil))
+	first, etag := rr.Body.Bytes(), rr.Header().Get("ETag")
+
+	entries, _ := os.ReadDir(cacheDir)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 cached thumbnail, got %d", len(entries))
+	}
+
+	// a new handler picks up the cached thumbnail
+	h = testTools.ServeThumbnails("/thumb", ThumbnailOptions{Dir: dir, CacheDir: cacheDir})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, u, nil))
+	if !bytes.Equal(rr.Body.Bytes(), first) || rr.Header().Get("ETag") != etag {
+		t.Error("expected the cached thumbnail to be served")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, u, nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, rr.Code)
+	}
+
+	// replacing the original changes the thumbnail
+	writeTestImage(t, filepath.Join(dir, "photo.jpg"), 300, 300)
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(filepath.Join(dir, "photo.jpg"), later, later)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, u, nil))
+	if rr.Header().Get("ETag") == etag {
+		t.Error("expected a new ETag once the original was replaced")
+	}
+	if cfg, _, _ := image.DecodeConfig(rr.Body); cfg.Width != 100 || cfg.Height != 100 {
+		t.Errorf("expected a 100x100 thumbnail, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
+
+func TestThumbnailCache_Evict(t *testing.T) {
+	dir := t.TempDir()
+	c := newThumbnailCache(dir, 25)
+	for _, name := range []string{"a", "b"} {
+		if err := c.add(name, make([]byte, 10)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// using a makes b the least recently used
+	if f, ok := c.open("a"); !ok {
+		t.Fatal("expected a to be cached")
+	} else {
+		_ = f.Close()
+	}
+	_ = c.add("c", make([]byte, 10))
+
+	for name, expected := range map[string]bool{"a": true, "b": false, "c": true} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if (err == nil) != expected {
+			t.Errorf("%s: expected cached to be %t", name, expected)
+		}
+	}
+
+	// a cache over a smaller limit is trimmed when it is opened
+	c = newThumbnailCache(dir, 10)
+	if _, ok := c.open("a"); ok {
+		t.Error("expected a to have been evicted")
+	}
+	if f, ok := c.open("c"); !ok {
+		t.Error("expected c to be cached")
+	} else {
+		_ = f.Close()
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// a 2x1 image, red on the left and blue on the right
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	src.SetRGBA(0, 0, red)
+	src.SetRGBA(1, 0, blue)
+
+	tests := []struct {
+		orientation   int
+		width, height int
+		red           image.Point
+	}{
+		{orientation: 1, width: 2, height: 1, red: image.Pt(0, 0)},
+		{orientation: 2, width: 2, height: 1, red: image.Pt(1, 0)},
+		{orientation: 3, width: 2, height: 1, red: image.Pt(1, 0)},
+		{orientation: 4, width: 2, height: 1, red: image.Pt(0, 0)},
+		{orientation: 5, width: 1, height: 2, red: image.Pt(0, 0)},
+		{orientation: 6, width: 1, height: 2, red: image.Pt(0, 0)},
+		{orientation: 7, width: 1, height: 2, red: image.Pt(0, 1)},
+		{orientation: 8, width: 1, height: 2, red: image.Pt(0, 1)},
+	}
+
+	for _, e := range tests {
+		dst := orientImage(src, e.orientation)
+		if dst.Bounds().Dx() != e.width || dst.Bounds().Dy() != e.height {
+			t.Errorf("orientation %d: expected %dx%d, got %v", e.orientation, e.width, e.height, dst.Bounds())
+			continue
+		}
+		if dst.RGBAAt(e.red.X, e.red.Y) != red {
+			t.Errorf("orientation %d: expected red at %v", e.orientation, e.red)
+		}
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	// a 4x4 image, black on the left half and white on the right
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 2; x < 4; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+
+	dst := resizeImage(src, thumbnailSpec{width: 2, height: 2})
+	if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 2 {
+		t.Fatalf("expected 2x2, got %v", dst.Bounds())
+	}
+	if dst.RGBAAt(0, 0).R != 0 || dst.RGBAAt(1, 1).R != 255 {
+		t.Errorf("unexpected pixels %v %v", dst.RGBAAt(0, 0), dst.RGBAAt(1, 1))
+	}
+
+	dst = resizeImage(src, thumbnailSpec{width: 1, height: 1})
+	if r := dst.RGBAAt(0, 0).R; r != 127 {
+		t.Errorf("expected the average grey 127, got %d", r)
+	}
+
+	dst = resizeImage(src, thumbnailSpec{width: 2, height: 1, cover: true})
+	if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 1 {
+		t.Errorf("expected 2x1, got %v", dst.Bounds())
+	}
+}
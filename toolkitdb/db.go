@@ -0,0 +1,111 @@
+// Package toolkitdb removes the database/sql boilerplate around an app's queries: connecting with retries
+// while the database starts up, running functions in transactions, scanning rows into structs, and a
+// health check for the toolkit's readiness endpoint. It works with any database/sql driver.
+package toolkitdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+// ConnectOptions configures ConnectWithRetry. The zero value tries 5 times, waiting 1 second after the
+// first failure and twice as long after each one after that, up to 30 seconds.
+type ConnectOptions struct {
+	Attempts        int           // number of times to try connecting; defaults to 5
+	Delay           time.Duration // wait after the first failed attempt, doubled after each failure; defaults to 1 second
+	MaxDelay        time.Duration // longest wait between attempts; defaults to 30 seconds
+	MaxOpenConns    int           // passed to sql.DB.SetMaxOpenConns if set
+	MaxIdleConns    int           // passed to sql.DB.SetMaxIdleConns if set
+	ConnMaxLifetime time.Duration // passed to sql.DB.SetConnMaxLifetime if set
+	Logger          *slog.Logger  // if set, failed attempts are logged at warn level, such as to Tools.Slog
+}
+
+// ConnectWithRetry opens a database with the named driver and dsn, as sql.Open does, and pings it until it
+// responds, so an app started alongside its database, such as with Docker Compose, waits for it rather than
+// failing. It gives up, closing the database, when opts.Attempts have failed or ctx is done, returning the
+// last error.
+func ConnectWithRetry(ctx context.Context, driver, dsn string, opts ConnectOptions) (*sql.DB, error) {
+	if opts.Attempts <= 0 {
+		opts.Attempts = 5
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = time.Second
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 30 * time.Second
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+
+	delay := opts.Delay
+	for attempt := 1; ; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			return db, nil
+		}
+		if attempt == opts.Attempts {
+			break
+		}
+		if opts.Logger != nil {
+			opts.Logger.WarnContext(ctx, "database not ready", "driver", driver, "attempt", attempt, "retry_in", delay.String(), "error", err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			_ = db.Close()
+			return nil, fmt.Errorf("connecting to database: %w", ctx.Err())
+		case <-timer.C:
+		}
+		delay = min(delay*2, opts.MaxDelay)
+	}
+
+	_ = db.Close()
+	return nil, fmt.Errorf("connecting to database after %d attempts: %w", opts.Attempts, err)
+}
+
+// PoolStats are the connection pool statistics a HealthCheck reports in its details.
+type PoolStats struct {
+	MaxOpen   int    `json:"max_open"`
+	Open      int    `json:"open"`
+	InUse     int    `json:"in_use"`
+	Idle      int    `json:"idle"`
+	WaitCount int64  `json:"wait_count"`
+	WaitTime  string `json:"wait_time"`
+}
+
+// HealthCheck returns a check for Health.AddReadinessCheck which pings db, reporting the statistics of its
+// connection pool in the check's details, so a pool which has run out of connections shows up in the
+// readiness report.
+func HealthCheck(db *sql.DB) toolkit.HealthCheck {
+	return func(ctx context.Context) error {
+		err := db.PingContext(ctx)
+		s := db.Stats()
+		toolkit.SetHealthDetails(ctx, PoolStats{
+			MaxOpen:   s.MaxOpenConnections,
+			Open:      s.OpenConnections,
+			InUse:     s.InUse,
+			Idle:      s.Idle,
+			WaitCount: s.WaitCount,
+			WaitTime:  s.WaitDuration.String(),
+		})
+		return err
+	}
+}
@@ -0,0 +1,249 @@
+package toolkitdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+// fakeDriver is a database/sql driver whose databases are fakeDBs, looked up by DSN, so tests can script
+// results and see what was done.
+type fakeDriver struct{}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = make(map[string]*fakeDB)
+)
+
+func init() {
+	sql.Register("toolkitdbfake", fakeDriver{})
+}
+
+// fakeDB is the state of a fake database.
+type fakeDB struct {
+	mu           sync.Mutex
+	pingFailures int // pings fail until this many have been made
+	pings        int
+	results      map[string]fakeResult // by query
+	execs        []string
+	commits      int
+	rollbacks    int
+	onExec       func(query string, args []driver.Value) (int64, error)     // if set, runs each Exec, returning the rows affected
+	onQuery      func(query string, args []driver.Value) (fakeResult, bool) // if set, answers queries it knows before results
+}
+
+// fakeResult is the result of a query.
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// newFakeDB registers a fake database under the test's name, and returns it with its DSN.
+func newFakeDB(t *testing.T) (*fakeDB, string) {
+	f := &fakeDB{results: make(map[string]fakeResult)}
+	fakeDBsMu.Lock()
+	fakeDBs[t.Name()] = f
+	fakeDBsMu.Unlock()
+	return f, t.Name()
+}
+
+// open opens the fake database for a test with sql.Open.
+func (f *fakeDB) open(t *testing.T, dsn string) *sql.DB {
+	db, err := sql.Open("toolkitdbfake", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	f, ok := fakeDBs[dsn]
+	if !ok {
+		return nil, errors.New("unknown database " + dsn)
+	}
+	return &fakeConn{db: f}, nil
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return &fakeTx{db: c.db}, nil }
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.pings++
+	if c.db.pings <= c.db.pingFailures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.execs = append(s.db.execs, s.query)
+	if s.db.onExec != nil {
+		n, err := s.db.onExec(s.query, args)
+		if err != nil {
+			return nil, err
+		}
+		return driver.RowsAffected(n), nil
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if s.db.onQuery != nil {
+		if r, ok := s.db.onQuery(s.query, args); ok {
+			return &fakeRows{result: r}, nil
+		}
+	}
+	r, ok := s.db.results[s.query]
+	if !ok {
+		return nil, errors.New("unexpected query " + s.query)
+	}
+	return &fakeRows{result: r}, nil
+}
+
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (tx *fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.rollbacks++
+	return nil
+}
+
+func TestConnectWithRetry(t *testing.T) {
+	f, dsn := newFakeDB(t)
+	f.pingFailures = 2
+
+	var logBuf strings.Builder
+	db, err := ConnectWithRetry(context.Background(), "toolkitdbfake", dsn, ConnectOptions{
+		Delay:        time.Millisecond,
+		MaxOpenConns: 3,
+		Logger:       slog.New(slog.NewTextHandler(&logBuf, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if f.pings != 3 {
+		t.Errorf("expected 3 pings, got %d", f.pings)
+	}
+	if strings.Count(logBuf.String(), "database not ready") != 2 {
+		t.Errorf("expected two failures to be logged, got %s", logBuf.String())
+	}
+	if db.Stats().MaxOpenConnections != 3 {
+		t.Errorf("expected the pool to be configured, got %d", db.Stats().MaxOpenConnections)
+	}
+}
+
+func TestConnectWithRetry_GivesUp(t *testing.T) {
+	f, dsn := newFakeDB(t)
+	f.pingFailures = 10
+
+	_, err := ConnectWithRetry(context.Background(), "toolkitdbfake", dsn, ConnectOptions{Attempts: 3, Delay: time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected the last error, got %v", err)
+	}
+	if f.pings != 3 {
+		t.Errorf("expected 3 attempts, got %d", f.pings)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ConnectWithRetry(ctx, "toolkitdbfake", dsn, ConnectOptions{Delay: time.Hour}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+
+	if _, err := ConnectWithRetry(context.Background(), "nodriver", dsn, ConnectOptions{}); err == nil {
+		t.Error("expected an error for an unknown driver")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	var testTools toolkit.Tools
+	f, dsn := newFakeDB(t)
+	db := f.open(t, dsn)
+
+	health := testTools.NewHealth()
+	health.AddReadinessCheck("database", HealthCheck(db))
+	rr := httptest.NewRecorder()
+	health.ReadinessHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report struct {
+		Checks map[string]struct {
+			Status  string
+			Details PoolStats
+		}
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	check := report.Checks["database"]
+	if rr.Code != http.StatusOK || check.Status != "ok" || check.Details.Open != 1 {
+		t.Errorf("expected a passing check with the pool stats, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	f.pingFailures = f.pings + 1
+	rr = httptest.NewRecorder()
+	health.ReadinessHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the check to fail, got %d", rr.Code)
+	}
+}
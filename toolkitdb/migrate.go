@@ -0,0 +1,235 @@
+package toolkitdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// ErrDirtyMigration is wrapped by the errors Status, Up and Down return when the files no longer match the
+// applied migrations, such as when an applied migration's files have been deleted.
+var ErrDirtyMigration = errors.New("migrations don't match the database")
+
+// MigrateOptions configures a Migrator.
+type MigrateOptions struct {
+	Table  string       // table recording the applied migrations; defaults to schema_migrations
+	Logger *slog.Logger // if set, each migration applied or rolled back is logged at info level, such as Tools.Slog
+}
+
+// Migration is a schema change, read from a pair of files named with its version and name, such as
+// 0001_create_books.up.sql and 0001_create_books.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string // the SQL applying the migration
+	Down    string // the SQL rolling it back; empty if there is no down file
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// Migrator applies the migrations in a file system, such as an embed.FS of SQL files, to a database,
+// recording the versions applied in a table. Each migration runs in a transaction, so a failed migration
+// leaves no trace on databases with transactional DDL, such as PostgreSQL and SQLite. Files with several
+// statements need a driver which accepts them in a single Exec (MySQL's needs multiStatements=true). Run
+// migrations from a single instance, such as a release job, as the Migrator doesn't lock the table.
+type Migrator struct {
+	db   *sql.DB
+	fsys fs.FS
+	opts MigrateOptions
+}
+
+// identifierRegexp matches the table names allowed in MigrateOptions.Table.
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// NewMigrator returns a Migrator for the migrations in the root of fsys; use fs.Sub for a subdirectory.
+func NewMigrator(db *sql.DB, fsys fs.FS, opts MigrateOptions) *Migrator {
+	if opts.Table == "" {
+		opts.Table = "schema_migrations"
+	}
+	return &Migrator{db: db, fsys: fsys, opts: opts}
+}
+
+// Migrate applies the pending migrations in fsys to db, for calling when an app starts.
+func Migrate(ctx context.Context, db *sql.DB, fsys fs.FS, opts MigrateOptions) error {
+	_, err := NewMigrator(db, fsys, opts).Up(ctx)
+	return err
+}
+
+// migrationFileRegexp matches the name of a migration file, capturing its version, name and direction.
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migrations returns the migrations in the file system, ordered by version. Files which aren't named like
+// migrations are ignored.
+func (m *Migrator) Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := migrationFileRegexp.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		b, err := fs.ReadFile(m.fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Status returns every migration, with whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, mig := range migrations {
+		status[i] = MigrationStatus{Migration: mig, Applied: applied[mig.Version]}
+	}
+	return status, nil
+}
+
+// Up applies the pending migrations, in order, and returns how many were applied. It stops at the first
+// which fails.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range migrations {
+		if applied[mig.Version] {
+			continue
+		}
+		m.log(ctx, "applying migration", "version", mig.Version, "name", mig.Name)
+		err := WithTx(ctx, m.db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, applied_at) VALUES (%d, CURRENT_TIMESTAMP)", m.opts.Table, mig.Version))
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		count++
+	}
+	if count == 0 {
+		m.log(ctx, "migrations are up to date")
+	}
+	return count, nil
+}
+
+// Down rolls back the last steps migrations applied, newest first, and returns how many were rolled back.
+// A migration without a down file can't be rolled back, and stops it with an error.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := migrations[i]
+		if !applied[mig.Version] {
+			continue
+		}
+		if mig.Down == "" {
+			return count, fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+		}
+		m.log(ctx, "rolling back migration", "version", mig.Version, "name", mig.Name)
+		err := WithTx(ctx, m.db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version = %d", m.opts.Table, mig.Version))
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// load returns the migrations in the file system, and the versions applied, creating the table if it
+// doesn't exist.
+func (m *Migrator) load(ctx context.Context) ([]Migration, map[int64]bool, error) {
+	if !identifierRegexp.MatchString(m.opts.Table) {
+		return nil, nil, fmt.Errorf("invalid migrations table name %q", m.opts.Table)
+	}
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version BIGINT PRIMARY KEY, applied_at TIMESTAMP NOT NULL)", m.opts.Table)
+	if _, err := m.db.ExecContext(ctx, create); err != nil {
+		return nil, nil, err
+	}
+
+	versions, err := QueryAll[int64](ctx, m.db, fmt.Sprintf("SELECT version FROM %s", m.opts.Table))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		if !slices.ContainsFunc(migrations, func(mig Migration) bool { return mig.Version == v }) {
+			return nil, nil, fmt.Errorf("%w: version %d was applied, but has no files", ErrDirtyMigration, v)
+		}
+		applied[v] = true
+	}
+	return migrations, applied, nil
+}
+
+// log logs msg at info level, with key-value pairs, if there is a Logger.
+func (m *Migrator) log(ctx context.Context, msg string, args ...any) {
+	if m.opts.Logger != nil {
+		m.opts.Logger.InfoContext(ctx, msg, args...)
+	}
+}
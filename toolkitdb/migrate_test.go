@@ -0,0 +1,156 @@
+package toolkitdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// fakeMigrations records the versions in the fake database's migrations table, and the migrations run.
+type fakeMigrations struct {
+	versions []int64
+	ran      []string
+	fail     string // a migration whose SQL fails
+}
+
+// install has f answer the Migrator's statements from m.
+func (m *fakeMigrations) install(f *fakeDB) {
+	f.onExec = func(query string, args []driver.Value) (int64, error) {
+		var version int64
+		switch {
+		case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+			_, _ = fmt.Sscanf(query, "INSERT INTO schema_migrations (version, applied_at) VALUES (%d,", &version)
+			m.versions = append(m.versions, version)
+		case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+			_, _ = fmt.Sscanf(query, "DELETE FROM schema_migrations WHERE version = %d", &version)
+			for i, v := range m.versions {
+				if v == version {
+					m.versions = append(m.versions[:i], m.versions[i+1:]...)
+				}
+			}
+		case query == m.fail:
+			return 0, errors.New("syntax error")
+		default:
+			m.ran = append(m.ran, query)
+		}
+		return 1, nil
+	}
+	f.onQuery = func(query string, args []driver.Value) (fakeResult, bool) {
+		if query != "SELECT version FROM schema_migrations" {
+			return fakeResult{}, false
+		}
+		r := fakeResult{columns: []string{"version"}}
+		for _, v := range m.versions {
+			r.rows = append(r.rows, []driver.Value{v})
+		}
+		return r, true
+	}
+}
+
+var testMigrations = fstest.MapFS{
+	"0001_create_books.up.sql":     {Data: []byte("CREATE TABLE books")},
+	"0001_create_books.down.sql":   {Data: []byte("DROP TABLE books")},
+	"0002_add_isbn.up.sql":         {Data: []byte("ALTER TABLE books ADD isbn")},
+	"0002_add_isbn.down.sql":       {Data: []byte("ALTER TABLE books DROP isbn")},
+	"0010_create_authors.up.sql":   {Data: []byte("CREATE TABLE authors")},
+	"0010_create_authors.down.sql": {Data: []byte("DROP TABLE authors")},
+	"README.md":                    {Data: []byte("not a migration")},
+}
+
+func TestMigrator(t *testing.T) {
+	f, dsn := newFakeDB(t)
+	db := f.open(t, dsn)
+	state := &fakeMigrations{}
+	state.install(f)
+	ctx := context.Background()
+
+	var logBuf strings.Builder
+	m := NewMigrator(db, testMigrations, MigrateOptions{Logger: slog.New(slog.NewTextHandler(&logBuf, nil))})
+
+	n, err := m.Up(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 migrations to be applied, got %d, %v", n, err)
+	}
+	expected := []string{"CREATE TABLE books", "ALTER TABLE books ADD isbn", "CREATE TABLE authors"}
+	if strings.Join(state.ran, ";") != strings.Join(expected, ";") {
+		t.Errorf("expected the migrations in order, got %v", state.ran)
+	}
+	if !strings.Contains(logBuf.String(), "name=add_isbn") {
+		t.Errorf("expected the migrations to be logged, got %s", logBuf.String())
+	}
+
+	// nothing is pending
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Errorf("expected nothing to apply, got %d, %v", n, err)
+	}
+
+	state.ran = nil
+	if n, err := m.Down(ctx, 2); err != nil || n != 2 {
+		t.Fatalf("expected 2 migrations to be rolled back, got %d, %v", n, err)
+	}
+	if strings.Join(state.ran, ";") != "DROP TABLE authors;ALTER TABLE books DROP isbn" {
+		t.Errorf("expected the newest migrations to be rolled back first, got %v", state.ran)
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil || len(status) != 3 || !status[0].Applied || status[1].Applied || status[2].Applied {
+		t.Errorf("unexpected status %+v, %v", status, err)
+	}
+}
+
+func TestMigrate_Failure(t *testing.T) {
+	f, dsn := newFakeDB(t)
+	db := f.open(t, dsn)
+	state := &fakeMigrations{fail: "ALTER TABLE books ADD isbn"}
+	state.install(f)
+
+	err := Migrate(context.Background(), db, testMigrations, MigrateOptions{})
+	if err == nil || !strings.Contains(err.Error(), "2_add_isbn") {
+		t.Fatalf("expected the failing migration to be named, got %v", err)
+	}
+	if len(state.versions) != 1 || state.versions[0] != 1 {
+		t.Errorf("expected only the first migration to be recorded, got %v", state.versions)
+	}
+	if f.rollbacks != 1 {
+		t.Errorf("expected the failed migration to be rolled back, got %d rollbacks", f.rollbacks)
+	}
+}
+
+func TestMigrator_Errors(t *testing.T) {
+	f, dsn := newFakeDB(t)
+	db := f.open(t, dsn)
+	state := &fakeMigrations{versions: []int64{99}}
+	state.install(f)
+	ctx := context.Background()
+
+	if _, err := NewMigrator(db, testMigrations, MigrateOptions{}).Up(ctx); !errors.Is(err, ErrDirtyMigration) {
+		t.Errorf("expected ErrDirtyMigration for an applied version without files, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		opts MigrateOptions
+	}{
+		{name: "no up file", fsys: fstest.MapFS{"0001_a.down.sql": {Data: []byte("x")}}},
+		{name: "same version", fsys: fstest.MapFS{"0001_a.up.sql": {Data: []byte("x")}, "0001_b.up.sql": {Data: []byte("y")}}},
+		{name: "bad table name", fsys: testMigrations, opts: MigrateOptions{Table: "migrations; DROP TABLE books"}},
+	}
+	for _, e := range tests {
+		if _, err := NewMigrator(db, e.fsys, e.opts).Status(ctx); err == nil {
+			t.Errorf("%s: expected an error", e.name)
+		}
+	}
+
+	state.versions = []int64{1}
+	noDown := fstest.MapFS{"0001_a.up.sql": {Data: []byte("x")}}
+	if _, err := NewMigrator(db, noDown, MigrateOptions{}).Down(ctx, 1); err == nil {
+		t.Error("expected an error rolling back a migration without a down file")
+	}
+}
@@ -0,0 +1,171 @@
+package toolkitdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+// OutboxStoreOptions configures an OutboxStore.
+type OutboxStoreOptions struct {
+	Table  string // table holding the messages; defaults to toolkit_outbox
+	Dollar bool   // use $1-style placeholders, as PostgreSQL needs, rather than ?
+}
+
+// OutboxStore is a toolkit.OutboxStore keeping messages in a database table. Add messages with AddTx in the
+// transaction saving the change they announce, so they are sent if, and only if, it is committed. Times
+// are stored as Unix milliseconds, so the table works the same with any database.
+type OutboxStore struct {
+	db   *sql.DB
+	opts OutboxStoreOptions
+}
+
+var _ toolkit.OutboxStore = (*OutboxStore)(nil)
+
+// NewOutboxStore returns an OutboxStore keeping messages in db. Call CreateTable, or create the table in a
+// migration, before using it.
+func NewOutboxStore(db *sql.DB, opts OutboxStoreOptions) (*OutboxStore, error) {
+	if opts.Table == "" {
+		opts.Table = "toolkit_outbox"
+	}
+	if !identifierRegexp.MatchString(opts.Table) {
+		return nil, fmt.Errorf("invalid outbox table name %q", opts.Table)
+	}
+	return &OutboxStore{db: db, opts: opts}, nil
+}
+
+// CreateTable creates the table, if it doesn't exist.
+func (s *OutboxStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.query(`CREATE TABLE IF NOT EXISTS {table} (
+	id VARCHAR(64) PRIMARY KEY,
+	uri TEXT NOT NULL,
+	body TEXT NOT NULL,
+	headers TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	next_attempt BIGINT NOT NULL,
+	last_error TEXT NOT NULL,
+	failed SMALLINT NOT NULL,
+	created_at BIGINT NOT NULL
+)`))
+	return err
+}
+
+// Add saves a new message on its own; use AddTx to save it with other changes.
+func (s *OutboxStore) Add(ctx context.Context, msg toolkit.OutboxMessage) error {
+	return WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		return s.AddTx(ctx, tx, msg)
+	})
+}
+
+// AddTx saves a new message in tx, such as one made with Outbox.Message. The dispatcher only sees it once
+// tx is committed; call Outbox.Notify afterwards to have it sent straight away.
+func (s *OutboxStore) AddTx(ctx context.Context, tx *sql.Tx, msg toolkit.OutboxMessage) error {
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.query("INSERT INTO {table} (id, uri, body, headers, attempts, next_attempt, last_error, failed, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		msg.ID, msg.URI, string(msg.Body), string(headers), msg.Attempts, msg.NextAttempt.UnixMilli(), msg.LastError, boolInt(msg.Failed), msg.CreatedAt.UnixMilli())
+	return err
+}
+
+// outboxRow is a row of the outbox table.
+type outboxRow struct {
+	ID          string
+	URI         string
+	Body        string
+	Headers     string
+	Attempts    int
+	NextAttempt int64
+	LastError   string
+	CreatedAt   int64
+}
+
+// Claim returns the messages due at now, and postpones them by lease. A message another dispatcher claims
+// first is skipped.
+func (s *OutboxStore) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]toolkit.OutboxMessage, error) {
+	var msgs []toolkit.OutboxMessage
+	err := WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		msgs = nil
+		rows, err := QueryAll[outboxRow](ctx, tx, s.query(fmt.Sprintf("SELECT id, uri, body, headers, attempts, next_attempt, last_error, created_at FROM {table} WHERE failed = 0 AND next_attempt <= ? ORDER BY created_at LIMIT %d", limit)), now.UnixMilli())
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			// only the dispatcher which moves next_attempt on from the value it read has the message
+			result, err := tx.ExecContext(ctx, s.query("UPDATE {table} SET next_attempt = ? WHERE id = ? AND next_attempt = ?"), now.Add(lease).UnixMilli(), row.ID, row.NextAttempt)
+			if err != nil {
+				return err
+			}
+			if n, err := result.RowsAffected(); err != nil || n != 1 {
+				continue
+			}
+
+			msg := toolkit.OutboxMessage{
+				ID:          row.ID,
+				URI:         row.URI,
+				Body:        []byte(row.Body),
+				Attempts:    row.Attempts,
+				NextAttempt: time.UnixMilli(row.NextAttempt),
+				LastError:   row.LastError,
+				CreatedAt:   time.UnixMilli(row.CreatedAt),
+			}
+			var headers http.Header
+			if err := json.Unmarshal([]byte(row.Headers), &headers); err != nil {
+				return fmt.Errorf("outbox message %s: %w", row.ID, err)
+			}
+			msg.Headers = headers
+			msgs = append(msgs, msg)
+		}
+		return nil
+	})
+	return msgs, err
+}
+
+// Delete removes a message.
+func (s *OutboxStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, s.query("DELETE FROM {table} WHERE id = ?"), id)
+	return err
+}
+
+// Update saves a message after a failed attempt.
+func (s *OutboxStore) Update(ctx context.Context, msg toolkit.OutboxMessage) error {
+	_, err := s.db.ExecContext(ctx, s.query("UPDATE {table} SET attempts = ?, next_attempt = ?, last_error = ?, failed = ? WHERE id = ?"),
+		msg.Attempts, msg.NextAttempt.UnixMilli(), msg.LastError, boolInt(msg.Failed), msg.ID)
+	return err
+}
+
+// query returns q with the table name filled in, and its placeholders numbered if Dollar is set.
+func (s *OutboxStore) query(q string) string {
+	q = strings.ReplaceAll(q, "{table}", s.opts.Table)
+	if !s.opts.Dollar {
+		return q
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range q {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// boolInt returns 1 for true and 0 for false, for databases without a boolean type.
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
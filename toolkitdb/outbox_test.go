@@ -0,0 +1,146 @@
+package toolkitdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+// fakeOutbox keeps the rows of the fake database's outbox table, by ID, with the columns in table order.
+type fakeOutbox struct {
+	rows  map[string][]driver.Value
+	stale bool // if set, the next SELECT returns next_attempt values another dispatcher has since changed
+}
+
+// install has f answer the OutboxStore's statements from o.
+func (o *fakeOutbox) install(f *fakeDB) {
+	o.rows = make(map[string][]driver.Value)
+	f.onExec = func(query string, args []driver.Value) (int64, error) {
+		switch {
+		case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS toolkit_outbox"):
+		case strings.HasPrefix(query, "INSERT INTO toolkit_outbox"):
+			o.rows[args[0].(string)] = args
+		case strings.HasPrefix(query, "UPDATE toolkit_outbox SET next_attempt = ?"):
+			row, ok := o.rows[args[1].(string)]
+			if !ok || row[5] != args[2] {
+				return 0, nil
+			}
+			row[5] = args[0]
+		case strings.HasPrefix(query, "UPDATE toolkit_outbox SET attempts"):
+			row := o.rows[args[4].(string)]
+			row[4], row[5], row[6], row[7] = args[0], args[1], args[2], args[3]
+		case strings.HasPrefix(query, "DELETE FROM toolkit_outbox"):
+			delete(o.rows, args[0].(string))
+		default:
+			return 0, errors.New("unexpected query " + query)
+		}
+		return 1, nil
+	}
+	f.onQuery = func(query string, args []driver.Value) (fakeResult, bool) {
+		if !strings.HasPrefix(query, "SELECT id, uri, body, headers, attempts, next_attempt, last_error, created_at FROM toolkit_outbox WHERE failed = 0 AND next_attempt <= ? ORDER BY created_at LIMIT") {
+			return fakeResult{}, false
+		}
+		r := fakeResult{columns: []string{"id", "uri", "body", "headers", "attempts", "next_attempt", "last_error", "created_at"}}
+		for _, row := range o.rows {
+			if row[7].(int64) == 0 && row[5].(int64) <= args[0].(int64) {
+				nextAttempt := row[5]
+				if o.stale {
+					nextAttempt = row[5].(int64) - 1
+				}
+				r.rows = append(r.rows, []driver.Value{row[0], row[1], row[2], row[3], row[4], nextAttempt, row[6], row[8]})
+			}
+		}
+		sort.Slice(r.rows, func(i, j int) bool { return r.rows[i][7].(int64) < r.rows[j][7].(int64) })
+		o.stale = false
+		return r, true
+	}
+}
+
+func TestOutboxStore(t *testing.T) {
+	var testTools toolkit.Tools
+	f, dsn := newFakeDB(t)
+	var table fakeOutbox
+	table.install(f)
+	db := f.open(t, dsn)
+
+	store, err := NewOutboxStore(db, OutboxStoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	outbox := testTools.NewOutbox(toolkit.OutboxOptions{Store: store})
+
+	msg, err := outbox.Message("https://example.com/hooks", map[string]string{"event": "order.paid"}, http.Header{"X-Signature": {"sig"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		return store.AddTx(context.Background(), tx, msg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := msg.CreatedAt.Add(time.Second)
+	claimed, err := store.Claim(context.Background(), now, time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 {
+		t.Fatalf("expected the message to be claimed, got %+v", claimed)
+	}
+	got := claimed[0]
+	if got.ID != msg.ID || got.URI != msg.URI || string(got.Body) != `{"event":"order.paid"}` || got.Headers.Get("X-Signature") != "sig" || !got.CreatedAt.Equal(msg.CreatedAt.Truncate(time.Millisecond)) {
+		t.Errorf("expected the stored message back, got %+v", got)
+	}
+
+	if claimed, _ := store.Claim(context.Background(), now, time.Minute, 10); len(claimed) != 0 {
+		t.Errorf("expected a claimed message not to be claimed again during its lease, got %+v", claimed)
+	}
+
+	later := now.Add(2 * time.Minute)
+	table.stale = true
+	if claimed, _ := store.Claim(context.Background(), later, time.Minute, 10); len(claimed) != 0 {
+		t.Errorf("expected a message claimed by another dispatcher to be skipped, got %+v", claimed)
+	}
+
+	got.Attempts, got.LastError, got.Failed = 3, "remote server returned status 400", true
+	if err := store.Update(context.Background(), got); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, _ := store.Claim(context.Background(), later, time.Minute, 10); len(claimed) != 0 {
+		t.Errorf("expected a failed message not to be claimed, got %+v", claimed)
+	}
+	if row := table.rows[msg.ID]; row[4] != int64(3) || row[6] != "remote server returned status 400" || row[7] != int64(1) {
+		t.Errorf("expected the failure to be saved, got %v", row)
+	}
+
+	if err := store.Delete(context.Background(), msg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(table.rows) != 0 {
+		t.Errorf("expected the message to be deleted, got %v", table.rows)
+	}
+}
+
+func TestOutboxStore_Options(t *testing.T) {
+	if _, err := NewOutboxStore(nil, OutboxStoreOptions{Table: "outbox; DROP TABLE users"}); err == nil {
+		t.Error("expected an invalid table name to be rejected")
+	}
+
+	store, _ := NewOutboxStore(nil, OutboxStoreOptions{Table: "app.outbox", Dollar: true})
+	expected := "UPDATE app.outbox SET next_attempt = $1 WHERE id = $2 AND next_attempt = $3"
+	if got := store.query("UPDATE {table} SET next_attempt = ? WHERE id = ? AND next_attempt = ?"); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
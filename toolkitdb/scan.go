@@ -0,0 +1,189 @@
+package toolkitdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Querier is implemented by *sql.DB, *sql.Tx and *sql.Conn, so queries can run in a transaction or out.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// QueryAll runs query on q and scans every row into a T, as ScanAll does.
+func QueryAll[T any](ctx context.Context, q Querier, query string, args ...any) ([]T, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ScanAll[T](rows)
+}
+
+// QueryOne runs query on q and scans the first row into a T, as ScanOne does, returning sql.ErrNoRows if
+// there isn't one.
+func QueryOne[T any](ctx context.Context, q Querier, query string, args ...any) (T, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return ScanOne[T](rows)
+}
+
+// ScanAll scans every row into a T, and closes rows. If T is a struct, each column is stored in the field
+// whose db tag names it, or else whose name matches it, ignoring case, either as it is or in snake_case
+// (CreatedAt matches created_at); fields of embedded structs are included, and a column without a field
+// is an error. Fields tagged db:"-" are skipped. Otherwise, such as for an int, a string, a time.Time or a
+// sql.Scanner, the query must return a single column, which is scanned into the value.
+func ScanAll[T any](rows *sql.Rows) ([]T, error) {
+	defer rows.Close()
+
+	scan, err := scanner[T](rows)
+	if err != nil {
+		return nil, err
+	}
+	var all []T
+	for rows.Next() {
+		var v T
+		if err := scan(&v); err != nil {
+			return nil, err
+		}
+		all = append(all, v)
+	}
+	return all, rows.Err()
+}
+
+// ScanOne scans the first row into a T, as ScanAll does, and closes rows. It returns sql.ErrNoRows if there
+// are no rows.
+func ScanOne[T any](rows *sql.Rows) (T, error) {
+	defer rows.Close()
+
+	var v T
+	scan, err := scanner[T](rows)
+	if err != nil {
+		return v, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return v, err
+		}
+		return v, sql.ErrNoRows
+	}
+	if err := scan(&v); err != nil {
+		return v, err
+	}
+	return v, rows.Close()
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// scanner returns a function scanning the current row into a T, having matched the columns of rows to its
+// fields.
+func scanner[T any](rows *sql.Rows) (func(v *T) error, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct || t == timeType || reflect.PointerTo(t).Implements(scannerType) {
+		if len(columns) != 1 {
+			return nil, fmt.Errorf("toolkitdb: scanning %d columns into a %s, which takes one", len(columns), t)
+		}
+		return func(v *T) error { return rows.Scan(v) }, nil
+	}
+
+	fields := structFields(t)
+	indexes := make([][]int, len(columns))
+	for i, column := range columns {
+		index, ok := fields[strings.ToLower(column)]
+		if !ok {
+			return nil, fmt.Errorf("toolkitdb: no field of %s for column %s", t, column)
+		}
+		indexes[i] = index
+	}
+
+	dest := make([]any, len(columns))
+	return func(v *T) error {
+		rv := reflect.ValueOf(v).Elem()
+		for i, index := range indexes {
+			dest[i] = rv.FieldByIndex(index).Addr().Interface()
+		}
+		return rows.Scan(dest...)
+	}, nil
+}
+
+// fieldCache holds the result of structFields for each type, as it doesn't change.
+var fieldCache sync.Map // reflect.Type -> map[string][]int
+
+// structFields returns the index of each field of struct type t a column can be stored in, keyed by the
+// lower case column names which match it. Fields of t take precedence over those of embedded structs.
+func structFields(t reflect.Type) map[string][]int {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.(map[string][]int)
+	}
+
+	fields := make(map[string][]int)
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("db")
+		switch {
+		case tag == "-":
+			continue
+		case f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct:
+			embedded = append(embedded, f)
+			continue
+		case !f.IsExported():
+			continue
+		}
+
+		names := []string{strings.ToLower(tag)}
+		if tag == "" {
+			names = []string{strings.ToLower(f.Name), snakeCase(f.Name)}
+		}
+		for _, name := range names {
+			if _, ok := fields[name]; !ok {
+				fields[name] = f.Index
+			}
+		}
+	}
+
+	for _, f := range embedded {
+		for name, index := range structFields(f.Type) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = append(append([]int{}, f.Index...), index...)
+			}
+		}
+	}
+
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// snakeCase returns name in lower snake_case, keeping acronyms together, so UserID becomes user_id and
+// HTTPStatus http_status.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package toolkitdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type scanAudit struct {
+	CreatedAt time.Time
+	UpdatedBy *string
+}
+
+type scanBook struct {
+	ID       int64
+	Title    string `db:"name"`
+	AuthorID int
+	Internal string `db:"-"`
+	scanAudit
+}
+
+func TestQueryAll(t *testing.T) {
+	f, dsn := newFakeDB(t)
+	db := f.open(t, dsn)
+	ctx := context.Background()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	f.results["SELECT books"] = fakeResult{
+		columns: []string{"id", "NAME", "author_id", "created_at", "updated_by"},
+		rows: [][]driver.Value{
+			{int64(1), "Go", int64(7), created, nil},
+			{int64(2), "Rust", int64(8), created, "admin"},
+		},
+	}
+	f.results["SELECT unknown"] = fakeResult{columns: []string{"id", "isbn"}}
+	f.results["SELECT count"] = fakeResult{columns: []string{"count"}, rows: [][]driver.Value{{int64(2)}}}
+	f.results["SELECT none"] = fakeResult{columns: []string{"id"}}
+
+	books, err := QueryAll[scanBook](ctx, db, "SELECT books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 2 || books[0].ID != 1 || books[0].Title != "Go" || books[0].AuthorID != 7 || !books[0].CreatedAt.Equal(created) {
+		t.Errorf("unexpected books %+v", books)
+	}
+	if books[0].UpdatedBy != nil || books[1].UpdatedBy == nil || *books[1].UpdatedBy != "admin" {
+		t.Errorf("expected NULL to scan into a nil pointer, got %+v", books)
+	}
+
+	if _, err := QueryAll[scanBook](ctx, db, "SELECT unknown"); err == nil || !strings.Contains(err.Error(), "isbn") {
+		t.Errorf("expected an error naming the column without a field, got %v", err)
+	}
+
+	n, err := QueryOne[int](ctx, db, "SELECT count")
+	if err != nil || n != 2 {
+		t.Errorf("expected a count of 2, got %d, %v", n, err)
+	}
+	if _, err := QueryOne[scanBook](ctx, db, "SELECT books"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := QueryOne[int](ctx, db, "SELECT none"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+	if _, err := QueryOne[int](ctx, db, "SELECT books"); err == nil {
+		t.Error("expected an error scanning several columns into an int")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"ID":         "id",
+		"Title":      "title",
+		"AuthorID":   "author_id",
+		"CreatedAt":  "created_at",
+		"HTTPStatus": "http_status",
+		"Address2":   "address2",
+		"Line2Text":  "line2_text",
+	}
+	for name, expected := range tests {
+		if got := snakeCase(name); got != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, got)
+		}
+	}
+}
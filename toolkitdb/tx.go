@@ -0,0 +1,38 @@
+package toolkitdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// WithTx runs fn in a transaction on db, committing it if fn returns nil, and rolling it back if fn
+// returns an error or panics, in which case the panic is passed on after the rollback. The error from fn
+// is returned, joined with any error rolling back.
+func WithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return WithTxOptions(ctx, db, nil, fn)
+}
+
+// WithTxOptions is WithTx with options for the transaction, such as its isolation level, or whether it is
+// read only.
+func WithTxOptions(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				err = errors.Join(err, rbErr)
+			}
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	committed = true
+	return tx.Commit()
+}
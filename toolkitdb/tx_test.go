@@ -0,0 +1,40 @@
+package toolkitdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestWithTx(t *testing.T) {
+	f, dsn := newFakeDB(t)
+	db := f.open(t, dsn)
+	ctx := context.Background()
+
+	err := WithTx(ctx, db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO books")
+		return err
+	})
+	if err != nil || f.commits != 1 || f.rollbacks != 0 {
+		t.Errorf("expected a commit, got %v, %d commits, %d rollbacks", err, f.commits, f.rollbacks)
+	}
+
+	failure := errors.New("out of stock")
+	err = WithTx(ctx, db, func(tx *sql.Tx) error { return failure })
+	if !errors.Is(err, failure) || f.commits != 1 || f.rollbacks != 1 {
+		t.Errorf("expected a rollback, got %v, %d commits, %d rollbacks", err, f.commits, f.rollbacks)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to be passed on")
+			}
+		}()
+		_ = WithTxOptions(ctx, db, &sql.TxOptions{}, func(tx *sql.Tx) error { panic("boom") })
+	}()
+	if f.rollbacks != 2 {
+		t.Errorf("expected a rollback after a panic, got %d", f.rollbacks)
+	}
+}
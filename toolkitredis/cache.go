@@ -0,0 +1,63 @@
+package toolkitredis
+
+import (
+	"context"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+// Cache is a toolkit.Cache keeping values in Redis strings, which Redis expires itself.
+type Cache struct {
+	client Client
+	prefix string
+}
+
+var _ toolkit.Cache = (*Cache)(nil)
+
+// Get returns the value stored for key.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.client.Do(ctx, "GET", c.prefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	v, ok, err := replyString(reply)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	return []byte(v), true, nil
+}
+
+// Set stores value for key, for ttl, or without an expiry if ttl is 0.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []any{"SET", c.prefix + key, value}
+	if ttl > 0 {
+		args = append(args, "PX", max(ttl.Milliseconds(), 1))
+	}
+	_, err := c.client.Do(ctx, args...)
+	return err
+}
+
+// Delete removes the entry for key.
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	_, err := c.client.Do(ctx, "DEL", c.prefix+key)
+	return err
+}
+
+// TTL returns the time left before the entry for key expires, using PTTL.
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	reply, err := c.client.Do(ctx, "PTTL", c.prefix+key)
+	if err != nil {
+		return 0, false, err
+	}
+	ms, err := replyInt(reply)
+	switch {
+	case err != nil:
+		return 0, false, err
+	case ms == -2:
+		return 0, false, nil
+	case ms < 0:
+		return 0, true, nil
+	}
+	return time.Duration(ms) * time.Millisecond, true, nil
+}
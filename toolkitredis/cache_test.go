@@ -0,0 +1,53 @@
+package toolkitredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+func TestCache(t *testing.T) {
+	fake := newFakeRedis()
+	cache := New(fake, Options{}).Cache
+	ctx := context.Background()
+
+	if _, ok, err := cache.Get(ctx, "a"); ok || err != nil {
+		t.Fatalf("expected a miss, got %v", err)
+	}
+	if _, ok, _ := cache.TTL(ctx, "a"); ok {
+		t.Error("expected no TTL for a missing key")
+	}
+
+	_ = cache.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = cache.Set(ctx, "forever", []byte("2"), 0)
+	if v, ok, _ := cache.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Fatalf("expected 1, got %q", v)
+	}
+	if ttl, ok, _ := cache.TTL(ctx, "a"); !ok || ttl != time.Minute {
+		t.Errorf("expected a minute left, got %s", ttl)
+	}
+	if ttl, ok, _ := cache.TTL(ctx, "forever"); !ok || ttl != 0 {
+		t.Errorf("expected no expiry, got %s", ttl)
+	}
+
+	_ = cache.Delete(ctx, "forever")
+	if _, ok, _ := cache.Get(ctx, "forever"); ok {
+		t.Error("expected the entry to have been deleted")
+	}
+
+	fake.now = fake.now.Add(time.Minute)
+	if _, ok, _ := cache.Get(ctx, "a"); ok {
+		t.Error("expected the entry to have expired")
+	}
+
+	// the toolkit's generic helpers work on it
+	v, err := toolkit.Remember(ctx, cache, "answer", time.Minute, func(ctx context.Context) (int, error) { return 42, nil })
+	if err != nil || v != 42 {
+		t.Errorf("expected 42, got %d, %v", v, err)
+	}
+	if v, loaded, _ := toolkit.GetOrSet(ctx, cache, "answer", 1, time.Minute); !loaded || v != 42 {
+		t.Errorf("expected the remembered value, got %d", v)
+	}
+}
@@ -0,0 +1,80 @@
+package toolkitredis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+// IdempotencyStore is a toolkit.IdempotencyStore keeping responses in Redis, encoded as JSON. A claim on
+// a key is stored as an empty string, replaced by the response when the request finishes, so a retry sent
+// to another instance is told to wait, and then gets the response.
+type IdempotencyStore struct {
+	client Client
+	prefix string
+}
+
+var _ toolkit.IdempotencyStore = (*IdempotencyStore)(nil)
+
+// idempotencyStartScript returns the value of KEYS[1] if it has one, or else claims it for ARGV[1]
+// milliseconds and returns nil.
+const idempotencyStartScript = `
+local v = redis.call('GET', KEYS[1])
+if v then
+  return v
+end
+redis.call('SET', KEYS[1], '', 'PX', ARGV[1])
+return false
+`
+
+// idempotencyCancelScript deletes KEYS[1] if it holds a claim rather than a response.
+const idempotencyCancelScript = `
+if redis.call('GET', KEYS[1]) == '' then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+// Start returns the response stored for key, if there is one, or else claims key for ttl.
+func (s *IdempotencyStore) Start(ctx context.Context, key string, ttl time.Duration) (*toolkit.IdempotentResponse, bool, error) {
+	reply, err := eval(ctx, s.client, idempotencyStartScript, []string{s.prefix + key}, ttl.Milliseconds())
+	if err != nil {
+		return nil, false, err
+	}
+
+	v, ok, err := replyString(reply)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case !ok:
+		return nil, true, nil
+	case v == "":
+		// another request holds the claim
+		return nil, false, nil
+	}
+
+	var resp toolkit.IdempotentResponse
+	if err := json.Unmarshal([]byte(v), &resp); err != nil {
+		return nil, false, fmt.Errorf("toolkitredis: decoding stored response: %w", err)
+	}
+	return &resp, false, nil
+}
+
+// Finish stores resp for key, in place of the claim.
+func (s *IdempotencyStore) Finish(ctx context.Context, key string, resp *toolkit.IdempotentResponse, ttl time.Duration) error {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Do(ctx, "SET", s.prefix+key, b, "PX", ttl.Milliseconds())
+	return err
+}
+
+// Cancel releases the claim on key. A stored response is left alone.
+func (s *IdempotencyStore) Cancel(ctx context.Context, key string) error {
+	_, err := eval(ctx, s.client, idempotencyCancelScript, []string{s.prefix + key})
+	return err
+}
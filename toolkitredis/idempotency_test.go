@@ -0,0 +1,71 @@
+package toolkitredis
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	fake := newFakeRedis()
+	store := New(fake, Options{}).Idempotency
+	ctx := context.Background()
+
+	if resp, claimed, err := store.Start(ctx, "a", time.Minute); resp != nil || !claimed || err != nil {
+		t.Fatalf("expected the key to be claimed, got %v, %v", resp, err)
+	}
+	if _, claimed, _ := store.Start(ctx, "a", time.Minute); claimed {
+		t.Fatal("expected the key not to be claimed twice")
+	}
+
+	_ = store.Cancel(ctx, "a")
+	if _, claimed, _ := store.Start(ctx, "a", time.Minute); !claimed {
+		t.Fatal("expected the key to be claimed again after cancelling")
+	}
+
+	stored := &toolkit.IdempotentResponse{Status: http.StatusCreated, Header: http.Header{"Location": {"/payments/1"}}, Body: []byte(`{"id":1}`), Fingerprint: "f"}
+	if err := store.Finish(ctx, "a", stored, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	_ = store.Cancel(ctx, "a")
+	resp, claimed, err := store.Start(ctx, "a", time.Minute)
+	if err != nil || claimed || resp == nil || resp.Status != http.StatusCreated || string(resp.Body) != `{"id":1}` || resp.Header.Get("Location") != "/payments/1" {
+		t.Fatalf("expected the stored response, got %+v, %v", resp, err)
+	}
+
+	// an abandoned claim expires
+	_, _, _ = store.Start(ctx, "b", time.Minute)
+	fake.now = fake.now.Add(2 * time.Minute)
+	if _, claimed, _ := store.Start(ctx, "b", time.Minute); !claimed {
+		t.Error("expected an expired claim to be replaced")
+	}
+}
+
+func TestIdempotencyStore_Middleware(t *testing.T) {
+	var tools toolkit.Tools
+	stores := New(newFakeRedis(), Options{})
+	calls := 0
+	handler := tools.Idempotency(toolkit.IdempotencyOptions{Store: stores.Idempotency})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_ = tools.Created(w, "/payments/1", map[string]int{"call": calls})
+	}))
+
+	for i := 0; i < 2; i++ {
+		request := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{"amount":10}`))
+		request.Header.Set("Idempotency-Key", "k")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+
+		if rr.Code != http.StatusCreated || rr.Body.String() != `{"call":1}` {
+			t.Errorf("request %d: expected the first response, got %d %s", i, rr.Code, rr.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the handler to run once, got %d", calls)
+	}
+}
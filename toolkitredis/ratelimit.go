@@ -0,0 +1,68 @@
+package toolkitredis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+// RateLimitStore is a toolkit.RateLimitStore keeping token buckets in Redis hashes, updated atomically
+// by a script using the server's clock, so every instance sees the same buckets. Buckets expire once they
+// would have refilled completely.
+type RateLimitStore struct {
+	client Client
+	prefix string
+}
+
+var _ toolkit.RateLimitStore = (*RateLimitStore)(nil)
+
+// rateLimitScript takes a token from the bucket in KEYS[1], refilling at ARGV[1] tokens per second up to
+// ARGV[2], and returns whether it could, and if not, the milliseconds until it can.
+const rateLimitScript = `
+redis.replicate_commands()
+local rate, burst = tonumber(ARGV[1]), tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens, ts = tonumber(b[1]) or burst, tonumber(b[2]) or now
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+end
+local allowed, wait = 0, 3600000
+if tokens >= 1 then
+  tokens, allowed, wait = tokens - 1, 1, 0
+elseif rate > 0 then
+  wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+if rate > 0 then
+  redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+else
+  redis.call('PEXPIRE', KEYS[1], 3600000)
+end
+return {allowed, wait}
+`
+
+// Allow takes a token from the bucket for key, creating a full bucket if there isn't one.
+func (s *RateLimitStore) Allow(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	reply, err := eval(ctx, s.client, rateLimitScript, []string{s.prefix + key}, rate, burst)
+	if err != nil {
+		return false, 0, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("toolkitredis: unexpected rate limit reply %v", reply)
+	}
+	allowed, err := replyInt(values[0])
+	if err != nil {
+		return false, 0, err
+	}
+	wait, err := replyInt(values[1])
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
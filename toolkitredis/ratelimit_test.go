@@ -0,0 +1,44 @@
+package toolkitredis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimitStore_Allow(t *testing.T) {
+	fake := newFakeRedis()
+	store := New(fake, Options{}).RateLimit
+	ctx := context.Background()
+
+	// the burst is allowed straight away
+	for i := 0; i < 2; i++ {
+		if allowed, _, err := store.Allow(ctx, "k", 1, 2); !allowed || err != nil {
+			t.Fatalf("request %d: expected to be allowed, got %v", i, err)
+		}
+	}
+
+	allowed, wait, err := store.Allow(ctx, "k", 1, 2)
+	if allowed || err != nil || wait != time.Second {
+		t.Errorf("expected to wait a second, got %t, %s, %v", allowed, wait, err)
+	}
+
+	fake.now = fake.now.Add(time.Second)
+	if allowed, _, _ := store.Allow(ctx, "k", 1, 2); !allowed {
+		t.Error("expected a token after a second")
+	}
+
+	// other keys have buckets of their own
+	if allowed, _, _ := store.Allow(ctx, "other", 1, 2); !allowed {
+		t.Error("expected another key to be allowed")
+	}
+}
+
+func TestRateLimitStore_Allow_BadReply(t *testing.T) {
+	client := ClientFunc(func(ctx context.Context, args ...any) (any, error) {
+		return "OK", nil
+	})
+	if _, _, err := New(client, Options{}).RateLimit.Allow(context.Background(), "k", 1, 1); err == nil {
+		t.Error("expected an error for an unexpected reply")
+	}
+}
@@ -0,0 +1,96 @@
+// Package toolkitredis implements the toolkit's store interfaces on top of Redis, so caches, rate limits
+// and idempotent responses are shared between the instances of a service. It doesn't depend on a Redis
+// client library: anything able to send a command and return its reply, such as go-redis or redigo, is
+// wrapped in a Client with a few lines.
+package toolkitredis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Client sends a command, such as "SET", "key", "value", to Redis and returns its reply: an int64 for
+// integers, a string or []byte for strings, a []any for arrays, and nil, with a nil error, for a nil reply.
+// With go-redis, whose Result returns redis.Nil as an error for nil replies:
+//
+//	client := toolkitredis.ClientFunc(func(ctx context.Context, args ...any) (any, error) {
+//		v, err := rdb.Do(ctx, args...).Result()
+//		if errors.Is(err, redis.Nil) {
+//			return nil, nil
+//		}
+//		return v, err
+//	})
+type Client interface {
+	Do(ctx context.Context, args ...any) (any, error)
+}
+
+// ClientFunc is an adapter allowing an ordinary function to be used as a Client.
+type ClientFunc func(ctx context.Context, args ...any) (any, error)
+
+// Do calls f(ctx, args...).
+func (f ClientFunc) Do(ctx context.Context, args ...any) (any, error) {
+	return f(ctx, args...)
+}
+
+// Options configures the stores returned by New.
+type Options struct {
+	Prefix string // prefix of every key the stores use, to share a database with other data; defaults to "toolkit:"
+}
+
+// Stores holds a store for each of the toolkit's store interfaces, all using the same client.
+type Stores struct {
+	Cache       *Cache            // a toolkit.Cache, for Remember and GetOrSet
+	RateLimit   *RateLimitStore   // for RateLimitOptions.Store
+	Idempotency *IdempotencyStore // for IdempotencyOptions.Store
+}
+
+// New returns the stores backed by client.
+func New(client Client, opts Options) *Stores {
+	if opts.Prefix == "" {
+		opts.Prefix = "toolkit:"
+	}
+	return &Stores{
+		Cache:       &Cache{client: client, prefix: opts.Prefix + "cache:"},
+		RateLimit:   &RateLimitStore{client: client, prefix: opts.Prefix + "ratelimit:"},
+		Idempotency: &IdempotencyStore{client: client, prefix: opts.Prefix + "idempotency:"},
+	}
+}
+
+// eval runs a Lua script with EVAL.
+func eval(ctx context.Context, client Client, script string, keys []string, args ...any) (any, error) {
+	cmd := make([]any, 0, 3+len(keys)+len(args))
+	cmd = append(cmd, "EVAL", script, len(keys))
+	for _, k := range keys {
+		cmd = append(cmd, k)
+	}
+	return client.Do(ctx, append(cmd, args...)...)
+}
+
+// replyInt returns an integer reply as an int64.
+func replyInt(v any) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	}
+	return 0, fmt.Errorf("toolkitredis: unexpected reply %T, expected an integer", v)
+}
+
+// replyString returns a string reply, and false for a nil reply.
+func replyString(v any) (string, bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		return v, true, nil
+	case []byte:
+		return string(v), true, nil
+	}
+	return "", false, fmt.Errorf("toolkitredis: unexpected reply %T, expected a string", v)
+}
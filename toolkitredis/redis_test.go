@@ -0,0 +1,160 @@
+package toolkitredis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a Client keeping strings and rate limit buckets in memory, which runs the package's scripts
+// by doing in Go what they do in Lua.
+type fakeRedis struct {
+	mu       sync.Mutex
+	now      time.Time
+	values   map[string]string
+	expires  map[string]time.Time
+	buckets  map[string][2]float64 // tokens, and the time of the last refill in milliseconds
+	commands [][]any
+	err      error
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		now:     time.Unix(1700000000, 0),
+		values:  make(map[string]string),
+		expires: make(map[string]time.Time),
+		buckets: make(map[string][2]float64),
+	}
+}
+
+func (f *fakeRedis) Do(ctx context.Context, args ...any) (any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.commands = append(f.commands, args)
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	switch args[0] {
+	case "SET":
+		key := args[1].(string)
+		f.values[key] = string(args[2].([]byte))
+		delete(f.expires, key)
+		if len(args) == 5 {
+			f.expires[key] = f.now.Add(time.Duration(args[4].(int64)) * time.Millisecond)
+		}
+		return "OK", nil
+	case "GET":
+		key := args[1].(string)
+		f.expire(key)
+		if v, ok := f.values[key]; ok {
+			return v, nil
+		}
+		return nil, nil
+	case "DEL":
+		key := args[1].(string)
+		_, ok := f.values[key]
+		delete(f.values, key)
+		delete(f.expires, key)
+		if ok {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case "PTTL":
+		key := args[1].(string)
+		f.expire(key)
+		if _, ok := f.values[key]; !ok {
+			return int64(-2), nil
+		}
+		if exp, ok := f.expires[key]; ok {
+			return exp.Sub(f.now).Milliseconds(), nil
+		}
+		return int64(-1), nil
+	case "EVAL":
+		key := args[3].(string)
+		f.expire(key)
+		switch args[1] {
+		case idempotencyStartScript:
+			if v, ok := f.values[key]; ok {
+				return []byte(v), nil
+			}
+			f.values[key] = ""
+			f.expires[key] = f.now.Add(time.Duration(args[4].(int64)) * time.Millisecond)
+			return nil, nil
+		case idempotencyCancelScript:
+			if v, ok := f.values[key]; ok && v == "" {
+				delete(f.values, key)
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case rateLimitScript:
+			return f.allow(key, args[4].(float64), float64(args[5].(int))), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected command %v", args[0])
+}
+
+// expire discards key if it has expired.
+func (f *fakeRedis) expire(key string) {
+	if exp, ok := f.expires[key]; ok && !f.now.Before(exp) {
+		delete(f.values, key)
+		delete(f.expires, key)
+	}
+}
+
+// allow does what rateLimitScript does.
+func (f *fakeRedis) allow(key string, rate, burst float64) []any {
+	now := float64(f.now.UnixMilli())
+	b, ok := f.buckets[key]
+	if !ok {
+		b = [2]float64{burst, now}
+	}
+	tokens := math.Min(burst, b[0]+(now-b[1])*rate/1000)
+	allowed, wait := int64(0), int64(3600000)
+	if tokens >= 1 {
+		tokens, allowed, wait = tokens-1, 1, 0
+	} else if rate > 0 {
+		wait = int64(math.Ceil((1 - tokens) * 1000 / rate))
+	}
+	f.buckets[key] = [2]float64{tokens, now}
+	return []any{allowed, strconv.FormatInt(wait, 10)}
+}
+
+func TestNew(t *testing.T) {
+	fake := newFakeRedis()
+	stores := New(fake, Options{Prefix: "app:"})
+
+	_, _, _ = stores.RateLimit.Allow(context.Background(), "1.2.3.4", 1, 1)
+	_ = stores.Idempotency.Cancel(context.Background(), "k")
+	_ = stores.Cache.Delete(context.Background(), "c")
+
+	for i, expected := range []string{"app:ratelimit:1.2.3.4", "app:idempotency:k", "app:cache:c"} {
+		if key := keyOf(fake.commands[i]); key != expected {
+			t.Errorf("expected key %s, got %v", expected, key)
+		}
+	}
+}
+
+// keyOf returns the key a command is for.
+func keyOf(cmd []any) any {
+	if cmd[0] == "EVAL" {
+		return cmd[3]
+	}
+	return cmd[1]
+}
+
+func TestClientFunc(t *testing.T) {
+	client := ClientFunc(func(ctx context.Context, args ...any) (any, error) {
+		return nil, errors.New("connection refused")
+	})
+	stores := New(client, Options{})
+	if _, _, err := stores.RateLimit.Allow(context.Background(), "k", 1, 1); err == nil {
+		t.Error("expected the client's error")
+	}
+}
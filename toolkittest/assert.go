@@ -0,0 +1,27 @@
+package toolkittest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+// AssertStatus fails the test if the response recorded by rr doesn't have the status code want, showing
+// the body to help work out why.
+func AssertStatus(t testing.TB, rr *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rr.Code != want {
+		t.Errorf("wrong status code; expected %d but got %d, with body %s", want, rr.Code, rr.Body.String())
+	}
+}
+
+// AssertJSONBody fails the test unless the body recorded by rr is JSON equal to the JSON encoding of want.
+// Object keys may be in any order, and whitespace is ignored, so want can be a struct, a map or a
+// json.RawMessage.
+func AssertJSONBody(t testing.TB, rr *httptest.ResponseRecorder, want any) {
+	t.Helper()
+	if !jsonEqual(want, rr.Body.Bytes()) {
+		expected, _ := json.Marshal(want)
+		t.Errorf("wrong JSON body; expected %s but got %s", expected, rr.Body.String())
+	}
+}
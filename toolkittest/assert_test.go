@@ -0,0 +1,64 @@
+package toolkittest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordingTB records failures instead of failing the test, so the assertions themselves can be tested.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertStatus(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.WriteHeader(http.StatusNotFound)
+	_, _ = rr.WriteString("missing")
+
+	var tb recordingTB
+	AssertStatus(&tb, rr, http.StatusNotFound)
+	if len(tb.errors) != 0 {
+		t.Errorf("expected no failure, got %v", tb.errors)
+	}
+
+	AssertStatus(&tb, rr, http.StatusOK)
+	if len(tb.errors) != 1 || tb.errors[0] != "wrong status code; expected 200 but got 404, with body missing" {
+		t.Errorf("wrong failure %v", tb.errors)
+	}
+}
+
+func TestAssertJSONBody(t *testing.T) {
+	rr := httptest.NewRecorder()
+	_, _ = rr.WriteString(`{"error": false, "message": "ok", "data": {"id": 1}}`)
+
+	tests := []struct {
+		name string
+		want any
+		ok   bool
+	}{
+		{name: "map", want: map[string]any{"message": "ok", "error": false, "data": map[string]int{"id": 1}}, ok: true},
+		{name: "raw", want: json.RawMessage(`{"data":{"id":1},"error":false,"message":"ok"}`), ok: true},
+		{name: "struct", want: struct {
+			Error   bool   `json:"error"`
+			Message string `json:"message"`
+		}{Message: "ok"}, ok: false},
+		{name: "different", want: map[string]any{"message": "no", "error": false, "data": map[string]int{"id": 1}}, ok: false},
+	}
+	for _, e := range tests {
+		var tb recordingTB
+		AssertJSONBody(&tb, rr, e.want)
+		if (len(tb.errors) == 0) != e.ok {
+			t.Errorf("%s: expected ok %v, got %v", e.name, e.ok, tb.errors)
+		}
+	}
+}
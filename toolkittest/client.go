@@ -0,0 +1,19 @@
+package toolkittest
+
+import "net/http"
+
+// RoundTripFunc is an http.RoundTripper backed by a function, so tests can answer requests without a server.
+type RoundTripFunc func(req *http.Request) *http.Response
+
+// RoundTrip implements http.RoundTripper.
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// NewTestClient returns an *http.Client whose requests are answered by fn, for passing to
+// PushJSONToRemote and the other remote helpers.
+func NewTestClient(fn RoundTripFunc) *http.Client {
+	return &http.Client{
+		Transport: fn,
+	}
+}
@@ -0,0 +1,35 @@
+package toolkittest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+func TestNewTestClient(t *testing.T) {
+	var got string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		body, _ := io.ReadAll(req.Body)
+		got = string(body)
+		return &http.Response{
+			StatusCode: http.StatusAccepted,
+			Body:       io.NopCloser(bytes.NewBufferString("ok")),
+			Header:     make(http.Header),
+		}
+	})
+
+	var testTools toolkit.Tools
+	_, status, err := testTools.PushJSONToRemote("http://example.com/hooks", map[string]string{"event": "created"}, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if status != http.StatusAccepted {
+		t.Errorf("wrong status code; expected %d but got %d", http.StatusAccepted, status)
+	}
+	if !jsonEqual(map[string]string{"event": "created"}, []byte(got)) {
+		t.Errorf("wrong body sent: %s", got)
+	}
+}
@@ -0,0 +1,248 @@
+// Package toolkittest provides helpers for testing code that uses the toolkit: a stub remote server to
+// point PushJSONToRemote at, a client backed by a function, builders for JSON and multipart requests, and
+// assertions on recorded responses.
+package toolkittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Expectation describes a request the stub expects to receive, and the canned response it sends back when
+// a request matches. Empty matcher fields match anything.
+type Expectation struct {
+	Method   string                                  // the expected method (e.g. POST)
+	Path     string                                  // the expected URL path (e.g. /some/path)
+	JSONBody any                                     // if set, the request body must be JSON equal to this value
+	Match    func(r *http.Request, body []byte) bool // optional custom matcher
+	Status   int                                     // the response status code; defaults to 200
+	Response any                                     // the response body, encoded as JSON unless it is a string or []byte
+	Header   http.Header                             // optional response headers
+	Times    int                                     // the number of times the expectation must be met; 0 means at least once
+	Delay    time.Duration                           // how long to wait before responding, to simulate a slow remote
+	Fail     bool                                    // if set to true, drop the connection instead of responding, to simulate a network failure
+	hits     int
+}
+
+// ReceivedRequest is a request recorded by the stub, with its body already read.
+type ReceivedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// RemoteStub is an httptest server which answers requests according to a list of expectations.
+type RemoteStub struct {
+	*httptest.Server
+	t            testing.TB
+	mu           sync.Mutex
+	expectations []*Expectation
+	requests     []ReceivedRequest
+}
+
+// NewRemoteStub starts a stub server which answers requests using the supplied expectations, checked in
+// order, so a sequence of responses can be scripted with Times: e.g. two failures and then a success. A
+// request which matches no expectation fails the test and receives a 500 response. When the test
+// finishes, the server is closed and any expectation which was not met fails the test.
+func NewRemoteStub(t testing.TB, expectations ...Expectation) *RemoteStub {
+	t.Helper()
+
+	stub := &RemoteStub{t: t}
+	for i := range expectations {
+		e := expectations[i]
+		stub.expectations = append(stub.expectations, &e)
+	}
+
+	stub.Server = httptest.NewServer(http.HandlerFunc(stub.serveHTTP))
+	t.Cleanup(func() {
+		stub.Close()
+		stub.verify()
+	})
+
+	return stub
+}
+
+// Requests returns a copy of every request the stub has received so far.
+func (s *RemoteStub) Requests() []ReceivedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]ReceivedRequest, len(s.requests))
+	copy(out, s.requests)
+	return out
+}
+
+func (s *RemoteStub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.t.Errorf("remote stub: error reading request body: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, ReceivedRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+	var matched *Expectation
+	for _, e := range s.expectations {
+		if e.Times > 0 && e.hits >= e.Times {
+			continue
+		}
+		if e.matches(r, body) {
+			e.hits++
+			matched = e
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if matched == nil {
+		s.t.Errorf("remote stub: unexpected request %s %s with body %s", r.Method, r.URL.Path, body)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if matched.Delay > 0 {
+		select {
+		case <-time.After(matched.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if matched.Fail {
+		dropConnection(s.t, w)
+		return
+	}
+	matched.respond(s.t, w)
+}
+
+// dropConnection closes the connection w writes to without sending a response.
+func dropConnection(t testing.TB, w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		t.Errorf("remote stub: error dropping connection: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = conn.Close()
+}
+
+// AssertCallCount fails the test unless the stub has received exactly want requests.
+func (s *RemoteStub) AssertCallCount(want int) {
+	s.t.Helper()
+	if got := len(s.Requests()); got != want {
+		s.t.Errorf("remote stub: expected %d requests, but received %d", want, got)
+	}
+}
+
+// AssertReceivedJSON fails the test unless a request to path had a body which is JSON equal to want.
+func (s *RemoteStub) AssertReceivedJSON(path string, want any) {
+	s.t.Helper()
+	var bodies []string
+	for _, r := range s.Requests() {
+		if r.Path != path {
+			continue
+		}
+		if jsonEqual(want, r.Body) {
+			return
+		}
+		bodies = append(bodies, string(r.Body))
+	}
+	expected, _ := json.Marshal(want)
+	s.t.Errorf("remote stub: expected a request to %s with body %s, but received %q", path, expected, bodies)
+}
+
+// DecodeJSON decodes the body of the request into v.
+func (r ReceivedRequest) DecodeJSON(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+func (s *RemoteStub) verify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.expectations {
+		switch {
+		case e.Times == 0 && e.hits == 0:
+			s.t.Errorf("remote stub: expected request %s %s was never received", e.Method, e.Path)
+		case e.Times > 0 && e.hits != e.Times:
+			s.t.Errorf("remote stub: expected request %s %s %d times, but received it %d times", e.Method, e.Path, e.Times, e.hits)
+		}
+	}
+}
+
+// matches reports whether the request satisfies every matcher set on the expectation.
+func (e *Expectation) matches(r *http.Request, body []byte) bool {
+	if e.Method != "" && e.Method != r.Method {
+		return false
+	}
+	if e.Path != "" && e.Path != r.URL.Path {
+		return false
+	}
+	if e.JSONBody != nil && !jsonEqual(e.JSONBody, body) {
+		return false
+	}
+	if e.Match != nil && !e.Match(r, body) {
+		return false
+	}
+	return true
+}
+
+// respond writes the canned response for the expectation.
+func (e *Expectation) respond(t testing.TB, w http.ResponseWriter) {
+	for key, val := range e.Header {
+		w.Header()[key] = val
+	}
+
+	var out []byte
+	switch v := e.Response.(type) {
+	case nil:
+	case string:
+		out = []byte(v)
+	case []byte:
+		out = v
+	default:
+		var err error
+		out, err = json.Marshal(v)
+		if err != nil {
+			t.Errorf("remote stub: error encoding response: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+	}
+
+	status := e.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(out)
+}
+
+// jsonEqual reports whether body holds JSON which is semantically equal to the JSON encoding of expected.
+func jsonEqual(expected any, body []byte) bool {
+	want, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+
+	var a, b any
+	if err := json.Unmarshal(want, &a); err != nil {
+		return false
+	}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&b); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,143 @@
+package toolkittest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+func TestNewRemoteStub(t *testing.T) {
+	stub := NewRemoteStub(t,
+		Expectation{
+			Method:   http.MethodPost,
+			Path:     "/some/path",
+			JSONBody: map[string]string{"bar": "bar"},
+			Status:   http.StatusCreated,
+			Response: map[string]string{"id": "1"},
+		},
+		Expectation{
+			Method: http.MethodPost,
+			Path:   "/other/path",
+			Status: http.StatusAccepted,
+			Times:  2,
+		},
+	)
+
+	var testTools toolkit.Tools
+	var foo struct {
+		Bar string `json:"bar"`
+	}
+	foo.Bar = "bar"
+
+	_, status, err := testTools.PushJSONToRemote(stub.URL+"/some/path", foo)
+	if err != nil {
+		t.Fatalf("failed to call remote url: %s", err)
+	}
+	if status != http.StatusCreated {
+		t.Errorf("wrong status code; expected %d but got %d", http.StatusCreated, status)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, status, err = testTools.PushJSONToRemote(stub.URL+"/other/path", foo)
+		if err != nil {
+			t.Fatalf("failed to call remote url: %s", err)
+		}
+		if status != http.StatusAccepted {
+			t.Errorf("wrong status code; expected %d but got %d", http.StatusAccepted, status)
+		}
+	}
+
+	if len(stub.Requests()) != 3 {
+		t.Errorf("wrong number of requests recorded; expected 3 but got %d", len(stub.Requests()))
+	}
+}
+
+func TestRemoteStub_Script(t *testing.T) {
+	stub := NewRemoteStub(t,
+		Expectation{Path: "/hooks", Fail: true, Times: 1},
+		Expectation{Path: "/hooks", Status: http.StatusServiceUnavailable, Times: 1},
+		Expectation{Path: "/hooks", Status: http.StatusAccepted, Response: map[string]string{"id": "7"}, Times: 1},
+	)
+
+	var testTools toolkit.Tools
+	var out struct {
+		ID string `json:"id"`
+	}
+	status, err := testTools.PushJSONToRemoteInto(stub.URL+"/hooks", map[string]string{"event": "created"}, &out,
+		toolkit.RemoteOptions{Retries: 2, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if status != http.StatusAccepted || out.ID != "7" {
+		t.Errorf("expected the scripted success, got %d and %+v", status, out)
+	}
+
+	stub.AssertCallCount(3)
+	stub.AssertReceivedJSON("/hooks", map[string]string{"event": "created"})
+
+	var payload map[string]string
+	if err := stub.Requests()[0].DecodeJSON(&payload); err != nil || payload["event"] != "created" {
+		t.Errorf("expected the recorded payload, got %v and %v", payload, err)
+	}
+}
+
+func TestRemoteStub_Delay(t *testing.T) {
+	stub := NewRemoteStub(t, Expectation{Path: "/slow", Delay: 200 * time.Millisecond})
+
+	var testTools toolkit.Tools
+	_, _, err := testTools.PushJSONToRemote(stub.URL+"/slow", map[string]string{}, &http.Client{Timeout: 50 * time.Millisecond})
+	if err == nil {
+		t.Fatal("expected the call to time out")
+	}
+
+	start := time.Now()
+	_, status, err := testTools.PushJSONToRemote(stub.URL+"/slow", map[string]string{})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected result %d, %v", status, err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected the response to be delayed, but it took %s", elapsed)
+	}
+}
+
+func TestRemoteStub_Assertions(t *testing.T) {
+	stub := NewRemoteStub(t, Expectation{Path: "/hooks"})
+	var testTools toolkit.Tools
+	if _, _, err := testTools.PushJSONToRemote(stub.URL+"/hooks", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	tb := &recordingTB{TB: t}
+	stub.t = tb
+	stub.AssertCallCount(2)
+	stub.AssertReceivedJSON("/hooks", map[string]int{"n": 2})
+	stub.AssertReceivedJSON("/hooks", map[string]int{"n": 1})
+	stub.t = t
+
+	if len(tb.errors) != 2 || tb.errors[0] != "remote stub: expected 2 requests, but received 1" ||
+		!strings.HasPrefix(tb.errors[1], `remote stub: expected a request to /hooks with body {"n":2}, but received`) {
+		t.Errorf("wrong failures %q", tb.errors)
+	}
+}
+
+var jsonEqualTests = []struct {
+	name     string
+	expected any
+	body     string
+	equal    bool
+}{
+	{name: "same", expected: map[string]any{"a": 1, "b": "c"}, body: `{"b":"c","a":1}`, equal: true},
+	{name: "different value", expected: map[string]any{"a": 1}, body: `{"a":2}`, equal: false},
+	{name: "invalid json", expected: map[string]any{"a": 1}, body: `{"a":`, equal: false},
+}
+
+func TestJSONEqual(t *testing.T) {
+	for _, e := range jsonEqualTests {
+		if jsonEqual(e.expected, []byte(e.body)) != e.equal {
+			t.Errorf("%s: expected %v", e.name, e.equal)
+		}
+	}
+}
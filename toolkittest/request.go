@@ -0,0 +1,76 @@
+package toolkittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+// JSONRequest returns a test request with body encoded as JSON, and a Content-Type of application/json. A
+// nil body sends no body at all. It fails the test if body can't be encoded.
+func JSONRequest(t testing.TB, method, target string, body any) *http.Request {
+	t.Helper()
+
+	if body == nil {
+		return httptest.NewRequest(method, target, nil)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("toolkittest: error encoding request body: %s", err)
+	}
+	r := httptest.NewRequest(method, target, bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+// MultipartRequestBuilder builds a multipart/form-data test request, such as an upload for UploadFiles.
+type MultipartRequestBuilder struct {
+	method string
+	target string
+	files  []toolkit.MultipartFile
+	fields map[string]string
+}
+
+// NewMultipartRequest starts building a multipart request to target, e.g. "/upload".
+func NewMultipartRequest(method, target string) *MultipartRequestBuilder {
+	return &MultipartRequestBuilder{method: method, target: target, fields: make(map[string]string)}
+}
+
+// File adds the file at path as a part named field.
+func (b *MultipartRequestBuilder) File(field, path string) *MultipartRequestBuilder {
+	b.files = append(b.files, toolkit.MultipartFile{FieldName: field, Path: path})
+	return b
+}
+
+// FileBytes adds content, sent as a file called fileName, as a part named field.
+func (b *MultipartRequestBuilder) FileBytes(field, fileName string, content []byte) *MultipartRequestBuilder {
+	b.files = append(b.files, toolkit.MultipartFile{FieldName: field, FileName: fileName, Content: bytes.NewReader(content)})
+	return b
+}
+
+// Field adds a plain form field.
+func (b *MultipartRequestBuilder) Field(name, value string) *MultipartRequestBuilder {
+	b.fields[name] = value
+	return b
+}
+
+// Build returns the request, with the body held in memory. It fails the test if a file can't be read.
+func (b *MultipartRequestBuilder) Build(t testing.TB) *http.Request {
+	t.Helper()
+
+	var tools toolkit.Tools
+	body, contentType := tools.BuildMultipartBody(b.files, b.fields)
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("toolkittest: error building multipart body: %s", err)
+	}
+
+	r := httptest.NewRequest(b.method, b.target, bytes.NewReader(data))
+	r.Header.Set("Content-Type", contentType)
+	return r
+}
@@ -0,0 +1,62 @@
+package toolkittest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rozdolsky33/toolkit"
+)
+
+func TestJSONRequest(t *testing.T) {
+	r := JSONRequest(t, http.MethodPost, "/items", map[string]any{"name": "widget"})
+	if r.Method != http.MethodPost || r.URL.Path != "/items" {
+		t.Errorf("wrong request %s %s", r.Method, r.URL.Path)
+	}
+	if r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("wrong Content-Type %q", r.Header.Get("Content-Type"))
+	}
+
+	var testTools toolkit.Tools
+	var in struct {
+		Name string `json:"name"`
+	}
+	if err := testTools.ReadJSON(httptest.NewRecorder(), r, &in); err != nil || in.Name != "widget" {
+		t.Errorf("expected the body to be read back, got %+v and %v", in, err)
+	}
+
+	r = JSONRequest(t, http.MethodGet, "/items", nil)
+	if b, _ := io.ReadAll(r.Body); len(b) != 0 || r.Header.Get("Content-Type") != "" {
+		t.Errorf("expected no body, got %q", b)
+	}
+}
+
+func TestMultipartRequestBuilder(t *testing.T) {
+	r := NewMultipartRequest(http.MethodPost, "/upload").
+		File("file", "../testdata/img.png").
+		FileBytes("file", "notes.txt", []byte("hello")).
+		Field("title", "holiday").
+		Build(t)
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := r.FormValue("title"); got != "holiday" {
+		t.Errorf("wrong field value %q", got)
+	}
+	files := r.MultipartForm.File["file"]
+	if len(files) != 2 || files[0].Filename != "img.png" || files[1].Filename != "notes.txt" || files[1].Size != 5 {
+		t.Fatalf("wrong files %+v", files)
+	}
+
+	dir := t.TempDir()
+	var testTools toolkit.Tools
+	uploaded, err := testTools.UploadFiles(NewMultipartRequest(http.MethodPost, "/upload").File("file", "../testdata/img.png").Build(t), dir)
+	if err != nil || len(uploaded) != 1 {
+		t.Fatalf("expected the upload to be stored, got %v and %v", uploaded, err)
+	}
+	if uploaded[0].OriginalFileName != "img.png" {
+		t.Errorf("wrong uploaded file %+v", uploaded[0])
+	}
+}
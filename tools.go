@@ -2,37 +2,135 @@ package toolkit
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"os"
-	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// maxPooledBufferSize buffers which have grown larger than this are not returned to the pool
+const maxPooledBufferSize = 64 * 1024
+
+// bufferPool holds buffers reused by the encoding fast paths.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns a buffer to the pool, unless it has grown too large to be worth keeping.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
 // randomStringSource defines the character set used for generating random strings.
 const randomStringSource = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVXWYZ0123456789_+"
 
 // defaultMaxUpload the default max upload size (10 mb)
 const defaultMaxUpload = 10485760
 
+// defaultMaxJSONArraySize the default max size of a body streamed by ReadJSONArray (1 gb)
+const defaultMaxJSONArraySize = 1073741824
+
+// defaultMaxSlugLength the default maximum length of a string passed to Slugify
+const defaultMaxSlugLength = 2048
+
+// defaultMaxXMLAttributes the default maximum number of attributes on a single XML element
+const defaultMaxXMLAttributes = 256
+
+// defaultMaxXMLDepth the default maximum nesting depth of XML elements
+const defaultMaxXMLDepth = 256
+
+// defaultMaxXMLTokens the default maximum number of tokens in an XML document
+const defaultMaxXMLTokens = 1000000
+
+// defaultMaxMultipartParts the default maximum number of parts in a multipart form
+const defaultMaxMultipartParts = 1000
+
 // Tools is the type used to instantiate this module. Any variable of this type will have access
 // to all the methods with the receiver *Tools.
 type Tools struct {
-	MaxJSONSize        int         // maximum size of JSON file we'll process
-	MaxXMLSize         int         // maximum size of XML file we'll process
-	MaxFileSize        int         // maximum size of uploaded files in bytes
-	AllowedFileTypes   []string    // allowed file types for upload (e.g. image/jpeg)
-	AllowUnknownFields bool        // if set to true, allow unknown fields in JSON
-	ErrorLog           *log.Logger // the info log.
-	InfoLog            *log.Logger // the error log.
+	Limits               Limits                           // limits applied to every request format; each one set takes precedence over the older field for the format
+	MaxJSONSize          int                              // maximum size of JSON file we'll process
+	MaxXMLSize           int                              // maximum size of XML file we'll process
+	MaxFormSize          int                              // maximum size of a form body ReadForm will process
+	MaxJSONArraySize     int                              // maximum size of a body ReadJSONArray will stream; each element is limited by MaxJSONSize
+	MaxDecompressedSize  int                              // maximum size of a gzip or deflate request body once decompressed; defaults to the read limit
+	MaxFileSize          int                              // maximum size of uploaded files in bytes
+	MaxFiles             int                              // maximum number of files in a request to UploadFiles; 0 means no limit
+	MaxTotalUploadSize   int64                            // maximum size of all the files in a request to UploadFiles together, in bytes; 0 means no limit
+	DuplicateChecker     DuplicateChecker                 // if set, uploads whose content is already stored are not stored again, and the existing file is returned
+	MetadataStore        MetadataStore                    // if set, receives the metadata of each upload
+	RejectEncryptedPDFs  bool                             // if set to true, UploadFiles rejects password-protected and other encrypted PDFs
+	MediaProber          MediaProber                      // if set, probes audio and video uploads for their duration, codecs, resolution and bit rate into UploadedFile.Media
+	FileScanner          FileScanner                      // if set, checks the content of each upload, e.g. for viruses, before it is stored
+	QuarantineDir        string                           // if set, uploads rejected by validation or FileScanner are kept here for review rather than discarded
+	Converters           []Converter                      // if set, convert uploads of the types they handle into other formats before they are stored, in order
+	OriginalsDir         string                           // if set, the originals of uploads changed by Converters are kept here
+	ExtractImageMetadata bool                             // if set to true, UploadFiles reads the dimensions and EXIF data of JPEG, PNG and GIF uploads into UploadedFile.Image
+	ImageMetadataOptions ImageMetadataOptions             // options used to extract the metadata of image uploads, e.g. to include the GPS position
+	WriteUploadMetadata  bool                             // if set to true, write each upload's metadata to a JSON file next to it, named <file>.meta.json
+	RollbackUploads      bool                             // if set to true, UploadFiles removes the files it stored for a request when one of its files fails
+	SyncUploadDir        bool                             // if set to true, sync the upload directory to disk after each file is stored, so it survives a crash
+	MinFreeDiskSpace     uint64                           // if set, UploadFiles refuses uploads with 507 Insufficient Storage unless the upload directory keeps this many bytes free
+	MinFreeDiskPercent   float64                          // if set, UploadFiles refuses uploads with 507 Insufficient Storage unless the upload directory keeps this percentage of its file system free
+	MultipartMemoryLimit int                              // bytes of a multipart form held in memory before spilling to temp files; defaults to MaxFileSize
+	AllowedFileTypes     []string                         // allowed file types for upload (e.g. image/jpeg, or image/* for any image)
+	AllowedTypes         []TypeRule                       // allowed file types with optional per-type size limits; used alongside AllowedFileTypes
+	FileNames            FileNamePolicy                   // how the extensions of uploads are cleaned up, and which are allowed, whether or not they are renamed
+	FileSignatures       []FileSignature                  // extra magic numbers used to detect file types, with optional per-type size limits; checked before the built-in ones
+	AllowUnknownFields   bool                             // if set to true, allow unknown fields in JSON
+	MaxSlugLength        int                              // maximum length of a string Slugify will accept; 0 means no limit
+	SlugTransliterator   func(r rune) string              // optional fallback transliteration used by Slugify (e.g. Chinese characters to pinyin)
+	MaxXMLAttributes     int                              // maximum number of attributes allowed on a single XML element; 0 means no limit
+	MaxXMLDepth          int                              // maximum nesting depth of elements in an XML document; 0 means no limit
+	MaxXMLTokens         int                              // maximum number of tokens (elements, text, comments...) in an XML document; 0 means no limit
+	AllowXMLDTD          bool                             // if set to true, accept XML documents with a DOCTYPE or other directives; entities they declare are never expanded
+	XMLCharsetReader     XMLCharsetReader                 // converts non-UTF-8 XML documents to UTF-8; defaults to one handling US-ASCII, ISO-8859-1 and windows-1252
+	MaxMultipartParts    int                              // maximum number of parts (files and fields) in a multipart form; 0 means no limit
+	EncryptionKeys       []EncryptionKey                  // keys used by Encrypt and Decrypt; the first key is used to encrypt
+	URLSigningKey        []byte                           // secret used by SignURL and VerifySignedURL
+	S3                   S3Config                         // region, credentials and endpoint used by GeneratePresignedUpload and VerifyPresignedUpload
+	TrustedProxies       []string                         // IP addresses or CIDR ranges of proxies whose forwarding headers ClientIP trusts
+	HTTPClient           *http.Client                     // client used by the remote helpers when none is given; defaults to a shared client built by NewHTTPClient
+	JSONOptions          JSONOptions                      // indentation, escaping, time format and null handling used when writing JSON
+	Redactor             Redactor                         // masks sensitive fields in logs, logged request bodies and debug output; the zero value masks password, token, ssn and the like
+	DebugMode            bool                             // if set to true, error responses include diagnostics, such as why a value couldn't be encoded as JSON; don't use in production
+	ErrorHandler         ErrorHandler                     // if set, called with every error response before it is sent, to report, redact or re-envelope it
+	Translator           Translator                       // translations of request error messages, used by ErrorJSONLocalized; errors are sent in English if nil
+	Locales              []string                         // locales Translator has messages for, e.g. "en", "pt-BR"; the first is used when the client accepts none of them
+	LocaleFormats        map[string]LocaleFormat          // number, currency and date formats by locale, added to or replacing the built-in ones
+	LogHandler           slog.Handler                     // structured log handler used by LogDebug, LogInfo, LogWarn and LogError; falls back to InfoLog and ErrorLog when nil
+	ErrorLog             *log.Logger                      // the error log; used for warnings and errors when LogHandler is nil
+	InfoLog              *log.Logger                      // the info log; used for info messages when LogHandler is nil
+	jsonMarshalers       map[reflect.Type]JSONMarshalFunc // custom renderers registered with RegisterJSONMarshaler
 }
 
 // JSONResponse is the type used for sending JSON around.
@@ -54,26 +152,57 @@ type UploadedFile struct {
 	NewFileName      string
 	OriginalFileName string
 	FileSize         int64
+	ContentType      string         // the detected MIME type of the file
+	SHA256           string         // hex-encoded hash of the content; set when DuplicateChecker, MetadataStore or WriteUploadMetadata is used
+	Duplicate        bool           // true if the content was already stored as NewFileName, so nothing new was written
+	Image            *ImageMetadata // the dimensions and EXIF data of an image; set when ExtractImageMetadata is set
+	Media            *MediaInfo     // the duration, codecs, resolution and bit rate of audio or video; set when MediaProber is set
+	ConvertedFrom    string         // the detected type of the file as received, if a Converter changed its format
+	OriginalCopy     string         // the name of the file as received in OriginalsDir; set when it was converted and OriginalsDir is set
 }
 
 // New returns a new toolbox with sensible defaults.
 func New() Tools {
 	return Tools{
-		MaxJSONSize: defaultMaxUpload,
-		MaxXMLSize:  defaultMaxUpload,
-		MaxFileSize: defaultMaxUpload,
-		InfoLog:     log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
-		ErrorLog:    log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+		MaxJSONSize:       defaultMaxUpload,
+		MaxXMLSize:        defaultMaxUpload,
+		MaxFormSize:       defaultMaxUpload,
+		MaxJSONArraySize:  defaultMaxJSONArraySize,
+		MaxFileSize:       defaultMaxUpload,
+		MaxSlugLength:     defaultMaxSlugLength,
+		MaxXMLAttributes:  defaultMaxXMLAttributes,
+		MaxXMLDepth:       defaultMaxXMLDepth,
+		MaxXMLTokens:      defaultMaxXMLTokens,
+		MaxMultipartParts: defaultMaxMultipartParts,
+		InfoLog:           log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+		ErrorLog:          log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
 	}
 }
 
 // RandomString returns a string of random characters of length n, using randomStringSource as the source for the string
 func (t *Tools) RandomString(n int) string {
-	s, r := make([]rune, n), []rune(randomStringSource)
-	for i := range s {
-		p, _ := rand.Prime(rand.Reader, len(r))
-		x, y := p.Uint64(), uint64(len(r))
-		s[i] = r[x%y]
+	if n <= 0 {
+		return ""
+	}
+
+	s, r := make([]byte, n), randomStringSource
+
+	// Read random bytes in batches rather than one call per character, and reject any byte at or above
+	// limit, so that every character in the source is equally likely.
+	limit := 256 - 256%len(r)
+	buf := make([]byte, n+n/2)
+	for i := 0; i < n; {
+		_, _ = rand.Read(buf)
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			s[i] = r[int(b)%len(r)]
+			i++
+			if i == n {
+				break
+			}
+		}
 	}
 	return string(s)
 }
@@ -98,29 +227,98 @@ func (t *Tools) UploadOneFile(r *http.Request, uploadDir string, rename ...bool)
 // UploadFiles uploads one or more file to a specified directory, and gives the files a random name.
 // It returns a slice containing the newly named files, the original file names, the size of the files,
 // and potentially an error. If the optional last parameter is set to true, then we will not rename
-// the files, but will use the original file names.
+// the files, but will use the original file names. Requests for a tenant resolved by TenantResolver are
+// stored in the tenant's subdirectory of uploadDir. Files are stored in the order of their form fields; if
+// one fails, the rest are skipped and an *UploadError reports the outcome of each. The files stored before
+// the failure are returned, or removed if RollbackUploads is set.
 func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) ([]*UploadedFile, error) {
 	renameFile := true
 	if len(rename) > 0 {
 		renameFile = rename[0]
 	}
+	return t.uploadFiles(r, uploadDir, renameFile, nil)
+}
+
+// uploadFiles stores the files of r in uploadDir, as UploadFiles does. If only is not nil, just the files
+// sent in its field are stored, once they pass its checks.
+func (t *Tools) uploadFiles(r *http.Request, uploadDir string, renameFile bool, only *fieldUpload) ([]*UploadedFile, error) {
 	var uploadedFiles []*UploadedFile
+	uploadDir = tenantDir(r.Context(), uploadDir)
 	if t.MaxFileSize == 0 {
 		t.MaxFileSize = 1024 * 1024 * 1024 // 1Gb
 	}
 
+	if err := t.checkHeaders(r); err != nil {
+		return nil, err
+	}
+	if t.Limits.MaxBody > 0 {
+		r.Body = http.MaxBytesReader(nil, r.Body, int64(t.Limits.MaxBody))
+	}
+
 	err := t.CreateDirIfNotExist(uploadDir)
 	if err != nil {
 		return nil, err
 	}
 
-	err = r.ParseMultipartForm(int64(t.MaxFileSize))
+	// Refuse the upload before reading it if it would leave too little disk space.
+	if err := t.checkDiskSpace(uploadDir, r.ContentLength); err != nil {
+		return nil, err
+	}
+
+	// Hold at most MultipartMemoryLimit bytes of the form in memory, spilling the rest to temporary files.
+	// If it isn't set, fall back to MaxFileSize as before.
+	memoryLimit := t.MaxFileSize
+	if t.MultipartMemoryLimit > 0 {
+		memoryLimit = t.MultipartMemoryLimit
+	}
+
+	// Stop parsing as soon as the form has too many parts or files, or too many bytes in them.
+	stopLimit := t.limitUploadBody(r)
+	err = r.ParseMultipartForm(int64(memoryLimit))
+	stopLimit()
+	if errors.Is(err, ErrTooManyFiles) || errors.Is(err, ErrTotalUploadTooLarge) {
+		var limitErr *HTTPError
+		errors.As(err, &limitErr)
+		return nil, limitErr
+	}
+	var partsErr *partsLimitError
+	if errors.As(err, &partsErr) {
+		return nil, partsErr
+	}
 	if err != nil {
 		return nil, errors.New("error parsing multipart form: " + err.Error())
 	}
-	for _, fHeaders := range r.MultipartForm.File {
-		for _, hdr := range fHeaders {
-			uploadedFiles, err = func(uploadedFiles []*UploadedFile) ([]*UploadedFile, error) {
+
+	// If a part limit is set, reject forms with too many parts.
+	if err := t.checkMultipartParts(r.MultipartForm); err != nil {
+		return nil, err
+	}
+	if err := t.checkUploadLimits(r.MultipartForm); err != nil {
+		return nil, err
+	}
+	// Store the files in the order of their fields, stopping at the first which fails.
+	var results []UploadResult
+	var failure error
+	var fields []string
+	if only != nil {
+		if err := only.check(r.MultipartForm); err != nil {
+			return nil, err
+		}
+		fields = []string{only.name}
+	} else {
+		fields = make([]string, 0, len(r.MultipartForm.File))
+		for field := range r.MultipartForm.File {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+	}
+	for _, field := range fields {
+		for _, hdr := range r.MultipartForm.File[field] {
+			if failure != nil {
+				results = append(results, UploadResult{FieldName: field, FileName: hdr.Filename, Err: ErrUploadSkipped})
+				continue
+			}
+			file, err := func() (*UploadedFile, error) {
 				var uploadedFile UploadedFile
 				infile, err := hdr.Open()
 				if err != nil {
@@ -128,63 +326,165 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 				}
 				defer infile.Close()
 
-				buff := make([]byte, 512)
+				buff := make([]byte, fileSniffLen)
 				_, err = infile.Read(buff)
 
 				if err != nil {
 					return nil, err
 				}
 
-				//TODO: Check to see if the file type is permitted
-				allowed := false
-				fileType := http.DetectContentType(buff)
+				// Check the file type, size and content, keeping rejected files for review if QuarantineDir is set.
+				fileType := t.DetectFileType(buff)
+				if err := t.validateUpload(r.Context(), hdr, infile, fileType); err != nil {
+					if t.QuarantineDir != "" {
+						t.quarantineUpload(r.Context(), uploadDir, t.uploadFileName(hdr.Filename, renameFile), hdr, infile, fileType, err)
+					}
+					return nil, err
+				}
+
+				// Convert the file to a supported format, if a Converter handles its type.
+				var src io.ReadSeeker = infile
+				storedName := t.uploadFileName(hdr.Filename, renameFile)
+				converted, convertedType, ext, err := t.convertUpload(r.Context(), hdr.Filename, infile, fileType)
+				if err != nil {
+					return nil, err
+				}
+				if converted != nil {
+					defer removeTempFile(converted)
+					uploadedFile.ConvertedFrom = fileType
+					src, fileType = converted, convertedType
+					storedName = strings.TrimSuffix(storedName, filepath.Ext(storedName)) + ext
+				}
 
-				if len(t.AllowedFileTypes) > 0 {
-					for _, x := range t.AllowedFileTypes {
-						if strings.EqualFold(fileType, x) {
-							allowed = true
+				// Read the dimensions and EXIF data of images, if asked to. Files which can't be decoded have none.
+				if t.ExtractImageMetadata && strings.HasPrefix(fileType, "image/") {
+					if meta, err := ExtractImageMetadata(src, t.ImageMetadataOptions); err == nil {
+						uploadedFile.Image = meta
+					}
+					if _, err := src.Seek(0, io.SeekStart); err != nil {
+						return nil, err
+					}
+				}
+
+				// If a file with the same content has already been stored, return it instead.
+				if t.DuplicateChecker != nil {
+					uploadedFile.SHA256, err = hashContent(src)
+					if err != nil {
+						return nil, err
+					}
+					existing, found, err := t.findDuplicateUpload(r.Context(), uploadDir, uploadedFile.SHA256)
+					if err != nil {
+						return nil, err
+					}
+					if found {
+						uploadedFile.NewFileName = existing
+						uploadedFile.OriginalFileName = hdr.Filename
+						uploadedFile.FileSize = hdr.Size
+						uploadedFile.ContentType = fileType
+						uploadedFile.Duplicate = true
+						if p, err := t.EnsureWithinBase(uploadDir, existing); err == nil {
+							t.probeUpload(r.Context(), p, &uploadedFile)
+						}
+						if err := t.saveUploadMetadata(r.Context(), uploadDir, &uploadedFile); err != nil {
+							return nil, err
 						}
+						return &uploadedFile, nil
+					}
+					if _, err = src.Seek(0, 0); err != nil {
+						return nil, err
 					}
-				} else {
-					allowed = true
 				}
-				if !allowed {
-					return nil, errors.New("file type not allowed: " + fileType)
+
+				uploadedFile.NewFileName = storedName
+
+				uploadedFile.OriginalFileName = hdr.Filename
+				uploadedFile.ContentType = fileType
+
+				// Make sure the file name can't be used to write outside of the upload directory.
+				outPath, err := t.EnsureWithinBase(uploadDir, uploadedFile.NewFileName)
+				if err != nil {
+					return nil, err
 				}
-				_, err = infile.Seek(0, 0)
+
+				// Hash the content as it is written, if the metadata needs it and it hasn't been hashed yet.
+				var content io.Reader = src
+				hash := sha256.New()
+				if uploadedFile.SHA256 == "" && t.recordsUploadMetadata() {
+					content = io.TeeReader(src, hash)
+				}
+
+				// Write to a temporary file and rename it, so a crash can't leave a truncated file behind.
+				fileSize, err := writeFileAtomic(outPath, content, t.SyncUploadDir)
 				if err != nil {
 					return nil, err
 				}
-				if renameFile {
-					uploadedFile.NewFileName = fmt.Sprintf("%s%s", t.RandomString(25), filepath.Ext(hdr.Filename))
-				} else {
-					uploadedFile.NewFileName = hdr.Filename
+				uploadedFile.FileSize = fileSize
+				t.probeUpload(r.Context(), outPath, &uploadedFile)
+
+				// Keep the file as it was received, if it was converted and OriginalsDir is set.
+				if converted != nil && t.OriginalsDir != "" {
+					if uploadedFile.OriginalCopy, err = t.keepOriginal(r.Context(), &uploadedFile, infile); err != nil {
+						return &uploadedFile, err
+					}
 				}
 
-				uploadedFile.OriginalFileName = hdr.Filename
+				if uploadedFile.SHA256 == "" && t.recordsUploadMetadata() {
+					uploadedFile.SHA256 = hex.EncodeToString(hash.Sum(nil))
+				}
 
-				var outFile *os.File
-				defer outFile.Close()
-				if outFile, err = os.Create(filepath.Join(uploadDir, uploadedFile.NewFileName)); err != nil {
-					return nil, err
-				} else {
-					fileSize, err := io.Copy(outFile, infile)
-					if err != nil {
-						return nil, err
+				if t.DuplicateChecker != nil {
+					if err := t.DuplicateChecker.RecordFile(r.Context(), uploadedFile.SHA256, uploadedFile.NewFileName); err != nil {
+						return &uploadedFile, err
 					}
-					uploadedFile.FileSize = fileSize
 				}
-				uploadedFiles = append(uploadedFiles, &uploadedFile)
-				return uploadedFiles, nil
-			}(uploadedFiles)
+				if err := t.saveUploadMetadata(r.Context(), uploadDir, &uploadedFile); err != nil {
+					return &uploadedFile, err
+				}
+				return &uploadedFile, nil
+			}()
+
+			results = append(results, UploadResult{FieldName: field, FileName: hdr.Filename, File: file, Err: err})
 			if err != nil {
-				return uploadedFiles, err
+				failure = err
+				continue
 			}
+			uploadedFiles = append(uploadedFiles, file)
+		}
+	}
+
+	if failure != nil {
+		uploadErr := &UploadError{Err: failure, Results: results}
+		if t.RollbackUploads {
+			t.rollbackUploads(r.Context(), uploadDir, uploadErr)
+			return nil, uploadErr
 		}
+		return uploadedFiles, uploadErr
 	}
 	return uploadedFiles, nil
 }
 
+// uploadFileName returns the name an upload called name is stored under: a random name with the same
+// extension, or name itself if rename is false, cleaned up as FileNames says.
+func (t *Tools) uploadFileName(name string, rename bool) string {
+	name = t.FileNames.cleanFileName(name)
+	if !rename {
+		return name
+	}
+	return fmt.Sprintf("%s%s", t.RandomString(25), filepath.Ext(name))
+}
+
+// countMultipartParts returns the total number of values and files in a parsed multipart form.
+func countMultipartParts(form *multipart.Form) int {
+	count := 0
+	for _, v := range form.Value {
+		count += len(v)
+	}
+	for _, f := range form.File {
+		count += len(f)
+	}
+	return count
+}
+
 // CreateDirIfNotExist creates a directory with the specified name if it does not already exist.
 func (t *Tools) CreateDirIfNotExist(dir string) error {
 	const mode = 0755
@@ -198,28 +498,178 @@ func (t *Tools) CreateDirIfNotExist(dir string) error {
 }
 
 // Slugify transforms an input string into a URL-friendly slug by replacing non-alphanumeric characters with hyphens.
+// Non-ASCII text is transliterated first, so "Crème Brûlée" becomes "creme-brulee"; characters with no built-in
+// transliteration (such as Chinese characters) are passed to SlugTransliterator, or dropped if it isn't set.
 func (t *Tools) Slugify(s string) (string, error) {
+	return t.SlugifyWithOptions(s, SlugOptions{})
+}
+
+// SlugOptions customizes the slugs produced by SlugifyWithOptions. The zero value produces the same slugs as Slugify.
+type SlugOptions struct {
+	MaxLength      int      // maximum length of the slug, truncated at a word boundary where possible; 0 means no limit
+	Separator      string   // the separator between words; defaults to "-"
+	KeepCase       bool     // if set to true, keep the case of letters instead of lowercasing them
+	Reserved       []string // slugs which may not be used (e.g. "admin", "api"), compared case-insensitively
+	ReservedSuffix string   // if set, appended to a reserved slug instead of returning an error
+}
+
+// SlugifyWithOptions transforms an input string into a URL-friendly slug like Slugify, with control over its length,
+// separator and case, and a list of reserved slugs.
+func (t *Tools) SlugifyWithOptions(s string, opts SlugOptions) (string, error) {
 	if s == "" {
 		return "", errors.New("empty string not permitted")
 	}
-	var regEx = regexp.MustCompile(`[^a-z\d]+`)
+	if t.MaxSlugLength > 0 && len(s) > t.MaxSlugLength {
+		return "", fmt.Errorf("string must not be longer than %d bytes", t.MaxSlugLength)
+	}
+
+	sep := opts.Separator
+	if sep == "" {
+		sep = "-"
+	}
+
+	s = transliterate(s, t.SlugTransliterator)
+	var regEx = regexp.MustCompile(`[^a-zA-Z\d]+`)
+	if !opts.KeepCase {
+		s = strings.ToLower(s)
+	}
 
-	slug := strings.Trim(regEx.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	slug := strings.Trim(regEx.ReplaceAllString(s, sep), sep)
+
+	if opts.MaxLength > 0 && len(slug) > opts.MaxLength {
+		// Cut at the last separator which fits, unless the cut already falls between words.
+		cut := slug[:opts.MaxLength]
+		if !strings.HasPrefix(slug[opts.MaxLength:], sep) {
+			if i := strings.LastIndex(cut, sep); i > 0 {
+				cut = cut[:i]
+			}
+		}
+		slug = strings.TrimSuffix(cut, sep)
+	}
 
 	if len(slug) == 0 {
 		return "", errors.New("after removing characters, slug is zero length")
 	}
 
+	for _, reserved := range opts.Reserved {
+		if strings.EqualFold(slug, reserved) {
+			if opts.ReservedSuffix == "" {
+				return "", fmt.Errorf("slug %q is reserved", slug)
+			}
+			slug = slug + sep + opts.ReservedSuffix
+			break
+		}
+	}
+
 	return slug, nil
 }
 
 // DownloadStaticFile downloads a file, and tries to force the browser to avoid displaying it
-// in the browser window by setting content disposition. It also allows specification of the display name
+// in the browser window by setting content disposition. It also allows specification of the display name.
+// Downloads are resumable: Range and If-Range requests are honored, Accept-Ranges, ETag and Last-Modified
+// headers are set, and If-None-Match and If-Modified-Since result in a 304 Not Modified response.
 func (t *Tools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, p, file, displayName string) {
-	fp := path.Join(p, file)
+	fp, err := t.EnsureWithinBase(p, file)
+	if err != nil {
+		http.Error(w, "invalid file path", http.StatusBadRequest)
+		return
+	}
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
+
+	// http.ServeFile handles the conditional and range headers itself, using any ETag already set on the
+	// response, so all we have to do is provide one.
+	if info, err := os.Stat(fp); err == nil && !info.IsDir() {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("ETag", fileETag(info))
+	}
+
 	http.ServeFile(w, r, fp)
+}
+
+// DownloadStaticFileFS is like DownloadStaticFile, but serves file from fsys, such as an embed.FS, instead
+// of the real filesystem. The name uses forward slashes, and must not contain ".." elements.
+func (t *Tools) DownloadStaticFileFS(w http.ResponseWriter, r *http.Request, fsys fs.FS, file, displayName string) {
+	name := strings.TrimPrefix(file, "/")
+	if !fs.ValidPath(name) {
+		http.Error(w, "invalid file path", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
+
+	if info, err := fs.Stat(fsys, name); err == nil && !info.IsDir() {
+		if etag, err := fsFileETag(fsys, name, info); err == nil {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("ETag", etag)
+		}
+	}
+
+	http.ServeFileFS(w, r, fsys, name)
+}
+
+// DownloadStream sends the contents of reader to the client as a download named displayName, with the
+// same Content-Disposition handling as DownloadStaticFile, so content from object storage, databases, or
+// generated on the fly can be served without writing it to disk first. If size is zero or more, it is sent
+// as the Content-Length; pass -1 if the size isn't known. If contentType is empty, it is detected from the
+// first bytes of the content. If reader is also an io.ReadSeeker, Range requests are honored.
+func (t *Tools) DownloadStream(w http.ResponseWriter, r *http.Request, reader io.Reader, size int64, displayName, contentType string) error {
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	// If we can seek, let http.ServeContent handle ranges and content type detection.
+	if rs, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, displayName, time.Time{}, rs)
+		return nil
+	}
+
+	// Otherwise, sniff the content type from the first bytes, and stream the rest after them.
+	if contentType == "" {
+		buff := make([]byte, 512)
+		n, err := io.ReadFull(reader, buff)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return err
+		}
+		w.Header().Set("Content-Type", http.DetectContentType(buff[:n]))
+		reader = io.MultiReader(bytes.NewReader(buff[:n]), reader)
+	}
+
+	if size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w.WriteHeader(http.StatusOK)
 
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	_, err := io.Copy(w, reader)
+	return err
+}
+
+// fileETag returns a strong ETag derived from a file's size and modification time.
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf("\"%x-%x\"", info.Size(), info.ModTime().UnixNano())
+}
+
+// fsFileETag returns a strong ETag for a file in fsys. Files in an embed.FS have no modification time, so
+// their ETag is derived from a hash of the content instead.
+func fsFileETag(fsys fs.FS, name string, info fs.FileInfo) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fileETag(info), nil
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("\"%x\"", h.Sum(nil)[:16]), nil
 }
 
 // ReadJSON tries to read the body of a request and converts it from JSON to a variable. The third parameter, data,
@@ -231,74 +681,287 @@ func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{
 	if r.Header.Get("Content-Type") != "" {
 		contentType := r.Header.Get("Content-Type")
 		if strings.ToLower(contentType) != "application/json" {
-			return errors.New("Content-Type must be application/json")
+			return newMessageError(MsgContentTypeJSON, "")
 		}
 	}
 
-	// Set a sensible default for the maximum payload size.
-	maxBytes := defaultMaxUpload
+	if err := t.checkHeaders(r); err != nil {
+		return err
+	}
+
+	// Use Limits.MaxBody or MaxJSONSize if set, or else a sensible default, as the maximum payload size.
+	maxBytes := t.bodyLimit(t.MaxJSONSize, defaultMaxUpload)
 
-	// If MaxJSONSize is set, use the value instead of default.
-	if t.MaxJSONSize != 0 {
-		maxBytes = t.MaxJSONSize
+	// Limit the size of the body, decompressing it if necessary.
+	body, err := t.requestBody(w, r, maxBytes)
+	if err != nil {
+		return err
 	}
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
 
-	dec := json.NewDecoder(r.Body)
+	dec := json.NewDecoder(t.limitJSONDepth(body, "body"))
 
 	if !t.AllowUnknownFields {
 		dec.DisallowUnknownFields()
 	}
 
 	// Attempt to decode the data, and figure out what the error is, if any, to send back a human-readable response
-	err := dec.Decode(data)
+	err = dec.Decode(data)
 	if err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarshalError *json.InvalidUnmarshalError
+		return jsonDecodeError("body", err)
+	}
 
-		switch {
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return newMessageError(MsgJSONOneValue, "body")
+	}
 
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
+	return nil
+}
 
-		case errors.As(err, &unmarshalTypeError):
-			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains icnorrect JSON type for field %q", unmarshalTypeError.Field)
-			}
-			return fmt.Errorf("body contains an invalid JSON (at character %d)", unmarshalTypeError.Offset)
+// jsonDecodeError turns an error from decoding JSON into a human-readable one, describing the decoded
+// value as subject (e.g. "body").
+func jsonDecodeError(subject string, err error) error {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+	var maxBytesError *http.MaxBytesError
 
-		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+	switch {
+	case errors.As(err, &syntaxError):
+		return newMessageError(MsgJSONSyntaxAt, subject, syntaxError.Offset)
 
-		case strings.HasPrefix(err.Error(), "json: unknown field"):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return newMessageError(MsgJSONSyntax, subject)
 
-		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return newMessageError(MsgJSONFieldType, subject, unmarshalTypeError.Field)
+		}
+		return newMessageError(MsgJSONTypeAt, subject, unmarshalTypeError.Offset)
 
-		case errors.As(err, &invalidUnmarshalError):
-			return fmt.Errorf("error unmarshalling JSON: %s", err.Error())
+	case errors.Is(err, io.EOF):
+		return newMessageError(MsgEmpty, subject)
 
-		default:
-			return err
+	case strings.HasPrefix(err.Error(), "json: unknown field"):
+		fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
+		return newMessageError(MsgUnknownKey, subject, fieldName)
+
+	case errors.As(err, &maxBytesError):
+		return newMessageError(MsgTooLarge, subject, maxBytesError.Limit)
+
+	case errors.As(err, &invalidUnmarshalError):
+		return fmt.Errorf("error unmarshalling JSON: %s", err.Error())
+
+	default:
+		return err
+	}
+}
+
+// jsonStreamPool holds the writers and encoders reused by WriteJSON when JSONOptions.Stream is set.
+var jsonStreamPool = sync.Pool{
+	New: func() any {
+		sw := new(jsonStreamWriter)
+		sw.enc = json.NewEncoder(sw)
+		return sw
+	},
+}
+
+// jsonStreamWriter passes an encoded response on to a ResponseWriter, writing the headers and status
+// first. Since json.Encoder only writes once a value has been encoded in full, nothing is sent if encoding
+// fails.
+type jsonStreamWriter struct {
+	w       http.ResponseWriter
+	status  int
+	headers http.Header
+	enc     *json.Encoder
+	wrote   bool
+	failed  bool // set if a write failed, after which enc keeps returning the error, so can't be reused
+}
+
+// Write implements io.Writer.
+func (sw *jsonStreamWriter) Write(b []byte) (int, error) {
+	if !sw.wrote {
+		for key, val := range sw.headers {
+			sw.w.Header()[key] = val
 		}
+		sw.w.Header().Set("Content-Type", "application/json")
+		sw.w.WriteHeader(sw.status)
+		sw.wrote = true
 	}
+	n, err := sw.w.Write(b)
+	sw.failed = err != nil
+	return n, err
+}
 
-	err = dec.Decode(&struct{}{})
-	if err != io.EOF {
-		return errors.New("body must contain only one JSON value")
+// streamJSON writes data like WriteJSON, but encodes it straight to w with a pooled encoder, so the
+// response isn't copied through a buffer of the toolkit's own. The body ends with a newline.
+func (t *Tools) streamJSON(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	sw := jsonStreamPool.Get().(*jsonStreamWriter)
+	sw.w, sw.status, sw.wrote, sw.headers = w, status, false, nil
+	if len(headers) > 0 {
+		sw.headers = headers[0]
 	}
+	sw.enc.SetEscapeHTML(!t.JSONOptions.DisableHTMLEscaping)
+	sw.enc.SetIndent("", t.JSONOptions.Indent)
 
-	return nil
+	err := t.streamEncode(sw, data)
+	if err != nil && !sw.wrote {
+		t.writeJSONFailure(w, err)
+	}
+
+	sw.w, sw.headers = nil, nil
+	if !sw.failed {
+		jsonStreamPool.Put(sw)
+	}
+	return err
+}
+
+// streamEncode encodes data with sw's encoder, turning a panic into an error.
+func (t *Tools) streamEncode(sw *jsonStreamWriter, data any) (err error) {
+	defer recoverJSONPanic(data, &err)
+
+	data, err = t.applyJSONMarshalers(data)
+	if err != nil {
+		return err
+	}
+	return sw.enc.Encode(data)
 }
 
-// WriteJSON takes a response status code and arbitrary data and writes json to the client
+// recoverJSONPanic recovers from a panic while encoding data, such as one in a MarshalJSON method,
+// setting *err to an error describing it. It must be deferred.
+func recoverJSONPanic(data any, err *error) {
+	if rec := recover(); rec != nil {
+		*err = fmt.Errorf("json: panic encoding %T: %v", data, rec)
+	}
+}
+
+// writeJSONFailure sends a 500 Internal Server Error in place of a response whose data couldn't be
+// encoded, so the client isn't left with an empty one. In DebugMode, the message says why.
+func (t *Tools) writeJSONFailure(w http.ResponseWriter, err error) {
+	t.LogError(context.Background(), "could not encode JSON response", "error", err)
+
+	message := http.StatusText(http.StatusInternalServerError)
+	if t.DebugMode {
+		message += ": " + err.Error()
+	}
+	out, _ := json.Marshal(JSONResponse{Error: true, Message: message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = w.Write(out)
+}
+
+// WriteJSON takes a response status code and arbitrary data and writes json to the client. If
+// JSONOptions.Stream is set, data is encoded straight to w rather than into a pooled buffer first. Data
+// which can't be encoded, such as a channel or func, or a MarshalJSON method which panics, is reported as
+// an error, and a 500 Internal Server Error is sent in its place.
 func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
-	out, err := json.Marshal(data)
+	if t.JSONOptions.Stream && !t.JSONOptions.OmitNull {
+		return t.streamJSON(w, status, data, headers...)
+	}
+
+	buf, err := t.encodeJSON(data)
+	if err != nil {
+		t.writeJSONFailure(w, err)
+		return err
+	}
+	defer putBuffer(buf)
+
+	return t.writeJSONBody(w, status, buf.Bytes(), headers...)
+}
+
+// WriteJSONWithETag writes data like WriteJSON, but also sends a strong ETag computed from the encoded
+// body. If the request is a GET or HEAD with an If-None-Match header matching the ETag, a 304 Not Modified
+// response is sent instead, so clients polling for large responses only download them when they change.
+// ETags are only added to 200 OK responses.
+func (t *Tools) WriteJSONWithETag(w http.ResponseWriter, r *http.Request, status int, data interface{}, headers ...http.Header) error {
+	buf, err := t.encodeJSON(data)
+	if err != nil {
+		t.writeJSONFailure(w, err)
+		return err
+	}
+	defer putBuffer(buf)
+
+	if status != http.StatusOK {
+		return t.writeJSONBody(w, status, buf.Bytes(), headers...)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	etag := fmt.Sprintf("\"%x\"", sum[:16])
+
+	if len(headers) > 0 {
+		for key, val := range headers[0] {
+			w.Header()[key] = val
+		}
+	}
+	w.Header().Set("ETag", etag)
+
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	return t.writeJSONBody(w, status, buf.Bytes())
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag, using the weak comparison
+// RFC 9110 specifies for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeJSON encodes data into a pooled buffer, so the body is complete before any headers are written,
+// without allocating a new slice for every response. The JSONOptions are applied, apart from Indent, so
+// the result is always compact; see indentJSON. The caller must return the buffer with putBuffer.
+func (t *Tools) encodeJSON(data interface{}) (buf *bytes.Buffer, err error) {
+	// A MarshalJSON method or registered marshaler which panics fails the encoding, not the request.
+	defer recoverJSONPanic(data, &err)
+
+	// Render any values which have a registered marshaler.
+	data, err = t.applyJSONMarshalers(data)
+	if err != nil {
+		return nil, err
+	}
+
+	buf = getBuffer()
+	enc := json.NewEncoder(buf)
+	if t.JSONOptions.DisableHTMLEscaping {
+		enc.SetEscapeHTML(false)
+	}
+	err = enc.Encode(data)
+	if err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	// Encode adds a trailing newline, which json.Marshal does not.
+	buf.Truncate(buf.Len() - 1)
+
+	if t.JSONOptions.OmitNull {
+		out := getBuffer()
+		err = omitJSONNulls(out, buf.Bytes(), !t.JSONOptions.DisableHTMLEscaping)
+		putBuffer(buf)
+		if err != nil {
+			putBuffer(out)
+			return nil, err
+		}
+		buf = out
+	}
+
+	return buf, nil
+}
+
+// writeJSONBody sends an encoded JSON body, indented according to JSONOptions, with any custom headers.
+func (t *Tools) writeJSONBody(w http.ResponseWriter, status int, out []byte, headers ...http.Header) error {
+	out, err := t.indentJSON(out)
 	if err != nil {
 		return err
 	}
@@ -322,62 +985,76 @@ func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, h
 	return nil
 }
 
-// ErrorJSON takes an error, and optionally a status code, and generates and sends a JSON error message
+// ErrorJSON takes an error, and optionally a status code, and generates and sends a JSON error message.
+// An *HTTPError is sent with its public message and fields, and its status if none is given.
 func (t *Tools) ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
-	statusCode := http.StatusBadRequest
-
-	// if a custom response code is specified, use that instead of bad request.
+	// if a custom response code is specified, use that; otherwise sendError picks one.
+	statusCode := 0
 	if len(status) > 0 {
 		statusCode = status[0]
 	}
 
-	// build JSON Payload.
-	var payload JSONResponse
-	payload.Error = true
-	payload.Message = err.Error()
-
-	return t.WriteJSON(w, statusCode, payload)
+	return t.sendError(w, &ErrorResponse{Err: err, Status: statusCode})
 }
 
 // PushJSONToRemote posts arbitrary json to some url, and returns the response, the response
-// status code, and error, if any. The final parameter, client, is optional, and will default
-// to the standard http.Client. It exists to make testing possible without an active remote
-// url.
+// status code, and error, if any. The response body has already been read, up to 10mb, and can be
+// read again from the returned response. The final parameter, client, is optional, and will default
+// to HTTPClient, or a shared client with safe timeouts. It exists to make testing possible without
+// an active remote url.
 func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.Client) (*http.Response, int, error) {
 	// Create json
-	jsonData, err := json.Marshal(data)
-
+	jsonData, err := t.remoteJSONBody(data)
 	if err != nil {
 		return nil, 0, err
 	}
-	// Check for custom http client
-	httpClient := &http.Client{}
-	if len(client) > 0 {
-		httpClient = client[0]
+
+	return t.PushToRemote(uri, bytes.NewReader(jsonData), "application/json", clientOptions(client))
+}
+
+// remoteJSONBody encodes data as the body of a remote call.
+func (t *Tools) remoteJSONBody(data interface{}) ([]byte, error) {
+	buf, err := t.encodeJSON(data)
+	if err != nil {
+		return nil, err
 	}
+	defer putBuffer(buf)
 
-	// Build the request and set the header
-	request, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(jsonData))
+	jsonData, err := t.indentJSON(buf.Bytes())
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+	if t.DebugMode {
+		t.LogDebug(context.Background(), "pushing JSON to remote", "payload", t.Redactor.Redact(data))
 	}
-	request.Header.Set("Content-Type", "application/json")
+	// The request may still be reading the body after Do returns, so it gets a copy of its own.
+	return bytes.Clone(jsonData), nil
+}
 
-	// Call the remote uri
-	response, err := httpClient.Do(request)
+// PushXMLToRemote posts arbitrary data as XML to some url, and returns the response, the response status
+// code, and error, if any. Data is encoded the same way as by WriteXML. Like PushJSONToRemote, the final
+// parameter, client, is optional.
+func (t *Tools) PushXMLToRemote(uri string, data interface{}, client ...*http.Client) (*http.Response, int, error) {
+	out, err := marshalXML("", data)
 	if err != nil {
 		return nil, 0, err
 	}
-	defer response.Body.Close()
 
-	// Send response back
-	return response, response.StatusCode, nil
+	return t.PushToRemote(uri, bytes.NewReader(append([]byte(xml.Header), out...)), "application/xml", clientOptions(client))
 }
 
 // WriteXML takes a response status code and arbitrary data and writes an XML response to the client.
 // The Content-Type header is set to application/xml
 func (t *Tools) WriteXML(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
-	out, err := xml.Marshal(data)
+	return t.WriteXMLWithRoot(w, status, "", data, headers...)
+}
+
+// WriteXMLWithRoot writes data like WriteXML, with the root element named root. Maps and slices are
+// supported as well as structs: map keys become elements, or attributes if they start with "@", and slice
+// values become <item> elements. If root is empty, structs are named as encoding/xml would name them,
+// and anything else is named "response".
+func (t *Tools) WriteXMLWithRoot(w http.ResponseWriter, status int, root string, data interface{}, headers ...http.Header) error {
+	out, err := marshalXML(root, data)
 	if err != nil {
 		return err
 	}
@@ -403,20 +1080,35 @@ func (t *Tools) WriteXML(w http.ResponseWriter, status int, data interface{}, he
 }
 
 // ReadXML tries to read the body of an XML request into a variable. The third parameter, data, is expected be a pointer, so we can read data into it.
+// Documents containing a DTD are rejected unless AllowXMLDTD is set, and MaxXMLAttributes, MaxXMLDepth and
+// MaxXMLTokens are enforced before the body is decoded.
 func (t *Tools) ReadXML(w http.ResponseWriter, r *http.Request, data interface{}) error {
-	maxBytes := defaultMaxUpload
+	if err := t.checkHeaders(r); err != nil {
+		return err
+	}
+
+	// Use Limits.MaxBody or MaxXMLSize if set, or else the default.
+	maxBytes := t.bodyLimit(t.MaxXMLSize, defaultMaxUpload)
 
-	// If MaxXMLSize is set, use that value instead of default
-	if t.MaxXMLSize != 0 {
-		maxBytes = t.MaxXMLSize
+	// Limit the size of the body, decompressing it if necessary.
+	body, err := t.requestBody(w, r, maxBytes)
+	if err != nil {
+		return err
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
 
-	dec := xml.NewDecoder(r.Body)
+	// Check the document limits before decoding into data.
+	dec, err := t.newXMLDecoder(b)
+	if err != nil {
+		return err
+	}
 
 	// Attempt to decode the data.
-	err := dec.Decode(data)
+	err = dec.Decode(data)
 	if err != nil {
 		return err
 	}
@@ -429,17 +1121,13 @@ func (t *Tools) ReadXML(w http.ResponseWriter, r *http.Request, data interface{}
 }
 
 // ErrorXML takes and error, and optionally a response status code, and generates adn sends an XML error response.
+// An *HTTPError is sent as it is by ErrorJSON.
 func (t *Tools) ErrorXML(w http.ResponseWriter, err error, status ...int) error {
-	statusCode := http.StatusBadRequest
-
-	// If a custom response code is specified, use that instead of bad request.
+	// If a custom response code is specified, use that; otherwise sendError picks one.
+	statusCode := 0
 	if len(status) > 0 {
 		statusCode = status[0]
 	}
 
-	var payload XMLResponse
-	payload.Error = true
-	payload.Message = err.Error()
-
-	return t.WriteXML(w, statusCode, payload)
+	return t.sendError(w, &ErrorResponse{Err: err, Status: statusCode, XML: true})
 }
@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -187,6 +188,44 @@ func TestTools_UploadOneFile(t *testing.T) {
 	_ = os.Remove(fmt.Sprintf("./testdata/uploads/%s", uploadedFiles.NewFileName))
 }
 
+func TestTools_UploadFiles_MaxMultipartParts(t *testing.T) {
+	var testTools Tools
+	testTools.MaxMultipartParts = 1
+
+	body, contentType := testTools.BuildMultipartBody(
+		[]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}},
+		map[string]string{"title": "a picture"},
+	)
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Add("Content-Type", contentType)
+
+	_, err := testTools.UploadFiles(request, "./testdata/uploads/")
+	if err == nil {
+		t.Error("expected an error for too many multipart parts, but none received")
+	}
+}
+
+func TestTools_UploadFiles_MultipartMemoryLimit(t *testing.T) {
+	var testTools Tools
+	// a tiny memory limit forces the file to be spilled to a temporary file while parsing
+	testTools.MultipartMemoryLimit = 1
+
+	body, contentType := testTools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Add("Content-Type", contentType)
+
+	uploadedFiles, err := testTools.UploadFiles(request, "./testdata/uploads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(fmt.Sprintf("./testdata/uploads/%s", uploadedFiles[0].NewFileName)); os.IsNotExist(err) {
+		t.Errorf("file not uploaded %s", err.Error())
+	}
+	// Clean up
+	_ = os.Remove(fmt.Sprintf("./testdata/uploads/%s", uploadedFiles[0].NewFileName))
+}
+
 func TestTools_CreateDirIfNotExist(t *testing.T) {
 	var testTools Tools
 
@@ -210,30 +249,99 @@ var slugTests = []struct {
 	name          string
 	s             string
 	expected      string
+	maxLength     int
 	errorExpected bool
 }{
 	{name: "valid string", s: "Hello World", expected: "hello-world", errorExpected: false},
 	{name: "empty string", s: "", expected: "", errorExpected: true},
 	{name: "complex string", s: "Now is the time for all GOOD men! + fish & such &^123", expected: "now-is-the-time-for-all-good-men-fish-such-123", errorExpected: false},
-	{name: " japanese string", s: "こんにちは世界", expected: "", errorExpected: true},
-	{name: " japanese string and roman characters", s: "hello world こんにちは世界", expected: "hello-world", errorExpected: false},
+	{name: " japanese string", s: "こんにちは世界", expected: "konnichiha", errorExpected: false},
+	{name: " japanese string and roman characters", s: "hello world こんにちは世界", expected: "hello-world-konnichiha", errorExpected: false},
+	{name: "katakana", s: "チョコレート ケーキ", expected: "chokoreto-keki", errorExpected: false},
+	{name: "small tsu", s: "ほっかいどう まっちゃ", expected: "hokkaidou-matcha", errorExpected: false},
+	{name: "chinese string", s: "世界", expected: "", errorExpected: true},
+	{name: "accented latin", s: "Crème Brûlée", expected: "creme-brulee", errorExpected: false},
+	{name: "decomposed accents", s: "Cre\u0300me", expected: "creme", errorExpected: false},
+	{name: "german", s: "Straße Œuvre", expected: "strasse-oeuvre", errorExpected: false},
+	{name: "polish", s: "Łódź", expected: "lodz", errorExpected: false},
+	{name: "cyrillic", s: "Привет, мир", expected: "privet-mir", errorExpected: false},
+	{name: "greek", s: "Καλημέρα", expected: "kalimera", errorExpected: false},
+	{name: "within max length", s: "Hello World", expected: "hello-world", maxLength: 11, errorExpected: false},
+	{name: "exceeds max length", s: "Hello World", expected: "", maxLength: 10, errorExpected: true},
 }
 
 func TestTools_Slugify(t *testing.T) {
 	var testTools Tools
 
 	for _, test := range slugTests {
+		testTools.MaxSlugLength = test.maxLength
+
 		slug, err := testTools.Slugify(test.s)
 		if err != nil && !test.errorExpected {
 			t.Errorf("%s: error received but none expected: %s", test.name, err.Error())
 		}
 
+		if err == nil && test.errorExpected {
+			t.Errorf("%s: error expected but none received", test.name)
+		}
+
 		if !test.errorExpected && slug != test.expected {
 			t.Errorf("%s: wrong slug retrned; expected %s but got %s", test.name, test.expected, slug)
 		}
 	}
 }
 
+var slugOptionsTests = []struct {
+	name          string
+	s             string
+	opts          SlugOptions
+	expected      string
+	errorExpected bool
+}{
+	{name: "defaults", s: "Hello World", opts: SlugOptions{}, expected: "hello-world"},
+	{name: "truncate at word boundary", s: "The quick brown fox", opts: SlugOptions{MaxLength: 12}, expected: "the-quick"},
+	{name: "truncate between words", s: "The quick brown fox", opts: SlugOptions{MaxLength: 9}, expected: "the-quick"},
+	{name: "truncate single long word", s: "Supercalifragilistic", opts: SlugOptions{MaxLength: 5}, expected: "super"},
+	{name: "custom separator", s: "Hello World", opts: SlugOptions{Separator: "_"}, expected: "hello_world"},
+	{name: "keep case", s: "Hello World", opts: SlugOptions{KeepCase: true}, expected: "Hello-World"},
+	{name: "reserved", s: "Admin", opts: SlugOptions{Reserved: []string{"admin", "api"}}, errorExpected: true},
+	{name: "reserved with suffix", s: "API", opts: SlugOptions{Reserved: []string{"admin", "api"}, ReservedSuffix: "1"}, expected: "api-1"},
+	{name: "not reserved", s: "Admins", opts: SlugOptions{Reserved: []string{"admin"}}, expected: "admins"},
+}
+
+func TestTools_SlugifyWithOptions(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range slugOptionsTests {
+		slug, err := testTools.SlugifyWithOptions(e.s, e.opts)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error received but none expected: %s", e.name, err)
+		}
+		if !e.errorExpected && slug != e.expected {
+			t.Errorf("%s: wrong slug returned; expected %s but got %s", e.name, e.expected, slug)
+		}
+	}
+}
+
+func TestTools_Slugify_Transliterator(t *testing.T) {
+	var testTools Tools
+	testTools.SlugTransliterator = func(r rune) string {
+		pinyin := map[rune]string{'世': "shi ", '界': "jie "}
+		return pinyin[r]
+	}
+
+	slug, err := testTools.Slugify("你好 世界")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slug != "shi-jie" {
+		t.Errorf("wrong slug returned; expected shi-jie but got %s", slug)
+	}
+}
+
 func TestTools_DownloadStaticFile(t *testing.T) {
 	rr := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/", nil)
@@ -257,6 +365,159 @@ func TestTools_DownloadStaticFile(t *testing.T) {
 	}
 }
 
+var downloadStreamTests = []struct {
+	name                string
+	reader              func() io.Reader
+	size                int64
+	contentType         string
+	rangeHeader         string
+	expectedStatus      int
+	expectedContentType string
+	expectedLength      string
+}{
+	{name: "reader with size", reader: func() io.Reader { return io.MultiReader(strings.NewReader("hello, world")) }, size: 12, contentType: "text/plain", expectedStatus: http.StatusOK, expectedContentType: "text/plain", expectedLength: "12"},
+	{name: "reader without size", reader: func() io.Reader { return io.MultiReader(strings.NewReader("hello, world")) }, size: -1, expectedStatus: http.StatusOK, expectedContentType: "text/plain; charset=utf-8", expectedLength: ""},
+	{name: "seeker with range", reader: func() io.Reader { return strings.NewReader("hello, world") }, size: 12, rangeHeader: "bytes=0-4", expectedStatus: http.StatusPartialContent, expectedContentType: "text/plain; charset=utf-8", expectedLength: "5"},
+}
+
+func TestTools_DownloadStream(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range downloadStreamTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if e.rangeHeader != "" {
+			req.Header.Set("Range", e.rangeHeader)
+		}
+
+		err := testTools.DownloadStream(rr, req, e.reader(), e.size, "hello.txt", e.contentType)
+		if err != nil {
+			t.Errorf("%s: error not expected but one received: %s", e.name, err)
+		}
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if rr.Header().Get("Content-Type") != e.expectedContentType {
+			t.Errorf("%s: wrong content type; expected %s but got %s", e.name, e.expectedContentType, rr.Header().Get("Content-Type"))
+		}
+		if rr.Header().Get("Content-Length") != e.expectedLength {
+			t.Errorf("%s: wrong content length; expected %s but got %s", e.name, e.expectedLength, rr.Header().Get("Content-Length"))
+		}
+		if rr.Header().Get("Content-Disposition") != "attachment; filename=\"hello.txt\"" {
+			t.Errorf("%s: wrong content disposition of %s", e.name, rr.Header().Get("Content-Disposition"))
+		}
+	}
+}
+
+var downloadRangeTests = []struct {
+	name           string
+	header         map[string]string
+	expectedStatus int
+	expectedLength string
+}{
+	{name: "full download", header: map[string]string{}, expectedStatus: http.StatusOK, expectedLength: "98827"},
+	{name: "range", header: map[string]string{"Range": "bytes=0-99"}, expectedStatus: http.StatusPartialContent, expectedLength: "100"},
+	{name: "resume from offset", header: map[string]string{"Range": "bytes=98727-"}, expectedStatus: http.StatusPartialContent, expectedLength: "100"},
+	{name: "if-range with stale etag", header: map[string]string{"Range": "bytes=0-99", "If-Range": `"stale"`}, expectedStatus: http.StatusOK, expectedLength: "98827"},
+	{name: "if-none-match with stale etag", header: map[string]string{"If-None-Match": `"stale"`}, expectedStatus: http.StatusOK, expectedLength: "98827"},
+}
+
+func TestTools_DownloadStaticFile_Range(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range downloadRangeTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range e.header {
+			req.Header.Set(k, v)
+		}
+
+		testTools.DownloadStaticFile(rr, req, "./testdata", "pic.jpg", "puppy.jpg")
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if rr.Header().Get("Content-Length") != e.expectedLength {
+			t.Errorf("%s: wrong content length; expected %s but got %s", e.name, e.expectedLength, rr.Header().Get("Content-Length"))
+		}
+		if rr.Header().Get("Accept-Ranges") != "bytes" {
+			t.Errorf("%s: Accept-Ranges header not set", e.name)
+		}
+	}
+}
+
+func TestTools_DownloadStaticFile_Conditional(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	testTools.DownloadStaticFile(rr, req, "./testdata", "pic.jpg", "puppy.jpg")
+
+	etag := rr.Header().Get("ETag")
+	lastModified := rr.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatal("ETag and Last-Modified headers must be set")
+	}
+
+	for _, h := range []string{"If-None-Match", "If-Modified-Since"} {
+		rr = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/", nil)
+		if h == "If-None-Match" {
+			req.Header.Set(h, etag)
+		} else {
+			req.Header.Set(h, lastModified)
+		}
+
+		testTools.DownloadStaticFile(rr, req, "./testdata", "pic.jpg", "puppy.jpg")
+		if rr.Code != http.StatusNotModified {
+			t.Errorf("%s: wrong status; expected %d but got %d", h, http.StatusNotModified, rr.Code)
+		}
+	}
+
+	// a matching If-Range resumes the download
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=0-99")
+	req.Header.Set("If-Range", etag)
+	testTools.DownloadStaticFile(rr, req, "./testdata", "pic.jpg", "puppy.jpg")
+	if rr.Code != http.StatusPartialContent {
+		t.Errorf("If-Range: wrong status; expected %d but got %d", http.StatusPartialContent, rr.Code)
+	}
+}
+
+func TestTools_DownloadStaticFileFS(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	testTools.DownloadStaticFileFS(rr, req, testAssets, "testdata/pic.jpg", "puppy.jpg")
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Length") != "98827" {
+		t.Errorf("wrong response; got status %d and content length %s", rr.Code, rr.Header().Get("Content-Length"))
+	}
+	if rr.Header().Get("Content-Disposition") != "attachment; filename=\"puppy.jpg\"" {
+		t.Error("wrong content disposition of", rr.Header().Get("Content-Disposition"))
+	}
+
+	// embedded files have no modification time, so the ETag comes from the content
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag header must be set")
+	}
+	rr = httptest.NewRecorder()
+	req.Header.Set("If-None-Match", etag)
+	testTools.DownloadStaticFileFS(rr, req, testAssets, "testdata/pic.jpg", "puppy.jpg")
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("wrong status; expected %d but got %d", http.StatusNotModified, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	testTools.DownloadStaticFileFS(rr, req, testAssets, "../tools.go", "tools.go")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("wrong status for traversal; expected %d but got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
 var jsonTests = []struct {
 	name          string
 	json          string
@@ -331,6 +592,101 @@ func TestTools_WriteJSON(t *testing.T) {
 	}
 }
 
+// panickingMarshaler is a json.Marshaler which panics.
+type panickingMarshaler struct{}
+
+func (panickingMarshaler) MarshalJSON() ([]byte, error) {
+	panic("marshaler broke")
+}
+
+func TestTools_WriteJSON_Unencodable(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          any
+		debug         bool
+		stream        bool
+		expectedError string
+		expectedBody  string
+	}{
+		{name: "channel", data: map[string]any{"c": make(chan int)}, expectedBody: `{"error":true,"message":"Internal Server Error"}`},
+		{name: "func streamed", data: []any{func() {}}, stream: true, expectedBody: `{"error":true,"message":"Internal Server Error"}`},
+		{name: "panic", data: panickingMarshaler{}, expectedError: "json: panic encoding toolkit.panickingMarshaler: marshaler broke",
+			expectedBody: `{"error":true,"message":"Internal Server Error"}`},
+		{name: "panic streamed", data: []any{panickingMarshaler{}}, stream: true, expectedError: "json: panic encoding []interface {}: marshaler broke",
+			expectedBody: `{"error":true,"message":"Internal Server Error"}`},
+		{name: "debug", data: panickingMarshaler{}, debug: true, expectedError: "json: panic encoding toolkit.panickingMarshaler: marshaler broke",
+			expectedBody: `{"error":true,"message":"Internal Server Error: json: panic encoding toolkit.panickingMarshaler: marshaler broke"}`},
+	}
+
+	for _, e := range tests {
+		testTools := Tools{DebugMode: e.debug, JSONOptions: JSONOptions{Stream: e.stream}}
+
+		rr := httptest.NewRecorder()
+		err := testTools.WriteJSON(rr, http.StatusOK, e.data, http.Header{"X-Foo": {"bar"}})
+		if err == nil {
+			t.Errorf("%s: expected an error, but none received", e.name)
+			continue
+		}
+		if e.expectedError != "" && err.Error() != e.expectedError {
+			t.Errorf("%s: expected error %q, got %q", e.name, e.expectedError, err)
+		}
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected status %d, got %d", e.name, http.StatusInternalServerError, rr.Code)
+		}
+		if rr.Body.String() != e.expectedBody {
+			t.Errorf("%s: expected body %s, got %s", e.name, e.expectedBody, rr.Body.String())
+		}
+		if rr.Header().Get("X-Foo") != "" {
+			t.Errorf("%s: expected the response's headers to be dropped", e.name)
+		}
+	}
+}
+
+func TestTools_WriteJSONWithETag(t *testing.T) {
+	var testTools Tools
+	payload := JSONResponse{Message: "foo", Data: []int{1, 2, 3}}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := testTools.WriteJSONWithETag(rr, req, http.StatusOK, payload); err != nil {
+		t.Fatal(err)
+	}
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" || rr.Body.String() != `{"error":false,"message":"foo","data":[1,2,3]}` {
+		t.Fatalf("wrong response; got %d with ETag %q and body %s", rr.Code, etag, rr.Body.String())
+	}
+
+	etagTests := []struct {
+		name           string
+		method         string
+		ifNoneMatch    string
+		status         int
+		expectedStatus int
+	}{
+		{name: "matching", method: http.MethodGet, ifNoneMatch: etag, status: http.StatusOK, expectedStatus: http.StatusNotModified},
+		{name: "weak matching in list", method: http.MethodGet, ifNoneMatch: `"other", W/` + etag, status: http.StatusOK, expectedStatus: http.StatusNotModified},
+		{name: "wildcard", method: http.MethodHead, ifNoneMatch: "*", status: http.StatusOK, expectedStatus: http.StatusNotModified},
+		{name: "stale", method: http.MethodGet, ifNoneMatch: `"stale"`, status: http.StatusOK, expectedStatus: http.StatusOK},
+		{name: "not a GET", method: http.MethodPost, ifNoneMatch: etag, status: http.StatusOK, expectedStatus: http.StatusOK},
+		{name: "not a 200", method: http.MethodGet, ifNoneMatch: etag, status: http.StatusCreated, expectedStatus: http.StatusCreated},
+	}
+
+	for _, e := range etagTests {
+		rr = httptest.NewRecorder()
+		req = httptest.NewRequest(e.method, "/", nil)
+		req.Header.Set("If-None-Match", e.ifNoneMatch)
+
+		_ = testTools.WriteJSONWithETag(rr, req, e.status, payload)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if e.expectedStatus == http.StatusNotModified && rr.Body.Len() != 0 {
+			t.Errorf("%s: expected empty body but got %s", e.name, rr.Body.String())
+		}
+	}
+}
+
 func TestTools_ErrorJSON(t *testing.T) {
 	var testTools Tools
 
@@ -400,6 +756,7 @@ var xmlTests = []struct {
 	name          string
 	xml           string
 	maxBytes      int
+	maxAttributes int
 	errorExpected bool
 }{
 	{
@@ -424,6 +781,18 @@ var xmlTests = []struct {
 						<?xml version="1.0" encoding="UTF-8"?><note><to>Luke Skywalker</to><from>R2D2</from></note>`,
 		errorExpected: true,
 	},
+	{
+		name:          "Attributes within limit",
+		xml:           `<?xml version="1.0" encoding="UTF-8"?><note a="1" b="2"><to>John Smith</to><from>Jane Jones</from></note>`,
+		maxAttributes: 2,
+		errorExpected: false,
+	},
+	{
+		name:          "Too many attributes",
+		xml:           `<?xml version="1.0" encoding="UTF-8"?><note a="1" b="2" c="3"><to>John Smith</to><from>Jane Jones</from></note>`,
+		maxAttributes: 2,
+		errorExpected: true,
+	},
 }
 
 func TestTools_ReadXML(t *testing.T) {
@@ -434,6 +803,7 @@ func TestTools_ReadXML(t *testing.T) {
 		if e.maxBytes != 0 {
 			tools.MaxXMLSize = e.maxBytes
 		}
+		tools.MaxXMLAttributes = e.maxAttributes
 
 		// create a request with the body.
 		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(e.xml)))
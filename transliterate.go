@@ -0,0 +1,167 @@
+package toolkit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// latinRanges maps ranges of accented Latin letters (Latin-1 Supplement and Latin Extended-A) to their
+// closest ASCII equivalents.
+var latinRanges = []struct {
+	from, to rune
+	ascii    string
+}{
+	{0xC0, 0xC5, "a"}, {0xC6, 0xC6, "ae"}, {0xC7, 0xC7, "c"}, {0xC8, 0xCB, "e"}, {0xCC, 0xCF, "i"},
+	{0xD0, 0xD0, "d"}, {0xD1, 0xD1, "n"}, {0xD2, 0xD6, "o"}, {0xD8, 0xD8, "o"}, {0xD9, 0xDC, "u"},
+	{0xDD, 0xDD, "y"}, {0xDE, 0xDE, "th"}, {0xDF, 0xDF, "ss"}, {0xE0, 0xE5, "a"}, {0xE6, 0xE6, "ae"},
+	{0xE7, 0xE7, "c"}, {0xE8, 0xEB, "e"}, {0xEC, 0xEF, "i"}, {0xF0, 0xF0, "d"}, {0xF1, 0xF1, "n"},
+	{0xF2, 0xF6, "o"}, {0xF8, 0xF8, "o"}, {0xF9, 0xFC, "u"}, {0xFD, 0xFD, "y"}, {0xFE, 0xFE, "th"},
+	{0xFF, 0xFF, "y"}, {0x100, 0x105, "a"}, {0x106, 0x10D, "c"}, {0x10E, 0x111, "d"}, {0x112, 0x11B, "e"},
+	{0x11C, 0x123, "g"}, {0x124, 0x127, "h"}, {0x128, 0x131, "i"}, {0x132, 0x133, "ij"}, {0x134, 0x135, "j"},
+	{0x136, 0x138, "k"}, {0x139, 0x142, "l"}, {0x143, 0x14B, "n"}, {0x14C, 0x151, "o"}, {0x152, 0x153, "oe"},
+	{0x154, 0x159, "r"}, {0x15A, 0x161, "s"}, {0x162, 0x167, "t"}, {0x168, 0x173, "u"}, {0x174, 0x175, "w"},
+	{0x176, 0x178, "y"}, {0x179, 0x17E, "z"}, {0x17F, 0x17F, "s"},
+}
+
+// scriptLetters maps lower case Cyrillic and Greek letters to Latin.
+var scriptLetters = map[rune]string{
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+	'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o", 'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o",
+	'ύ': "y", 'ώ': "o", 'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+}
+
+// kanaRomaji maps hiragana to Hepburn romaji. Katakana is converted to hiragana before lookup.
+var kanaRomaji = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko", 'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so", 'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to", 'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho", 'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o", 'ゎ': "wa",
+}
+
+// smallKanaY maps the small ya, yu and yo, which combine with the kana before them (e.g. きゃ is "kya").
+var smallKanaY = map[rune]string{'ゃ': "a", 'ゅ': "u", 'ょ': "o"}
+
+// transliterate converts s to ASCII as far as possible: accented Latin letters lose their accents,
+// Cyrillic and Greek are romanized, and Japanese kana are converted to romaji. Any other non-ASCII
+// character is passed to fallback, or dropped if fallback is nil.
+func transliterate(s string, fallback func(rune) string) string {
+	var b strings.Builder
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if r < 0x80 {
+			b.WriteRune(r)
+			continue
+		}
+
+		// combining marks (e.g. the accent in a decomposed "é") are dropped
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		if ascii, ok := latinASCII(r); ok {
+			b.WriteString(ascii)
+			continue
+		}
+
+		if latin, ok := scriptLetters[unicode.ToLower(r)]; ok {
+			b.WriteString(latin)
+			continue
+		}
+
+		if isKana(r) {
+			romaji, consumed := kanaToRomaji(runes[i:])
+			b.WriteString(romaji)
+			i += consumed - 1
+			continue
+		}
+
+		if fallback != nil {
+			b.WriteString(fallback(r))
+		}
+	}
+
+	return b.String()
+}
+
+// latinASCII returns the ASCII equivalent of an accented Latin letter.
+func latinASCII(r rune) (string, bool) {
+	for _, lr := range latinRanges {
+		if r >= lr.from && r <= lr.to {
+			return lr.ascii, true
+		}
+	}
+	return "", false
+}
+
+// isKana reports whether r is hiragana, katakana, or the katakana long vowel mark.
+func isKana(r rune) bool {
+	return (r >= 0x3041 && r <= 0x3096) || (r >= 0x30A1 && r <= 0x30FC)
+}
+
+// toHiragana converts katakana to the equivalent hiragana.
+func toHiragana(r rune) rune {
+	if r >= 0x30A1 && r <= 0x30F6 {
+		return r - 0x60
+	}
+	return r
+}
+
+// kanaToRomaji converts the kana at the start of runes to romaji, and returns the number of runes used.
+func kanaToRomaji(runes []rune) (string, int) {
+	r := toHiragana(runes[0])
+
+	switch r {
+	case 'ー':
+		// the long vowel mark is dropped, as is usual in romanized slugs
+		return "", 1
+	case 'っ':
+		// a small tsu doubles the consonant which follows it
+		if len(runes) > 1 && isKana(runes[1]) {
+			next, n := kanaToRomaji(runes[1:])
+			if next != "" && !strings.ContainsRune("aeiou", rune(next[0])) {
+				if strings.HasPrefix(next, "ch") {
+					return "t" + next, n + 1
+				}
+				return next[:1] + next, n + 1
+			}
+			return next, n + 1
+		}
+		return "", 1
+	}
+
+	romaji, ok := kanaRomaji[r]
+	if !ok {
+		return "", 1
+	}
+
+	// combine with a following small ya, yu or yo
+	if len(runes) > 1 && strings.HasSuffix(romaji, "i") && len(romaji) > 1 {
+		if vowel, ok := smallKanaY[toHiragana(runes[1])]; ok {
+			stem := romaji[:len(romaji)-1]
+			if stem == "sh" || stem == "ch" || stem == "j" {
+				return stem + vowel, 2
+			}
+			return stem + "y" + vowel, 2
+		}
+	}
+
+	return romaji, 1
+}
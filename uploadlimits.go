@@ -0,0 +1,162 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sync"
+)
+
+// ErrTooManyFiles is wrapped by the error UploadFiles returns for a request with more files than MaxFiles.
+var ErrTooManyFiles = errors.New("too many files in request")
+
+// ErrTotalUploadTooLarge is wrapped by the error UploadFiles returns for a request whose files add up to
+// more than MaxTotalUploadSize bytes.
+var ErrTotalUploadTooLarge = errors.New("files in request are too large")
+
+// tooManyFilesError returns the 413 error for a request with more than max files.
+func tooManyFilesError(max int) error {
+	return &HTTPError{Status: http.StatusRequestEntityTooLarge, PublicMessage: fmt.Sprintf("request must not contain more than %d files", max), Internal: ErrTooManyFiles}
+}
+
+// totalUploadTooLargeError returns the 413 error for a request whose files add up to more than max bytes.
+func totalUploadTooLargeError(max int64) error {
+	return &HTTPError{Status: http.StatusRequestEntityTooLarge, PublicMessage: "files must not add up to more than " + HumanBytes(max), Internal: ErrTotalUploadTooLarge}
+}
+
+// limitUploadBody wraps the body of r, if the multipart parts limit, MaxFiles or MaxTotalUploadSize is set,
+// so reading it fails as soon as the multipart form it carries goes over any of them. Parsing stops there,
+// rather than after every file has been written to a temporary file. The returned func must be called once
+// the form has been parsed.
+func (t *Tools) limitUploadBody(r *http.Request) func() {
+	return t.limitMultipartBody(r, t.multipartPartsLimit(), t.MaxFiles, t.MaxTotalUploadSize)
+}
+
+// limitMultipartBody wraps the body of r, as limitUploadBody does, for forms of at most maxParts parts, and
+// maxFiles files adding up to maxTotal bytes. Zero means no limit.
+func (t *Tools) limitMultipartBody(r *http.Request, maxParts, maxFiles int, maxTotal int64) func() {
+	if maxParts <= 0 && maxFiles <= 0 && maxTotal <= 0 {
+		return func() {}
+	}
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return func() {}
+	}
+
+	pr, pw := io.Pipe()
+	l := &uploadLimitReader{ReadCloser: r.Body, pw: pw}
+	go l.watch(multipart.NewReader(pr, params["boundary"]), pr, maxParts, maxFiles, maxTotal)
+	r.Body = l
+	return func() { _ = pw.Close() }
+}
+
+// uploadLimitReader passes a multipart body through to the form parser, and a copy of it to watch, which
+// counts the parts, files and their bytes.
+type uploadLimitReader struct {
+	io.ReadCloser
+	pw  *io.PipeWriter
+	mu  sync.Mutex
+	err error
+}
+
+// Read reads from the body, failing once watch has found the form over a limit.
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	if err := l.limitErr(); err != nil {
+		return 0, err
+	}
+	n, err := l.ReadCloser.Read(p)
+	if n > 0 {
+		_, _ = l.pw.Write(p[:n])
+	}
+	if err != nil {
+		_ = l.pw.Close()
+	}
+	if limitErr := l.limitErr(); limitErr != nil {
+		return 0, limitErr
+	}
+	return n, err
+}
+
+// Close closes the body, and stops watch.
+func (l *uploadLimitReader) Close() error {
+	_ = l.pw.Close()
+	return l.ReadCloser.Close()
+}
+
+// limitErr returns the limit the form went over, if any.
+func (l *uploadLimitReader) limitErr() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// watch reads the parts of the form from mr, recording an error once there are more than maxParts parts,
+// more than maxFiles files or more than maxTotal bytes in them. Whatever happens, it reads pr to the end, so
+// Read never blocks on it.
+func (l *uploadLimitReader) watch(mr *multipart.Reader, pr *io.PipeReader, maxParts, maxFiles int, maxTotal int64) {
+	defer func() { _, _ = io.Copy(io.Discard, pr) }()
+
+	parts, files, total := 0, 0, int64(0)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			// the end of the form, or a malformed one, which the form parser reports
+			return
+		}
+		parts++
+		if maxParts > 0 && parts > maxParts {
+			l.fail(&partsLimitError{limit: maxParts})
+			return
+		}
+		if part.FileName() == "" {
+			continue
+		}
+
+		files++
+		if maxFiles > 0 && files > maxFiles {
+			l.fail(tooManyFilesError(maxFiles))
+			return
+		}
+		n, _ := io.Copy(io.Discard, part)
+		total += n
+		if maxTotal > 0 && total > maxTotal {
+			l.fail(totalUploadTooLargeError(maxTotal))
+			return
+		}
+	}
+}
+
+// fail records err as the limit the form went over.
+func (l *uploadLimitReader) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err = err
+}
+
+// checkUploadLimits returns an error if form has more files than MaxFiles, or files adding up to more than
+// MaxTotalUploadSize bytes, removing any files it holds. It catches what limitUploadBody lets through
+// while the parser is ahead of it.
+func (t *Tools) checkUploadLimits(form *multipart.Form) error {
+	files, total := 0, int64(0)
+	for _, hdrs := range form.File {
+		files += len(hdrs)
+		for _, hdr := range hdrs {
+			total += hdr.Size
+		}
+	}
+
+	var err error
+	switch {
+	case t.MaxFiles > 0 && files > t.MaxFiles:
+		err = tooManyFilesError(t.MaxFiles)
+	case t.MaxTotalUploadSize > 0 && total > t.MaxTotalUploadSize:
+		err = totalUploadTooLargeError(t.MaxTotalUploadSize)
+	default:
+		return nil
+	}
+	_ = form.RemoveAll()
+	return err
+}
@@ -0,0 +1,102 @@
+package toolkit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// multipartFiles returns a multipart body with n files of size bytes each, and its content type.
+func multipartFiles(n, size int) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for i := 0; i < n; i++ {
+		w, _ := mw.CreateFormFile(fmt.Sprintf("file%d", i), fmt.Sprintf("file%d.txt", i))
+		_, _ = w.Write(bytes.Repeat([]byte("a"), size))
+	}
+	_ = mw.WriteField("title", "files")
+	_ = mw.Close()
+	return body, mw.FormDataContentType()
+}
+
+func TestTools_UploadFiles_UploadLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxFiles  int
+		maxTotal  int64
+		files     int
+		size      int
+		expected  error
+		errorText string
+	}{
+		{name: "within limits", maxFiles: 3, maxTotal: 300, files: 3, size: 100},
+		{name: "too many files", maxFiles: 3, files: 4, size: 10, expected: ErrTooManyFiles, errorText: "request must not contain more than 3 files"},
+		{name: "far too many files", maxFiles: 3, files: 1000, size: 10, expected: ErrTooManyFiles},
+		{name: "too large", maxTotal: 250, files: 3, size: 100, expected: ErrTotalUploadTooLarge, errorText: "files must not add up to more than 250 bytes"},
+		{name: "far too large", maxTotal: 1 << 20, files: 20, size: 1 << 20, expected: ErrTotalUploadTooLarge},
+	}
+
+	for _, e := range tests {
+		dir := t.TempDir()
+		testTools := Tools{MaxFiles: e.maxFiles, MaxTotalUploadSize: e.maxTotal}
+		body, contentType := multipartFiles(e.files, e.size)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		files, err := testTools.UploadFiles(request, dir)
+		if e.expected == nil {
+			if err != nil || len(files) != e.files {
+				t.Errorf("%s: expected %d files, got %d and %v", e.name, e.files, len(files), err)
+			}
+			continue
+		}
+		if !errors.Is(err, e.expected) {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expected, err)
+			continue
+		}
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || httpErr.Status != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected a 413 error, got %v", e.name, err)
+		}
+		if e.errorText != "" && httpErr.PublicMessage != e.errorText {
+			t.Errorf("%s: expected the message %q, got %q", e.name, e.errorText, httpErr.PublicMessage)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("%s: expected nothing stored, got %d files", e.name, len(entries))
+		}
+		// parsing stopped at the limit, rather than reading the whole body
+		if e.files*e.size > 1<<20 && body.Len() == 0 {
+			t.Errorf("%s: expected the body not to be read to the end", e.name)
+		}
+	}
+}
+
+func TestTools_checkUploadLimits(t *testing.T) {
+	body, contentType := multipartFiles(3, 100)
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Add("Content-Type", contentType)
+	if err := request.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		tools    Tools
+		expected error
+	}{
+		{name: "no limits", tools: Tools{}},
+		{name: "within limits", tools: Tools{MaxFiles: 3, MaxTotalUploadSize: 300}},
+		{name: "too many files", tools: Tools{MaxFiles: 2}, expected: ErrTooManyFiles},
+		{name: "too large", tools: Tools{MaxTotalUploadSize: 299}, expected: ErrTotalUploadTooLarge},
+	}
+	for _, e := range tests {
+		if err := e.tools.checkUploadLimits(request.MultipartForm); !errors.Is(err, e.expected) || (e.expected == nil && err != nil) {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expected, err)
+		}
+	}
+}
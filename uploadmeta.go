@@ -0,0 +1,97 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// uploaderIDKey is the context key for the uploader ID set by WithUploaderID.
+const uploaderIDKey contextKey = "uploaderID"
+
+// uploadMetadataSuffix is appended to the name of an upload to name its metadata sidecar.
+const uploadMetadataSuffix = ".meta.json"
+
+// UploadMetadata describes a file received by UploadFiles. It is passed to MetadataStore and written to the
+// sidecar file when WriteUploadMetadata is set.
+type UploadMetadata struct {
+	NewFileName      string         `json:"new_file_name"`
+	OriginalFileName string         `json:"original_file_name"`
+	ContentType      string         `json:"content_type"`
+	Size             int64          `json:"size"`
+	SHA256           string         `json:"sha256"`
+	UploaderID       string         `json:"uploader_id,omitempty"`
+	UploadedAt       time.Time      `json:"uploaded_at"`
+	Duplicate        bool           `json:"duplicate,omitempty"`
+	Image            *ImageMetadata `json:"image,omitempty"`
+	Media            *MediaInfo     `json:"media,omitempty"`
+	ConvertedFrom    string         `json:"converted_from,omitempty"`
+	OriginalCopy     string         `json:"original_copy,omitempty"`
+}
+
+// MetadataStore receives the metadata of each file received by UploadFiles, e.g. to save it in a database.
+type MetadataStore interface {
+	// SaveMetadata is called once a file has been stored, or found to be a duplicate of one already stored.
+	// An error fails the upload.
+	SaveMetadata(ctx context.Context, meta UploadMetadata) error
+}
+
+// WithUploaderID returns a copy of ctx carrying the ID of the user making an upload, which UploadFiles
+// includes in the upload metadata. Authentication middleware would usually set it on the request context.
+func WithUploaderID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, uploaderIDKey, id)
+}
+
+// UploaderIDFromContext returns the uploader ID set by WithUploaderID, or an empty string if there isn't one.
+func UploaderIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(uploaderIDKey).(string)
+	return id
+}
+
+// recordsUploadMetadata reports whether the metadata of uploads is kept.
+func (t *Tools) recordsUploadMetadata() bool {
+	return t.MetadataStore != nil || t.WriteUploadMetadata
+}
+
+// saveUploadMetadata passes the metadata of file to MetadataStore and writes its sidecar, as configured.
+// Duplicates already have a sidecar, so it isn't written again.
+func (t *Tools) saveUploadMetadata(ctx context.Context, uploadDir string, file *UploadedFile) error {
+	if !t.recordsUploadMetadata() {
+		return nil
+	}
+
+	meta := UploadMetadata{
+		NewFileName:      file.NewFileName,
+		OriginalFileName: file.OriginalFileName,
+		ContentType:      file.ContentType,
+		Size:             file.FileSize,
+		SHA256:           file.SHA256,
+		UploaderID:       UploaderIDFromContext(ctx),
+		UploadedAt:       time.Now().UTC(),
+		Duplicate:        file.Duplicate,
+		Image:            file.Image,
+		Media:            file.Media,
+		ConvertedFrom:    file.ConvertedFrom,
+		OriginalCopy:     file.OriginalCopy,
+	}
+
+	if t.WriteUploadMetadata && !file.Duplicate {
+		out, err := json.MarshalIndent(meta, "", "  ")
+		if err != nil {
+			return err
+		}
+		p, err := t.EnsureWithinBase(uploadDir, file.NewFileName+uploadMetadataSuffix)
+		if err != nil {
+			return err
+		}
+		if _, err := writeFileAtomic(p, bytes.NewReader(out), t.SyncUploadDir); err != nil {
+			return err
+		}
+	}
+
+	if t.MetadataStore != nil {
+		return t.MetadataStore.SaveMetadata(ctx, meta)
+	}
+	return nil
+}
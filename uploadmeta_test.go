@@ -0,0 +1,104 @@
+package toolkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testMetadataStore struct {
+	saved []UploadMetadata
+	err   error
+}
+
+func (s *testMetadataStore) SaveMetadata(ctx context.Context, meta UploadMetadata) error {
+	s.saved = append(s.saved, meta)
+	return s.err
+}
+
+func uploadTestImage(t *testing.T, ctx context.Context, tools *Tools, dir string) (*UploadedFile, error) {
+	t.Helper()
+	body, contentType := tools.BuildMultipartBody([]MultipartFile{{FieldName: "file", Path: "./testdata/img.png"}}, nil)
+	request := httptest.NewRequest(http.MethodPost, "/upload", body).WithContext(ctx)
+	request.Header.Add("Content-Type", contentType)
+	return tools.UploadOneFile(request, dir)
+}
+
+func TestTools_UploadFiles_Metadata(t *testing.T) {
+	dir := t.TempDir()
+	store := &testMetadataStore{}
+	testTools := Tools{MetadataStore: store, WriteUploadMetadata: true}
+
+	before := time.Now().UTC()
+	file, err := uploadTestImage(t, WithUploaderID(context.Background(), "user-42"), &testTools, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(store.saved) != 1 {
+		t.Fatalf("expected metadata to be saved once, got %d", len(store.saved))
+	}
+	meta := store.saved[0]
+	if meta.NewFileName != file.NewFileName || meta.OriginalFileName != "img.png" || meta.ContentType != "image/png" ||
+		meta.Size != file.FileSize || meta.UploaderID != "user-42" || meta.Duplicate {
+		t.Errorf("wrong metadata %+v", meta)
+	}
+	if len(meta.SHA256) != 64 || meta.SHA256 != file.SHA256 {
+		t.Errorf("wrong hash %q", meta.SHA256)
+	}
+	if meta.UploadedAt.Before(before) {
+		t.Errorf("wrong upload time %s", meta.UploadedAt)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, file.NewFileName+".meta.json"))
+	if err != nil {
+		t.Fatalf("sidecar not written: %s", err)
+	}
+	var sidecar UploadMetadata
+	if err := json.Unmarshal(b, &sidecar); err != nil {
+		t.Fatalf("sidecar is not valid JSON: %s", err)
+	}
+	if !sidecar.UploadedAt.Equal(meta.UploadedAt) {
+		t.Errorf("sidecar time %s doesn't match %s", sidecar.UploadedAt, meta.UploadedAt)
+	}
+	sidecar.UploadedAt = meta.UploadedAt
+	if sidecar != meta {
+		t.Errorf("sidecar %+v doesn't match metadata %+v", sidecar, meta)
+	}
+}
+
+func TestTools_UploadFiles_MetadataDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	store := &testMetadataStore{}
+	testTools := Tools{MetadataStore: store, DuplicateChecker: NewMemoryDuplicateChecker()}
+
+	for i := 0; i < 2; i++ {
+		if _, err := uploadTestImage(t, context.Background(), &testTools, dir); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if len(store.saved) != 2 {
+		t.Fatalf("expected metadata to be saved twice, got %d", len(store.saved))
+	}
+	if store.saved[0].Duplicate || !store.saved[1].Duplicate {
+		t.Error("only the second upload should be marked as a duplicate")
+	}
+	if store.saved[0].NewFileName != store.saved[1].NewFileName || store.saved[0].SHA256 != store.saved[1].SHA256 {
+		t.Error("duplicate metadata should refer to the stored file")
+	}
+}
+
+func TestTools_UploadFiles_MetadataStoreError(t *testing.T) {
+	testTools := Tools{MetadataStore: &testMetadataStore{err: errors.New("database unavailable")}}
+	_, err := uploadTestImage(t, context.Background(), &testTools, t.TempDir())
+	if err == nil || err.Error() != "database unavailable" {
+		t.Errorf("expected the store's error, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrUploadSkipped is the error of the files in a request UploadFiles didn't try to store, because an earlier
+// file failed.
+var ErrUploadSkipped = errors.New("upload skipped after an earlier file failed")
+
+// UploadResult is the outcome of one file in a request to UploadFiles.
+type UploadResult struct {
+	FieldName  string        // the form field the file was sent in
+	FileName   string        // the name the client gave the file
+	File       *UploadedFile // the file as stored, if it was written
+	Err        error         // why the file failed, ErrUploadSkipped if it wasn't tried, or nil if it was stored
+	RolledBack bool          // true if the file was written, then removed because RollbackUploads is set
+}
+
+// UploadError is returned by UploadFiles when a file in the request fails. Its message is that of the
+// file's error, which it wraps, and Results lists the outcome of every file in the request.
+type UploadError struct {
+	Err     error          // the error of the file which failed
+	Results []UploadResult // the outcome of each file, in the order they were handled
+}
+
+// Error returns the message of the file's error.
+func (e *UploadError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the file's error, so errors.Is and errors.As see through an UploadError.
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+// MetadataDeleter is implemented by a MetadataStore which can also delete the metadata it has saved. If
+// MetadataStore implements it, the metadata of files removed because RollbackUploads is set is deleted.
+type MetadataDeleter interface {
+	// DeleteMetadata deletes the metadata saved for the stored file name.
+	DeleteMetadata(ctx context.Context, name string) error
+}
+
+// rollbackUploads removes the files written for the request reported by uploadErr, with their metadata
+// sidecars and kept originals, and marks them as rolled back. Duplicates, which were already stored, are
+// left alone. Files which can't be removed are logged.
+func (t *Tools) rollbackUploads(ctx context.Context, uploadDir string, uploadErr *UploadError) {
+	for i := range uploadErr.Results {
+		file := uploadErr.Results[i].File
+		if file == nil || file.Duplicate {
+			continue
+		}
+
+		paths := []string{filepath.Join(uploadDir, file.NewFileName), filepath.Join(uploadDir, file.NewFileName+uploadMetadataSuffix)}
+		if file.OriginalCopy != "" {
+			paths = append(paths, filepath.Join(tenantDir(ctx, t.OriginalsDir), file.OriginalCopy))
+		}
+		for _, p := range paths {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				t.LogError(ctx, "could not roll back upload", "file", p, "error", err)
+			}
+		}
+		if deleter, ok := t.MetadataStore.(MetadataDeleter); ok {
+			if err := deleter.DeleteMetadata(ctx, file.NewFileName); err != nil {
+				t.LogError(ctx, "could not delete metadata of rolled back upload", "file", file.NewFileName, "error", err)
+			}
+		}
+		uploadErr.Results[i].RolledBack = true
+	}
+}
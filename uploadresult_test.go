@@ -0,0 +1,114 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// failingMetadataStore is a MetadataStore and MetadataDeleter which fails to save the metadata of files
+// called failName, and records the names deleted.
+type failingMetadataStore struct {
+	failName string
+	deleted  []string
+}
+
+func (s *failingMetadataStore) SaveMetadata(ctx context.Context, meta UploadMetadata) error {
+	if meta.OriginalFileName == s.failName {
+		return errors.New("database unavailable")
+	}
+	return nil
+}
+
+func (s *failingMetadataStore) DeleteMetadata(ctx context.Context, name string) error {
+	s.deleted = append(s.deleted, name)
+	return nil
+}
+
+func TestTools_UploadFiles_Rollback(t *testing.T) {
+	text := filepath.Join(t.TempDir(), "notes.txt")
+	_ = os.WriteFile(text, []byte("some notes, which aren't an image"), 0644)
+	files := []MultipartFile{
+		{FieldName: "a", Path: "./testdata/img.png"},
+		{FieldName: "b", Path: text},
+		{FieldName: "c", Path: "./testdata/pic.jpg"},
+	}
+
+	tests := []struct {
+		name       string
+		rollback   bool
+		store      *failingMetadataStore
+		failedName string
+		kept       int
+	}{
+		{name: "rejected, kept", failedName: "notes.txt", kept: 1},
+		{name: "rejected, rolled back", rollback: true, failedName: "notes.txt"},
+		{name: "metadata fails, rolled back", rollback: true, store: &failingMetadataStore{failName: "img.png"}, failedName: "img.png"},
+	}
+
+	for _, e := range tests {
+		dir := t.TempDir()
+		testTools := Tools{AllowedFileTypes: []string{"image/*"}, WriteUploadMetadata: true, RollbackUploads: e.rollback}
+		if e.store != nil {
+			testTools.AllowedFileTypes = nil
+			testTools.MetadataStore = e.store
+		}
+		body, contentType := testTools.BuildMultipartBody(files, nil)
+		request := httptest.NewRequest(http.MethodPost, "/upload", body)
+		request.Header.Add("Content-Type", contentType)
+
+		uploaded, err := testTools.UploadFiles(request, dir)
+		var uploadErr *UploadError
+		if !errors.As(err, &uploadErr) {
+			t.Errorf("%s: expected an *UploadError, got %v", e.name, err)
+			continue
+		}
+		if len(uploaded) != e.kept {
+			t.Errorf("%s: expected %d files returned, got %d", e.name, e.kept, len(uploaded))
+		}
+
+		// the files are handled in the order of their fields, stopping at the one which fails
+		if len(uploadErr.Results) != 3 {
+			t.Fatalf("%s: expected 3 results, got %d", e.name, len(uploadErr.Results))
+		}
+		failed := false
+		for i, res := range uploadErr.Results {
+			if res.FieldName != files[i].FieldName {
+				t.Errorf("%s: expected result %d for field %s, got %s", e.name, i, files[i].FieldName, res.FieldName)
+			}
+			switch {
+			case res.FileName == e.failedName:
+				failed = true
+				if res.Err == nil || res.Err == ErrUploadSkipped || !errors.Is(err, res.Err) {
+					t.Errorf("%s: expected %s to have failed with the returned error, got %v", e.name, res.FileName, res.Err)
+				}
+			case failed:
+				if res.Err != ErrUploadSkipped || res.File != nil {
+					t.Errorf("%s: expected %s to be skipped, got %v", e.name, res.FileName, res.Err)
+				}
+			default:
+				if res.Err != nil || res.File == nil {
+					t.Errorf("%s: expected %s to be stored, got %v", e.name, res.FileName, res.Err)
+				}
+			}
+			if res.File != nil && res.RolledBack != e.rollback {
+				t.Errorf("%s: expected %s rolled back to be %t", e.name, res.FileName, e.rollback)
+			}
+		}
+
+		// nothing is left behind once rolled back
+		entries, _ := os.ReadDir(dir)
+		if expected := e.kept * 2; len(entries) != expected {
+			t.Errorf("%s: expected %d files and sidecars in the upload directory, got %d", e.name, expected, len(entries))
+		}
+		if e.store != nil {
+			if written := uploadErr.Results[0].File; written == nil || len(e.store.deleted) != 1 || e.store.deleted[0] != written.NewFileName {
+				t.Errorf("%s: expected the metadata of the written file to be deleted, got %v", e.name, e.store.deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// minURLSigningKeyLength the shortest URLSigningKey Validate accepts
+const minURLSigningKeyLength = 16
+
+// Validate checks the configuration for mistakes which would otherwise only show up when a request
+// fails: negative limits, limits which contradict each other, malformed keys, and upload directories
+// which can't be written to. Every problem found is returned, joined into a single error, so they can all
+// be fixed at once; nil means the configuration looks good.
+func (t *Tools) Validate(uploadDirs ...string) error {
+	var errs []error
+
+	limits := []struct {
+		name  string
+		value int64
+	}{
+		{"MaxJSONSize", int64(t.MaxJSONSize)},
+		{"MaxXMLSize", int64(t.MaxXMLSize)},
+		{"MaxFormSize", int64(t.MaxFormSize)},
+		{"MaxJSONArraySize", int64(t.MaxJSONArraySize)},
+		{"MaxDecompressedSize", int64(t.MaxDecompressedSize)},
+		{"MaxFileSize", int64(t.MaxFileSize)},
+		{"MultipartMemoryLimit", int64(t.MultipartMemoryLimit)},
+		{"MaxSlugLength", int64(t.MaxSlugLength)},
+		{"MaxXMLAttributes", int64(t.MaxXMLAttributes)},
+		{"MaxXMLDepth", int64(t.MaxXMLDepth)},
+		{"MaxXMLTokens", int64(t.MaxXMLTokens)},
+		{"MaxMultipartParts", int64(t.MaxMultipartParts)},
+		{"MaxFiles", int64(t.MaxFiles)},
+		{"MaxTotalUploadSize", t.MaxTotalUploadSize},
+		{"Limits.MaxBody", int64(t.Limits.MaxBody)},
+		{"Limits.MaxMultipartParts", int64(t.Limits.MaxMultipartParts)},
+		{"Limits.MaxHeaders", int64(t.Limits.MaxHeaders)},
+		{"Limits.MaxDepth", int64(t.Limits.MaxDepth)},
+	}
+	for _, l := range limits {
+		if l.value < 0 {
+			errs = append(errs, fmt.Errorf("%s is %d; it must be zero (for the default) or positive", l.name, l.value))
+		}
+	}
+
+	if t.MultipartMemoryLimit > 0 && t.MaxFileSize > 0 && t.MultipartMemoryLimit > t.MaxFileSize {
+		errs = append(errs, fmt.Errorf("MultipartMemoryLimit (%d) is larger than MaxFileSize (%d); lower it, or files will be held in memory in full", t.MultipartMemoryLimit, t.MaxFileSize))
+	}
+
+	if t.MaxFileSize > 0 && t.MaxTotalUploadSize > 0 && int64(t.MaxFileSize) > t.MaxTotalUploadSize {
+		errs = append(errs, fmt.Errorf("MaxFileSize (%d) is larger than MaxTotalUploadSize (%d); a file that large would be refused anyway", t.MaxFileSize, t.MaxTotalUploadSize))
+	}
+
+	seen := make(map[string]bool)
+	for i, k := range t.EncryptionKeys {
+		if len(k.Key) != 32 {
+			errs = append(errs, fmt.Errorf("EncryptionKeys[%d] (%q) is %d bytes; it must be 32 bytes, so use NewEncryptionKey to derive it", i, k.ID, len(k.Key)))
+		}
+		if len(k.ID) > 255 {
+			errs = append(errs, fmt.Errorf("EncryptionKeys[%d] has an ID longer than 255 bytes", i))
+		}
+		if seen[k.ID] {
+			errs = append(errs, fmt.Errorf("EncryptionKeys[%d] reuses the ID %q; every key needs a unique ID", i, k.ID))
+		}
+		seen[k.ID] = true
+	}
+
+	if len(t.URLSigningKey) > 0 && len(t.URLSigningKey) < minURLSigningKeyLength {
+		errs = append(errs, fmt.Errorf("URLSigningKey is %d bytes; use at least %d random bytes", len(t.URLSigningKey), minURLSigningKeyLength))
+	}
+
+	if t.QuarantineDir != "" {
+		uploadDirs = append(uploadDirs, t.QuarantineDir)
+	}
+	if t.OriginalsDir != "" {
+		uploadDirs = append(uploadDirs, t.OriginalsDir)
+	}
+	for _, dir := range uploadDirs {
+		if err := checkWritableDir(dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Serve validates the configuration, including the upload directories given, and only starts srv if no
+// problems were found, so a misconfigured service fails at startup rather than on its first request.
+func (t *Tools) Serve(srv *http.Server, uploadDirs ...string) error {
+	if err := t.Validate(uploadDirs...); err != nil {
+		return fmt.Errorf("invalid toolkit configuration:\n%w", err)
+	}
+	return srv.ListenAndServe()
+}
+
+// checkWritableDir returns an error unless dir is a writable directory, or doesn't exist yet but could be
+// created by CreateDirIfNotExist.
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		parent := filepath.Dir(filepath.Clean(dir))
+		if parent == dir {
+			return fmt.Errorf("upload directory %s does not exist", dir)
+		}
+		if err := checkWritableDir(parent); err != nil {
+			return fmt.Errorf("upload directory %s does not exist, and can't be created: %w", dir, err)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("upload directory %s can't be read: %w", dir, err)
+	case !info.IsDir():
+		return fmt.Errorf("upload directory %s is not a directory", dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".toolkit-check-*")
+	if err != nil {
+		return fmt.Errorf("upload directory %s is not writable: %w", dir, err)
+	}
+	name := f.Name()
+	_ = f.Close()
+	_ = os.Remove(name)
+
+	return nil
+}
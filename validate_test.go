@@ -0,0 +1,73 @@
+package toolkit
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var validateTests = []struct {
+	name          string
+	tools         Tools
+	uploadDirs    []string
+	errorsContain []string
+}{
+	{name: "zero value", tools: Tools{}, errorsContain: nil},
+	{name: "defaults", tools: New(), uploadDirs: []string{"./testdata/uploads", "./testdata/not-yet/created"}, errorsContain: nil},
+	{name: "negative limit", tools: Tools{MaxJSONSize: -1}, errorsContain: []string{"MaxJSONSize"}},
+	{name: "contradictory limits", tools: Tools{MaxFileSize: 10, MultipartMemoryLimit: 20}, errorsContain: []string{"MultipartMemoryLimit"}},
+	{name: "negative upload limits", tools: Tools{MaxFiles: -1, MaxTotalUploadSize: -1}, errorsContain: []string{"MaxFiles is -1", "MaxTotalUploadSize is -1"}},
+	{name: "file larger than total", tools: Tools{MaxFileSize: 20, MaxTotalUploadSize: 10}, errorsContain: []string{"MaxFileSize (20) is larger than MaxTotalUploadSize (10)"}},
+	{name: "bad encryption keys", tools: Tools{EncryptionKeys: []EncryptionKey{{ID: "a", Key: []byte("short")}, {ID: "a", Key: bytes.Repeat([]byte{1}, 32)}}}, errorsContain: []string{"must be 32 bytes", "unique ID"}},
+	{name: "short signing key", tools: Tools{URLSigningKey: []byte("short")}, errorsContain: []string{"URLSigningKey"}},
+	{name: "upload dir is a file", tools: Tools{}, uploadDirs: []string{"./testdata/pic.jpg"}, errorsContain: []string{"not a directory"}},
+	{name: "several problems", tools: Tools{MaxXMLSize: -1, URLSigningKey: []byte("short")}, errorsContain: []string{"MaxXMLSize", "URLSigningKey"}},
+}
+
+func TestTools_Validate(t *testing.T) {
+	for _, e := range validateTests {
+		err := e.tools.Validate(e.uploadDirs...)
+		if len(e.errorsContain) == 0 {
+			if err != nil {
+				t.Errorf("%s: error not expected but one received: %s", e.name, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+			continue
+		}
+		for _, s := range e.errorsContain {
+			if !strings.Contains(err.Error(), s) {
+				t.Errorf("%s: expected error to mention %q, but got %s", e.name, s, err)
+			}
+		}
+	}
+}
+
+func TestTools_Validate_UnwritableDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced for root")
+	}
+
+	var testTools Tools
+	dir := filepath.Join(t.TempDir(), "readonly")
+	_ = os.Mkdir(dir, 0555)
+
+	if err := testTools.Validate(dir); err == nil {
+		t.Error("expected an error for an unwritable directory, but none received")
+	}
+}
+
+func TestTools_Serve(t *testing.T) {
+	testTools := Tools{MaxJSONSize: -1}
+
+	err := testTools.Serve(&http.Server{Addr: "127.0.0.1:0"})
+	if err == nil || !strings.Contains(err.Error(), "MaxJSONSize") {
+		t.Errorf("expected Serve to refuse an invalid configuration, but got %v", err)
+	}
+}
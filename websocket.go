@@ -0,0 +1,386 @@
+package toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webSocketGUID is appended to the client's key to compute Sec-WebSocket-Accept (RFC 6455, section 1.3).
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// defaultWebSocketPingInterval how often pings are sent to keep a connection alive
+const defaultWebSocketPingInterval = 30 * time.Second
+
+// webSocketWriteTimeout the time allowed to write a single frame
+const webSocketWriteTimeout = 10 * time.Second
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WebSocket close codes.
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseInvalidData   = 1007
+	wsCloseTooBig        = 1009
+)
+
+// ErrWebSocketClosed is returned by ReadJSONMessage once the client has closed the connection.
+var ErrWebSocketClosed = errors.New("websocket connection closed")
+
+// WebSocketOptions configures UpgradeWebSocket.
+type WebSocketOptions struct {
+	AllowedOrigins []string      // origins which may connect, as in CORSOptions; if empty, only the same host may connect
+	PingInterval   time.Duration // how often to ping the client; defaults to 30 seconds, negative disables pings
+}
+
+// WebSocketConn is a WebSocket connection carrying JSON messages. Messages may be written from several
+// goroutines, but should only be read from one.
+type WebSocketConn struct {
+	tools        *Tools
+	conn         net.Conn
+	br           *bufio.Reader
+	pingInterval time.Duration
+	writeMu      sync.Mutex
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+// UpgradeWebSocket upgrades the request to a WebSocket connection. If the handshake is invalid, or the
+// origin isn't allowed, an error response is sent with ErrorJSON and the error is returned. Unless
+// disabled, the client is pinged every PingInterval, and a connection which sends nothing (not even a pong)
+// for two intervals is treated as dead.
+func (t *Tools) UpgradeWebSocket(w http.ResponseWriter, r *http.Request, opts WebSocketOptions) (*WebSocketConn, error) {
+	key, status, err := checkWebSocketHandshake(r, opts.AllowedOrigins)
+	if err != nil {
+		if status == http.StatusUpgradeRequired {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+		}
+		_ = t.ErrorJSON(w, err, status)
+		return nil, err
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		_ = t.ErrorJSON(w, errors.New("websocket upgrade not supported"), http.StatusInternalServerError)
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+
+	_ = conn.SetDeadline(time.Time{})
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	interval := opts.PingInterval
+	if interval == 0 {
+		interval = defaultWebSocketPingInterval
+	}
+
+	c := &WebSocketConn{tools: t, conn: conn, br: brw.Reader, pingInterval: interval, done: make(chan struct{})}
+	if interval > 0 {
+		c.extendReadDeadline()
+		go c.keepAlive()
+	}
+	return c, nil
+}
+
+// checkWebSocketHandshake validates an upgrade request, returning the client's key, or the status and
+// error to respond with.
+func checkWebSocketHandshake(r *http.Request, allowedOrigins []string) (string, int, error) {
+	if r.Method != http.MethodGet {
+		return "", http.StatusMethodNotAllowed, errors.New("websocket upgrade requires GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return "", http.StatusBadRequest, errors.New("request is not a websocket upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return "", http.StatusUpgradeRequired, errors.New("unsupported websocket version")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return "", http.StatusBadRequest, errors.New("invalid Sec-WebSocket-Key")
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		sameHost := err == nil && strings.EqualFold(u.Host, r.Host)
+		if !sameHost && !originAllowed(origin, allowedOrigins) {
+			return "", http.StatusForbidden, errors.New("origin not allowed")
+		}
+	}
+
+	return key, 0, nil
+}
+
+// headerContainsToken reports whether the comma-separated header contains token, ignoring case.
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadJSONMessage reads the next text or binary message and decodes it into data, which should be a
+// pointer. Messages are subject to the same limits (Limits.MaxBody or MaxJSONSize, and Limits.MaxDepth)
+// and unknown field rules as ReadJSON, and decoding errors are described the same way. A message which is
+// too large closes the connection. Pings are answered while waiting. Once the client closes the
+// connection, ErrWebSocketClosed is returned.
+func (c *WebSocketConn) ReadJSONMessage(data any) error {
+	maxBytes := c.tools.bodyLimit(c.tools.MaxJSONSize, defaultMaxUpload)
+
+	msg, err := c.readMessage(int64(maxBytes))
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(c.tools.limitJSONDepth(bytes.NewReader(msg), "message"))
+	if !c.tools.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(data); err != nil {
+		return jsonDecodeError("message", err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return newMessageError(MsgJSONOneValue, "message")
+	}
+	return nil
+}
+
+// WriteJSONMessage sends data to the client as a JSON text message, rendered the same way as WriteJSON.
+func (c *WebSocketConn) WriteJSONMessage(data any) error {
+	buf, err := c.tools.encodeJSON(data)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(buf)
+	b := buf.Bytes()
+	return c.writeFrame(wsText, b)
+}
+
+// WriteErrorMessage sends err to the client in the same envelope as ErrorJSON.
+func (c *WebSocketConn) WriteErrorMessage(err error) error {
+	return c.WriteJSONMessage(JSONResponse{Error: true, Message: err.Error()})
+}
+
+// Close sends a normal close frame and closes the connection.
+func (c *WebSocketConn) Close() error {
+	return c.closeWith(wsCloseNormal, "")
+}
+
+// Done returns a channel which is closed when the connection is closed.
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// closeWith sends a close frame with code and reason, then closes the connection.
+func (c *WebSocketConn) closeWith(code int, reason string) error {
+	err := ErrWebSocketClosed
+	c.closeOnce.Do(func() {
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+		_ = c.writeFrame(wsClose, payload)
+
+		close(c.done)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// keepAlive pings the client every interval until the connection is closed.
+func (c *WebSocketConn) keepAlive() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(wsPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// extendReadDeadline gives the client another two ping intervals to send something.
+func (c *WebSocketConn) extendReadDeadline() {
+	if c.pingInterval > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(2 * c.pingInterval))
+	}
+}
+
+// readMessage reads frames until a complete data message of at most limit bytes has arrived, handling
+// control frames along the way.
+func (c *WebSocketConn) readMessage(limit int64) ([]byte, error) {
+	var msg []byte
+	inMessage := false
+
+	for {
+		fin, opcode, payload, err := c.readFrame(limit - int64(len(msg)))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesError):
+				_ = c.closeWith(wsCloseTooBig, "message too large")
+				return nil, fmt.Errorf("message must not be larger than %d bytes", limit)
+			case errors.Is(err, errWebSocketProtocol):
+				_ = c.closeWith(wsCloseProtocolError, err.Error())
+			default:
+				c.closeOnce.Do(func() {
+					close(c.done)
+					_ = c.conn.Close()
+				})
+			}
+			return nil, err
+		}
+		c.extendReadDeadline()
+
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			_ = c.closeWith(wsCloseNormal, "")
+			return nil, ErrWebSocketClosed
+		case wsText, wsBinary:
+			if inMessage {
+				_ = c.closeWith(wsCloseProtocolError, "expected continuation frame")
+				return nil, errWebSocketProtocol
+			}
+			inMessage = true
+		case wsContinuation:
+			if !inMessage {
+				_ = c.closeWith(wsCloseProtocolError, "unexpected continuation frame")
+				return nil, errWebSocketProtocol
+			}
+		default:
+			_ = c.closeWith(wsCloseInvalidData, "unknown opcode")
+			return nil, errWebSocketProtocol
+		}
+
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// errWebSocketProtocol is returned when the client breaks the WebSocket protocol.
+var errWebSocketProtocol = errors.New("websocket protocol error")
+
+// readFrame reads a single frame, refusing data frames with a payload larger than limit.
+func (c *WebSocketConn) readFrame(limit int64) (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7F)
+
+	// Extensions aren't negotiated, so the reserved bits must be clear, and clients must mask their frames.
+	if header[0]&0x70 != 0 || !masked {
+		return false, 0, nil, errWebSocketProtocol
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	isControl := opcode&0x08 != 0
+	if isControl && (length > 125 || !fin) {
+		return false, 0, nil, errWebSocketProtocol
+	}
+	if !isControl && (length < 0 || length > limit) {
+		return false, 0, nil, &http.MaxBytesError{Limit: limit}
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unmasked frame, as servers must.
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|opcode)
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
@@ -0,0 +1,226 @@
+package toolkit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestClient is a minimal WebSocket client for exercising the server side.
+type wsTestClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, url string, headers map[string]string) (*wsTestClient, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req := "GET / HTTP/1.1\r\nHost: " + strings.TrimPrefix(url, "http://") + "\r\n"
+	for k, v := range headers {
+		req += k + ": " + v + "\r\n"
+	}
+	if _, err := conn.Write([]byte(req + "\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsTestClient{conn: conn, br: br}, resp
+}
+
+func (c *wsTestClient) send(opcode byte, fin bool, payload []byte) error {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func (c *wsTestClient) receive() (byte, []byte, error) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err := io.ReadFull(c.br, payload)
+	return header[0] & 0x0F, payload, err
+}
+
+var validWebSocketHeaders = map[string]string{
+	"Connection":            "keep-alive, Upgrade",
+	"Upgrade":               "websocket",
+	"Sec-WebSocket-Version": "13",
+	"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+}
+
+var webSocketHandshakeTests = []struct {
+	name           string
+	headers        map[string]string
+	expectedStatus int
+}{
+	{name: "valid", headers: validWebSocketHeaders, expectedStatus: http.StatusSwitchingProtocols},
+	{name: "not an upgrade", headers: map[string]string{"Sec-WebSocket-Version": "13"}, expectedStatus: http.StatusBadRequest},
+	{name: "wrong version", headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="}, expectedStatus: http.StatusUpgradeRequired},
+	{name: "bad key", headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "short"}, expectedStatus: http.StatusBadRequest},
+	{name: "allowed origin", headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Origin": "https://app.example.com"}, expectedStatus: http.StatusSwitchingProtocols},
+	{name: "disallowed origin", headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Origin": "https://evil.com"}, expectedStatus: http.StatusForbidden},
+}
+
+func TestTools_UpgradeWebSocket(t *testing.T) {
+	var testTools Tools
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := testTools.UpgradeWebSocket(w, r, WebSocketOptions{AllowedOrigins: []string{"https://*.example.com"}})
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	for _, e := range webSocketHandshakeTests {
+		_, resp := dialWebSocket(t, srv.URL, e.headers)
+		if resp.StatusCode != e.expectedStatus {
+			t.Errorf("%s: wrong status; expected %d but got %d", e.name, e.expectedStatus, resp.StatusCode)
+		}
+		if e.expectedStatus == http.StatusSwitchingProtocols && resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("%s: wrong Sec-WebSocket-Accept %q", e.name, resp.Header.Get("Sec-WebSocket-Accept"))
+		}
+	}
+}
+
+func TestWebSocketConn_JSONMessages(t *testing.T) {
+	testTools := Tools{MaxJSONSize: 64}
+
+	type message struct {
+		Text string `json:"text"`
+	}
+
+	errs := make(chan error, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := testTools.UpgradeWebSocket(w, r, WebSocketOptions{PingInterval: -1})
+		if err != nil {
+			errs <- err
+			return
+		}
+		for {
+			var m message
+			err := conn.ReadJSONMessage(&m)
+			errs <- err
+			if errors.Is(err, ErrWebSocketClosed) || err != nil && strings.Contains(err.Error(), "larger") {
+				return
+			}
+			if err != nil {
+				_ = conn.WriteErrorMessage(err)
+				continue
+			}
+			_ = conn.WriteJSONMessage(message{Text: strings.ToUpper(m.Text)})
+		}
+	}))
+	defer srv.Close()
+
+	client, _ := dialWebSocket(t, srv.URL, validWebSocketHeaders)
+
+	// a fragmented message, with a ping in the middle
+	_ = client.send(wsText, false, []byte(`{"text":`))
+	_ = client.send(wsPing, true, []byte("hi"))
+	_ = client.send(wsContinuation, true, []byte(`"hello"}`))
+
+	op, payload, err := client.receive()
+	if err != nil || op != wsPong || string(payload) != "hi" {
+		t.Errorf("expected pong echoing the ping, got %x %q %v", op, payload, err)
+	}
+	op, payload, err = client.receive()
+	if err != nil || op != wsText || string(payload) != `{"text":"HELLO"}` {
+		t.Errorf("wrong reply; got %x %q %v", op, payload, err)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected read error %s", err)
+	}
+
+	// an unknown field is reported in the error envelope
+	_ = client.send(wsText, true, []byte(`{"other":1}`))
+	_, payload, _ = client.receive()
+	if string(payload) != `{"error":true,"message":"message contains unknown key  \"other\""}` {
+		t.Errorf("wrong error message; got %q", payload)
+	}
+	<-errs
+
+	// an oversized message closes the connection
+	_ = client.send(wsText, true, []byte(`{"text":"`+strings.Repeat("a", 100)+`"}`))
+	op, payload, _ = client.receive()
+	if op != wsClose || binary.BigEndian.Uint16(payload) != wsCloseTooBig {
+		t.Errorf("expected close 1009, got %x %v", op, payload)
+	}
+	if err := <-errs; err == nil || err.Error() != "message must not be larger than 64 bytes" {
+		t.Errorf("wrong size error %v", err)
+	}
+}
+
+func TestWebSocketConn_ClientClose(t *testing.T) {
+	var testTools Tools
+
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := testTools.UpgradeWebSocket(w, r, WebSocketOptions{PingInterval: 20 * time.Millisecond})
+		if err != nil {
+			errs <- err
+			return
+		}
+		var v any
+		errs <- conn.ReadJSONMessage(&v)
+	}))
+	defer srv.Close()
+
+	client, _ := dialWebSocket(t, srv.URL, validWebSocketHeaders)
+
+	// the server pings on its own
+	op, _, err := client.receive()
+	if err != nil || op != wsPing {
+		t.Errorf("expected ping, got %x %v", op, err)
+	}
+
+	_ = client.send(wsClose, true, binary.BigEndian.AppendUint16(nil, wsCloseNormal))
+	if err := <-errs; !errors.Is(err, ErrWebSocketClosed) {
+		t.Errorf("expected ErrWebSocketClosed, got %v", err)
+	}
+}
@@ -0,0 +1,326 @@
+package toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// XMLCharsetReader returns a reader which converts input, in the named charset, to UTF-8.
+type XMLCharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+// newXMLDecoder checks the XML document b against MaxXMLAttributes, Limits.MaxDepth or MaxXMLDepth, MaxXMLTokens and
+// AllowXMLDTD, and returns a decoder for it which reads other charsets with XMLCharsetReader. The document
+// is checked before it is decoded, rather than as it is decoded, so that ",innerxml" fields still work.
+func (t *Tools) newXMLDecoder(b []byte) (*xml.Decoder, error) {
+	charsetReader := t.XMLCharsetReader
+	if charsetReader == nil {
+		charsetReader = defaultXMLCharsetReader
+	}
+
+	if err := t.checkXMLDocument(b, charsetReader); err != nil {
+		return nil, err
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	dec.CharsetReader = charsetReader
+	return dec, nil
+}
+
+// checkXMLDocument returns an error if the XML document b breaks any of the document limits. Syntax
+// errors are ignored here; they are reported when the document is decoded.
+func (t *Tools) checkXMLDocument(b []byte, charsetReader XMLCharsetReader) error {
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	dec.CharsetReader = charsetReader
+
+	depth, tokens := 0, 0
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return nil
+		}
+
+		tokens++
+		if t.MaxXMLTokens > 0 && tokens > t.MaxXMLTokens {
+			return fmt.Errorf("body must not contain more than %d XML tokens", t.MaxXMLTokens)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if t.MaxXMLAttributes > 0 && len(tok.Attr) > t.MaxXMLAttributes {
+				return fmt.Errorf("element %s must not have more than %d attributes", tok.Name.Local, t.MaxXMLAttributes)
+			}
+			depth++
+			if maxDepth := t.xmlDepthLimit(); maxDepth > 0 && depth > maxDepth {
+				return fmt.Errorf("body must not nest XML elements more than %d deep", maxDepth)
+			}
+		case xml.EndElement:
+			depth--
+		case xml.Directive:
+			if !t.AllowXMLDTD {
+				return errors.New("body must not contain a DTD or other XML directives")
+			}
+		}
+	}
+}
+
+// windows1252 holds the characters windows-1252 puts in 0x80-0x9F, where ISO-8859-1 has control characters.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// defaultXMLCharsetReader is the charset reader used when XMLCharsetReader is nil. It converts documents
+// declared as US-ASCII, ISO-8859-1 (Latin-1) or windows-1252 to UTF-8.
+func defaultXMLCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "us-ascii", "ascii", "iso-8859-1", "iso8859-1", "latin1", "l1":
+		return &singleByteReader{r: bufio.NewReader(input)}, nil
+	case "windows-1252", "cp1252":
+		return &singleByteReader{r: bufio.NewReader(input), table: &windows1252}, nil
+	}
+	return nil, fmt.Errorf("unsupported XML charset %q", charset)
+}
+
+// singleByteReader converts a single-byte charset to UTF-8. Bytes map to the code point of the same value,
+// except for 0x80-0x9F, which are looked up in table if it is set.
+type singleByteReader struct {
+	r       *bufio.Reader
+	table   *[32]rune
+	pending []byte
+}
+
+// Read implements io.Reader.
+func (s *singleByteReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(s.pending) > 0 {
+			c := copy(p[n:], s.pending)
+			s.pending = s.pending[c:]
+			n += c
+			continue
+		}
+		// Don't block for more input once some has been read.
+		if n > 0 && s.r.Buffered() == 0 {
+			break
+		}
+
+		b, err := s.r.ReadByte()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+
+		r := rune(b)
+		if s.table != nil && b >= 0x80 && b <= 0x9F {
+			r = s.table[b-0x80]
+		}
+		if r < utf8.RuneSelf {
+			p[n] = byte(r)
+			n++
+			continue
+		}
+		s.pending = utf8.AppendRune(s.pending[:0], r)
+	}
+	return n, nil
+}
+
+var xmlMarshalerType = reflect.TypeOf((*xml.Marshaler)(nil)).Elem()
+
+// MarshalXML writes the envelope with error and message elements, and a data element if Data is set. Data
+// is written the same way as by WriteXMLWithRoot, so maps and slices are supported. The root element is
+// named XMLResponse, unless the envelope is written with WriteXMLWithRoot.
+func (r XMLResponse) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(r.Error, xml.StartElement{Name: xml.Name{Local: "error"}}); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(r.Message, xml.StartElement{Name: xml.Name{Local: "message"}}); err != nil {
+		return err
+	}
+	if r.Data != nil {
+		if err := encodeXMLValue(e, xml.StartElement{Name: xml.Name{Local: "data"}}, reflect.ValueOf(r.Data), "data"); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// marshalXML encodes data as an XML document with a root element named root. If root is empty, structs
+// are named the way encoding/xml names them, and anything else is named "response".
+func marshalXML(root string, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	start := xml.StartElement{Name: xml.Name{Local: root}}
+	if err := encodeXMLValue(enc, start, reflect.ValueOf(data), "response"); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeXMLValue writes v as the element start. Maps become an element per key, in sorted order, with
+// keys starting with "@" written as attributes and the "#text" key as character data; keys which
+// aren't valid element names are written as <entry key="...">. Slices become an <item> element per value,
+// unless the values name themselves (structs and types implementing xml.Marshaler). Everything else is
+// encoded by encoding/xml. If start has no name, defaultName is used unless v can name itself.
+func encodeXMLValue(e *xml.Encoder, start xml.StartElement, v reflect.Value, defaultName string) error {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+
+	if start.Name.Local == "" && (!v.IsValid() || !xmlNamesItself(v.Type())) {
+		start.Name.Local = defaultName
+	}
+
+	switch {
+	case !v.IsValid():
+		// nil is written as an empty element
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		return e.EncodeToken(start.End())
+
+	case v.Type().Implements(xmlMarshalerType), v.Type().Implements(textMarshalerType):
+		return encodeXMLElement(e, start, v)
+
+	case v.Kind() == reflect.Map:
+		return encodeXMLMap(e, start, v)
+
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeXMLValue(e, xml.StartElement{}, v.Index(i), "item"); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+
+	default:
+		return encodeXMLElement(e, start, v)
+	}
+}
+
+// xmlNamesItself reports whether encoding/xml can name an element holding a value of type typ.
+func xmlNamesItself(typ reflect.Type) bool {
+	return (typ.Kind() == reflect.Struct && typ.Name() != "") || typ.Implements(xmlMarshalerType)
+}
+
+// encodeXMLElement encodes v with encoding/xml, letting it name the element if start has no name.
+func encodeXMLElement(e *xml.Encoder, start xml.StartElement, v reflect.Value) error {
+	if start.Name.Local == "" {
+		return e.Encode(v.Interface())
+	}
+	return e.EncodeElement(v.Interface(), start)
+}
+
+// encodeXMLMap writes a map as the element start; see encodeXMLValue.
+func encodeXMLMap(e *xml.Encoder, start xml.StartElement, v reflect.Value) error {
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := jsonMapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		values[key] = iter.Value()
+	}
+	sort.Strings(keys)
+
+	var text *string
+	for _, key := range keys {
+		switch {
+		case strings.HasPrefix(key, "@"):
+			value, err := xmlAttrValue(values[key])
+			if err != nil {
+				return err
+			}
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: key[1:]}, Value: value})
+		case key == "#text":
+			value, err := xmlAttrValue(values[key])
+			if err != nil {
+				return err
+			}
+			text = &value
+		}
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if text != nil {
+		if err := e.EncodeToken(xml.CharData(*text)); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, "@") || key == "#text" {
+			continue
+		}
+		child := xml.StartElement{Name: xml.Name{Local: key}}
+		if !isXMLName(key) {
+			child = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}}}
+		}
+		if err := encodeXMLValue(e, child, values[key], ""); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xmlAttrValue returns the text form of a value written as an attribute or character data.
+func xmlAttrValue(v reflect.Value) (string, error) {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "", nil
+	}
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// isXMLName reports whether name can be used as an element name without a namespace prefix.
+func isXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package toolkit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var xmlHardeningTests = []struct {
+	name          string
+	xml           string
+	tools         Tools
+	expected      string
+	errorExpected string
+}{
+	{name: "defaults", xml: `<note><to>Ann</to></note>`, tools: New(), expected: "Ann"},
+	{name: "doctype rejected", xml: `<?xml version="1.0"?><!DOCTYPE note [<!ENTITY who "Ann">]><note><to>&who;</to></note>`,
+		errorExpected: "body must not contain a DTD or other XML directives"},
+	{name: "doctype allowed but entities not expanded", xml: `<!DOCTYPE note [<!ENTITY who "Ann">]><note><to>&who;</to></note>`,
+		tools: Tools{AllowXMLDTD: true}, errorExpected: "XML syntax error on line 1: invalid character entity &who;"},
+	{name: "doctype allowed", xml: `<!DOCTYPE note><note><to>Ann</to></note>`, tools: Tools{AllowXMLDTD: true}, expected: "Ann"},
+	{name: "depth within limit", xml: `<note><to>Ann</to></note>`, tools: Tools{MaxXMLDepth: 2}, expected: "Ann"},
+	{name: "too deep", xml: `<note><to><b>Ann</b></to></note>`, tools: Tools{MaxXMLDepth: 2}, errorExpected: "body must not nest XML elements more than 2 deep"},
+	{name: "tokens within limit", xml: `<note><to>Ann</to></note>`, tools: Tools{MaxXMLTokens: 5}, expected: "Ann"},
+	{name: "too many tokens", xml: `<note><to>Ann</to><!-- x --></note>`, tools: Tools{MaxXMLTokens: 5}, errorExpected: "body must not contain more than 5 XML tokens"},
+	{name: "latin-1", xml: "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><note><to>Ren\xe9e</to></note>", expected: "Renée"},
+	{name: "windows-1252", xml: "<?xml version=\"1.0\" encoding=\"windows-1252\"?><note><to>\x93Ann\x94 \x80</to></note>", expected: "“Ann” €"},
+	{name: "unsupported charset", xml: `<?xml version="1.0" encoding="EBCDIC"?><note><to>Ann</to></note>`, errorExpected: `unsupported XML charset "EBCDIC"`},
+	{name: "custom charset reader", xml: `<?xml version="1.0" encoding="upper"?><note><to>ann</to></note>`,
+		tools: Tools{XMLCharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+			b, err := io.ReadAll(input)
+			return strings.NewReader(strings.Replace(string(b), "ann", "ANN", 1)), err
+		}}, expected: "ANN"},
+	{name: "mismatched elements still caught", xml: `<note><to>Ann</from></note>`, errorExpected: "XML syntax error on line 1: element <to> closed by </from>"},
+}
+
+func TestTools_ReadXMLHardening(t *testing.T) {
+	for _, e := range xmlHardeningTests {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(e.xml)))
+		rr := httptest.NewRecorder()
+
+		var note struct {
+			To string `xml:"to"`
+		}
+		err := e.tools.ReadXML(rr, req, &note)
+
+		switch {
+		case e.errorExpected == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		case e.errorExpected != "" && (err == nil || !strings.Contains(err.Error(), e.errorExpected)):
+			t.Errorf("%s: expected error %q but got %v", e.name, e.errorExpected, err)
+		case e.errorExpected == "" && note.To != e.expected:
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, note.To)
+		}
+	}
+}
+
+func TestTools_ReadXMLNamespaces(t *testing.T) {
+	var testTools Tools
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<n:note xmlns:n="urn:notes"><n:to>Ann</n:to></n:note>`))
+
+	var note struct {
+		To string `xml:"urn:notes to"`
+	}
+	if err := testTools.ReadXML(httptest.NewRecorder(), req, &note); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if note.To != "Ann" {
+		t.Errorf("expected namespaced element to be decoded, got %q", note.To)
+	}
+}
+
+func TestTools_ReadXMLInnerXML(t *testing.T) {
+	testTools := New()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<note><body><b>Hi</b> there</body></note>`))
+
+	var note struct {
+		Body struct {
+			Raw string `xml:",innerxml"`
+		} `xml:"body"`
+	}
+	if err := testTools.ReadXML(httptest.NewRecorder(), req, &note); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if note.Body.Raw != "<b>Hi</b> there" {
+		t.Errorf("expected inner XML to be kept, got %q", note.Body.Raw)
+	}
+}
+
+func TestSingleByteReader(t *testing.T) {
+	r, _ := defaultXMLCharsetReader("latin1", iotest.OneByteReader(strings.NewReader("caf\xe9 \xff")))
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(b) != "café ÿ" {
+		t.Errorf("expected %q but got %q", "café ÿ", b)
+	}
+}
+
+type xmlBook struct {
+	ID    int    `xml:"id,attr"`
+	Title string `xml:"title"`
+}
+
+var writeXMLWithRootTests = []struct {
+	name     string
+	root     string
+	data     any
+	expected string
+}{
+	{name: "struct keeps its name", data: xmlBook{ID: 1, Title: "Go"}, expected: `<xmlBook id="1"><title>Go</title></xmlBook>`},
+	{name: "struct renamed", root: "book", data: xmlBook{ID: 1, Title: "Go"}, expected: `<book id="1"><title>Go</title></book>`},
+	{name: "map", root: "user", data: map[string]any{"name": "Ann", "age": 30, "@id": 7},
+		expected: `<user id="7"><age>30</age><name>Ann</name></user>`},
+	{name: "map without root", data: map[string]string{"a": "1"}, expected: `<response><a>1</a></response>`},
+	{name: "map with text and invalid keys", root: "v", data: map[string]any{"#text": "hi", "1st": true, "@unit": "cm"},
+		expected: `<v unit="cm">hi<entry key="1st">true</entry></v>`},
+	{name: "slice of structs", root: "books", data: []xmlBook{{ID: 1, Title: "Go"}, {ID: 2, Title: "C"}},
+		expected: `<books><xmlBook id="1"><title>Go</title></xmlBook><xmlBook id="2"><title>C</title></xmlBook></books>`},
+	{name: "slice of scalars", root: "tags", data: []string{"a", "b"}, expected: `<tags><item>a</item><item>b</item></tags>`},
+	{name: "nested", root: "r", data: map[string]any{"list": []any{1, map[string]any{"x": nil}}},
+		expected: `<r><list><item>1</item><item><x></x></item></list></r>`},
+	{name: "envelope", data: XMLResponse{Message: "ok", Data: map[string]int{"count": 2}},
+		expected: `<XMLResponse><error>false</error><message>ok</message><data><count>2</count></data></XMLResponse>`},
+	{name: "envelope renamed", root: "result", data: XMLResponse{Error: true, Message: "bad"},
+		expected: `<result><error>true</error><message>bad</message></result>`},
+}
+
+func TestTools_WriteXMLWithRoot(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range writeXMLWithRootTests {
+		rr := httptest.NewRecorder()
+		if err := testTools.WriteXMLWithRoot(rr, http.StatusOK, e.root, e.data); err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+			continue
+		}
+		expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + e.expected
+		if rr.Body.String() != expected {
+			t.Errorf("%s: wrong body;\nexpected %s\n but got %s", e.name, expected, rr.Body.String())
+		}
+	}
+}